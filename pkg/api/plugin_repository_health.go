@@ -0,0 +1,41 @@
+package api
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins/manager"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// pluginRepositoryHealthCacheKey is the cache key of the result of AdminGetPluginRepositoryHealth.
+const pluginRepositoryHealthCacheKey = "plugin-repository-health"
+
+// AdminGetPluginRepositoryHealth reports whether the plugin repository and each of its mirrors can be reached from
+// the server, and is healthy if plugins can be installed from any of them. The result is cached for a minute so that
+// monitoring doesn't flood the repository.
+func (hs *HTTPServer) AdminGetPluginRepositoryHealth(c *models.ReqContext) response.Response {
+	if cached, found := hs.CacheService.Get(pluginRepositoryHealthCacheKey); found {
+		return response.JSON(200, cached)
+	}
+
+	// Every repository is probed on its own, without failing over to the mirrors
+	i := installer.NewWithOpts(installer.Opts{}, hs.Cfg.BuildVersion, manager.New("plugin.installer", false))
+	repoURLs := append([]string{setting.GrafanaComUrl + "/api/plugins"}, hs.Cfg.PluginRepoMirrors...)
+	repositories := make([]installer.RepoHealth, 0, len(repoURLs))
+	healthy := false
+	for _, repoURL := range repoURLs {
+		health := i.CheckRepoReachable(repoURL)
+		healthy = healthy || health.Reachable
+		repositories = append(repositories, health)
+	}
+	result := map[string]interface{}{
+		"healthy":      healthy,
+		"repositories": repositories,
+	}
+
+	hs.CacheService.Set(pluginRepositoryHealthCacheKey, result, time.Minute)
+	return response.JSON(200, result)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminGetPluginRepositoryHealth(t *testing.T) {
+	type repoHealth struct {
+		URL        string `json:"url"`
+		Reachable  bool   `json:"reachable"`
+		StatusCode int    `json:"statusCode"`
+		Error      string `json:"error"`
+	}
+	type health struct {
+		Healthy      bool         `json:"healthy"`
+		Repositories []repoHealth `json:"repositories"`
+	}
+	newRepo := func(t *testing.T, status int) (*httptest.Server, *int32) {
+		var probes int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&probes, 1)
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(server.Close)
+		return server, &probes
+	}
+	setup := func(t *testing.T, primaryURL string, mirrors ...string) *HTTPServer {
+		origGrafanaComURL := setting.GrafanaComUrl
+		t.Cleanup(func() { setting.GrafanaComUrl = origGrafanaComURL })
+		setting.GrafanaComUrl = primaryURL
+		cfg := setting.NewCfg()
+		cfg.PluginRepoMirrors = mirrors
+		return &HTTPServer{CacheService: localcache.New(5*time.Minute, 10*time.Minute), Cfg: cfg}
+	}
+	get := func(t *testing.T, hs *HTTPServer) health {
+		t.Helper()
+		resp := hs.AdminGetPluginRepositoryHealth(&models.ReqContext{})
+		require.Equal(t, 200, resp.Status())
+		var result health
+		require.NoError(t, json.Unmarshal(resp.Body(), &result))
+		return result
+	}
+
+	t.Run("Should report the health of the repository and each mirror", func(t *testing.T) {
+		primary, primaryProbes := newRepo(t, http.StatusServiceUnavailable)
+		mirror, mirrorProbes := newRepo(t, http.StatusOK)
+		hs := setup(t, primary.URL, mirror.URL+"/api/plugins")
+
+		result := get(t, hs)
+		assert.True(t, result.Healthy)
+		require.Len(t, result.Repositories, 2)
+		assert.Equal(t, primary.URL+"/api/plugins", result.Repositories[0].URL)
+		assert.False(t, result.Repositories[0].Reachable)
+		assert.Equal(t, http.StatusServiceUnavailable, result.Repositories[0].StatusCode)
+		assert.NotEmpty(t, result.Repositories[0].Error)
+		assert.Equal(t, mirror.URL+"/api/plugins", result.Repositories[1].URL)
+		assert.True(t, result.Repositories[1].Reachable)
+		// Each repository is probed once, the primary doesn't fail over to the mirror
+		assert.Equal(t, int32(1), atomic.LoadInt32(primaryProbes))
+		assert.Equal(t, int32(1), atomic.LoadInt32(mirrorProbes))
+	})
+
+	t.Run("Should be unhealthy if no repository is reachable", func(t *testing.T) {
+		primary, _ := newRepo(t, http.StatusBadGateway)
+		hs := setup(t, primary.URL)
+
+		result := get(t, hs)
+		assert.False(t, result.Healthy)
+		require.Len(t, result.Repositories, 1)
+		assert.False(t, result.Repositories[0].Reachable)
+	})
+
+	t.Run("Should cache the result", func(t *testing.T) {
+		primary, probes := newRepo(t, http.StatusOK)
+		hs := setup(t, primary.URL)
+
+		first := get(t, hs)
+		second := get(t, hs)
+		assert.Equal(t, first, second)
+		assert.Equal(t, int32(1), atomic.LoadInt32(probes))
+
+		hs.CacheService.Delete(pluginRepositoryHealthCacheKey)
+		get(t, hs)
+		assert.Equal(t, int32(2), atomic.LoadInt32(probes))
+	})
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/commands/datamigrations"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
-	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/setting"
@@ -65,27 +64,63 @@ func runPluginCommand(command func(commandLine utils.CommandLine) error) func(co
 }
 
 // Command contains command state.
-type Command struct {
-	Client utils.ApiClient
-}
+type Command struct{}
 
-var cmd Command = Command{
-	Client: &services.GrafanaComClient{},
-}
+var cmd Command = Command{}
 
 var pluginCommands = []*cli.Command{
 	{
 		Name:   "install",
-		Usage:  "install <plugin id> <plugin version (optional)>",
+		Usage:  "install [<source alias>:]<plugin id>[@<version or channel>] <plugin version (optional)>",
 		Action: runPluginCommand(cmd.installCommand),
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "from-lockfile",
+				Usage: "Install only the plugins pinned by --lockfile, all of them if no plugin is specified",
+			},
+			&cli.BoolFlag{
+				Name:  "skip-deps",
+				Usage: "Install only the requested plugin, without its dependencies",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Download and verify the plugin and its dependencies and print what would be installed",
+			},
+		},
+	}, {
+		Name:   "approve",
+		Usage:  "approve <plugin id> installed with --quarantineOnly",
+		Action: runPluginCommand(cmd.approveCommand),
 	}, {
 		Name:   "list-remote",
 		Usage:  "list remote available plugins",
 		Action: runPluginCommand(cmd.listRemoteCommand),
+	}, {
+		Name:   "search",
+		Usage:  "search [<query>] for plugins in the remote repository",
+		Action: runPluginCommand(cmd.searchCommand),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "type",
+				Usage: "Only find plugins of this type: panel, datasource or app",
+			},
+			&cli.StringFlag{
+				Name:  "signature",
+				Usage: "Only find plugins signed at least at this level: private, community, commercial or grafana",
+			},
+			&cli.IntFlag{
+				Name:  "limit",
+				Usage: "Maximum number of plugins to find, 0 finds all",
+			},
+		},
 	}, {
 		Name:   "list-versions",
 		Usage:  "list-versions <plugin id>",
 		Action: runPluginCommand(cmd.listVersionsCommand),
+	}, {
+		Name:   "deps",
+		Usage:  "deps [<source alias>:]<plugin id>[@<version or channel>] <plugin version (optional)> prints the dependency tree of a plugin",
+		Action: runPluginCommand(cmd.depsCommand),
 	}, {
 		Name:    "update",
 		Usage:   "update <plugin id>",
@@ -100,11 +135,66 @@ var pluginCommands = []*cli.Command{
 		Name:   "ls",
 		Usage:  "list all installed plugins",
 		Action: runPluginCommand(cmd.lsCommand),
+	}, {
+		Name:   "verify",
+		Usage:  "verify the signatures and checksums of all installed plugins",
+		Action: runPluginCommand(cmd.verifyCommand),
+	}, {
+		Name:   "doctor",
+		Usage:  "check that the plugin repository and plugin sources can be reached",
+		Action: runPluginCommand(cmd.doctorCommand),
+	}, {
+		Name:   "purge-cache",
+		Usage:  "remove all plugin archives from the download cache",
+		Action: runPluginCommand(cmd.purgeCacheCommand),
+	}, {
+		Name:   "export",
+		Usage:  "export <plugin id>[@<version>]... downloads plugins and their dependencies into an offline bundle",
+		Action: runPluginCommand(cmd.exportCommand),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "Directory, or .tar.gz file, to write the bundle to",
+			},
+			&cli.StringSliceFlag{
+				Name:  "platform",
+				Usage: "Platform to export the plugins for, as <os>-<arch>, defaults to the current one",
+			},
+		},
+	}, {
+		Name:   "import",
+		Usage:  "import <bundle> [<plugin id>[@<version>]...] installs plugins from an offline bundle",
+		Action: runPluginCommand(cmd.importCommand),
+	}, {
+		Name:   "serve-repo",
+		Usage:  "serve-repo <directory> serves a directory of plugin zips as a static plugin repository",
+		Action: runPluginCommand(cmd.serveRepoCommand),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "listen",
+				Usage: "Address to serve the plugin repository on",
+				Value: "localhost:3011",
+			},
+			&cli.BoolFlag{
+				Name:  "index-only",
+				Usage: "Only write the index.json of the directory instead of serving it",
+			},
+		},
 	}, {
 		Name:    "uninstall",
 		Aliases: []string{"remove"},
 		Usage:   "uninstall <plugin id>",
 		Action:  runPluginCommand(cmd.removeCommand),
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Remove the plugin even if installed plugins require it",
+			},
+			&cli.BoolFlag{
+				Name:  "prune",
+				Usage: "Also remove the dependencies no other installed plugin requires anymore",
+			},
+		},
 	},
 }
 
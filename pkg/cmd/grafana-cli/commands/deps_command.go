@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+)
+
+// depsCommand prints the plugins installing a plugin would pull in, with the versions they resolve to and where
+// they're downloaded from, without installing anything.
+func (cmd Command) depsCommand(c utils.CommandLine) error {
+	pluginID := c.Args().First()
+	if pluginID == "" {
+		return errors.New("please specify plugin to list dependencies for")
+	}
+
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
+	i := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger)
+	tree, err := i.ResolveDependencyTree(pluginID, c.Args().Get(1), c.PluginRepoURL())
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := installer.RenderDependencyTree(&buf, tree); err != nil {
+		return err
+	}
+	logger.Info(buf.String())
+	return nil
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+)
+
+// doctorCommand checks whether the plugin repository, its mirrors and the configured plugin sources can be
+// reached with the current TLS, proxy and credential settings, and fails if any of them can't.
+func (cmd Command) doctorCommand(c utils.CommandLine) error {
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
+	i := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger)
+
+	repoURLs := append([]string{c.PluginRepoURL()}, opts.RepoMirrors...)
+	aliases := make([]string, 0, len(opts.SourceAliases))
+	for name := range opts.SourceAliases {
+		aliases = append(aliases, name)
+	}
+	sort.Strings(aliases)
+	for _, name := range aliases {
+		repoURLs = append(repoURLs, opts.SourceAliases[name].URL)
+	}
+
+	failed := 0
+	for _, repoURL := range repoURLs {
+		health := i.CheckRepoReachable(repoURL)
+		switch {
+		case !health.Reachable:
+			failed++
+			logger.Infof("%s %s\n", color.RedString("✘"), health)
+		case health.Error != "":
+			logger.Infof("%s %s\n", color.YellowString("!"), health)
+		default:
+			logger.Infof("%s %s\n", color.GreenString("✔"), health)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d plugin repositories are unreachable", failed, len(repoURLs))
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+)
+
+// exportCommand downloads plugins and their dependencies into an offline bundle, which air-gapped sites install
+// from by passing it as plugin repository.
+func (cmd Command) exportCommand(c utils.CommandLine) error {
+	if c.Args().Len() == 0 {
+		return errors.New("please specify plugins to export")
+	}
+	output := c.String("output")
+	if output == "" {
+		return errors.New("please specify the bundle to write with --output")
+	}
+
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
+	i := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger)
+	if err := i.ExportBundle(c.Args().Slice(), output, c.PluginRepoURL(), c.StringSlice("platform")); err != nil {
+		return err
+	}
+
+	logger.Infof("Exported offline bundle to %s\n", output)
+	return nil
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+)
+
+// importCommand installs plugins from an offline bundle written by the export command, without network access.
+func (cmd Command) importCommand(c utils.CommandLine) error {
+	pluginFolder := c.PluginDirectory()
+	if err := validateInput(c, pluginFolder); err != nil {
+		return err
+	}
+
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
+	bundlePath := c.Args().First()
+	finishProgress := withProgressBar(c, &opts)
+	i := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger)
+	err = i.ImportBundle(c.Args().Tail(), bundlePath, pluginFolder)
+	finishProgress()
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Installed plugins from offline bundle %s\n", bundlePath)
+	return nil
+}
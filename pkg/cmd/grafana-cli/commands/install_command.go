@@ -1,23 +1,15 @@
 package commands
 
 import (
-	"archive/zip"
-	"bytes"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"os"
-	"path/filepath"
-	"regexp"
-	"runtime"
 	"strings"
 
-	"github.com/fatih/color"
-	"github.com/grafana/grafana/pkg/cmd/grafana-cli/models"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
 	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/util/errutil"
 
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
@@ -51,308 +43,361 @@ func validateInput(c utils.CommandLine, pluginFolder string) error {
 
 func (cmd Command) installCommand(c utils.CommandLine) error {
 	pluginFolder := c.PluginDirectory()
+	if c.Bool("from-lockfile") && c.Args().First() == "" {
+		return installFromLockfile(c, pluginFolder)
+	}
 	if err := validateInput(c, pluginFolder); err != nil {
 		return err
 	}
 
 	pluginID := c.Args().First()
 	version := c.Args().Get(1)
-	skipTLSVerify := c.Bool("insecure")
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
 
-	i := installer.New(skipTLSVerify, services.GrafanaVersion, services.Logger)
-	return i.Install(pluginID, version, c.PluginDirectory(), c.PluginURL(), c.PluginRepoURL())
+	finishProgress := withProgressBar(c, &opts)
+	defer finishProgress()
+	i := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger)
+	plan, err := i.PlanInstall(pluginID, version, c.PluginDirectory(), c.PluginURL(), c.PluginRepoURL())
+	if err != nil {
+		return err
+	}
+	if c.Bool("dry-run") {
+		defer plan.Close()
+		finishProgress()
+		logger.Info(formatInstallPlan(plan))
+		return nil
+	}
+	return i.ApplyPlan(plan)
 }
 
-// InstallPlugin downloads the plugin code as a zip file from the Grafana.com API
-// and then extracts the zip into the plugins directory.
-func InstallPlugin(pluginName, version string, c utils.CommandLine, client utils.ApiClient) error {
-	pluginFolder := c.PluginDirectory()
-	downloadURL := c.PluginURL()
-	isInternal := false
-
-	var checksum string
-	if downloadURL == "" {
-		if strings.HasPrefix(pluginName, "grafana-") {
-			// At this point the plugin download is going through grafana.com API and thus the name is validated.
-			// Checking for grafana prefix is how it is done there so no 3rd party plugin should have that prefix.
-			// You can supply custom plugin name and then set custom download url to 3rd party plugin but then that
-			// is up to the user to know what she is doing.
-			isInternal = true
+// formatInstallPlan lists the plugins the plan installs, one per line.
+func formatInstallPlan(plan *installer.InstallPlan) string {
+	var sb strings.Builder
+	for _, p := range plan.Plugins {
+		action := "install"
+		if p.Update {
+			action = "update"
 		}
-		plugin, err := client.GetPlugin(pluginName, c.PluginRepoURL())
-		if err != nil {
-			return err
+		fmt.Fprintf(&sb, "%s %s v%s", action, p.ID, p.Version)
+		if p.RequiredBy != "" {
+			fmt.Fprintf(&sb, " (required by %s)", p.RequiredBy)
+		}
+		fmt.Fprintf(&sb, " from %s\n", p.Source)
+		for _, nested := range p.Nested {
+			fmt.Fprintf(&sb, "  nested %s %s in %s\n", nested.Type, nested.ID, nested.Path)
 		}
+	}
+	return sb.String()
+}
+
+// installFromLockfile installs all plugins pinned by the lockfile.
+func installFromLockfile(c utils.CommandLine, pluginFolder string) error {
+	if c.String("lockfile") == "" {
+		return errors.New("please specify the lockfile to install from with --lockfile")
+	}
+	if err := os.MkdirAll(pluginFolder, os.ModePerm); err != nil {
+		return fmt.Errorf("pluginsDir (%s) is not a writable directory", pluginFolder)
+	}
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
+	defer withProgressBar(c, &opts)()
+	return installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger).InstallFromLockfile(pluginFolder)
+}
 
-		v, err := SelectVersion(&plugin, version)
+// approveCommand installs a plugin that was installed in quarantine-only mode.
+func (cmd Command) approveCommand(c utils.CommandLine) error {
+	pluginID := c.Args().First()
+	if pluginID == "" {
+		quarantined, err := installer.QuarantinedPlugins(c.PluginDirectory())
 		if err != nil {
 			return err
 		}
-
-		if version == "" {
-			version = v.Version
-		}
-		downloadURL = fmt.Sprintf("%s/%s/versions/%s/download",
-			c.String("repo"),
-			pluginName,
-			version,
-		)
-
-		// Plugins which are downloaded just as sourcecode zipball from github do not have checksum
-		if v.Arch != nil {
-			archMeta, exists := v.Arch[osAndArchString()]
-			if !exists {
-				archMeta = v.Arch["any"]
-			}
-			checksum = archMeta.SHA256
+		if len(quarantined) == 0 {
+			return errors.New("please specify plugin to approve, no plugins are awaiting approval")
 		}
+		return fmt.Errorf("please specify plugin to approve, plugins awaiting approval: %s",
+			strings.Join(quarantined, ", "))
 	}
 
-	logger.Infof("installing %v @ %v\n", pluginName, version)
-	logger.Infof("from: %v\n", downloadURL)
-	logger.Infof("into: %v\n", pluginFolder)
-	logger.Info("\n")
-
-	// Create temp file for downloading zip file
-	tmpFile, err := ioutil.TempFile("", "*.zip")
+	opts, err := installerOpts(c)
 	if err != nil {
-		return errutil.Wrap("failed to create temporary file", err)
+		return err
 	}
-	defer func() {
-		if err := os.Remove(tmpFile.Name()); err != nil {
-			logger.Warn("Failed to remove temporary file", "file", tmpFile.Name(), "err", err)
-		}
-	}()
+	return installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger).Approve(pluginID, c.PluginDirectory())
+}
 
-	err = client.DownloadFile(pluginName, tmpFile, downloadURL, checksum)
+// installerOpts returns the installer options from the command line flags and the Grafana configuration.
+func installerOpts(c utils.CommandLine) (installer.Opts, error) {
+	channel, err := installer.ParseChannel(c.String("channel"))
 	if err != nil {
-		if err := tmpFile.Close(); err != nil {
-			logger.Warn("Failed to close file", "err", err)
-		}
-		return errutil.Wrap("failed to download plugin archive", err)
+		return installer.Opts{}, err
 	}
-	err = tmpFile.Close()
+	signaturePolicy, err := installer.ParseSignaturePolicy(c.String("signaturePolicy"))
 	if err != nil {
-		return errutil.Wrap("failed to close tmp file", err)
+		return installer.Opts{}, err
 	}
-
-	err = extractFiles(tmpFile.Name(), pluginName, pluginFolder, isInternal)
+	advisoryPolicy, err := installer.ParseAdvisoryPolicy(c.String("advisoryPolicy"))
 	if err != nil {
-		return errutil.Wrap("failed to extract plugin archive", err)
+		return installer.Opts{}, err
 	}
-
-	logger.Infof("%s Installed %s successfully \n", color.GreenString("✔"), pluginName)
-
-	res, _ := services.ReadPlugin(pluginFolder, pluginName)
-	for _, v := range res.Dependencies.Plugins {
-		if err := InstallPlugin(v.ID, "", c, client); err != nil {
-			return errutil.Wrapf(err, "failed to install plugin '%s'", v.ID)
-		}
-
-		logger.Infof("Installed dependency: %v ✔\n", v.ID)
+	minSignatureLevel, err := installer.ParseSignatureLevel(c.String("minSignatureLevel"))
+	if err != nil {
+		return installer.Opts{}, err
 	}
-
-	return err
-}
-
-func osAndArchString() string {
-	osString := strings.ToLower(runtime.GOOS)
-	arch := runtime.GOARCH
-	return osString + "-" + arch
-}
-
-func supportsCurrentArch(version *models.Version) bool {
-	if version.Arch == nil {
-		return true
+	pinnedKeys, err := installer.ParsePinnedKeys(c.StringSlice("pinnedKey"))
+	if err != nil {
+		return installer.Opts{}, err
 	}
-	for arch := range version.Arch {
-		if arch == osAndArchString() || arch == "any" {
-			return true
-		}
+	hostOverrides, err := installer.ParseHostOverrides(c.StringSlice("hostOverride"))
+	if err != nil {
+		return installer.Opts{}, err
 	}
-	return false
-}
-
-func latestSupportedVersion(plugin *models.Plugin) *models.Version {
-	for _, v := range plugin.Versions {
-		ver := v
-		if supportsCurrentArch(&ver) {
-			return &ver
-		}
+	ipFamily, err := installer.ParseIPFamily(c.String("ipFamily"))
+	if err != nil {
+		return installer.Opts{}, err
 	}
-	return nil
-}
-
-// SelectVersion returns latest version if none is specified or the specified version. If the version string is not
-// matched to existing version it errors out. It also errors out if version that is matched is not available for current
-// os and platform. It expects plugin.Versions to be sorted so the newest version is first.
-func SelectVersion(plugin *models.Plugin, version string) (*models.Version, error) {
-	var ver models.Version
-
-	latestForArch := latestSupportedVersion(plugin)
-	if latestForArch == nil {
-		return nil, fmt.Errorf("plugin is not supported on your architecture and OS")
+	repoAPIVersion, err := installer.ParseRepoAPIVersion(c.String("repoApi"))
+	if err != nil {
+		return installer.Opts{}, err
 	}
-
-	if version == "" {
-		return latestForArch, nil
+	opts := installer.Opts{
+		SkipTLSVerify:         c.Bool("insecure"),
+		AllowInsecureHTTP:     c.Bool("allowInsecureHttp"),
+		CACertPath:            c.String("caCert"),
+		ClientCertPath:        c.String("clientCert"),
+		ClientKeyPath:         c.String("clientKey"),
+		PinnedKeys:            pinnedKeys,
+		FIPSMode:              c.Bool("fips"),
+		GitLabURL:             c.String("gitlabUrl"),
+		GitLabToken:           c.String("gitlabToken"),
+		ManifestKeyringPath:   c.String("manifestKeyring"),
+		ArchivePassphrase:     c.String("archivePassphrase"),
+		ArchivePassphraseFile: c.String("archivePassphraseFile"),
+		Channel:               channel,
+		AllowPrerelease:       c.Bool("allowPrerelease"),
+		ChecksumURL:           c.String("checksumUrl"),
+		FetchChecksumFile:     c.Bool("verifyChecksumFile"),
+		RequireChecksum:       c.Bool("requireChecksum"),
+		SumFilePath:           c.String("sumFile"),
+		SumFileKeyringPath:    c.String("sumFileKeyring"),
+		LockfilePath:          c.String("lockfile"),
+		FromLockfile:          c.Bool("from-lockfile"),
+		SkipDependencies:      c.Bool("skip-deps"),
+		ForceUninstall:        c.Bool("force"),
+		PruneDependencies:     c.Bool("prune"),
+		StreamExtraction:      c.Bool("streamExtraction"),
+		Enterprise:            c.Bool("enterprise"),
+		EnterpriseRepoURL:     c.String("enterpriseRepo"),
+		RepoMirrors:           c.StringSlice("repoMirror"),
+		LicensePath:           c.String("licensePath"),
+		BinaryPluginJSONPath:  c.String("binaryPluginJson"),
+		BinaryPluginType:      c.String("binaryPluginType"),
+		SignaturePolicy:       signaturePolicy,
+		MinSignatureLevel:     minSignatureLevel,
+		IgnoreRootURLs:        c.Bool("ignoreRootUrls"),
+		AllowedPlugins:        c.StringSlice("allowPlugins"),
+		DeniedPlugins:         c.StringSlice("denyPlugins"),
+		AllowedSources:        c.StringSlice("allowSources"),
+		RedirectHosts:         c.StringSlice("redirectHosts"),
+		SourcePolicyPath:      c.String("sourcePolicy"),
+		AdvisoryPolicy:        advisoryPolicy,
+		AdvisoryURL:           c.String("advisoryUrl"),
+		QuarantineOnly:        c.Bool("quarantineOnly"),
+		AuditLogPath:          c.String("auditLog"),
+		SignatureKeyringPath:  c.String("signatureKeyring"),
+		SignatureURL:          c.String("signatureUrl"),
+		Cosign: installer.CosignOpts{
+			KeyPath:            c.String("cosignKey"),
+			Identity:           c.String("cosignIdentity"),
+			OIDCIssuer:         c.String("cosignOidcIssuer"),
+			RootsPath:          c.String("cosignRoots"),
+			RekorURL:           c.String("rekorUrl"),
+			RekorPublicKeyPath: c.String("rekorPublicKey"),
+			IgnoreTlog:         c.Bool("cosignIgnoreTlog"),
+		},
+		Provenance: installer.ProvenanceOpts{
+			KeyPath:     c.String("provenanceKey"),
+			URL:         c.String("provenanceUrl"),
+			BuilderIDs:  c.StringSlice("provenanceBuilder"),
+			SourceRepos: c.StringSlice("provenanceSource"),
+		},
+		TUF: installer.TUFOpts{
+			RootPath:    c.String("tufRoot"),
+			RepoURL:     c.String("tufRepo"),
+			MetadataURL: c.String("tufMetadataUrl"),
+		},
+		Limits: installer.ExtractionLimits{
+			MaxArchiveSize: int64(c.Int("maxArchiveSize")) << 20,
+			MaxFileSize:    int64(c.Int("maxFileSize")) << 20,
+			MaxTotalSize:   int64(c.Int("maxExtractedSize")) << 20,
+			MaxEntries:     c.Int("maxArchiveEntries"),
+		},
+		MinFreeDiskSpace: int64(c.Int("minFreeDiskSpace")) << 20,
+		TempDir:          c.String("tempDir"),
+		ContentFilter: installer.ContentFilter{
+			DenyNestedArchives: c.Bool("denyNestedArchives"),
+			DeniedExtensions:   c.StringSlice("denyExtension"),
+		},
+		AngularSupportDisabled: c.Bool("disableAngular"),
+		MetadataCache: installer.MetadataCacheOpts{
+			Dir:      c.String("metadataCacheDir"),
+			MaxAge:   c.Duration("metadataCacheMaxAge"),
+			MaxStale: c.Duration("metadataCacheMaxStale"),
+		},
+		DownloadCache: installer.DownloadCacheOpts{
+			Dir:     c.String("downloadCacheDir"),
+			MaxSize: int64(c.Int("downloadCacheMaxSize")) << 20,
+		},
+		MirrorSelection: installer.MirrorSelectionOpts{
+			ByLatency:     c.Bool("repoMirrorsByLatency"),
+			ProbeInterval: c.Duration("repoMirrorProbeInterval"),
+		},
+		RepoAPIVersion: repoAPIVersion,
+		Retry: installer.RetryOpts{
+			MaxRetries:    c.Int("repoRetries"),
+			MaxRetryAfter: c.Duration("repoMaxRetryAfter"),
+		},
+		GrafanaVersion: services.TargetGrafanaVersion,
+		DownloadTransport: installer.TransportOpts{
+			HTTP2:                  c.Bool("downloadHttp2"),
+			MaxConnsPerHost:        c.Int("downloadMaxConnsPerHost"),
+			ReadBufferSize:         c.Int("downloadReadBufferSize"),
+			WriteBufferSize:        c.Int("downloadWriteBufferSize"),
+			KeepAlive:              c.Duration("downloadKeepAlive"),
+			Timeout:                c.Duration("downloadTimeout"),
+			ConnectTimeout:         c.Duration("downloadConnectTimeout"),
+			TLSHandshakeTimeout:    c.Duration("downloadTlsTimeout"),
+			ResponseHeaderTimeout:  c.Duration("downloadResponseHeaderTimeout"),
+			IdleReadTimeout:        c.Duration("downloadIdleTimeout"),
+			RateLimit:              int64(c.Int("downloadRateLimit")),
+			MaxConcurrentDownloads: c.Int("downloadConcurrency"),
+		},
 	}
-	for _, v := range plugin.Versions {
-		if v.Version == version {
-			ver = v
-			break
-		}
+	repoCredentials := installer.RepoCredentials{
+		Token:            c.String("repoToken"),
+		Username:         c.String("repoUsername"),
+		Password:         c.String("repoPassword"),
+		Header:           c.String("repoHeader"),
+		CredentialHelper: c.String("repoCredentialHelper"),
 	}
-
-	if len(ver.Version) == 0 {
-		return nil, fmt.Errorf("could not find the version you're looking for")
+	if repoCredentials != (installer.RepoCredentials{}) {
+		opts.RepoCredentials = map[string]installer.RepoCredentials{c.PluginRepoURL(): repoCredentials}
 	}
-
-	if !supportsCurrentArch(&ver) {
-		return nil, fmt.Errorf(
-			"the version you want is not supported on your architecture and OS, latest suitable version is %s",
-			latestForArch.Version)
+	if c.String("repoProxy") != "" || len(c.StringSlice("repoNoProxy")) > 0 {
+		opts.RepoProxies = map[string]installer.RepoProxy{c.PluginRepoURL(): {
+			URL:     c.String("repoProxy"),
+			NoProxy: c.StringSlice("repoNoProxy"),
+		}}
 	}
+	opts.SOCKSProxy = c.String("socksProxy")
+	opts.DNS = installer.DNSOpts{HostOverrides: hostOverrides, Server: c.String("dnsServer"), IPFamily: ipFamily}
+	opts.ProxyCredentials = installer.ProxyCredentials{
+		Token:    c.String("proxyToken"),
+		Username: c.String("proxyUsername"),
+		Password: c.String("proxyPassword"),
+	}
+	if opts.TUF.RootPath != "" && opts.TUF.RepoURL == "" {
+		opts.TUF.RepoURL = c.PluginRepoURL()
+	}
+	if err := applyConfigSettings(c, &opts); err != nil {
+		return installer.Opts{}, err
+	}
+	return opts, nil
 
-	return &ver, nil
-}
-
-var reGitBuild = regexp.MustCompile("^[a-zA-Z0-9_.-]*/")
-
-func removeGitBuildFromName(pluginName, filename string) string {
-	return reGitBuild.ReplaceAllString(filename, pluginName+"/")
 }
 
-const permissionsDeniedMessage = "could not create %q, permission denied, make sure you have write access to plugin dir"
-
-func extractFiles(archiveFile string, pluginName string, dstDir string, allowSymlinks bool) error {
-	var err error
-	dstDir, err = filepath.Abs(dstDir)
-	if err != nil {
-		return err
+// applyConfigSettings reads the plugin source aliases, the Enterprise license path, the plugins allowed to be
+// unsigned, the plugin install allow and deny lists, the source policy, the audit log, the root URL and whether
+// Angular support is enabled from the Grafana configuration when a config file or home path is provided.
+func applyConfigSettings(c utils.CommandLine, opts *installer.Opts) error {
+	if c.String("config") == "" && c.String("homepath") == "" {
+		return nil
 	}
-	logger.Debugf("Extracting archive %q to %q...\n", archiveFile, dstDir)
 
-	existingInstallDir := filepath.Join(dstDir, pluginName)
-	if _, err := os.Stat(existingInstallDir); !os.IsNotExist(err) {
-		err = os.RemoveAll(existingInstallDir)
-		if err != nil {
-			return err
-		}
-
-		logger.Infof("Removed existing installation of %s\n\n", pluginName)
+	cfg := setting.NewCfg()
+	if err := cfg.Load(&setting.CommandLineArgs{
+		Config:   c.String("config"),
+		HomePath: c.String("homepath"),
+		Args:     strings.Split(c.String("configOverrides"), " "),
+	}); err != nil {
+		return errutil.Wrap("failed to load configuration", err)
 	}
 
-	r, err := zip.OpenReader(archiveFile)
-	if err != nil {
-		return err
+	if opts.LicensePath == "" {
+		opts.LicensePath = cfg.EnterpriseLicensePath
 	}
-	for _, zf := range r.File {
-		if filepath.IsAbs(zf.Name) || strings.HasPrefix(zf.Name, ".."+string(filepath.Separator)) {
-			return fmt.Errorf(
-				"archive member %q tries to write outside of plugin directory: %q, this can be a security risk",
-				zf.Name, dstDir)
-		}
-
-		dstPath := filepath.Clean(filepath.Join(dstDir, removeGitBuildFromName(pluginName, zf.Name)))
-
-		if zf.FileInfo().IsDir() {
-			// We can ignore gosec G304 here since it makes sense to give all users read access
-			// nolint:gosec
-			if err := os.MkdirAll(dstPath, 0755); err != nil {
-				if os.IsPermission(err) {
-					return fmt.Errorf(permissionsDeniedMessage, dstPath)
-				}
-
-				return err
-			}
-
-			continue
-		}
-
-		// Create needed directories to extract file
-		// We can ignore gosec G304 here since it makes sense to give all users read access
-		// nolint:gosec
-		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
-			return errutil.Wrap("failed to create directory to extract plugin files", err)
-		}
-
-		if isSymlink(zf) {
-			if !allowSymlinks {
-				logger.Warnf("%v: plugin archive contains a symlink, which is not allowed. Skipping \n", zf.Name)
-				continue
-			}
-			if err := extractSymlink(zf, dstPath); err != nil {
-				logger.Errorf("Failed to extract symlink: %v \n", err)
-				continue
-			}
-			continue
-		}
-
-		if err := extractFile(zf, dstPath); err != nil {
-			return errutil.Wrap("failed to extract file", err)
-		}
+	opts.AllowUnsigned = cfg.PluginsAllowUnsigned
+	opts.AppURL = cfg.AppURL
+	opts.AngularSupportDisabled = opts.AngularSupportDisabled || !cfg.AngularSupportEnabled
+	opts.AllowedPlugins = append(opts.AllowedPlugins, cfg.PluginsInstallAllowList...)
+	opts.DeniedPlugins = append(opts.DeniedPlugins, cfg.PluginsInstallDenyList...)
+	opts.AllowedSources = append(opts.AllowedSources, cfg.PluginsAllowedSources...)
+	opts.RedirectHosts = append(opts.RedirectHosts, cfg.PluginsRedirectHosts...)
+	opts.RepoMirrors = append(opts.RepoMirrors, cfg.PluginRepoMirrors...)
+	opts.MirrorSelection.ByLatency = opts.MirrorSelection.ByLatency || cfg.PluginRepoMirrorsByLatency
+	if opts.MirrorSelection.ProbeInterval == 0 {
+		opts.MirrorSelection.ProbeInterval = cfg.PluginRepoMirrorProbeInterval
 	}
-
-	return nil
-}
-
-func isSymlink(file *zip.File) bool {
-	return file.Mode()&os.ModeSymlink == os.ModeSymlink
-}
-
-func extractSymlink(file *zip.File, filePath string) error {
-	// symlink target is the contents of the file
-	src, err := file.Open()
-	if err != nil {
-		return errutil.Wrap("failed to extract file", err)
+	if opts.SourcePolicyPath == "" {
+		opts.SourcePolicyPath = cfg.PluginsSourcePolicyPath
 	}
-	buf := new(bytes.Buffer)
-	if _, err := io.Copy(buf, src); err != nil {
-		return errutil.Wrap("failed to copy symlink contents", err)
+	if opts.AuditLogPath == "" {
+		opts.AuditLogPath = cfg.PluginsInstallAuditLog
 	}
-	if err := os.Symlink(strings.TrimSpace(buf.String()), filePath); err != nil {
-		return errutil.Wrapf(err, "failed to make symbolic link for %v", filePath)
+	opts.ContentFilter.DenyNestedArchives = opts.ContentFilter.DenyNestedArchives || cfg.PluginsInstallDenyNestedArchives
+	opts.ContentFilter.DeniedExtensions = append(opts.ContentFilter.DeniedExtensions, cfg.PluginsInstallDeniedExtensions...)
+	if opts.MetadataCache.Dir == "" {
+		opts.MetadataCache.Dir = cfg.PluginRepoMetadataCacheDir
 	}
-	return nil
-}
-
-func extractFile(file *zip.File, filePath string) (err error) {
-	fileMode := file.Mode()
-	// This is entry point for backend plugins so we want to make them executable
-	if strings.HasSuffix(filePath, "_linux_amd64") || strings.HasSuffix(filePath, "_darwin_amd64") {
-		fileMode = os.FileMode(0755)
+	if opts.MetadataCache.MaxAge == 0 {
+		opts.MetadataCache.MaxAge = cfg.PluginRepoMetadataCacheMaxAge
 	}
-
-	// We can ignore the gosec G304 warning on this one, since the variable part of the file path stems
-	// from command line flag "pluginsDir", and the only possible damage would be writing to the wrong directory.
-	// If the user shouldn't be writing to this directory, they shouldn't have the permission in the file system.
-	// nolint:gosec
-	dst, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, fileMode)
-	if err != nil {
-		if os.IsPermission(err) {
-			return fmt.Errorf(permissionsDeniedMessage, filePath)
-		}
-
-		unwrappedError := errors.Unwrap(err)
-		if unwrappedError != nil && strings.EqualFold(unwrappedError.Error(), "text file busy") {
-			return fmt.Errorf("file %q is in use - please stop Grafana, install the plugin and restart Grafana", filePath)
+	if opts.MetadataCache.MaxStale == 0 {
+		opts.MetadataCache.MaxStale = cfg.PluginRepoMetadataCacheMaxStale
+	}
+	if opts.DownloadCache.Dir == "" {
+		opts.DownloadCache.Dir = cfg.PluginDownloadCacheDir
+	}
+	if opts.DownloadCache.MaxSize == 0 {
+		opts.DownloadCache.MaxSize = cfg.PluginDownloadCacheMaxSize
+	}
+	opts.SourceAliases = make(map[string]installer.SourceAlias, len(cfg.PluginSourceAliases))
+	for name, alias := range cfg.PluginSourceAliases {
+		opts.SourceAliases[name] = installer.SourceAlias{
+			URL:              alias.URL,
+			Token:            alias.Token,
+			Username:         alias.Username,
+			Password:         alias.Password,
+			Header:           alias.Header,
+			CredentialHelper: alias.CredentialHelper,
+			SkipTLSVerify:    alias.SkipTLSVerify,
+			CACertPath:       alias.CACertPath,
+			ClientCertPath:   alias.ClientCertPath,
+			ClientKeyPath:    alias.ClientKeyPath,
+			Proxy:            alias.Proxy,
+			NoProxy:          alias.NoProxy,
+			Plugins:          alias.Plugins,
+			Priority:         alias.Priority,
 		}
-
-		return errutil.Wrap("failed to open file", err)
 	}
-	defer func() {
-		err = dst.Close()
-	}()
-
-	src, err := file.Open()
-	if err != nil {
-		return errutil.Wrap("failed to extract file", err)
+	opts.DependencyPins = make(map[string]installer.DependencyPin, len(cfg.PluginPins))
+	for pluginID, pin := range cfg.PluginPins {
+		opts.DependencyPins[pluginID] = installer.DependencyPin{Version: pin.Version, Source: pin.Source}
 	}
-	defer func() {
-		err = src.Close()
-	}()
-
-	_, err = io.Copy(dst, src)
-	return err
+	opts.DependencyProviders = map[string]string{}
+	for provider, provided := range cfg.PluginProvides {
+		for _, pluginID := range provided {
+			if other, exists := opts.DependencyProviders[pluginID]; exists && other != provider {
+				return fmt.Errorf("plugin %s is provided by both %s and %s in [plugin_provides]", pluginID, other,
+					provider)
+			}
+			opts.DependencyProviders[pluginID] = provider
+		}
+	}
+	return nil
 }
@@ -2,24 +2,30 @@ package commands
 
 import (
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+	"github.com/grafana/grafana/pkg/plugins/repo"
 )
 
 // listRemoteCommand prints out all plugins in the remote repo with latest version supported on current platform.
 // If there are no supported versions for plugin it is skipped.
 func (cmd Command) listRemoteCommand(c utils.CommandLine) error {
-	plugin, err := cmd.Client.ListAllPlugins(c.PluginRepoURL())
+	opts, err := installerOpts(c)
 	if err != nil {
 		return err
 	}
 
-	for _, p := range plugin.Plugins {
+	i := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger)
+	plugins, err := i.RepoClient(c.PluginRepoURL()).ListPlugins()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range plugins {
 		plugin := p
-		if len(plugin.Versions) > 0 {
-			ver := latestSupportedVersion(&plugin)
-			if ver != nil {
-				logger.Infof("id: %v version: %s\n", plugin.ID, ver.Version)
-			}
+		if ver := repo.LatestSupportedVersion(&plugin, "", repo.CurrentPlatform()); ver != nil {
+			logger.Infof("id: %v version: %s\n", plugin.ID, ver.Version)
 		}
 	}
 
@@ -2,9 +2,14 @@ package commands
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+	"github.com/grafana/grafana/pkg/plugins/repo"
 )
 
 func validateVersionInput(c utils.CommandLine) error {
@@ -16,21 +21,46 @@ func validateVersionInput(c utils.CommandLine) error {
 	return nil
 }
 
+// listVersionsCommand prints all published versions of a plugin with the Grafana versions they're compatible with,
+// and whether they can be installed.
 func (cmd Command) listVersionsCommand(c utils.CommandLine) error {
 	if err := validateVersionInput(c); err != nil {
 		return err
 	}
 
 	pluginToList := c.Args().First()
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
 
-	plugin, err := cmd.Client.GetPlugin(pluginToList, c.String("repo"))
+	i := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger)
+	versions, err := i.ListVersions(pluginToList, c.PluginRepoURL())
 	if err != nil {
 		return err
 	}
 
-	for _, i := range plugin.Versions {
-		logger.Infof("%v\n", i.Version)
+	grafanaVersion := services.GrafanaVersion
+	if opts.GrafanaVersion != "" {
+		grafanaVersion = opts.GrafanaVersion
+	}
+	for _, v := range versions {
+		logger.Infof("%v\n", formatVersionInfo(v, grafanaVersion))
 	}
 
 	return nil
 }
+
+func formatVersionInfo(v installer.VersionInfo, grafanaVersion string) string {
+	details := []string{string(v.Channel)}
+	if v.GrafanaDependency != "" {
+		details = append(details, "grafana "+v.GrafanaDependency)
+	}
+	if !v.Supported {
+		details = append(details, fmt.Sprintf("not supported on %s, only on %s", repo.CurrentPlatform(),
+			strings.Join(v.Platforms, " ")))
+	} else if !v.Compatible {
+		details = append(details, "incompatible with Grafana "+grafanaVersion)
+	}
+	return fmt.Sprintf("%s (%s)", v.Version, strings.Join(details, ", "))
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	progressBarWidth    = 30
+	progressRedrawDelay = 100 * time.Millisecond
+)
+
+// progressBar renders the progress of downloading and extracting plugins on a single terminal line per plugin and
+// phase.
+type progressBar struct {
+	mu     sync.Mutex
+	w      io.Writer
+	key    string
+	drawn  time.Time
+	active bool
+}
+
+// withProgressBar renders the installer progress on stderr unless it's disabled or stderr isn't a terminal. The
+// returned function ends the progress line and must be called once the plugins are installed.
+func withProgressBar(c utils.CommandLine, opts *installer.Opts) func() {
+	if c.Bool("noProgress") || !isatty.IsTerminal(os.Stderr.Fd()) {
+		return func() {}
+	}
+	bar := &progressBar{w: os.Stderr}
+	opts.Progress = bar.update
+	return bar.finish
+}
+
+func (b *progressBar) update(p installer.Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := p.PluginID + " " + string(p.Phase)
+	complete := p.Total > 0 && p.Done >= p.Total
+	if key == b.key && !complete && time.Since(b.drawn) < progressRedrawDelay {
+		return
+	}
+	if key != b.key && b.active {
+		// Keep the line of the previous plugin or phase
+		fmt.Fprintln(b.w)
+	}
+	fmt.Fprintf(b.w, "\r%s\x1b[K", formatProgress(p))
+	b.key = key
+	b.drawn = time.Now()
+	b.active = true
+}
+
+func (b *progressBar) finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.active {
+		fmt.Fprintln(b.w)
+		b.active = false
+	}
+}
+
+// formatProgress formats the progress as a bar if the total is known, otherwise just the amount done.
+func formatProgress(p installer.Progress) string {
+	amount := func(n int64) string {
+		if p.Phase == installer.ProgressPhaseDownload {
+			return formatBytes(n)
+		}
+		return fmt.Sprintf("%d files", n)
+	}
+	if p.Total <= 0 {
+		return fmt.Sprintf("%s %-8s %s", p.PluginID, p.Phase, amount(p.Done))
+	}
+
+	done := p.Done
+	if done > p.Total {
+		done = p.Total
+	}
+	filled := int(done * progressBarWidth / p.Total)
+	return fmt.Sprintf("%s %-8s [%s%s] %3d%% %s / %s", p.PluginID, p.Phase, strings.Repeat("=", filled),
+		strings.Repeat(" ", progressBarWidth-filled), done*100/p.Total, amount(done), amount(p.Total))
+}
+
+// formatBytes formats a number of bytes in binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
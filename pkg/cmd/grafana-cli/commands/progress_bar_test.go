@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressBar(t *testing.T) {
+	t.Run("Should format a bar if the total is known", func(t *testing.T) {
+		p := installer.Progress{PluginID: "test-panel", Phase: installer.ProgressPhaseDownload, Done: 1536,
+			Total: 3072}
+		assert.Equal(t, "test-panel download [===============               ]  50% 1.5 KiB / 3.0 KiB",
+			formatProgress(p))
+	})
+
+	t.Run("Should format the amount done if the total is unknown", func(t *testing.T) {
+		p := installer.Progress{PluginID: "test-panel", Phase: installer.ProgressPhaseExtract, Done: 12}
+		assert.Equal(t, "test-panel extract  12 files", formatProgress(p))
+	})
+
+	t.Run("Should keep the line of each plugin and phase", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		bar := &progressBar{w: buf}
+		bar.update(installer.Progress{PluginID: "a", Phase: installer.ProgressPhaseDownload, Done: 10, Total: 10})
+		bar.update(installer.Progress{PluginID: "a", Phase: installer.ProgressPhaseExtract, Done: 1})
+		bar.finish()
+		bar.finish()
+
+		assert.Equal(t, "\ra download [==============================] 100% 10 B / 10 B\x1b[K\n"+
+			"\ra extract  1 files\x1b[K\n", buf.String())
+	})
+}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+)
+
+// purgeCacheCommand removes all plugin archives from the download cache directory.
+func (cmd Command) purgeCacheCommand(c utils.CommandLine) error {
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
+	if opts.DownloadCache.Dir == "" {
+		return errors.New("no download cache is configured, please specify it with --downloadCacheDir")
+	}
+
+	freed, err := installer.PurgeDownloadCache(opts.DownloadCache.Dir)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Removed %s of cached plugin archives from %s\n", formatBytes(freed), opts.DownloadCache.Dir)
+	return nil
+}
@@ -7,10 +7,9 @@ import (
 
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
 )
 
-var removePlugin func(pluginPath, id string) error = services.RemoveInstalledPlugin
-
 func (cmd Command) removeCommand(c utils.CommandLine) error {
 	pluginPath := c.PluginDirectory()
 
@@ -19,7 +18,11 @@ func (cmd Command) removeCommand(c utils.CommandLine) error {
 		return errors.New("missing plugin parameter")
 	}
 
-	err := removePlugin(pluginPath, plugin)
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
+	err = installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger).Uninstall(plugin, pluginPath)
 
 	if err != nil {
 		if strings.Contains(err.Error(), "no such file or directory") {
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+)
+
+// searchCommand prints the plugins in the remote repo matching the query, with their type, signature and latest
+// version.
+func (cmd Command) searchCommand(c utils.CommandLine) error {
+	query := strings.Join(c.Args().Slice(), " ")
+	minSignatureLevel, err := installer.ParseSignatureLevel(c.String("signature"))
+	if err != nil {
+		return err
+	}
+	if c.Int("limit") < 0 {
+		return errors.New("limit must not be negative")
+	}
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
+
+	i := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger)
+	results, err := i.Search(query, installer.SearchFilters{
+		Type:              c.String("type"),
+		MinSignatureLevel: minSignatureLevel,
+		Limit:             c.Int("limit"),
+	}, c.PluginRepoURL())
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		logger.Info("No plugins found\n")
+		return nil
+	}
+
+	for _, res := range results {
+		signature := string(res.SignatureType)
+		if signature == "" {
+			signature = "unsigned"
+		}
+		logger.Infof("id: %v version: %s type: %s signature: %s\n", res.ID, res.LatestVersion, res.Type, signature)
+	}
+	return nil
+}
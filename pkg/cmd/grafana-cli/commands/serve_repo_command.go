@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+)
+
+// serveRepoCommand serves a directory of plugin zips as a static plugin repository, or only writes its index with
+// --index-only.
+func (cmd Command) serveRepoCommand(c utils.CommandLine) error {
+	dir := c.Args().First()
+	if dir == "" {
+		return errors.New("please specify the directory of plugin zips to serve")
+	}
+
+	i := installer.NewWithOpts(installer.Opts{}, services.GrafanaVersion, services.Logger)
+	if c.Bool("index-only") {
+		if err := i.WriteLocalRepoIndex(dir); err != nil {
+			return err
+		}
+		logger.Infof("Wrote plugin repository index to %s\n", dir)
+		return nil
+	}
+
+	listen := c.String("listen")
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           i.LocalRepoHandler(dir),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logger.Infof("Serving plugin repository %s at http://%s/index.json\n", dir, listen)
+	return server.ListenAndServe()
+}
@@ -2,19 +2,23 @@ package commands
 
 import (
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
-	"github.com/grafana/grafana/pkg/cmd/grafana-cli/models"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+	"github.com/grafana/grafana/pkg/plugins/repo"
 	"github.com/hashicorp/go-version"
 )
 
-func shouldUpgrade(installed string, remote *models.Plugin) bool {
+func shouldUpgrade(installed string, remote *repo.Plugin) bool {
 	installedVersion, err := version.NewVersion(installed)
 	if err != nil {
 		return false
 	}
 
-	latest := latestSupportedVersion(remote)
+	latest := repo.LatestSupportedVersion(remote, "", repo.CurrentPlatform())
+	if latest == nil {
+		return false
+	}
 	latestVersion, err := version.NewVersion(latest.Version)
 	if err != nil {
 		return false
@@ -27,35 +31,37 @@ func (cmd Command) upgradeAllCommand(c utils.CommandLine) error {
 
 	localPlugins := services.GetLocalPlugins(pluginsDir)
 
-	remotePlugins, err := cmd.Client.ListAllPlugins(c.String("repo"))
+	opts, err := installerOpts(c)
 	if err != nil {
 		return err
 	}
+	defer withProgressBar(c, &opts)()
+	i := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger)
 
-	pluginsToUpgrade := make([]models.InstalledPlugin, 0)
+	remotePlugins, err := i.RepoClient(c.PluginRepoURL()).ListPlugins()
+	if err != nil {
+		return err
+	}
+
+	pluginsToUpgrade := make([]repo.Plugin, 0)
 
 	for _, localPlugin := range localPlugins {
-		for _, p := range remotePlugins.Plugins {
+		for _, p := range remotePlugins {
 			remotePlugin := p
 			if localPlugin.ID != remotePlugin.ID {
 				continue
 			}
 			if shouldUpgrade(localPlugin.Info.Version, &remotePlugin) {
-				pluginsToUpgrade = append(pluginsToUpgrade, localPlugin)
+				pluginsToUpgrade = append(pluginsToUpgrade, remotePlugin)
 			}
 		}
 	}
 
 	for _, p := range pluginsToUpgrade {
-		logger.Infof("Updating %v \n", p.ID)
-
-		err := services.RemoveInstalledPlugin(pluginsDir, p.ID)
-		if err != nil {
-			return err
-		}
+		remotePlugin := p
+		logger.Infof("Updating %v \n", remotePlugin.ID)
 
-		err = InstallPlugin(p.ID, "", c, cmd.Client)
-		if err != nil {
+		if err := upgradePlugin(i, &remotePlugin, c); err != nil {
 			return err
 		}
 	}
@@ -4,18 +4,18 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/grafana/grafana/pkg/cmd/grafana-cli/models"
+	"github.com/grafana/grafana/pkg/plugins/repo"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestVersionComparison(t *testing.T) {
 	t.Run("Validate that version is outdated", func(t *testing.T) {
-		versions := []models.Version{
+		versions := []repo.Version{
 			{Version: "1.1.1"},
 			{Version: "2.0.0"},
 		}
 
-		upgradeablePlugins := map[string]models.Plugin{
+		upgradeablePlugins := map[string]repo.Plugin{
 			"0.0.0": {Versions: versions},
 			"1.0.0": {Versions: versions},
 		}
@@ -29,12 +29,12 @@ func TestVersionComparison(t *testing.T) {
 	})
 
 	t.Run("Validate that version is ok", func(t *testing.T) {
-		versions := []models.Version{
+		versions := []repo.Version{
 			{Version: "1.1.1"},
 			{Version: "2.0.0"},
 		}
 
-		shouldNotUpgrade := map[string]models.Plugin{
+		shouldNotUpgrade := map[string]repo.Plugin{
 			"2.0.0": {Versions: versions},
 			"6.0.0": {Versions: versions},
 		}
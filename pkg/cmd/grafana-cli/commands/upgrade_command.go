@@ -5,7 +5,8 @@ import (
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
 	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
-	"github.com/grafana/grafana/pkg/util/errutil"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+	"github.com/grafana/grafana/pkg/plugins/repo"
 )
 
 func (cmd Command) upgradeCommand(c utils.CommandLine) error {
@@ -18,19 +19,36 @@ func (cmd Command) upgradeCommand(c utils.CommandLine) error {
 		return err
 	}
 
-	plugin, err2 := cmd.Client.GetPlugin(pluginName, c.PluginRepoURL())
-	if err2 != nil {
-		return err2
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
 	}
+	finishProgress := withProgressBar(c, &opts)
+	defer finishProgress()
+	i := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger)
 
-	if shouldUpgrade(localPlugin.Info.Version, &plugin) {
-		if err := services.RemoveInstalledPlugin(pluginsDir, pluginName); err != nil {
-			return errutil.Wrapf(err, "failed to remove plugin '%s'", pluginName)
-		}
+	plugin, err := i.RepoClient(c.PluginRepoURL()).GetPlugin(pluginName)
+	if err != nil {
+		return err
+	}
 
-		return InstallPlugin(pluginName, "", c, cmd.Client)
+	if shouldUpgrade(localPlugin.Info.Version, &plugin) {
+		return upgradePlugin(i, &plugin, c)
 	}
 
+	finishProgress()
 	logger.Infof("%s %s is up to date \n", color.GreenString("✔"), pluginName)
 	return nil
 }
+
+// upgradePlugin installs the latest version of the plugin supporting the current platform like the install command,
+// so the update is verified and audited like an install. The installed version is only replaced once the new one
+// is downloaded and verified.
+func upgradePlugin(i *installer.Installer, plugin *repo.Plugin, c utils.CommandLine) error {
+	latest := repo.LatestSupportedVersion(plugin, "", repo.CurrentPlatform())
+	plan, err := i.PlanInstall(plugin.ID, latest.Version, c.PluginDirectory(), c.PluginURL(), c.PluginRepoURL())
+	if err != nil {
+		return err
+	}
+	return i.ApplyPlan(plan)
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/services"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/plugins/manager/installer"
+)
+
+// verifyCommand re-verifies all installed plugins and fails if any of them drifted from a verified installation,
+// so that it can be used in readiness checks and scheduled compliance scans.
+func (cmd Command) verifyCommand(c utils.CommandLine) error {
+	pluginDir := c.PluginDirectory()
+	if err := validateLsCommand(pluginDir); err != nil {
+		return err
+	}
+
+	opts, err := installerOpts(c)
+	if err != nil {
+		return err
+	}
+	results, err := installer.NewWithOpts(opts, services.GrafanaVersion, services.Logger).VerifyAll(pluginDir)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, res := range results {
+		if res.OK() {
+			logger.Infof("%s %s\n", color.GreenString("✔"), res)
+			continue
+		}
+		failed++
+		logger.Infof("%s %s\n", color.RedString("✘"), res)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d installed plugins failed verification", failed, len(results))
+	}
+	return nil
+}
@@ -1,9 +1,8 @@
 package utils
 
 import (
-	"os"
+	"time"
 
-	"github.com/grafana/grafana/pkg/cmd/grafana-cli/models"
 	"github.com/urfave/cli/v2"
 )
 
@@ -14,6 +13,7 @@ type CommandLine interface {
 	Args() cli.Args
 	Bool(name string) bool
 	Int(name string) int
+	Duration(name string) time.Duration
 	String(name string) string
 	StringSlice(name string) []string
 	FlagNames() (names []string)
@@ -24,12 +24,6 @@ type CommandLine interface {
 	PluginURL() string
 }
 
-type ApiClient interface {
-	GetPlugin(pluginId, repoUrl string) (models.Plugin, error)
-	DownloadFile(pluginName string, tmpFile *os.File, url string, checksum string) (err error)
-	ListAllPlugins(repoUrl string) (models.PluginRepo, error)
-}
-
 type ContextCommandLine struct {
 	*cli.Context
 }
@@ -0,0 +1,164 @@
+package installer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"github.com/hashicorp/go-version"
+)
+
+// defaultAdvisoryURL is the endpoint security advisories of plugins from grafana.com are looked up at, as
+// <url>/<plugin id>.
+const defaultAdvisoryURL = "https://grafana.com/api/plugins/advisories"
+
+// AdvisoryPolicy decides what happens when a plugin version with known security advisories is installed.
+type AdvisoryPolicy string
+
+const (
+	// AdvisoryPolicyWarn logs a warning for vulnerable plugin versions.
+	AdvisoryPolicyWarn AdvisoryPolicy = "warn"
+	// AdvisoryPolicyBlock refuses to install vulnerable plugin versions, and plugins whose advisories can't be
+	// looked up.
+	AdvisoryPolicyBlock AdvisoryPolicy = "block"
+	// AdvisoryPolicyIgnore skips the advisory check.
+	AdvisoryPolicyIgnore AdvisoryPolicy = "ignore"
+)
+
+// ParseAdvisoryPolicy returns the advisory policy with the provided name. An empty name returns the warn policy.
+func ParseAdvisoryPolicy(name string) (AdvisoryPolicy, error) {
+	switch p := AdvisoryPolicy(strings.ToLower(name)); p {
+	case "":
+		return AdvisoryPolicyWarn, nil
+	case AdvisoryPolicyWarn, AdvisoryPolicyBlock, AdvisoryPolicyIgnore:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown advisory policy %q, valid policies are warn, block and ignore", name)
+}
+
+// Advisory is a security advisory affecting a range of plugin versions.
+type Advisory struct {
+	ID       string `json:"id"`
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	// AffectedVersions is a version constraint, e.g. ">= 1.0.0, < 1.2.3".
+	AffectedVersions string `json:"affectedVersions"`
+	FixedVersion     string `json:"fixedVersion"`
+	URL              string `json:"url"`
+}
+
+// VulnerableVersionError is returned when the requested plugin version is affected by security advisories and
+// the advisory policy blocks it.
+type VulnerableVersionError struct {
+	PluginID   string
+	Version    string
+	Advisories []Advisory
+}
+
+func (e *VulnerableVersionError) Error() string {
+	ids := make([]string, 0, len(e.Advisories))
+	for _, a := range e.Advisories {
+		ids = append(ids, a.ID)
+	}
+	return fmt.Sprintf("plugin %s v%s is affected by security advisories %s", e.PluginID, e.Version,
+		strings.Join(ids, ", "))
+}
+
+// checkAdvisories looks up the security advisories of the plugin and applies the advisory policy if the version
+// is affected by any of them. The source is the repository or archive the plugin is installed from, empty if it
+// isn't known, see advisoriesApply.
+func (i *Installer) checkAdvisories(pluginID, pluginVersion, source string) error {
+	policy := i.opts.AdvisoryPolicy
+	if policy == "" {
+		policy = AdvisoryPolicyWarn
+	}
+	if policy == AdvisoryPolicyIgnore || pluginVersion == "" || !i.advisoriesApply(source) {
+		return nil
+	}
+
+	advisories, err := i.affectingAdvisories(pluginID, pluginVersion)
+	if err != nil {
+		if policy == AdvisoryPolicyBlock {
+			return errutil.Wrapf(err, "failed to check security advisories of %s", pluginID)
+		}
+		i.log.Warnf("Failed to check security advisories of %s: %v", pluginID, err)
+		return nil
+	}
+	if len(advisories) == 0 {
+		return nil
+	}
+
+	vulnErr := &VulnerableVersionError{PluginID: pluginID, Version: pluginVersion, Advisories: advisories}
+	if policy == AdvisoryPolicyBlock {
+		return vulnErr
+	}
+	for _, a := range advisories {
+		msg := fmt.Sprintf("%s v%s is affected by %s security advisory %s: %s", pluginID, pluginVersion, a.Severity,
+			a.ID, a.Summary)
+		if a.FixedVersion != "" {
+			msg += fmt.Sprintf(", upgrade to v%s or later", a.FixedVersion)
+		}
+		if a.URL != "" {
+			msg += fmt.Sprintf(" (%s)", a.URL)
+		}
+		i.log.Warn(msg)
+	}
+	return nil
+}
+
+// advisoriesApply reports whether the advisories of plugins installed from the source are looked up. They're
+// looked up at the configured advisory URL for any source, otherwise only for plugins from grafana.com, as other
+// repositories and local archives aren't covered by its advisories. Nothing is looked up while installing from an
+// offline bundle, which mustn't need network access.
+func (i *Installer) advisoriesApply(source string) bool {
+	if i.offline {
+		return false
+	}
+	if i.opts.AdvisoryURL != "" {
+		return true
+	}
+	u, err := url.Parse(source)
+	return err == nil && (sourceMatches("grafana.com", u) || sourceMatches("*.grafana.com", u))
+}
+
+// affectingAdvisories returns the advisories of the plugin that affect the version.
+func (i *Installer) affectingAdvisories(pluginID, pluginVersion string) ([]Advisory, error) {
+	v, err := version.NewVersion(pluginVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin version %q: %w", pluginVersion, err)
+	}
+
+	advisoryURL := i.opts.AdvisoryURL
+	if advisoryURL == "" {
+		advisoryURL = defaultAdvisoryURL
+	}
+	body, err := i.sendRequestGetBytes(advisoryURL, pluginID)
+	if err != nil {
+		if errors.Is(err, ErrNotFoundError) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var res struct {
+		Advisories []Advisory `json:"advisories"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, errutil.Wrap("invalid security advisories", err)
+	}
+
+	var affecting []Advisory
+	for _, a := range res.Advisories {
+		constraints, err := version.NewConstraint(a.AffectedVersions)
+		if err != nil {
+			return nil, fmt.Errorf("invalid affected versions %q of advisory %s: %w", a.AffectedVersions, a.ID, err)
+		}
+		if constraints.Check(v) {
+			affecting = append(affecting, a)
+		}
+	}
+	return affecting, nil
+}
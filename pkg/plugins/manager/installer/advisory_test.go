@@ -0,0 +1,113 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAdvisories(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/advisories/test-panel":
+			_, _ = w.Write([]byte(`{"advisories":[{"id":"GPA-2021-01","severity":"high","summary":"XSS in panel",` +
+				`"affectedVersions":">= 1.0.0, < 1.2.3","fixedVersion":"1.2.3"}]}`))
+		case "/advisories/broken-panel":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	advisoryURL := server.URL + "/advisories"
+
+	t.Run("Should refuse vulnerable versions when blocking", func(t *testing.T) {
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyBlock, AdvisoryURL: advisoryURL}, "8.0.0", &fakeLogger{})
+
+		err := i.checkAdvisories("test-panel", "1.2.0", "")
+		var vulnErr *VulnerableVersionError
+		require.ErrorAs(t, err, &vulnErr)
+		require.Len(t, vulnErr.Advisories, 1)
+		assert.Equal(t, "GPA-2021-01", vulnErr.Advisories[0].ID)
+
+		require.NoError(t, i.checkAdvisories("test-panel", "1.2.3", ""))
+		require.NoError(t, i.checkAdvisories("other-panel", "1.0.0", ""))
+		require.Error(t, i.checkAdvisories("broken-panel", "1.0.0", ""))
+	})
+
+	t.Run("Should only warn about vulnerable versions by default", func(t *testing.T) {
+		i := NewWithOpts(Opts{AdvisoryURL: advisoryURL}, "8.0.0", &fakeLogger{})
+
+		require.NoError(t, i.checkAdvisories("test-panel", "1.2.0", ""))
+		require.NoError(t, i.checkAdvisories("broken-panel", "1.0.0", ""))
+	})
+
+	t.Run("Should remove vulnerable plugin installed from URL", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{
+			"plugin.json": `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`,
+		})
+		pluginsDir := t.TempDir()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyBlock, AdvisoryURL: advisoryURL,
+			SignaturePolicy: SignaturePolicyIgnore}, "8.0.0", &fakeLogger{})
+
+		err := i.Install("test-panel", "", pluginsDir, archive, "")
+		var vulnErr *VulnerableVersionError
+		require.ErrorAs(t, err, &vulnErr)
+		assert.Equal(t, "1.0.0", vulnErr.Version)
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+	})
+
+	t.Run("Should only look up advisories of plugins from grafana.com without advisory URL", func(t *testing.T) {
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+		assert.True(t, i.advisoriesApply("https://grafana.com/api/plugins"))
+		assert.True(t, i.advisoriesApply("https://storage.grafana.com/plugins/test-panel-1.0.0.zip"))
+		assert.False(t, i.advisoriesApply("https://plugins.example.com/api/plugins"))
+		assert.False(t, i.advisoriesApply(t.TempDir()))
+		assert.False(t, i.advisoriesApply(""))
+
+		i = NewWithOpts(Opts{AdvisoryURL: advisoryURL}, "8.0.0", &fakeLogger{})
+		assert.True(t, i.advisoriesApply("https://plugins.example.com/api/plugins"))
+		assert.True(t, i.advisoriesApply(t.TempDir()))
+	})
+
+	t.Run("Should install from local repository without looking up advisories", func(t *testing.T) {
+		archive, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{
+			"plugin.json": `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.2.0"}}`,
+		}))
+		require.NoError(t, err)
+		repoDir := t.TempDir()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(repoDir, "test-panel-1.2.0.zip"), archive, 0600))
+		pluginsDir := t.TempDir()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyBlock, SignaturePolicy: SignaturePolicyIgnore}, "8.0.0",
+			&fakeLogger{})
+
+		require.NoError(t, i.WriteLocalRepoIndex(repoDir))
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, "", repoDir))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+	})
+
+	t.Run("Should import offline bundle without looking up advisories", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		t.Cleanup(server.Close)
+		bundleDir := filepath.Join(t.TempDir(), "bundle")
+		exporter := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+		require.NoError(t, exporter.ExportBundle([]string{"test-app"}, bundleDir,
+			writeTestBundleRepo(t, osAndArchString()), nil))
+		pluginsDir := t.TempDir()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyBlock, AdvisoryURL: server.URL}, "8.0.0", &fakeLogger{})
+
+		require.NoError(t, i.ImportBundle([]string{"test-app"}, bundleDir, pluginsDir))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-app", "plugin.json"))
+		assert.Zero(t, atomic.LoadInt32(&requests))
+	})
+}
@@ -0,0 +1,97 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// angularPatterns identify module.js files of plugins that depend on Angular. They're the ones Grafana uses to
+// detect Angular plugins.
+var angularPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`PanelCtrl`),
+	regexp.MustCompile(`ConfigCtrl`),
+	regexp.MustCompile(`app/plugins/sdk`),
+	regexp.MustCompile(`angular\.isNumber\(`),
+	regexp.MustCompile(`editor\.html`),
+	regexp.MustCompile(`ctrl\.annotation`),
+	regexp.MustCompile(`getLegacyAngularInjector`),
+	regexp.MustCompile(`["']QueryCtrl["']`),
+}
+
+// AngularPluginError is returned when installing a plugin that depends on Angular while Angular support is
+// disabled.
+type AngularPluginError struct {
+	PluginID string
+	Reason   string
+}
+
+func (e *AngularPluginError) Error() string {
+	return fmt.Sprintf("plugin %s depends on Angular, which is disabled on this instance: %s", e.PluginID, e.Reason)
+}
+
+// checkAngular refuses the installed plugin if Angular support is disabled and the plugin, or one of its nested
+// plugins, declares angularDetected in its plugin.json or has a module.js that uses Angular.
+func (i *Installer) checkAngular(pluginsDir, pluginID string) error {
+	if !i.opts.AngularSupportDisabled {
+		return nil
+	}
+
+	pluginDir := filepath.Join(pluginsDir, pluginID)
+	return filepath.Walk(pluginDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || (fi.Name() != "plugin.json" && fi.Name() != "module.js") {
+			return nil
+		}
+
+		// nolint:gosec
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(pluginDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if fi.Name() == "plugin.json" {
+			var pj struct {
+				AngularDetected bool `json:"angularDetected"`
+			}
+			if err := json.Unmarshal(data, &pj); err == nil && pj.AngularDetected {
+				return &AngularPluginError{PluginID: pluginID, Reason: fmt.Sprintf("%s declares angularDetected", rel)}
+			}
+			return nil
+		}
+		if isAngularModule(data) {
+			return &AngularPluginError{PluginID: pluginID, Reason: fmt.Sprintf("%s uses Angular", rel)}
+		}
+		return nil
+	})
+}
+
+// isAngularModule reports whether the module.js contents use Angular.
+func isAngularModule(data []byte) bool {
+	for _, p := range angularPatterns {
+		if p.Match(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAngularVersion returns an error if Angular support is disabled and the plugin repository detected Angular
+// in the plugin version, so that the install fails before downloading the plugin.
+func (i *Installer) checkAngularVersion(pluginID string, v *Version) error {
+	if !i.opts.AngularSupportDisabled || !v.AngularDetected {
+		return nil
+	}
+	return &AngularPluginError{PluginID: pluginID,
+		Reason: fmt.Sprintf("the plugin repository detected Angular in version %s", v.Version)}
+}
@@ -0,0 +1,48 @@
+package installer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAngularPlugins(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+	angularModule := `define(["app/plugins/sdk"], function(sdk) { return { PanelCtrl: sdk.MetricsPanelCtrl }; });`
+
+	t.Run("Should refuse Angular plugins when Angular support is disabled", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON, "module.js": angularModule})
+		pluginsDir := t.TempDir()
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, AngularSupportDisabled: true}}
+
+		var angularErr *AngularPluginError
+		require.ErrorAs(t, i.Install("test-panel", "", pluginsDir, archive, ""), &angularErr)
+		assert.Contains(t, angularErr.Reason, "module.js")
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+
+		i.opts.AngularSupportDisabled = false
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, archive, ""))
+	})
+
+	t.Run("Should refuse nested plugins declaring angularDetected", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{
+			"plugin.json":               `{"id":"test-app","name":"Test","type":"app","info":{"version":"1.0.0"}}`,
+			"module.js":                 `export const plugin = new AppPlugin();`,
+			"panels/nested/plugin.json": `{"id":"nested","name":"Nested","type":"panel","angularDetected":true}`,
+		})
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, AngularSupportDisabled: true}}
+
+		var angularErr *AngularPluginError
+		require.ErrorAs(t, i.Install("test-app", "", t.TempDir(), archive, ""), &angularErr)
+		assert.Contains(t, angularErr.Reason, "panels/nested/plugin.json")
+	})
+
+	t.Run("Should refuse versions the plugin repository detected Angular in", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AngularSupportDisabled: true}}
+
+		require.Error(t, i.checkAngularVersion("test-panel", &Version{Version: "1.0.0", AngularDetected: true}))
+		require.NoError(t, i.checkAngularVersion("test-panel", &Version{Version: "2.0.0"}))
+	})
+}
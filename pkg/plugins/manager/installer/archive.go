@@ -0,0 +1,365 @@
+package installer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// archiveFile is a single member of a plugin archive.
+type archiveFile struct {
+	name     string
+	mode     os.FileMode
+	linkname string
+	open     func() (io.ReadCloser, error)
+}
+
+func (f *archiveFile) isDir() bool {
+	return f.mode.IsDir()
+}
+
+func (f *archiveFile) isSymlink() bool {
+	return f.mode&os.ModeSymlink == os.ModeSymlink
+}
+
+// symlinkTarget returns the target of a symlink member. Zip archives store the target as the contents of the
+// member, tar archives store it in the header.
+func (f *archiveFile) symlinkTarget() (string, error) {
+	if f.linkname != "" {
+		return f.linkname, nil
+	}
+	src, err := f.open()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	buf := new(bytes.Buffer)
+	if _, err := io.Copy(buf, src); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// pluginArchive provides sequential access to the members of a plugin archive.
+type pluginArchive interface {
+	// walk calls fn for every member of the archive in order. A member's contents can only be read
+	// from within fn.
+	walk(fn func(f *archiveFile) error) error
+	Close() error
+}
+
+// Archive formats recognized by detectArchiveFormat.
+const (
+	formatZip        = "zip"
+	formatTar        = "tar"
+	formatGzip       = "gzip"
+	formatZstd       = "zstd"
+	formatBzip2      = "bzip2"
+	formatXz         = "xz"
+	formatLzma       = "lzma"
+	formatOpenPGP    = "OpenPGP encrypted"
+	formatAge        = "age encrypted"
+	formatDeb        = "deb"
+	formatRPM        = "rpm"
+	formatExecutable = "executable"
+	format7z         = "7z"
+	formatRar        = "rar"
+	formatLz4        = "lz4"
+	formatHTML       = "HTML document"
+	formatEmpty      = "empty file"
+	formatUnknown    = "unknown"
+)
+
+// archiveSniffLen is the number of bytes needed to detect all archive formats, the ustar magic of tar archives
+// is at offset 257 of the first block.
+const archiveSniffLen = 512
+
+var (
+	zipMagics   = [][]byte{[]byte("PK\x03\x04"), []byte("PK\x05\x06"), []byte("PK\x07\x08")}
+	gzipMagic   = []byte{0x1f, 0x8b}
+	zstdMagic   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic  = []byte("BZh")
+	xzMagic     = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	sevenZMagic = []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}
+	rarMagic    = []byte("Rar!\x1a\x07")
+	lz4Magic    = []byte{0x04, 0x22, 0x4d, 0x18}
+	ustarMagic  = []byte("ustar")
+)
+
+// UnsupportedArchiveFormatError is returned when the downloaded file isn't an archive format the installer
+// can extract.
+type UnsupportedArchiveFormatError struct {
+	// Format is the detected format of the file.
+	Format string
+}
+
+func (e *UnsupportedArchiveFormatError) Error() string {
+	return fmt.Sprintf("unsupported archive format: detected %s", e.Format)
+}
+
+// detectArchiveFormat identifies the format of a file by its leading bytes.
+func detectArchiveFormat(header []byte) string {
+	for _, magic := range zipMagics {
+		if bytes.HasPrefix(header, magic) {
+			return formatZip
+		}
+	}
+	switch {
+	case len(header) == 0:
+		return formatEmpty
+	case isOpenPGPEncrypted(header):
+		return formatOpenPGP
+	case isAgeEncrypted(header):
+		return formatAge
+	case bytes.HasPrefix(header, gzipMagic):
+		return formatGzip
+	case bytes.HasPrefix(header, zstdMagic):
+		return formatZstd
+	case bytes.HasPrefix(header, bzip2Magic):
+		return formatBzip2
+	case bytes.HasPrefix(header, xzMagic):
+		return formatXz
+	case bytes.HasPrefix(header, debMagic):
+		return formatDeb
+	case bytes.HasPrefix(header, rpmMagic):
+		return formatRPM
+	case len(header) >= 257+len(ustarMagic) && bytes.Equal(header[257:257+len(ustarMagic)], ustarMagic):
+		return formatTar
+	case isExecutable(header):
+		return formatExecutable
+	case bytes.HasPrefix(header, sevenZMagic):
+		return format7z
+	case bytes.HasPrefix(header, rarMagic):
+		return formatRar
+	case bytes.HasPrefix(header, lz4Magic):
+		return formatLz4
+	case bytes.HasPrefix(bytes.TrimSpace(header), []byte("<")):
+		return formatHTML
+	}
+	return formatUnknown
+}
+
+// openArchive opens the archive at the provided path, picking the reader based on its contents. Single executables
+// are treated as an archive containing the executable of the plugin with the provided ID.
+func (i *Installer) openArchive(archivePath, pluginID string) (pluginArchive, error) {
+	// We can ignore the gosec G304 warning since the archive path is either a temp file we created or
+	// stems from the command line flag "pluginUrl".
+	// nolint:gosec
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, archiveSniffLen)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		_ = f.Close()
+		return nil, err
+	}
+
+	format := detectArchiveFormat(header[:n])
+	if format == formatZip {
+		fi, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		r, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return &zipArchive{r: r, f: f, passphrase: i.archivePassphrase}, nil
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	if decompress, isTar := tarDecompressor(format); isTar {
+		return openTarArchive(archivePath, decompress)
+	}
+	switch format {
+	case formatOpenPGP:
+		return i.openEncryptedArchive(archivePath, pluginID)
+	case formatAge:
+		return i.openAgeArchive(archivePath, pluginID)
+	case formatDeb:
+		return openDebArchive(archivePath)
+	case formatRPM:
+		return openRPMArchive(archivePath)
+	case formatExecutable:
+		return i.openBinaryArchive(archivePath, pluginID)
+	}
+	return nil, &UnsupportedArchiveFormatError{Format: format}
+}
+
+// tarDecompressor returns the decompressor of tar archives of the format, nil for uncompressed ones, and whether
+// the format is a possibly compressed tar archive.
+func tarDecompressor(format string) (func(io.Reader) (io.ReadCloser, error), bool) {
+	switch format {
+	case formatTar:
+		return nil, true
+	case formatGzip:
+		return func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		}, true
+	case formatZstd:
+		return func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return &zstdReadCloser{zr}, nil
+		}, true
+	case formatBzip2:
+		return func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bzip2.NewReader(r)), nil
+		}, true
+	case formatXz:
+		return func(r io.Reader) (io.ReadCloser, error) {
+			xr, err := xz.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(xr), nil
+		}, true
+	case formatLzma:
+		return func(r io.Reader) (io.ReadCloser, error) {
+			lr, err := lzma.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(lr), nil
+		}, true
+	}
+	return nil, false
+}
+
+// zstdReadCloser releases the resources of a zstd decoder on Close.
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (r *zstdReadCloser) Close() error {
+	r.Decoder.Close()
+	return nil
+}
+
+type zipArchive struct {
+	r          *zip.Reader
+	f          *os.File
+	passphrase func() (string, error)
+}
+
+func (a *zipArchive) walk(fn func(f *archiveFile) error) error {
+	for _, zf := range a.r.File {
+		zf := zf
+		open := func() (io.ReadCloser, error) { return zf.Open() }
+		switch {
+		case zf.Method == winZipAESMethod:
+			open = func() (io.ReadCloser, error) { return a.openAES(zf) }
+		case zf.Flags&0x1 != 0:
+			return fmt.Errorf("%s: legacy zip encryption is not supported, please use AES encryption", zf.Name)
+		}
+		if err := fn(&archiveFile{
+			name: zf.Name,
+			mode: zf.Mode(),
+			open: open,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *zipArchive) size() int {
+	return len(a.r.File)
+}
+
+func (a *zipArchive) Close() error {
+	return a.f.Close()
+}
+
+type tarArchive struct {
+	f          *os.File
+	decompress func(io.Reader) (io.ReadCloser, error)
+}
+
+// openTarArchive opens a tar archive, which is decompressed with decompress if it's not nil.
+func openTarArchive(archivePath string, decompress func(io.Reader) (io.ReadCloser, error)) (*tarArchive, error) {
+	// nolint:gosec
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	return &tarArchive{f: f, decompress: decompress}, nil
+}
+
+func (a *tarArchive) walk(fn func(f *archiveFile) error) error {
+	// Rewind so that the archive can be walked more than once
+	if _, err := a.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var r io.Reader = bufio.NewReader(a.f)
+	if a.decompress != nil {
+		dr, err := a.decompress(r)
+		if err != nil {
+			return fmt.Errorf("failed to decompress archive: %w", err)
+		}
+		defer func() {
+			_ = dr.Close()
+		}()
+		r = dr
+	}
+
+	return walkTar(r, fn)
+}
+
+// walkTar calls fn for every regular file, directory and symlink in the tar stream.
+func walkTar(r io.Reader, fn func(f *archiveFile) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		mode := hdr.FileInfo().Mode()
+		if !mode.IsRegular() && !mode.IsDir() && hdr.Typeflag != tar.TypeSymlink {
+			// Skip hard links, devices and other special members
+			continue
+		}
+
+		if err := fn(&archiveFile{
+			name:     hdr.Name,
+			mode:     mode,
+			linkname: hdr.Linkname,
+			open: func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(tr), nil
+			},
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *tarArchive) Close() error {
+	return a.f.Close()
+}
@@ -0,0 +1,122 @@
+package installer
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// AuditOperation is an installer operation recorded in the audit log.
+type AuditOperation string
+
+const (
+	AuditOperationInstall   AuditOperation = "install"
+	AuditOperationUpdate    AuditOperation = "update"
+	AuditOperationUninstall AuditOperation = "uninstall"
+	AuditOperationApprove   AuditOperation = "approve"
+	// AuditOperationRollback records that an applied install or update was undone because a later plugin of the
+	// same install plan failed.
+	AuditOperationRollback AuditOperation = "rollback"
+)
+
+// AuditEvent records who performed an installer operation on which plugin, when, where the plugin was installed
+// from and whether the operation succeeded.
+type AuditEvent struct {
+	Time      time.Time      `json:"time"`
+	Actor     string         `json:"actor"`
+	Operation AuditOperation `json:"operation"`
+	PluginID  string         `json:"pluginId"`
+	Version   string         `json:"version,omitempty"`
+	Source    string         `json:"source,omitempty"`
+	// Checksum is the SHA256 checksum of the installed plugin archive.
+	Checksum  string `json:"checksum,omitempty"`
+	PluginDir string `json:"pluginDir"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+	// Download are the statistics of the download of the plugin archive, nil if it wasn't downloaded.
+	Download *DownloadStats `json:"download,omitempty"`
+}
+
+// AuditSink receives the audit events of the installer, e.g. to forward them to the audit subsystem of the
+// server running the installer.
+type AuditSink interface {
+	RecordPluginEvent(event AuditEvent)
+}
+
+func (i *Installer) auditEnabled() bool {
+	return i.opts.AuditLogPath != "" || i.opts.AuditSink != nil
+}
+
+// newAuditEvent starts recording an operation on a plugin.
+func (i *Installer) newAuditEvent(op AuditOperation, pluginID, pluginsDir string) *AuditEvent {
+	actor := i.opts.AuditActor
+	if actor == "" {
+		actor = "unknown"
+		if u, err := user.Current(); err == nil {
+			actor = u.Username
+		}
+	}
+	return &AuditEvent{
+		Time:      time.Now().UTC(),
+		Actor:     actor,
+		Operation: op,
+		PluginID:  pluginID,
+		PluginDir: filepath.Join(pluginsDir, pluginID),
+	}
+}
+
+// rollbackEvent returns the event recording that the operation of the event is undone.
+func rollbackEvent(event *AuditEvent) *AuditEvent {
+	return &AuditEvent{
+		Time:      time.Now().UTC(),
+		Actor:     event.Actor,
+		Operation: AuditOperationRollback,
+		PluginID:  event.PluginID,
+		Version:   event.Version,
+		Source:    event.Source,
+		Checksum:  event.Checksum,
+		PluginDir: event.PluginDir,
+	}
+}
+
+// audit records the result of the operation in the audit log file and passes it to the audit sink. Failing to
+// write the audit log doesn't fail the operation, but is logged as an error.
+func (i *Installer) audit(event *AuditEvent, err error) {
+	if !i.auditEnabled() {
+		return
+	}
+	event.Success = err == nil
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	if i.opts.AuditSink != nil {
+		i.opts.AuditSink.RecordPluginEvent(*event)
+	}
+	if i.opts.AuditLogPath == "" {
+		return
+	}
+	if err := appendAuditEvent(i.opts.AuditLogPath, event); err != nil {
+		i.log.Errorf("Failed to write audit log %s: %s", i.opts.AuditLogPath, err)
+	}
+}
+
+// appendAuditEvent appends the event as a JSON line to the audit log file, which is only ever appended to.
+func appendAuditEvent(path string, event *AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// nolint:gosec
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,84 @@
+package installer
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAuditSink struct {
+	events []AuditEvent
+}
+
+func (s *fakeAuditSink) RecordPluginEvent(event AuditEvent) {
+	s.events = append(s.events, event)
+}
+
+func TestAuditLog(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+	archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+	checksum, err := plugins.FileSHA256(archive)
+	require.NoError(t, err)
+
+	pluginsDir := t.TempDir()
+	auditLog := filepath.Join(t.TempDir(), "audit.log")
+	sink := &fakeAuditSink{}
+	i := &Installer{log: &fakeLogger{}, opts: Opts{
+		AdvisoryPolicy: AdvisoryPolicyIgnore,
+		AuditLogPath:   auditLog,
+		AuditSink:      sink,
+		AuditActor:     "admin",
+	}}
+
+	require.NoError(t, i.Install("test-panel", "", pluginsDir, archive, ""))
+	require.NoError(t, i.Install("test-panel", "", pluginsDir, archive, ""))
+	require.Error(t, i.Install("test-panel", "", pluginsDir, filepath.Join(t.TempDir(), "missing.zip"), ""))
+	require.NoError(t, i.Uninstall("test-panel", pluginsDir))
+
+	f, err := os.Open(auditLog)
+	require.NoError(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+	var events []AuditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event AuditEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, events, 4)
+	require.Len(t, sink.events, 4)
+
+	t.Run("Should record installs with source and checksum", func(t *testing.T) {
+		e := events[0]
+		assert.Equal(t, AuditOperationInstall, e.Operation)
+		assert.Equal(t, "admin", e.Actor)
+		assert.Equal(t, "test-panel", e.PluginID)
+		assert.Equal(t, "1.0.0", e.Version)
+		assert.Equal(t, archive, e.Source)
+		assert.Equal(t, checksum, e.Checksum)
+		assert.True(t, e.Success)
+		assert.False(t, e.Time.IsZero())
+		assert.Equal(t, AuditOperationUpdate, events[1].Operation)
+	})
+
+	t.Run("Should record failures", func(t *testing.T) {
+		assert.False(t, events[2].Success)
+		assert.NotEmpty(t, events[2].Error)
+	})
+
+	t.Run("Should record uninstalls", func(t *testing.T) {
+		assert.Equal(t, AuditOperationUninstall, events[3].Operation)
+		assert.Equal(t, "1.0.0", events[3].Version)
+		assert.True(t, events[3].Success)
+		assert.Equal(t, events, sink.events)
+	})
+}
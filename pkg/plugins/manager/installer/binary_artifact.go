@@ -0,0 +1,198 @@
+package installer
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// defaultBinaryExecutable is the executable name used in synthesized plugin.json files.
+const defaultBinaryExecutable = "plugin_start"
+
+var (
+	elfMagic    = []byte("\x7fELF")
+	peMagic     = []byte("MZ")
+	machoMagics = [][]byte{
+		{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf},
+		{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+	}
+	machoFatMagic  = []byte{0xca, 0xfe, 0xba, 0xbe}
+	elfMachineArch = map[elf.Machine]string{
+		elf.EM_386:     "386",
+		elf.EM_X86_64:  "amd64",
+		elf.EM_ARM:     "arm",
+		elf.EM_AARCH64: "arm64",
+	}
+	peMachineArch = map[uint16]string{
+		pe.IMAGE_FILE_MACHINE_I386:  "386",
+		pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+		pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+	}
+	machoCPUArch = map[macho.Cpu]string{
+		macho.Cpu386:   "386",
+		macho.CpuAmd64: "amd64",
+		macho.CpuArm64: "arm64",
+	}
+)
+
+// isExecutable reports whether the header is the start of an ELF, PE or Mach-O executable.
+func isExecutable(header []byte) bool {
+	if bytes.HasPrefix(header, elfMagic) || bytes.HasPrefix(header, peMagic) {
+		return true
+	}
+	for _, magic := range machoMagics {
+		if bytes.HasPrefix(header, magic) {
+			return true
+		}
+	}
+	// The fat Mach-O magic is shared with Java class files, tell them apart by the architecture count
+	// which overlaps the class file version.
+	return bytes.HasPrefix(header, machoFatMagic) && len(header) >= 8 && header[4] == 0 && header[5] == 0 &&
+		header[6] == 0 && header[7] < 20
+}
+
+// binaryPlatform returns the operating system and architecture the executable was built for, using Go's naming.
+func binaryPlatform(f *os.File) (string, string, error) {
+	if ef, err := elf.NewFile(f); err == nil {
+		if arch, ok := elfMachineArch[ef.Machine]; ok {
+			return "linux", arch, nil
+		}
+		return "", "", fmt.Errorf("unsupported ELF machine %s", ef.Machine)
+	}
+	if pf, err := pe.NewFile(f); err == nil {
+		if arch, ok := peMachineArch[pf.Machine]; ok {
+			return "windows", arch, nil
+		}
+		return "", "", fmt.Errorf("unsupported PE machine %#x", pf.Machine)
+	}
+	if mf, err := macho.NewFile(f); err == nil {
+		if arch, ok := machoCPUArch[mf.Cpu]; ok {
+			return "darwin", arch, nil
+		}
+		return "", "", fmt.Errorf("unsupported Mach-O CPU %s", mf.Cpu)
+	}
+	if _, err := macho.NewFatFile(f); err == nil {
+		// Universal binaries run on every architecture they contain, name them after the current one
+		return "darwin", "", nil
+	}
+	return "", "", fmt.Errorf("unrecognized executable format")
+}
+
+// binaryArchive presents a single executable as a plugin archive containing the executable and its plugin.json.
+// The plugin.json is either read from Opts.BinaryPluginJSONPath or synthesized from Opts.BinaryPluginType.
+type binaryArchive struct {
+	f          *os.File
+	pluginID   string
+	pluginJSON []byte
+	executable string
+}
+
+func (i *Installer) openBinaryArchive(archivePath, pluginID string) (*binaryArchive, error) {
+	if pluginID == "" {
+		return nil, fmt.Errorf("plugin ID is required to install a plugin executable")
+	}
+
+	pluginJSON, executable, err := i.binaryPluginJSON(pluginID)
+	if err != nil {
+		return nil, err
+	}
+
+	// nolint:gosec
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	goos, goarch, err := binaryPlatform(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	executable = fmt.Sprintf("%s_%s_%s", executable, goos, goarch)
+	if goos == "windows" {
+		executable += ".exe"
+	}
+
+	return &binaryArchive{f: f, pluginID: pluginID, pluginJSON: pluginJSON, executable: executable}, nil
+}
+
+// binaryPluginJSON returns the plugin.json to install alongside a plugin executable and the executable name it
+// declares.
+func (i *Installer) binaryPluginJSON(pluginID string) ([]byte, string, error) {
+	if i.opts.BinaryPluginJSONPath != "" {
+		// nolint:gosec
+		data, err := ioutil.ReadFile(i.opts.BinaryPluginJSONPath)
+		if err != nil {
+			return nil, "", errutil.Wrap("failed to read plugin.json for plugin executable", err)
+		}
+		var pj struct {
+			ID         string `json:"id"`
+			Backend    bool   `json:"backend"`
+			Executable string `json:"executable"`
+		}
+		if err := json.Unmarshal(data, &pj); err != nil {
+			return nil, "", fmt.Errorf("invalid plugin.json for plugin executable: %w", err)
+		}
+		if pj.ID != pluginID {
+			return nil, "", fmt.Errorf("plugin.json is for plugin %q, but %q is being installed", pj.ID, pluginID)
+		}
+		if !pj.Backend || pj.Executable == "" {
+			return nil, "", fmt.Errorf("plugin.json for plugin executable must declare a backend executable")
+		}
+		return data, pj.Executable, nil
+	}
+
+	if i.opts.BinaryPluginType == "" {
+		return nil, "", fmt.Errorf("installing a plugin executable requires a plugin.json or a plugin type")
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"id":         pluginID,
+		"name":       pluginID,
+		"type":       i.opts.BinaryPluginType,
+		"backend":    true,
+		"executable": defaultBinaryExecutable,
+	}, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	return data, defaultBinaryExecutable, nil
+}
+
+func (a *binaryArchive) walk(fn func(f *archiveFile) error) error {
+	if err := fn(&archiveFile{
+		name: path.Join(a.pluginID, "plugin.json"),
+		mode: 0644,
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(a.pluginJSON)), nil
+		},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := a.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return fn(&archiveFile{
+		name: path.Join(a.pluginID, a.executable),
+		mode: 0755,
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(a.f), nil
+		},
+	})
+}
+
+func (a *binaryArchive) Close() error {
+	return a.f.Close()
+}
@@ -0,0 +1,79 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractBinaryArtifact(t *testing.T) {
+	// The test binary itself is an executable for the current platform
+	binary, err := os.Executable()
+	require.NoError(t, err)
+	executable := fmt.Sprintf("_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		executable += ".exe"
+	}
+
+	t.Run("Should synthesize plugin.json", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}, opts: Opts{BinaryPluginType: "renderer"}}
+		pluginsDir := t.TempDir()
+
+		err := i.extractFiles(binary, "test-renderer", pluginsDir, false)
+		require.NoError(t, err)
+
+		fi, err := os.Stat(filepath.Join(pluginsDir, "test-renderer", defaultBinaryExecutable+executable))
+		require.NoError(t, err)
+		if runtime.GOOS != "windows" {
+			assert.NotZero(t, fi.Mode()&0100, "executable bit should be set")
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-renderer", "plugin.json"))
+		require.NoError(t, err)
+		var pj map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &pj))
+		assert.Equal(t, "test-renderer", pj["id"])
+		assert.Equal(t, "renderer", pj["type"])
+		assert.Equal(t, true, pj["backend"])
+		assert.Equal(t, defaultBinaryExecutable, pj["executable"])
+	})
+
+	t.Run("Should use provided plugin.json", func(t *testing.T) {
+		pluginJSON := filepath.Join(t.TempDir(), "plugin.json")
+		require.NoError(t, ioutil.WriteFile(pluginJSON,
+			[]byte(`{"id": "test-datasource", "type": "datasource", "backend": true, "executable": "gpx_test"}`), 0600))
+		i := &Installer{log: &fakeLogger{}, opts: Opts{BinaryPluginJSONPath: pluginJSON}}
+		pluginsDir := t.TempDir()
+
+		err := i.extractFiles(binary, "test-datasource", pluginsDir, false)
+		require.NoError(t, err)
+
+		_, err = os.Stat(filepath.Join(pluginsDir, "test-datasource", "gpx_test"+executable))
+		require.NoError(t, err)
+	})
+
+	t.Run("Should fail without plugin.json or plugin type", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+
+		err := i.extractFiles(binary, "test-renderer", t.TempDir(), false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires a plugin.json")
+	})
+
+	t.Run("Should fail for plugin.json of other plugin", func(t *testing.T) {
+		pluginJSON := filepath.Join(t.TempDir(), "plugin.json")
+		require.NoError(t, ioutil.WriteFile(pluginJSON,
+			[]byte(`{"id": "other-datasource", "backend": true, "executable": "gpx_test"}`), 0600))
+		i := &Installer{log: &fakeLogger{}, opts: Opts{BinaryPluginJSONPath: pluginJSON}}
+
+		err := i.extractFiles(binary, "test-datasource", t.TempDir(), false)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,176 @@
+package installer
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// WrongPlatformError is returned when a backend executable of the installed plugin is a binary for another
+// platform than the one it's installed for, which would only fail once Grafana tries to start it.
+type WrongPlatformError struct {
+	PluginID string
+	Path     string
+	// Binary is the format and architecture of the binary, e.g. PE amd64.
+	Binary string
+	// Expected is the platform the executable is installed for, e.g. linux/amd64.
+	Expected string
+}
+
+func (e *WrongPlatformError) Error() string {
+	return fmt.Sprintf("wrong platform artifact in plugin %s: %s is a %s binary, expected a %s binary", e.PluginID,
+		e.Path, e.Binary, e.Expected)
+}
+
+// binaryFormats are the executable formats of the operating systems Grafana runs on.
+var binaryFormats = map[string]string{
+	"linux":   "ELF",
+	"freebsd": "ELF",
+	"openbsd": "ELF",
+	"netbsd":  "ELF",
+	"windows": "PE",
+	"darwin":  "Mach-O",
+}
+
+var elfArchs = map[elf.Machine]string{
+	elf.EM_X86_64:  "amd64",
+	elf.EM_386:     "386",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_ARM:     "arm",
+}
+
+var peArchs = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+	pe.IMAGE_FILE_MACHINE_I386:  "386",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+	pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+}
+
+var machoArchs = map[macho.Cpu]string{
+	macho.CpuAmd64: "amd64",
+	macho.Cpu386:   "386",
+	macho.CpuArm64: "arm64",
+	macho.CpuArm:   "arm",
+}
+
+// knownArchs are the architectures binaries are inspected for.
+var knownArchs = map[string]bool{"amd64": true, "386": true, "arm64": true, "arm": true}
+
+// checkExecutables inspects the headers of the backend executables declared by the installed plugin and rejects
+// binaries built for another platform. Variants named <executable>_<os>_<arch>[.exe] have to be built for the
+// platform in their name, other executables for the current platform. Files that aren't ELF, PE or Mach-O
+// binaries, like scripts, aren't checked.
+func checkExecutables(pluginsDir, pluginID string) error {
+	pluginDir := filepath.Join(pluginsDir, pluginID)
+	executables, err := declaredExecutables(pluginDir)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(pluginDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() || !isDeclaredExecutable(path, executables) {
+			return nil
+		}
+
+		expectedOS, expectedArch := executablePlatform(path, executables)
+		if _, exists := binaryFormats[expectedOS]; !exists || !knownArchs[expectedArch] {
+			return nil
+		}
+		format, archs, err := inspectBinary(path)
+		if err != nil || format == "" {
+			return err
+		}
+		if format == binaryFormats[expectedOS] && containsString(archs, expectedArch) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(pluginDir, path)
+		if err != nil {
+			return err
+		}
+		return &WrongPlatformError{
+			PluginID: pluginID,
+			Path:     filepath.ToSlash(rel),
+			Binary:   fmt.Sprintf("%s %s", format, strings.Join(archs, "+")),
+			Expected: fmt.Sprintf("%s/%s", expectedOS, expectedArch),
+		}
+	})
+}
+
+// executablePlatform returns the platform a declared executable is for, either from its _<os>_<arch>[.exe]
+// suffix or the current one.
+func executablePlatform(path string, executables []string) (string, string) {
+	for _, executable := range executables {
+		if path == executable || !strings.HasPrefix(path, executable) {
+			continue
+		}
+		suffix := strings.TrimSuffix(path[len(executable):], ".exe")
+		if parts := strings.Split(strings.TrimPrefix(suffix, "_"), "_"); len(parts) == 2 {
+			return parts[0], parts[1]
+		}
+	}
+	return runtime.GOOS, runtime.GOARCH
+}
+
+// inspectBinary returns the format and architectures of an ELF, PE or Mach-O binary. The format is empty for
+// other files. Architectures Grafana doesn't run on are reported as unknown.
+func inspectBinary(path string) (string, []string, error) {
+	// nolint:gosec
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		// Too short to be a binary
+		return "", nil, nil
+	}
+
+	switch {
+	case string(magic) == elf.ELFMAG:
+		ef, err := elf.NewFile(f)
+		if err != nil {
+			return "", nil, fmt.Errorf("malformed ELF binary %s: %w", filepath.Base(path), err)
+		}
+		return "ELF", []string{archName(elfArchs[ef.Machine])}, nil
+	case magic[0] == 'M' && magic[1] == 'Z':
+		pf, err := pe.NewFile(f)
+		if err != nil {
+			return "", nil, fmt.Errorf("malformed PE binary %s: %w", filepath.Base(path), err)
+		}
+		return "PE", []string{archName(peArchs[pf.Machine])}, nil
+	}
+
+	// Universal binaries contain several architectures
+	if ff, err := macho.NewFatFile(f); err == nil {
+		var archs []string
+		for _, a := range ff.Arches {
+			archs = append(archs, archName(machoArchs[a.Cpu]))
+		}
+		return "Mach-O", archs, nil
+	}
+	if mf, err := macho.NewFile(f); err == nil {
+		return "Mach-O", []string{archName(machoArchs[mf.Cpu])}, nil
+	}
+	return "", nil, nil
+}
+
+func archName(arch string) string {
+	if arch == "" {
+		return "unknown"
+	}
+	return arch
+}
@@ -0,0 +1,71 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckExecutables(t *testing.T) {
+	// The test binary itself is an executable for the current platform
+	binary, err := os.Executable()
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(binary)
+	require.NoError(t, err)
+	otherOS, otherArch := "windows", "arm64"
+	if runtime.GOOS == "windows" {
+		otherOS = "linux"
+	}
+	if runtime.GOARCH == "arm64" {
+		otherArch = "amd64"
+	}
+
+	writePlugin := func(t *testing.T, executables ...string) string {
+		t.Helper()
+		pluginsDir := t.TempDir()
+		pluginDir := filepath.Join(pluginsDir, "test-datasource")
+		require.NoError(t, os.MkdirAll(pluginDir, 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pluginDir, "plugin.json"),
+			[]byte(`{"id":"test-datasource","type":"datasource","backend":true,"executable":"gpx_test"}`), 0600))
+		for _, name := range executables {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(pluginDir, name), data, 0600))
+		}
+		return pluginsDir
+	}
+
+	t.Run("Should accept binaries for their platform", func(t *testing.T) {
+		pluginsDir := writePlugin(t, "gpx_test", fmt.Sprintf("gpx_test_%s_%s", runtime.GOOS, runtime.GOARCH))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pluginsDir, "test-datasource", "gpx_test_linux_ppc64le"),
+			[]byte("#!/bin/sh\n"), 0600))
+
+		require.NoError(t, checkExecutables(pluginsDir, "test-datasource"))
+	})
+
+	t.Run("Should reject binaries for another platform", func(t *testing.T) {
+		for _, name := range []string{
+			fmt.Sprintf("gpx_test_%s_%s", otherOS, runtime.GOARCH),
+			fmt.Sprintf("gpx_test_%s_%s", runtime.GOOS, otherArch),
+		} {
+			pluginsDir := writePlugin(t, name)
+
+			var platformErr *WrongPlatformError
+			require.ErrorAs(t, checkExecutables(pluginsDir, "test-datasource"), &platformErr, name)
+			assert.Equal(t, name, platformErr.Path)
+			assert.Contains(t, platformErr.Error(), "wrong platform artifact")
+		}
+	})
+
+	t.Run("Should ignore files that aren't binaries", func(t *testing.T) {
+		pluginsDir := writePlugin(t)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pluginsDir, "test-datasource", "gpx_test"),
+			[]byte("#!/bin/sh\nexec ./gpx_test_linux_amd64\n"), 0600))
+
+		require.NoError(t, checkExecutables(pluginsDir, "test-datasource"))
+	})
+}
@@ -0,0 +1,56 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
+// Channel is a release channel plugin versions are published to, see repo.Channel.
+type Channel = repo.Channel
+
+const (
+	ChannelStable  = repo.ChannelStable
+	ChannelBeta    = repo.ChannelBeta
+	ChannelNightly = repo.ChannelNightly
+)
+
+// ParseChannel returns the channel with the provided name, see repo.ParseChannel.
+func ParseChannel(name string) (Channel, error) {
+	return repo.ParseChannel(name)
+}
+
+// parsePluginRef splits references of the form pluginID@version or pluginID@channel.
+func parsePluginRef(ref, requestedVersion string) (string, string, Channel, error) {
+	idx := strings.LastIndex(ref, "@")
+	if idx < 0 {
+		return ref, requestedVersion, "", nil
+	}
+	pluginID, suffix := ref[:idx], ref[idx+1:]
+	if pluginID == "" || suffix == "" {
+		return "", "", "", fmt.Errorf("invalid plugin reference %q", ref)
+	}
+
+	if c, err := ParseChannel(suffix); err == nil {
+		return pluginID, requestedVersion, c, nil
+	}
+	if requestedVersion != "" && requestedVersion != suffix {
+		return "", "", "", fmt.Errorf("conflicting versions %s and %s requested for %s", suffix, requestedVersion,
+			pluginID)
+	}
+	return pluginID, suffix, "", nil
+}
+
+// resolveChannel returns the channel the version of a plugin is selected from, the requested channel or else the
+// configured one. Without either, prerelease versions are skipped like semver tooling does, unless they're allowed
+// with Opts.AllowPrerelease or the requested version or range is a prerelease.
+func (i *Installer) resolveChannel(channel Channel, requestedVersion string) Channel {
+	if channel == "" {
+		channel = i.opts.Channel
+	}
+	if channel == "" && !i.opts.AllowPrerelease && !repo.RequestsPrerelease(requestedVersion) {
+		return ChannelStable
+	}
+	return channel
+}
@@ -0,0 +1,111 @@
+package installer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectVersionWithChannel(t *testing.T) {
+	plugin := &Plugin{
+		ID: "test-panel",
+		Versions: []Version{
+			{Version: "2.1.0-nightly.20210501"},
+			{Version: "2.0.0-beta.2"},
+			{Version: "1.9.0", Channel: "beta"},
+			{Version: "1.8.0"},
+		},
+	}
+
+	for channel, expected := range map[Channel]string{
+		"":             "2.1.0-nightly.20210501",
+		ChannelNightly: "2.1.0-nightly.20210501",
+		ChannelBeta:    "2.0.0-beta.2",
+		ChannelStable:  "1.8.0",
+	} {
+		v, err := selectVersion(plugin, "", channel)
+		require.NoError(t, err)
+		assert.Equal(t, expected, v.Version, "channel %q", channel)
+	}
+
+	t.Run("Explicit version ignores channel", func(t *testing.T) {
+		v, err := selectVersion(plugin, "2.0.0-beta.2", ChannelStable)
+		require.NoError(t, err)
+		assert.Equal(t, "2.0.0-beta.2", v.Version)
+	})
+
+	t.Run("Should fail when channel has no versions", func(t *testing.T) {
+		_, err := selectVersion(&Plugin{ID: "test-panel", Versions: []Version{{Version: "1.0.0-beta"}}}, "",
+			ChannelStable)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "stable channel")
+	})
+}
+
+func TestResolveChannel(t *testing.T) {
+	i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+
+	t.Run("Should skip prerelease versions by default", func(t *testing.T) {
+		assert.Equal(t, ChannelStable, i.resolveChannel("", ""))
+		assert.Equal(t, ChannelStable, i.resolveChannel("", "^1.2.0"))
+		assert.Equal(t, ChannelStable, i.resolveChannel("", "1.x"))
+	})
+
+	t.Run("Should consider prerelease versions when requested", func(t *testing.T) {
+		assert.Equal(t, Channel(""), i.resolveChannel("", "1.2.0-beta.1"))
+		assert.Equal(t, Channel(""), i.resolveChannel("", ">=1.2.0-beta.1 <2.0.0"))
+		assert.Equal(t, Channel(""), i.resolveChannel("", "^1.0.0 || ^2.0.0-rc.1"))
+		assert.Equal(t, ChannelBeta, i.resolveChannel(ChannelBeta, ""))
+
+		assert.Equal(t, ChannelNightly, NewWithOpts(Opts{Channel: ChannelNightly}, "8.0.0",
+			&fakeLogger{}).resolveChannel("", ""))
+		assert.Equal(t, Channel(""), NewWithOpts(Opts{AllowPrerelease: true}, "8.0.0",
+			&fakeLogger{}).resolveChannel("", ""))
+	})
+
+	t.Run("Should install latest stable version of a dependency", func(t *testing.T) {
+		dir := writeTestDependencyRepo(t, map[string]string{
+			"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-panel","type":"panel"}]}}`,
+			"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`,
+			"test-panel-2.0.0-beta.1.zip": `{"id":"test-panel","type":"panel","name":"Panel",
+				"info":{"version":"2.0.0-beta.1"}}`,
+		})
+		for allow, expected := range map[bool]string{false: "1.0.0", true: "2.0.0-beta.1"} {
+			i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, AllowPrerelease: allow}, "8.0.0",
+				&fakeLogger{})
+			tree, err := i.ResolveDependencyTree("test-app", "", dir)
+			require.NoError(t, err)
+			require.Len(t, tree.Dependencies, 1)
+			assert.Equal(t, expected, tree.Dependencies[0].Version, "allow prerelease %t", allow)
+		}
+	})
+}
+
+func TestParsePluginRef(t *testing.T) {
+	tcs := []struct {
+		ref, version                string
+		expectedID, expectedVersion string
+		expectedChannel             Channel
+		expectError                 bool
+	}{
+		{ref: "test-panel", expectedID: "test-panel"},
+		{ref: "test-panel", version: "1.0.0", expectedID: "test-panel", expectedVersion: "1.0.0"},
+		{ref: "test-panel@beta", expectedID: "test-panel", expectedChannel: ChannelBeta},
+		{ref: "test-panel@1.2.3", expectedID: "test-panel", expectedVersion: "1.2.3"},
+		{ref: "test-panel@1.2.3", version: "1.0.0", expectError: true},
+		{ref: "@beta", expectError: true},
+	}
+	for _, tc := range tcs {
+		id, version, channel, err := parsePluginRef(tc.ref, tc.version)
+		if tc.expectError {
+			require.Error(t, err, tc.ref)
+			continue
+		}
+		require.NoError(t, err, tc.ref)
+		assert.Equal(t, tc.expectedID, id)
+		assert.Equal(t, tc.expectedVersion, version)
+		assert.Equal(t, tc.expectedChannel, channel)
+	}
+}
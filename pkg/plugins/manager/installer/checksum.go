@@ -0,0 +1,138 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// checksumFileSuffix is appended to a direct plugin URL to find its companion checksum file.
+const checksumFileSuffix = ".sha256"
+
+// ErrChecksumRequired is returned when checksums are required, but none is available for the plugin archive.
+var ErrChecksumRequired = errors.New("no checksum available to verify the plugin archive")
+
+// directURLChecksum returns the checksum to verify an archive downloaded from a direct URL against, either read
+// from the configured checksum URL or from the companion <url>.sha256 file if enabled. It returns an empty string
+// if neither is configured.
+func (i *Installer) directURLChecksum(pluginZipURL string) (string, error) {
+	checksumURL := i.opts.ChecksumURL
+	if checksumURL == "" && i.opts.FetchChecksumFile {
+		checksumURL = pluginZipURL + checksumFileSuffix
+	}
+	if checksumURL == "" {
+		return "", nil
+	}
+
+	i.log.Debugf("Fetching checksum from %s", RedactURL(checksumURL))
+	body, err := i.readCompanionFile(checksumURL)
+	if err != nil {
+		return "", errutil.Wrapf(err, "failed to fetch checksum file %s", RedactURL(checksumURL))
+	}
+
+	checksum, err := parseChecksumFile(body)
+	if err != nil {
+		return "", errutil.Wrapf(err, "invalid checksum file %s", RedactURL(checksumURL))
+	}
+	return checksum, nil
+}
+
+// readCompanionFile reads a file published alongside an archive, like its checksum or signature. Just like
+// plugin archives, these can be local files.
+func (i *Installer) readCompanionFile(fileURL string) ([]byte, error) {
+	if _, err := os.Stat(fileURL); err == nil {
+		// nolint:gosec
+		return ioutil.ReadFile(fileURL)
+	}
+	return i.sendRequestGetBytes(fileURL)
+}
+
+// parseChecksumFile reads the checksum from the output of sha256sum or sha512sum, that is the hex encoded
+// checksum optionally followed by the file name. The checksum may be prefixed with its algorithm, e.g.
+// sha512:<checksum>. Only the first line is considered.
+func parseChecksumFile(body []byte) (string, error) {
+	fields := strings.Fields(strings.SplitN(string(body), "\n", 2)[0])
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum file is empty")
+	}
+
+	c, err := parseChecksum(fields[0])
+	if err != nil {
+		return "", err
+	}
+	return c.String(), nil
+}
+
+// archiveChecksum is a hex encoded digest and the hash algorithm it was computed with. The zero value computes
+// SHA256 checksums.
+type archiveChecksum struct {
+	algorithm string
+	digest    string
+}
+
+var checksumAlgorithms = map[string]struct {
+	size    int
+	newHash func() hash.Hash
+}{
+	"sha256": {sha256.Size, sha256.New},
+	"sha384": {sha512.Size384, sha512.New384},
+	"sha512": {sha512.Size, sha512.New},
+}
+
+// parseChecksum parses checksums of the form <algorithm>:<hex digest>. Checksums without algorithm are SHA256
+// checksums, unless their length identifies them as SHA512 checksums.
+func parseChecksum(s string) (archiveChecksum, error) {
+	c := archiveChecksum{digest: strings.ToLower(s)}
+	if idx := strings.Index(s, ":"); idx >= 0 {
+		c.algorithm, c.digest = strings.ToLower(s[:idx]), strings.ToLower(s[idx+1:])
+	} else if len(c.digest) == 2*sha512.Size {
+		c.algorithm = "sha512"
+	} else {
+		c.algorithm = "sha256"
+	}
+
+	algorithm, exists := checksumAlgorithms[c.algorithm]
+	if !exists {
+		return archiveChecksum{}, fmt.Errorf("unsupported checksum algorithm %q", c.algorithm)
+	}
+	if decoded, err := hex.DecodeString(c.digest); err != nil || len(decoded) != algorithm.size {
+		return archiveChecksum{}, fmt.Errorf("%q is not a %s checksum", s, strings.ToUpper(c.algorithm))
+	}
+	return c, nil
+}
+
+func (c archiveChecksum) newHash() hash.Hash {
+	if algorithm, exists := checksumAlgorithms[c.algorithm]; exists {
+		return algorithm.newHash()
+	}
+	return sha256.New()
+}
+
+// matches reports whether the hash, which has to be created with newHash, has the expected digest.
+func (c archiveChecksum) matches(h hash.Hash) bool {
+	return hex.EncodeToString(h.Sum(nil)) == c.digest
+}
+
+func (c archiveChecksum) name() string {
+	if c.algorithm == "" {
+		return "SHA256"
+	}
+	return strings.ToUpper(c.algorithm)
+}
+
+// String returns the checksum prefixed with its algorithm. SHA256 checksums aren't prefixed, as that's the
+// format plugin repositories use.
+func (c archiveChecksum) String() string {
+	if c.algorithm == "" || c.algorithm == "sha256" {
+		return c.digest
+	}
+	return c.algorithm + ":" + c.digest
+}
@@ -0,0 +1,93 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// ChecksumMismatchError is returned when a downloaded archive doesn't match its expected checksum. If the archive
+// has mirrors, it's downloaded from each of them before the error is returned, and the error tells corrupt
+// downloads, which differ from each other, from an archive that may have been tampered with.
+type ChecksumMismatchError struct {
+	// Algorithm is the name of the hash algorithm of the expected checksum.
+	Algorithm string
+	// Sources are the redacted URLs the mismatching archives were downloaded from, and Checksums the checksums of
+	// the archives.
+	Sources   []string
+	Checksums []string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	switch {
+	case len(e.Sources) <= 1:
+		return fmt.Sprintf("expected %s checksum does not match the downloaded archive - please contact "+
+			"security@grafana.com", e.Algorithm)
+	case e.Corrupt():
+		return fmt.Sprintf("expected %s checksum does not match the archives downloaded from %s, which differ "+
+			"from each other - the downloads are likely corrupt, please try again", e.Algorithm,
+			strings.Join(e.Sources, ", "))
+	default:
+		return fmt.Sprintf("expected %s checksum does not match the identical archives downloaded from %s - the "+
+			"archive may have been tampered with, please contact security@grafana.com", e.Algorithm,
+			strings.Join(e.Sources, ", "))
+	}
+}
+
+// Corrupt reports whether the archives downloaded from several mirrors differ from each other, which points to
+// corruption in transit or in storage rather than to tampering with the archive before it was mirrored.
+func (e *ChecksumMismatchError) Corrupt() bool {
+	for _, checksum := range e.Checksums {
+		if checksum != e.Checksums[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// newChecksumMismatchError returns the error of an archive downloaded from the URL not matching the expected
+// checksum. The hash has to be created with the expected checksum's newHash.
+func newChecksumMismatchError(expected archiveChecksum, h hash.Hash, downloadURL string) *ChecksumMismatchError {
+	return &ChecksumMismatchError{Algorithm: expected.name(), Sources: []string{RedactURL(downloadURL)},
+		Checksums: []string{fmt.Sprintf("%x", h.Sum(nil))}}
+}
+
+// checksumMismatches collects the checksum mismatches of the mirrors an archive is downloaded from, so that the
+// next mirror is tried instead of failing right away.
+type checksumMismatches struct {
+	err *ChecksumMismatchError
+}
+
+// add records the error if it's a checksum mismatch, reporting whether it was.
+func (m *checksumMismatches) add(err error) bool {
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		return false
+	}
+	if m.err == nil {
+		m.err = &ChecksumMismatchError{Algorithm: mismatch.Algorithm}
+	}
+	m.err.Sources = append(m.err.Sources, mismatch.Sources...)
+	m.err.Checksums = append(m.err.Checksums, mismatch.Checksums...)
+	return true
+}
+
+// warnRecovered warns which mirrors served a mismatching archive, if any, once the archive was downloaded from
+// the URL after all.
+func (m *checksumMismatches) warnRecovered(i *Installer, downloadURL string) {
+	if m.err == nil {
+		return
+	}
+	i.log.Warnf("The archive downloaded from %s does not match its %s checksum, it is corrupt or was tampered with. "+
+		"Downloaded it from %s instead", strings.Join(m.err.Sources, ", "), m.err.Algorithm, RedactURL(downloadURL))
+}
+
+// error returns the error the download failed with, which is the checksum mismatch of all mirrors if any mirror
+// served a mismatching archive.
+func (m *checksumMismatches) error(err error) error {
+	if m.err != nil {
+		return m.err
+	}
+	return err
+}
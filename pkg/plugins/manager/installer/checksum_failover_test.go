@@ -0,0 +1,109 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumMismatchFailover(t *testing.T) {
+	archive := []byte("plugin archive")
+	sum := sha256.Sum256(archive)
+	checksum := hex.EncodeToString(sum[:])
+	newServer := func(t *testing.T, body []byte) (*httptest.Server, *int) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			_, _ = w.Write(body)
+		}))
+		t.Cleanup(server.Close)
+		return server, &requests
+	}
+	download := func(t *testing.T, downloadURLs ...string) ([]byte, error) {
+		t.Helper()
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tmpFile.Close()) }()
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+		if _, _, err := i.downloadFromMirrors("test-panel", tmpFile, downloadURLs, checksum); err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadFile(tmpFile.Name())
+		require.NoError(t, err)
+		return data, nil
+	}
+
+	t.Run("Should download the archive from the next mirror if it doesn't match the checksum", func(t *testing.T) {
+		corrupt, _ := newServer(t, []byte("plugin arch1ve"))
+		mirror, mirrorRequests := newServer(t, archive)
+
+		data, err := download(t, corrupt.URL, mirror.URL)
+		require.NoError(t, err)
+		assert.Equal(t, archive, data)
+		assert.Equal(t, 1, *mirrorRequests)
+	})
+
+	t.Run("Should report corruption if the mirrors serve different archives", func(t *testing.T) {
+		a, _ := newServer(t, []byte("plugin arch1ve"))
+		b, _ := newServer(t, []byte("plugin arch2ve"))
+
+		_, err := download(t, a.URL, b.URL)
+		var mismatch *ChecksumMismatchError
+		require.ErrorAs(t, err, &mismatch)
+		assert.Equal(t, []string{a.URL, b.URL}, mismatch.Sources)
+		assert.True(t, mismatch.Corrupt())
+		assert.Contains(t, err.Error(), "likely corrupt")
+		assert.NotContains(t, err.Error(), "security@grafana.com")
+	})
+
+	t.Run("Should report possible tampering if the mirrors serve the same archive", func(t *testing.T) {
+		a, _ := newServer(t, []byte("tampered archive"))
+		b, _ := newServer(t, []byte("tampered archive"))
+
+		_, err := download(t, a.URL, b.URL)
+		var mismatch *ChecksumMismatchError
+		require.ErrorAs(t, err, &mismatch)
+		assert.False(t, mismatch.Corrupt())
+		assert.Contains(t, err.Error(), "tampered with, please contact security@grafana.com")
+	})
+
+	t.Run("Should keep the security message without mirrors", func(t *testing.T) {
+		server, _ := newServer(t, []byte("tampered archive"))
+
+		_, err := download(t, server.URL)
+		require.Error(t, err)
+		assert.Equal(t, "expected SHA256 checksum does not match the downloaded archive - please contact "+
+			"security@grafana.com", err.Error())
+	})
+
+	t.Run("Should extract the archive from the next mirror if it doesn't match the checksum", func(t *testing.T) {
+		const pluginJSON = `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`
+		archivePath := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+		data, err := ioutil.ReadFile(archivePath)
+		require.NoError(t, err)
+		corruptArchivePath := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON, "extra": "x"})
+		corruptData, err := ioutil.ReadFile(corruptArchivePath)
+		require.NoError(t, err)
+		archiveSum, err := plugins.FileSHA256(archivePath)
+		require.NoError(t, err)
+		corrupt, _ := newServer(t, corruptData)
+		mirror, _ := newServer(t, data)
+		pluginsDir := t.TempDir()
+
+		p := &pendingInstall{pluginID: "test-panel", downloadURLs: []string{corrupt.URL, mirror.URL},
+			checksum: archiveSum, event: &AuditEvent{}}
+		i := NewWithOpts(Opts{StreamExtraction: true}, "8.0.0", &fakeLogger{})
+		require.NoError(t, i.fetchArchive(p, pluginsDir))
+		defer p.discard(&fakeLogger{})
+		assert.Equal(t, mirror.URL, p.pluginZipURL)
+		assert.NoFileExists(t, filepath.Join(p.stagingDir, "test-panel", "extra"))
+	})
+}
@@ -0,0 +1,120 @@
+package installer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksumFile(t *testing.T) {
+	const checksum = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+
+	for _, body := range []string{
+		checksum,
+		checksum + "\n",
+		checksum + "  plugin.zip\n",
+		"B94D27B9934D3E08A52E52D7DA7DABFAC484EFE37A5380EE9088F7ACE2EFCDE9 *plugin.zip",
+	} {
+		res, err := parseChecksumFile([]byte(body))
+		require.NoError(t, err, body)
+		assert.Equal(t, checksum, res)
+	}
+
+	for _, body := range []string{"", "not-a-checksum", "b94d27b9", "md5:5d41402abc4b2a76b9719d911017c592"} {
+		_, err := parseChecksumFile([]byte(body))
+		require.Error(t, err, body)
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	const sha256sum = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	const sha512sum = "309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f" +
+		"989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"
+
+	tcs := []struct {
+		checksum          string
+		expectedAlgorithm string
+		expectedString    string
+	}{
+		{checksum: sha256sum, expectedAlgorithm: "sha256", expectedString: sha256sum},
+		{checksum: "sha256:" + sha256sum, expectedAlgorithm: "sha256", expectedString: sha256sum},
+		{checksum: "SHA512:" + sha512sum, expectedAlgorithm: "sha512", expectedString: "sha512:" + sha512sum},
+		{checksum: sha512sum, expectedAlgorithm: "sha512", expectedString: "sha512:" + sha512sum},
+	}
+	for _, tc := range tcs {
+		c, err := parseChecksum(tc.checksum)
+		require.NoError(t, err, tc.checksum)
+		assert.Equal(t, tc.expectedAlgorithm, c.algorithm)
+		assert.Equal(t, tc.expectedString, c.String())
+
+		h := c.newHash()
+		_, err = h.Write([]byte("hello world"))
+		require.NoError(t, err)
+		assert.True(t, c.matches(h), tc.checksum)
+	}
+
+	_, err := parseChecksum("sha512:" + sha256sum)
+	require.Error(t, err)
+}
+
+func TestDownloadFileChecksum(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "plugin.zip")
+	require.NoError(t, ioutil.WriteFile(archive, []byte("hello world"), 0600))
+	i := &Installer{log: &fakeLogger{}}
+
+	for checksum, valid := range map[string]bool{
+		"sha512:309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f" +
+			"989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f": true,
+		"sha512:" + strings.Repeat("0", 128):                               false,
+		"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9": true,
+	} {
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "*.zip")
+		require.NoError(t, err)
+		err = i.DownloadFile("test-panel", tmpFile, archive, checksum)
+		require.NoError(t, tmpFile.Close())
+		if valid {
+			require.NoError(t, err, checksum)
+		} else {
+			require.Error(t, err, checksum)
+			assert.Contains(t, err.Error(), "SHA512")
+		}
+	}
+}
+
+func TestDirectURLChecksum(t *testing.T) {
+	const checksum = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	archive := filepath.Join(t.TempDir(), "plugin.zip")
+	require.NoError(t, ioutil.WriteFile(archive+checksumFileSuffix, []byte(checksum+"  plugin.zip\n"), 0600))
+
+	t.Run("Should not fetch checksum unless enabled", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+		res, err := i.directURLChecksum(archive)
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+
+	t.Run("Should read companion checksum file", func(t *testing.T) {
+		i := &Installer{opts: Opts{FetchChecksumFile: true}, log: &fakeLogger{}}
+		res, err := i.directURLChecksum(archive)
+		require.NoError(t, err)
+		assert.Equal(t, checksum, res)
+	})
+
+	t.Run("Should fail when companion checksum file is missing", func(t *testing.T) {
+		i := &Installer{opts: Opts{FetchChecksumFile: true}, log: &fakeLogger{}}
+		_, err := i.directURLChecksum(filepath.Join(t.TempDir(), "missing.zip"))
+		require.Error(t, err)
+	})
+}
+
+func TestRequireChecksum(t *testing.T) {
+	archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+	i := &Installer{opts: Opts{RequireChecksum: true}, log: &fakeLogger{}}
+
+	err := i.Install("test-panel", "", t.TempDir(), archive, "")
+	require.ErrorIs(t, err, ErrChecksumRequired)
+}
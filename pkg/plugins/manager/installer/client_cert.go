@@ -0,0 +1,80 @@
+package installer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// clientCertLoader provides the client certificate for mutual TLS, reloading it from disk when the certificate
+// or key file changes so that rotated certificates are picked up without restarting.
+type clientCertLoader struct {
+	certPath string
+	keyPath  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certStat fileStamp
+	keyStat  fileStamp
+}
+
+// fileStamp identifies a version of a file by its modification time and size.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// newClientCertLoader loads the PEM encoded client certificate and key, failing if they can't be loaded.
+func newClientCertLoader(certPath, keyPath string) (*clientCertLoader, error) {
+	if certPath == "" || keyPath == "" {
+		return nil, fmt.Errorf("both a client certificate and a client key are required for mutual TLS")
+	}
+	l := &clientCertLoader{certPath: certPath, keyPath: keyPath}
+	if _, err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// load returns the client certificate, reloading it if either file changed since it was loaded. While a rotation
+// is in progress, e.g. the certificate was replaced but the key wasn't yet, the previous certificate is used.
+func (l *clientCertLoader) load() (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	certStat, certErr := statFile(l.certPath)
+	keyStat, keyErr := statFile(l.keyPath)
+	if l.cert != nil && certErr == nil && keyErr == nil && certStat.equal(l.certStat) &&
+		keyStat.equal(l.keyStat) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certPath, l.keyPath)
+	if err != nil {
+		if l.cert != nil {
+			return l.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load client certificate %s: %w", l.certPath, err)
+	}
+	l.cert, l.certStat, l.keyStat = &cert, certStat, keyStat
+	return l.cert, nil
+}
+
+// getClientCertificate implements tls.Config.GetClientCertificate.
+func (l *clientCertLoader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return l.load()
+}
+
+func (s fileStamp) equal(other fileStamp) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+func statFile(path string) (fileStamp, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
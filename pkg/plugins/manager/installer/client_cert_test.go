@@ -0,0 +1,121 @@
+package installer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCert(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Force a new handshake for every request, so that rotated certificates are presented
+		w.Header().Set("Connection", "close")
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeTestClientCert(t, certPath, keyPath, "client-1")
+
+	t.Run("Should present client certificate and reload it when rotated", func(t *testing.T) {
+		i := NewWithOpts(Opts{SkipTLSVerify: true, ClientCertPath: certPath, ClientKeyPath: keyPath}, "8.0.0",
+			&fakeLogger{})
+		body, err := i.sendRequestGetBytes(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "client-1", string(body))
+
+		writeTestClientCert(t, certPath, keyPath, "client-2")
+		rotated := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(certPath, rotated, rotated))
+		require.NoError(t, os.Chtimes(keyPath, rotated, rotated))
+		body, err = i.sendRequestGetBytes(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "client-2", string(body))
+	})
+
+	t.Run("Should keep previous certificate while rotation is in progress", func(t *testing.T) {
+		l, err := newClientCertLoader(certPath, keyPath)
+		require.NoError(t, err)
+		previous, err := l.load()
+		require.NoError(t, err)
+
+		otherDir := t.TempDir()
+		writeTestClientCert(t, filepath.Join(otherDir, "client.crt"), filepath.Join(otherDir, "client.key"), "client-3")
+		data, err := ioutil.ReadFile(filepath.Join(otherDir, "client.crt"))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(certPath, data, 0600))
+		rotated := time.Now().Add(2 * time.Hour)
+		require.NoError(t, os.Chtimes(certPath, rotated, rotated))
+
+		cert, err := l.load()
+		require.NoError(t, err)
+		assert.Same(t, previous, cert)
+	})
+
+	t.Run("Should use client certificate of source alias", func(t *testing.T) {
+		aliasDir := t.TempDir()
+		aliasCert, aliasKey := filepath.Join(aliasDir, "client.crt"), filepath.Join(aliasDir, "client.key")
+		writeTestClientCert(t, aliasCert, aliasKey, "corp-client")
+		i := NewWithOpts(Opts{SourceAliases: map[string]SourceAlias{
+			"corp": {URL: server.URL, SkipTLSVerify: true, ClientCertPath: aliasCert, ClientKeyPath: aliasKey},
+		}}, "8.0.0", &fakeLogger{})
+
+		body, err := i.sendRequestGetBytes(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "corp-client", string(body))
+	})
+
+	t.Run("Should fail for missing client key", func(t *testing.T) {
+		i := NewWithOpts(Opts{SkipTLSVerify: true, ClientCertPath: certPath}, "8.0.0", &fakeLogger{})
+		_, err := i.sendRequestGetBytes(server.URL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "client key")
+
+		i = NewWithOpts(Opts{SkipTLSVerify: true, ClientCertPath: certPath,
+			ClientKeyPath: filepath.Join(dir, "missing.key")}, "8.0.0", &fakeLogger{})
+		_, err = i.sendRequestGetBytes(server.URL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to load client certificate")
+	})
+}
+
+// writeTestClientCert writes a self-signed client certificate with the common name and its key.
+func writeTestClientCert(t *testing.T, certPath, keyPath, commonName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		0600))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY",
+		Bytes: keyDER}), 0600))
+}
@@ -0,0 +1,87 @@
+package installer
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decodeContent returns the body of the response decoded according to its Content-Encoding, so that checksums are
+// computed over, and extraction reads, the downloaded file itself rather than its transfer representation. It
+// reports whether the body was decoded. The download transport doesn't decompress transparently, as it would only
+// handle gzip and do so depending on whether it requested the encoding itself.
+func decodeContent(res *http.Response, body io.ReadCloser) (io.ReadCloser, bool, error) {
+	var encodings []string
+	for _, value := range res.Header.Values("Content-Encoding") {
+		for _, enc := range strings.Split(value, ",") {
+			if enc = strings.ToLower(strings.TrimSpace(enc)); enc != "" && enc != "identity" {
+				encodings = append(encodings, enc)
+			}
+		}
+	}
+	if len(encodings) == 0 {
+		return body, false, nil
+	}
+
+	decoded := &decodedBody{Reader: body, closers: []io.Closer{body}}
+	// Encodings are listed in the order they were applied
+	for idx := len(encodings) - 1; idx >= 0; idx-- {
+		var err error
+		switch encodings[idx] {
+		case "gzip", "x-gzip":
+			var zr *gzip.Reader
+			if zr, err = gzip.NewReader(decoded.Reader); err == nil {
+				decoded.Reader = zr
+				decoded.closers = append(decoded.closers, zr)
+			}
+		case "deflate":
+			var dr io.ReadCloser
+			if dr, err = newDeflateReader(decoded.Reader); err == nil {
+				decoded.Reader = dr
+				decoded.closers = append(decoded.closers, dr)
+			}
+		default:
+			err = fmt.Errorf("unsupported Content-Encoding %q", encodings[idx])
+		}
+		if err != nil {
+			_ = decoded.Close()
+			return nil, false, fmt.Errorf("failed to decode download: %w", err)
+		}
+	}
+	return decoded, true, nil
+}
+
+// newDeflateReader decodes the deflate content encoding, which is zlib wrapped deflate data, but is sent as raw
+// deflate data by some servers.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// decodedBody is a response body read through the decoders of its content encodings.
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+	for idx := len(b.closers) - 1; idx >= 0; idx-- {
+		if closeErr := b.closers[idx].Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package installer
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentEncoding(t *testing.T) {
+	archive := bytes.Repeat([]byte("plugin archive "), 100)
+	sum := sha256.Sum256(archive)
+	checksum := hex.EncodeToString(sum[:])
+	encode := func(t *testing.T, newWriter func(io.Writer) io.WriteCloser) []byte {
+		buf := new(bytes.Buffer)
+		w := newWriter(buf)
+		_, err := w.Write(archive)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return buf.Bytes()
+	}
+	newServer := func(t *testing.T, encoding string, body []byte) (*httptest.Server, *[]string) {
+		var acceptEncodings []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			acceptEncodings = append(acceptEncodings, r.Header.Get("Accept-Encoding"))
+			w.Header().Set("Content-Encoding", encoding)
+			_, _ = w.Write(body)
+		}))
+		t.Cleanup(server.Close)
+		return server, &acceptEncodings
+	}
+	download := func(t *testing.T, url string) ([]byte, error) {
+		t.Helper()
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tmpFile.Close()) }()
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+		if err := i.DownloadFile("test-panel", tmpFile, url, checksum); err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadFile(tmpFile.Name())
+		require.NoError(t, err)
+		return data, nil
+	}
+
+	t.Run("Should verify the checksum of the decoded archive", func(t *testing.T) {
+		for encoding, body := range map[string][]byte{
+			"gzip":     encode(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }),
+			"deflate":  encode(t, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }),
+			"identity": archive,
+		} {
+			server, _ := newServer(t, encoding, body)
+			data, err := download(t, server.URL)
+			require.NoError(t, err, encoding)
+			assert.Equal(t, archive, data, encoding)
+		}
+	})
+
+	t.Run("Should decode raw deflate data and stacked encodings", func(t *testing.T) {
+		raw := encode(t, func(w io.Writer) io.WriteCloser {
+			fw, err := flate.NewWriter(w, flate.DefaultCompression)
+			require.NoError(t, err)
+			return fw
+		})
+		server, _ := newServer(t, "deflate", raw)
+		data, err := download(t, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, archive, data)
+
+		buf := new(bytes.Buffer)
+		zw := gzip.NewWriter(buf)
+		_, err = zw.Write(raw)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		server, _ = newServer(t, "deflate, gzip", buf.Bytes())
+		data, err = download(t, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, archive, data)
+	})
+
+	t.Run("Should not request compressed downloads", func(t *testing.T) {
+		server, acceptEncodings := newServer(t, "", archive)
+		_, err := download(t, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, []string{""}, *acceptEncodings)
+	})
+
+	t.Run("Should fail for unsupported content encodings", func(t *testing.T) {
+		server, _ := newServer(t, "br", archive)
+		_, err := download(t, server.URL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unsupported Content-Encoding "br"`)
+	})
+}
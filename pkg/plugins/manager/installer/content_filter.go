@@ -0,0 +1,165 @@
+package installer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// ContentFilter rejects plugins containing files an organization doesn't allow in the plugins directory. The
+// zero value allows all files.
+type ContentFilter struct {
+	// DenyNestedArchives rejects plugins containing archives, detected by their extension or contents, which
+	// could smuggle files past the other checks.
+	DenyNestedArchives bool
+	// DeniedExtensions are file extensions plugins may not contain, e.g. .so or .dylib. Matching is case
+	// insensitive and versioned shared libraries like libfoo.so.1 match .so.
+	DeniedExtensions []string
+}
+
+func (f ContentFilter) enabled() bool {
+	return f.DenyNestedArchives || len(f.DeniedExtensions) > 0
+}
+
+// DeniedContentError is returned when a plugin contains a file the content filter doesn't allow.
+type DeniedContentError struct {
+	PluginID string
+	Path     string
+	Reason   string
+}
+
+func (e *DeniedContentError) Error() string {
+	return fmt.Sprintf("plugin %s contains %s, which isn't allowed: %s", e.PluginID, e.Path, e.Reason)
+}
+
+// archiveExtensions are the extensions of archive formats, checked in addition to the contents of files.
+var archiveExtensions = []string{".zip", ".tar", ".tgz", ".gz", ".bz2", ".xz", ".zst", ".7z", ".rar", ".jar", ".war"}
+
+// archiveMagics are the leading bytes of archive formats.
+var archiveMagics = [][]byte{
+	[]byte("PK\x03\x04"),
+	[]byte("PK\x05\x06"),
+	{0x1f, 0x8b},
+	[]byte("BZh"),
+	{0xfd, '7', 'z', 'X', 'Z', 0x00},
+	{0x28, 0xb5, 0x2f, 0xfd},
+	{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c},
+	[]byte("Rar!\x1a\x07"),
+}
+
+const (
+	tarMagicOffset = 257
+	tarMagic       = "ustar"
+)
+
+// checkContent applies the content filter to the files of the extracted plugin.
+func (i *Installer) checkContent(pluginsDir, pluginID string) error {
+	filter := i.opts.ContentFilter
+	if !filter.enabled() {
+		return nil
+	}
+
+	pluginDir := filepath.Join(pluginsDir, pluginID)
+	return filepath.Walk(pluginDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(pluginDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		for _, ext := range filter.DeniedExtensions {
+			if hasExtension(fi.Name(), ext) {
+				return &DeniedContentError{PluginID: pluginID, Path: rel,
+					Reason: fmt.Sprintf("%s files are denied", normalizeExtension(ext))}
+			}
+		}
+		if !filter.DenyNestedArchives {
+			return nil
+		}
+		for _, ext := range archiveExtensions {
+			if hasExtension(fi.Name(), ext) {
+				return &DeniedContentError{PluginID: pluginID, Path: rel, Reason: "nested archives are denied"}
+			}
+		}
+		isArchive, err := isArchiveFile(path)
+		if err != nil {
+			return err
+		}
+		if isArchive {
+			return &DeniedContentError{PluginID: pluginID, Path: rel, Reason: "nested archives are denied"}
+		}
+		return nil
+	})
+}
+
+// hasExtension reports whether the file name has the extension, ignoring case and version suffixes like the .1
+// of libfoo.so.1.
+func hasExtension(name, ext string) bool {
+	ext = normalizeExtension(ext)
+	if ext == "." {
+		return false
+	}
+	name = strings.ToLower(name)
+	for {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+		idx := strings.LastIndex(name, ".")
+		if idx <= 0 || !isNumber(name[idx+1:]) {
+			return false
+		}
+		name = name[:idx]
+	}
+}
+
+func normalizeExtension(ext string) string {
+	return "." + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isArchiveFile reports whether the file starts with the magic bytes of an archive or compressed file.
+func isArchiveFile(path string) (bool, error) {
+	// nolint:gosec
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	header := make([]byte, tarMagicOffset+len(tarMagic))
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	header = header[:n]
+
+	for _, magic := range archiveMagics {
+		if bytes.HasPrefix(header, magic) {
+			return true, nil
+		}
+	}
+	return len(header) == tarMagicOffset+len(tarMagic) && string(header[tarMagicOffset:]) == tarMagic, nil
+}
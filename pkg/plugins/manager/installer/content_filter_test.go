@@ -0,0 +1,82 @@
+package installer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentFilter(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+
+	writePlugin := func(t *testing.T, files map[string][]byte) string {
+		pluginsDir := t.TempDir()
+		for name, data := range files {
+			path := filepath.Join(pluginsDir, "test-panel", name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+			require.NoError(t, ioutil.WriteFile(path, data, 0600))
+		}
+		return pluginsDir
+	}
+
+	t.Run("Should allow all files by default", func(t *testing.T) {
+		pluginsDir := writePlugin(t, map[string][]byte{"lib/helper.so": nil, "data.zip": []byte("PK\x03\x04")})
+		i := &Installer{log: &fakeLogger{}}
+
+		require.NoError(t, i.checkContent(pluginsDir, "test-panel"))
+	})
+
+	t.Run("Should reject denied extensions", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}, opts: Opts{ContentFilter: ContentFilter{DeniedExtensions: []string{"so", ".DYLIB"}}}}
+
+		for _, name := range []string{"lib/helper.so", "lib/libhook.so.1.2", "lib/Helper.dylib"} {
+			err := i.checkContent(writePlugin(t, map[string][]byte{name: nil}), "test-panel")
+			var contentErr *DeniedContentError
+			require.True(t, errors.As(err, &contentErr), name)
+			assert.Equal(t, name, contentErr.Path)
+		}
+		require.NoError(t, i.checkContent(writePlugin(t, map[string][]byte{"module.js": nil, "img/solid.svg": nil}),
+			"test-panel"))
+	})
+
+	t.Run("Should reject nested archives by extension and contents", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}, opts: Opts{ContentFilter: ContentFilter{DenyNestedArchives: true}}}
+
+		tarHeader := make([]byte, 512)
+		copy(tarHeader[tarMagicOffset:], tarMagic)
+		for name, data := range map[string][]byte{
+			"vendor.zip":    nil,
+			"dist/data.tgz": nil,
+			"img/logo.svg":  []byte("PK\x03\x04rest"),
+			"payload.bin":   {0x1f, 0x8b, 0x08},
+			"blob":          tarHeader,
+		} {
+			err := i.checkContent(writePlugin(t, map[string][]byte{name: data}), "test-panel")
+			var contentErr *DeniedContentError
+			require.True(t, errors.As(err, &contentErr), name)
+			assert.Equal(t, name, contentErr.Path)
+		}
+		require.NoError(t, i.checkContent(writePlugin(t, map[string][]byte{
+			"plugin.json": []byte(pluginJSON),
+			"module.js":   []byte("define([], function() {})"),
+			"empty.txt":   nil,
+		}), "test-panel"))
+	})
+
+	t.Run("Should refuse plugin before installing it", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore,
+			ContentFilter: ContentFilter{DenyNestedArchives: true}}}
+
+		err := i.Install("test-panel", "", pluginsDir,
+			writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON, "extra.zip": ""}), "")
+		var contentErr *DeniedContentError
+		require.True(t, errors.As(err, &contentErr))
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+	})
+}
@@ -0,0 +1,420 @@
+package installer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+const (
+	// cosignSignatureSuffix and cosignCertificateSuffix are appended to the archive URL to find the outputs of
+	// cosign sign-blob --output-signature and --output-certificate.
+	cosignSignatureSuffix   = ".sig"
+	cosignCertificateSuffix = ".pem"
+	defaultRekorURL         = "https://rekor.sigstore.dev"
+)
+
+// sigstoreRekorPublicKey is the public key of the public good Rekor instance at defaultRekorURL. Keys of other
+// logs have to be configured, fetching them from the log being verified would let the log vouch for itself.
+const sigstoreRekorPublicKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE2G2Y+2tabdTV5BcGiBIx0a9fAFwr
+kBbmLSGtks4L3qX6yYY0zufBnhC8Ur/iy55GhWP/9A/bY2LhC30M9+RYtw==
+-----END PUBLIC KEY-----
+`
+
+// oidFulcioIssuer is the certificate extension Fulcio records the OIDC issuer of the signer's identity in.
+var oidFulcioIssuer = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+
+// CosignOpts configures the verification of plugin archives signed with cosign sign-blob. Archives are either
+// verified against a public key or, keyless, against the Fulcio certificate of a signer identity. In both cases
+// the signature has to be recorded in the Rekor transparency log.
+type CosignOpts struct {
+	// KeyPath is the path to the PEM encoded public key the archive has to be signed with.
+	KeyPath string
+	// Identity is the subject (email or URI) of the keyless signer's certificate.
+	Identity string
+	// OIDCIssuer is the OIDC issuer of the keyless signer's identity, e.g. https://token.actions.githubusercontent.com.
+	OIDCIssuer string
+	// RootsPath is the path to the PEM encoded Fulcio root and intermediate certificates trusted for keyless
+	// signatures.
+	RootsPath string
+	// RekorURL is the URL of the Rekor transparency log. Defaults to https://rekor.sigstore.dev.
+	RekorURL string
+	// RekorPublicKeyPath is the path to the PEM encoded public key of the Rekor log. Required unless RekorURL is
+	// the default log, whose key is built in.
+	RekorPublicKeyPath string
+	// IgnoreTlog skips the Rekor lookup for signatures made with a public key.
+	IgnoreTlog bool
+}
+
+func (o CosignOpts) enabled() bool {
+	return o.KeyPath != "" || o.Identity != ""
+}
+
+// rekorEntry is a Rekor log entry as returned by /api/v1/log/entries.
+type rekorEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+	Verification   struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"verification"`
+}
+
+// hashedRekord is the body of a Rekor entry for a signed artifact digest.
+type hashedRekord struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// verifyCosignSignature verifies the archive against its cosign signature, read from <archiveURL>.sig, when cosign
+// verification is configured.
+func (i *Installer) verifyCosignSignature(archivePath, archiveURL string) error {
+	opts := i.opts.Cosign
+	if !opts.enabled() {
+		return nil
+	}
+
+	// nolint:gosec
+	artifact, err := ioutil.ReadFile(archivePath)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(artifact)
+
+	signature, err := i.readBase64CompanionFile(archiveURL + cosignSignatureSuffix)
+	if err != nil {
+		return errutil.Wrap("failed to read cosign signature", err)
+	}
+
+	var pub crypto.PublicKey
+	var cert *x509.Certificate
+	var signerPEM []byte
+	if opts.KeyPath != "" {
+		// nolint:gosec
+		if signerPEM, err = ioutil.ReadFile(opts.KeyPath); err != nil {
+			return errutil.Wrap("failed to read cosign public key", err)
+		}
+		if pub, err = parsePEMPublicKey(signerPEM); err != nil {
+			return errutil.Wrap("invalid cosign public key", err)
+		}
+	} else {
+		if signerPEM, err = i.readBase64CompanionFile(archiveURL + cosignCertificateSuffix); err != nil {
+			return errutil.Wrap("failed to read cosign certificate", err)
+		}
+		if cert, err = parsePEMCertificate(signerPEM); err != nil {
+			return errutil.Wrap("invalid cosign certificate", err)
+		}
+		pub = cert.PublicKey
+	}
+	if err := i.checkFIPSKey(pub); err != nil {
+		return errutil.Wrap("invalid cosign signer", err)
+	}
+
+	if err := verifyBlobSignature(pub, artifact, digest[:], signature); err != nil {
+		return errutil.Wrap("cosign signature verification failed", err)
+	}
+
+	if cert == nil && opts.IgnoreTlog {
+		i.log.Info("Verified cosign signature, skipping transparency log lookup")
+		return nil
+	}
+
+	entry, err := i.findRekorEntry(digest[:], signature, signerPEM)
+	if err != nil {
+		return errutil.Wrap("cosign signature is not recorded in the transparency log", err)
+	}
+
+	if cert != nil {
+		// Fulcio certificates are only valid for a few minutes, check them at the time the log recorded the
+		// signature
+		if err := verifyFulcioCertificate(cert, opts, time.Unix(entry.IntegratedTime, 0)); err != nil {
+			return errutil.Wrap("cosign certificate verification failed", err)
+		}
+		i.log.Infof("Verified cosign signature by %s (%s)", opts.Identity, opts.OIDCIssuer)
+		return nil
+	}
+	i.log.Info("Verified cosign signature")
+	return nil
+}
+
+// readBase64CompanionFile reads a companion file, decoding its contents if they are base64 encoded like the
+// outputs of cosign sign-blob.
+func (i *Installer) readBase64CompanionFile(fileURL string) ([]byte, error) {
+	body, err := i.readCompanionFile(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	body = bytes.TrimSpace(body)
+	if decoded, err := base64.StdEncoding.DecodeString(string(body)); err == nil {
+		return decoded, nil
+	}
+	return body, nil
+}
+
+func verifyBlobSignature(pub crypto.PublicKey, artifact, digest, signature []byte) error {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, artifact, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported public key type %T", pub)
+}
+
+// verifyFulcioCertificate checks that the certificate was issued by a trusted Fulcio root to the configured
+// identity and OIDC issuer.
+func verifyFulcioCertificate(cert *x509.Certificate, opts CosignOpts, at time.Time) error {
+	if opts.RootsPath == "" {
+		return errors.New("no Fulcio roots configured for keyless verification")
+	}
+	// nolint:gosec
+	rootsPEM, err := ioutil.ReadFile(opts.RootsPath)
+	if err != nil {
+		return errutil.Wrap("failed to read Fulcio roots", err)
+	}
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for rest := rootsPEM; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return errutil.Wrap("invalid Fulcio certificate", err)
+		}
+		if bytes.Equal(c.RawIssuer, c.RawSubject) {
+			roots.AddCert(c)
+		} else {
+			intermediates.AddCert(c)
+		}
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return err
+	}
+
+	var issuer string
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidFulcioIssuer) {
+			issuer = string(ext.Value)
+		}
+	}
+	if issuer != opts.OIDCIssuer {
+		return fmt.Errorf("certificate OIDC issuer %q doesn't match %q", issuer, opts.OIDCIssuer)
+	}
+
+	identities := append([]string{}, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		identities = append(identities, u.String())
+	}
+	for _, identity := range identities {
+		if identity == opts.Identity {
+			return nil
+		}
+	}
+	return fmt.Errorf("certificate identities %s don't match %q", strings.Join(identities, ", "), opts.Identity)
+}
+
+// findRekorEntry looks up the transparency log entry of the signature, verifying that it records the artifact
+// digest, signature and signer and that it was signed by the log.
+func (i *Installer) findRekorEntry(digest, signature, signerPEM []byte) (*rekorEntry, error) {
+	rekorURL := strings.TrimSuffix(i.opts.Cosign.RekorURL, "/")
+	if rekorURL == "" {
+		rekorURL = defaultRekorURL
+	}
+	logKey, err := i.rekorPublicKey(rekorURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var uuids []string
+	if err := i.postJSON(rekorURL+"/api/v1/index/retrieve",
+		map[string]string{"hash": "sha256:" + hex.EncodeToString(digest)}, &uuids); err != nil {
+		return nil, err
+	}
+
+	for _, uuid := range uuids {
+		body, err := i.sendRequestGetBytes(rekorURL, "api", "v1", "log", "entries", uuid)
+		if err != nil {
+			return nil, err
+		}
+		var entries map[string]rekorEntry
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return nil, errutil.Wrap("invalid Rekor response", err)
+		}
+		for _, entry := range entries {
+			entry := entry
+			if !rekorEntryMatches(&entry, digest, signature, signerPEM) {
+				continue
+			}
+			if err := verifyRekorSET(&entry, logKey); err != nil {
+				return nil, err
+			}
+			return &entry, nil
+		}
+	}
+	return nil, errors.New("no matching entry found")
+}
+
+func rekorEntryMatches(entry *rekorEntry, digest, signature, signerPEM []byte) bool {
+	body, err := base64.StdEncoding.DecodeString(entry.Body)
+	if err != nil {
+		return false
+	}
+	var record hashedRekord
+	if err := json.Unmarshal(body, &record); err != nil || record.Kind != "hashedrekord" {
+		return false
+	}
+	if record.Spec.Data.Hash.Algorithm != "sha256" || record.Spec.Data.Hash.Value != hex.EncodeToString(digest) {
+		return false
+	}
+	recordSig, err := base64.StdEncoding.DecodeString(record.Spec.Signature.Content)
+	if err != nil || !bytes.Equal(recordSig, signature) {
+		return false
+	}
+	recordPEM, err := base64.StdEncoding.DecodeString(record.Spec.Signature.PublicKey.Content)
+	if err != nil {
+		return false
+	}
+	recordBlock, _ := pem.Decode(recordPEM)
+	signerBlock, _ := pem.Decode(signerPEM)
+	return recordBlock != nil && signerBlock != nil && bytes.Equal(recordBlock.Bytes, signerBlock.Bytes)
+}
+
+// verifyRekorSET verifies the signed entry timestamp, the log's promise to include the entry.
+func verifyRekorSET(entry *rekorEntry, logKey crypto.PublicKey) error {
+	// The timestamp signs the canonical JSON of these fields, encoding/json sorts struct fields as declared
+	payload, err := json.Marshal(struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+	}{entry.Body, entry.IntegratedTime, entry.LogID, entry.LogIndex})
+	if err != nil {
+		return err
+	}
+	set, err := base64.StdEncoding.DecodeString(entry.Verification.SignedEntryTimestamp)
+	if err != nil {
+		return errutil.Wrap("invalid signed entry timestamp", err)
+	}
+	digest := sha256.Sum256(payload)
+	if err := verifyBlobSignature(logKey, payload, digest[:], set); err != nil {
+		return errutil.Wrap("invalid signed entry timestamp", err)
+	}
+	return nil
+}
+
+func (i *Installer) rekorPublicKey(rekorURL string) (crypto.PublicKey, error) {
+	if i.opts.Cosign.RekorPublicKeyPath == "" {
+		if rekorURL != defaultRekorURL {
+			return nil, fmt.Errorf("no public key configured for Rekor log %s", rekorURL)
+		}
+		return parsePEMPublicKey([]byte(sigstoreRekorPublicKey))
+	}
+	// nolint:gosec
+	data, err := ioutil.ReadFile(i.opts.Cosign.RekorPublicKeyPath)
+	if err != nil {
+		return nil, errutil.Wrap("failed to read Rekor public key", err)
+	}
+	return parsePEMPublicKey(data)
+}
+
+// postJSON sends a JSON request and decodes the JSON response into v.
+func (i *Installer) postJSON(URL string, body interface{}, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(URL)
+	if err != nil {
+		return RedactURLError(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "grafana "+i.grafanaVersion)
+
+	client, err := i.clientFor(u, false)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return RedactURLError(err)
+	}
+	bodyReader, err := i.handleResponse(res)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := bodyReader.Close(); err != nil {
+			i.log.Warn("Failed to close body", "err", err)
+		}
+	}()
+	return json.NewDecoder(io.LimitReader(bodyReader, 1<<20)).Decode(v)
+}
+
+func parsePEMPublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func parsePEMCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
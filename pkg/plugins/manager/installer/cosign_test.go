@@ -0,0 +1,250 @@
+package installer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCosignSignature(t *testing.T) {
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rekor := newFakeRekor(t, rekorKey)
+	rekorKeyPath := writeTestPublicKey(t, &rekorKey.PublicKey)
+
+	archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+	artifact, err := ioutil.ReadFile(archive)
+	require.NoError(t, err)
+	digest := sha256.Sum256(artifact)
+
+	signerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signature, err := ecdsa.SignASN1(rand.Reader, signerKey, digest[:])
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(archive+cosignSignatureSuffix,
+		[]byte(base64.StdEncoding.EncodeToString(signature)), 0600))
+
+	t.Run("Should verify signature made with public key", func(t *testing.T) {
+		keyDER, err := x509.MarshalPKIXPublicKey(&signerKey.PublicKey)
+		require.NoError(t, err)
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyDER})
+		keyPath := filepath.Join(t.TempDir(), "cosign.pub")
+		require.NoError(t, ioutil.WriteFile(keyPath, keyPEM, 0600))
+		opts := CosignOpts{KeyPath: keyPath, RekorURL: rekor.URL, RekorPublicKeyPath: rekorKeyPath}
+		i := &Installer{log: &fakeLogger{}, opts: Opts{Cosign: opts}}
+
+		rekor.entries = nil
+		err = i.verifyCosignSignature(archive, archive)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "transparency log")
+
+		rekor.add(t, digest[:], signature, keyPEM, time.Now())
+		require.NoError(t, i.verifyCosignSignature(archive, archive))
+
+		i.opts.Cosign.RekorURL = ""
+		i.opts.Cosign.IgnoreTlog = true
+		require.NoError(t, i.verifyCosignSignature(archive, archive))
+	})
+
+	t.Run("Should verify keyless signature", func(t *testing.T) {
+		rootsPath, certPEM := newFulcioTestCertificate(t, &signerKey.PublicKey)
+		require.NoError(t, ioutil.WriteFile(archive+cosignCertificateSuffix,
+			[]byte(base64.StdEncoding.EncodeToString(certPEM)), 0600))
+		rekor.entries = nil
+		rekor.add(t, digest[:], signature, certPEM, time.Now())
+
+		opts := CosignOpts{
+			Identity:           "release@example.com",
+			OIDCIssuer:         "https://accounts.example.com",
+			RootsPath:          rootsPath,
+			RekorURL:           rekor.URL,
+			RekorPublicKeyPath: rekorKeyPath,
+		}
+		i := &Installer{log: &fakeLogger{}, opts: Opts{Cosign: opts}}
+		require.NoError(t, i.verifyCosignSignature(archive, archive))
+
+		i.opts.Cosign.Identity = "someone@example.com"
+		require.Error(t, i.verifyCosignSignature(archive, archive))
+
+		i.opts.Cosign = opts
+		i.opts.Cosign.OIDCIssuer = "https://other.example.com"
+		require.Error(t, i.verifyCosignSignature(archive, archive))
+	})
+
+	t.Run("Should fail for log signed with another key than the configured one", func(t *testing.T) {
+		keyDER, err := x509.MarshalPKIXPublicKey(&signerKey.PublicKey)
+		require.NoError(t, err)
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyDER})
+		keyPath := filepath.Join(t.TempDir(), "cosign.pub")
+		require.NoError(t, ioutil.WriteFile(keyPath, keyPEM, 0600))
+		rekor.entries = nil
+		rekor.add(t, digest[:], signature, keyPEM, time.Now())
+
+		// The fake log serves the key it signs with, which must not be trusted
+		i := &Installer{log: &fakeLogger{}, opts: Opts{Cosign: CosignOpts{KeyPath: keyPath, RekorURL: rekor.URL}}}
+		err = i.verifyCosignSignature(archive, archive)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no public key configured")
+
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		i.opts.Cosign.RekorPublicKeyPath = writeTestPublicKey(t, &otherKey.PublicKey)
+		require.Error(t, i.verifyCosignSignature(archive, archive))
+
+		i.opts.Cosign.RekorPublicKeyPath = ""
+		_, err = i.rekorPublicKey(defaultRekorURL)
+		require.NoError(t, err)
+	})
+
+	t.Run("Should fail for modified archive", func(t *testing.T) {
+		keyDER, err := x509.MarshalPKIXPublicKey(&signerKey.PublicKey)
+		require.NoError(t, err)
+		keyPath := filepath.Join(t.TempDir(), "cosign.pub")
+		require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyDER}),
+			0600))
+		modified := filepath.Join(t.TempDir(), "plugin.tar.gz")
+		require.NoError(t, ioutil.WriteFile(modified, append(artifact, 0), 0600))
+		require.NoError(t, ioutil.WriteFile(modified+cosignSignatureSuffix,
+			[]byte(base64.StdEncoding.EncodeToString(signature)), 0600))
+		i := &Installer{log: &fakeLogger{}, opts: Opts{Cosign: CosignOpts{KeyPath: keyPath, IgnoreTlog: true}}}
+
+		require.Error(t, i.verifyCosignSignature(modified, modified))
+	})
+}
+
+func writeTestPublicKey(t *testing.T, key crypto.PublicKey) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "key.pub")
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+	return keyPath
+}
+
+type fakeRekor struct {
+	*httptest.Server
+	key     *ecdsa.PrivateKey
+	entries map[string]rekorEntry
+}
+
+func newFakeRekor(t *testing.T, key *ecdsa.PrivateKey) *fakeRekor {
+	r := &fakeRekor{key: key}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v1/log/publicKey":
+			der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+			require.NoError(t, err)
+			_, _ = w.Write(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		case "/api/v1/index/retrieve":
+			uuids := []string{}
+			for uuid := range r.entries {
+				uuids = append(uuids, uuid)
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(uuids))
+		default:
+			uuid := filepath.Base(req.URL.Path)
+			entry, exists := r.entries[uuid]
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]rekorEntry{uuid: entry}))
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *fakeRekor) add(t *testing.T, digest, signature, signerPEM []byte, integrated time.Time) {
+	var record hashedRekord
+	record.Kind = "hashedrekord"
+	record.Spec.Data.Hash.Algorithm = "sha256"
+	record.Spec.Data.Hash.Value = hex.EncodeToString(digest)
+	record.Spec.Signature.Content = base64.StdEncoding.EncodeToString(signature)
+	record.Spec.Signature.PublicKey.Content = base64.StdEncoding.EncodeToString(signerPEM)
+	body, err := json.Marshal(record)
+	require.NoError(t, err)
+
+	entry := rekorEntry{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: integrated.Unix(),
+		LogID:          "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d",
+		LogIndex:       int64(len(r.entries)),
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"body":           entry.Body,
+		"integratedTime": entry.IntegratedTime,
+		"logID":          entry.LogID,
+		"logIndex":       entry.LogIndex,
+	})
+	require.NoError(t, err)
+	payloadDigest := sha256.Sum256(payload)
+	set, err := ecdsa.SignASN1(rand.Reader, r.key, payloadDigest[:])
+	require.NoError(t, err)
+	entry.Verification.SignedEntryTimestamp = base64.StdEncoding.EncodeToString(set)
+
+	if r.entries == nil {
+		r.entries = map[string]rekorEntry{}
+	}
+	r.entries[hex.EncodeToString(digest)+entry.LogID[:8]] = entry
+}
+
+// newFulcioTestCertificate issues a short-lived code signing certificate like Fulcio does, returning the path
+// of the root certificate and the PEM encoded certificate.
+func newFulcioTestCertificate(t *testing.T, pub *ecdsa.PublicKey) (string, []byte) {
+	t.Helper()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	root := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sigstore"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, root, root, &rootKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	root, err = x509.ParseCertificate(rootDER)
+	require.NoError(t, err)
+
+	leaf := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		NotBefore:      time.Now().Add(-time.Minute),
+		NotAfter:       time.Now().Add(10 * time.Minute),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		EmailAddresses: []string{"release@example.com"},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidFulcioIssuer, Value: []byte("https://accounts.example.com")},
+		},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, root, pub, rootKey)
+	require.NoError(t, err)
+
+	rootsPath := filepath.Join(t.TempDir(), "fulcio.pem")
+	require.NoError(t, ioutil.WriteFile(rootsPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}),
+		0600))
+	return rootsPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+}
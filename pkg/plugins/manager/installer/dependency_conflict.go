@@ -0,0 +1,125 @@
+package installer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"github.com/hashicorp/go-version"
+)
+
+// DependencyRequirement is a version of a plugin required by another plugin, or requested to be installed.
+type DependencyRequirement struct {
+	// RequiredBy is the ID of the plugin depending on the plugin, empty if it was requested to be installed.
+	RequiredBy string
+	// Requested is the version or version range required, empty for any version.
+	Requested string
+}
+
+func (r DependencyRequirement) String() string {
+	requested := r.Requested
+	if requested == "" {
+		requested = "any version"
+	}
+	if r.RequiredBy == "" {
+		return requested + " (requested)"
+	}
+	return fmt.Sprintf("%s (required by %s)", requested, r.RequiredBy)
+}
+
+// DependencyConflictError is returned when plugins installed together require versions of the same plugin that
+// no single version satisfies, so that installing one would overwrite the version the other requires.
+type DependencyConflictError struct {
+	PluginID string
+	// Version is the version the first requirement resolved to.
+	Version     string
+	Resolved    DependencyRequirement
+	Conflicting DependencyRequirement
+}
+
+func (e *DependencyConflictError) Error() string {
+	return fmt.Sprintf("conflicting versions of %s: %s resolved to v%s, which doesn't satisfy %s", e.PluginID,
+		e.Resolved, e.Version, e.Conflicting)
+}
+
+// dependencyResolution tracks the plugins resolved while installing a plugin and its dependencies, or a batch of
+// plugins, so that conflicting requirements are detected before a resolved plugin is overwritten.
+type dependencyResolution struct {
+	resolved map[string]resolvedDependency
+}
+
+// resolvedDependency is a plugin version resolved for the first requirement of the plugin.
+type resolvedDependency struct {
+	version     string
+	requirement DependencyRequirement
+}
+
+func newDependencyResolution() *dependencyResolution {
+	return &dependencyResolution{resolved: map[string]resolvedDependency{}}
+}
+
+// check reports whether the plugin is already resolved to a version satisfying the requirement, failing with a
+// DependencyConflictError if it's resolved to a version that doesn't.
+func (r *dependencyResolution) check(pluginID string, requirement DependencyRequirement) (bool, error) {
+	resolved, exists := r.resolved[pluginID]
+	if !exists {
+		return false, nil
+	}
+	if satisfiesRequirement(resolved.version, requirement) {
+		return true, nil
+	}
+	return false, &DependencyConflictError{PluginID: pluginID, Version: resolved.version,
+		Resolved: resolved.requirement, Conflicting: requirement}
+}
+
+// satisfiesRequirement reports whether the plugin version satisfies the requested version or version range.
+func satisfiesRequirement(pluginVersion string, requirement DependencyRequirement) bool {
+	if requirement.Requested == "" || requirement.Requested == pluginVersion {
+		return true
+	}
+	v, err := version.NewVersion(pluginVersion)
+	if err != nil {
+		return false
+	}
+	satisfied, err := repo.VersionInRange(v, requirement.Requested)
+	return err == nil && satisfied
+}
+
+// record records the version the requirement of the plugin resolved to.
+func (r *dependencyResolution) record(pluginID, version string, requirement DependencyRequirement) {
+	if _, exists := r.resolved[pluginID]; !exists {
+		r.resolved[pluginID] = resolvedDependency{version: version, requirement: requirement}
+	}
+}
+
+// sortedDependencies returns the dependencies sorted by ID, so that they're resolved in the same order on every run.
+func sortedDependencies(deps []PluginDependency) []PluginDependency {
+	sorted := append([]PluginDependency(nil), deps...)
+	sort.SliceStable(sorted, func(a, b int) bool { return sorted[a].ID < sorted[b].ID })
+	return sorted
+}
+
+// requiredBy returns the dependent plugin of the chain of plugins whose dependencies are being resolved, empty if
+// the plugin was requested.
+func requiredBy(chain []string) string {
+	if len(chain) == 0 {
+		return ""
+	}
+	return chain[len(chain)-1]
+}
+
+// InstallAll installs the plugins, referenced as [<source alias>:]<plugin id>[@<version or channel>], and their
+// dependencies as a batch. Plugins already installed by the batch at a version satisfying the requirement aren't
+// installed again, conflicting requirements fail with a DependencyConflictError.
+func (i *Installer) InstallAll(pluginRefs []string, pluginsDir, pluginRepoURL string) error {
+	plan := i.newInstallPlan(pluginsDir)
+	resolution := newDependencyResolution()
+	for _, ref := range pluginRefs {
+		if err := i.planInstall(plan, ref, "", "", pluginRepoURL, resolution); err != nil {
+			plan.Close()
+			return errutil.Wrapf(err, "failed to install plugin '%s'", ref)
+		}
+	}
+	return i.ApplyPlan(plan)
+}
@@ -0,0 +1,72 @@
+package installer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyConflict(t *testing.T) {
+	dir := writeTestDependencyRepo(t, map[string]string{
+		"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+			"dependencies":{"plugins":[{"id":"test-panel","type":"panel","version":"^1.0.0"},
+			{"id":"test-datasource","type":"datasource"}]}}`,
+		"test-panel-1.1.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.1.0"}}`,
+		"test-panel-2.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"2.0.0"}}`,
+		"test-datasource-1.0.0.zip": `{"id":"test-datasource","type":"datasource","name":"Datasource",
+			"info":{"version":"1.0.0"},"dependencies":{"plugins":[{"id":"test-panel","type":"panel","version":">=2.0.0"}]}}`,
+	})
+	newInstaller := func() *Installer {
+		return NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+	}
+
+	t.Run("Should report conflicting requirements of dependencies without installing", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		err := newInstaller().Install("test-app", "", pluginsDir, "", dir)
+		var conflictErr *DependencyConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		// Dependencies are resolved by ID, so test-datasource and its dependencies come first
+		assert.Equal(t, &DependencyConflictError{PluginID: "test-panel", Version: "2.0.0",
+			Resolved:    DependencyRequirement{RequiredBy: "test-datasource", Requested: ">=2.0.0"},
+			Conflicting: DependencyRequirement{RequiredBy: "test-app", Requested: "^1.0.0"},
+		}, conflictErr)
+		assert.Contains(t, err.Error(), "conflicting versions of test-panel: >=2.0.0 (required by test-datasource) "+
+			"resolved to v2.0.0, which doesn't satisfy ^1.0.0 (required by test-app)")
+
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-app"))
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+	})
+
+	t.Run("Should report conflicting requirements of a batch", func(t *testing.T) {
+		err := newInstaller().InstallAll([]string{"test-panel@1.1.0", "test-datasource"}, t.TempDir(), dir)
+		var conflictErr *DependencyConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		assert.Equal(t, "1.1.0 (requested)", conflictErr.Resolved.String())
+		assert.Equal(t, ">=2.0.0 (required by test-datasource)", conflictErr.Conflicting.String())
+	})
+
+	t.Run("Should install plugins of a batch once if requirements are satisfied", func(t *testing.T) {
+		sink := &fakeAuditSink{}
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, AuditSink: sink}, "8.0.0", &fakeLogger{})
+		pluginsDir := t.TempDir()
+		require.NoError(t, i.InstallAll([]string{"test-datasource", "test-panel"}, pluginsDir, dir))
+
+		installed, err := toPluginDTO(pluginsDir, "test-panel")
+		require.NoError(t, err)
+		assert.Equal(t, "2.0.0", installed.Info.Version)
+		var installs []string
+		for _, event := range sink.events {
+			installs = append(installs, event.PluginID)
+		}
+		assert.Equal(t, []string{"test-panel", "test-datasource"}, installs)
+	})
+
+	t.Run("Should report conflicting requirements when resolving the dependency tree", func(t *testing.T) {
+		_, err := newInstaller().ResolveDependencyTree("test-app", "", dir)
+		var conflictErr *DependencyConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		assert.Equal(t, "test-panel", conflictErr.PluginID)
+	})
+}
@@ -0,0 +1,98 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DependencyCycleError is returned when a plugin depends on itself, directly or through other plugins, which
+// would otherwise install the same plugins over and over again.
+type DependencyCycleError struct {
+	// Cycle are the IDs of the plugins depending on each other, starting and ending with the same plugin.
+	Cycle []string
+}
+
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("dependency cycle detected: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// checkDependencyCycle fails if the plugin is already being installed by one of the dependents in the chain, the
+// IDs of the plugins whose dependencies are being installed, outermost first.
+func checkDependencyCycle(chain []string, pluginID string) error {
+	for idx, id := range chain {
+		if id == pluginID {
+			cycle := make([]string, 0, len(chain)-idx+1)
+			cycle = append(append(cycle, chain[idx:]...), pluginID)
+			return &DependencyCycleError{Cycle: cycle}
+		}
+	}
+	return nil
+}
+
+// maxDependencyDepth is the maximum number of plugins in a chain of dependencies, including the requested plugin.
+const maxDependencyDepth = 10
+
+// DependencyDepthError is returned when a chain of dependencies is deeper than maxDependencyDepth, which points to
+// broken plugin repository metadata rather than plugins actually depending on each other.
+type DependencyDepthError struct {
+	// Chain are the IDs of the plugins depending on each other, outermost first.
+	Chain []string
+}
+
+func (e *DependencyDepthError) Error() string {
+	return fmt.Sprintf("dependency chain exceeds the maximum depth of %d: %s", maxDependencyDepth,
+		strings.Join(e.Chain, " -> "))
+}
+
+// checkDependencyDepth fails if installing the plugin as a dependency of the chain exceeds maxDependencyDepth.
+func checkDependencyDepth(chain []string, pluginID string) error {
+	if len(chain) < maxDependencyDepth {
+		return nil
+	}
+	return &DependencyDepthError{Chain: append(append(make([]string, 0, len(chain)+1), chain...), pluginID)}
+}
+
+// DependencyMetadataError is returned when the plugin repository has no metadata for a dependency, or metadata
+// that can't be used, naming the plugins requiring the dependency.
+type DependencyMetadataError struct {
+	// Chain are the IDs of the plugins requiring the dependency, outermost first, ending with the dependency.
+	Chain []string
+	Err   error
+}
+
+func (e *DependencyMetadataError) Error() string {
+	pluginID, requiredBy := e.Chain[len(e.Chain)-1], strings.Join(e.Chain[:len(e.Chain)-1], " -> ")
+	var notFoundErr *PluginNotFoundError
+	if errors.As(e.Err, &notFoundErr) {
+		return fmt.Sprintf("dependency %s required by %s isn't published in the plugin repository", pluginID,
+			requiredBy)
+	}
+	return fmt.Sprintf("invalid metadata of dependency %s required by %s: %v", pluginID, requiredBy, e.Err)
+}
+
+func (e *DependencyMetadataError) Unwrap() error {
+	return e.Err
+}
+
+// dependencyMetadataError wraps the error looking up a dependency of the chain in a DependencyMetadataError if
+// the plugin repository has no or invalid metadata for it. Other errors, and errors looking up the requested
+// plugin, are returned unchanged.
+func dependencyMetadataError(chain []string, pluginID string, err error) error {
+	var notFoundErr *PluginNotFoundError
+	var invalidErr *InvalidRepoResponseError
+	if len(chain) == 0 || !errors.As(err, &notFoundErr) && !errors.As(err, &invalidErr) {
+		return err
+	}
+	return &DependencyMetadataError{Chain: append(append(make([]string, 0, len(chain)+1), chain...), pluginID),
+		Err: err}
+}
+
+// namesDependencyChain reports whether the error already names the chain of dependencies it occurred in, so that
+// it isn't wrapped again by every dependent plugin.
+func namesDependencyChain(err error) bool {
+	var cycleErr *DependencyCycleError
+	var depthErr *DependencyDepthError
+	var metadataErr *DependencyMetadataError
+	return errors.As(err, &cycleErr) || errors.As(err, &depthErr) || errors.As(err, &metadataErr)
+}
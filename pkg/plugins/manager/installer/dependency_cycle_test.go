@@ -0,0 +1,197 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyCycle(t *testing.T) {
+	t.Run("Should abort with the cycle when a plugin transitively depends on itself", func(t *testing.T) {
+		dir := writeTestDependencyRepo(t, map[string]string{
+			"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-panel","type":"panel","version":"1.0.0"}]}}`,
+			"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-datasource","type":"datasource","version":"^1.0.0"}]}}`,
+			"test-datasource-1.0.0.zip": `{"id":"test-datasource","type":"datasource","name":"Datasource",
+				"info":{"version":"1.0.0"},"dependencies":{"plugins":[{"id":"test-panel","type":"panel"}]}}`,
+		})
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+
+		err := i.Install("test-app", "", t.TempDir(), "", dir)
+		var cycleErr *DependencyCycleError
+		require.ErrorAs(t, err, &cycleErr)
+		assert.Equal(t, []string{"test-panel", "test-datasource", "test-panel"}, cycleErr.Cycle)
+		assert.Contains(t, err.Error(), "dependency cycle detected: test-panel -> test-datasource -> test-panel")
+	})
+
+	t.Run("Should install shared dependencies", func(t *testing.T) {
+		dir := writeTestDependencyRepo(t, map[string]string{
+			"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-panel","type":"panel"},
+				{"id":"test-datasource","type":"datasource"}]}}`,
+			"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-datasource","type":"datasource"}]}}`,
+			"test-datasource-1.0.0.zip": `{"id":"test-datasource","type":"datasource","name":"Datasource",
+				"info":{"version":"1.0.0"}}`,
+		})
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+
+		pluginsDir := t.TempDir()
+		require.NoError(t, i.Install("test-app", "", pluginsDir, "", dir))
+		for _, pluginID := range []string{"test-app", "test-panel", "test-datasource"} {
+			_, err := os.Stat(filepath.Join(pluginsDir, pluginID, "plugin.json"))
+			assert.NoError(t, err, pluginID)
+		}
+	})
+
+	t.Run("Should download dependency archives concurrently", func(t *testing.T) {
+		dir := writeTestDependencyRepo(t, map[string]string{
+			"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-panel-a","type":"panel"},{"id":"test-panel-b","type":"panel"},
+				{"id":"test-panel-c","type":"panel"}]}}`,
+			"test-panel-a-1.0.0.zip": `{"id":"test-panel-a","type":"panel","name":"A","info":{"version":"1.0.0"}}`,
+			"test-panel-b-1.0.0.zip": `{"id":"test-panel-b","type":"panel","name":"B","info":{"version":"1.0.0"}}`,
+			"test-panel-c-1.0.0.zip": `{"id":"test-panel-c","type":"panel","name":"C","info":{"version":"1.0.0"}}`,
+		})
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+
+		// Hold the panel downloads until all of them are in flight
+		var mu sync.Mutex
+		inFlight, maxInFlight := 0, 0
+		allInFlight := make(chan struct{})
+		repo := i.LocalRepoHandler(dir)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Contains(r.URL.Path, "test-panel-") {
+				mu.Lock()
+				inFlight++
+				if inFlight > maxInFlight {
+					maxInFlight = inFlight
+				}
+				if inFlight == 3 {
+					close(allInFlight)
+				}
+				mu.Unlock()
+				select {
+				case <-allInFlight:
+				case <-time.After(5 * time.Second):
+				}
+				defer func() {
+					mu.Lock()
+					inFlight--
+					mu.Unlock()
+				}()
+			}
+			repo.ServeHTTP(w, r)
+		}))
+		t.Cleanup(server.Close)
+
+		pluginsDir := t.TempDir()
+		require.NoError(t, i.Install("test-app", "", pluginsDir, "", server.URL+"/index.json"))
+		assert.Equal(t, 3, maxInFlight)
+		for _, pluginID := range []string{"test-panel-a", "test-panel-b", "test-panel-c"} {
+			assert.FileExists(t, filepath.Join(pluginsDir, pluginID, "plugin.json"))
+		}
+	})
+
+	t.Run("Should abort dependency chains deeper than the maximum depth", func(t *testing.T) {
+		pluginJSONs := map[string]string{}
+		for n := 0; n <= maxDependencyDepth; n++ {
+			pluginJSONs[fmt.Sprintf("test-panel-%d-1.0.0.zip", n)] = fmt.Sprintf(`{"id":"test-panel-%d","type":"panel",
+				"name":"Panel","info":{"version":"1.0.0"},"dependencies":{"plugins":[{"id":"test-panel-%d"}]}}`, n, n+1)
+		}
+		dir := writeTestDependencyRepo(t, pluginJSONs)
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+
+		err := i.Install("test-panel-0", "", t.TempDir(), "", dir)
+		var depthErr *DependencyDepthError
+		require.ErrorAs(t, err, &depthErr)
+		assert.Len(t, depthErr.Chain, maxDependencyDepth+1)
+		assert.True(t, strings.HasPrefix(err.Error(), "dependency chain exceeds the maximum depth of 10: "+
+			"test-panel-0 -> test-panel-1"), err.Error())
+
+		_, err = i.ResolveDependencyTree("test-panel-0", "", dir)
+		require.ErrorAs(t, err, &depthErr)
+	})
+
+	t.Run("Should name the dependents of dependencies with missing or invalid metadata", func(t *testing.T) {
+		dir := writeTestDependencyRepo(t, map[string]string{
+			"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-panel","type":"panel"}]}}`,
+			"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-datasource","type":"datasource"}]}}`,
+		})
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+
+		err := i.Install("test-app", "", t.TempDir(), "", dir)
+		var metadataErr *DependencyMetadataError
+		require.ErrorAs(t, err, &metadataErr)
+		assert.Equal(t, []string{"test-app", "test-panel", "test-datasource"}, metadataErr.Chain)
+		assert.Equal(t, "dependency test-datasource required by test-app -> test-panel isn't published in the "+
+			"plugin repository", err.Error())
+
+		indexPath := filepath.Join(dir, "index.json")
+		data, err := ioutil.ReadFile(indexPath)
+		require.NoError(t, err)
+		var index PluginRepo
+		require.NoError(t, json.Unmarshal(data, &index))
+		index.Plugins = append(index.Plugins, Plugin{ID: "test-datasource"})
+		data, err = json.Marshal(index)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(indexPath, data, 0600))
+
+		_, err = i.ResolveDependencyTree("test-app", "", dir)
+		require.ErrorAs(t, err, &metadataErr)
+		assert.True(t, strings.HasPrefix(err.Error(), "invalid metadata of dependency test-datasource required by "+
+			"test-app -> test-panel: invalid plugin repository response"), err.Error())
+	})
+
+	t.Run("Should install dependencies listed more than once a single time", func(t *testing.T) {
+		dir := writeTestDependencyRepo(t, map[string]string{
+			"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-panel","version":"^1.0.0"},{"id":"test-panel","version":"1.1.0"}]}}`,
+			"test-app-2.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"2.0.0"},
+				"dependencies":{"plugins":[{"id":"test-panel","version":"^1.0.0"},{"id":"test-panel","version":"1.0.0"}]}}`,
+			"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`,
+			"test-panel-1.1.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.1.0"}}`,
+		})
+		sink := &fakeAuditSink{}
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, AuditSink: sink}, "8.0.0", &fakeLogger{})
+
+		pluginsDir := t.TempDir()
+		require.NoError(t, i.Install("test-app", "1.0.0", pluginsDir, "", dir))
+		require.Len(t, sink.events, 2)
+		assert.Equal(t, "test-panel", sink.events[0].PluginID)
+		assert.Equal(t, "1.1.0", sink.events[0].Version)
+
+		err := i.Install("test-app", "2.0.0", t.TempDir(), "", dir)
+		var conflictErr *DependencyConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		assert.Equal(t, "1.1.0", conflictErr.Version)
+	})
+}
+
+// writeTestDependencyRepo writes a static repository of the plugin zips, by their file name, with the plugin.json.
+func writeTestDependencyRepo(t *testing.T, pluginJSONs map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, pluginJSON := range pluginJSONs {
+		data, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0600))
+	}
+	i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+	require.NoError(t, i.WriteLocalRepoIndex(dir))
+	return dir
+}
@@ -0,0 +1,32 @@
+package installer
+
+import (
+	"strings"
+)
+
+// DependencyPin pins a dependency plugin to a version, overriding the version or version range required by the
+// plugins depending on it, for environments that only qualified certain versions.
+type DependencyPin struct {
+	Version string
+	// Source replaces the source the plugin is installed from. It's either the name of a source alias or the URL
+	// of the plugin archive. Empty keeps the source.
+	Source string
+}
+
+// pinDependency returns the reference, version and archive URL, empty unless pinned to one, the dependency of the
+// plugin is installed with, applying its pin if any.
+func (i *Installer) pinDependency(dep PluginDependency, dependent string) (string, string, string) {
+	version := strings.TrimSpace(dep.Version)
+	pin, exists := i.opts.DependencyPins[dep.ID]
+	if !exists || i.opts.FromLockfile {
+		return dep.ID, version, ""
+	}
+	if pin.Version != version {
+		requested := DependencyRequirement{RequiredBy: dependent, Requested: version}
+		i.log.Infof("Using %s v%s pinned by the configuration instead of %s", dep.ID, pin.Version, requested)
+	}
+	if _, alias := i.opts.SourceAliases[pin.Source]; alias {
+		return pin.Source + ":" + dep.ID, pin.Version, ""
+	}
+	return dep.ID, pin.Version, pin.Source
+}
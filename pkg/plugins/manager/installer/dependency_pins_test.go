@@ -0,0 +1,56 @@
+package installer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyPins(t *testing.T) {
+	dir := writeTestDependencyRepo(t, map[string]string{
+		"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+			"dependencies":{"plugins":[{"id":"test-panel","type":"panel","version":"^1.0.0"}]}}`,
+		"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`,
+		"test-panel-1.1.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.1.0"}}`,
+	})
+	installPinned := func(t *testing.T, pin DependencyPin, aliases map[string]SourceAlias) string {
+		t.Helper()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, SourceAliases: aliases,
+			DependencyPins: map[string]DependencyPin{"test-panel": pin}}, "8.0.0", &fakeLogger{})
+		pluginsDir := t.TempDir()
+		require.NoError(t, i.Install("test-app", "", pluginsDir, "", dir))
+		installed, err := toPluginDTO(pluginsDir, "test-panel")
+		require.NoError(t, err)
+		return installed.Info.Version
+	}
+
+	t.Run("Should install pinned version instead of required version", func(t *testing.T) {
+		assert.Equal(t, "1.0.0", installPinned(t, DependencyPin{Version: "1.0.0"}, nil))
+	})
+
+	t.Run("Should install pinned version from replaced source", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{
+			"plugin.json": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"2.0.0"}}`})
+		assert.Equal(t, "2.0.0", installPinned(t, DependencyPin{Version: "2.0.0", Source: archive}, nil))
+
+		corpDir := writeTestDependencyRepo(t, map[string]string{
+			"test-panel-3.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"3.0.0"}}`,
+		})
+		assert.Equal(t, "3.0.0", installPinned(t, DependencyPin{Version: "3.0.0", Source: "corp"},
+			map[string]SourceAlias{"corp": {URL: corpDir}}))
+	})
+
+	t.Run("Should list pinned versions in the dependency tree", func(t *testing.T) {
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, DependencyPins: map[string]DependencyPin{
+			"test-panel": {Version: "2.0.0", Source: "https://artifacts.corp/test-panel-2.0.0.zip"}}}, "8.0.0",
+			&fakeLogger{})
+		tree, err := i.ResolveDependencyTree("test-app", "", dir)
+		require.NoError(t, err)
+		require.Len(t, tree.Dependencies, 1)
+		assert.Equal(t, &DependencyNode{ID: "test-panel", Requested: "2.0.0", Version: "2.0.0",
+			Source: "https://artifacts.corp/test-panel-2.0.0.zip"}, tree.Dependencies[0])
+		assert.Equal(t, filepath.Join(dir, "test-app-1.0.0.zip"), tree.Source)
+	})
+}
@@ -0,0 +1,14 @@
+package installer
+
+// provideDependency returns the dependency installed in place of the dependency of the plugin, which is the plugin
+// that Opts.DependencyProviders maps its ID to, if any. Versions of a providing plugin, like a fork or a renamed
+// plugin, are unrelated to the versions of the plugin it replaces, so that the latest version is installed.
+func (i *Installer) provideDependency(dep PluginDependency, dependent string) PluginDependency {
+	provider, exists := i.opts.DependencyProviders[dep.ID]
+	if !exists || provider == dep.ID {
+		return dep
+	}
+	i.log.Infof("Installing %s in place of %s required by %s, which it provides", provider, dep.ID, dependent)
+	dep.ID, dep.Version = provider, ""
+	return dep
+}
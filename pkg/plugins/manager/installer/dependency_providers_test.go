@@ -0,0 +1,40 @@
+package installer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyProviders(t *testing.T) {
+	dir := writeTestDependencyRepo(t, map[string]string{
+		"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+			"dependencies":{"plugins":[{"id":"test-panel","type":"panel","version":"^1.0.0"}]}}`,
+		"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`,
+		"fork-panel-3.0.0.zip": `{"id":"fork-panel","type":"panel","name":"Fork","info":{"version":"3.0.0"}}`,
+	})
+	newInstaller := func() *Installer {
+		return NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore,
+			DependencyProviders: map[string]string{"test-panel": "fork-panel"}}, "8.0.0", &fakeLogger{})
+	}
+
+	t.Run("Should install providing plugin instead of dependency", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		plan, err := newInstaller().PlanInstall("test-app", "", pluginsDir, "", dir)
+		require.NoError(t, err)
+		defer plan.Close()
+		require.Len(t, plan.Plugins, 2)
+		assert.Equal(t, "fork-panel", plan.Plugins[0].ID)
+		assert.Equal(t, "3.0.0", plan.Plugins[0].Version)
+		assert.Equal(t, "test-app", plan.Plugins[0].RequiredBy)
+	})
+
+	t.Run("Should list providing plugin in the dependency tree", func(t *testing.T) {
+		tree, err := newInstaller().ResolveDependencyTree("test-app", "", dir)
+		require.NoError(t, err)
+		require.Len(t, tree.Dependencies, 1)
+		assert.Equal(t, "fork-panel", tree.Dependencies[0].ID)
+		assert.Equal(t, "3.0.0", tree.Dependencies[0].Version)
+	})
+}
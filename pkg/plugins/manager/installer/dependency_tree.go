@@ -0,0 +1,234 @@
+package installer
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// DependencyNode is a plugin in a dependency tree, with the version installing it would resolve to.
+type DependencyNode struct {
+	ID string `json:"id"`
+	// Requested is the version or version range the dependent plugin requires, empty for the latest version.
+	Requested string `json:"requested,omitempty"`
+	Version   string `json:"version"`
+	// Source is the redacted URL of the archive the version is downloaded from.
+	Source string `json:"source"`
+	// Duplicate reports that the plugin is already resolved elsewhere in the tree, where its dependencies are
+	// listed.
+	Duplicate bool `json:"duplicate,omitempty"`
+	// Optional reports that the plugin is an optional dependency, which doesn't fail the install of the dependent
+	// plugin if it can't be installed.
+	Optional bool `json:"optional,omitempty"`
+	// Error is the reason the optional dependency can't be installed, empty if it can.
+	Error        string            `json:"error,omitempty"`
+	Dependencies []*DependencyNode `json:"dependencies,omitempty"`
+}
+
+// ResolveDependencyTree resolves the plugin, referenced as [<source alias>:]<plugin id>[@<version or channel>],
+// and its transitive dependencies the way Install would, without installing anything. Archives are downloaded to
+// a temporary directory to read the dependencies from their plugin.json. Plugins depending on themselves fail
+// with a DependencyCycleError.
+func (i *Installer) ResolveDependencyTree(pluginID, version, pluginRepoURL string) (*DependencyNode, error) {
+	if err := i.checkFIPS(); err != nil {
+		return nil, err
+	}
+	if i.opts.Enterprise {
+		pluginRepoURL = i.enterpriseRepoURL()
+	}
+	return i.resolveDependencyNode(pluginID, version, pluginRepoURL, nil, newDependencyResolution(),
+		map[string]*DependencyNode{})
+}
+
+// resolveDependencyNode resolves the plugin and its dependencies. The chain holds the IDs of the dependent plugins,
+// outermost first, and nodes the plugins resolved so far by ID.
+func (i *Installer) resolveDependencyNode(ref, version, pluginRepoURL string, chain []string,
+	resolution *dependencyResolution, nodes map[string]*DependencyNode) (*DependencyNode, error) {
+	pluginID, pluginRepoURL, err := i.resolveSourceAlias(ref, pluginRepoURL)
+	if err != nil {
+		return nil, err
+	}
+	defaultRepo := !i.opts.Enterprise && pluginID == ref
+	pluginID, version, channel, err := parsePluginRef(pluginID, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDependencyCycle(chain, pluginID); err != nil {
+		return nil, err
+	}
+	if err := checkDependencyDepth(chain, pluginID); err != nil {
+		return nil, err
+	}
+	node := &DependencyNode{ID: pluginID, Requested: version}
+	requirement := DependencyRequirement{RequiredBy: requiredBy(chain), Requested: version}
+	if r, exists := nodes[pluginID]; exists {
+		if _, err := resolution.check(pluginID, requirement); err != nil {
+			return nil, err
+		}
+		node.Version, node.Source, node.Duplicate = r.Version, r.Source, true
+		return node, nil
+	}
+	if err := i.checkPluginAllowed(pluginID); err != nil {
+		return nil, err
+	}
+	channel = i.resolveChannel(channel, version)
+
+	lookup := i.lookupPlugin
+	if len(chain) > 0 {
+		lookup = i.lookupDependency
+	}
+	plugin, repoURLs, err := lookup(pluginID, pluginRepoURL, defaultRepo)
+	if err != nil {
+		return nil, dependencyMetadataError(chain, pluginID, err)
+	}
+	candidates, requestedVersion, err := resolveVersionRange(&plugin, version)
+	if err != nil {
+		return nil, err
+	}
+	v, err := i.selectCompatibleVersion(&candidates, requestedVersion,
+		func(p *Plugin, version string) (*Version, error) {
+			return selectVersion(p, version, channel)
+		})
+	if err != nil {
+		return nil, i.suggestCompatibleVersion(err, &plugin, channel)
+	}
+	var downloadURLs []string
+	for _, repoURL := range repoURLs {
+		downloadURL := pluginDownloadURL(repoURL, pluginID, v)
+		if !containsString(downloadURLs, downloadURL) {
+			downloadURLs = append(downloadURLs, downloadURL)
+		}
+	}
+	node.Version, node.Source = v.Version, RedactURL(downloadURLs[0])
+	nodes[pluginID] = node
+	resolution.record(pluginID, v.Version, requirement)
+
+	deps, err := i.readDependencies(pluginID, v, repoURLs[0], downloadURLs)
+	if err != nil {
+		return nil, err
+	}
+	dependents := append(append(make([]string, 0, len(chain)+1), chain...), pluginID)
+	for _, dep := range sortedDependencies(deps) {
+		dep = i.provideDependency(dep, pluginID)
+		ref, version, pluginZipURL := i.pinDependency(dep, pluginID)
+		if pluginZipURL != "" {
+			// The dependencies of plugins pinned to an archive aren't resolved, as they aren't in the repository
+			node.Dependencies = append(node.Dependencies, &DependencyNode{ID: dep.ID, Requested: version,
+				Version: version, Source: RedactURL(pluginZipURL), Optional: dep.Optional})
+			continue
+		}
+		child, err := i.resolveDependencyNode(ref, version, pluginRepoURL, dependents, resolution, nodes)
+		if err != nil && dep.Optional {
+			child = &DependencyNode{ID: dep.ID, Requested: strings.TrimSpace(dep.Version), Error: err.Error()}
+		} else if err != nil && namesDependencyChain(err) {
+			return nil, err
+		} else if err != nil {
+			return nil, errutil.Wrapf(err, "failed to resolve dependency '%s' of %s", dep.ID, pluginID)
+		}
+		child.Optional = dep.Optional
+		node.Dependencies = append(node.Dependencies, child)
+	}
+	return node, nil
+}
+
+// readDependencies downloads the archive of the plugin version to a temporary directory and returns the plugin
+// dependencies listed by its plugin.json.
+func (i *Installer) readDependencies(pluginID string, v *Version, repoURL string,
+	downloadURLs []string) ([]PluginDependency, error) {
+	_, archMeta := platformArch(v, osAndArchString())
+	checksum := archMeta.SHA256
+	if checksum == "" && archMeta.SHA512 != "" {
+		checksum = "sha512:" + archMeta.SHA512
+	}
+	if _, generic := repo.GenericBase(repoURL); generic && checksum == "" {
+		var err error
+		if checksum, err = i.genericRepoChecksum(downloadURLs[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	baseDir, err := i.tempDir("")
+	if err != nil {
+		return nil, err
+	}
+	tmpDir, err := ioutil.TempDir(baseDir, "plugin-dependencies")
+	if err != nil {
+		return nil, errutil.Wrap("failed to create temporary directory", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			i.log.Warn("Failed to remove temporary directory", "dir", tmpDir, "err", err)
+		}
+	}()
+	i.log.Debugf("Reading dependencies of %s v%s", pluginID, v.Version)
+	archivePath := filepath.Join(tmpDir, "plugin.zip")
+	if err := i.downloadArchive(pluginID, archivePath, downloadURLs, checksum); err != nil {
+		return nil, errutil.Wrap("failed to download plugin archive", err)
+	}
+	pluginJSON, err := i.readArchivePluginJSON(archivePath, pluginID)
+	if err != nil {
+		return nil, errutil.Wrap("failed to read plugin.json of plugin archive", err)
+	}
+	if pluginJSON == nil {
+		return nil, nil
+	}
+	return pluginJSON.Dependencies.Plugins, nil
+}
+
+// RenderDependencyTree writes the dependency tree as text, one plugin per line, indented below its dependent.
+func RenderDependencyTree(w io.Writer, root *DependencyNode) error {
+	if _, err := fmt.Fprintln(w, formatDependencyNode(root)); err != nil {
+		return err
+	}
+	return renderDependencies(w, root.Dependencies, "")
+}
+
+func renderDependencies(w io.Writer, nodes []*DependencyNode, indent string) error {
+	for idx, node := range nodes {
+		branch, childIndent := "├── ", indent+"│   "
+		if idx == len(nodes)-1 {
+			branch, childIndent = "└── ", indent+"    "
+		}
+		if _, err := fmt.Fprintln(w, indent+branch+formatDependencyNode(node)); err != nil {
+			return err
+		}
+		if err := renderDependencies(w, node.Dependencies, childIndent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatDependencyNode(node *DependencyNode) string {
+	line := node.ID
+	if node.Version != "" {
+		line += " v" + node.Version
+	}
+	var details []string
+	if node.Requested != "" && node.Requested != node.Version {
+		details = append(details, "requested "+node.Requested)
+	}
+	if node.Optional {
+		details = append(details, "optional")
+	}
+	if node.Duplicate {
+		details = append(details, "see above")
+	}
+	if len(details) > 0 {
+		line += fmt.Sprintf(" (%s)", strings.Join(details, ", "))
+	}
+	switch {
+	case node.Error != "":
+		return line + " can't be installed: " + node.Error
+	case node.Duplicate:
+		return line
+	default:
+		return line + " from " + node.Source
+	}
+}
@@ -0,0 +1,127 @@
+package installer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyTree(t *testing.T) {
+	dir := writeTestDependencyRepo(t, map[string]string{
+		"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+			"dependencies":{"plugins":[{"id":"test-panel","type":"panel","version":"^1.0.0"},
+			{"id":"test-datasource","type":"datasource"}]}}`,
+		"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`,
+		"test-panel-1.1.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.1.0"},
+			"dependencies":{"plugins":[{"id":"test-datasource","type":"datasource","version":">=2.0.0"}]}}`,
+		"test-panel-2.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"2.0.0"}}`,
+		"test-datasource-2.1.0.zip": `{"id":"test-datasource","type":"datasource","name":"Datasource",
+			"info":{"version":"2.1.0"}}`,
+	})
+	newInstaller := func() *Installer {
+		return NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+	}
+
+	t.Run("Should resolve transitive dependencies without installing them", func(t *testing.T) {
+		tree, err := newInstaller().ResolveDependencyTree("test-app", "", dir)
+		require.NoError(t, err)
+
+		// Dependencies are resolved by ID, whatever their order in the plugin.json
+		assert.Equal(t, &DependencyNode{ID: "test-app", Version: "1.0.0",
+			Source: filepath.Join(dir, "test-app-1.0.0.zip"), Dependencies: []*DependencyNode{
+				{ID: "test-datasource", Version: "2.1.0", Source: filepath.Join(dir, "test-datasource-2.1.0.zip")},
+				{ID: "test-panel", Requested: "^1.0.0", Version: "1.1.0",
+					Source: filepath.Join(dir, "test-panel-1.1.0.zip"), Dependencies: []*DependencyNode{
+						{ID: "test-datasource", Requested: ">=2.0.0", Version: "2.1.0",
+							Source: filepath.Join(dir, "test-datasource-2.1.0.zip"), Duplicate: true},
+					}},
+			}}, tree)
+		entries, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 6)
+	})
+
+	t.Run("Should render dependency tree", func(t *testing.T) {
+		tree := &DependencyNode{ID: "test-app", Version: "1.0.0", Source: "https://repo/test-app-1.0.0.zip",
+			Dependencies: []*DependencyNode{
+				{ID: "test-panel", Requested: "^1.0.0", Version: "1.1.0", Source: "https://repo/test-panel-1.1.0.zip",
+					Dependencies: []*DependencyNode{
+						{ID: "test-datasource", Version: "2.1.0", Source: "https://repo/test-datasource-2.1.0.zip"},
+					}},
+				{ID: "test-datasource", Requested: "2.1.0", Version: "2.1.0", Duplicate: true},
+			}}
+
+		var buf bytes.Buffer
+		require.NoError(t, RenderDependencyTree(&buf, tree))
+		assert.Equal(t, `test-app v1.0.0 from https://repo/test-app-1.0.0.zip
+├── test-panel v1.1.0 (requested ^1.0.0) from https://repo/test-panel-1.1.0.zip
+│   └── test-datasource v2.1.0 from https://repo/test-datasource-2.1.0.zip
+└── test-datasource v2.1.0 (see above)
+`, buf.String())
+	})
+
+	t.Run("Should fail for dependency cycles", func(t *testing.T) {
+		cycleDir := writeTestDependencyRepo(t, map[string]string{
+			"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-app","type":"app"}]}}`,
+		})
+
+		_, err := newInstaller().ResolveDependencyTree("test-app", "", cycleDir)
+		var cycleErr *DependencyCycleError
+		require.ErrorAs(t, err, &cycleErr)
+		assert.Equal(t, []string{"test-app", "test-app"}, cycleErr.Cycle)
+	})
+}
+
+func TestOptionalDependency(t *testing.T) {
+	dir := writeTestDependencyRepo(t, map[string]string{
+		"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+			"dependencies":{"plugins":[{"id":"test-panel","type":"panel","optional":true},
+			{"id":"test-datasource","type":"datasource","version":"^1.0.0","optional":true}]}}`,
+		"test-datasource-1.0.0.zip": `{"id":"test-datasource","type":"datasource","name":"Datasource",
+			"info":{"version":"1.0.0"}}`,
+	})
+
+	t.Run("Should install plugin if optional dependency fails", func(t *testing.T) {
+		sink := &fakeAuditSink{}
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, AuditSink: sink}, "8.0.0", &fakeLogger{})
+		pluginsDir := t.TempDir()
+		require.NoError(t, i.Install("test-app", "", pluginsDir, "", dir))
+
+		assert.DirExists(t, filepath.Join(pluginsDir, "test-app"))
+		assert.DirExists(t, filepath.Join(pluginsDir, "test-datasource"))
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+		require.Len(t, sink.events, 3)
+		assert.Equal(t, "test-panel", sink.events[0].PluginID)
+		assert.False(t, sink.events[0].Success)
+		assert.Contains(t, sink.events[0].Error, "test-panel")
+	})
+
+	t.Run("Should skip dependencies", func(t *testing.T) {
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, SkipDependencies: true}, "8.0.0", &fakeLogger{})
+		pluginsDir := t.TempDir()
+		require.NoError(t, i.Install("test-app", "", pluginsDir, "", dir))
+
+		assert.DirExists(t, filepath.Join(pluginsDir, "test-app"))
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-datasource"))
+	})
+
+	t.Run("Should list optional dependencies that can't be installed in the tree", func(t *testing.T) {
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+		tree, err := i.ResolveDependencyTree("test-app", "", dir)
+		require.NoError(t, err)
+		require.Len(t, tree.Dependencies, 2)
+		assert.Equal(t, "1.0.0", tree.Dependencies[0].Version)
+		assert.True(t, tree.Dependencies[1].Optional)
+		assert.NotEmpty(t, tree.Dependencies[1].Error)
+
+		var buf bytes.Buffer
+		require.NoError(t, RenderDependencyTree(&buf, tree))
+		assert.Contains(t, buf.String(), "├── test-datasource v1.0.0 (requested ^1.0.0, optional) from ")
+		assert.Contains(t, buf.String(), "└── test-panel (optional) can't be installed: ")
+	})
+}
@@ -0,0 +1,72 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+// DependentsError is returned when uninstalling a plugin that installed plugins depend on, which would break them.
+type DependentsError struct {
+	PluginID string
+	// Dependents are the IDs of the installed plugins requiring the plugin.
+	Dependents []string
+}
+
+func (e *DependentsError) Error() string {
+	return fmt.Sprintf("can't uninstall %s, it's required by %s", e.PluginID, strings.Join(e.Dependents, ", "))
+}
+
+// InstalledDependents returns the sorted IDs of the plugins in the plugins directory that require the plugin.
+// Plugins depending on it optionally aren't returned, as they keep working without it.
+func InstalledDependents(pluginsDir, pluginID string) ([]string, error) {
+	return installedDependents(pluginsDir, pluginID, false)
+}
+
+// installedDependents returns the sorted IDs of the plugins in the plugins directory that depend on the plugin,
+// including the ones depending on it optionally if optional is set.
+func installedDependents(pluginsDir, pluginID string, optional bool) ([]string, error) {
+	entries, err := ioutil.ReadDir(pluginsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var dependents []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || name == pluginID || name == plugins.QuarantineDirName || strings.HasPrefix(name, ".") {
+			continue
+		}
+		installed, err := toPluginDTO(pluginsDir, name)
+		if err != nil {
+			continue
+		}
+		for _, dep := range installed.Dependencies.Plugins {
+			if dep.ID == pluginID && (optional || !dep.Optional) {
+				dependents = append(dependents, installed.ID)
+				break
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents, nil
+}
+
+// CheckDependents fails with a DependentsError if installed plugins in the plugins directory require the plugin.
+func CheckDependents(pluginsDir, pluginID string) error {
+	dependents, err := InstalledDependents(pluginsDir, pluginID)
+	if err != nil {
+		return err
+	}
+	if len(dependents) > 0 {
+		return &DependentsError{PluginID: pluginID, Dependents: dependents}
+	}
+	return nil
+}
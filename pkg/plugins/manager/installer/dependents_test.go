@@ -0,0 +1,49 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstalledDependents(t *testing.T) {
+	pluginsDir := t.TempDir()
+	for dir, pluginJSON := range map[string]string{
+		"test-panel": `{"id":"test-panel","type":"panel","info":{"version":"1.0.0"}}`,
+		"test-app": `{"id":"test-app","type":"app","info":{"version":"1.0.0"},
+			"dependencies":{"plugins":[{"id":"test-panel","type":"panel"}]}}`,
+		"other-app/dist": `{"id":"other-app","type":"app","info":{"version":"1.0.0"},
+			"dependencies":{"plugins":[{"id":"test-panel","type":"panel"}]}}`,
+		"optional-app": `{"id":"optional-app","type":"app","info":{"version":"1.0.0"},
+			"dependencies":{"plugins":[{"id":"test-panel","type":"panel","optional":true}]}}`,
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(pluginsDir, dir), 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pluginsDir, dir, "plugin.json"), []byte(pluginJSON), 0600))
+	}
+
+	t.Run("Should list plugins requiring the plugin", func(t *testing.T) {
+		dependents, err := InstalledDependents(pluginsDir, "test-panel")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"other-app", "test-app"}, dependents)
+
+		dependents, err = InstalledDependents(pluginsDir, "test-app")
+		require.NoError(t, err)
+		assert.Empty(t, dependents)
+	})
+
+	t.Run("Should refuse to uninstall plugins required by installed plugins unless forced", func(t *testing.T) {
+		err := New(false, "8.0.0", &fakeLogger{}).Uninstall("test-panel", pluginsDir)
+		var dependentsErr *DependentsError
+		require.ErrorAs(t, err, &dependentsErr)
+		assert.Equal(t, "can't uninstall test-panel, it's required by other-app, test-app", err.Error())
+		assert.DirExists(t, filepath.Join(pluginsDir, "test-panel"))
+
+		i := NewWithOpts(Opts{ForceUninstall: true}, "8.0.0", &fakeLogger{})
+		require.NoError(t, i.Uninstall("test-panel", pluginsDir))
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+	})
+}
@@ -0,0 +1,87 @@
+package installer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// signatureFileSuffix is appended to the archive URL to find its detached signature.
+const signatureFileSuffix = ".asc"
+
+// verifyDetachedSignature verifies the downloaded archive against its detached OpenPGP signature when a signature
+// keyring is configured. The signature is read from signatureURL, or from <archiveURL>.asc if it's empty. Both
+// armored and binary signatures are accepted.
+func (i *Installer) verifyDetachedSignature(archivePath, archiveURL, signatureURL string) error {
+	if i.opts.SignatureKeyringPath == "" {
+		return nil
+	}
+	if signatureURL == "" {
+		signatureURL = archiveURL + signatureFileSuffix
+	}
+
+	keyring, err := readKeyring(i.opts.SignatureKeyringPath)
+	if err != nil {
+		return errutil.Wrap("failed to read signature keyring", err)
+	}
+
+	i.log.Debugf("Fetching archive signature from %s", RedactURL(signatureURL))
+	signature, err := i.readCompanionFile(signatureURL)
+	if err != nil {
+		return errutil.Wrapf(err, "failed to fetch archive signature %s", RedactURL(signatureURL))
+	}
+	if block, err := armor.Decode(bytes.NewReader(signature)); err == nil {
+		if signature, err = ioutil.ReadAll(block.Body); err != nil {
+			return errutil.Wrap("failed to decode archive signature", err)
+		}
+	}
+
+	if err := i.checkFIPSSignature(signature); err != nil {
+		return errutil.Wrap("invalid archive signature", err)
+	}
+
+	// nolint:gosec
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := archive.Close(); err != nil {
+			i.log.Warn("Failed to close file", "err", err)
+		}
+	}()
+
+	signer, err := openpgp.CheckDetachedSignature(keyring, archive, bytes.NewReader(signature))
+	if err != nil {
+		return errutil.Wrap("archive signature verification failed", err)
+	}
+	name := fmt.Sprintf("key %X", signer.PrimaryKey.KeyId)
+	for identity := range signer.Identities {
+		name = identity
+		break
+	}
+	i.log.Infof("Verified archive signature by %s", name)
+	return nil
+}
+
+// readKeyring reads an armored or binary OpenPGP keyring.
+func readKeyring(path string) (openpgp.EntityList, error) {
+	// nolint:gosec
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data)); err == nil {
+		return keyring, nil
+	}
+	keyring, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("no OpenPGP keys found in %s: %w", path, err)
+	}
+	return keyring, nil
+}
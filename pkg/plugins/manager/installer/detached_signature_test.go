@@ -0,0 +1,70 @@
+package installer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestVerifyDetachedSignature(t *testing.T) {
+	i, entity := newManifestTestInstaller(t)
+	i.opts.SignatureKeyringPath = i.opts.ManifestKeyringPath
+
+	sign := func(t *testing.T, archive string, armored bool) {
+		t.Helper()
+		data, err := ioutil.ReadFile(archive)
+		require.NoError(t, err)
+		sig := new(bytes.Buffer)
+		if armored {
+			require.NoError(t, openpgp.ArmoredDetachSign(sig, entity, bytes.NewReader(data), nil))
+		} else {
+			require.NoError(t, openpgp.DetachSign(sig, entity, bytes.NewReader(data), nil))
+		}
+		require.NoError(t, ioutil.WriteFile(archive+signatureFileSuffix, sig.Bytes(), 0600))
+	}
+
+	t.Run("Should verify armored and binary signatures", func(t *testing.T) {
+		for _, armored := range []bool{true, false} {
+			archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+			sign(t, archive, armored)
+
+			require.NoError(t, i.verifyDetachedSignature(archive, archive, ""))
+		}
+	})
+
+	t.Run("Should fail for modified archive", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+		sign(t, archive, true)
+		f, err := os.OpenFile(archive, os.O_APPEND|os.O_WRONLY, 0600)
+		require.NoError(t, err)
+		_, err = f.Write([]byte("tampered"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		require.Error(t, i.verifyDetachedSignature(archive, archive, ""))
+	})
+
+	t.Run("Should fail without signature", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+
+		require.Error(t, i.verifyDetachedSignature(archive, archive, ""))
+	})
+
+	t.Run("Should use explicit signature URL", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+		sign(t, archive, true)
+
+		require.NoError(t, i.verifyDetachedSignature(archive, "https://example.com/plugin.tar.gz",
+			archive+signatureFileSuffix))
+	})
+
+	t.Run("Should skip verification without keyring", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+
+		require.NoError(t, (&Installer{log: &fakeLogger{}}).verifyDetachedSignature(archive, archive, ""))
+	})
+}
@@ -0,0 +1,92 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// InsufficientDiskSpaceError is returned when the filesystem a plugin archive is downloaded or extracted to doesn't
+// have enough free space, before anything is written to it.
+type InsufficientDiskSpaceError struct {
+	// Path is the directory on the filesystem that is short of space.
+	Path string
+	// Operation is what the space is needed for, e.g. "download the plugin archive".
+	Operation string
+	// Required and Available are the bytes needed, including the configured reserve, and the bytes available.
+	Required  uint64
+	Available uint64
+}
+
+func (e *InsufficientDiskSpaceError) Error() string {
+	return fmt.Sprintf("not enough disk space in %q to %s: %s required, %s available", e.Path, e.Operation,
+		formatBytes(e.Required), formatBytes(e.Available))
+}
+
+// checkDiskSpace fails if the filesystem of dir doesn't have size bytes available, in addition to the configured
+// reserve. The check is skipped if the free space of the filesystem can't be determined.
+func (i *Installer) checkDiskSpace(dir string, size int64, operation string) error {
+	reserve := i.opts.MinFreeDiskSpace
+	if reserve < 0 {
+		return nil
+	}
+	if size < 0 {
+		size = 0
+	}
+	available, err := freeDiskSpace(existingAncestor(dir))
+	if err != nil {
+		i.log.Debugf("Failed to determine the free disk space in %q: %v", dir, err)
+		return nil
+	}
+	required := uint64(size) + uint64(reserve)
+	if available < required {
+		return &InsufficientDiskSpaceError{Path: dir, Operation: operation, Required: required, Available: available}
+	}
+	return nil
+}
+
+// existingAncestor returns the directory, or its closest ancestor that exists, e.g. for plugins directories that
+// are only created when the first plugin is installed.
+func existingAncestor(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// extractedSize returns the size of the extracted members of the archive if it's known up front, and the size of
+// the archive file otherwise, which is a lower bound of the space its members take.
+func extractedSize(r pluginArchive, archivePath string) (int64, error) {
+	if za, ok := r.(*zipArchive); ok {
+		var size int64
+		for _, zf := range za.r.File {
+			size += int64(zf.UncompressedSize64)
+		}
+		return size, nil
+	}
+	fi, err := os.Stat(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// formatBytes formats the number of bytes in binary units, e.g. 1.5 MiB.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,10 @@
+// +build !linux,!darwin,!freebsd,!windows
+
+package installer
+
+import "errors"
+
+// freeDiskSpace isn't supported on this platform, so disk space isn't checked.
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errors.New("determining free disk space is not supported on this platform")
+}
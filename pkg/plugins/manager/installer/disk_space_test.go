@@ -0,0 +1,92 @@
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskSpaceCheck(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+	// Requiring more space than any filesystem has fails the check deterministically
+	const unavailable = 1 << 60
+
+	t.Run("Should fail downloads that don't fit on the filesystem of the temporary file", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("plugin archive"))
+		}))
+		t.Cleanup(server.Close)
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tmpFile.Close()) }()
+		i := NewWithOpts(Opts{MinFreeDiskSpace: unavailable}, "8.0.0", &fakeLogger{})
+
+		err = i.DownloadFile("test-panel", tmpFile, server.URL, "")
+		var spaceErr *InsufficientDiskSpaceError
+		require.ErrorAs(t, err, &spaceErr)
+		assert.Equal(t, "download the plugin archive", spaceErr.Operation)
+		assert.Equal(t, filepath.Dir(tmpFile.Name()), spaceErr.Path)
+		assert.Equal(t, uint64(unavailable+len("plugin archive")), spaceErr.Required)
+		assert.Contains(t, err.Error(), "not enough disk space in")
+		fi, err := tmpFile.Stat()
+		require.NoError(t, err)
+		assert.Zero(t, fi.Size())
+	})
+
+	t.Run("Should fail before extracting plugins that don't fit on the filesystem", func(t *testing.T) {
+		archivePath := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+		pluginsDir := t.TempDir()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, MinFreeDiskSpace: unavailable}, "8.0.0",
+			&fakeLogger{})
+
+		_, err := i.PlanInstall("test-panel", "", pluginsDir, archivePath, "")
+		var spaceErr *InsufficientDiskSpaceError
+		require.ErrorAs(t, err, &spaceErr)
+		assert.Equal(t, "extract the plugin", spaceErr.Operation)
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+	})
+
+	t.Run("Should install plugins if the check is disabled", func(t *testing.T) {
+		archivePath := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, MinFreeDiskSpace: -1}, "8.0.0", &fakeLogger{})
+
+		plan, err := i.PlanInstall("test-panel", "", t.TempDir(), archivePath, "")
+		require.NoError(t, err)
+		plan.Close()
+	})
+
+	t.Run("Should use the uncompressed size of zip archives", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		for name, content := range map[string]string{"plugin.json": pluginJSON, "module.js": "console.log(1)"} {
+			w, err := zw.Create(name)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+		archivePath := filepath.Join(t.TempDir(), "plugin.zip")
+		require.NoError(t, ioutil.WriteFile(archivePath, buf.Bytes(), 0600))
+		i := &Installer{log: &fakeLogger{}}
+		r, err := i.openArchive(archivePath, "test-panel")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, r.Close()) }()
+
+		size, err := extractedSize(r, archivePath)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(pluginJSON)+len("console.log(1)")), size)
+	})
+
+	t.Run("Should format sizes in binary units", func(t *testing.T) {
+		assert.Equal(t, "512 B", formatBytes(512))
+		assert.Equal(t, "1.5 KiB", formatBytes(1536))
+		assert.Equal(t, "2.0 GiB", formatBytes(2<<30))
+	})
+}
@@ -0,0 +1,15 @@
+// +build linux darwin freebsd
+
+package installer
+
+import "syscall"
+
+// freeDiskSpace returns the bytes available to unprivileged users on the filesystem of dir.
+func freeDiskSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	// The field types differ between platforms
+	return uint64(st.Bavail) * uint64(st.Bsize), nil // nolint:unconvert
+}
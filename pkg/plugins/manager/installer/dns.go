@@ -0,0 +1,276 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// defaultDNSPort is the port of DNS servers configured without one.
+const defaultDNSPort = "53"
+
+// IPFamily restricts or orders the IP addresses connections to plugin repositories and download hosts are made to.
+type IPFamily string
+
+const (
+	// IPFamilyAny connects to IPv4 and IPv6 addresses, racing them as in RFC 6555 ("Happy Eyeballs").
+	IPFamilyAny IPFamily = ""
+	// IPFamilyIPv4 only connects to IPv4 addresses.
+	IPFamilyIPv4 IPFamily = "ipv4"
+	// IPFamilyIPv6 only connects to IPv6 addresses.
+	IPFamilyIPv6 IPFamily = "ipv6"
+	// IPFamilyPreferIPv4 connects to the IPv4 addresses of a host first, and to its IPv6 addresses as well if that
+	// fails or takes longer than the fallback delay.
+	IPFamilyPreferIPv4 IPFamily = "prefer-ipv4"
+	// IPFamilyPreferIPv6 connects to the IPv6 addresses of a host first, and to its IPv4 addresses as well if that
+	// fails or takes longer than the fallback delay.
+	IPFamilyPreferIPv6 IPFamily = "prefer-ipv6"
+)
+
+// ParseIPFamily returns the IP family with the provided name. An empty name returns IPFamilyAny.
+func ParseIPFamily(name string) (IPFamily, error) {
+	switch f := IPFamily(strings.ToLower(name)); f {
+	case IPFamilyAny, IPFamilyIPv4, IPFamilyIPv6, IPFamilyPreferIPv4, IPFamilyPreferIPv6:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown IP family %q, valid families are ipv4, ipv6, prefer-ipv4 and prefer-ipv6", name)
+}
+
+// DNSOpts overrides how the host names of plugin repositories, mirrors and download hosts are resolved, e.g. in
+// split-DNS environments where grafana.com has to resolve to the VIP of an internal mirror. TLS certificates are
+// still verified against the host name of the request. Requests sent through a proxy only resolve the proxy's host
+// name, the proxy resolves the rest.
+type DNSOpts struct {
+	// HostOverrides maps host names to the IP address or host name connections to them are made to instead, like
+	// entries of /etc/hosts. See ParseHostOverrides.
+	HostOverrides map[string]string
+	// Server is the address of the DNS server host names are resolved with instead of the system resolver, e.g.
+	// 10.0.0.53 or 10.0.0.53:5353. The port defaults to 53. Windows builds of Go before 1.19 ignore it and always use
+	// the system resolver.
+	Server string
+	// IPFamily forces or prefers IPv4 or IPv6 connections, e.g. to avoid stalls on hosts with broken IPv6.
+	IPFamily IPFamily
+}
+
+// ParseHostOverrides parses host=address pairs into host overrides, where the address is an IP address or another
+// host name, e.g. grafana.com=10.0.0.10.
+func ParseHostOverrides(pairs []string) (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, pair := range pairs {
+		idx := strings.Index(pair, "=")
+		if idx <= 0 || strings.TrimSpace(pair[idx+1:]) == "" {
+			return nil, fmt.Errorf("invalid host override %q, expected host=address", pair)
+		}
+		host, address := normalizeHost(pair[:idx]), strings.Trim(strings.TrimSpace(pair[idx+1:]), "[]")
+		if strings.Contains(address, "/") || (strings.Contains(address, ":") && net.ParseIP(address) == nil) {
+			return nil, fmt.Errorf("invalid address %q of host override %s, expected an IP address or host name",
+				address, host)
+		}
+		overrides[host] = address
+	}
+	return overrides, nil
+}
+
+// normalizeHost lowercases the host name and removes the trailing dot of fully qualified names.
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// dialContext returns the function connections are dialed with, which connects to the overridden address of hosts
+// and resolves host names with the configured DNS server.
+func (o DNSOpts) dialContext(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if o.Server != "" {
+		server := o.Server
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), defaultDNSPort)
+		}
+		d.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var serverDialer net.Dialer
+				return serverDialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	dial := d.DialContext
+	switch o.IPFamily {
+	case IPFamilyIPv4, IPFamilyIPv6:
+		suffix := "4"
+		if o.IPFamily == IPFamilyIPv6 {
+			suffix = "6"
+		}
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if network == "tcp" || network == "udp" {
+				network += suffix
+			}
+			return d.DialContext(ctx, network, addr)
+		}
+	case IPFamilyPreferIPv4, IPFamilyPreferIPv6:
+		dial = dialPreferring(d, o.IPFamily == IPFamilyPreferIPv6)
+	}
+	if len(o.HostOverrides) == 0 {
+		return dial
+	}
+	overrides := make(map[string]string, len(o.HostOverrides))
+	for host, address := range o.HostOverrides {
+		overrides[normalizeHost(host)] = address
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if address, ok := overrides[normalizeHost(host)]; ok {
+				addr = net.JoinHostPort(address, port)
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+const (
+	// defaultFallbackDelay is how long connections to the addresses of the preferred IP family are given before the
+	// other family is dialed as well, like the default of net.Dialer.
+	defaultFallbackDelay = 300 * time.Millisecond
+	// minDialTimeout is the minimum time a connection to one of several addresses of a host is given, like net.Dialer
+	// does when sharing the timeout among them.
+	minDialTimeout = 2 * time.Second
+)
+
+// dialFunc dials a connection to the address on the named network.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialPreferring returns a dial function connecting to the addresses of the preferred IP family of a host first.
+// Like Happy Eyeballs (RFC 6555), the addresses of the other family are dialed in parallel once the fallback delay
+// of the dialer passed without a connection, or the preferred family failed, so that a blackholed family doesn't
+// stall every connection for the connect timeout.
+func dialPreferring(d *net.Dialer, preferIPv6 bool) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return d.DialContext(ctx, network, addr)
+		}
+		resolver := d.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err == nil && len(addrs) == 0 {
+			err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		if err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+		if d.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+			defer cancel()
+		}
+		delay := d.FallbackDelay
+		if delay <= 0 {
+			delay = defaultFallbackDelay
+		}
+		preferred, fallbacks := splitFamily(addrs, preferIPv6)
+		return dialFallback(ctx, d.DialContext, network, port, preferred, fallbacks, delay)
+	}
+}
+
+// dialFallback dials the preferred addresses, and the fallback addresses in parallel once the delay passed or the
+// preferred addresses failed. It returns the first connection established, closing any other.
+func dialFallback(ctx context.Context, dial dialFunc, network, port string, preferred, fallbacks []net.IPAddr,
+	delay time.Duration) (net.Conn, error) {
+	if len(preferred) == 0 || len(fallbacks) == 0 {
+		return dialSerial(ctx, dial, network, port, append(preferred, fallbacks...))
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, 2)
+	start := func(addrs []net.IPAddr) {
+		go func() {
+			conn, err := dialSerial(ctx, dial, network, port, addrs)
+			results <- result{conn: conn, err: err}
+		}()
+	}
+	start(preferred)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending, fallbackStarted := 1, false
+	var firstErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				start(fallbacks)
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if pending > 0 {
+					// The other dial is canceled, close its connection if it was established anyway
+					go func() {
+						if late := <-results; late.conn != nil {
+							_ = late.conn.Close()
+						}
+					}()
+				}
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				start(fallbacks)
+			}
+		}
+	}
+	return nil, firstErr
+}
+
+// dialSerial dials the addresses one after another until a connection is established. If the context has a
+// deadline, each address is given an equal share of the remaining time, but at least minDialTimeout.
+func dialSerial(ctx context.Context, dial dialFunc, network, port string, addrs []net.IPAddr) (net.Conn, error) {
+	var firstErr error
+	for idx, ip := range addrs {
+		dialCtx, cancel := ctx, context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout := time.Until(deadline) / time.Duration(len(addrs)-idx)
+			if timeout < minDialTimeout {
+				timeout = minDialTimeout
+			}
+			dialCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		conn, err := dial(dialCtx, network, net.JoinHostPort(ip.String(), port))
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// splitFamily splits the addresses into those of the preferred IP family and the others, keeping the order of the
+// resolver.
+func splitFamily(addrs []net.IPAddr, preferIPv6 bool) (preferred, others []net.IPAddr) {
+	for _, ip := range addrs {
+		if (ip.IP.To4() == nil) == preferIPv6 {
+			preferred = append(preferred, ip)
+		} else {
+			others = append(others, ip)
+		}
+	}
+	return preferred, others
+}
@@ -0,0 +1,206 @@
+package installer
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNS(t *testing.T) {
+	archive := []byte("plugin archive")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	})
+	download := func(t *testing.T, opts Opts, url string) {
+		t.Helper()
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tmpFile.Close()) }()
+		require.NoError(t, NewWithOpts(opts, "8.0.0", &fakeLogger{}).DownloadFile("test-panel", tmpFile, url, ""))
+		data, err := ioutil.ReadFile(tmpFile.Name())
+		require.NoError(t, err)
+		assert.Equal(t, archive, data)
+	}
+
+	t.Run("Should parse host overrides", func(t *testing.T) {
+		overrides, err := ParseHostOverrides([]string{"Grafana.com.=10.0.0.10", "storage.googleapis.com=[::1]",
+			"cdn.corp = mirror.corp"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"grafana.com": "10.0.0.10", "storage.googleapis.com": "::1",
+			"cdn.corp": "mirror.corp"}, overrides)
+
+		for _, pair := range []string{"grafana.com", "=10.0.0.10", "grafana.com=", "grafana.com=10.0.0.10:443",
+			"grafana.com=http://mirror.corp"} {
+			_, err := ParseHostOverrides([]string{pair})
+			assert.Error(t, err, pair)
+		}
+	})
+
+	t.Run("Should connect to the overridden address of hosts", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+		download(t, Opts{DNS: DNSOpts{HostOverrides: map[string]string{"plugins.split-dns.test": "127.0.0.1"}}},
+			"http://plugins.split-dns.test:"+port+"/plugin.zip")
+	})
+
+	t.Run("Should verify certificates against the host name of overridden hosts", func(t *testing.T) {
+		server := httptest.NewTLSServer(handler)
+		t.Cleanup(server.Close)
+		port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+		caCertPath := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, ioutil.WriteFile(caCertPath,
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+		// The certificate of the test server is valid for example.com
+		download(t, Opts{CACertPath: caCertPath, DNS: DNSOpts{HostOverrides: map[string]string{
+			"example.com": "127.0.0.1"}}}, "https://example.com:"+port+"/plugin.zip")
+
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tmpFile.Close()) }()
+		i := NewWithOpts(Opts{CACertPath: caCertPath, DNS: DNSOpts{HostOverrides: map[string]string{
+			"plugins.split-dns.test": "127.0.0.1"}}}, "8.0.0", &fakeLogger{})
+		err = i.DownloadFile("test-panel", tmpFile, "https://plugins.split-dns.test:"+port+"/plugin.zip", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "certificate")
+	})
+
+	t.Run("Should resolve host names with the configured DNS server", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Windows builds of Go before 1.19 always use the system resolver")
+		}
+		dnsServer, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = dnsServer.Close() })
+		queried := make(chan struct{}, 1)
+		go func() {
+			buf := make([]byte, 512)
+			if _, _, err := dnsServer.ReadFrom(buf); err == nil {
+				queried <- struct{}{}
+			}
+		}()
+
+		dial := DNSOpts{Server: dnsServer.LocalAddr().String()}.dialContext(&net.Dialer{})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		// The DNS server never answers, so that the lookup fails once it timed out
+		_, err = dial(ctx, "tcp", "plugins.split-dns.test:443")
+		require.Error(t, err)
+		select {
+		case <-queried:
+		case <-time.After(time.Second):
+			t.Fatal("the configured DNS server wasn't queried")
+		}
+	})
+
+	t.Run("Should parse IP families", func(t *testing.T) {
+		for name, family := range map[string]IPFamily{"": IPFamilyAny, "IPv4": IPFamilyIPv4, "ipv6": IPFamilyIPv6,
+			"prefer-ipv4": IPFamilyPreferIPv4, "prefer-ipv6": IPFamilyPreferIPv6} {
+			parsed, err := ParseIPFamily(name)
+			require.NoError(t, err)
+			assert.Equal(t, family, parsed)
+		}
+		_, err := ParseIPFamily("ipv5")
+		assert.Error(t, err)
+	})
+
+	t.Run("Should only connect to addresses of the forced IP family", func(t *testing.T) {
+		listener, err := net.Listen("tcp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = listener.Close() })
+
+		conn, err := DNSOpts{IPFamily: IPFamilyIPv4}.dialContext(&net.Dialer{})(context.Background(), "tcp",
+			listener.Addr().String())
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+
+		_, err = DNSOpts{IPFamily: IPFamilyIPv6}.dialContext(&net.Dialer{})(context.Background(), "tcp",
+			listener.Addr().String())
+		require.Error(t, err)
+	})
+
+	t.Run("Should connect to the other IP family if the preferred one is unreachable", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+		for _, family := range []IPFamily{IPFamilyPreferIPv4, IPFamilyPreferIPv6} {
+			download(t, Opts{DNS: DNSOpts{IPFamily: family}}, "http://localhost:"+port+"/plugin.zip")
+		}
+	})
+
+	t.Run("Should split the addresses by IP family", func(t *testing.T) {
+		addrs := []net.IPAddr{{IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("192.0.2.1")},
+			{IP: net.ParseIP("2001:db8::2")}, {IP: net.ParseIP("192.0.2.2")}}
+
+		preferred, others := splitFamily(addrs, false)
+		assert.Equal(t, []net.IPAddr{addrs[1], addrs[3]}, preferred)
+		assert.Equal(t, []net.IPAddr{addrs[0], addrs[2]}, others)
+		preferred, others = splitFamily(addrs, true)
+		assert.Equal(t, []net.IPAddr{addrs[0], addrs[2]}, preferred)
+		assert.Equal(t, []net.IPAddr{addrs[1], addrs[3]}, others)
+	})
+
+	t.Run("Should fall back to the other IP family if the preferred one doesn't answer", func(t *testing.T) {
+		listener, err := net.Listen("tcp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = listener.Close() })
+		_, port, err := net.SplitHostPort(listener.Addr().String())
+		require.NoError(t, err)
+		// Connections to the preferred IPv6 addresses are blackholed, they only fail once they time out
+		dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if strings.HasPrefix(addr, "[") {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, net.JoinHostPort("127.0.0.1", port))
+		}
+		preferred := []net.IPAddr{{IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("2001:db8::2")}}
+		fallbacks := []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		start := time.Now()
+		conn, err := dialFallback(ctx, dial, "tcp", port, preferred, fallbacks, 50*time.Millisecond)
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+		assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	})
+
+	t.Run("Should share the timeout among the addresses of a family", func(t *testing.T) {
+		var mu sync.Mutex
+		var timeouts []time.Duration
+		dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+			deadline, _ := ctx.Deadline()
+			mu.Lock()
+			timeouts = append(timeouts, time.Until(deadline))
+			mu.Unlock()
+			return nil, errors.New("connection refused")
+		}
+		addrs := []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("192.0.2.2")},
+			{IP: net.ParseIP("192.0.2.3")}}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		_, err := dialSerial(ctx, dial, "tcp", "443", addrs)
+		require.Error(t, err)
+		require.Len(t, timeouts, 3)
+		assert.LessOrEqual(t, int64(timeouts[0]), int64(10*time.Second))
+	})
+}
@@ -0,0 +1,181 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDownloadCacheMaxSize = 1 << 30
+	downloadCacheExt            = ".archive"
+)
+
+// DownloadCacheOpts configures the on-disk cache of downloaded plugin archives, which are reused when the same
+// archive is downloaded again, e.g. by several Grafana instances on a host or repeated CI builds. Only archives
+// with a known checksum are cached, and cached archives are verified against it before they're reused.
+type DownloadCacheOpts struct {
+	// Dir is the directory the archives are cached in. Archives are only cached if it's set.
+	Dir string
+	// MaxSize is the size in bytes the cached archives may take up together, the least recently used ones are
+	// removed beyond it. Defaults to 1GiB, negative values disable the limit.
+	MaxSize int64
+}
+
+// downloadCachePath returns the cache file of the archive, keyed by its URL and checksum, or an empty string if
+// it's not cached.
+func (i *Installer) downloadCachePath(url, checksum string) string {
+	if i.opts.DownloadCache.Dir == "" || checksum == "" {
+		return ""
+	}
+	if _, err := os.Stat(url); err == nil {
+		// Local archives are read directly
+		return ""
+	}
+	c, err := parseChecksum(checksum)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(url + "\n" + c.String()))
+	return filepath.Join(i.opts.DownloadCache.Dir, hex.EncodeToString(sum[:])+downloadCacheExt)
+}
+
+// readCachedDownload copies the cached archive to the file if it matches the checksum, reporting whether it did.
+// Cached archives that don't match their checksum are removed.
+func (i *Installer) readCachedDownload(path string, tmpFile *os.File, checksum string) (bool, error) {
+	// nolint:gosec
+	f, err := os.Open(path)
+	if err != nil {
+		return false, nil
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			i.log.Warn("Failed to close file", "err", err)
+		}
+	}()
+
+	expected, err := parseChecksum(checksum)
+	if err != nil {
+		return false, err
+	}
+	h := expected.newHash()
+	if _, err := io.Copy(tmpFile, io.TeeReader(f, h)); err == nil && expected.matches(h) {
+		now := time.Now()
+		// The modification time tracks when the archive was last used, to remove the least recently used ones
+		if err := os.Chtimes(path, now, now); err != nil {
+			i.log.Debugf("Failed to update the modification time of %s: %v", path, err)
+		}
+		return true, nil
+	}
+
+	i.log.Warnf("Cached plugin archive %s is corrupt, downloading it again", path)
+	if err := os.Remove(path); err != nil {
+		i.log.Warn("Failed to remove cached plugin archive", "path", path, "err", err)
+	}
+	if err := tmpFile.Truncate(0); err != nil {
+		return false, err
+	}
+	_, err = tmpFile.Seek(0, 0)
+	return false, err
+}
+
+// writeCachedDownload atomically adds the downloaded archive to the cache, then removes the least recently used
+// archives beyond the maximum cache size.
+func (i *Installer) writeCachedDownload(path string, tmpFile *os.File) error {
+	fi, err := tmpFile.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".download-*")
+	if err != nil {
+		return err
+	}
+	// Reading at offsets leaves the file positioned at the end of the download
+	if _, err := io.Copy(f, io.NewSectionReader(tmpFile, 0, fi.Size())); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	maxSize := i.opts.DownloadCache.MaxSize
+	if maxSize == 0 {
+		maxSize = defaultDownloadCacheMaxSize
+	}
+	if maxSize < 0 {
+		return nil
+	}
+	return pruneDownloadCache(filepath.Dir(path), maxSize)
+}
+
+// pruneDownloadCache removes the least recently used archives until the cached archives take up at most maxSize
+// bytes.
+func pruneDownloadCache(dir string, maxSize int64) error {
+	entries, err := downloadCacheEntries(dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].ModTime().After(entries[b].ModTime()) })
+
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size()
+		if size <= maxSize {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeDownloadCache removes all archives from the download cache directory, returning the number of bytes freed.
+func PurgeDownloadCache(dir string) (int64, error) {
+	entries, err := downloadCacheEntries(dir)
+	if err != nil {
+		return 0, err
+	}
+	var freed int64
+	for _, entry := range entries {
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return freed, err
+		}
+		freed += entry.Size()
+	}
+	return freed, nil
+}
+
+// downloadCacheEntries returns the cached archives of the directory, none if it doesn't exist.
+func downloadCacheEntries(dir string) ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []os.FileInfo
+	for _, fi := range infos {
+		if fi.Mode().IsRegular() && strings.HasSuffix(fi.Name(), downloadCacheExt) {
+			entries = append(entries, fi)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,119 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadCache(t *testing.T) {
+	archive := []byte("plugin archive")
+	sum := sha256.Sum256(archive)
+	checksum := hex.EncodeToString(sum[:])
+
+	newServer := func(t *testing.T) (*httptest.Server, *int) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			_, _ = w.Write(archive)
+		}))
+		t.Cleanup(server.Close)
+		return server, &requests
+	}
+	download := func(t *testing.T, i *Installer, url, checksum string) []byte {
+		t.Helper()
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tmpFile.Close()) }()
+		require.NoError(t, i.DownloadFile("test-panel", tmpFile, url, checksum))
+		data, err := ioutil.ReadFile(tmpFile.Name())
+		require.NoError(t, err)
+		return data
+	}
+
+	t.Run("Should reuse cached archives with the same URL and checksum", func(t *testing.T) {
+		server, requests := newServer(t)
+		cacheDir := t.TempDir()
+		i := NewWithOpts(Opts{DownloadCache: DownloadCacheOpts{Dir: cacheDir}}, "8.0.0", &fakeLogger{})
+
+		assert.Equal(t, archive, download(t, i, server.URL+"/a.zip", checksum))
+		assert.Equal(t, archive, download(t, i, server.URL+"/a.zip", checksum))
+		assert.Equal(t, 1, *requests)
+
+		assert.Equal(t, archive, download(t, i, server.URL+"/b.zip", checksum))
+		assert.Equal(t, 2, *requests)
+	})
+
+	t.Run("Should not cache archives without checksum", func(t *testing.T) {
+		server, requests := newServer(t)
+		cacheDir := t.TempDir()
+		i := NewWithOpts(Opts{DownloadCache: DownloadCacheOpts{Dir: cacheDir}}, "8.0.0", &fakeLogger{})
+
+		download(t, i, server.URL, "")
+		download(t, i, server.URL, "")
+		assert.Equal(t, 2, *requests)
+		entries, err := downloadCacheEntries(cacheDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Should download corrupt cached archives again", func(t *testing.T) {
+		server, requests := newServer(t)
+		cacheDir := t.TempDir()
+		i := NewWithOpts(Opts{DownloadCache: DownloadCacheOpts{Dir: cacheDir}}, "8.0.0", &fakeLogger{})
+		require.NoError(t, ioutil.WriteFile(i.downloadCachePath(server.URL, checksum), []byte("corrupt"), 0600))
+
+		assert.Equal(t, archive, download(t, i, server.URL, checksum))
+		assert.Equal(t, 1, *requests)
+		data, err := ioutil.ReadFile(i.downloadCachePath(server.URL, checksum))
+		require.NoError(t, err)
+		assert.Equal(t, archive, data)
+	})
+
+	t.Run("Should remove the least recently used archives beyond the maximum size", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		for idx, name := range []string{"old", "recent", "newest"} {
+			path := filepath.Join(cacheDir, name+downloadCacheExt)
+			require.NoError(t, ioutil.WriteFile(path, make([]byte, 10), 0600))
+			mtime := time.Now().Add(time.Duration(idx-3) * time.Hour)
+			require.NoError(t, os.Chtimes(path, mtime, mtime))
+		}
+
+		require.NoError(t, pruneDownloadCache(cacheDir, 25))
+		_, err := os.Stat(filepath.Join(cacheDir, "old"+downloadCacheExt))
+		assert.True(t, os.IsNotExist(err))
+		entries, err := downloadCacheEntries(cacheDir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("Should purge all cached archives", func(t *testing.T) {
+		server, _ := newServer(t)
+		cacheDir := t.TempDir()
+		i := NewWithOpts(Opts{DownloadCache: DownloadCacheOpts{Dir: cacheDir}}, "8.0.0", &fakeLogger{})
+		download(t, i, server.URL, checksum)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(cacheDir, "unrelated"), nil, 0600))
+
+		freed, err := PurgeDownloadCache(cacheDir)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(archive)), freed)
+		entries, err := downloadCacheEntries(cacheDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+		_, err = os.Stat(filepath.Join(cacheDir, "unrelated"))
+		assert.NoError(t, err)
+
+		freed, err = PurgeDownloadCache(filepath.Join(cacheDir, "missing"))
+		require.NoError(t, err)
+		assert.Zero(t, freed)
+	})
+}
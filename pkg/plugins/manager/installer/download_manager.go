@@ -0,0 +1,69 @@
+package installer
+
+import (
+	"io"
+	"sync"
+)
+
+// defaultMaxConcurrentDownloads is the number of archives downloaded at the same time if no limit is configured.
+const defaultMaxConcurrentDownloads = 4
+
+// DownloadManager bounds the number of plugin archives downloaded at the same time, e.g. so that provisioning
+// dozens of plugins doesn't open as many connections through a constrained proxy. Each installer creates its own
+// manager unless one is passed in Opts.DownloadManager, whose batch installs and dependency fetches share the limit.
+// The per-platform installers of ExportBundle share the manager of the installer exporting the bundle.
+type DownloadManager struct {
+	slots chan struct{}
+}
+
+// NewDownloadManager returns a manager downloading at most maxConcurrent archives at the same time, 4 if it's not
+// positive.
+func NewDownloadManager(maxConcurrent int) *DownloadManager {
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrentDownloads
+	}
+	return &DownloadManager{slots: make(chan struct{}, maxConcurrent)}
+}
+
+// Limit returns the number of archives downloaded at the same time.
+func (m *DownloadManager) Limit() int {
+	if m == nil {
+		return defaultMaxConcurrentDownloads
+	}
+	return cap(m.slots)
+}
+
+func (m *DownloadManager) release() {
+	if m != nil {
+		<-m.slots
+	}
+}
+
+// acquireDownloadSlot waits for a slot of the installer's download manager to download the URL, logging if all
+// slots are taken. Installers without a manager don't bound their downloads.
+func (i *Installer) acquireDownloadSlot(downloadURL string) {
+	if i.downloads == nil {
+		return
+	}
+	select {
+	case i.downloads.slots <- struct{}{}:
+		return
+	default:
+	}
+	i.log.Debugf("Waiting for one of %d concurrent downloads to finish before downloading %s",
+		i.downloads.Limit(), RedactURL(downloadURL))
+	i.downloads.slots <- struct{}{}
+}
+
+// slotBody releases the download slot of the body once it's closed.
+type slotBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *slotBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
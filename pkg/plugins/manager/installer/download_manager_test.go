@@ -0,0 +1,80 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadManager(t *testing.T) {
+	download := func(t *testing.T, i *Installer, url string) error {
+		t.Helper()
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tmpFile.Close()) }()
+		return i.DownloadFile("test-panel", tmpFile, url, "")
+	}
+
+	t.Run("Should limit the downloads of installers sharing a manager", func(t *testing.T) {
+		var mu sync.Mutex
+		var inFlight, maxInFlight int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			_, _ = w.Write([]byte("plugin archive"))
+		}))
+		t.Cleanup(server.Close)
+		manager := NewDownloadManager(2)
+		installers := []*Installer{
+			NewWithOpts(Opts{DownloadManager: manager}, "8.0.0", &fakeLogger{}),
+			NewWithOpts(Opts{DownloadManager: manager}, "8.0.0", &fakeLogger{}),
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, 8)
+		for idx := range errs {
+			wg.Add(1)
+			go func(idx int) {
+				defer wg.Done()
+				errs[idx] = download(t, installers[idx%2], server.URL)
+			}(idx)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, maxInFlight)
+	})
+
+	t.Run("Should release the slots of failed downloads", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		t.Cleanup(server.Close)
+		i := NewWithOpts(Opts{DownloadTransport: TransportOpts{MaxConcurrentDownloads: 1}}, "8.0.0", &fakeLogger{})
+
+		for n := 0; n < 3; n++ {
+			require.Error(t, download(t, i, server.URL))
+		}
+	})
+
+	t.Run("Should limit the downloads to the configured number", func(t *testing.T) {
+		i := NewWithOpts(Opts{DownloadTransport: TransportOpts{MaxConcurrentDownloads: 8}}, "8.0.0", &fakeLogger{})
+		assert.Equal(t, 8, i.downloads.Limit())
+		assert.Equal(t, 4, NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).downloads.Limit())
+	})
+}
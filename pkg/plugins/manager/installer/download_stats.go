@@ -0,0 +1,43 @@
+package installer
+
+import (
+	"time"
+)
+
+// DownloadStats are the transfer statistics of a plugin archive download, e.g. to spot slow mirrors or to show
+// them in the UI.
+type DownloadStats struct {
+	// Bytes is the number of bytes of the archive that were downloaded.
+	Bytes int64 `json:"bytes"`
+	// Duration is the time the download from the mirror that served the archive took, failed attempts at other
+	// mirrors aren't included.
+	Duration time.Duration `json:"durationNs"`
+	// BytesPerSecond is the average throughput of the download.
+	BytesPerSecond int64 `json:"bytesPerSecond"`
+	// Attempts is the number of mirrors the archive was requested from, including the one that served it.
+	Attempts int `json:"attempts"`
+}
+
+func newDownloadStats(bytes int64, start time.Time, attempts int) *DownloadStats {
+	stats := &DownloadStats{Bytes: bytes, Duration: time.Since(start), Attempts: attempts}
+	if stats.Duration > 0 {
+		stats.BytesPerSecond = int64(float64(bytes) / stats.Duration.Seconds())
+	}
+	return stats
+}
+
+// logDownloadStats logs the statistics of the download of the plugin's archive from the URL, as key=value pairs
+// that log processors can parse.
+func (i *Installer) logDownloadStats(pluginID, downloadURL string, stats *DownloadStats) {
+	i.log.Debugf("Downloaded plugin archive pluginId=%s source=%s bytes=%d duration=%s bytesPerSecond=%d attempts=%d",
+		pluginID, RedactURL(downloadURL), stats.Bytes, stats.Duration.Round(time.Millisecond), stats.BytesPerSecond,
+		stats.Attempts)
+}
+
+// byteCounter counts the bytes written to it.
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
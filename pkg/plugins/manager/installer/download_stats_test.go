@@ -0,0 +1,68 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadStats(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+	archive, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}))
+	require.NoError(t, err)
+	newRepo := func(t *testing.T, downloadStatus int) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/repo/test-panel":
+				_, _ = w.Write([]byte(`{"id": "test-panel", "versions": [{"version": "1.0.0"}]}`))
+			case "/test-panel/versions/1.0.0/download":
+				w.WriteHeader(downloadStatus)
+				_, _ = w.Write(archive)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	for _, streamExtraction := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Should report the statistics of the download in the plan and audit events (stream extraction: %t)", streamExtraction), func(t *testing.T) {
+			primary := newRepo(t, http.StatusBadGateway)
+			mirror := newRepo(t, http.StatusOK)
+			sink := &fakeAuditSink{}
+			i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, RepoMirrors: []string{mirror.URL},
+				Retry: noRetry, AuditSink: sink, StreamExtraction: streamExtraction}, "8.0.0", &fakeLogger{})
+
+			plan, err := i.PlanInstall("test-panel", "", t.TempDir(), "", primary.URL)
+			require.NoError(t, err)
+			require.Len(t, plan.Plugins, 1)
+			stats := plan.Plugins[0].Download
+			require.NotNil(t, stats)
+			assert.Equal(t, int64(len(archive)), stats.Bytes)
+			assert.Equal(t, 2, stats.Attempts)
+			assert.Positive(t, stats.Duration)
+			assert.Positive(t, stats.BytesPerSecond)
+
+			require.NoError(t, i.ApplyPlan(plan))
+			require.Len(t, sink.events, 1)
+			assert.Equal(t, stats, sink.events[0].Download)
+		})
+	}
+
+	t.Run("Should not report statistics for local archives", func(t *testing.T) {
+		archivePath := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+
+		plan, err := i.PlanInstall("test-panel", "", t.TempDir(), archivePath, "")
+		require.NoError(t, err)
+		defer plan.Close()
+		require.Len(t, plan.Plugins, 1)
+		assert.Nil(t, plan.Plugins[0].Download)
+	})
+}
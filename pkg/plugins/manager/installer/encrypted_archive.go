@@ -0,0 +1,352 @@
+package installer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrArchivePassphraseRequired is returned when installing an encrypted archive without a passphrase.
+var ErrArchivePassphraseRequired = errors.New("plugin archive is encrypted, please provide a passphrase")
+
+const (
+	// ageHeader starts the header of age encrypted files, see https://age-encryption.org/v1.
+	ageHeader = "age-encryption.org/v1\n"
+	// ageSecretKeyPrefix starts the X25519 identities created by age-keygen.
+	ageSecretKeyPrefix = "AGE-SECRET-KEY-1"
+	// winZipAESMethod is the compression method of zip members encrypted with WinZip AES (AE-1 and AE-2),
+	// see https://www.winzip.com/en/support/aes-encryption/
+	winZipAESMethod   = 99
+	winZipAESExtraID  = 0x9901
+	winZipAuthCodeLen = 10
+	winZipPwvLen      = 2
+)
+
+// archivePassphrase returns the passphrase for encrypted archives, read from the configured key file if no
+// passphrase has been provided directly.
+func (i *Installer) archivePassphrase() (string, error) {
+	if i.opts.ArchivePassphrase != "" {
+		return i.opts.ArchivePassphrase, nil
+	}
+	if i.opts.ArchivePassphraseFile == "" {
+		return "", ErrArchivePassphraseRequired
+	}
+	// nolint:gosec
+	data, err := ioutil.ReadFile(i.opts.ArchivePassphraseFile)
+	if err != nil {
+		return "", errutil.Wrap("failed to read archive passphrase file", err)
+	}
+	passphrase := strings.TrimRight(string(data), "\r\n")
+	if passphrase == "" {
+		return "", ErrArchivePassphraseRequired
+	}
+	return passphrase, nil
+}
+
+// isOpenPGPEncrypted reports whether the header belongs to a passphrase encrypted OpenPGP message, such as
+// the ones created by `gpg --symmetric`.
+func isOpenPGPEncrypted(header []byte) bool {
+	if bytes.HasPrefix(header, []byte("-----BEGIN PGP MESSAGE-----")) {
+		return true
+	}
+	if len(header) == 0 || header[0]&0x80 == 0 {
+		return false
+	}
+	// Both old and new packet formats, tag 3 is a symmetric-key encrypted session key packet
+	if header[0]&0x40 == 0 {
+		return (header[0]>>2)&0x0f == 3
+	}
+	return header[0]&0x3f == 3
+}
+
+// decryptedArchive removes the decrypted copy of an encrypted archive when closed.
+type decryptedArchive struct {
+	pluginArchive
+	path string
+}
+
+func (a *decryptedArchive) Close() error {
+	err := a.pluginArchive.Close()
+	if rerr := os.Remove(a.path); rerr != nil && err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// openEncryptedArchive decrypts an OpenPGP encrypted archive into a temporary file and opens the result.
+func (i *Installer) openEncryptedArchive(archivePath, pluginID string) (pluginArchive, error) {
+	passphrase, err := i.archivePassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	// nolint:gosec
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			i.log.Warn("Failed to close file", "err", err)
+		}
+	}()
+
+	var r io.Reader = f
+	if block, err := armor.Decode(f); err == nil {
+		r = block.Body
+	} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	prompted := false
+	md, err := openpgp.ReadMessage(r, nil, func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		// The prompt is called again when decryption fails, bail out instead of looping forever
+		if prompted {
+			return nil, errors.New("invalid archive passphrase")
+		}
+		prompted = true
+		return []byte(passphrase), nil
+	}, nil)
+	if err != nil {
+		return nil, errutil.Wrap("failed to decrypt plugin archive", err)
+	}
+
+	// The message may be compressed, so the decrypted archive is bounded like a downloaded one
+	return i.openDecryptedArchive(md.UnverifiedBody, pluginID)
+}
+
+// isAgeEncrypted reports whether the header belongs to an age encrypted file, in the binary or the armored format.
+func isAgeEncrypted(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(ageHeader)) || bytes.HasPrefix(header, []byte(agearmor.Header))
+}
+
+// ageIdentities returns the identities age encrypted archives are decrypted with. The archive passphrase, or the
+// file it's read from, either holds X25519 identities created by age-keygen or is a passphrase (age -p).
+func (i *Installer) ageIdentities() ([]age.Identity, error) {
+	passphrase, err := i.archivePassphrase()
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(passphrase, ageSecretKeyPrefix) {
+		identities, err := age.ParseIdentities(strings.NewReader(passphrase))
+		if err != nil {
+			return nil, errutil.Wrap("invalid age identity", err)
+		}
+		return identities, nil
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Identity{identity}, nil
+}
+
+// openAgeArchive decrypts an age encrypted archive into a temporary file and opens the result.
+func (i *Installer) openAgeArchive(archivePath, pluginID string) (pluginArchive, error) {
+	identities, err := i.ageIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	// nolint:gosec
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			i.log.Warn("Failed to close file", "err", err)
+		}
+	}()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if start, _ := br.Peek(len(agearmor.Header)); string(start) == agearmor.Header {
+		r = agearmor.NewReader(br)
+	}
+	decrypted, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, errutil.Wrap("failed to decrypt plugin archive", err)
+	}
+	return i.openDecryptedArchive(decrypted, pluginID)
+}
+
+// openDecryptedArchive writes the decrypted content of an encrypted archive to a temporary file, bounded like a
+// downloaded archive, and opens the result.
+func (i *Installer) openDecryptedArchive(decrypted io.Reader, pluginID string) (pluginArchive, error) {
+	tmpDir, err := i.tempDir("")
+	if err != nil {
+		return nil, err
+	}
+	tmpFile, err := ioutil.TempFile(tmpDir, "*.decrypted")
+	if err != nil {
+		return nil, errutil.Wrap("failed to create temporary file", err)
+	}
+	if _, err := io.Copy(tmpFile, i.archiveSizeReader(decrypted)); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return nil, errutil.Wrap("failed to decrypt plugin archive", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return nil, err
+	}
+
+	archive, err := i.openArchive(tmpFile.Name(), pluginID)
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return nil, err
+	}
+	return &decryptedArchive{pluginArchive: archive, path: tmpFile.Name()}, nil
+}
+
+// openAES decrypts and decompresses a WinZip AES encrypted zip member.
+func (a *zipArchive) openAES(zf *zip.File) (io.ReadCloser, error) {
+	passphrase, err := a.passphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	strength, method, err := winZipAESParams(zf.Extra)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", zf.Name, err)
+	}
+	keyLen := 8 * (strength + 1)
+	saltLen := keyLen / 2
+
+	offset, err := zf.DataOffset()
+	if err != nil {
+		return nil, err
+	}
+	size := int64(zf.CompressedSize64)
+	if size < int64(saltLen+winZipPwvLen+winZipAuthCodeLen) {
+		return nil, fmt.Errorf("%s: encrypted data is truncated", zf.Name)
+	}
+	raw := io.NewSectionReader(a.f, offset, size)
+
+	header := make([]byte, saltLen+winZipPwvLen)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, err
+	}
+	keys := pbkdf2.Key([]byte(passphrase), header[:saltLen], 1000, 2*keyLen+winZipPwvLen, sha1.New)
+	if !hmac.Equal(keys[2*keyLen:], header[saltLen:]) {
+		return nil, errors.New("invalid archive passphrase")
+	}
+
+	block, err := aes.NewCipher(keys[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	dataLen := size - int64(len(header)) - winZipAuthCodeLen
+	mac := hmac.New(sha1.New, keys[keyLen:2*keyLen])
+	decrypted := &winZipAESReader{
+		data:     io.TeeReader(io.NewSectionReader(raw, int64(len(header)), dataLen), mac),
+		stream:   newWinZipCTR(block),
+		mac:      mac,
+		authCode: io.NewSectionReader(raw, int64(len(header))+dataLen, winZipAuthCodeLen),
+	}
+
+	switch method {
+	case zip.Store:
+		return ioutil.NopCloser(decrypted), nil
+	case zip.Deflate:
+		return flate.NewReader(decrypted), nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported compression method %d", zf.Name, method)
+	}
+}
+
+// winZipAESParams returns the key strength (1-3) and actual compression method from the AES extra field.
+func winZipAESParams(extra []byte) (int, uint16, error) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		if id == winZipAESExtraID && size >= 7 {
+			strength := int(extra[4])
+			if strength < 1 || strength > 3 {
+				return 0, 0, fmt.Errorf("invalid AES key strength %d", strength)
+			}
+			return strength, binary.LittleEndian.Uint16(extra[5:7]), nil
+		}
+		extra = extra[size:]
+	}
+	return 0, 0, errors.New("missing AES encryption header")
+}
+
+// winZipAESReader decrypts the member data and verifies its authentication code once all data has been read.
+type winZipAESReader struct {
+	data     io.Reader
+	stream   cipher.Stream
+	mac      hash.Hash
+	authCode io.Reader
+	verified bool
+}
+
+func (r *winZipAESReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	r.stream.XORKeyStream(p[:n], p[:n])
+	if errors.Is(err, io.EOF) && !r.verified {
+		r.verified = true
+		expected := make([]byte, winZipAuthCodeLen)
+		if _, err := io.ReadFull(r.authCode, expected); err != nil {
+			return n, err
+		}
+		if !hmac.Equal(expected, r.mac.Sum(nil)[:winZipAuthCodeLen]) {
+			return n, errors.New("authentication of encrypted archive member failed")
+		}
+	}
+	return n, err
+}
+
+// winZipCTR is AES in counter mode with the little endian counter, starting at 1, that WinZip uses.
+type winZipCTR struct {
+	block     cipher.Block
+	counter   [aes.BlockSize]byte
+	keyStream [aes.BlockSize]byte
+	pos       int
+}
+
+func newWinZipCTR(block cipher.Block) *winZipCTR {
+	return &winZipCTR{block: block, pos: aes.BlockSize}
+}
+
+func (c *winZipCTR) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.pos == aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.keyStream[:], c.counter[:])
+			c.pos = 0
+		}
+		dst[i] = src[i] ^ c.keyStream[c.pos]
+		c.pos++
+	}
+}
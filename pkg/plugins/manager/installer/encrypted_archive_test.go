@@ -0,0 +1,236 @@
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const testPluginJSON = `{"id":"test-panel","type":"panel","info":{"version":"1.0.0"}}`
+
+func TestExtractEncryptedArchives(t *testing.T) {
+	t.Run("Should extract WinZip AES encrypted zip", func(t *testing.T) {
+		i := &Installer{opts: Opts{ArchivePassphrase: "secret"}, log: &fakeLogger{}}
+		archive := writeTestAESZip(t, "secret", "test-panel/plugin.json", testPluginJSON)
+		pluginsDir := t.TempDir()
+
+		err := i.extractFiles(archive, "test-panel", pluginsDir, false)
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		require.NoError(t, err)
+		assert.Equal(t, testPluginJSON, string(data))
+	})
+
+	t.Run("Should fail to extract WinZip AES encrypted zip with wrong passphrase", func(t *testing.T) {
+		i := &Installer{opts: Opts{ArchivePassphrase: "wrong"}, log: &fakeLogger{}}
+		archive := writeTestAESZip(t, "secret", "test-panel/plugin.json", testPluginJSON)
+
+		err := i.extractFiles(archive, "test-panel", t.TempDir(), false)
+		require.Error(t, err)
+	})
+
+	t.Run("Should fail to extract WinZip AES encrypted zip without passphrase", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+		archive := writeTestAESZip(t, "secret", "test-panel/plugin.json", testPluginJSON)
+
+		err := i.extractFiles(archive, "test-panel", t.TempDir(), false)
+		require.ErrorIs(t, err, ErrArchivePassphraseRequired)
+	})
+
+	t.Run("Should extract OpenPGP encrypted tar.gz with passphrase from file", func(t *testing.T) {
+		passphraseFile := filepath.Join(t.TempDir(), "passphrase")
+		require.NoError(t, ioutil.WriteFile(passphraseFile, []byte("secret\n"), 0600))
+		i := &Installer{opts: Opts{ArchivePassphraseFile: passphraseFile}, log: &fakeLogger{}}
+
+		plain, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON}))
+		require.NoError(t, err)
+		buf := new(bytes.Buffer)
+		w, err := openpgp.SymmetricallyEncrypt(buf, []byte("secret"), nil, nil)
+		require.NoError(t, err)
+		_, err = w.Write(plain)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		archive := filepath.Join(t.TempDir(), "plugin.tar.gz.gpg")
+		require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+		pluginsDir := t.TempDir()
+
+		err = i.extractFiles(archive, "test-panel", pluginsDir, false)
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		require.NoError(t, err)
+		assert.Equal(t, testPluginJSON, string(data))
+	})
+
+	t.Run("Should extract armored age encrypted tar.gz with passphrase", func(t *testing.T) {
+		recipient, err := age.NewScryptRecipient("secret")
+		require.NoError(t, err)
+		recipient.SetWorkFactor(10)
+		archive := writeTestAgeArchive(t, true, recipient)
+		i := &Installer{opts: Opts{ArchivePassphrase: "secret"}, log: &fakeLogger{}}
+		pluginsDir := t.TempDir()
+
+		err = i.extractFiles(archive, "test-panel", pluginsDir, false)
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		require.NoError(t, err)
+		assert.Equal(t, testPluginJSON, string(data))
+	})
+
+	t.Run("Should extract age encrypted tar.gz with identity from key file", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+		archive := writeTestAgeArchive(t, false, identity.Recipient())
+		keyFile := filepath.Join(t.TempDir(), "key.txt")
+		require.NoError(t, ioutil.WriteFile(keyFile, []byte("# created: 2021-09-06T10:00:00Z\n"+
+			"# public key: "+identity.Recipient().String()+"\n"+identity.String()+"\n"), 0600))
+		i := &Installer{opts: Opts{ArchivePassphraseFile: keyFile}, log: &fakeLogger{}}
+		pluginsDir := t.TempDir()
+
+		err = i.extractFiles(archive, "test-panel", pluginsDir, false)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+	})
+
+	t.Run("Should fail to extract age encrypted tar.gz with wrong key", func(t *testing.T) {
+		identity, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+		other, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+		archive := writeTestAgeArchive(t, false, identity.Recipient())
+		i := &Installer{opts: Opts{ArchivePassphrase: other.String()}, log: &fakeLogger{}}
+
+		err = i.extractFiles(archive, "test-panel", t.TempDir(), false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to decrypt plugin archive")
+	})
+
+	t.Run("Should fail for compressed OpenPGP message exceeding maximum archive size", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		i := &Installer{opts: Opts{ArchivePassphrase: "secret", TempDir: tmpDir,
+			Limits: ExtractionLimits{MaxArchiveSize: 64 << 10}}, log: &fakeLogger{}}
+		buf := new(bytes.Buffer)
+		w, err := openpgp.SymmetricallyEncrypt(buf, []byte("secret"), nil, &packet.Config{
+			DefaultCompressionAlgo: packet.CompressionZLIB,
+			CompressionConfig:      &packet.CompressionConfig{Level: packet.BestCompression},
+		})
+		require.NoError(t, err)
+		_, err = w.Write(make([]byte, 16<<20))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.Less(t, buf.Len(), 64<<10)
+		archive := filepath.Join(t.TempDir(), "plugin.tar.gz.gpg")
+		require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+
+		err = i.extractFiles(archive, "test-panel", t.TempDir(), false)
+		var limitErr *LimitExceededError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, "archive size", limitErr.Limit)
+		files, err := ioutil.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+}
+
+// writeTestAgeArchive writes a tar.gz with a plugin.json encrypted with age for the recipient.
+func writeTestAgeArchive(t *testing.T, armored bool, recipient age.Recipient) string {
+	t.Helper()
+
+	plain, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON}))
+	require.NoError(t, err)
+	buf := new(bytes.Buffer)
+	var out io.Writer = buf
+	var aw io.WriteCloser
+	if armored {
+		aw = agearmor.NewWriter(buf)
+		out = aw
+	}
+	w, err := age.Encrypt(out, recipient)
+	require.NoError(t, err)
+	_, err = w.Write(plain)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	if aw != nil {
+		require.NoError(t, aw.Close())
+	}
+	archive := filepath.Join(t.TempDir(), "plugin.tar.gz.age")
+	require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+	return archive
+}
+
+// writeTestAESZip writes a zip with a single stored member encrypted with AES-256 the way WinZip does.
+func writeTestAESZip(t *testing.T, passphrase, name, content string) string {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	zw.RegisterCompressor(winZipAESMethod, func(out io.Writer) (io.WriteCloser, error) {
+		return &testAESWriter{out: out, passphrase: passphrase}, nil
+	})
+
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], winZipAESExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], 2)
+	copy(extra[6:], "AE")
+	extra[8] = 3
+	binary.LittleEndian.PutUint16(extra[9:], zip.Store)
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: winZipAESMethod, Extra: extra, Flags: 0x1})
+	require.NoError(t, err)
+	_, err = w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	archive := filepath.Join(t.TempDir(), "plugin.zip")
+	require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+	return archive
+}
+
+type testAESWriter struct {
+	out        io.Writer
+	passphrase string
+	data       []byte
+}
+
+func (w *testAESWriter) Write(p []byte) (int, error) {
+	w.data = append(w.data, p...)
+	return len(p), nil
+}
+
+func (w *testAESWriter) Close() error {
+	salt := bytes.Repeat([]byte{7}, 16)
+	keys := pbkdf2.Key([]byte(w.passphrase), salt, 1000, 66, sha1.New)
+	block, err := aes.NewCipher(keys[:32])
+	if err != nil {
+		return err
+	}
+	encrypted := make([]byte, len(w.data))
+	newWinZipCTR(block).XORKeyStream(encrypted, w.data)
+	mac := hmac.New(sha1.New, keys[32:64])
+	mac.Write(encrypted)
+
+	for _, b := range [][]byte{salt, keys[64:], encrypted, mac.Sum(nil)[:winZipAuthCodeLen]} {
+		if _, err := w.out.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	defaultEnterpriseRepoURL = "https://grafana.com/api/enterprise/plugins"
+	// licenseTextEnvVar is the environment variable Grafana Enterprise reads the license token from.
+	licenseTextEnvVar = "GF_ENTERPRISE_LICENSE_TEXT"
+)
+
+// ErrLicenseRequired is returned when the Enterprise plugin repository requires a license token, but none is
+// configured.
+var ErrLicenseRequired = errors.New("a Grafana Enterprise license is required to install this plugin")
+
+// EntitlementError is returned when the Enterprise plugin repository rejects the license token for a plugin,
+// for example because the plugin is not included in the license or the license has expired.
+type EntitlementError struct {
+	PluginID string
+	Message  string
+}
+
+func (e *EntitlementError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("your Grafana Enterprise license does not include plugin %q", e.PluginID)
+	}
+	return fmt.Sprintf("your Grafana Enterprise license does not include plugin %q: %s", e.PluginID, e.Message)
+}
+
+func (i *Installer) enterpriseRepoURL() string {
+	if i.opts.EnterpriseRepoURL != "" {
+		return strings.TrimSuffix(i.opts.EnterpriseRepoURL, "/")
+	}
+	return defaultEnterpriseRepoURL
+}
+
+// licenseToken returns the license token of the instance, which is read from the options, the license file or
+// the GF_ENTERPRISE_LICENSE_TEXT environment variable, in that order.
+func (i *Installer) licenseToken() (string, error) {
+	if i.opts.LicenseToken != "" {
+		return i.opts.LicenseToken, nil
+	}
+	if i.opts.LicensePath != "" {
+		// nolint:gosec
+		data, err := ioutil.ReadFile(i.opts.LicensePath)
+		if err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read license file: %w", err)
+		}
+	}
+	return strings.TrimSpace(os.Getenv(licenseTextEnvVar)), nil
+}
+
+// setLicenseAuth authenticates requests to the Enterprise plugin repository with the license token.
+func (i *Installer) setLicenseAuth(req *http.Request, u *url.URL) error {
+	if !i.opts.Enterprise || !isUnderURL(u, i.enterpriseRepoURL()) {
+		return nil
+	}
+	token, err := i.licenseToken()
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// entitlementError translates the authorization errors returned by the Enterprise plugin repository.
+func (i *Installer) entitlementError(pluginID string, err error) error {
+	var badRequest *BadRequestError
+	if !i.opts.Enterprise || !errors.As(err, &badRequest) {
+		return err
+	}
+
+	switch {
+	case strings.HasPrefix(badRequest.Status, "401"):
+		if token, _ := i.licenseToken(); token == "" {
+			return ErrLicenseRequired
+		}
+		return &EntitlementError{PluginID: pluginID, Message: "the license token is invalid or has expired"}
+	case strings.HasPrefix(badRequest.Status, "403"):
+		return &EntitlementError{PluginID: pluginID, Message: badRequest.Message}
+	}
+	return err
+}
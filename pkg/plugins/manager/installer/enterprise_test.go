@@ -0,0 +1,89 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnterpriseRepo(t *testing.T) {
+	const licenseToken = "test-license-token"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Authorization") == "":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Header.Get("Authorization") != "Bearer "+licenseToken:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message": "invalid license"}`))
+		case r.URL.Path == "/enterprise/plugins/repo/grafana-splunk-datasource":
+			_, _ = w.Write([]byte(`{"id": "grafana-splunk-datasource", "versions": [{"version": "1.0.0"}]}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message": "plugin is not included in the license"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	repoURL := server.URL + "/enterprise/plugins"
+
+	t.Run("Should authenticate with license file", func(t *testing.T) {
+		licensePath := filepath.Join(t.TempDir(), "license.jwt")
+		require.NoError(t, ioutil.WriteFile(licensePath, []byte(licenseToken+"\n"), 0600))
+		i := NewWithOpts(Opts{Enterprise: true, EnterpriseRepoURL: repoURL, LicensePath: licensePath}, "8.0.0",
+			&fakeLogger{})
+
+		plugin, err := i.getPluginMetadataFromPluginRepo("grafana-splunk-datasource", i.enterpriseRepoURL())
+		require.NoError(t, err)
+		assert.Equal(t, "grafana-splunk-datasource", plugin.ID)
+	})
+
+	t.Run("Should return entitlement error for plugins not included in license", func(t *testing.T) {
+		i := NewWithOpts(Opts{Enterprise: true, EnterpriseRepoURL: repoURL, LicenseToken: licenseToken}, "8.0.0",
+			&fakeLogger{})
+
+		_, err := i.getPluginMetadataFromPluginRepo("grafana-oracle-datasource", i.enterpriseRepoURL())
+		var entitlementErr *EntitlementError
+		require.ErrorAs(t, err, &entitlementErr)
+		assert.Equal(t, "grafana-oracle-datasource", entitlementErr.PluginID)
+		assert.Equal(t, "plugin is not included in the license", entitlementErr.Message)
+	})
+
+	t.Run("Should reject invalid license", func(t *testing.T) {
+		i := NewWithOpts(Opts{Enterprise: true, EnterpriseRepoURL: repoURL, LicenseToken: "expired"}, "8.0.0",
+			&fakeLogger{})
+
+		_, err := i.getPluginMetadataFromPluginRepo("grafana-splunk-datasource", i.enterpriseRepoURL())
+		var entitlementErr *EntitlementError
+		require.ErrorAs(t, err, &entitlementErr)
+		assert.Contains(t, entitlementErr.Error(), "invalid or has expired")
+	})
+
+	t.Run("Should require license", func(t *testing.T) {
+		i := NewWithOpts(Opts{Enterprise: true, EnterpriseRepoURL: repoURL,
+			LicensePath: filepath.Join(t.TempDir(), "missing.jwt")}, "8.0.0", &fakeLogger{})
+		if license, ok := os.LookupEnv(licenseTextEnvVar); ok {
+			require.NoError(t, os.Unsetenv(licenseTextEnvVar))
+			t.Cleanup(func() {
+				require.NoError(t, os.Setenv(licenseTextEnvVar, license))
+			})
+		}
+
+		_, err := i.getPluginMetadataFromPluginRepo("grafana-splunk-datasource", i.enterpriseRepoURL())
+		require.ErrorIs(t, err, ErrLicenseRequired)
+	})
+
+	t.Run("Should not send license to other repositories", func(t *testing.T) {
+		i := NewWithOpts(Opts{Enterprise: true, EnterpriseRepoURL: repoURL, LicenseToken: licenseToken}, "8.0.0",
+			&fakeLogger{})
+
+		req, err := i.createRequest("https://grafana.com/api/plugins", "repo", "grafana-piechart-panel")
+		require.NoError(t, err)
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+}
@@ -0,0 +1,466 @@
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// bundleSumsFile lists the SHA256 checksums of the files of an offline bundle.
+const bundleSumsFile = "SHA256SUMS"
+
+// An offline bundle is a static plugin repository (see repo.StaticIndex) containing plugins and their dependency
+// closure for a set of platforms, along with a SHA256SUMS file listing the checksums of the index and archives in
+// the format of sha256sum. Air-gapped sites install from the bundle by passing its directory as plugin repository.
+
+// exportRef is a plugin reference waiting to be exported, along with the repository it's looked up in.
+type exportRef struct {
+	ref     string
+	version string
+	repoURL string
+	// dependent is the plugin depending on the plugin, if it's an optional dependency.
+	dependent string
+	optional  bool
+}
+
+// offlineBundle collects the plugins and checksums of a bundle being exported.
+type offlineBundle struct {
+	dir     string
+	plugins map[string]*Plugin
+	sums    map[string]string
+}
+
+// ExportBundle downloads the plugins, referenced as [<source alias>:]<plugin id>[@<version or channel>], and all
+// their dependencies for each of the <os>-<arch> platforms into an offline bundle. The bundle is written as a
+// directory, or as a gzipped tarball if the path ends with .tar.gz or .tgz. Platforms default to the current one.
+func (i *Installer) ExportBundle(pluginRefs []string, bundlePath, pluginRepoURL string, platforms []string) error {
+	if err := i.checkFIPS(); err != nil {
+		return err
+	}
+	if len(pluginRefs) == 0 {
+		return fmt.Errorf("no plugins to export")
+	}
+	if len(platforms) == 0 {
+		platforms = []string{osAndArchString()}
+	}
+	for _, platform := range platforms {
+		if parts := strings.Split(platform, "-"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid platform %q, expected <os>-<arch>", platform)
+		}
+	}
+	if i.opts.Enterprise {
+		pluginRepoURL = i.enterpriseRepoURL()
+	}
+
+	tarball := strings.HasSuffix(bundlePath, ".tar.gz") || strings.HasSuffix(bundlePath, ".tgz")
+	dir := bundlePath
+	if tarball {
+		tmpDir, err := ioutil.TempDir("", "plugin-bundle")
+		if err != nil {
+			return errutil.Wrap("failed to create temporary directory", err)
+		}
+		defer func() {
+			if err := os.RemoveAll(tmpDir); err != nil {
+				i.log.Warn("Failed to remove temporary directory", "dir", tmpDir, "err", err)
+			}
+		}()
+		dir = tmpDir
+	} else if entries, err := ioutil.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("bundle directory %q isn't empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return errutil.Wrap("failed to create bundle directory", err)
+	}
+
+	b := &offlineBundle{dir: dir, plugins: map[string]*Plugin{}, sums: map[string]string{}}
+	platformInstallers := map[string]*Installer{}
+	for _, platform := range platforms {
+		pi := NewWithOpts(i.opts, i.grafanaVersion, i.log)
+		pi.platform = platform
+		pi.downloads = i.downloads
+		platformInstallers[platform] = pi
+	}
+
+	queue := make([]exportRef, 0, len(pluginRefs))
+	for _, ref := range pluginRefs {
+		queue = append(queue, exportRef{ref: ref, repoURL: pluginRepoURL})
+	}
+	exported := map[string]bool{}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+		pluginID, repoURL, deps, err := i.exportPlugin(b, r, platforms, platformInstallers, exported)
+		if err != nil && r.optional {
+			i.log.Warnf("Failed to export optional dependency %s of %s: %v", r.ref, r.dependent, err)
+			continue
+		}
+		if err != nil {
+			return errutil.Wrapf(err, "failed to export plugin '%s'", r.ref)
+		}
+		if len(deps) > 0 {
+			i.log.Infof("Fetching %s dependencies...", pluginID)
+		}
+		for _, dep := range sortedDependencies(deps) {
+			if !exported[dep.ID] {
+				queue = append(queue, exportRef{ref: dep.ID, version: strings.TrimSpace(dep.Version), repoURL: repoURL,
+					dependent: pluginID, optional: dep.Optional})
+			}
+		}
+	}
+
+	if err := b.writeIndex(); err != nil {
+		return err
+	}
+	if tarball {
+		if err := writeBundleTarball(dir, bundlePath); err != nil {
+			return errutil.Wrap("failed to write bundle", err)
+		}
+	}
+	i.log.Successf("Exported %d plugins for %s to %s", len(b.plugins), strings.Join(platforms, ", "), bundlePath)
+	return nil
+}
+
+// exportPlugin downloads the archives of the plugin for the platforms into the bundle. It returns the resolved
+// plugin ID, the repository it was found in and the dependencies of the exported versions.
+func (i *Installer) exportPlugin(b *offlineBundle, r exportRef, platforms []string,
+	platformInstallers map[string]*Installer, exported map[string]bool) (string, string, []PluginDependency, error) {
+	pluginID, repoURL, err := i.resolveSourceAlias(r.ref, r.repoURL)
+	if err != nil {
+		return "", "", nil, err
+	}
+	defaultRepo := !i.opts.Enterprise && pluginID == r.ref
+	pluginID, requestedVersion, channel, err := parsePluginRef(pluginID, r.version)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if exported[pluginID] {
+		return pluginID, repoURL, nil, nil
+	}
+	exported[pluginID] = true
+	if err := i.checkPluginAllowed(pluginID); err != nil {
+		return "", "", nil, err
+	}
+	channel = i.resolveChannel(channel, requestedVersion)
+
+	plugin, repoURLs, err := i.lookupPlugin(pluginID, repoURL, defaultRepo)
+	if err != nil {
+		return "", "", nil, err
+	}
+	plugin, requestedVersion, err = resolveVersionRange(&plugin, requestedVersion)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var deps []PluginDependency
+	depsRead := map[string]bool{}
+	for _, platform := range platforms {
+		v, err := i.selectCompatibleVersion(&plugin, requestedVersion,
+			func(p *Plugin, requestedVersion string) (*Version, error) {
+				return selectPlatformVersion(p, requestedVersion, channel, platform)
+			})
+		if err != nil {
+			return "", "", nil, err
+		}
+		if err := i.checkAngularVersion(pluginID, v); err != nil {
+			return "", "", nil, err
+		}
+		if err := i.checkAdvisories(pluginID, v.Version, repoURLs[0]); err != nil {
+			return "", "", nil, err
+		}
+
+		arch, archMeta := platformArch(v, platform)
+		name := fmt.Sprintf("%s-%s.zip", pluginID, v.Version)
+		if arch != "any" {
+			name = fmt.Sprintf("%s-%s.%s.zip", pluginID, v.Version, arch)
+		}
+		rel := path.Join(pluginID, v.Version, name)
+		if _, exists := b.sums[rel]; exists {
+			continue
+		}
+
+		var downloadURLs []string
+		for _, u := range repoURLs {
+			downloadURL := repo.DownloadURL(u, pluginID, v, platform)
+			if !containsString(downloadURLs, downloadURL) {
+				downloadURLs = append(downloadURLs, downloadURL)
+			}
+		}
+		if err := i.checkSourceAllowed(downloadURLs[0]); err != nil {
+			return "", "", nil, err
+		}
+		checksum := archMeta.SHA256
+		if checksum == "" && archMeta.SHA512 != "" {
+			checksum = "sha512:" + archMeta.SHA512
+		}
+		if _, generic := repo.GenericBase(repoURLs[0]); generic && checksum == "" {
+			if checksum, err = i.genericRepoChecksum(downloadURLs[0]); err != nil {
+				return "", "", nil, err
+			}
+		}
+		if checksum == "" && i.opts.RequireChecksum {
+			return "", "", nil, errutil.Wrapf(ErrChecksumRequired, "failed to export %s %s for %s", pluginID,
+				v.Version, platform)
+		}
+
+		i.log.Infof("Exporting %s v%s for %s", pluginID, v.Version, platform)
+		archivePath := filepath.Join(b.dir, filepath.FromSlash(rel))
+		if err := platformInstallers[platform].downloadArchive(pluginID, archivePath, downloadURLs,
+			checksum); err != nil {
+			return "", "", nil, errutil.Wrap("failed to download plugin archive", err)
+		}
+		sum, err := plugins.FileSHA256(archivePath)
+		if err != nil {
+			return "", "", nil, err
+		}
+		b.add(&plugin, v, arch, sum, rel)
+
+		if !depsRead[v.Version] {
+			depsRead[v.Version] = true
+			pluginJSON, err := i.readArchivePluginJSON(archivePath, pluginID)
+			if err != nil {
+				return "", "", nil, errutil.Wrap("failed to read plugin.json of plugin archive", err)
+			}
+			if pluginJSON != nil {
+				deps = append(deps, pluginJSON.Dependencies.Plugins...)
+			}
+		}
+	}
+	return pluginID, repoURL, deps, nil
+}
+
+// downloadArchive downloads the plugin archive from the first available of the URLs to the path.
+func (i *Installer) downloadArchive(pluginID, archivePath string, downloadURLs []string, checksum string) error {
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0750); err != nil {
+		return err
+	}
+	// nolint:gosec
+	f, err := os.OpenFile(archivePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	if _, _, err := i.downloadFromMirrors(pluginID, f, downloadURLs, checksum); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readArchivePluginJSON reads the top-most plugin.json of the archive. It returns nil if there's none.
+func (i *Installer) readArchivePluginJSON(archivePath, pluginID string) (*InstalledPlugin, error) {
+	data, err := i.readArchivePluginJSONData(archivePath, pluginID)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var p InstalledPlugin
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// readArchivePluginJSONData returns the contents of the top-most plugin.json of the archive, or nil if there's none.
+func (i *Installer) readArchivePluginJSONData(archivePath, pluginID string) ([]byte, error) {
+	a, err := i.openArchive(archivePath, pluginID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := a.Close(); err != nil {
+			i.log.Warn("Failed to close archive", "err", err)
+		}
+	}()
+
+	var data []byte
+	depth := -1
+	err = a.walk(func(f *archiveFile) error {
+		name := strings.Trim(strings.TrimPrefix(filepath.ToSlash(f.name), "./"), "/")
+		if f.isDir() || f.isSymlink() || path.Base(name) != "plugin.json" {
+			return nil
+		}
+		if d := strings.Count(name, "/"); depth < 0 || d < depth {
+			r, err := f.open()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = r.Close()
+			}()
+			if data, err = ioutil.ReadAll(io.LimitReader(r, 1<<20)); err != nil {
+				return err
+			}
+			depth = d
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// selectPlatformVersion selects the version like selectVersion does for the current platform.
+func selectPlatformVersion(plugin *Plugin, requestedVersion string, channel Channel, platform string) (*Version,
+	error) {
+	for _, v := range plugin.Versions {
+		ver := v
+		if requestedVersion != "" && ver.Version != requestedVersion {
+			continue
+		}
+		if requestedVersion == "" && (!channel.Includes(&ver) || !supportsPlatform(&ver, platform)) {
+			continue
+		}
+		if !supportsPlatform(&ver, platform) {
+			return nil, fmt.Errorf("%s %s isn't supported on %s", plugin.ID, ver.Version, platform)
+		}
+		return &ver, nil
+	}
+	if requestedVersion != "" {
+		return nil, fmt.Errorf("could not find a version %s for %s", requestedVersion, plugin.ID)
+	}
+	return nil, fmt.Errorf("%s has no version supported on %s", plugin.ID, platform)
+}
+
+// platformArch returns the archive of the version for the platform, the platform specific one if there is one.
+func platformArch(v *Version, platform string) (string, ArchMeta) {
+	if archMeta, exists := v.Arch[platform]; exists {
+		return platform, archMeta
+	}
+	return "any", v.Arch["any"]
+}
+
+// add records the archive of the plugin version in the bundle index and checksums.
+func (b *offlineBundle) add(plugin *Plugin, v *Version, arch, sum, rel string) {
+	p, exists := b.plugins[plugin.ID]
+	if !exists {
+		p = &Plugin{ID: plugin.ID, Category: plugin.Category, SignatureType: plugin.SignatureType,
+			Status: plugin.Status}
+		b.plugins[plugin.ID] = p
+	}
+	var ver *Version
+	for idx := range p.Versions {
+		if p.Versions[idx].Version == v.Version {
+			ver = &p.Versions[idx]
+		}
+	}
+	if ver == nil {
+		p.Versions = append(p.Versions, Version{Version: v.Version, Channel: v.Channel,
+			AngularDetected: v.AngularDetected, GrafanaDependency: v.GrafanaDependency, Arch: map[string]ArchMeta{}})
+		ver = &p.Versions[len(p.Versions)-1]
+	}
+	ver.Arch[arch] = ArchMeta{SHA256: sum}
+	b.sums[rel] = sum
+}
+
+// writeIndex writes the index of the bundle, listing the versions of each plugin newest first, and the checksums.
+func (b *offlineBundle) writeIndex() error {
+	bundleIndex := PluginRepo{}
+	for _, p := range b.plugins {
+		repo.SortVersions(p.Versions)
+		bundleIndex.Plugins = append(bundleIndex.Plugins, *p)
+	}
+	sort.Slice(bundleIndex.Plugins, func(a, c int) bool {
+		return bundleIndex.Plugins[a].ID < bundleIndex.Plugins[c].ID
+	})
+
+	index, err := json.MarshalIndent(bundleIndex, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(b.dir, repo.StaticIndexFile), index, 0640); err != nil {
+		return errutil.Wrap("failed to write bundle index", err)
+	}
+	sum, err := plugins.FileSHA256(filepath.Join(b.dir, repo.StaticIndexFile))
+	if err != nil {
+		return err
+	}
+	b.sums[repo.StaticIndexFile] = sum
+
+	names := make([]string, 0, len(b.sums))
+	for name := range b.sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sums strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sums, "%s  %s\n", b.sums[name], name)
+	}
+	if err := ioutil.WriteFile(filepath.Join(b.dir, bundleSumsFile), []byte(sums.String()), 0640); err != nil {
+		return errutil.Wrap("failed to write bundle checksums", err)
+	}
+	return nil
+}
+
+// writeBundleTarball writes the files of the directory to a gzipped tarball, in a stable order.
+func writeBundleTarball(dir, tarballPath string) (err error) {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(tarballPath), ".plugin-bundle-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmpFile.Close()
+			_ = os.Remove(tmpFile.Name())
+		}
+	}()
+
+	gw := gzip.NewWriter(tmpFile)
+	tw := tar.NewWriter(gw)
+	err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		// nolint:gosec
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = gw.Close(); err != nil {
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), tarballPath)
+}
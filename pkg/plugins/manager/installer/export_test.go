@@ -0,0 +1,173 @@
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportBundle(t *testing.T) {
+	newInstaller := func() *Installer {
+		return NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+	}
+
+	t.Run("Should export plugins and dependencies for all platforms", func(t *testing.T) {
+		repoDir := writeTestBundleRepo(t, "linux-amd64", "darwin-arm64")
+		bundleDir := filepath.Join(t.TempDir(), "bundle")
+
+		err := newInstaller().ExportBundle([]string{"test-app"}, bundleDir, repoDir,
+			[]string{"linux-amd64", "darwin-arm64"})
+		require.NoError(t, err)
+
+		var files []string
+		require.NoError(t, filepath.Walk(bundleDir, func(p string, fi os.FileInfo, err error) error {
+			if err == nil && !fi.IsDir() {
+				rel, _ := filepath.Rel(bundleDir, p)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return err
+		}))
+		sort.Strings(files)
+		assert.Equal(t, []string{
+			bundleSumsFile,
+			"index.json",
+			"test-app/1.0.0/test-app-1.0.0.darwin-arm64.zip",
+			"test-app/1.0.0/test-app-1.0.0.linux-amd64.zip",
+			"test-panel/2.0.0/test-panel-2.0.0.zip",
+		}, files)
+
+		data, err := ioutil.ReadFile(filepath.Join(bundleDir, "index.json"))
+		require.NoError(t, err)
+		var index PluginRepo
+		require.NoError(t, json.Unmarshal(data, &index))
+		require.Len(t, index.Plugins, 2)
+		assert.Equal(t, "test-app", index.Plugins[0].ID)
+		assert.Len(t, index.Plugins[0].Versions[0].Arch, 2)
+		assert.Equal(t, "test-panel", index.Plugins[1].ID)
+		require.Len(t, index.Plugins[1].Versions, 1)
+		assert.Equal(t, "2.0.0", index.Plugins[1].Versions[0].Version)
+
+		sums, err := ioutil.ReadFile(filepath.Join(bundleDir, bundleSumsFile))
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(sums)), "\n")
+		assert.Len(t, lines, 4)
+		assert.Contains(t, lines, sha256Hex(t, filepath.Join(bundleDir, "index.json"))+"  index.json")
+	})
+
+	t.Run("Should install from exported bundle", func(t *testing.T) {
+		bundleDir := filepath.Join(t.TempDir(), "bundle")
+		require.NoError(t, newInstaller().ExportBundle([]string{"test-panel@2.0.0"}, bundleDir, writeTestBundleRepo(t, "linux-amd64", "darwin-arm64"), nil))
+
+		pluginsDir := t.TempDir()
+		require.NoError(t, newInstaller().Install("test-panel", "", pluginsDir, "", bundleDir))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+	})
+
+	t.Run("Should export bundle as tarball", func(t *testing.T) {
+		tarball := filepath.Join(t.TempDir(), "bundle.tar.gz")
+		require.NoError(t, newInstaller().ExportBundle([]string{"test-panel@2.0.0"}, tarball, writeTestBundleRepo(t, "linux-amd64", "darwin-arm64"),
+			[]string{"linux-amd64"}))
+
+		f, err := os.Open(tarball)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, f.Close()) }()
+		gr, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		var names []string
+		tr := tar.NewReader(gr)
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				break
+			}
+			names = append(names, hdr.Name)
+		}
+		assert.Contains(t, names, "index.json")
+		assert.Contains(t, names, bundleSumsFile)
+		assert.Contains(t, names, "test-panel/2.0.0/test-panel-2.0.0.zip")
+	})
+
+	t.Run("Should fail for unsupported platform", func(t *testing.T) {
+		err := newInstaller().ExportBundle([]string{"test-app"}, filepath.Join(t.TempDir(), "bundle"),
+			writeTestBundleRepo(t, "linux-amd64", "darwin-arm64"), []string{"windows-amd64"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "test-app has no version supported on windows-amd64")
+
+		err = newInstaller().ExportBundle([]string{"test-app"}, t.TempDir(), writeTestBundleRepo(t, "linux-amd64", "darwin-arm64"), []string{"linux"})
+		require.Error(t, err)
+	})
+
+	t.Run("Should refuse non-empty bundle directory", func(t *testing.T) {
+		err := newInstaller().ExportBundle([]string{"test-panel"}, writeTestBundleRepo(t, "linux-amd64", "darwin-arm64"), writeTestBundleRepo(t, "linux-amd64", "darwin-arm64"), nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "isn't empty")
+	})
+}
+
+// writeTestBundleRepo writes a static repository with an app, available for the platforms, depending on a panel
+// available for any platform.
+func writeTestBundleRepo(t *testing.T, platforms ...string) string {
+	repoDir := t.TempDir()
+	writeArchive := func(pluginID, version, name, pluginJSON string) string {
+		data, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}))
+		require.NoError(t, err)
+		dir := filepath.Join(repoDir, pluginID, version)
+		require.NoError(t, os.MkdirAll(dir, 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0600))
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	appJSON := `{"id":"test-app","type":"app","name":"Test","info":{"version":"1.0.0"},
+		"dependencies":{"plugins":[{"id":"test-panel","type":"panel","version":"^2.0.0"}]}}`
+	appArch := map[string]map[string]string{}
+	for _, platform := range platforms {
+		appArch[platform] = map[string]string{
+			"sha256": writeArchive("test-app", "1.0.0", "test-app-1.0.0."+platform+".zip", appJSON),
+		}
+	}
+	panel := writeArchive("test-panel", "2.0.0", "test-panel-2.0.0.zip",
+		`{"id":"test-panel","type":"panel","name":"Test","info":{"version":"2.0.0"}}`)
+
+	index, err := json.Marshal(map[string]interface{}{"plugins": []interface{}{
+		map[string]interface{}{"id": "test-app", "versions": []interface{}{
+			map[string]interface{}{"version": "1.0.0", "arch": appArch},
+		}},
+		map[string]interface{}{"id": "test-panel", "versions": []interface{}{
+			map[string]interface{}{"version": "3.0.0"},
+			map[string]interface{}{"version": "2.0.0", "arch": map[string]interface{}{"any": map[string]string{"sha256": panel}}},
+		}},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repoDir, "index.json"), index, 0600))
+	return repoDir
+}
+
+func sha256Hex(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSortVersions(t *testing.T) {
+	for _, versions := range [][]Version{
+		{{Version: "1.0.0"}, {Version: "2.0.0-beta.1"}, {Version: "v1.0.0"}, {Version: "2.0.0"}},
+		{{Version: "v1.0.0"}, {Version: "2.0.0"}, {Version: "1.0.0"}, {Version: "2.0.0-beta.1"}},
+	} {
+		repo.SortVersions(versions)
+		assert.Equal(t, []Version{{Version: "2.0.0"}, {Version: "2.0.0-beta.1"}, {Version: "v1.0.0"},
+			{Version: "1.0.0"}}, versions)
+	}
+}
@@ -0,0 +1,120 @@
+package installer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// ErrFIPSUnavailable is returned in FIPS mode when Grafana wasn't built with FIPS validated crypto.
+var ErrFIPSUnavailable = errors.New("FIPS mode requires a Grafana build using BoringCrypto")
+
+// fipsCipherSuites are the FIPS approved TLS 1.2 cipher suites. The TLS 1.3 cipher suites aren't configurable,
+// which is why FIPS mode is limited to TLS 1.2.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsHashes are the FIPS approved hash algorithms signatures may use.
+var fipsHashes = map[crypto.Hash]bool{
+	crypto.SHA224: true,
+	crypto.SHA256: true,
+	crypto.SHA384: true,
+	crypto.SHA512: true,
+}
+
+// checkFIPS returns ErrFIPSUnavailable if FIPS mode is enabled, but the crypto in use isn't FIPS validated.
+func (i *Installer) checkFIPS() error {
+	if i.opts.FIPSMode && !fipsCryptoEnabled() {
+		return ErrFIPSUnavailable
+	}
+	return nil
+}
+
+// checkFIPSKey rejects public keys that aren't FIPS approved in FIPS mode.
+func (i *Installer) checkFIPSKey(pub crypto.PublicKey) error {
+	if !i.opts.FIPSMode {
+		return nil
+	}
+	return checkFIPSPublicKey(pub)
+}
+
+// checkFIPSSignature rejects OpenPGP signatures that aren't FIPS approved in FIPS mode.
+func (i *Installer) checkFIPSSignature(signature []byte) error {
+	if !i.opts.FIPSMode {
+		return nil
+	}
+	return checkFIPSOpenPGPSignature(signature)
+}
+
+// applyFIPSTLS restricts the TLS configuration to FIPS approved protocol versions, cipher suites and curves.
+func applyFIPSTLS(tlsConfig *tls.Config) {
+	tlsConfig.MinVersion = tls.VersionTLS12
+	tlsConfig.MaxVersion = tls.VersionTLS12
+	tlsConfig.CipherSuites = fipsCipherSuites
+	tlsConfig.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+}
+
+// checkFIPSPublicKey rejects public keys of algorithms and sizes that aren't FIPS approved, like Ed25519 keys or
+// RSA keys shorter than 2048 bits.
+func checkFIPSPublicKey(pub crypto.PublicKey) error {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return fmt.Errorf("%d bit RSA keys aren't allowed in FIPS mode", k.N.BitLen())
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+			return nil
+		}
+		return fmt.Errorf("ECDSA curve %s isn't allowed in FIPS mode", k.Curve.Params().Name)
+	}
+	return fmt.Errorf("%T keys aren't allowed in FIPS mode", pub)
+}
+
+// checkFIPSOpenPGPSignature rejects armored or binary OpenPGP signatures made with algorithms that aren't FIPS
+// approved, like SHA1 or EdDSA signatures.
+func checkFIPSOpenPGPSignature(signature []byte) error {
+	var r io.Reader = bytes.NewReader(signature)
+	if block, err := armor.Decode(bytes.NewReader(signature)); err == nil {
+		r = block.Body
+	}
+
+	p, err := packet.Read(r)
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+	var hash crypto.Hash
+	var algo packet.PublicKeyAlgorithm
+	switch sig := p.(type) {
+	case *packet.Signature:
+		hash, algo = sig.Hash, sig.PubKeyAlgo
+	case *packet.SignatureV3:
+		hash, algo = sig.Hash, sig.PubKeyAlgo
+	default:
+		return errors.New("no signature found")
+	}
+
+	if !fipsHashes[hash] {
+		return fmt.Errorf("signatures using %s aren't allowed in FIPS mode", hash)
+	}
+	switch algo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly, packet.PubKeyAlgoECDSA:
+		return nil
+	}
+	return fmt.Errorf("OpenPGP public key algorithm %d isn't allowed in FIPS mode", algo)
+}
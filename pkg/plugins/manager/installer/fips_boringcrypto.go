@@ -0,0 +1,10 @@
+// +build boringcrypto
+
+package installer
+
+import "crypto/boring"
+
+// fipsCryptoEnabled reports whether crypto operations go through the FIPS validated BoringCrypto module.
+func fipsCryptoEnabled() bool {
+	return boring.Enabled()
+}
@@ -0,0 +1,9 @@
+// +build !boringcrypto
+
+package installer
+
+// fipsCryptoEnabled reports whether crypto operations go through the FIPS validated BoringCrypto module, which
+// requires building Grafana with the boringcrypto build tag and Go toolchain.
+func fipsCryptoEnabled() bool {
+	return false
+}
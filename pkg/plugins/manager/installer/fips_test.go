@@ -0,0 +1,66 @@
+package installer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestFIPSMode(t *testing.T) {
+	t.Run("Should refuse to run without FIPS validated crypto", func(t *testing.T) {
+		if fipsCryptoEnabled() {
+			t.Skip("built with BoringCrypto")
+		}
+		i := &Installer{log: &fakeLogger{}, opts: Opts{FIPSMode: true}}
+
+		err := i.Install("test-panel", "", t.TempDir(), writeTestTarGz(t, nil, nil), "")
+		require.ErrorIs(t, err, ErrFIPSUnavailable)
+	})
+
+	t.Run("Should restrict TLS to FIPS approved ciphers", func(t *testing.T) {
+		tlsConfig, err := makeTLSConfig(false, "", "", "", nil, true)
+		require.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MaxVersion)
+		assert.Equal(t, fipsCipherSuites, tlsConfig.CipherSuites)
+	})
+
+	t.Run("Should reject keys that aren't FIPS approved", func(t *testing.T) {
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		require.NoError(t, checkFIPSPublicKey(&ecKey.PublicKey))
+
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+		require.NoError(t, err)
+		require.Error(t, checkFIPSPublicKey(&rsaKey.PublicKey))
+
+		edKey, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		require.Error(t, checkFIPSPublicKey(edKey))
+	})
+
+	t.Run("Should reject OpenPGP signatures using SHA1", func(t *testing.T) {
+		_, entity := newManifestTestInstaller(t)
+		sign := func(hash crypto.Hash) []byte {
+			sig := new(bytes.Buffer)
+			require.NoError(t, openpgp.ArmoredDetachSign(sig, entity, bytes.NewBufferString("data"),
+				&packet.Config{DefaultHash: hash}))
+			return sig.Bytes()
+		}
+
+		require.NoError(t, checkFIPSOpenPGPSignature(sign(crypto.SHA256)))
+		err := checkFIPSOpenPGPSignature(sign(crypto.SHA1))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "FIPS mode")
+	})
+}
@@ -0,0 +1,33 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
+// RepoAPIVersion is the version of the grafana.com plugins API plugin metadata is fetched with, see
+// repo.APIVersion.
+type RepoAPIVersion = repo.APIVersion
+
+const (
+	// RepoAPILegacy only uses the legacy <repo>/repo/<plugin id> endpoint.
+	RepoAPILegacy = repo.APILegacy
+	// RepoAPIAuto uses the v2 API, falling back to the legacy API if the plugin repository doesn't support it.
+	RepoAPIAuto = repo.APIAuto
+	// RepoAPIV2 only uses the v2 API.
+	RepoAPIV2 = repo.APIV2
+)
+
+// ParseRepoAPIVersion returns the plugins API version with the provided name. An empty name returns
+// RepoAPILegacy.
+func ParseRepoAPIVersion(name string) (RepoAPIVersion, error) {
+	switch v := RepoAPIVersion(strings.ToLower(name)); v {
+	case RepoAPILegacy, "legacy":
+		return RepoAPILegacy, nil
+	case RepoAPIAuto, RepoAPIV2:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown plugins API version %q, valid versions are auto, legacy and v2", name)
+}
@@ -0,0 +1,87 @@
+package installer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoAPIv2(t *testing.T) {
+	newServer := func(t *testing.T, routes map[string]string) (string, *[]string) {
+		var requests []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.URL.RequestURI())
+			body, exists := routes[r.URL.RequestURI()]
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+		return server.URL + "/api/plugins", &requests
+	}
+
+	t.Run("Should fetch all pages of versions", func(t *testing.T) {
+		sum := strings.Repeat("ab", 32)
+		repoURL, requests := newServer(t, map[string]string{
+			"/api/plugins/test-panel": `{"slug": "test-panel", "typeCode": "panel", "signatureType": "community",
+				"status": "deprecated"}`,
+			"/api/plugins/test-panel/versions": `{"items": [{"version": "2.0.0", "grafanaDependency": ">=8.0.0",
+				"packages": {"any": {"sha256": "` + sum + `"}}}], "links": [{"rel": "next", "href": "versions?page=2"}]}`,
+			"/api/plugins/test-panel/versions?page=2": `{"items": [{"version": "1.0.0", "angularDetected": true}],
+				"links": [{"rel": "self", "href": "versions?page=2"}]}`,
+		})
+		i := NewWithOpts(Opts{RepoAPIVersion: RepoAPIV2}, "8.0.0", &fakeLogger{})
+
+		plugin, err := i.getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		require.NoError(t, err)
+		assert.Equal(t, Plugin{
+			ID:            "test-panel",
+			Category:      "panel",
+			SignatureType: "community",
+			Status:        "deprecated",
+			Versions: []Version{
+				{Version: "2.0.0", GrafanaDependency: ">=8.0.0", Arch: map[string]ArchMeta{"any": {SHA256: sum}}},
+				{Version: "1.0.0", AngularDetected: true},
+			},
+		}, plugin)
+		assert.Len(t, *requests, 3)
+	})
+
+	t.Run("Should fall back to legacy API", func(t *testing.T) {
+		repoURL, requests := newServer(t, map[string]string{
+			"/api/plugins/repo/test-panel": `{"id": "test-panel", "versions": [{"version": "1.0.0"}]}`,
+		})
+
+		i := NewWithOpts(Opts{RepoAPIVersion: RepoAPIAuto}, "8.0.0", &fakeLogger{})
+		plugin, err := i.getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		require.NoError(t, err)
+		assert.Equal(t, "1.0.0", plugin.Versions[0].Version)
+		assert.Equal(t, []string{"/api/plugins/test-panel", "/api/plugins/repo/test-panel"}, *requests)
+
+		i = NewWithOpts(Opts{RepoAPIVersion: RepoAPIV2}, "8.0.0", &fakeLogger{})
+		_, err = i.getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		var notFound *PluginNotFoundError
+		require.ErrorAs(t, err, &notFound)
+	})
+
+	t.Run("Should parse API version", func(t *testing.T) {
+		for name, expected := range map[string]RepoAPIVersion{
+			"":       RepoAPILegacy,
+			"legacy": RepoAPILegacy,
+			"Auto":   RepoAPIAuto,
+			"v2":     RepoAPIV2,
+		} {
+			v, err := ParseRepoAPIVersion(name)
+			require.NoError(t, err)
+			assert.Equal(t, expected, v)
+		}
+		_, err := ParseRepoAPIVersion("v3")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,55 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// checksumHeader is the header Artifactory reports the SHA256 checksum of a downloaded artifact in.
+const checksumHeader = "X-Checksum-Sha256"
+
+// genericRepoChecksum returns the checksum of an archive in a generic repository, which Artifactory reports in the
+// X-Checksum-Sha256 header and both Artifactory and Nexus publish as <archive>.sha256. The checksum is empty if
+// the repository provides neither.
+func (i *Installer) genericRepoChecksum(archiveURL string) (string, error) {
+	req, err := i.createRequest(archiveURL)
+	if err != nil {
+		return "", err
+	}
+	req.Method = http.MethodHead
+	client, err := i.clientFor(req.URL, false)
+	if err != nil {
+		return "", err
+	}
+	res, err := i.doWithRetry(client, req, isRetryable)
+	if err != nil {
+		return "", errutil.Wrapf(RedactURLError(err), "failed to fetch checksum of %s", RedactURL(archiveURL))
+	}
+	if err := res.Body.Close(); err != nil {
+		i.log.Warn("Failed to close response body", "err", err)
+	}
+	if sum := res.Header.Get(checksumHeader); res.StatusCode/100 == 2 && sum != "" {
+		c, err := parseChecksum(sum)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s header of %s: %w", checksumHeader, RedactURL(archiveURL), err)
+		}
+		return c.String(), nil
+	}
+
+	i.log.Debugf("Fetching checksum from %s", RedactURL(archiveURL+checksumFileSuffix))
+	body, err := i.readCompanionFile(archiveURL + checksumFileSuffix)
+	if errors.Is(err, ErrNotFoundError) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errutil.Wrapf(err, "failed to fetch checksum file %s", RedactURL(archiveURL+checksumFileSuffix))
+	}
+	checksum, err := parseChecksumFile(body)
+	if err != nil {
+		return "", errutil.Wrapf(err, "invalid checksum file %s", RedactURL(archiveURL+checksumFileSuffix))
+	}
+	return checksum, nil
+}
@@ -0,0 +1,132 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenericRepo(t *testing.T) {
+	newInstaller := func() *Installer {
+		return NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+	}
+	archive := writeTestTarGz(t, nil, map[string]string{
+		"plugin.json": `{"id":"test-panel","type":"panel","name":"Test","info":{"version":"1.1.0"}}`,
+	})
+	archiveData, err := ioutil.ReadFile(archive)
+	require.NoError(t, err)
+	sum := sha256Hex(t, archive)
+
+	// newServer serves the archive as version 1.1.0 of test-panel, along with the files.
+	newServer := func(t *testing.T, files map[string]string, headers map[string]string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/artifactory/plugins/test-panel/1.1.0/test-panel-1.1.0.zip" {
+				for name, value := range headers {
+					w.Header().Set(name, value)
+				}
+				_, _ = w.Write(archiveData)
+				return
+			}
+			content, exists := files[r.URL.Path]
+			if !exists {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(content))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	artifactoryListing := map[string]string{
+		"/artifactory/plugins/test-panel/": `<html><body><pre><a href="../">../</a>
+			<a href="1.0.0/">1.0.0/</a> <a href="1.1.0/">1.1.0/</a> <a href="notes.txt">notes.txt</a></pre></body></html>`,
+	}
+
+	t.Run("Should install from directory listing using checksum header", func(t *testing.T) {
+		server := newServer(t, artifactoryListing, map[string]string{checksumHeader: sum})
+		repoURL := "generic+" + server.URL + "/artifactory/plugins"
+
+		versions, err := newInstaller().ListVersions("test-panel", repoURL)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, "1.1.0", versions[0].Version)
+		assert.Equal(t, "1.0.0", versions[1].Version)
+
+		pluginsDir := t.TempDir()
+		require.NoError(t, newInstaller().Install("test-panel", "", pluginsDir, "", repoURL))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+	})
+
+	t.Run("Should install from maven metadata using checksum file", func(t *testing.T) {
+		server := newServer(t, map[string]string{
+			"/artifactory/plugins/test-panel/maven-metadata.xml": `<metadata><groupId>plugins</groupId>
+				<versioning><versions><version>1.1.0</version><version>1.0.0</version></versions></versioning>
+				</metadata>`,
+			"/artifactory/plugins/test-panel/1.1.0/test-panel-1.1.0.zip.sha256": sum + "  test-panel-1.1.0.zip\n",
+		}, nil)
+
+		checksum, err := newInstaller().genericRepoChecksum(
+			server.URL + "/artifactory/plugins/test-panel/1.1.0/test-panel-1.1.0.zip")
+		require.NoError(t, err)
+		assert.Equal(t, sum, checksum)
+
+		pluginsDir := t.TempDir()
+		require.NoError(t, newInstaller().Install("test-panel", "", pluginsDir, "",
+			"generic+"+server.URL+"/artifactory/plugins/"))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+	})
+
+	t.Run("Should prefer metadata.json", func(t *testing.T) {
+		files := map[string]string{
+			"/artifactory/plugins/test-panel/metadata.json": `{"versions":[{"version":"1.0.0"},
+				{"version":"1.1.0","arch":{"any":{"sha256":"` + sum + `"}}}]}`,
+		}
+		for name, content := range artifactoryListing {
+			files[name] = content
+		}
+		server := newServer(t, files, nil)
+
+		plugin, err := newInstaller().GetPlugin("test-panel", "generic+"+server.URL+"/artifactory/plugins")
+		require.NoError(t, err)
+		assert.Equal(t, "test-panel", plugin.ID)
+		require.Len(t, plugin.Versions, 2)
+		assert.Equal(t, sum, plugin.Versions[0].Arch["any"].SHA256)
+
+		latest, err := newInstaller().LatestVersions([]string{"test-panel", "other-panel"},
+			"generic+"+server.URL+"/artifactory/plugins")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"test-panel": "1.1.0"}, latest)
+	})
+
+	t.Run("Should fail for checksum header not matching archive", func(t *testing.T) {
+		server := newServer(t, artifactoryListing, map[string]string{checksumHeader: sha256Hex(t, writeTestTarGz(t,
+			nil, map[string]string{"plugin.json": "{}"}))})
+
+		err := newInstaller().Install("test-panel", "", t.TempDir(), "", "generic+"+server.URL+"/artifactory/plugins")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum does not match")
+	})
+
+	t.Run("Should fail for unknown plugin", func(t *testing.T) {
+		server := newServer(t, artifactoryListing, nil)
+
+		_, err := newInstaller().GetPlugin("other-panel", "generic+"+server.URL+"/artifactory/plugins")
+		var notFound *PluginNotFoundError
+		require.ErrorAs(t, err, &notFound)
+	})
+
+	t.Run("Should authenticate with credentials of generic repository", func(t *testing.T) {
+		i := NewWithOpts(Opts{RepoCredentials: map[string]RepoCredentials{
+			"generic+https://artifacts.corp/artifactory/plugins": {Token: "token"},
+		}}, "8.0.0", &fakeLogger{})
+
+		req, err := i.createRequest("https://artifacts.corp/artifactory/plugins/test-panel/1.0.0/test-panel-1.0.0.zip")
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	})
+}
@@ -0,0 +1,77 @@
+package installer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	gitLabScheme     = "gitlab"
+	defaultGitLabURL = "https://gitlab.com"
+)
+
+// gitLabPackageURL translates a gitlab://group/project/package/version/file source into the
+// URL of the file in GitLab's generic package registry. Any number of (sub)groups may precede
+// the project, the last three path segments always name the package, version and file.
+func (i *Installer) gitLabPackageURL(u *url.URL) (*url.URL, error) {
+	segments := strings.Split(strings.Trim(u.Host+u.Path, "/"), "/")
+	if len(segments) < 5 {
+		return nil, fmt.Errorf("invalid GitLab package source %q, expected gitlab://group/project/package/version/file",
+			u.String())
+	}
+	for _, s := range segments {
+		if s == "" || s == "." || s == ".." {
+			return nil, fmt.Errorf("invalid GitLab package source %q", u.String())
+		}
+	}
+
+	n := len(segments)
+	project := strings.Join(segments[:n-3], "/")
+
+	baseURL := i.opts.GitLabURL
+	if baseURL == "" {
+		baseURL = defaultGitLabURL
+	}
+	apiURL, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitLab URL %q: %w", RedactURL(baseURL), RedactURLError(err))
+	}
+
+	// The project path has to be URL encoded as a single path segment, see
+	// https://docs.gitlab.com/ee/api/index.html#namespaced-path-encoding
+	escaped := []string{"api", "v4", "projects", url.PathEscape(project), "packages", "generic"}
+	for _, s := range segments[n-3:] {
+		escaped = append(escaped, url.PathEscape(s))
+	}
+	apiURL.RawPath = apiURL.EscapedPath() + "/" + strings.Join(escaped, "/")
+	apiURL.Path, err = url.PathUnescape(apiURL.RawPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiURL, nil
+}
+
+// setGitLabAuth adds the configured GitLab credentials to the request. Access tokens take
+// precedence over CI job tokens.
+func (i *Installer) setGitLabAuth(req *http.Request) {
+	if token := firstNonEmpty(i.opts.GitLabToken, os.Getenv("GITLAB_TOKEN")); token != "" {
+		req.Header.Set("PRIVATE-TOKEN", token)
+		return
+	}
+	if token := firstNonEmpty(i.opts.GitLabJobToken, os.Getenv("CI_JOB_TOKEN")); token != "" {
+		req.Header.Set("JOB-TOKEN", token)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
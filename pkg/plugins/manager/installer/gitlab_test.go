@@ -0,0 +1,73 @@
+package installer
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitLabPackageURL(t *testing.T) {
+	t.Run("Should resolve package on gitlab.com", func(t *testing.T) {
+		i := &Installer{}
+		u, err := url.Parse("gitlab://my-group/my-project/my-panel/1.0.0/my-panel-1.0.0.zip")
+		require.NoError(t, err)
+
+		res, err := i.gitLabPackageURL(u)
+		require.NoError(t, err)
+		assert.Equal(t,
+			"https://gitlab.com/api/v4/projects/my-group%2Fmy-project/packages/generic/my-panel/1.0.0/my-panel-1.0.0.zip",
+			res.String())
+	})
+
+	t.Run("Should resolve package in subgroup on self-hosted instance", func(t *testing.T) {
+		i := &Installer{opts: Opts{GitLabURL: "https://git.example.com/gitlab/"}}
+		u, err := url.Parse("gitlab://group/subgroup/project/panel/2.0.0/panel.zip")
+		require.NoError(t, err)
+
+		res, err := i.gitLabPackageURL(u)
+		require.NoError(t, err)
+		assert.Equal(t,
+			"https://git.example.com/gitlab/api/v4/projects/group%2Fsubgroup%2Fproject/packages/generic/panel/2.0.0/panel.zip",
+			res.String())
+	})
+
+	t.Run("Should fail when the source is incomplete", func(t *testing.T) {
+		i := &Installer{}
+		u, err := url.Parse("gitlab://group/project/panel.zip")
+		require.NoError(t, err)
+
+		_, err = i.gitLabPackageURL(u)
+		require.Error(t, err)
+	})
+}
+
+func TestSetGitLabAuth(t *testing.T) {
+	t.Run("Should prefer access token over job token", func(t *testing.T) {
+		i := &Installer{opts: Opts{GitLabToken: "access", GitLabJobToken: "job"}}
+		req, err := http.NewRequest(http.MethodGet, "https://gitlab.com", nil)
+		require.NoError(t, err)
+
+		i.setGitLabAuth(req)
+		assert.Equal(t, "access", req.Header.Get("PRIVATE-TOKEN"))
+		assert.Empty(t, req.Header.Get("JOB-TOKEN"))
+	})
+
+	t.Run("Should use job token when no access token is set", func(t *testing.T) {
+		if token, ok := os.LookupEnv("GITLAB_TOKEN"); ok {
+			require.NoError(t, os.Unsetenv("GITLAB_TOKEN"))
+			t.Cleanup(func() {
+				require.NoError(t, os.Setenv("GITLAB_TOKEN", token))
+			})
+		}
+		i := &Installer{opts: Opts{GitLabJobToken: "job"}}
+		req, err := http.NewRequest(http.MethodGet, "https://gitlab.com", nil)
+		require.NoError(t, err)
+
+		i.setGitLabAuth(req)
+		assert.Equal(t, "job", req.Header.Get("JOB-TOKEN"))
+	})
+}
@@ -0,0 +1,184 @@
+package installer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
+// RepoHealth describes whether a plugin repository can be reached from this host, and how.
+type RepoHealth struct {
+	URL       string `json:"url"`
+	Reachable bool   `json:"reachable"`
+	// StatusCode is the status code the repository responded with, if any.
+	StatusCode int `json:"statusCode,omitempty"`
+	// Proxy is the redacted URL of the proxy the request was sent through, empty for direct connections.
+	Proxy string `json:"proxy,omitempty"`
+	// TLS describes the TLS connection to the repository, nil for plain HTTP and local repositories.
+	TLS *RepoTLSHealth `json:"tls,omitempty"`
+	// DNS, Connect and TLSHandshake are the durations of the phases of the connection, zero when the connection
+	// was reused or the phase didn't happen. Latency is the time until the first response byte.
+	DNS          time.Duration `json:"dnsNs,omitempty"`
+	Connect      time.Duration `json:"connectNs,omitempty"`
+	TLSHandshake time.Duration `json:"tlsHandshakeNs,omitempty"`
+	Latency      time.Duration `json:"latencyNs"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// RepoTLSHealth describes the TLS connection to a plugin repository.
+type RepoTLSHealth struct {
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipherSuite"`
+	ServerName  string    `json:"serverName"`
+	Subject     string    `json:"subject,omitempty"`
+	Issuer      string    `json:"issuer,omitempty"`
+	NotAfter    time.Time `json:"notAfter,omitempty"`
+}
+
+func (h RepoHealth) String() string {
+	if !h.Reachable {
+		return fmt.Sprintf("%s: unreachable: %s", h.URL, h.Error)
+	}
+	var details []string
+	if h.StatusCode != 0 {
+		details = append(details, fmt.Sprintf("status %d", h.StatusCode))
+	}
+	if h.Proxy != "" {
+		details = append(details, "via proxy "+h.Proxy)
+	} else if h.StatusCode != 0 {
+		details = append(details, "direct")
+	}
+	if h.TLS != nil {
+		details = append(details, fmt.Sprintf("%s %s", h.TLS.Version, h.TLS.CipherSuite))
+		if !h.TLS.NotAfter.IsZero() {
+			details = append(details, "certificate valid until "+h.TLS.NotAfter.Format("2006-01-02"))
+		}
+	}
+	details = append(details, fmt.Sprintf("latency %s", h.Latency.Round(time.Millisecond)))
+	s := fmt.Sprintf("%s: reachable (%s)", h.URL, strings.Join(details, ", "))
+	if h.Error != "" {
+		s += ": " + h.Error
+	}
+	return s
+}
+
+// CheckRepoReachable sends a HEAD request to the plugin repository, with the credentials, proxy and TLS settings
+// that installing from it uses, and reports how the connection went. Any response other than a server error
+// means the repository is reachable, rejected credentials are reported as an error of a reachable repository.
+// Local static repositories are checked on disk.
+func (i *Installer) CheckRepoReachable(repoURL string) RepoHealth {
+	repoURL = strings.TrimSuffix(strings.TrimSpace(repoURL), "/")
+	health := RepoHealth{URL: RedactURL(repoURL)}
+
+	target := repoURL
+	if baseURL, generic := repo.GenericBase(repoURL); generic {
+		target = baseURL
+	} else if index, static := repo.StaticIndex(repoURL); static {
+		target = index
+		if u, err := url.Parse(index); err != nil || len(u.Scheme) <= 1 {
+			start := time.Now()
+			_, err := os.Stat(index)
+			health.Latency = time.Since(start)
+			if err != nil {
+				health.Error = err.Error()
+				return health
+			}
+			health.Reachable = true
+			return health
+		}
+	}
+
+	req, err := i.createRequest(target)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	req.Method = http.MethodHead
+	client, err := i.clientFor(req.URL, false)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	if tr := httpTransport(client.Transport); tr != nil && tr.Proxy != nil {
+		if proxyURL, err := tr.Proxy(req); err == nil && proxyURL != nil {
+			health.Proxy = RedactURL(proxyURL.String())
+		}
+	}
+
+	var start, dnsStart, connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { health.DNS = time.Since(dnsStart) },
+		ConnectStart:      func(string, string) { connectStart = time.Now() },
+		ConnectDone:       func(string, string, error) { health.Connect = time.Since(connectStart) },
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			health.TLSHandshake = time.Since(tlsStart)
+		},
+		GotFirstResponseByte: func() { health.Latency = time.Since(start) },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start = time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		health.Latency = time.Since(start)
+		health.Error = RedactURLError(err).Error()
+		return health
+	}
+	if err := res.Body.Close(); err != nil {
+		i.log.Warn("Failed to close response body", "err", err)
+	}
+
+	health.StatusCode = res.StatusCode
+	health.TLS = repoTLSHealth(res.TLS)
+	switch {
+	case res.StatusCode >= 500:
+		health.Error = "repository responded with " + res.Status
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		health.Reachable = true
+		health.Error = "repository rejected the credentials with " + res.Status
+	default:
+		health.Reachable = true
+	}
+	return health
+}
+
+func repoTLSHealth(state *tls.ConnectionState) *RepoTLSHealth {
+	if state == nil {
+		return nil
+	}
+	h := &RepoTLSHealth{
+		Version:     tlsVersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ServerName:  state.ServerName,
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		h.Subject = cert.Subject.String()
+		h.Issuer = cert.Issuer.String()
+		h.NotAfter = cert.NotAfter
+	}
+	return h
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
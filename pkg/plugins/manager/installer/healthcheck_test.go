@@ -0,0 +1,94 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRepoReachable(t *testing.T) {
+	t.Run("Should report reachable repository", func(t *testing.T) {
+		var method string
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method = r.Method
+		}))
+		t.Cleanup(server.Close)
+		i := NewWithOpts(Opts{SkipTLSVerify: true}, "8.0.0", &fakeLogger{})
+
+		health := i.CheckRepoReachable(server.URL + "/api/plugins")
+		assert.True(t, health.Reachable)
+		assert.Equal(t, http.MethodHead, method)
+		assert.Equal(t, http.StatusOK, health.StatusCode)
+		assert.Empty(t, health.Error)
+		assert.Empty(t, health.Proxy)
+		require.NotNil(t, health.TLS)
+		assert.NotEmpty(t, health.TLS.Version)
+		assert.NotEmpty(t, health.TLS.CipherSuite)
+		assert.False(t, health.TLS.NotAfter.IsZero())
+		assert.True(t, health.Latency > 0)
+	})
+
+	t.Run("Should report proxy", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		t.Cleanup(server.Close)
+		i := NewWithOpts(Opts{RepoProxies: map[string]RepoProxy{
+			"http://plugins.example.com": {URL: "http://user:secret@" + server.Listener.Addr().String()},
+		}}, "8.0.0", &fakeLogger{})
+
+		health := i.CheckRepoReachable("http://plugins.example.com/api/plugins")
+		assert.True(t, health.Reachable)
+		assert.Equal(t, "http://REDACTED@"+server.Listener.Addr().String(), health.Proxy)
+		assert.Nil(t, health.TLS)
+	})
+
+	t.Run("Should report unreachable repository", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		t.Cleanup(server.Close)
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+
+		health := i.CheckRepoReachable(server.URL)
+		assert.False(t, health.Reachable)
+		assert.Equal(t, http.StatusBadGateway, health.StatusCode)
+		assert.Equal(t, "repository responded with 502 Bad Gateway", health.Error)
+
+		server.Close()
+		health = i.CheckRepoReachable(server.URL)
+		assert.False(t, health.Reachable)
+		assert.Zero(t, health.StatusCode)
+		assert.NotEmpty(t, health.Error)
+	})
+
+	t.Run("Should report rejected credentials", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		t.Cleanup(server.Close)
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+
+		health := i.CheckRepoReachable(server.URL)
+		assert.True(t, health.Reachable)
+		assert.Equal(t, "repository rejected the credentials with 401 Unauthorized", health.Error)
+	})
+
+	t.Run("Should check local static repository on disk", func(t *testing.T) {
+		dir := t.TempDir()
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+
+		health := i.CheckRepoReachable(filepath.Join(dir, repo.StaticIndexFile))
+		assert.False(t, health.Reachable)
+		assert.NotEmpty(t, health.Error)
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, repo.StaticIndexFile), []byte(`{"plugins": []}`), 0600))
+		health = i.CheckRepoReachable(dir)
+		assert.True(t, health.Reachable)
+		assert.Empty(t, health.Error)
+	})
+}
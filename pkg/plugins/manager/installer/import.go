@@ -0,0 +1,215 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// ErrOffline is returned for requests that would need network access while installing from an offline bundle.
+var ErrOffline = errors.New("network access is disabled while installing from an offline bundle")
+
+// ImportBundle installs the plugins, referenced as <plugin id>[@<version or channel>], and their dependencies from
+// an offline bundle written by ExportBundle, without any network access. The bundle is a directory or a gzipped
+// tarball, whose checksums are verified before anything is installed. Without references, all plugins of the
+// bundle are installed.
+func (i *Installer) ImportBundle(pluginRefs []string, bundlePath, pluginsDir string) error {
+	if err := i.checkFIPS(); err != nil {
+		return err
+	}
+
+	dir := bundlePath
+	if fi, err := os.Stat(bundlePath); err != nil {
+		return errutil.Wrap("failed to open bundle", err)
+	} else if !fi.IsDir() {
+		baseDir, err := i.tempDir(pluginsDir)
+		if err != nil {
+			return err
+		}
+		tmpDir, err := ioutil.TempDir(baseDir, ".plugin-bundle-")
+		if err != nil {
+			return errutil.Wrap("failed to create temporary directory", err)
+		}
+		defer func() {
+			if err := os.RemoveAll(tmpDir); err != nil {
+				i.log.Warn("Failed to remove temporary directory", "dir", tmpDir, "err", err)
+			}
+		}()
+		if err := extractBundleTarball(bundlePath, tmpDir); err != nil {
+			return errutil.Wrap("failed to extract bundle", err)
+		}
+		dir = tmpDir
+	}
+	if err := verifyBundle(dir); err != nil {
+		return err
+	}
+
+	if len(pluginRefs) == 0 {
+		data, err := ioutil.ReadFile(filepath.Join(dir, repo.StaticIndexFile))
+		if err != nil {
+			return errutil.Wrap("failed to read bundle index", err)
+		}
+		var index PluginRepo
+		if err := json.Unmarshal(data, &index); err != nil {
+			return errutil.Wrap("failed to parse bundle index", err)
+		}
+		for _, p := range index.Plugins {
+			pluginRefs = append(pluginRefs, p.ID)
+		}
+		sort.Strings(pluginRefs)
+	}
+
+	// Plugins and their dependencies are only resolved from the bundle, sources that could send them elsewhere
+	// are dropped and any remaining request over the network fails.
+	opts := i.opts
+	opts.SourceAliases = nil
+	opts.RepoMirrors = nil
+	opts.Enterprise = false
+	opts.TUF = TUFOpts{}
+	bi := NewWithOpts(opts, i.grafanaVersion, i.log)
+	bi.offline = true
+	// The plugins are installed as a batch, so that plugins requiring conflicting versions of a dependency fail
+	plan := bi.newInstallPlan(pluginsDir)
+	resolution := newDependencyResolution()
+	for _, ref := range pluginRefs {
+		if err := bi.planInstall(plan, ref, "", "", dir, resolution); err != nil {
+			plan.Close()
+			return errutil.Wrapf(err, "failed to install plugin '%s' from bundle", ref)
+		}
+	}
+	return bi.ApplyPlan(plan)
+}
+
+// verifyBundle checks the bundle files against the SHA256SUMS file, which must list the index and every archive.
+func verifyBundle(dir string) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, bundleSumsFile))
+	if err != nil {
+		return errutil.Wrap("failed to read bundle checksums", err)
+	}
+	sums, err := parseBundleSums(data)
+	if err != nil {
+		return errutil.Wrap("failed to parse bundle checksums", err)
+	}
+	if _, exists := sums[repo.StaticIndexFile]; !exists {
+		return fmt.Errorf("bundle checksums don't list %s", repo.StaticIndexFile)
+	}
+
+	for name, expected := range sums {
+		sum, err := plugins.FileSHA256(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return errutil.Wrapf(err, "failed to verify bundle file %s", name)
+		}
+		if !strings.EqualFold(sum, expected) {
+			return fmt.Errorf("bundle file %s doesn't match its checksum", name)
+		}
+	}
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if _, exists := sums[rel]; !exists && rel != bundleSumsFile {
+			return fmt.Errorf("bundle file %s isn't listed in the bundle checksums", rel)
+		}
+		return nil
+	})
+}
+
+// parseBundleSums parses a SHA256SUMS file in the format of sha256sum into checksums by file name.
+func parseBundleSums(data []byte) (map[string]string, error) {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || len(fields[0]) != 64 {
+			return nil, fmt.Errorf("line %d: expected <checksum>  <file>", n)
+		}
+		name := strings.TrimPrefix(fields[1], "*")
+		if !isBundlePath(name) {
+			return nil, fmt.Errorf("line %d: invalid file name %q", n, name)
+		}
+		sums[name] = fields[0]
+	}
+	return sums, scanner.Err()
+}
+
+// isBundlePath returns whether the name is a clean relative path that stays within the bundle.
+func isBundlePath(name string) bool {
+	return name != "" && path.Clean(name) == name && !path.IsAbs(name) && name != ".." &&
+		!strings.HasPrefix(name, "../") && !strings.Contains(name, "\\")
+}
+
+// extractBundleTarball extracts the regular files and directories of a gzipped bundle tarball into dir.
+func extractBundleTarball(tarballPath, dir string) error {
+	// nolint:gosec
+	f, err := os.Open(tarballPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	gr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("failed to decompress bundle: %w", err)
+	}
+	defer func() {
+		_ = gr.Close()
+	}()
+
+	return walkTar(gr, func(file *archiveFile) error {
+		name := path.Clean(file.name)
+		if !isBundlePath(name) {
+			return fmt.Errorf("invalid bundle file name %q", file.name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if file.mode.IsDir() {
+			return os.MkdirAll(target, 0750)
+		}
+		if !file.mode.IsRegular() {
+			return fmt.Errorf("bundle file %s isn't a regular file", name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+			return err
+		}
+		r, err := file.open()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+		// nolint:gosec
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, r); err != nil {
+			_ = out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
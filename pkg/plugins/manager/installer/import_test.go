@@ -0,0 +1,98 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportBundle(t *testing.T) {
+	newInstaller := func(opts Opts) *Installer {
+		opts.AdvisoryPolicy = AdvisoryPolicyIgnore
+		return NewWithOpts(opts, "8.0.0", &fakeLogger{})
+	}
+	exportBundle := func(t *testing.T, bundlePath string) string {
+		repoDir := writeTestBundleRepo(t, osAndArchString())
+		require.NoError(t, newInstaller(Opts{}).ExportBundle([]string{"test-app"}, bundlePath, repoDir, nil))
+		return bundlePath
+	}
+
+	t.Run("Should install plugins and dependencies from bundle without network access", func(t *testing.T) {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+		bundleDir := exportBundle(t, filepath.Join(t.TempDir(), "bundle"))
+
+		pluginsDir := t.TempDir()
+		i := newInstaller(Opts{RepoMirrors: []string{server.URL}, AdvisoryURL: server.URL})
+		require.NoError(t, i.ImportBundle([]string{"test-app"}, bundleDir, pluginsDir))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-app", "plugin.json"))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		assert.Zero(t, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Should install all plugins from bundle tarball", func(t *testing.T) {
+		tarball := exportBundle(t, filepath.Join(t.TempDir(), "bundle.tar.gz"))
+
+		pluginsDir := t.TempDir()
+		require.NoError(t, newInstaller(Opts{}).ImportBundle(nil, tarball, pluginsDir))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-app", "plugin.json"))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+	})
+
+	t.Run("Should reject bundle with tampered archive", func(t *testing.T) {
+		bundleDir := exportBundle(t, filepath.Join(t.TempDir(), "bundle"))
+		archive := filepath.Join(bundleDir, "test-panel", "2.0.0", "test-panel-2.0.0.zip")
+		require.NoError(t, ioutil.WriteFile(archive, []byte("tampered"), 0600))
+
+		pluginsDir := t.TempDir()
+		err := newInstaller(Opts{}).ImportBundle(nil, bundleDir, pluginsDir)
+		require.Error(t, err)
+		assert.Equal(t, "bundle file test-panel/2.0.0/test-panel-2.0.0.zip doesn't match its checksum", err.Error())
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-app"))
+	})
+
+	t.Run("Should reject bundle with files missing from checksums", func(t *testing.T) {
+		bundleDir := exportBundle(t, filepath.Join(t.TempDir(), "bundle"))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(bundleDir, "extra.zip"), []byte("extra"), 0600))
+
+		err := newInstaller(Opts{}).ImportBundle(nil, bundleDir, t.TempDir())
+		require.Error(t, err)
+		assert.Equal(t, "bundle file extra.zip isn't listed in the bundle checksums", err.Error())
+	})
+
+	t.Run("Should fail for plugin missing from bundle", func(t *testing.T) {
+		bundleDir := exportBundle(t, filepath.Join(t.TempDir(), "bundle"))
+
+		err := newInstaller(Opts{}).ImportBundle([]string{"other-panel"}, bundleDir, t.TempDir())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to install plugin 'other-panel' from bundle")
+	})
+
+	t.Run("Should refuse network requests when offline", func(t *testing.T) {
+		i := newInstaller(Opts{})
+		i.offline = true
+		_, err := i.sendRequest("https://grafana.com/api/plugins")
+		require.ErrorIs(t, err, ErrOffline)
+	})
+
+	t.Run("Should parse bundle checksums", func(t *testing.T) {
+		sums, err := parseBundleSums([]byte(
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  index.json\n"))
+		require.NoError(t, err)
+		assert.Len(t, sums, 1)
+
+		_, err = parseBundleSums([]byte(
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  ../index.json\n"))
+		require.Error(t, err)
+	})
+}
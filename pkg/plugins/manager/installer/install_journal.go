@@ -0,0 +1,392 @@
+package installer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+const (
+	// journalFileName is the name of the install journal in the quarantine directory.
+	journalFileName = ".install-journal.json"
+	// journalResumeMaxAge is how long the partial archive of an interrupted download is kept to resume it.
+	journalResumeMaxAge = 24 * time.Hour
+	// journalForeignHostMaxAge is how long installs recorded on other hosts, whose processes can't be checked, are
+	// assumed to be in progress.
+	journalForeignHostMaxAge = 24 * time.Hour
+)
+
+// Phases of an install recorded in the install journal.
+const (
+	journalPhaseDownload = "download"
+	journalPhaseExtract  = "extract"
+	journalPhaseApply    = "apply"
+)
+
+// journalMu serializes updates of install journals, as the archives of dependencies are fetched concurrently.
+var journalMu sync.Mutex
+
+var (
+	// journalRunID identifies the entries recorded by this process. PIDs aren't unique enough, e.g. a restarted
+	// container runs grafana-cli with the same PID as before.
+	journalRunID = newJournalRunID()
+	// journalHost is the host name of this host, the PIDs of entries recorded on other hosts sharing the plugins
+	// directory can't be checked.
+	journalHost, _ = os.Hostname()
+)
+
+func newJournalRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// journalEntry is an install in progress, recorded so that the next run can recover from it if the process died.
+type journalEntry struct {
+	PluginID string `json:"pluginId"`
+	Phase    string `json:"phase"`
+	// PID, Run and Host identify the process running the install, see journalEntry.running.
+	PID  int    `json:"pid"`
+	Run  string `json:"run,omitempty"`
+	Host string `json:"host,omitempty"`
+	// ArchivePath is the temporary file the archive is downloaded to, and Source the URL it's downloaded from.
+	ArchivePath string `json:"archivePath,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Checksum    string `json:"checksum,omitempty"`
+	// StagingDir is the directory in the quarantine directory the plugin is extracted to.
+	StagingDir string `json:"stagingDir,omitempty"`
+	// TargetDir is the directory the plugin is moved into, and Update whether it replaces an installed version.
+	TargetDir string    `json:"targetDir,omitempty"`
+	Update    bool      `json:"update,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// installJournal records the installs in progress in a plugins directory. It's best effort: failing to update it
+// is logged, but doesn't fail installs.
+type installJournal struct {
+	path string
+	log  plugins.PluginInstallerLogger
+}
+
+// own reports whether the entry was recorded by this process.
+func (e journalEntry) own() bool {
+	if e.Run != "" {
+		return e.Run == journalRunID
+	}
+	return e.PID == os.Getpid()
+}
+
+// running reports whether the install of an entry recorded by another process may still be in progress, as its
+// process is running. Installs recorded on other hosts are assumed to be in progress until they're a day old.
+func (e journalEntry) running() bool {
+	if e.Host != "" && e.Host != journalHost {
+		return time.Since(e.StartedAt) < journalForeignHostMaxAge
+	}
+	return e.PID != os.Getpid() && processRunning(e.PID)
+}
+
+// interrupted reports whether the entry is an install of another process that was interrupted.
+func (e journalEntry) interrupted() bool {
+	return !e.own() && !e.running()
+}
+
+func (i *Installer) journalFor(pluginsDir string) *installJournal {
+	return &installJournal{path: filepath.Join(quarantineDir(pluginsDir), journalFileName), log: i.log}
+}
+
+func (j *installJournal) read() ([]journalEntry, error) {
+	// nolint:gosec
+	data, err := ioutil.ReadFile(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []journalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid install journal %q: %w", j.path, err)
+	}
+	return entries, nil
+}
+
+func (j *installJournal) write(entries []journalEntry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	// nolint:gosec
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(j.path), ".install-journal-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), j.path)
+}
+
+// modify applies fn to the entries of the journal and writes them back if fn reports that it changed them.
+func (j *installJournal) modify(fn func([]journalEntry) ([]journalEntry, bool)) {
+	if j == nil {
+		return
+	}
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	entries, err := j.read()
+	if err == nil {
+		if modified, changed := fn(entries); changed {
+			err = j.write(modified)
+		}
+	}
+	if err != nil {
+		j.log.Warn("Failed to update the install journal", "file", j.path, "err", err)
+	}
+}
+
+// record records the install of the plugin in the phase of the entry, replacing its previous entry, or that of an
+// interrupted install it takes over. Entries of installs of the plugin in progress in other processes are kept.
+func (j *installJournal) record(e journalEntry) {
+	j.modify(func(entries []journalEntry) ([]journalEntry, bool) {
+		e.PID, e.Run, e.Host, e.StartedAt = os.Getpid(), journalRunID, journalHost, time.Now().UTC()
+		kept := entries[:0]
+		for _, existing := range entries {
+			if existing.PluginID == e.PluginID && (existing.own() || existing.interrupted()) {
+				e.StartedAt = existing.StartedAt
+			} else {
+				kept = append(kept, existing)
+			}
+		}
+		return append(kept, e), true
+	})
+}
+
+// remove removes the entry of the plugin's install by this process, once it finished or was cleaned up.
+func (j *installJournal) remove(pluginID string) {
+	j.modify(func(entries []journalEntry) ([]journalEntry, bool) {
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.PluginID != pluginID || !e.own() {
+				kept = append(kept, e)
+			}
+		}
+		return kept, len(kept) != len(entries)
+	})
+}
+
+// interrupted returns the entry of the plugin's install if it was interrupted, i.e. recorded by another process
+// that isn't running anymore.
+func (j *installJournal) interrupted(pluginID string) (journalEntry, bool) {
+	if j == nil {
+		return journalEntry{}, false
+	}
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	entries, err := j.read()
+	if err != nil {
+		return journalEntry{}, false
+	}
+	for _, e := range entries {
+		if e.PluginID == pluginID && e.interrupted() {
+			return e, true
+		}
+	}
+	return journalEntry{}, false
+}
+
+// recoverInterruptedInstalls recovers from the installs into the plugins directory that were interrupted, e.g.
+// because the process was killed or the host crashed. Partial extractions are removed, and plugins that were being
+// moved into place are removed again, restoring the versions they replaced. Interrupted downloads whose checksum
+// is known are kept to be resumed, see resumeInterruptedDownload. Installs of processes that are still running,
+// e.g. another grafana-cli installing into the same plugins directory, are left alone.
+func (i *Installer) recoverInterruptedInstalls(pluginsDir string) {
+	j := i.journalFor(pluginsDir)
+	j.modify(func(entries []journalEntry) ([]journalEntry, bool) {
+		kept := entries[:0]
+		for _, e := range entries {
+			if !e.interrupted() || resumable(e) {
+				kept = append(kept, e)
+				continue
+			}
+			if err := i.rollbackInterrupted(e); err != nil {
+				i.log.Warnf("Failed to roll back the interrupted install of %s: %v", e.PluginID, err)
+				kept = append(kept, e)
+				continue
+			}
+			if e.Phase != journalPhaseDownload {
+				i.log.Warnf("Rolled back the interrupted install of %s", e.PluginID)
+			}
+		}
+		return kept, len(kept) != len(entries)
+	})
+}
+
+// resumable reports whether the entry is an interrupted download that can be resumed, whose partial archive still
+// exists and whose checksum is known.
+func resumable(e journalEntry) bool {
+	if e.Phase != journalPhaseDownload || e.Checksum == "" || time.Since(e.StartedAt) >= journalResumeMaxAge {
+		return false
+	}
+	_, err := os.Stat(e.ArchivePath)
+	return err == nil
+}
+
+// rollbackInterrupted removes the temporary files of the interrupted install, and for installs interrupted while
+// the plugin was moved into place, restores the state before the install.
+func (i *Installer) rollbackInterrupted(e journalEntry) error {
+	if e.Phase == journalPhaseApply && e.TargetDir != "" && e.StagingDir != "" {
+		if err := restoreInterrupted(e); err != nil {
+			return err
+		}
+	}
+	if e.StagingDir != "" {
+		if err := os.RemoveAll(e.StagingDir); err != nil {
+			return err
+		}
+	}
+	if e.ArchivePath != "" {
+		if err := os.Remove(e.ArchivePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreInterrupted restores the installation the interrupted install was replacing, which replacePlugin keeps
+// in the staging directory, or removes a newly installed plugin. Updates interrupted before the installed version
+// was moved aside are left as they are.
+func restoreInterrupted(e journalEntry) error {
+	backups, err := filepath.Glob(filepath.Join(e.StagingDir, ".previous-*", e.PluginID))
+	if err != nil {
+		return err
+	}
+	if len(backups) > 0 {
+		return restorePlugin(e.TargetDir, e.PluginID, backups[0])
+	}
+	if e.Update {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(e.TargetDir, e.PluginID))
+}
+
+// resumeInterruptedDownload resumes the interrupted download of the plugin's archive, reporting whether the
+// archive was downloaded. Only downloads from the same source with the same checksum are resumed, so that the
+// resumed archive is verified. Otherwise, or if resuming fails, the partial archive is removed.
+func (i *Installer) resumeInterruptedDownload(p *pendingInstall) bool {
+	e, interrupted := p.journal.interrupted(p.pluginID)
+	if !interrupted || e.Phase != journalPhaseDownload {
+		return false
+	}
+	// The entry is taken over by this process, so that it's removed along with the install
+	p.journal.record(e)
+	if p.checksum != "" && e.Checksum == p.checksum && len(p.downloadURLs) > 0 && e.Source == p.downloadURLs[0] {
+		start := time.Now()
+		size, err := i.resumeDownload(p.pluginID, e.ArchivePath, e.Source, p.checksum)
+		if err == nil {
+			i.log.Infof("Resumed the interrupted download of %s", p.pluginID)
+			p.archivePath, p.pluginZipURL = e.ArchivePath, e.Source
+			p.event.Source = RedactURL(e.Source)
+			p.event.Download = newDownloadStats(size, start, 1)
+			return true
+		}
+		i.log.Warnf("Failed to resume the interrupted download of %s, downloading it again: %v", p.pluginID, err)
+	}
+	if err := os.Remove(e.ArchivePath); err != nil && !os.IsNotExist(err) {
+		i.log.Warn("Failed to remove temporary file", "file", e.ArchivePath, "err", err)
+	}
+	p.journal.remove(p.pluginID)
+	return false
+}
+
+// resumeDownload downloads the rest of the partial archive at the path from the URL with a Range request, or the
+// whole archive if the server doesn't support ranges, and verifies it against the checksum. It returns the number
+// of bytes downloaded.
+func (i *Installer) resumeDownload(pluginID, archivePath, downloadURL, checksum string) (int64, error) {
+	expected, err := parseChecksum(checksum)
+	if err != nil {
+		return 0, err
+	}
+	// nolint:gosec
+	f, err := os.OpenFile(archivePath, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			i.log.Warn("Failed to close file", "err", err)
+		}
+	}()
+	h := expected.newHash()
+	offset, err := io.Copy(h, f)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := i.createRequest(downloadURL)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	// Ranges of encoded content can't be appended to the decoded partial archive
+	req.Header.Set("Accept-Encoding", "identity")
+	body, contentLength, partial, err := i.doDownloadRequest(req)
+	if err != nil {
+		return 0, errutil.Wrap("Failed to send request", i.entitlementError(pluginID, err))
+	}
+	body = i.idleTimeoutBody(body)
+	defer func() {
+		if err := body.Close(); err != nil {
+			i.log.Warn("Failed to close body", "err", err)
+		}
+	}()
+	if !partial {
+		// The server ignored the range and sends the whole archive
+		if err := f.Truncate(0); err != nil {
+			return 0, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		h.Reset()
+	}
+	if err := i.checkDiskSpace(filepath.Dir(archivePath), contentLength, "download the plugin archive"); err != nil {
+		return 0, err
+	}
+
+	r := i.progressReader(i.archiveSizeReader(i.throttledReader(body)), pluginID, contentLength)
+	n, err := io.Copy(f, io.TeeReader(r, h))
+	if err != nil {
+		return 0, err
+	}
+	if !expected.matches(h) {
+		return 0, newChecksumMismatchError(expected, h, downloadURL)
+	}
+	return n, nil
+}
@@ -0,0 +1,261 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallJournal(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+	// Entries of another process that isn't running are left behind by an interrupted install. The PID is above the
+	// maximum PID of Linux, macOS and FreeBSD, and not a multiple of 4 like PIDs on Windows.
+	otherPID := 1<<22 + 1
+	writeJournal := func(t *testing.T, pluginsDir string, entries ...journalEntry) {
+		t.Helper()
+		data, err := json.Marshal(entries)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(quarantineDir(pluginsDir), 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(quarantineDir(pluginsDir), journalFileName), data, 0600))
+	}
+	writePlugin := func(t *testing.T, dir, version string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "test-panel"), 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "test-panel", "plugin.json"), []byte(version), 0600))
+	}
+	readVersion := func(t *testing.T, dir string) string {
+		t.Helper()
+		data, err := ioutil.ReadFile(filepath.Join(dir, "test-panel", "plugin.json"))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("Should roll back interrupted extractions", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		stagingDir, err := newStagingDir(pluginsDir)
+		require.NoError(t, err)
+		writePlugin(t, stagingDir, "partial")
+		archivePath := filepath.Join(t.TempDir(), "plugin.zip")
+		require.NoError(t, ioutil.WriteFile(archivePath, []byte("archive"), 0600))
+		writeJournal(t, pluginsDir, journalEntry{PluginID: "test-panel", Phase: journalPhaseExtract, PID: otherPID,
+			ArchivePath: archivePath, StagingDir: stagingDir})
+
+		NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).recoverInterruptedInstalls(pluginsDir)
+		assert.NoDirExists(t, stagingDir)
+		assert.NoFileExists(t, archivePath)
+		assertQuarantineEmpty(t, pluginsDir)
+	})
+
+	t.Run("Should restore the replaced version of interrupted updates", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		stagingDir, err := newStagingDir(pluginsDir)
+		require.NoError(t, err)
+		writePlugin(t, stagingDir, "1.0.0")
+		writePlugin(t, pluginsDir, "0.9.0")
+		_, err = replacePlugin(stagingDir, pluginsDir, "test-panel")
+		require.NoError(t, err)
+		require.Equal(t, "1.0.0", readVersion(t, pluginsDir))
+		writeJournal(t, pluginsDir, journalEntry{PluginID: "test-panel", Phase: journalPhaseApply, PID: otherPID,
+			StagingDir: stagingDir, TargetDir: pluginsDir, Update: true})
+
+		NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).recoverInterruptedInstalls(pluginsDir)
+		assert.Equal(t, "0.9.0", readVersion(t, pluginsDir))
+		assertQuarantineEmpty(t, pluginsDir)
+	})
+
+	t.Run("Should keep the installed version of updates interrupted before it was replaced", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		stagingDir, err := newStagingDir(pluginsDir)
+		require.NoError(t, err)
+		writePlugin(t, stagingDir, "1.0.0")
+		writePlugin(t, pluginsDir, "0.9.0")
+		writeJournal(t, pluginsDir, journalEntry{PluginID: "test-panel", Phase: journalPhaseApply, PID: otherPID,
+			StagingDir: stagingDir, TargetDir: pluginsDir, Update: true})
+
+		NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).recoverInterruptedInstalls(pluginsDir)
+		assert.Equal(t, "0.9.0", readVersion(t, pluginsDir))
+		assertQuarantineEmpty(t, pluginsDir)
+	})
+
+	t.Run("Should remove plugins whose interrupted install moved them into place", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		stagingDir, err := newStagingDir(pluginsDir)
+		require.NoError(t, err)
+		writePlugin(t, pluginsDir, "1.0.0")
+		writeJournal(t, pluginsDir, journalEntry{PluginID: "test-panel", Phase: journalPhaseApply, PID: otherPID,
+			StagingDir: stagingDir, TargetDir: pluginsDir})
+
+		NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).recoverInterruptedInstalls(pluginsDir)
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+		assertQuarantineEmpty(t, pluginsDir)
+	})
+
+	t.Run("Should leave installs of the running process alone", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		stagingDir, err := newStagingDir(pluginsDir)
+		require.NoError(t, err)
+		writeJournal(t, pluginsDir, journalEntry{PluginID: "test-panel", Phase: journalPhaseExtract,
+			PID: os.Getpid(), StagingDir: stagingDir})
+
+		NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).recoverInterruptedInstalls(pluginsDir)
+		assert.DirExists(t, stagingDir)
+	})
+
+	t.Run("Should leave installs of other running processes alone", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		stagingDir, err := newStagingDir(pluginsDir)
+		require.NoError(t, err)
+		writePlugin(t, stagingDir, "partial")
+		applyingDir, err := newStagingDir(pluginsDir)
+		require.NoError(t, err)
+		writePlugin(t, pluginsDir, "1.0.0")
+		// The parent process, e.g. go test, is running
+		entries := []journalEntry{
+			{PluginID: "test-panel", Phase: journalPhaseExtract, PID: os.Getppid(), Run: "other",
+				StagingDir: stagingDir, StartedAt: time.Now()},
+			{PluginID: "other-panel", Phase: journalPhaseApply, PID: os.Getppid(), Run: "other",
+				StagingDir: applyingDir, TargetDir: pluginsDir, StartedAt: time.Now()},
+		}
+		writeJournal(t, pluginsDir, entries...)
+
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+		i.recoverInterruptedInstalls(pluginsDir)
+		assert.DirExists(t, stagingDir)
+		assert.DirExists(t, applyingDir)
+		assert.Equal(t, "1.0.0", readVersion(t, pluginsDir))
+		_, interrupted := i.journalFor(pluginsDir).interrupted("test-panel")
+		assert.False(t, interrupted)
+
+		i.journalFor(pluginsDir).record(journalEntry{PluginID: "test-panel", Phase: journalPhaseDownload})
+		recorded, err := i.journalFor(pluginsDir).read()
+		require.NoError(t, err)
+		assert.Len(t, recorded, 3)
+	})
+
+	t.Run("Should leave installs of other hosts alone until they're stale", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		stagingDir, err := newStagingDir(pluginsDir)
+		require.NoError(t, err)
+		writeJournal(t, pluginsDir, journalEntry{PluginID: "test-panel", Phase: journalPhaseExtract, PID: otherPID,
+			Host: "other-host", StagingDir: stagingDir, StartedAt: time.Now()})
+
+		NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).recoverInterruptedInstalls(pluginsDir)
+		assert.DirExists(t, stagingDir)
+
+		writeJournal(t, pluginsDir, journalEntry{PluginID: "test-panel", Phase: journalPhaseExtract, PID: otherPID,
+			Host: "other-host", StagingDir: stagingDir, StartedAt: time.Now().Add(-journalForeignHostMaxAge)})
+		NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).recoverInterruptedInstalls(pluginsDir)
+		assert.NoDirExists(t, stagingDir)
+	})
+
+	t.Run("Should roll back installs of earlier processes with the same PID", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		stagingDir, err := newStagingDir(pluginsDir)
+		require.NoError(t, err)
+		writeJournal(t, pluginsDir, journalEntry{PluginID: "test-panel", Phase: journalPhaseExtract,
+			PID: os.Getpid(), Run: "earlier", StagingDir: stagingDir})
+
+		NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).recoverInterruptedInstalls(pluginsDir)
+		assert.NoDirExists(t, stagingDir)
+	})
+
+	t.Run("Should clear the journal once plugins are installed", func(t *testing.T) {
+		archivePath := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+		pluginsDir := t.TempDir()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, archivePath, ""))
+		assert.NoFileExists(t, filepath.Join(quarantineDir(pluginsDir), journalFileName))
+	})
+
+	archive, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}))
+	require.NoError(t, err)
+	sum := sha256.Sum256(archive)
+	checksum := hex.EncodeToString(sum[:])
+	newServer := func(t *testing.T, ranges bool) (*httptest.Server, *[]string) {
+		var requested []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = append(requested, r.Header.Get("Range"))
+			if !ranges {
+				_, _ = w.Write(archive)
+				return
+			}
+			http.ServeContent(w, r, "plugin.tar.gz", time.Time{}, bytes.NewReader(archive))
+		}))
+		t.Cleanup(server.Close)
+		return server, &requested
+	}
+	interruptedDownload := func(t *testing.T, pluginsDir, source, checksum string) string {
+		t.Helper()
+		archivePath := filepath.Join(t.TempDir(), "partial.zip")
+		require.NoError(t, ioutil.WriteFile(archivePath, archive[:len(archive)/2], 0600))
+		writeJournal(t, pluginsDir, journalEntry{PluginID: "test-panel", Phase: journalPhaseDownload, PID: otherPID,
+			ArchivePath: archivePath, Source: source, Checksum: checksum, StartedAt: time.Now()})
+		return archivePath
+	}
+	fetch := func(t *testing.T, pluginsDir, source, checksum string) *pendingInstall {
+		t.Helper()
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+		i.recoverInterruptedInstalls(pluginsDir)
+		p := &pendingInstall{pluginID: "test-panel", downloadURLs: []string{source}, checksum: checksum,
+			event: &AuditEvent{}, journal: i.journalFor(pluginsDir)}
+		require.NoError(t, i.fetchArchive(p, pluginsDir))
+		t.Cleanup(func() { p.discard(&fakeLogger{}) })
+		data, err := ioutil.ReadFile(p.archivePath)
+		require.NoError(t, err)
+		assert.Equal(t, archive, data)
+		return p
+	}
+
+	t.Run("Should resume interrupted downloads", func(t *testing.T) {
+		server, requested := newServer(t, true)
+		pluginsDir := t.TempDir()
+		archivePath := interruptedDownload(t, pluginsDir, server.URL, checksum)
+
+		p := fetch(t, pluginsDir, server.URL, checksum)
+		assert.Equal(t, archivePath, p.archivePath)
+		assert.Equal(t, []string{fmt.Sprintf("bytes=%d-", len(archive)/2)}, *requested)
+		assert.Equal(t, int64(len(archive)-len(archive)/2), p.event.Download.Bytes)
+	})
+
+	t.Run("Should download the whole archive if the server doesn't support ranges", func(t *testing.T) {
+		server, _ := newServer(t, false)
+		pluginsDir := t.TempDir()
+		archivePath := interruptedDownload(t, pluginsDir, server.URL, checksum)
+
+		p := fetch(t, pluginsDir, server.URL, checksum)
+		assert.Equal(t, archivePath, p.archivePath)
+	})
+
+	t.Run("Should download the archive again if the checksum changed", func(t *testing.T) {
+		server, requested := newServer(t, true)
+		pluginsDir := t.TempDir()
+		archivePath := interruptedDownload(t, pluginsDir, server.URL, "0123")
+
+		p := fetch(t, pluginsDir, server.URL, checksum)
+		assert.NotEqual(t, archivePath, p.archivePath)
+		assert.NoFileExists(t, archivePath)
+		assert.Equal(t, []string{""}, *requested)
+	})
+
+	t.Run("Should remove interrupted downloads whose checksum is unknown", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		archivePath := interruptedDownload(t, pluginsDir, "https://grafana.com/plugin.zip", "")
+
+		NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).recoverInterruptedInstalls(pluginsDir)
+		assert.NoFileExists(t, archivePath)
+		assert.NoFileExists(t, filepath.Join(quarantineDir(pluginsDir), journalFileName))
+	})
+}
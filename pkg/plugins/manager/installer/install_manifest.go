@@ -0,0 +1,230 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+)
+
+// installManifestFile is the name of the manifest at the root of self-describing plugin archives.
+// It's a clear-signed JSON document, just like the MANIFEST.txt of signed plugins.
+const installManifestFile = "INSTALL_MANIFEST.txt"
+
+// installManifest describes the contents of a self-describing plugin archive so that it can be
+// verified without contacting the plugin repository.
+type installManifest struct {
+	Plugin  string   `json:"plugin"`
+	Version string   `json:"version"`
+	Archs   []string `json:"archs"`
+	// Files maps paths relative to the plugin root to their SHA256 checksums.
+	Files map[string]string `json:"files"`
+}
+
+func (m *installManifest) supportsCurrentArch() bool {
+	if len(m.Archs) == 0 {
+		return true
+	}
+	for _, arch := range m.Archs {
+		if arch == osAndArchString() || arch == "any" {
+			return true
+		}
+	}
+	return false
+}
+
+// isInstallManifest reports whether the archive member is the install manifest.
+func isInstallManifest(name string) bool {
+	return strings.TrimPrefix(name, "./") == installManifestFile
+}
+
+// verifyInstallManifest checks the signature of the archive's install manifest and verifies the archive contents
+// against it. It returns nil if the archive doesn't contain an install manifest.
+func (i *Installer) verifyInstallManifest(archivePath, pluginID, version string) (*installManifest, error) {
+	r, err := i.openArchive(archivePath, pluginID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			i.log.Warn("failed to close archive file", "err", err)
+		}
+	}()
+
+	var body []byte
+	err = r.walk(func(f *archiveFile) error {
+		if !isInstallManifest(f.name) || f.isDir() {
+			return nil
+		}
+		src, err := f.open()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = src.Close()
+		}()
+		body, err = ioutil.ReadAll(src)
+		return err
+	})
+	if err != nil {
+		return nil, errutil.Wrap("failed to read install manifest", err)
+	}
+	if body == nil {
+		return nil, nil
+	}
+
+	manifest, err := i.readInstallManifest(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.Plugin != pluginID {
+		return nil, fmt.Errorf("install manifest is for plugin %q, not %q", manifest.Plugin, pluginID)
+	}
+	if version != "" && manifest.Version != version {
+		return nil, fmt.Errorf("install manifest is for version %s of %s, not %s", manifest.Version, pluginID, version)
+	}
+	if !manifest.supportsCurrentArch() {
+		return nil, fmt.Errorf("%s v%s is not supported on your architecture and OS (%s), supported are: %s",
+			pluginID, manifest.Version, osAndArchString(), strings.Join(manifest.Archs, ", "))
+	}
+
+	seen := map[string]bool{}
+	err = r.walk(func(f *archiveFile) error {
+		if isInstallManifest(f.name) || f.isDir() {
+			return nil
+		}
+		rel := pluginRelativePath(f.name)
+		if rel == "" {
+			return fmt.Errorf("archive member %q is outside of the plugin directory", f.name)
+		}
+		expected, exists := manifest.Files[rel]
+		if !exists {
+			return fmt.Errorf("archive member %q is not listed in the install manifest", rel)
+		}
+
+		h := sha256.New()
+		if f.isSymlink() {
+			target, err := f.symlinkTarget()
+			if err != nil {
+				return err
+			}
+			h.Write([]byte(target))
+		} else {
+			src, err := f.open()
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(h, src)
+			if cerr := src.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if !strings.EqualFold(expected, fmt.Sprintf("%x", h.Sum(nil))) {
+			return fmt.Errorf("checksum of %q does not match the install manifest", rel)
+		}
+		seen[rel] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for p := range manifest.Files {
+		if !seen[p] {
+			return nil, fmt.Errorf("file %q listed in the install manifest is missing from the archive", p)
+		}
+	}
+
+	return manifest, nil
+}
+
+// readInstallManifest decodes the clear-signed install manifest and checks its signature.
+func (i *Installer) readInstallManifest(body []byte) (*installManifest, error) {
+	block, _ := clearsign.Decode(body)
+	if block == nil {
+		return nil, errors.New("unable to decode install manifest, it must be clear-signed")
+	}
+
+	keyring, err := i.installManifestKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := ioutil.ReadAll(block.ArmoredSignature.Body)
+	if err != nil {
+		return nil, errutil.Wrap("failed to read install manifest signature", err)
+	}
+	if err := i.checkFIPSSignature(signature); err != nil {
+		return nil, errutil.Wrap("invalid install manifest signature", err)
+	}
+	if _, err := openpgp.CheckDetachedSignature(keyring, bytes.NewBuffer(block.Bytes),
+		bytes.NewReader(signature)); err != nil {
+		return nil, errutil.Wrap("failed to check install manifest signature", err)
+	}
+
+	manifest := &installManifest{}
+	if err := json.Unmarshal(block.Plaintext, manifest); err != nil {
+		return nil, errutil.Wrap("failed to parse install manifest", err)
+	}
+	if manifest.Plugin == "" || manifest.Version == "" {
+		return nil, errors.New("install manifest must declare plugin and version")
+	}
+
+	return manifest, nil
+}
+
+// installManifestKeyring returns the keys trusted to sign install manifests, that is the Grafana key
+// and any keys in the configured keyring file.
+func (i *Installer) installManifestKeyring() (openpgp.EntityList, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewBufferString(plugins.PublicKeyText))
+	if err != nil {
+		return nil, errutil.Wrap("failed to parse public key", err)
+	}
+
+	if i.opts.ManifestKeyringPath == "" {
+		return keyring, nil
+	}
+
+	// nolint:gosec
+	f, err := os.Open(i.opts.ManifestKeyringPath)
+	if err != nil {
+		return nil, errutil.Wrap("failed to open install manifest keyring", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			i.log.Warn("Failed to close file", "err", err)
+		}
+	}()
+	extra, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, errutil.Wrap("failed to parse install manifest keyring", err)
+	}
+
+	return append(keyring, extra...), nil
+}
+
+// pluginRelativePath strips the top level directory from an archive member name, returning an empty string
+// for members at the root of the archive.
+func pluginRelativePath(name string) string {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	idx := strings.Index(name, "/")
+	if idx < 0 {
+		return ""
+	}
+	return name[idx+1:]
+}
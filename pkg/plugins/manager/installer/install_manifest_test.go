@@ -0,0 +1,157 @@
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestVerifyInstallManifest(t *testing.T) {
+	files := map[string]string{
+		"plugin.json":    `{"id":"test-panel","type":"panel","info":{"version":"1.0.0"}}`,
+		"dist/module.js": "define([], function() {})",
+	}
+
+	t.Run("Should accept archive matching signed manifest", func(t *testing.T) {
+		i, entity := newManifestTestInstaller(t)
+		manifest := testInstallManifest(files)
+		archive := writeTestTarGz(t, signTestManifest(t, entity, manifest), files)
+
+		res, err := i.verifyInstallManifest(archive, "test-panel", "1.0.0")
+		require.NoError(t, err)
+		require.NotNil(t, res)
+		assert.Equal(t, "1.0.0", res.Version)
+	})
+
+	t.Run("Should return nil for archive without manifest", func(t *testing.T) {
+		i, _ := newManifestTestInstaller(t)
+		archive := writeTestTarGz(t, nil, files)
+
+		res, err := i.verifyInstallManifest(archive, "test-panel", "")
+		require.NoError(t, err)
+		assert.Nil(t, res)
+	})
+
+	t.Run("Should reject modified file", func(t *testing.T) {
+		i, entity := newManifestTestInstaller(t)
+		manifest := testInstallManifest(files)
+		modified := map[string]string{
+			"plugin.json":    files["plugin.json"],
+			"dist/module.js": "alert('pwned')",
+		}
+		archive := writeTestTarGz(t, signTestManifest(t, entity, manifest), modified)
+
+		_, err := i.verifyInstallManifest(archive, "test-panel", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dist/module.js")
+	})
+
+	t.Run("Should reject manifest signed by untrusted key", func(t *testing.T) {
+		i, _ := newManifestTestInstaller(t)
+		untrusted, err := openpgp.NewEntity("untrusted", "", "untrusted@example.com", &packet.Config{RSABits: 1024})
+		require.NoError(t, err)
+		archive := writeTestTarGz(t, signTestManifest(t, untrusted, testInstallManifest(files)), files)
+
+		_, err = i.verifyInstallManifest(archive, "test-panel", "")
+		require.Error(t, err)
+	})
+
+	t.Run("Should reject manifest for another plugin", func(t *testing.T) {
+		i, entity := newManifestTestInstaller(t)
+		archive := writeTestTarGz(t, signTestManifest(t, entity, testInstallManifest(files)), files)
+
+		_, err := i.verifyInstallManifest(archive, "other-panel", "")
+		require.Error(t, err)
+	})
+}
+
+func newManifestTestInstaller(t *testing.T) (*Installer, *openpgp.Entity) {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", &packet.Config{RSABits: 1024})
+	require.NoError(t, err)
+
+	keyring := filepath.Join(t.TempDir(), "keyring.asc")
+	f, err := os.Create(keyring)
+	require.NoError(t, err)
+	w, err := armor.Encode(f, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	return &Installer{opts: Opts{ManifestKeyringPath: keyring}, log: &fakeLogger{}}, entity
+}
+
+func testInstallManifest(files map[string]string) installManifest {
+	m := installManifest{
+		Plugin:  "test-panel",
+		Version: "1.0.0",
+		Archs:   []string{"any"},
+		Files:   map[string]string{},
+	}
+	for name, content := range files {
+		m.Files[name] = fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	}
+	return m
+}
+
+func signTestManifest(t *testing.T, entity *openpgp.Entity, manifest installManifest) []byte {
+	t.Helper()
+
+	body, err := json.Marshal(manifest)
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	w, err := clearsign.Encode(buf, entity.PrivateKey, nil)
+	require.NoError(t, err)
+	_, err = w.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func writeTestTarGz(t *testing.T, manifest []byte, files map[string]string) string {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	write := func(name string, content []byte) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write(content)
+		require.NoError(t, err)
+	}
+	if manifest != nil {
+		write(installManifestFile, manifest)
+	}
+	for name, content := range files {
+		write("test-panel/"+name, []byte(content))
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	archive := filepath.Join(t.TempDir(), "plugin.tar.gz")
+	require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+	return archive
+}
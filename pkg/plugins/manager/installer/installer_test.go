@@ -0,0 +1,327 @@
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func TestExtractFiles(t *testing.T) {
+	t.Run("Should extract tar.gz archive", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+		archive := writeTestTarGz(t, nil, map[string]string{
+			"plugin.json":    `{"id":"test-panel","type":"panel","info":{"version":"1.0.0"}}`,
+			"dist/module.js": "define([], function() {})",
+		})
+		pluginsDir := t.TempDir()
+
+		err := i.extractFiles(archive, "test-panel", pluginsDir, false)
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "dist", "module.js"))
+		require.NoError(t, err)
+		assert.Equal(t, "define([], function() {})", string(data))
+	})
+
+	t.Run("Should not extract install manifest", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+		archive := writeTestTarGz(t, []byte("manifest"), map[string]string{
+			"plugin.json": `{"id":"test-panel","type":"panel","info":{"version":"1.0.0"}}`,
+		})
+		pluginsDir := t.TempDir()
+
+		err := i.extractFiles(archive, "test-panel", pluginsDir, false)
+		require.NoError(t, err)
+
+		_, err = os.Stat(filepath.Join(pluginsDir, installManifestFile))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Should only keep file permissions of plugin backend binaries declared in plugin.json", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Windows doesn't have Unix file permissions")
+		}
+		i := &Installer{log: &fakeLogger{}}
+		archive := filepath.Join("testdata", "grafana-simple-json-datasource-ec18fa4da8096a952608a7e4c7782b4260b41bcf.zip")
+		pluginsDir := t.TempDir()
+
+		err := i.extractFiles(archive, "grafana-simple-json-datasource", pluginsDir, false)
+		require.NoError(t, err)
+
+		for name, mode := range map[string]string{
+			// Files in zip have permissions 755, but the archive has no plugin.json declaring them as executables
+			"simple-plugin_darwin_amd64": "-rw-r--r--",
+			"simple-plugin_linux_amd64":  "-rw-r--r--",
+			"non-plugin-binary":          "-rw-r--r--",
+			// File in zip has permission 644
+			"simple-plugin_windows_amd64.exe": "-rw-r--r--",
+		} {
+			fi, err := os.Stat(filepath.Join(pluginsDir, "grafana-simple-json-datasource", name))
+			require.NoError(t, err)
+			assert.Equal(t, mode, fi.Mode().String(), name)
+		}
+	})
+
+	t.Run("Should ignore symlinks in zip if not allowed", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+		pluginsDir := t.TempDir()
+
+		err := i.extractFiles(filepath.Join("testdata", "plugin-with-symlink.zip"), "plugin-with-symlink", pluginsDir,
+			false)
+		require.NoError(t, err)
+
+		assert.FileExists(t, filepath.Join(pluginsDir, "plugin-with-symlink", "text.txt"))
+		_, err = os.Lstat(filepath.Join(pluginsDir, "plugin-with-symlink", "symlink_to_txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Should extract symlinks in zip if allowed", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Creating symlinks on Windows requires elevated privileges")
+		}
+		i := &Installer{log: &fakeLogger{}}
+		pluginsDir := t.TempDir()
+
+		err := i.extractFiles(filepath.Join("testdata", "plugin-with-symlink.zip"), "plugin-with-symlink", pluginsDir,
+			true)
+		require.NoError(t, err)
+
+		_, err = os.Stat(filepath.Join(pluginsDir, "plugin-with-symlink", "symlink_to_txt"))
+		require.NoError(t, err)
+	})
+
+	t.Run("Should detect if archive members point outside of the destination directory", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+		pluginsDir := t.TempDir()
+
+		err := i.extractFiles(filepath.Join("testdata", "plugin-with-parent-member.zip"), "plugin-with-parent-member",
+			pluginsDir, true)
+		require.EqualError(t, err, fmt.Sprintf(
+			`archive member "../member.txt" tries to write outside of plugin directory: %q, this can be a security risk`,
+			pluginsDir,
+		))
+		assert.NoFileExists(t, filepath.Join(filepath.Dir(pluginsDir), "member.txt"))
+	})
+
+	t.Run("Should detect if archive members are absolute", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+		pluginsDir := t.TempDir()
+
+		err := i.extractFiles(filepath.Join("testdata", "plugin-with-absolute-member.zip"),
+			"plugin-with-absolute-member", pluginsDir, true)
+		require.EqualError(t, err, fmt.Sprintf(
+			`archive member "/member.txt" tries to write outside of plugin directory: %q, this can be a security risk`,
+			pluginsDir,
+		))
+	})
+}
+
+func TestRemoveGitBuildFromName(t *testing.T) {
+	pluginID := "datasource-kairosdb"
+
+	// The root directory should get renamed to the plugin ID
+	paths := map[string]string{
+		"datasource-plugin-kairosdb-cc4a3965ef5d3eb1ae0ee4f93e9e78ec7db69e64/":                     "datasource-kairosdb/",
+		"datasource-plugin-kairosdb-cc4a3965ef5d3eb1ae0ee4f93e9e78ec7db69e64/README.md":            "datasource-kairosdb/README.md",
+		"datasource-plugin-kairosdb-cc4a3965ef5d3eb1ae0ee4f93e9e78ec7db69e64/partials/":            "datasource-kairosdb/partials/",
+		"datasource-plugin-kairosdb-cc4a3965ef5d3eb1ae0ee4f93e9e78ec7db69e64/partials/config.html": "datasource-kairosdb/partials/config.html",
+	}
+	for pth, exp := range paths {
+		assert.Equal(t, exp, removeGitBuildFromName(pth, pluginID))
+	}
+}
+
+func TestExtractExecutables(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for name, content := range map[string]string{
+		"test-app/plugin.json":                            `{"id":"test-app","type":"app","backend":true,"executable":"gpx_app"}`,
+		"test-app/gpx_app_linux_amd64":                    "binary",
+		"test-app/gpx_app_windows_amd64.exe":              "binary",
+		"test-app/gpx_app_notes.txt":                      "text",
+		"test-app/module.js":                              "define([], function() {})",
+		"test-app/datasource/plugin.json":                 `{"id":"test-datasource","type":"datasource","backend":true,"executable":"gpx_ds"}`,
+		"test-app/datasource/gpx_ds_linux_arm64":          "binary",
+		"test-app/datasource/gpx_app_linux_amd64":         "binary",
+		"test-app/datasource/scripts/install_linux_amd64": "script",
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	archive := filepath.Join(t.TempDir(), "plugin.tar")
+	require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+
+	pluginsDir := t.TempDir()
+	i := &Installer{log: &fakeLogger{}}
+	require.NoError(t, i.extractFiles(archive, "test-app", pluginsDir, false))
+
+	for name, executable := range map[string]bool{
+		"plugin.json":                            false,
+		"gpx_app_linux_amd64":                    true,
+		"gpx_app_windows_amd64.exe":              true,
+		"gpx_app_notes.txt":                      false,
+		"module.js":                              false,
+		"datasource/gpx_ds_linux_arm64":          true,
+		"datasource/gpx_app_linux_amd64":         false,
+		"datasource/scripts/install_linux_amd64": false,
+	} {
+		fi, err := os.Stat(filepath.Join(pluginsDir, "test-app", filepath.FromSlash(name)))
+		require.NoError(t, err)
+		assert.Equal(t, executable, fi.Mode()&0111 != 0, name)
+	}
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	writeArchive := func(t *testing.T, links map[string]string) string {
+		t.Helper()
+		buf := new(bytes.Buffer)
+		tw := tar.NewWriter(buf)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "test-panel/plugin.json", Mode: 0644,
+			Size: int64(len(testPluginJSON))}))
+		_, err := tw.Write([]byte(testPluginJSON))
+		require.NoError(t, err)
+		// Symlinks are written in order, so that they can refer to each other
+		for _, name := range []string{"test-panel/a", "test-panel/dist/b", "test-panel/c"} {
+			target, exists := links[name]
+			if !exists {
+				continue
+			}
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Linkname: target, Mode: 0777,
+				Typeflag: tar.TypeSymlink}))
+		}
+		require.NoError(t, tw.Close())
+		archive := filepath.Join(t.TempDir(), "plugin.tar")
+		require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+		return archive
+	}
+
+	t.Run("Should extract symlinks within plugin directory", func(t *testing.T) {
+		archive := writeArchive(t, map[string]string{
+			"test-panel/a":      "plugin.json",
+			"test-panel/dist/b": "../a",
+			"test-panel/c":      "dist/../a",
+		})
+		pluginsDir := t.TempDir()
+
+		i := &Installer{log: &fakeLogger{}}
+		require.NoError(t, i.extractFiles(archive, "test-panel", pluginsDir, true))
+
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "dist", "b"))
+		require.NoError(t, err)
+		assert.Equal(t, testPluginJSON, string(data))
+	})
+
+	for desc, links := range map[string]map[string]string{
+		"absolute target": {"test-panel/a": "/etc/passwd"},
+		"relative target": {"test-panel/dist/b": "../../other-plugin/plugin.json"},
+		"chained symlinks": {
+			"test-panel/a":      ".",
+			"test-panel/dist/b": "../a/..",
+		},
+	} {
+		links := links
+		t.Run("Should reject symlink escaping plugin directory with "+desc, func(t *testing.T) {
+			archive := writeArchive(t, links)
+
+			i := &Installer{log: &fakeLogger{}}
+			err := i.extractFiles(archive, "test-panel", t.TempDir(), true)
+			var escapeErr *symlinkEscapeError
+			require.ErrorAs(t, err, &escapeErr)
+		})
+	}
+}
+
+func TestExtractFilesFormats(t *testing.T) {
+	files := map[string]string{
+		"test-panel/plugin.json": testPluginJSON,
+		"test-panel/module.js":   "define([], function() {})",
+	}
+
+	for name, compress := range map[string]func(io.Writer) io.WriteCloser{
+		"tar": nil,
+		"tar.zst": func(w io.Writer) io.WriteCloser {
+			zw, err := zstd.NewWriter(w)
+			require.NoError(t, err)
+			return zw
+		},
+		"tar.xz": func(w io.Writer) io.WriteCloser {
+			xw, err := xz.NewWriter(w)
+			require.NoError(t, err)
+			return xw
+		},
+	} {
+		compress := compress
+		t.Run(fmt.Sprintf("Should extract %s archive", name), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			var w io.Writer = buf
+			var cw io.WriteCloser
+			if compress != nil {
+				cw = compress(buf)
+				w = cw
+			}
+			tw := tar.NewWriter(w)
+			for name, content := range files {
+				require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+				_, err := tw.Write([]byte(content))
+				require.NoError(t, err)
+			}
+			require.NoError(t, tw.Close())
+			if cw != nil {
+				require.NoError(t, cw.Close())
+			}
+			archive := filepath.Join(t.TempDir(), "plugin")
+			require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+
+			i := &Installer{log: &fakeLogger{}}
+			pluginsDir := t.TempDir()
+			err := i.extractFiles(archive, "test-panel", pluginsDir, false)
+			require.NoError(t, err)
+
+			data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "module.js"))
+			require.NoError(t, err)
+			assert.Equal(t, "define([], function() {})", string(data))
+		})
+	}
+
+	for content, format := range map[string]string{
+		"7z\xbc\xaf\x27\x1c\x00\x04":   format7z,
+		"<!DOCTYPE html><html></html>": formatHTML,
+		"":                             formatEmpty,
+		"plain text":                   formatUnknown,
+	} {
+		archive := filepath.Join(t.TempDir(), "plugin")
+		require.NoError(t, ioutil.WriteFile(archive, []byte(content), 0600))
+
+		i := &Installer{log: &fakeLogger{}}
+		err := i.extractFiles(archive, "test-panel", t.TempDir(), false)
+		var formatErr *UnsupportedArchiveFormatError
+		require.ErrorAs(t, err, &formatErr)
+		assert.Equal(t, format, formatErr.Format)
+	}
+}
+
+type fakeLogger struct{}
+
+func (*fakeLogger) Successf(_ string, _ ...interface{}) {}
+func (*fakeLogger) Failuref(_ string, _ ...interface{}) {}
+func (*fakeLogger) Info(_ ...interface{})               {}
+func (*fakeLogger) Infof(_ string, _ ...interface{})    {}
+func (*fakeLogger) Debug(_ ...interface{})              {}
+func (*fakeLogger) Debugf(_ string, _ ...interface{})   {}
+func (*fakeLogger) Warn(_ ...interface{})               {}
+func (*fakeLogger) Warnf(_ string, _ ...interface{})    {}
+func (*fakeLogger) Error(_ ...interface{})              {}
+func (*fakeLogger) Errorf(_ string, _ ...interface{})   {}
@@ -0,0 +1,134 @@
+package installer
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	defaultMaxArchiveSize = 1 << 30
+	defaultMaxFileSize    = 1 << 30
+	defaultMaxTotalSize   = 4 << 30
+	defaultMaxEntries     = 100000
+)
+
+// ExtractionLimits bound the size of plugin archives and of their extracted contents, protecting against
+// decompression bombs. Zero values use the defaults, negative values disable the limit.
+type ExtractionLimits struct {
+	// MaxArchiveSize is the maximum size in bytes of the downloaded archive. Defaults to 1 GiB.
+	MaxArchiveSize int64
+	// MaxFileSize is the maximum uncompressed size in bytes of a single archive member. Defaults to 1 GiB.
+	MaxFileSize int64
+	// MaxTotalSize is the maximum uncompressed size in bytes of all archive members. Defaults to 4 GiB.
+	MaxTotalSize int64
+	// MaxEntries is the maximum number of archive members, including directories. Defaults to 100000.
+	MaxEntries int
+}
+
+func (l ExtractionLimits) withDefaults() ExtractionLimits {
+	if l.MaxArchiveSize == 0 {
+		l.MaxArchiveSize = defaultMaxArchiveSize
+	}
+	if l.MaxFileSize == 0 {
+		l.MaxFileSize = defaultMaxFileSize
+	}
+	if l.MaxTotalSize == 0 {
+		l.MaxTotalSize = defaultMaxTotalSize
+	}
+	if l.MaxEntries == 0 {
+		l.MaxEntries = defaultMaxEntries
+	}
+	return l
+}
+
+// LimitExceededError is returned when a plugin archive exceeds one of the extraction limits.
+type LimitExceededError struct {
+	Limit string
+	Max   int64
+	// Name is the archive member exceeding the limit, if any.
+	Name string
+}
+
+func (e *LimitExceededError) Error() string {
+	unit := " bytes"
+	if e.Limit == "entry count" {
+		unit = ""
+	}
+	if e.Name != "" {
+		return fmt.Sprintf("plugin archive member %q exceeds the %s limit of %d%s", e.Name, e.Limit, e.Max, unit)
+	}
+	return fmt.Sprintf("plugin archive exceeds the %s limit of %d%s", e.Limit, e.Max, unit)
+}
+
+// archiveSizeReader fails once more than the maximum archive size is read from r.
+func (i *Installer) archiveSizeReader(r io.Reader) io.Reader {
+	max := i.opts.Limits.withDefaults().MaxArchiveSize
+	if max < 0 {
+		return r
+	}
+	return &limitedReader{r: r, max: max, err: &LimitExceededError{Limit: "archive size", Max: max}}
+}
+
+// limitedReader returns err once more than max bytes are read. Unlike io.LimitedReader it fails instead of
+// silently truncating the data.
+type limitedReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+	err  error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		return n, l.err
+	}
+	return n, err
+}
+
+// extractionBudget tracks the members and bytes extracted from an archive against the extraction limits.
+type extractionBudget struct {
+	limits  ExtractionLimits
+	entries int
+	total   int64
+}
+
+func newExtractionBudget(limits ExtractionLimits) *extractionBudget {
+	return &extractionBudget{limits: limits.withDefaults()}
+}
+
+// addEntry accounts for another archive member.
+func (b *extractionBudget) addEntry() error {
+	b.entries++
+	if b.limits.MaxEntries > 0 && b.entries > b.limits.MaxEntries {
+		return &LimitExceededError{Limit: "entry count", Max: int64(b.limits.MaxEntries)}
+	}
+	return nil
+}
+
+// reader returns a reader of the archive member's contents that fails once the member exceeds the maximum file
+// size or all members exceed the maximum total size.
+func (b *extractionBudget) reader(r io.Reader, name string) io.Reader {
+	return &budgetReader{r: r, budget: b, name: name}
+}
+
+type budgetReader struct {
+	r      io.Reader
+	budget *extractionBudget
+	name   string
+	read   int64
+}
+
+func (r *budgetReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	r.budget.total += int64(n)
+	if max := r.budget.limits.MaxFileSize; max > 0 && r.read > max {
+		return n, &LimitExceededError{Limit: "file size", Max: max, Name: r.name}
+	}
+	if max := r.budget.limits.MaxTotalSize; max > 0 && r.budget.total > max {
+		return n, &LimitExceededError{Limit: "total size", Max: max}
+	}
+	return n, err
+}
@@ -0,0 +1,73 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractionLimits(t *testing.T) {
+	extract := func(t *testing.T, limits ExtractionLimits, files map[string]string) (string, error) {
+		t.Helper()
+		archive := writeTestTarGz(t, nil, files)
+		pluginsDir := t.TempDir()
+		i := &Installer{log: &fakeLogger{}, opts: Opts{Limits: limits}}
+		return pluginsDir, i.extractFiles(archive, "test-panel", pluginsDir, false)
+	}
+
+	t.Run("Should extract archive within limits", func(t *testing.T) {
+		_, err := extract(t, ExtractionLimits{MaxEntries: 2, MaxFileSize: 100, MaxTotalSize: 200},
+			map[string]string{"plugin.json": testPluginJSON, "module.js": "{}"})
+		require.NoError(t, err)
+	})
+
+	tcs := []struct {
+		desc          string
+		limits        ExtractionLimits
+		expectedLimit string
+	}{
+		{desc: "entry count", limits: ExtractionLimits{MaxEntries: 2}, expectedLimit: "entry count"},
+		{desc: "file size", limits: ExtractionLimits{MaxFileSize: 1024}, expectedLimit: "file size"},
+		{desc: "total size", limits: ExtractionLimits{MaxTotalSize: 3000}, expectedLimit: "total size"},
+	}
+	for _, tc := range tcs {
+		t.Run("Should fail when exceeding "+tc.desc, func(t *testing.T) {
+			// Highly compressible members, like a decompression bomb
+			pluginsDir, err := extract(t, tc.limits, map[string]string{
+				"plugin.json": testPluginJSON,
+				"module.js":   strings.Repeat("0", 2048),
+				"README.md":   strings.Repeat("0", 2048),
+			})
+			var limitErr *LimitExceededError
+			require.ErrorAs(t, err, &limitErr)
+			assert.Equal(t, tc.expectedLimit, limitErr.Limit)
+
+			_, err = os.Stat(filepath.Join(pluginsDir, "test-panel"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+
+	t.Run("Should not limit when disabled", func(t *testing.T) {
+		_, err := extract(t, ExtractionLimits{MaxEntries: -1, MaxFileSize: -1, MaxTotalSize: -1},
+			map[string]string{"plugin.json": testPluginJSON, "module.js": strings.Repeat("0", 2048)})
+		require.NoError(t, err)
+	})
+
+	t.Run("Should fail when archive exceeds maximum size", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+		i := &Installer{log: &fakeLogger{}, opts: Opts{Limits: ExtractionLimits{MaxArchiveSize: 16}}}
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "*.zip")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tmpFile.Close()) }()
+
+		err = i.DownloadFile("test-panel", tmpFile, archive, "")
+		var limitErr *LimitExceededError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, "archive size", limitErr.Limit)
+	})
+}
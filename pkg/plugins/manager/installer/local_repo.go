@@ -0,0 +1,197 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// A local repository is a directory of plugin zips, in any layout, that's turned into a static plugin repository
+// by generating its index from the plugin.json of each zip. Zips named <anything>.<os>-<arch>.zip are listed for
+// that platform, all others for any platform. It's meant for standing up internal mirrors for testing and
+// air-gapped labs.
+
+// rePlatformZip matches the platform suffix of platform specific plugin zips.
+var rePlatformZip = regexp.MustCompile(`\.((?:linux|darwin|windows|freebsd)-(?:amd64|arm64|arm|386))\.zip$`)
+
+// localRepoPlugin is a plugin in the index of a local repository, listing the fields found by Search next to its
+// versions.
+type localRepoPlugin struct {
+	Plugin
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type"`
+	OrgName     string   `json:"orgName,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+}
+
+// localRepoPluginJSON holds the plugin.json fields listed in the index of a local repository.
+type localRepoPluginJSON struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Info struct {
+		Description string   `json:"description"`
+		Keywords    []string `json:"keywords"`
+		Version     string   `json:"version"`
+		Author      struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	} `json:"info"`
+	Dependencies struct {
+		GrafanaDependency string `json:"grafanaDependency"`
+	} `json:"dependencies"`
+}
+
+// WriteLocalRepoIndex generates the index of the plugin zips in the directory and writes it to its index.json, so
+// that the directory can be used as a static plugin repository.
+func (i *Installer) WriteLocalRepoIndex(dir string) error {
+	index, err := i.buildLocalRepoIndex(dir)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, repo.StaticIndexFile), index, 0640); err != nil {
+		return errutil.Wrap("failed to write plugin repository index", err)
+	}
+	return nil
+}
+
+// LocalRepoHandler serves the plugin zips in the directory as a static plugin repository, whose index is generated
+// on every request so that zips added to the directory are picked up right away. Only the index and the zips are
+// served.
+func (i *Installer) LocalRepoHandler(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := path.Clean("/" + r.URL.Path)
+		switch {
+		case name == "/"+repo.StaticIndexFile:
+			index, err := i.buildLocalRepoIndex(dir)
+			if err != nil {
+				i.log.Error("Failed to generate plugin repository index", "dir", dir, "err", err)
+				http.Error(w, "failed to generate plugin repository index", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(index)
+		case strings.HasSuffix(name, ".zip") && !hasHiddenElement(name):
+			files.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// buildLocalRepoIndex returns the index of the plugin zips in the directory, skipping hidden files and zips
+// without plugin.json.
+func (i *Installer) buildLocalRepoIndex(dir string) ([]byte, error) {
+	plugins := map[string]*localRepoPlugin{}
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(fi.Name(), ".") {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".zip") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		return i.addLocalRepoZip(plugins, p, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return nil, errutil.Wrap("failed to read plugin repository directory", err)
+	}
+
+	index := struct {
+		Plugins []localRepoPlugin `json:"plugins"`
+	}{Plugins: []localRepoPlugin{}}
+	for _, p := range plugins {
+		repo.SortVersions(p.Versions)
+		index.Plugins = append(index.Plugins, *p)
+	}
+	sort.Slice(index.Plugins, func(a, c int) bool {
+		return index.Plugins[a].ID < index.Plugins[c].ID
+	})
+	return json.MarshalIndent(index, "", "  ")
+}
+
+// addLocalRepoZip adds the plugin version of the zip, at the slash separated path relative to the repository, to
+// the repository plugins.
+func (i *Installer) addLocalRepoZip(repoPlugins map[string]*localRepoPlugin, zipPath, rel string) error {
+	data, err := i.readArchivePluginJSONData(zipPath, "")
+	if err != nil || data == nil {
+		i.log.Warn("Skipping zip without plugin.json", "file", rel, "err", err)
+		return nil
+	}
+	var pj localRepoPluginJSON
+	if err := json.Unmarshal(data, &pj); err != nil || pj.ID == "" || pj.Info.Version == "" {
+		i.log.Warn("Skipping zip with invalid plugin.json", "file", rel, "err", err)
+		return nil
+	}
+	sum, err := plugins.FileSHA256(zipPath)
+	if err != nil {
+		return err
+	}
+
+	p, exists := repoPlugins[pj.ID]
+	if !exists {
+		p = &localRepoPlugin{Plugin: Plugin{ID: pj.ID}}
+		repoPlugins[pj.ID] = p
+	}
+	p.Name, p.Type, p.Description = pj.Name, pj.Type, pj.Info.Description
+	p.OrgName, p.Keywords = pj.Info.Author.Name, pj.Info.Keywords
+
+	var v *Version
+	for idx := range p.Versions {
+		if p.Versions[idx].Version == pj.Info.Version {
+			v = &p.Versions[idx]
+		}
+	}
+	if v == nil {
+		p.Versions = append(p.Versions, Version{Version: pj.Info.Version,
+			GrafanaDependency: pj.Dependencies.GrafanaDependency, Arch: map[string]ArchMeta{}})
+		v = &p.Versions[len(p.Versions)-1]
+	}
+	arch := "any"
+	if m := rePlatformZip.FindStringSubmatch(rel); m != nil {
+		arch = m[1]
+	}
+	if existing, exists := v.Arch[arch]; exists {
+		return fmt.Errorf("%s and %s are both %s v%s for %s", existing.DownloadURL, rel, pj.ID, pj.Info.Version,
+			arch)
+	}
+	v.Arch[arch] = ArchMeta{SHA256: sum, DownloadURL: rel}
+	return nil
+}
+
+// hasHiddenElement returns whether any element of the slash separated path starts with a dot.
+func hasHiddenElement(name string) bool {
+	for _, e := range strings.Split(name, "/") {
+		if strings.HasPrefix(e, ".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package installer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalRepo(t *testing.T) {
+	newInstaller := func() *Installer {
+		return NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+	}
+	writeZip := func(t *testing.T, dir, name, pluginJSON string) {
+		data, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}))
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0600))
+	}
+	writeRepoDir := func(t *testing.T) string {
+		dir := t.TempDir()
+		writeZip(t, dir, "test-panel-1.0.0.zip", `{"id":"test-panel","type":"panel","name":"Test",
+			"info":{"version":"1.0.0","description":"A test panel"}}`)
+		writeZip(t, dir, "new/test-panel-2.0.0.zip", `{"id":"test-panel","type":"panel","name":"Test",
+			"info":{"version":"2.0.0","description":"A test panel"},"dependencies":{"grafanaDependency":">=7.0.0"}}`)
+		writeZip(t, dir, "test-app.linux-amd64.zip",
+			`{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"}}`)
+		writeZip(t, dir, "not-a-plugin.zip", `{}`)
+		writeZip(t, dir, ".hidden/test-panel-3.0.0.zip",
+			`{"id":"test-panel","type":"panel","name":"Test","info":{"version":"3.0.0"}}`)
+		return dir
+	}
+
+	t.Run("Should generate index from plugin zips", func(t *testing.T) {
+		dir := writeRepoDir(t)
+		data, err := newInstaller().buildLocalRepoIndex(dir)
+		require.NoError(t, err)
+
+		var index struct {
+			Plugins []localRepoPlugin `json:"plugins"`
+		}
+		require.NoError(t, json.Unmarshal(data, &index))
+		require.Len(t, index.Plugins, 2)
+
+		app := index.Plugins[0]
+		assert.Equal(t, "test-app", app.ID)
+		require.Len(t, app.Versions, 1)
+		assert.Equal(t, ArchMeta{SHA256: sha256Hex(t, filepath.Join(dir, "test-app.linux-amd64.zip")),
+			DownloadURL: "test-app.linux-amd64.zip"}, app.Versions[0].Arch["linux-amd64"])
+
+		panel := index.Plugins[1]
+		assert.Equal(t, "test-panel", panel.ID)
+		assert.Equal(t, "panel", panel.Type)
+		assert.Equal(t, "A test panel", panel.Description)
+		require.Len(t, panel.Versions, 2)
+		assert.Equal(t, "2.0.0", panel.Versions[0].Version)
+		assert.Equal(t, ">=7.0.0", panel.Versions[0].GrafanaDependency)
+		assert.Equal(t, "new/test-panel-2.0.0.zip", panel.Versions[0].Arch["any"].DownloadURL)
+		assert.Equal(t, "1.0.0", panel.Versions[1].Version)
+	})
+
+	t.Run("Should fail for duplicate plugin versions", func(t *testing.T) {
+		dir := writeRepoDir(t)
+		writeZip(t, dir, "copy/test-panel.zip", `{"id":"test-panel","type":"panel","name":"Test",
+			"info":{"version":"1.0.0"}}`)
+
+		_, err := newInstaller().buildLocalRepoIndex(dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "are both test-panel v1.0.0 for any")
+	})
+
+	t.Run("Should install from written index", func(t *testing.T) {
+		dir := writeRepoDir(t)
+		require.NoError(t, newInstaller().WriteLocalRepoIndex(dir))
+
+		pluginsDir := t.TempDir()
+		require.NoError(t, newInstaller().Install("test-panel", "", pluginsDir, "", dir))
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"version":"2.0.0"`)
+	})
+
+	t.Run("Should serve index and zips over HTTP", func(t *testing.T) {
+		dir := writeRepoDir(t)
+		server := httptest.NewServer(newInstaller().LocalRepoHandler(dir))
+		defer server.Close()
+
+		pluginsDir := t.TempDir()
+		require.NoError(t, newInstaller().Install("test-panel", "1.0.0", pluginsDir, "", server.URL+"/index.json"))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+
+		for _, p := range []string{"/.hidden/test-panel-3.0.0.zip", "/new/", "/missing.zip"} {
+			resp, err := http.Get(server.URL + p)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, p)
+		}
+	})
+}
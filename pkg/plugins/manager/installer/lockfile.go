@@ -0,0 +1,208 @@
+package installer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"github.com/hashicorp/go-version"
+)
+
+// lockfileVersion is the version of the lockfile format.
+const lockfileVersion = 1
+
+// Lockfile pins every installed plugin, including dependencies, to the exact version, archive and checksum it was
+// installed from, so that the same plugin set can be installed again byte for byte.
+type Lockfile struct {
+	LockfileVersion int            `json:"lockfileVersion"`
+	Plugins         []LockedPlugin `json:"plugins"`
+}
+
+// LockedPlugin is a plugin pinned by the lockfile.
+type LockedPlugin struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	// Source is the URL or path of the archive the plugin was installed from, without user info. Credentials of the
+	// repository are applied when installing from it again.
+	Source string `json:"source"`
+	// Checksum is the SHA256 checksum of the archive, as sha256:<hex digest>.
+	Checksum string `json:"checksum"`
+	// Dependencies are the IDs of the plugins the plugin depends on, which are pinned by the lockfile as well.
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// ReadLockfile reads the lockfile at the path.
+func ReadLockfile(path string) (*Lockfile, error) {
+	// nolint:gosec
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errutil.Wrap("failed to read lockfile", err)
+	}
+	var l Lockfile
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, errutil.Wrapf(err, "invalid lockfile %s", path)
+	}
+	if l.LockfileVersion != lockfileVersion {
+		return nil, fmt.Errorf("unsupported version %d of lockfile %s", l.LockfileVersion, path)
+	}
+	for idx, p := range l.Plugins {
+		if p.ID == "" || p.Version == "" || p.Source == "" {
+			return nil, fmt.Errorf("invalid lockfile %s: plugins[%d] requires an id, version and source", path, idx)
+		}
+		if _, err := parseChecksum(p.Checksum); err != nil {
+			return nil, fmt.Errorf("invalid lockfile %s: plugins[%d].checksum: %w", path, idx, err)
+		}
+	}
+	return &l, nil
+}
+
+func (l *Lockfile) plugin(pluginID string) (LockedPlugin, bool) {
+	for _, p := range l.Plugins {
+		if p.ID == pluginID {
+			return p, true
+		}
+	}
+	return LockedPlugin{}, false
+}
+
+// roots returns the locked plugins no other locked plugin depends on.
+func (l *Lockfile) roots() []LockedPlugin {
+	dependencies := map[string]bool{}
+	for _, p := range l.Plugins {
+		for _, dep := range p.Dependencies {
+			if dep != p.ID {
+				dependencies[dep] = true
+			}
+		}
+	}
+	var roots []LockedPlugin
+	for _, p := range l.Plugins {
+		if !dependencies[p.ID] {
+			roots = append(roots, p)
+		}
+	}
+	return roots
+}
+
+// lockedPlugin returns the plugin pinned by the lockfile, failing if it's missing or pinned to a version that
+// doesn't satisfy the requested version or version range.
+func (i *Installer) lockedPlugin(pluginID, requestedVersion string) (LockedPlugin, error) {
+	l, err := ReadLockfile(i.opts.LockfilePath)
+	if err != nil {
+		return LockedPlugin{}, err
+	}
+	p, exists := l.plugin(pluginID)
+	if !exists {
+		return LockedPlugin{}, fmt.Errorf("%s is missing from the lockfile %s", pluginID, i.opts.LockfilePath)
+	}
+	if requestedVersion == "" || requestedVersion == p.Version {
+		return p, nil
+	}
+	if v, err := version.NewVersion(p.Version); err == nil {
+		if satisfied, err := repo.VersionInRange(v, requestedVersion); err == nil && satisfied {
+			return p, nil
+		}
+	}
+	return LockedPlugin{}, fmt.Errorf("%s is locked to v%s, which doesn't satisfy the requested version %s",
+		pluginID, p.Version, requestedVersion)
+}
+
+// lockPlugin records the plugin installed from the archive downloaded from its source in the lockfile, replacing
+// a previous entry of the plugin. The resolved version is recorded if the installed plugin.json has none. It does
+// nothing if no lockfile is configured or plugins are installed from it.
+func (i *Installer) lockPlugin(pending *pendingInstall, installed InstalledPlugin) error {
+	if i.opts.LockfilePath == "" || i.opts.FromLockfile {
+		return nil
+	}
+	pluginID, version, source := pending.pluginID, pending.version, pending.pluginZipURL
+	sum, err := pending.archiveSum()
+	if err != nil {
+		return errutil.Wrap("failed to compute checksum of plugin archive", err)
+	}
+	source = withoutURLCredentials(source)
+	if installed.Info.Version != "" {
+		version = installed.Info.Version
+	}
+	if version == "" {
+		return fmt.Errorf("failed to lock %s, its version is unknown", pluginID)
+	}
+	p := LockedPlugin{ID: pluginID, Version: version, Source: source, Checksum: "sha256:" + sum}
+	for _, dep := range sortedDependencies(installed.Dependencies.Plugins) {
+		p.Dependencies = append(p.Dependencies, dep.ID)
+	}
+
+	l := &Lockfile{LockfileVersion: lockfileVersion}
+	if _, err := os.Stat(i.opts.LockfilePath); err == nil {
+		if l, err = ReadLockfile(i.opts.LockfilePath); err != nil {
+			return err
+		}
+	}
+
+	plugins := []LockedPlugin{p}
+	for _, existing := range l.Plugins {
+		if existing.ID != p.ID {
+			plugins = append(plugins, existing)
+		}
+	}
+	sort.Slice(plugins, func(a, b int) bool { return plugins[a].ID < plugins[b].ID })
+	l.Plugins = plugins
+	return writeLockfile(i.opts.LockfilePath, l)
+}
+
+func writeLockfile(path string, l *Lockfile) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".plugins-lock-*")
+	if err != nil {
+		return errutil.Wrap("failed to write lockfile", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return errutil.Wrap("failed to write lockfile", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return errutil.Wrap("failed to write lockfile", err)
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// InstallFromLockfile installs all plugins pinned by the lockfile, with only the pinned versions, archives and
+// checksums. Plugins other plugins depend on are installed as their dependencies.
+func (i *Installer) InstallFromLockfile(pluginsDir string) error {
+	if !i.opts.FromLockfile || i.opts.LockfilePath == "" {
+		return errors.New("installing from a lockfile requires a lockfile")
+	}
+	l, err := ReadLockfile(i.opts.LockfilePath)
+	if err != nil {
+		return err
+	}
+	roots := l.roots()
+	if len(roots) == 0 && len(l.Plugins) > 0 {
+		return fmt.Errorf("lockfile %s has no plugin that isn't a dependency of another", i.opts.LockfilePath)
+	}
+	refs := make([]string, 0, len(roots))
+	for _, p := range roots {
+		refs = append(refs, p.ID+"@"+p.Version)
+	}
+	return i.InstallAll(refs, pluginsDir, "")
+}
+
+// withoutURLCredentials removes the user info of a URL, so that credentials aren't written to disk.
+func withoutURLCredentials(source string) string {
+	if u, err := url.Parse(source); err == nil && u.Scheme != "" && u.User != nil {
+		u.User = nil
+		return u.String()
+	}
+	return source
+}
@@ -0,0 +1,94 @@
+package installer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockfile(t *testing.T) {
+	writeRepo := func(t *testing.T) string {
+		return writeTestDependencyRepo(t, map[string]string{
+			"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+				"dependencies":{"plugins":[{"id":"test-panel","type":"panel","version":"^1.0.0"}]}}`,
+			"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`,
+		})
+	}
+	newInstaller := func(lockfilePath string, fromLockfile bool) *Installer {
+		return NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, LockfilePath: lockfilePath,
+			FromLockfile: fromLockfile}, "8.0.0", &fakeLogger{})
+	}
+	readVersion := func(t *testing.T, pluginsDir, pluginID string) string {
+		p, err := toPluginDTO(pluginsDir, pluginID)
+		require.NoError(t, err)
+		return p.Info.Version
+	}
+
+	t.Run("Should record installed plugins and their dependencies", func(t *testing.T) {
+		repoDir := writeRepo(t)
+		lockfilePath := filepath.Join(t.TempDir(), "plugins.lock")
+
+		require.NoError(t, newInstaller(lockfilePath, false).Install("test-app", "", t.TempDir(), "", repoDir))
+		l, err := ReadLockfile(lockfilePath)
+		require.NoError(t, err)
+		assert.Equal(t, &Lockfile{LockfileVersion: 1, Plugins: []LockedPlugin{
+			{ID: "test-app", Version: "1.0.0", Source: filepath.Join(repoDir, "test-app-1.0.0.zip"),
+				Checksum:     "sha256:" + sha256Hex(t, filepath.Join(repoDir, "test-app-1.0.0.zip")),
+				Dependencies: []string{"test-panel"}},
+			{ID: "test-panel", Version: "1.0.0", Source: filepath.Join(repoDir, "test-panel-1.0.0.zip"),
+				Checksum: "sha256:" + sha256Hex(t, filepath.Join(repoDir, "test-panel-1.0.0.zip"))},
+		}}, l)
+	})
+
+	t.Run("Should install only the pinned plugins", func(t *testing.T) {
+		repoDir := writeRepo(t)
+		lockfilePath := filepath.Join(t.TempDir(), "plugins.lock")
+		require.NoError(t, newInstaller(lockfilePath, false).Install("test-app", "", t.TempDir(), "", repoDir))
+
+		pluginsDir := t.TempDir()
+		require.NoError(t, newInstaller(lockfilePath, true).InstallFromLockfile(pluginsDir))
+		assert.Equal(t, "1.0.0", readVersion(t, pluginsDir, "test-app"))
+		assert.Equal(t, "1.0.0", readVersion(t, pluginsDir, "test-panel"))
+
+		pluginsDir = t.TempDir()
+		require.NoError(t, newInstaller(lockfilePath, true).Install("test-panel", "", pluginsDir, "", ""))
+		assert.Equal(t, "1.0.0", readVersion(t, pluginsDir, "test-panel"))
+
+		err := newInstaller(lockfilePath, true).Install("test-datasource", "", t.TempDir(), "", repoDir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "test-datasource is missing from the lockfile")
+
+		err = newInstaller(lockfilePath, true).Install("test-panel", "^2.0.0", t.TempDir(), "", repoDir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "test-panel is locked to v1.0.0, which doesn't satisfy the requested version")
+	})
+
+	t.Run("Should refuse archives not matching the pinned checksum", func(t *testing.T) {
+		repoDir := writeRepo(t)
+		lockfilePath := filepath.Join(t.TempDir(), "plugins.lock")
+		require.NoError(t, newInstaller(lockfilePath, false).Install("test-panel", "", t.TempDir(), "", repoDir))
+
+		data, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{
+			"plugin.json": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"},"x":1}`}))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(repoDir, "test-panel-1.0.0.zip"), data, 0600))
+
+		err = newInstaller(lockfilePath, true).InstallFromLockfile(t.TempDir())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum")
+	})
+
+	t.Run("Should reject invalid lockfiles", func(t *testing.T) {
+		lockfilePath := filepath.Join(t.TempDir(), "plugins.lock")
+		require.NoError(t, ioutil.WriteFile(lockfilePath, []byte(`{"lockfileVersion":1,"plugins":[
+			{"id":"test-panel","version":"1.0.0","source":"https://example.com/test-panel.zip","checksum":"abc"}]}`),
+			0600))
+
+		_, err := ReadLockfile(lockfilePath)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "plugins[0].checksum")
+	})
+}
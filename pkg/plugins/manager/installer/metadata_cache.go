@@ -0,0 +1,190 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultMetadataCacheMaxStale = 24 * time.Hour
+
+// MetadataCacheOpts configures the on-disk cache of plugin repository metadata. Cached metadata is revalidated with
+// conditional requests, and used when the repository is unavailable as long as it's not too stale.
+type MetadataCacheOpts struct {
+	// Dir is the directory the metadata is cached in. Metadata is only cached if it's set.
+	Dir string
+	// MaxAge is how long cached metadata is used without revalidating it. Defaults to always revalidating.
+	MaxAge time.Duration
+	// MaxStale is how long after it was last fetched or revalidated cached metadata is used when the repository
+	// can't be reached or responds with a server error. Defaults to 24 hours, negative values disable it.
+	MaxStale time.Duration
+}
+
+// metadataCacheEntry is a cached metadata response.
+type metadataCacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+	Body         []byte    `json:"body"`
+}
+
+func (e *metadataCacheEntry) age() time.Duration {
+	return time.Since(e.FetchedAt)
+}
+
+// sendCachedRequestGetBytes fetches plugin repository metadata like sendRequestGetBytes, using the metadata cache
+// if it's configured. The metadata is verified against the TUF targets of the repository if TUF is configured.
+func (i *Installer) sendCachedRequestGetBytes(URL string, subPaths ...string) ([]byte, error) {
+	body, err := i.sendCachedRequest(URL, subPaths...)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.verifyTUFTarget(tufMetadataURL(URL, subPaths...), body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (i *Installer) sendCachedRequest(URL string, subPaths ...string) ([]byte, error) {
+	opts := i.opts.MetadataCache
+	if opts.Dir == "" {
+		return i.sendRequestGetBytes(URL, subPaths...)
+	}
+
+	req, err := i.createRequest(URL, subPaths...)
+	if err != nil {
+		return nil, err
+	}
+	requestURL := req.URL.String()
+	path := metadataCachePath(opts.Dir, requestURL)
+	entry := readMetadataCacheEntry(path, requestURL)
+	if entry != nil && opts.MaxAge > 0 && entry.age() < opts.MaxAge {
+		i.log.Debugf("Using cached metadata of %s", RedactURL(requestURL))
+		return entry.Body, nil
+	}
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	body, res, err := i.doMetadataRequest(req)
+	if err != nil {
+		if entry != nil && isMirrorFailure(err) && entry.age() < metadataCacheMaxStale(opts) {
+			i.log.Warnf("Failed to fetch %s, using metadata cached %s ago: %v", RedactURL(requestURL),
+				entry.age().Round(time.Second), err)
+			return entry.Body, nil
+		}
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotModified && entry != nil {
+		i.log.Debugf("Cached metadata of %s is up to date", RedactURL(requestURL))
+		body = entry.Body
+	} else {
+		entry = &metadataCacheEntry{URL: requestURL, ETag: res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"), Body: body}
+	}
+	entry.FetchedAt = time.Now()
+	if err := writeMetadataCacheEntry(path, entry); err != nil {
+		i.log.Warnf("Failed to cache metadata of %s: %v", RedactURL(requestURL), err)
+	}
+	return body, nil
+}
+
+// doMetadataRequest sends the request and returns the body of successful responses. 304 Not Modified responses
+// are returned without a body.
+func (i *Installer) doMetadataRequest(req *http.Request) ([]byte, *http.Response, error) {
+	client, err := i.clientFor(req.URL, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := i.doWithRetry(client, req, isRetryable)
+	if err != nil {
+		return nil, nil, RedactURLError(err)
+	}
+	if res.StatusCode == http.StatusNotModified {
+		if err := res.Body.Close(); err != nil {
+			i.log.Warn("Failed to close response body", "err", err)
+		}
+		return nil, res, nil
+	}
+
+	bodyReader, err := i.handleResponse(res)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err := bodyReader.Close(); err != nil {
+			i.log.Warn("Failed to close stream", "err", err)
+		}
+	}()
+	body, err := ioutil.ReadAll(bodyReader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, res, nil
+}
+
+func metadataCacheMaxStale(opts MetadataCacheOpts) time.Duration {
+	if opts.MaxStale == 0 {
+		return defaultMetadataCacheMaxStale
+	}
+	return opts.MaxStale
+}
+
+// metadataCachePath returns the cache file of the URL, which is named after the URL's hash so that it doesn't
+// reveal the URL.
+func metadataCachePath(dir, requestURL string) string {
+	sum := sha256.Sum256([]byte(requestURL))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// readMetadataCacheEntry returns the cached response for the URL, or nil if there's none.
+func readMetadataCacheEntry(path, requestURL string) *metadataCacheEntry {
+	// nolint:gosec
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry metadataCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != requestURL {
+		return nil
+	}
+	return &entry
+}
+
+// writeMetadataCacheEntry atomically replaces the cache file, so that concurrent installs never read a partially
+// written entry.
+func writeMetadataCacheEntry(path string, entry *metadataCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".metadata-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
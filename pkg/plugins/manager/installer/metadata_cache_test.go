@@ -0,0 +1,108 @@
+package installer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataCache(t *testing.T) {
+	type repo struct {
+		server    *httptest.Server
+		available bool
+		requests  []http.Header
+	}
+	newRepo := func(t *testing.T) *repo {
+		r := &repo{available: true}
+		r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			r.requests = append(r.requests, req.Header.Clone())
+			if !r.available {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if req.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+			_, _ = w.Write([]byte(`{"id":"test-panel"}`))
+		}))
+		t.Cleanup(r.server.Close)
+		return r
+	}
+	newInstaller := func(opts MetadataCacheOpts) *Installer {
+		return NewWithOpts(Opts{MetadataCache: opts, Retry: noRetry}, "8.0.0", &fakeLogger{})
+	}
+
+	t.Run("Should revalidate cached metadata with conditional requests", func(t *testing.T) {
+		r := newRepo(t)
+		i := newInstaller(MetadataCacheOpts{Dir: t.TempDir()})
+
+		for n := 0; n < 2; n++ {
+			body, err := i.sendCachedRequestGetBytes(r.server.URL, "repo", "test-panel")
+			require.NoError(t, err)
+			assert.Equal(t, `{"id":"test-panel"}`, string(body))
+		}
+		require.Len(t, r.requests, 2)
+		assert.Empty(t, r.requests[0].Get("If-None-Match"))
+		assert.Equal(t, `"v1"`, r.requests[1].Get("If-None-Match"))
+		assert.Equal(t, "Wed, 21 Oct 2015 07:28:00 GMT", r.requests[1].Get("If-Modified-Since"))
+	})
+
+	t.Run("Should use fresh cached metadata without requests", func(t *testing.T) {
+		r := newRepo(t)
+		i := newInstaller(MetadataCacheOpts{Dir: t.TempDir(), MaxAge: time.Hour})
+
+		for n := 0; n < 3; n++ {
+			_, err := i.sendCachedRequestGetBytes(r.server.URL, "repo", "test-panel")
+			require.NoError(t, err)
+		}
+		assert.Len(t, r.requests, 1)
+	})
+
+	t.Run("Should use stale cached metadata when repository is unavailable", func(t *testing.T) {
+		r := newRepo(t)
+		i := newInstaller(MetadataCacheOpts{Dir: t.TempDir()})
+
+		_, err := i.sendCachedRequestGetBytes(r.server.URL, "repo", "test-panel")
+		require.NoError(t, err)
+		r.available = false
+		body, err := i.sendCachedRequestGetBytes(r.server.URL, "repo", "test-panel")
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":"test-panel"}`, string(body))
+
+		r.server.Close()
+		body, err = i.sendCachedRequestGetBytes(r.server.URL, "repo", "test-panel")
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":"test-panel"}`, string(body))
+	})
+
+	t.Run("Should fail when cached metadata is too stale", func(t *testing.T) {
+		r := newRepo(t)
+		i := newInstaller(MetadataCacheOpts{Dir: t.TempDir(), MaxStale: -1})
+
+		_, err := i.sendCachedRequestGetBytes(r.server.URL, "repo", "test-panel")
+		require.NoError(t, err)
+		r.available = false
+		_, err = i.sendCachedRequestGetBytes(r.server.URL, "repo", "test-panel")
+		require.Error(t, err)
+	})
+
+	t.Run("Should not cache errors", func(t *testing.T) {
+		r := newRepo(t)
+		r.available = false
+		i := newInstaller(MetadataCacheOpts{Dir: t.TempDir()})
+
+		_, err := i.sendCachedRequestGetBytes(r.server.URL, "repo", "test-panel")
+		require.Error(t, err)
+		r.available = true
+		body, err := i.sendCachedRequestGetBytes(r.server.URL, "repo", "test-panel")
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":"test-panel"}`, string(body))
+	})
+}
@@ -0,0 +1,156 @@
+package installer
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMirrorProbeInterval = 10 * time.Minute
+	mirrorProbeTimeout         = 5 * time.Second
+)
+
+// MirrorSelectionOpts configures how the repository a plugin archive is downloaded from is chosen among the plugin
+// repository and its mirrors.
+type MirrorSelectionOpts struct {
+	// ByLatency probes the download URLs of an archive before downloading it and tries them in order of their
+	// latency instead of the configured order, so that hosts use their nearest mirror. Plugin metadata is still
+	// looked up in the configured order, as it's small.
+	ByLatency bool
+	// ProbeInterval is how long the measured latency of a host is reused before it's probed again. Defaults to
+	// 10 minutes.
+	ProbeInterval time.Duration
+}
+
+// mirrorProbe is the measured latency of a download host.
+type mirrorProbe struct {
+	latency time.Duration
+	// failed is set if the host couldn't be reached or responded with a server error.
+	failed   bool
+	probedAt time.Time
+}
+
+// mirrorLatencies holds the latency of the download hosts probed by the installer, by their scheme and host.
+type mirrorLatencies struct {
+	mu     sync.Mutex
+	probes map[string]mirrorProbe
+}
+
+// orderByLatency returns the download URLs ordered by the latency of their hosts if mirrors are selected by
+// latency, reachable hosts first. Hosts with the same latency, and URLs that aren't probed, keep their order. Hosts
+// are probed again once their latency is older than the probe interval.
+func (i *Installer) orderByLatency(downloadURLs []string) []string {
+	if !i.opts.MirrorSelection.ByLatency || len(downloadURLs) < 2 {
+		return downloadURLs
+	}
+	interval := i.opts.MirrorSelection.ProbeInterval
+	if interval <= 0 {
+		interval = defaultMirrorProbeInterval
+	}
+
+	// Probing runs under the lock, so that concurrent downloads don't probe the same hosts again
+	i.mirrorLatencies.mu.Lock()
+	defer i.mirrorLatencies.mu.Unlock()
+	if i.mirrorLatencies.probes == nil {
+		i.mirrorLatencies.probes = map[string]mirrorProbe{}
+	}
+
+	probes := make([]mirrorProbe, len(downloadURLs))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for idx, downloadURL := range downloadURLs {
+		origin := downloadOrigin(downloadURL)
+		if origin == "" {
+			continue
+		}
+		if probe, ok := i.mirrorLatencies.probes[origin]; ok && time.Since(probe.probedAt) < interval {
+			probes[idx] = probe
+			continue
+		}
+		wg.Add(1)
+		go func(idx int, downloadURL, origin string) {
+			defer wg.Done()
+			probe := i.probeMirror(downloadURL)
+			mu.Lock()
+			defer mu.Unlock()
+			probes[idx] = probe
+			i.mirrorLatencies.probes[origin] = probe
+		}(idx, downloadURL, origin)
+	}
+	wg.Wait()
+
+	order := make([]int, len(downloadURLs))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		pa, pb := probes[order[a]], probes[order[b]]
+		if pa.failed != pb.failed {
+			return !pa.failed
+		}
+		return pa.latency < pb.latency
+	})
+	ordered := make([]string, len(downloadURLs))
+	for idx, from := range order {
+		ordered[idx] = downloadURLs[from]
+		if probes[from].failed {
+			i.log.Debugf("Download host of %s is unavailable", RedactURL(downloadURLs[from]))
+		} else {
+			i.log.Debugf("Download host of %s responded in %s", RedactURL(downloadURLs[from]),
+				probes[from].latency.Round(time.Millisecond))
+		}
+	}
+	return ordered
+}
+
+// probeMirror sends a HEAD request to the download URL and measures the time until the response arrives. Any
+// response other than a server error counts, as the URL is only probed for the latency of its host. URLs not
+// permitted by the source policy aren't requested and count as failed.
+func (i *Installer) probeMirror(downloadURL string) mirrorProbe {
+	probe := mirrorProbe{failed: true, probedAt: time.Now()}
+	if err := i.checkSourceAllowed(downloadURL); err != nil {
+		return probe
+	}
+	req, err := i.createRequest(downloadURL)
+	if err != nil {
+		return probe
+	}
+	req.Method = http.MethodHead
+	client, err := i.clientFor(req.URL, false)
+	if err != nil {
+		return probe
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), mirrorProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		i.log.Debugf("Failed to probe %s: %v", RedactURL(downloadURL), RedactURLError(err))
+		return probe
+	}
+	probe.latency = time.Since(start)
+	if err := res.Body.Close(); err != nil {
+		i.log.Warn("Failed to close response body", "err", err)
+	}
+	probe.failed = res.StatusCode >= 500
+	return probe
+}
+
+// downloadOrigin returns the scheme and host of a remote download URL, the key its latency is measured by, or an
+// empty string for local archives.
+func downloadOrigin(downloadURL string) string {
+	if _, err := os.Stat(downloadURL); err == nil {
+		return ""
+	}
+	u, err := url.Parse(downloadURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,108 @@
+package installer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorSelectionByLatency(t *testing.T) {
+	newHost := func(t *testing.T, delay time.Duration, status int) (*httptest.Server, *int32) {
+		var probes int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				atomic.AddInt32(&probes, 1)
+				time.Sleep(delay)
+			}
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(server.Close)
+		return server, &probes
+	}
+
+	t.Run("Should order download URLs by the latency of their hosts", func(t *testing.T) {
+		slow, _ := newHost(t, 200*time.Millisecond, http.StatusOK)
+		fast, _ := newHost(t, 0, http.StatusOK)
+		failing, _ := newHost(t, 0, http.StatusServiceUnavailable)
+		i := NewWithOpts(Opts{MirrorSelection: MirrorSelectionOpts{ByLatency: true}}, "8.0.0", &fakeLogger{})
+
+		ordered := i.orderByLatency([]string{failing.URL + "/a.zip", slow.URL + "/a.zip", fast.URL + "/a.zip"})
+		assert.Equal(t, []string{fast.URL + "/a.zip", slow.URL + "/a.zip", failing.URL + "/a.zip"}, ordered)
+	})
+
+	t.Run("Should keep the configured order if not enabled", func(t *testing.T) {
+		slow, probes := newHost(t, 200*time.Millisecond, http.StatusOK)
+		fast, _ := newHost(t, 0, http.StatusOK)
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+
+		downloadURLs := []string{slow.URL, fast.URL}
+		assert.Equal(t, downloadURLs, i.orderByLatency(downloadURLs))
+		assert.Zero(t, atomic.LoadInt32(probes))
+	})
+
+	t.Run("Should probe hosts again after the probe interval", func(t *testing.T) {
+		a, aProbes := newHost(t, 0, http.StatusOK)
+		b, _ := newHost(t, 0, http.StatusOK)
+		downloadURLs := []string{a.URL + "/a.zip", b.URL + "/a.zip"}
+
+		i := NewWithOpts(Opts{MirrorSelection: MirrorSelectionOpts{ByLatency: true}}, "8.0.0", &fakeLogger{})
+		i.orderByLatency(downloadURLs)
+		i.orderByLatency(append(downloadURLs, a.URL+"/b.zip"))
+		assert.Equal(t, int32(1), atomic.LoadInt32(aProbes))
+
+		i.mirrorLatencies.probes[downloadOrigin(a.URL)] = mirrorProbe{probedAt: time.Now().Add(-time.Hour)}
+		i.orderByLatency(downloadURLs)
+		assert.Equal(t, int32(2), atomic.LoadInt32(aProbes))
+	})
+
+	t.Run("Should not probe hosts the source policy doesn't allow", func(t *testing.T) {
+		allowed, _ := newHost(t, 200*time.Millisecond, http.StatusOK)
+		denied, deniedProbes := newHost(t, 0, http.StatusOK)
+		i := NewWithOpts(Opts{MirrorSelection: MirrorSelectionOpts{ByLatency: true},
+			AllowedSources: []string{allowed.URL}}, "8.0.0", &fakeLogger{})
+
+		ordered := i.orderByLatency([]string{denied.URL + "/a.zip", allowed.URL + "/a.zip"})
+		assert.Equal(t, []string{allowed.URL + "/a.zip", denied.URL + "/a.zip"}, ordered)
+		assert.Zero(t, atomic.LoadInt32(deniedProbes))
+	})
+
+	t.Run("Should download from the fastest mirror", func(t *testing.T) {
+		const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+		var primaryDownloads, mirrorDownloads int32
+		newRepo := func(delay time.Duration, downloads *int32) *httptest.Server {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(delay)
+				switch r.URL.Path {
+				case "/repo/test-panel":
+					_, _ = w.Write([]byte(`{"id": "test-panel", "versions": [{"version": "1.0.0"}]}`))
+				case "/test-panel/versions/1.0.0/download":
+					if r.Method == http.MethodGet {
+						atomic.AddInt32(downloads, 1)
+					}
+					http.ServeFile(w, r, archive)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(server.Close)
+			return server
+		}
+		primary := newRepo(200*time.Millisecond, &primaryDownloads)
+		mirror := newRepo(0, &mirrorDownloads)
+		pluginsDir := t.TempDir()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, RepoMirrors: []string{mirror.URL}, Retry: noRetry,
+			MirrorSelection: MirrorSelectionOpts{ByLatency: true}}, "8.0.0", &fakeLogger{})
+
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, "", primary.URL))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		assert.Zero(t, atomic.LoadInt32(&primaryDownloads))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&mirrorDownloads))
+	})
+}
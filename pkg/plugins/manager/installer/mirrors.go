@@ -0,0 +1,122 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// statusError is returned for responses with an unexpected status code.
+type statusError struct {
+	Status     string
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("API returned invalid status: %s", e.Status)
+}
+
+// isMirrorFailure reports whether the error means the repository is unavailable, that is the connection failed,
+// a download was truncated or corrupted in transit, it responded with a server error or it rate limits the
+// installer, so that the next mirror should be tried. Other errors, like a plugin that doesn't exist, fail right
+// away. Checksum mismatches are retried with the next mirror by the downloads, see checksumMismatches.
+func isMirrorFailure(err error) bool {
+	var rateLimitErr *RateLimitError
+	var truncatedErr *TruncatedDownloadError
+	var digestErr *ContentDigestError
+	if errors.As(err, &rateLimitErr) || errors.As(err, &truncatedErr) || errors.As(err, &digestErr) {
+		return true
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// repoURLs returns the plugin repository followed by the configured mirrors. Mirrors only apply to the plugin
+// repository, not to plugins resolved through a source alias or the enterprise repository.
+func (i *Installer) repoURLs(pluginRepoURL string, mirrored bool) []string {
+	repoURLs := []string{strings.TrimSuffix(pluginRepoURL, "/")}
+	if !mirrored {
+		return repoURLs
+	}
+	for _, mirror := range i.opts.RepoMirrors {
+		mirror = strings.TrimSuffix(strings.TrimSpace(mirror), "/")
+		if mirror != "" && !containsString(repoURLs, mirror) {
+			repoURLs = append(repoURLs, mirror)
+		}
+	}
+	return repoURLs
+}
+
+// getPluginMetadataFromMirrors looks up the plugin in each repository in turn until one is available. It returns
+// the metadata and the repository that served it.
+func (i *Installer) getPluginMetadataFromMirrors(pluginID string, repoURLs []string) (Plugin, string, error) {
+	var err error
+	for idx, repoURL := range repoURLs {
+		var plugin Plugin
+		if plugin, err = i.getPluginMetadataFromPluginRepo(pluginID, repoURL); err == nil {
+			return plugin, repoURL, nil
+		}
+		if !isMirrorFailure(err) || idx == len(repoURLs)-1 {
+			break
+		}
+		i.log.Warnf("Plugin repository %s is unavailable, trying %s: %v", RedactURL(repoURL),
+			RedactURL(repoURLs[idx+1]), err)
+	}
+	return Plugin{}, "", err
+}
+
+// downloadFromMirrors downloads the plugin archive from each URL in turn, ordered by latency if configured, until
+// one is available and serves an archive matching the checksum, returning the URL it was downloaded from and the
+// statistics of the download. Each URL has to be permitted by the source policy.
+func (i *Installer) downloadFromMirrors(pluginID string, tmpFile *os.File, downloadURLs []string,
+	checksum string) (string, *DownloadStats, error) {
+	primary := downloadURLs[0]
+	downloadURLs = i.orderByLatency(downloadURLs)
+	var mismatches checksumMismatches
+	var err error
+	for idx, downloadURL := range downloadURLs {
+		if downloadURL != primary {
+			if err := i.checkSourceAllowed(downloadURL); err != nil {
+				return "", nil, err
+			}
+		}
+		if idx > 0 {
+			if err := tmpFile.Truncate(0); err != nil {
+				return "", nil, err
+			}
+			if _, err := tmpFile.Seek(0, 0); err != nil {
+				return "", nil, err
+			}
+		}
+		start := time.Now()
+		if err = i.DownloadFile(pluginID, tmpFile, downloadURL, checksum); err == nil {
+			mismatches.warnRecovered(i, downloadURL)
+			if _, err := os.Stat(downloadURL); err == nil {
+				// Local archives aren't downloaded
+				return downloadURL, nil, nil
+			}
+			var size int64
+			if fi, err := tmpFile.Stat(); err == nil {
+				size = fi.Size()
+			}
+			stats := newDownloadStats(size, start, idx+1)
+			i.logDownloadStats(pluginID, downloadURL, stats)
+			return downloadURL, stats, nil
+		}
+		if !mismatches.add(err) && !isMirrorFailure(err) || idx == len(downloadURLs)-1 {
+			break
+		}
+		i.log.Warnf("Failed to download %s, trying %s: %v", RedactURL(downloadURL),
+			RedactURL(downloadURLs[idx+1]), err)
+	}
+	return "", nil, mismatches.error(err)
+}
@@ -0,0 +1,102 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoMirrors(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+	archive, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}))
+	require.NoError(t, err)
+
+	newRepo := func(t *testing.T, metadataStatus, downloadStatus int) (*httptest.Server, *[]string) {
+		var requests []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.URL.Path)
+			switch r.URL.Path {
+			case "/repo/test-panel":
+				w.WriteHeader(metadataStatus)
+				_, _ = w.Write([]byte(`{"id": "test-panel", "versions": [{"version": "1.0.0"}]}`))
+			case "/test-panel/versions/1.0.0/download":
+				w.WriteHeader(downloadStatus)
+				_, _ = w.Write(archive)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		t.Cleanup(server.Close)
+		return server, &requests
+	}
+
+	t.Run("Should fail over to mirror when repository responds with server error", func(t *testing.T) {
+		primary, primaryRequests := newRepo(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		mirror, mirrorRequests := newRepo(t, http.StatusOK, http.StatusOK)
+		pluginsDir := t.TempDir()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, RepoMirrors: []string{mirror.URL}, Retry: noRetry},
+			"8.0.0", &fakeLogger{})
+
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, "", primary.URL))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		assert.Equal(t, []string{"/repo/test-panel"}, *primaryRequests)
+		assert.Equal(t, []string{"/repo/test-panel", "/test-panel/versions/1.0.0/download"}, *mirrorRequests)
+	})
+
+	t.Run("Should fail over to mirror when repository can't be reached", func(t *testing.T) {
+		primary, _ := newRepo(t, http.StatusOK, http.StatusOK)
+		primary.Close()
+		mirror, _ := newRepo(t, http.StatusOK, http.StatusOK)
+		pluginsDir := t.TempDir()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, RepoMirrors: []string{mirror.URL + "/"}, Retry: noRetry},
+			"8.0.0", &fakeLogger{})
+
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, "", primary.URL))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+	})
+
+	t.Run("Should fail over to mirror when download fails", func(t *testing.T) {
+		primary, _ := newRepo(t, http.StatusOK, http.StatusBadGateway)
+		mirror, mirrorRequests := newRepo(t, http.StatusOK, http.StatusOK)
+		pluginsDir := t.TempDir()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, RepoMirrors: []string{mirror.URL}, Retry: noRetry},
+			"8.0.0", &fakeLogger{})
+
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, "", primary.URL))
+		assert.Equal(t, []string{"/test-panel/versions/1.0.0/download"}, *mirrorRequests)
+	})
+
+	t.Run("Should not fail over when plugin isn't found", func(t *testing.T) {
+		primary, _ := newRepo(t, http.StatusNotFound, http.StatusNotFound)
+		mirror, mirrorRequests := newRepo(t, http.StatusOK, http.StatusOK)
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, RepoMirrors: []string{mirror.URL}, Retry: noRetry},
+			"8.0.0", &fakeLogger{})
+
+		require.Error(t, i.Install("test-panel", "", t.TempDir(), "", primary.URL))
+		assert.Empty(t, *mirrorRequests)
+	})
+
+	t.Run("Should return last error when all mirrors are unavailable", func(t *testing.T) {
+		primary, _ := newRepo(t, http.StatusInternalServerError, http.StatusInternalServerError)
+		mirror, _ := newRepo(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, RepoMirrors: []string{mirror.URL}, Retry: noRetry},
+			"8.0.0", &fakeLogger{})
+
+		err := i.Install("test-panel", "", t.TempDir(), "", primary.URL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "503")
+	})
+
+	t.Run("Should only use mirrors for plugin repository", func(t *testing.T) {
+		i := &Installer{opts: Opts{RepoMirrors: []string{"https://mirror.corp/plugins/", " "}}}
+
+		assert.Equal(t, []string{"https://grafana.com/api/plugins", "https://mirror.corp/plugins"},
+			i.repoURLs("https://grafana.com/api/plugins/", true))
+		assert.Equal(t, []string{"https://corp/plugins"}, i.repoURLs("https://corp/plugins", false))
+	})
+}
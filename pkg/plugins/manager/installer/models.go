@@ -1,16 +1,32 @@
 package installer
 
+import (
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
 type InstalledPlugin struct {
 	ID           string       `json:"id"`
 	Name         string       `json:"name"`
 	Type         string       `json:"type"`
 	Info         PluginInfo   `json:"info"`
 	Dependencies Dependencies `json:"dependencies"`
+
+	Includes []PluginInclude `json:"includes"`
+}
+
+// PluginInclude is an entry of the includes of an app plugin. Nested panel and data source plugins are included
+// with the path of their directory.
+type PluginInclude struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Path string `json:"path"`
 }
 
 type Dependencies struct {
 	GrafanaVersion string             `json:"grafanaVersion"`
 	Plugins        []PluginDependency `json:"plugins"`
+
+	GrafanaDependency string `json:"grafanaDependency"`
 }
 
 type PluginDependency struct {
@@ -18,6 +34,8 @@ type PluginDependency struct {
 	Type    string `json:"type"`
 	Name    string `json:"name"`
 	Version string `json:"version"`
+	// Optional dependencies that can't be installed don't fail the install of the dependent plugin.
+	Optional bool `json:"optional,omitempty"`
 }
 
 type PluginInfo struct {
@@ -25,24 +43,10 @@ type PluginInfo struct {
 	Updated string `json:"updated"`
 }
 
-type Plugin struct {
-	ID       string    `json:"id"`
-	Category string    `json:"category"`
-	Versions []Version `json:"versions"`
-}
-
-type Version struct {
-	Commit  string              `json:"commit"`
-	URL     string              `json:"url"`
-	Version string              `json:"version"`
-	Arch    map[string]ArchMeta `json:"arch"`
-}
-
-type ArchMeta struct {
-	SHA256 string `json:"sha256"`
-}
-
-type PluginRepo struct {
-	Plugins []Plugin `json:"plugins"`
-	Version string   `json:"version"`
-}
+// Plugin, Version, ArchMeta and PluginRepo are the plugin repository models, see the repo package.
+type (
+	Plugin     = repo.Plugin
+	Version    = repo.Version
+	ArchMeta   = repo.ArchMeta
+	PluginRepo = repo.PluginRepo
+)
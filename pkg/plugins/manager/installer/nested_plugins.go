@@ -0,0 +1,73 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NestedPlugin is a panel or data source plugin shipped inside an app plugin and declared in its includes.
+type NestedPlugin struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Path is the directory of the nested plugin, relative to the directory of the app plugin.
+	Path string `json:"path"`
+}
+
+// MissingNestedPluginsError is returned when the archive of an app plugin lacks nested plugins its plugin.json
+// includes.
+type MissingNestedPluginsError struct {
+	PluginID string
+	Paths    []string
+}
+
+func (e *MissingNestedPluginsError) Error() string {
+	return fmt.Sprintf("archive of plugin %s is missing the nested plugins included by its plugin.json: %s",
+		e.PluginID, strings.Join(e.Paths, ", "))
+}
+
+// nestedPlugins returns the nested plugins the app plugin extracted to pluginDir includes, failing if any of their
+// directories or plugin.json files are missing. Include paths are relative to the directory of the plugin.json of
+// the app, which is dist for plugins built with the toolkit.
+func nestedPlugins(pluginDir string, res InstalledPlugin) ([]NestedPlugin, error) {
+	if res.Type != "app" {
+		return nil, nil
+	}
+	baseDir := filepath.Join(pluginDir, res.ID)
+	if _, err := os.Stat(filepath.Join(baseDir, "dist", "plugin.json")); err == nil {
+		baseDir = filepath.Join(baseDir, "dist")
+	}
+
+	var nested []NestedPlugin
+	var missing []string
+	for _, include := range res.Includes {
+		if (include.Type != "panel" && include.Type != "datasource") || include.Path == "" {
+			continue
+		}
+		rel := filepath.Clean(filepath.FromSlash(include.Path))
+		if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			missing = append(missing, include.Path)
+			continue
+		}
+
+		// nolint:gosec
+		data, err := ioutil.ReadFile(filepath.Join(baseDir, rel, "plugin.json"))
+		if err != nil {
+			missing = append(missing, include.Path)
+			continue
+		}
+		var pj pluginJSON
+		if err := json.Unmarshal(data, &pj); err != nil {
+			return nil, &InvalidPluginJSONError{PluginID: res.ID, Path: filepath.ToSlash(filepath.Join(rel,
+				"plugin.json")), Problems: []string{fmt.Sprintf("malformed JSON: %s", err)}}
+		}
+		nested = append(nested, NestedPlugin{ID: pj.ID, Type: pj.Type, Path: filepath.ToSlash(rel)})
+	}
+	if len(missing) > 0 {
+		return nil, &MissingNestedPluginsError{PluginID: res.ID, Paths: missing}
+	}
+	return nested, nil
+}
@@ -0,0 +1,65 @@
+package installer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNestedPlugins(t *testing.T) {
+	const appJSON = `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},"includes":[
+		{"type":"page","name":"Config","path":"/a/test-app/config"},
+		{"type":"panel","name":"Panel","path":"panels/test-panel"},
+		{"type":"datasource","name":"Data source","path":"datasources/test-datasource"}]}`
+	const panelJSON = `{"id":"test-app-panel","type":"panel","name":"Panel"}`
+	const datasourceJSON = `{"id":"test-app-datasource","type":"datasource","name":"Data source"}`
+	newInstaller := func() *Installer {
+		return NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+	}
+
+	t.Run("Should record nested plugins in the plan", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{
+			"plugin.json":                             appJSON,
+			"panels/test-panel/plugin.json":           panelJSON,
+			"datasources/test-datasource/plugin.json": datasourceJSON,
+			"datasources/test-datasource/module.js":   "",
+		})
+		pluginsDir := t.TempDir()
+		i := newInstaller()
+		plan, err := i.PlanInstall("test-app", "", pluginsDir, archive, "")
+		require.NoError(t, err)
+		require.Len(t, plan.Plugins, 1)
+		assert.Equal(t, []NestedPlugin{
+			{ID: "test-app-panel", Type: "panel", Path: "panels/test-panel"},
+			{ID: "test-app-datasource", Type: "datasource", Path: "datasources/test-datasource"},
+		}, plan.Plugins[0].Nested)
+
+		require.NoError(t, i.ApplyPlan(plan))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-app", "panels", "test-panel", "plugin.json"))
+	})
+
+	t.Run("Should fail if the archive lacks nested plugins", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{
+			"plugin.json":                   appJSON,
+			"panels/test-panel/plugin.json": panelJSON,
+		})
+		pluginsDir := t.TempDir()
+		_, err := newInstaller().PlanInstall("test-app", "", pluginsDir, archive, "")
+		var missingErr *MissingNestedPluginsError
+		require.ErrorAs(t, err, &missingErr)
+		assert.Equal(t, []string{"datasources/test-datasource"}, missingErr.Paths)
+		assert.Contains(t, err.Error(), "archive of plugin test-app is missing the nested plugins")
+		assertQuarantineEmpty(t, pluginsDir)
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-app"))
+	})
+
+	t.Run("Should reject include paths outside of the plugin", func(t *testing.T) {
+		_, err := nestedPlugins(t.TempDir(), InstalledPlugin{ID: "test-app", Type: "app",
+			Includes: []PluginInclude{{Type: "panel", Path: "../other-panel"}}})
+		var missingErr *MissingNestedPluginsError
+		require.ErrorAs(t, err, &missingErr)
+		assert.Equal(t, []string{"../other-panel"}, missingErr.Paths)
+	})
+}
@@ -0,0 +1,136 @@
+package installer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// dependenciesFile lists the plugins of a plugins directory that were installed only as dependencies of other
+// plugins. It's hidden, so that it isn't taken for a plugin.
+const dependenciesFile = ".dependencies.json"
+
+// dependencyState is the content of the dependencies file.
+type dependencyState struct {
+	Plugins []string `json:"plugins"`
+}
+
+// readDependencyState returns the plugins of the plugins directory installed only as dependencies.
+func readDependencyState(pluginsDir string) (map[string]bool, error) {
+	// nolint:gosec
+	data, err := ioutil.ReadFile(filepath.Join(pluginsDir, dependenciesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]bool{}, nil
+		}
+		return nil, err
+	}
+	var state dependencyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	plugins := make(map[string]bool, len(state.Plugins))
+	for _, id := range state.Plugins {
+		plugins[id] = true
+	}
+	return plugins, nil
+}
+
+// markDependency records whether the plugin was installed only as a dependency of other plugins.
+func markDependency(pluginsDir, pluginID string, dependency bool) error {
+	plugins, err := readDependencyState(pluginsDir)
+	if err != nil {
+		return err
+	}
+	if plugins[pluginID] == dependency {
+		return nil
+	}
+	if dependency {
+		plugins[pluginID] = true
+	} else {
+		delete(plugins, pluginID)
+	}
+
+	path := filepath.Join(pluginsDir, dependenciesFile)
+	if len(plugins) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	state := dependencyState{Plugins: make([]string, 0, len(plugins))}
+	for id := range plugins {
+		state.Plugins = append(state.Plugins, id)
+	}
+	sort.Strings(state.Plugins)
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// ForgetDependency forgets that the plugin was installed as a dependency, once it's uninstalled.
+func ForgetDependency(pluginsDir, pluginID string) error {
+	return markDependency(pluginsDir, pluginID, false)
+}
+
+// OrphanedDependencies returns the sorted IDs of the plugins in the plugins directory that were installed only as
+// dependencies and that no installed plugin depends on anymore, not even optionally.
+func OrphanedDependencies(pluginsDir string) ([]string, error) {
+	plugins, err := readDependencyState(pluginsDir)
+	if err != nil {
+		return nil, err
+	}
+	var orphaned []string
+	for id := range plugins {
+		if _, err := toPluginDTO(pluginsDir, id); err != nil {
+			continue
+		}
+		dependents, err := installedDependents(pluginsDir, id, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(dependents) == 0 {
+			orphaned = append(orphaned, id)
+		}
+	}
+	sort.Strings(orphaned)
+	return orphaned, nil
+}
+
+// PruneDependencies uninstalls the orphaned dependencies in the plugins directory, including the ones orphaned
+// by uninstalling them, and returns the IDs of the uninstalled plugins.
+func (i *Installer) PruneDependencies(pluginsDir string) ([]string, error) {
+	var pruned []string
+	for {
+		orphaned, err := OrphanedDependencies(pluginsDir)
+		if err != nil {
+			return pruned, err
+		}
+		if len(orphaned) == 0 {
+			return pruned, nil
+		}
+		for _, id := range orphaned {
+			if err := i.uninstall(id, pluginsDir); err != nil {
+				return pruned, err
+			}
+			pruned = append(pruned, id)
+		}
+	}
+}
+
+// recordDependency records whether the installed plugin was installed only as a dependency. Plugins requested
+// explicitly aren't, and plugins already installed keep their state when updated as a dependency.
+func (i *Installer) recordDependency(p *pendingInstall, pluginsDir string) {
+	dependency := p.requirement.RequiredBy != ""
+	if dependency && p.event.Operation == AuditOperationUpdate {
+		return
+	}
+	if err := markDependency(pluginsDir, p.pluginID, dependency); err != nil {
+		i.log.Warn("Failed to record dependency plugins", "file", filepath.Join(pluginsDir, dependenciesFile),
+			"err", err)
+	}
+}
@@ -0,0 +1,60 @@
+package installer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrphanedDependencies(t *testing.T) {
+	dir := writeTestDependencyRepo(t, map[string]string{
+		"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+			"dependencies":{"plugins":[{"id":"test-datasource","type":"datasource"}]}}`,
+		"test-datasource-1.0.0.zip": `{"id":"test-datasource","type":"datasource","name":"Data source",
+			"info":{"version":"1.0.0"},"dependencies":{"plugins":[{"id":"test-panel","type":"panel"}]}}`,
+		"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`,
+	})
+	newInstaller := func(opts Opts) *Installer {
+		opts.AdvisoryPolicy = AdvisoryPolicyIgnore
+		return NewWithOpts(opts, "8.0.0", &fakeLogger{})
+	}
+
+	t.Run("Should report dependencies no longer required", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		i := newInstaller(Opts{})
+		require.NoError(t, i.Install("test-app", "", pluginsDir, "", dir))
+		orphaned, err := OrphanedDependencies(pluginsDir)
+		require.NoError(t, err)
+		assert.Empty(t, orphaned)
+
+		require.NoError(t, i.Uninstall("test-app", pluginsDir))
+		orphaned, err = OrphanedDependencies(pluginsDir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"test-datasource"}, orphaned)
+		assert.DirExists(t, filepath.Join(pluginsDir, "test-datasource"))
+	})
+
+	t.Run("Should prune dependencies orphaned by pruning", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		i := newInstaller(Opts{PruneDependencies: true})
+		require.NoError(t, i.Install("test-app", "", pluginsDir, "", dir))
+
+		require.NoError(t, i.Uninstall("test-app", pluginsDir))
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-datasource"))
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+		assert.NoFileExists(t, filepath.Join(pluginsDir, dependenciesFile))
+	})
+
+	t.Run("Should keep dependencies installed explicitly", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		i := newInstaller(Opts{PruneDependencies: true})
+		require.NoError(t, i.Install("test-app", "", pluginsDir, "", dir))
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, "", dir))
+
+		require.NoError(t, i.Uninstall("test-app", pluginsDir))
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-datasource"))
+		assert.DirExists(t, filepath.Join(pluginsDir, "test-panel"))
+	})
+}
@@ -0,0 +1,369 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	debMagic = []byte("!<arch>\n")
+	rpmMagic = []byte{0xed, 0xab, 0xee, 0xdb}
+
+	// debPayloadFormats maps the extension of the data.tar member of deb packages to its compression format.
+	debPayloadFormats = map[string]string{
+		".tar":  formatTar,
+		".gz":   formatGzip,
+		".xz":   formatXz,
+		".zst":  formatZstd,
+		".bz2":  formatBzip2,
+		".lzma": formatLzma,
+	}
+	// rpmPayloadFormats lists the RPM payload compressors, which are named like the archive formats.
+	rpmPayloadFormats = map[string]bool{
+		formatGzip:  true,
+		formatXz:    true,
+		formatLzma:  true,
+		formatZstd:  true,
+		formatBzip2: true,
+	}
+)
+
+// packagePayload exposes the plugin directory inside an OS package as a regular plugin archive. OS packages
+// contain full file system paths, such as ./var/lib/grafana/plugins/<id>/plugin.json, so the directory holding
+// the shallowest plugin.json is treated as the plugin root and everything outside of it is ignored.
+type packagePayload struct {
+	f    *os.File
+	kind string
+	// files walks all members of the package payload
+	files func(fn func(f *archiveFile) error) error
+}
+
+func (a *packagePayload) walk(fn func(f *archiveFile) error) error {
+	root := ""
+	found := false
+	err := a.files(func(f *archiveFile) error {
+		name := path.Clean(strings.TrimPrefix(f.name, "./"))
+		if f.isDir() || path.Base(name) != "plugin.json" {
+			return nil
+		}
+		dir := path.Dir(name)
+		if !found || strings.Count(dir, "/") < strings.Count(root, "/") {
+			root, found = dir, true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found || root == "." {
+		return fmt.Errorf("could not find a plugin directory in %s package", a.kind)
+	}
+
+	return a.files(func(f *archiveFile) error {
+		name := path.Clean(strings.TrimPrefix(f.name, "./"))
+		if name != root && !strings.HasPrefix(name, root+"/") {
+			return nil
+		}
+		// Re-root the member so that the plugin directory is the top level directory of the archive
+		f.name = path.Base(root) + strings.TrimPrefix(name, root)
+		if f.isDir() {
+			f.name += "/"
+		}
+		return fn(f)
+	})
+}
+
+func (a *packagePayload) Close() error {
+	return a.f.Close()
+}
+
+// openDebArchive opens a Debian package, an ar archive whose data.tar member holds the installed files.
+func openDebArchive(archivePath string) (*packagePayload, error) {
+	// nolint:gosec
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	a := &packagePayload{f: f, kind: "deb"}
+	a.files = func(fn func(f *archiveFile) error) error {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		name, data, err := findArMember(bufio.NewReader(f), "data.tar")
+		if err != nil {
+			return err
+		}
+
+		format, ok := debPayloadFormats[path.Ext(name)]
+		if !ok {
+			return fmt.Errorf("unsupported deb payload compression %q", path.Ext(name))
+		}
+		r, err := decompressPayload(data, format)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+
+		return walkTar(r, fn)
+	}
+	return a, nil
+}
+
+// findArMember returns the first member of the ar archive whose name starts with the prefix.
+func findArMember(r io.Reader, prefix string) (string, io.Reader, error) {
+	magic := make([]byte, len(debMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, debMagic) {
+		return "", nil, errors.New("invalid deb package")
+	}
+
+	header := make([]byte, 60)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", nil, fmt.Errorf("could not find %s in deb package", prefix)
+			}
+			return "", nil, err
+		}
+		name := strings.TrimRight(strings.TrimSpace(string(header[0:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil || size < 0 {
+			return "", nil, errors.New("invalid deb package member header")
+		}
+		if strings.HasPrefix(name, prefix) {
+			return name, io.LimitReader(r, size), nil
+		}
+		// Members are aligned to an even offset
+		if _, err := io.CopyN(ioutil.Discard, r, size+size%2); err != nil {
+			return "", nil, err
+		}
+	}
+}
+
+// openRPMArchive opens an RPM package, whose payload is a compressed cpio archive following the lead and the
+// signature and main headers.
+func openRPMArchive(archivePath string) (*packagePayload, error) {
+	// nolint:gosec
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	a := &packagePayload{f: f, kind: "rpm"}
+	a.files = func(fn func(f *archiveFile) error) error {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		br := bufio.NewReader(f)
+		compressor, err := readRPMHeaders(br)
+		if err != nil {
+			return err
+		}
+
+		if !rpmPayloadFormats[compressor] {
+			return fmt.Errorf("unsupported rpm payload compression %q", compressor)
+		}
+		r, err := decompressPayload(br, compressor)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+
+		return walkCpio(r, fn)
+	}
+	return a, nil
+}
+
+// decompressPayload returns a reader of the uncompressed package payload.
+func decompressPayload(r io.Reader, format string) (io.ReadCloser, error) {
+	decompress, _ := tarDecompressor(format)
+	if decompress == nil {
+		return ioutil.NopCloser(r), nil
+	}
+	return decompress(r)
+}
+
+const (
+	rpmLeadSize             = 96
+	rpmTagPayloadCompressor = 1125
+	rpmHeaderIndexEntrySize = 16
+	rpmHeaderIntroSize      = 16
+	rpmTypeString           = 6
+)
+
+var rpmHeaderMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+
+// readRPMHeaders skips the lead, signature and main headers and returns the payload compressor.
+func readRPMHeaders(r io.Reader) (string, error) {
+	lead := make([]byte, rpmLeadSize)
+	if _, err := io.ReadFull(r, lead); err != nil || !bytes.Equal(lead[:4], rpmMagic) {
+		return "", errors.New("invalid rpm package")
+	}
+
+	// The signature header is padded to a multiple of 8 bytes
+	if _, _, err := readRPMHeader(r, true); err != nil {
+		return "", fmt.Errorf("invalid rpm signature header: %w", err)
+	}
+	index, store, err := readRPMHeader(r, false)
+	if err != nil {
+		return "", fmt.Errorf("invalid rpm header: %w", err)
+	}
+
+	for i := 0; i+rpmHeaderIndexEntrySize <= len(index); i += rpmHeaderIndexEntrySize {
+		tag := binary.BigEndian.Uint32(index[i:])
+		typ := binary.BigEndian.Uint32(index[i+4:])
+		offset := int(binary.BigEndian.Uint32(index[i+8:]))
+		if tag != rpmTagPayloadCompressor || typ != rpmTypeString || offset >= len(store) {
+			continue
+		}
+		value := store[offset:]
+		if end := bytes.IndexByte(value, 0); end >= 0 {
+			value = value[:end]
+		}
+		return string(value), nil
+	}
+
+	// Payloads are gzip compressed unless specified otherwise
+	return "gzip", nil
+}
+
+func readRPMHeader(r io.Reader, padded bool) ([]byte, []byte, error) {
+	intro := make([]byte, rpmHeaderIntroSize)
+	if _, err := io.ReadFull(r, intro); err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(intro[:4], rpmHeaderMagic) {
+		return nil, nil, errors.New("bad magic")
+	}
+	entries := int64(binary.BigEndian.Uint32(intro[8:]))
+	size := int64(binary.BigEndian.Uint32(intro[12:]))
+	if entries > 1<<16 || size > 1<<28 {
+		return nil, nil, errors.New("header too large")
+	}
+
+	index := make([]byte, entries*rpmHeaderIndexEntrySize)
+	if _, err := io.ReadFull(r, index); err != nil {
+		return nil, nil, err
+	}
+	store := make([]byte, size)
+	if _, err := io.ReadFull(r, store); err != nil {
+		return nil, nil, err
+	}
+	if padded && size%8 != 0 {
+		if _, err := io.CopyN(ioutil.Discard, r, 8-size%8); err != nil {
+			return nil, nil, err
+		}
+	}
+	return index, store, nil
+}
+
+const (
+	cpioNewcMagic    = "070701"
+	cpioNewcCRCMagic = "070702"
+	cpioHeaderSize   = 110
+	cpioTrailer      = "TRAILER!!!"
+
+	cpioModeTypeMask = 0170000
+	cpioModeDir      = 0040000
+	cpioModeRegular  = 0100000
+	cpioModeSymlink  = 0120000
+)
+
+// walkCpio calls fn for every regular file, directory and symlink in a cpio archive in the "new ASCII" format
+// used by RPM.
+func walkCpio(r io.Reader, fn func(f *archiveFile) error) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, cpioHeaderSize)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			return fmt.Errorf("invalid cpio archive: %w", err)
+		}
+		magic := string(header[:6])
+		if magic != cpioNewcMagic && magic != cpioNewcCRCMagic {
+			return errors.New("invalid cpio archive: unsupported format")
+		}
+		field := func(i int) (int64, error) {
+			return strconv.ParseInt(string(header[6+i*8:14+i*8]), 16, 64)
+		}
+		mode, err := field(1)
+		if err != nil {
+			return err
+		}
+		size, err := field(6)
+		if err != nil {
+			return err
+		}
+		nameSize, err := field(11)
+		if err != nil {
+			return err
+		}
+		if nameSize <= 0 || nameSize > 4096 || size < 0 {
+			return errors.New("invalid cpio archive: bad header")
+		}
+
+		name := make([]byte, nameSize)
+		if _, err := io.ReadFull(br, name); err != nil {
+			return err
+		}
+		if err := skipCpioPadding(br, cpioHeaderSize+nameSize); err != nil {
+			return err
+		}
+		memberName := string(bytes.TrimRight(name, "\x00"))
+		if memberName == cpioTrailer {
+			return nil
+		}
+
+		data := io.LimitReader(br, size)
+		fileMode := os.FileMode(mode & 0777)
+		supported := true
+		switch mode & cpioModeTypeMask {
+		case cpioModeDir:
+			fileMode |= os.ModeDir
+		case cpioModeSymlink:
+			fileMode |= os.ModeSymlink
+		case cpioModeRegular:
+		default:
+			// Skip hard links, devices and other special members
+			supported = false
+		}
+
+		if supported {
+			if err := fn(&archiveFile{
+				name: memberName,
+				mode: fileMode,
+				open: func() (io.ReadCloser, error) {
+					return ioutil.NopCloser(data), nil
+				},
+			}); err != nil {
+				return err
+			}
+		}
+
+		// Skip whatever fn didn't read, including the padding
+		if _, err := io.Copy(ioutil.Discard, data); err != nil {
+			return err
+		}
+		if err := skipCpioPadding(br, size); err != nil {
+			return err
+		}
+	}
+}
+
+func skipCpioPadding(r io.Reader, n int64) error {
+	if pad := (4 - n%4) % 4; pad > 0 {
+		_, err := io.CopyN(ioutil.Discard, r, pad)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+func TestExtractPackageArchives(t *testing.T) {
+	files := map[string]string{
+		"./var/lib/grafana/plugins/test-panel/plugin.json":      testPluginJSON,
+		"./var/lib/grafana/plugins/test-panel/dist/plugin.json": testPluginJSON,
+		"./var/lib/grafana/plugins/test-panel/module.js":        "define([], function() {})",
+		"./usr/share/doc/test-panel/README.md":                  "# Test panel",
+	}
+
+	for _, tc := range []struct {
+		kind   string
+		format string
+		write  func(*testing.T, string, map[string]string) string
+	}{
+		{"deb", formatGzip, writeTestDeb},
+		{"deb", formatXz, writeTestDeb},
+		{"rpm", formatGzip, writeTestRPM},
+		{"rpm", formatXz, writeTestRPM},
+		{"rpm", formatLzma, writeTestRPM},
+	} {
+		tc := tc
+		t.Run(fmt.Sprintf("Should extract plugin directory from %s %s package", tc.format, tc.kind), func(t *testing.T) {
+			i := &Installer{log: &fakeLogger{}}
+			pluginsDir := t.TempDir()
+
+			err := i.extractFiles(tc.write(t, tc.format, files), "test-panel", pluginsDir, false)
+			require.NoError(t, err)
+
+			data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "module.js"))
+			require.NoError(t, err)
+			assert.Equal(t, "define([], function() {})", string(data))
+			_, err = ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "dist", "plugin.json"))
+			require.NoError(t, err)
+			_, err = ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "README.md"))
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("Should fail for package without plugin", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+		archive := writeTestDeb(t, formatGzip, map[string]string{"./usr/share/doc/README.md": "nothing to see"})
+
+		err := i.extractFiles(archive, "test-panel", t.TempDir(), false)
+		require.Error(t, err)
+	})
+}
+
+func writeTestDeb(t *testing.T, format string, files map[string]string) string {
+	t.Helper()
+
+	data := new(bytes.Buffer)
+	gw := compressWriter(t, data, format)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	var ext string
+	for e, f := range debPayloadFormats {
+		if f == format {
+			ext = e
+		}
+	}
+	buf := bytes.NewBuffer(debMagic)
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", []byte("ignored")},
+		{"data.tar" + ext, data.Bytes()},
+	} {
+		fmt.Fprintf(buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", m.name+"/", 0, 0, 0, "100644", len(m.data))
+		buf.Write(m.data)
+		if len(m.data)%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+
+	archive := filepath.Join(t.TempDir(), "plugin.deb")
+	require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+	return archive
+}
+
+func writeTestRPM(t *testing.T, format string, files map[string]string) string {
+	t.Helper()
+
+	payload := new(bytes.Buffer)
+	gw := compressWriter(t, payload, format)
+	writeEntry := func(name string, mode int, content string) {
+		fmt.Fprintf(gw, "%s%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+			cpioNewcMagic, 0, mode, 0, 0, 1, 0, len(content), 0, 0, 0, 0, len(name)+1, 0)
+		_, err := gw.Write(append([]byte(name), 0))
+		require.NoError(t, err)
+		_, err = gw.Write(make([]byte, (4-(cpioHeaderSize+len(name)+1)%4)%4))
+		require.NoError(t, err)
+		_, err = gw.Write([]byte(content))
+		require.NoError(t, err)
+		_, err = gw.Write(make([]byte, (4-len(content)%4)%4))
+		require.NoError(t, err)
+	}
+	for name, content := range files {
+		writeEntry(name, cpioModeRegular|0644, content)
+	}
+	writeEntry(cpioTrailer, 0, "")
+	require.NoError(t, gw.Close())
+
+	header := func(store []byte) []byte {
+		h := new(bytes.Buffer)
+		h.Write(rpmHeaderMagic)
+		h.Write(make([]byte, 4))
+		require.NoError(t, binary.Write(h, binary.BigEndian, uint32(1)))
+		require.NoError(t, binary.Write(h, binary.BigEndian, uint32(len(store))))
+		for _, v := range []uint32{rpmTagPayloadCompressor, rpmTypeString, 0, 1} {
+			require.NoError(t, binary.Write(h, binary.BigEndian, v))
+		}
+		h.Write(store)
+		return h.Bytes()
+	}
+
+	buf := new(bytes.Buffer)
+	lead := make([]byte, rpmLeadSize)
+	copy(lead, rpmMagic)
+	buf.Write(lead)
+	// Signature header with a 5 byte store that needs padding
+	buf.Write(header([]byte("sig\x00\x00")))
+	buf.Write(make([]byte, 3))
+	buf.Write(header(append([]byte(format), 0)))
+	buf.Write(payload.Bytes())
+
+	archive := filepath.Join(t.TempDir(), "plugin.rpm")
+	require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+	return archive
+}
+
+func compressWriter(t *testing.T, w io.Writer, format string) io.WriteCloser {
+	t.Helper()
+
+	switch format {
+	case formatXz:
+		xw, err := xz.NewWriter(w)
+		require.NoError(t, err)
+		return xw
+	case formatLzma:
+		lw, err := lzma.NewWriter(w)
+		require.NoError(t, err)
+		return lw
+	default:
+		return gzip.NewWriter(w)
+	}
+}
@@ -0,0 +1,85 @@
+package installer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// reExecutableVariant matches the _<os>_<arch>[.exe] suffix of platform specific backend executables.
+var reExecutableVariant = regexp.MustCompile(`^_[a-z0-9]+_[a-z0-9]+(\.exe)?$`)
+
+// restrictExecutables grants execute permission only to the backend executables declared by the plugin.json files
+// of an extracted plugin, including nested plugins, and strips it from all other files. Executables are matched
+// including their <executable>_<os>_<arch>[.exe] variants.
+func restrictExecutables(pluginDir string) error {
+	executables, err := declaredExecutables(pluginDir)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(pluginDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		mode := fi.Mode().Perm() &^ 0111
+		if isDeclaredExecutable(path, executables) {
+			mode |= 0755
+		}
+		if mode == fi.Mode().Perm() {
+			return nil
+		}
+		return os.Chmod(path, mode)
+	})
+}
+
+// declaredExecutables returns the paths of the executables declared by the plugin.json files in the plugin
+// directory.
+func declaredExecutables(pluginDir string) ([]string, error) {
+	var executables []string
+	err := filepath.Walk(pluginDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() || fi.Name() != "plugin.json" {
+			return nil
+		}
+
+		// nolint:gosec
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var pj struct {
+			Executable string `json:"executable"`
+		}
+		// Malformed plugin.json files are reported when the plugin is loaded
+		if err := json.Unmarshal(data, &pj); err != nil || pj.Executable == "" {
+			return nil
+		}
+
+		executable := filepath.Join(filepath.Dir(path), filepath.FromSlash(pj.Executable))
+		if isUnderDir(executable, pluginDir) {
+			executables = append(executables, executable)
+		}
+		return nil
+	})
+	return executables, err
+}
+
+func isDeclaredExecutable(path string, executables []string) bool {
+	for _, executable := range executables {
+		if path == executable ||
+			(strings.HasPrefix(path, executable) && reExecutableVariant.MatchString(path[len(executable):])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package installer
+
+import (
+	"os"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// PluginValidator checks a plugin extracted to the staging directory before it's installed. pluginsDir is the
+// directory the plugin was extracted to. Returning an error refuses the plugin.
+type PluginValidator func(pluginsDir, pluginID string) error
+
+// validators returns the validators every extracted plugin has to pass, followed by the ones registered in the
+// options. Path checks and size limits are already enforced while extracting.
+func (i *Installer) validators() []PluginValidator {
+	return append([]PluginValidator{
+		validatePluginJSONs,
+		i.checkPluginIDs,
+		i.checkContent,
+		i.checkAngular,
+		checkExecutables,
+		i.checkPluginSignature,
+	}, i.opts.Validators...)
+}
+
+// validatePlugin runs the validators on the plugin in the plugins directory, stopping at the first failure.
+func (i *Installer) validatePlugin(pluginsDir, pluginID string) error {
+	for _, validate := range i.validators() {
+		if err := validate(pluginsDir, pluginID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stagePlugin extracts the downloaded archive of the plugin to a new staging directory in the quarantine directory
+// and validates the extracted plugin. Any existing installation is left untouched until the staged plugin is moved
+// into place. The caller has to remove the returned staging directory.
+func (i *Installer) stagePlugin(p *pendingInstall, pluginsDir string) (string, error) {
+	stagingDir, err := newStagingDir(pluginsDir)
+	if err != nil {
+		return "", errutil.Wrap("failed to create quarantine directory", err)
+	}
+	p.journal.record(journalEntry{PluginID: p.pluginID, Phase: journalPhaseExtract, ArchivePath: p.archivePath,
+		StagingDir: stagingDir})
+
+	err = i.extractFiles(p.archivePath, p.pluginID, stagingDir, p.isInternal)
+	if err != nil {
+		err = errutil.Wrap("failed to extract plugin archive", err)
+	} else {
+		err = i.validatePlugin(stagingDir, p.pluginID)
+	}
+	if err != nil {
+		i.removeStagingDir(stagingDir)
+		return "", err
+	}
+	return stagingDir, nil
+}
+
+func (i *Installer) removeStagingDir(stagingDir string) {
+	if err := os.RemoveAll(stagingDir); err != nil {
+		i.log.Warn("Failed to remove quarantine directory", "dir", stagingDir, "err", err)
+	}
+}
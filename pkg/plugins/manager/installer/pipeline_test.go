@@ -0,0 +1,73 @@
+package installer
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationPipeline(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+	const updatedPluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"2.0.0"}}`
+
+	t.Run("Should run registered validators before replacing existing installation", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		var validated []string
+		errRefused := errors.New("refused")
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, Validators: []PluginValidator{
+			func(dir, pluginID string) error {
+				validated = append(validated, dir)
+				data, err := ioutil.ReadFile(filepath.Join(dir, pluginID, "plugin.json"))
+				require.NoError(t, err)
+				if string(data) == updatedPluginJSON {
+					return errRefused
+				}
+				return nil
+			},
+		}}}
+
+		require.NoError(t, i.Install("test-panel", "", pluginsDir,
+			writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}), ""))
+		err := i.Install("test-panel", "", pluginsDir,
+			writeTestTarGz(t, nil, map[string]string{"plugin.json": updatedPluginJSON}), "")
+		require.ErrorIs(t, err, errRefused)
+
+		require.Len(t, validated, 2)
+		for _, dir := range validated {
+			assert.NotEqual(t, pluginsDir, dir, "validators should run on the staging directory")
+			assert.NoDirExists(t, dir)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		require.NoError(t, err)
+		assert.Equal(t, pluginJSON, string(data))
+	})
+
+	t.Run("Should replace existing installation once validated", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}}
+
+		require.NoError(t, i.Install("test-panel", "", pluginsDir,
+			writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON, "old.js": ""}), ""))
+		require.NoError(t, i.Install("test-panel", "", pluginsDir,
+			writeTestTarGz(t, nil, map[string]string{"plugin.json": updatedPluginJSON}), ""))
+
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		require.NoError(t, err)
+		assert.Equal(t, updatedPluginJSON, string(data))
+		assert.NoFileExists(t, filepath.Join(pluginsDir, "test-panel", "old.js"))
+	})
+
+	t.Run("Should only extract to empty directory", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+		i := &Installer{log: &fakeLogger{}}
+
+		require.NoError(t, i.extractFiles(archive, "test-panel", pluginsDir, false))
+		require.Error(t, i.extractFiles(archive, "test-panel", pluginsDir, false))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+	})
+}
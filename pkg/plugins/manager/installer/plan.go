@@ -0,0 +1,447 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// InstallPlan lists the plugins an install resolved to, in the order ApplyPlan installs them, dependencies before
+// the plugins depending on them. Planning resolves versions and download URLs, downloads and verifies the archives
+// and extracts them to the quarantine directory, so that applying the plan only moves the plugins into place. A
+// plan holds temporary files until it's applied or closed.
+type InstallPlan struct {
+	// PluginsDir is the plugins directory the plan installs into.
+	PluginsDir string
+	Plugins    []PlannedPlugin
+
+	steps []*pendingInstall
+	log   plugins.PluginInstallerLogger
+}
+
+// PlannedPlugin is a plugin version installed by an InstallPlan.
+type PlannedPlugin struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	// RequiredBy is the ID of the plugin depending on the plugin, empty if it was requested to be installed.
+	RequiredBy string `json:"requiredBy,omitempty"`
+	// Requested is the version or version range requested, empty for the latest version.
+	Requested string `json:"requested,omitempty"`
+	// Source is the redacted URL the archive was downloaded from.
+	Source string `json:"source"`
+	// Download are the statistics of the download of the archive, nil if it was read from a local file.
+	Download *DownloadStats `json:"download,omitempty"`
+	// Update reports that the plugin replaces an installed version.
+	Update bool `json:"update,omitempty"`
+	// Nested are the nested plugins of an app plugin, which are registered along with it.
+	Nested []NestedPlugin `json:"nested,omitempty"`
+}
+
+// pendingInstall is a plugin resolved to be installed, whose archive is downloaded and then extracted.
+type pendingInstall struct {
+	pluginID    string
+	version     string
+	requirement DependencyRequirement
+	event       *AuditEvent
+	isInternal  bool
+	// pluginZipURL is the URL the archive is downloaded from. Once downloaded, it's the mirror that served it.
+	pluginZipURL string
+	downloadURLs []string
+	checksum     string
+	signatureURL string
+	// archivePath is the path of the downloaded archive, empty if the archive was extracted while it was downloaded.
+	archivePath string
+	// archiveSHA256 is the SHA256 checksum of an archive extracted while it was downloaded.
+	archiveSHA256 string
+	// stagingDir is the directory in the quarantine directory the archive is extracted to.
+	stagingDir string
+	// res is the plugin.json of the extracted plugin.
+	res InstalledPlugin
+
+	// installedTo is the directory the plugin was moved into once applied, and previous the path of the
+	// installation it replaced, which is kept in the staging directory until the plan is closed.
+	installedTo string
+	previous    string
+	// journal records the phases of the install, so that the next run can recover if the process dies.
+	journal *installJournal
+}
+
+// PlanInstall plans installing the plugin and its dependencies into the plugins directory without installing
+// anything, see InstallPlan. The returned plan has to be applied with ApplyPlan or closed.
+func (i *Installer) PlanInstall(pluginID, version, pluginsDir, pluginZipURL, pluginRepoURL string) (*InstallPlan,
+	error) {
+	plan := i.newInstallPlan(pluginsDir)
+	if err := i.planInstall(plan, pluginID, version, pluginZipURL, pluginRepoURL,
+		newDependencyResolution()); err != nil {
+		plan.Close()
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (i *Installer) newInstallPlan(pluginsDir string) *InstallPlan {
+	i.recoverInterruptedInstalls(pluginsDir)
+	return &InstallPlan{PluginsDir: pluginsDir, log: i.log}
+}
+
+// ApplyPlan installs the planned plugins in order and closes the plan. If a plugin fails to be installed, the
+// plugins installed before it are removed again and the versions they replaced restored, along with the lockfile,
+// so that no plugin is left installed without its dependencies.
+func (i *Installer) ApplyPlan(plan *InstallPlan) error {
+	defer plan.Close()
+	snapshots := i.snapshotPlanFiles(plan)
+	for idx, p := range plan.steps {
+		if err := i.applyPlanned(p, plan.PluginsDir); err != nil {
+			i.rollbackPlan(plan.steps[:idx+1], snapshots)
+			return err
+		}
+	}
+	return nil
+}
+
+// fileSnapshot is the content of a file before applying a plan, nil if it didn't exist.
+type fileSnapshot struct {
+	path string
+	data []byte
+}
+
+// snapshotPlanFiles saves the lockfile and the dependencies file of the plugins directory, and the quarantine records
+// of the planned plugins in quarantine-only mode, which applying the plan updates.
+func (i *Installer) snapshotPlanFiles(plan *InstallPlan) []fileSnapshot {
+	paths := []string{filepath.Join(plan.PluginsDir, dependenciesFile)}
+	if i.opts.LockfilePath != "" && !i.opts.FromLockfile {
+		paths = append(paths, i.opts.LockfilePath)
+	}
+	if i.opts.QuarantineOnly {
+		for _, p := range plan.steps {
+			paths = append(paths, quarantineRecordPath(plan.PluginsDir, p.pluginID))
+		}
+	}
+	snapshots := make([]fileSnapshot, 0, len(paths))
+	for _, path := range paths {
+		// nolint:gosec
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			i.log.Warn("Failed to save file to restore if the install fails", "file", path, "err", err)
+			continue
+		}
+		snapshots = append(snapshots, fileSnapshot{path: path, data: data})
+	}
+	return snapshots
+}
+
+// rollbackPlan removes the installed plugins of the plan, restoring the versions they replaced, and restores the
+// saved files, in reverse order. Each undone install is audited, since its success was audited when it was applied.
+func (i *Installer) rollbackPlan(steps []*pendingInstall, snapshots []fileSnapshot) {
+	for idx := len(steps) - 1; idx >= 0; idx-- {
+		p := steps[idx]
+		if p.installedTo == "" {
+			continue
+		}
+		err := restorePlugin(p.installedTo, p.pluginID, p.previous)
+		i.audit(rollbackEvent(p.event), err)
+		if err != nil {
+			i.log.Warn("Failed to roll back plugin", "plugin", p.pluginID, "err", err)
+			continue
+		}
+		p.installedTo, p.previous = "", ""
+		if p.event.Operation == AuditOperationUpdate {
+			i.log.Warnf("Restored the previous version of %s", p.pluginID)
+		} else {
+			i.log.Warnf("Removed %s again", p.pluginID)
+		}
+	}
+	for _, s := range snapshots {
+		var err error
+		if s.data == nil {
+			if err = os.Remove(s.path); os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			err = ioutil.WriteFile(s.path, s.data, 0600)
+		}
+		if err != nil {
+			i.log.Warn("Failed to restore file", "file", s.path, "err", err)
+		}
+	}
+}
+
+// Close removes the downloaded archives and extracted plugins of the plan that weren't installed.
+func (plan *InstallPlan) Close() {
+	for _, p := range plan.steps {
+		p.discard(plan.log)
+	}
+	plan.steps = nil
+}
+
+// planInstall plans installing the plugin and its dependencies, adding them to the plan. The resolution tracks
+// the plugins planned so far, so that conflicting requirements are detected. Failures are audited right away,
+// successful installs once the plan is applied.
+func (i *Installer) planInstall(plan *InstallPlan, pluginID, version, pluginZipURL, pluginRepoURL string,
+	resolution *dependencyResolution) error {
+	event := i.newAuditEvent(AuditOperationInstall, pluginID, plan.PluginsDir)
+	p, err := i.resolveInstall(event, pluginID, version, plan.PluginsDir, pluginZipURL, pluginRepoURL, nil,
+		resolution)
+	if p == nil && err == nil {
+		// Already planned along with another plugin
+		return nil
+	}
+	if err == nil {
+		err = i.fetchArchive(p, plan.PluginsDir)
+	}
+	if err == nil {
+		err = i.planPlugin(plan, p, pluginRepoURL, nil, resolution)
+	}
+	if err != nil {
+		if p != nil {
+			p.discard(i.log)
+		}
+		i.audit(event, err)
+	}
+	return err
+}
+
+// planPlugin verifies and extracts the downloaded archive of the plugin to the quarantine directory, then plans
+// its dependencies and adds the plugin to the plan after them. The chain holds the IDs of the plugins depending on
+// the plugin, outermost first.
+func (i *Installer) planPlugin(plan *InstallPlan, p *pendingInstall, pluginRepoURL string, chain []string,
+	resolution *dependencyResolution) error {
+	pluginID, version, event := p.pluginID, p.version, p.event
+	if i.auditEnabled() {
+		event.Checksum, _ = p.archiveSum()
+	}
+	// Archives extracted while they were downloaded are already staged, see streamArchive
+	if p.stagingDir == "" {
+		if err := i.verifyAndStage(plan, p); err != nil {
+			return err
+		}
+	}
+
+	res, _ := toPluginDTO(p.stagingDir, pluginID)
+	p.res = res
+	event.Version = res.Info.Version
+
+	// The version of plugins installed from a direct URL is only known once extracted
+	if version == "" {
+		if err := i.verifySumFile(p.archivePath, pluginID, res.Info.Version); err != nil {
+			return err
+		}
+		if err := i.checkAdvisories(pluginID, res.Info.Version, p.pluginZipURL); err != nil {
+			return err
+		}
+	}
+	if err := i.checkGrafanaDependency(res); err != nil {
+		return err
+	}
+	nested, err := nestedPlugins(p.stagingDir, res)
+	if err != nil {
+		return err
+	}
+	if res.Info.Version != "" {
+		version = res.Info.Version
+	}
+	resolution.record(pluginID, version, p.requirement)
+
+	// plan dependency plugins
+	if i.opts.SkipDependencies {
+		if len(res.Dependencies.Plugins) > 0 {
+			i.log.Infof("Skipping %d dependencies of %s", len(res.Dependencies.Plugins), res.ID)
+		}
+	} else {
+		dependents := append(append(make([]string, 0, len(chain)+1), chain...), pluginID)
+		if err := i.planDependencies(plan, res, pluginRepoURL, dependents, resolution); err != nil {
+			return err
+		}
+	}
+
+	plan.steps = append(plan.steps, p)
+	plan.Plugins = append(plan.Plugins, PlannedPlugin{ID: pluginID, Version: version,
+		RequiredBy: p.requirement.RequiredBy, Requested: p.requirement.Requested, Source: event.Source,
+		Download: event.Download, Update: event.Operation == AuditOperationUpdate, Nested: nested})
+	return nil
+}
+
+// verifyAndStage verifies the downloaded archive of the plugin and extracts it to the quarantine directory.
+func (i *Installer) verifyAndStage(plan *InstallPlan, p *pendingInstall) error {
+	if p.version != "" {
+		if err := i.verifySumFile(p.archivePath, p.pluginID, p.version); err != nil {
+			return err
+		}
+	}
+	if err := i.verifyDetachedSignature(p.archivePath, p.pluginZipURL, p.signatureURL); err != nil {
+		return err
+	}
+	if err := i.verifyCosignSignature(p.archivePath, p.pluginZipURL); err != nil {
+		return err
+	}
+	if err := i.verifyProvenance(p.archivePath, p.pluginZipURL); err != nil {
+		return err
+	}
+
+	manifest, err := i.verifyInstallManifest(p.archivePath, p.pluginID, p.version)
+	if err != nil {
+		return errutil.Wrap("failed to verify plugin archive", err)
+	}
+	if manifest != nil {
+		i.log.Infof("Verified signed install manifest of %s v%s", manifest.Plugin, manifest.Version)
+	}
+
+	// Extract into the quarantine directory, so that plugins only end up in the plugins directory once verified
+	p.stagingDir, err = i.stagePlugin(p, plan.PluginsDir)
+	return err
+}
+
+// archiveSum returns the SHA256 checksum of the archive of the plugin.
+func (p *pendingInstall) archiveSum() (string, error) {
+	if p.archiveSHA256 != "" {
+		return p.archiveSHA256, nil
+	}
+	return plugins.FileSHA256(p.archivePath)
+}
+
+// planDependencies plans the dependencies of the plugin by ID, so that the plan doesn't depend on their order in the
+// plugin.json. Their archives are downloaded concurrently, at most as many at a time as the download manager
+// allows, and then extracted one after another.
+func (i *Installer) planDependencies(plan *InstallPlan, res InstalledPlugin, pluginRepoURL string,
+	dependents []string, resolution *dependencyResolution) error {
+	deps := sortedDependencies(res.Dependencies.Plugins)
+	if len(deps) == 0 {
+		return nil
+	}
+	i.log.Infof("Fetching %s dependencies...", res.ID)
+
+	events := make([]*AuditEvent, len(deps))
+	pending := make([]*pendingInstall, len(deps))
+	errs := make([]error, len(deps))
+	planned := map[string]*pendingInstall{}
+	// Dependencies added to the plan are only removed by closing the plan
+	defer func() {
+		for _, p := range pending {
+			if p != nil {
+				p.discard(i.log)
+			}
+		}
+	}()
+	for idx, dep := range deps {
+		events[idx] = i.newAuditEvent(AuditOperationInstall, dep.ID, plan.PluginsDir)
+		ref, version, pluginZipURL := i.pinDependency(i.provideDependency(dep, res.ID), res.ID)
+		pending[idx], errs[idx] = i.resolveInstall(events[idx], ref, version, plan.PluginsDir, pluginZipURL,
+			pluginRepoURL, dependents, resolution)
+		// Plugins listed more than once are only installed once, if all requirements are satisfied
+		if p := pending[idx]; p != nil {
+			if first, exists := planned[p.pluginID]; exists {
+				pending[idx] = nil
+				if !satisfiesRequirement(first.version, p.requirement) {
+					errs[idx] = &DependencyConflictError{PluginID: p.pluginID, Version: first.version,
+						Resolved: first.requirement, Conflicting: p.requirement}
+				}
+			} else {
+				planned[p.pluginID] = p
+			}
+		}
+		if errs[idx] != nil && !dep.Optional {
+			i.audit(events[idx], errs[idx])
+			return wrapDependencyError(errs[idx], dep.ID)
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, i.downloads.Limit())
+	for idx, p := range pending {
+		if p == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(idx int, p *pendingInstall) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[idx] = i.fetchArchive(p, plan.PluginsDir)
+		}(idx, p)
+	}
+	wg.Wait()
+
+	for idx, dep := range deps {
+		p, err := pending[idx], errs[idx]
+		if p == nil && err == nil {
+			continue
+		}
+		if err == nil {
+			// The dependency may have been planned as a dependency of a dependency extracted before
+			var alreadyPlanned bool
+			if alreadyPlanned, err = resolution.check(p.pluginID, p.requirement); alreadyPlanned {
+				continue
+			}
+		}
+		if err == nil {
+			if err = i.planPlugin(plan, p, pluginRepoURL, dependents, resolution); err == nil {
+				pending[idx] = nil
+				continue
+			}
+		}
+		i.audit(events[idx], err)
+		if dep.Optional {
+			i.log.Warnf("Failed to install optional dependency %s of %s: %v", dep.ID, res.ID, err)
+			continue
+		}
+		return wrapDependencyError(err, dep.ID)
+	}
+	return nil
+}
+
+// wrapDependencyError wraps the error installing the dependency, unless it already names the dependency chain.
+func wrapDependencyError(err error, pluginID string) error {
+	if namesDependencyChain(err) {
+		return err
+	}
+	return errutil.Wrapf(err, "failed to install plugin '%s'", pluginID)
+}
+
+// applyPlanned moves the extracted plugin from the quarantine directory into the plugins directory and records it in
+// the lockfile, or moves it into the quarantine directory itself for plugins awaiting approval.
+func (i *Installer) applyPlanned(p *pendingInstall, pluginsDir string) (err error) {
+	defer func() {
+		i.audit(p.event, err)
+	}()
+	res := p.res
+	toDir := pluginsDir
+	if i.opts.QuarantineOnly {
+		toDir = quarantineDir(pluginsDir)
+	}
+	_, statErr := os.Lstat(filepath.Join(toDir, p.pluginID))
+	p.journal.record(journalEntry{PluginID: p.pluginID, Phase: journalPhaseApply, StagingDir: p.stagingDir,
+		TargetDir: toDir, Update: statErr == nil})
+	if p.previous, err = replacePlugin(p.stagingDir, toDir, p.pluginID); err != nil {
+		return err
+	}
+	p.installedTo = toDir
+	if i.opts.QuarantineOnly {
+		i.log.Successf("Quarantined %s v%s, it's installed once approved", res.ID, res.Info.Version)
+		// Quarantined plugins aren't installed, so they're only locked and recorded as dependencies by Approve
+		return writeQuarantineRecord(pluginsDir, p)
+	}
+	i.log.Successf("Installed %s v%s successfully", res.ID, res.Info.Version)
+	i.recordDependency(p, pluginsDir)
+	return i.lockPlugin(p, res)
+}
+
+// discard removes the downloaded archive and extracted plugin, and the journal entry of the install.
+func (p *pendingInstall) discard(log plugins.PluginInstallerLogger) {
+	p.journal.remove(p.pluginID)
+	if p.archivePath != "" {
+		if err := os.Remove(p.archivePath); err != nil {
+			log.Warn("Failed to remove temporary file", "file", p.archivePath, "err", err)
+		}
+		p.archivePath = ""
+	}
+	if p.stagingDir != "" {
+		if err := os.RemoveAll(p.stagingDir); err != nil {
+			log.Warn("Failed to remove quarantine directory", "dir", p.stagingDir, "err", err)
+		}
+		p.stagingDir = ""
+	}
+}
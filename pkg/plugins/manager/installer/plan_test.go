@@ -0,0 +1,132 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallPlan(t *testing.T) {
+	dir := writeTestDependencyRepo(t, map[string]string{
+		"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+			"dependencies":{"plugins":[{"id":"test-panel","type":"panel","version":"^1.0.0"}]}}`,
+		"test-panel-1.2.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.2.0"}}`,
+	})
+	newInstaller := func() *Installer {
+		return NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+	}
+
+	t.Run("Should plan dependencies first without installing", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		i := newInstaller()
+		plan, err := i.PlanInstall("test-app", "", pluginsDir, "", dir)
+		require.NoError(t, err)
+		assert.Equal(t, []PlannedPlugin{
+			{ID: "test-panel", Version: "1.2.0", RequiredBy: "test-app", Requested: "^1.0.0",
+				Source: filepath.Join(dir, "test-panel-1.2.0.zip")},
+			{ID: "test-app", Version: "1.0.0", Source: filepath.Join(dir, "test-app-1.0.0.zip")},
+		}, plan.Plugins)
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-app"))
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+
+		require.NoError(t, i.ApplyPlan(plan))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-app", "plugin.json"))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		assertQuarantineEmpty(t, pluginsDir)
+
+		plan, err = i.PlanInstall("test-panel", "", pluginsDir, "", dir)
+		require.NoError(t, err)
+		require.Len(t, plan.Plugins, 1)
+		assert.True(t, plan.Plugins[0].Update)
+		plan.Close()
+	})
+
+	t.Run("Should plan dependencies by ID whatever their order in the plugin.json", func(t *testing.T) {
+		var plans [][]PlannedPlugin
+		for _, deps := range []string{
+			`{"id":"test-panel","type":"panel"},{"id":"test-datasource","type":"datasource"}`,
+			`{"id":"test-datasource","type":"datasource"},{"id":"test-panel","type":"panel"}`,
+		} {
+			depsDir := writeTestDependencyRepo(t, map[string]string{
+				"test-app-1.0.0.zip": `{"id":"test-app","type":"app","name":"App","info":{"version":"1.0.0"},
+					"dependencies":{"plugins":[` + deps + `]}}`,
+				"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`,
+				"test-datasource-1.0.0.zip": `{"id":"test-datasource","type":"datasource","name":"Data source",
+					"info":{"version":"1.0.0"}}`,
+			})
+			plan, err := newInstaller().PlanInstall("test-app", "", t.TempDir(), "", depsDir)
+			require.NoError(t, err)
+			plan.Close()
+			planned := make([]PlannedPlugin, 0, len(plan.Plugins))
+			for _, p := range plan.Plugins {
+				planned = append(planned, PlannedPlugin{ID: p.ID, Version: p.Version, RequiredBy: p.RequiredBy})
+			}
+			plans = append(plans, planned)
+		}
+		assert.Equal(t, []PlannedPlugin{
+			{ID: "test-datasource", Version: "1.0.0", RequiredBy: "test-app"},
+			{ID: "test-panel", Version: "1.0.0", RequiredBy: "test-app"},
+			{ID: "test-app", Version: "1.0.0"},
+		}, plans[0])
+		assert.Equal(t, plans[0], plans[1])
+	})
+
+	t.Run("Should roll back dependencies if the plugin fails to be installed", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		lockfilePath := filepath.Join(t.TempDir(), "plugins.lock")
+		sink := &fakeAuditSink{}
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, LockfilePath: lockfilePath, AuditSink: sink},
+			"8.0.0", &fakeLogger{})
+		previousDir := filepath.Join(pluginsDir, "test-panel")
+		require.NoError(t, os.MkdirAll(previousDir, 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(previousDir, "plugin.json"),
+			[]byte(`{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`), 0600))
+
+		plan, err := i.PlanInstall("test-app", "", pluginsDir, "", dir)
+		require.NoError(t, err)
+		require.Len(t, plan.steps, 2)
+		// Moving the app into place fails once its dependency is installed
+		require.NoError(t, os.RemoveAll(filepath.Join(plan.steps[1].stagingDir, "test-app")))
+
+		require.Error(t, i.ApplyPlan(plan))
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-app"))
+		installed, err := toPluginDTO(pluginsDir, "test-panel")
+		require.NoError(t, err)
+		assert.Equal(t, "1.0.0", installed.Info.Version)
+		assert.NoFileExists(t, lockfilePath)
+		assertQuarantineEmpty(t, pluginsDir)
+
+		// The update of the dependency is audited, then its rollback once the app fails
+		require.Len(t, sink.events, 3)
+		assert.Equal(t, AuditOperationUpdate, sink.events[0].Operation)
+		assert.True(t, sink.events[0].Success)
+		assert.Equal(t, AuditOperationInstall, sink.events[1].Operation)
+		assert.False(t, sink.events[1].Success)
+		assert.Equal(t, AuditOperationRollback, sink.events[2].Operation)
+		assert.Equal(t, "test-panel", sink.events[2].PluginID)
+		assert.Equal(t, "1.2.0", sink.events[2].Version)
+		assert.True(t, sink.events[2].Success)
+	})
+
+	t.Run("Should remove extracted plugins when closed", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		plan, err := newInstaller().PlanInstall("test-app", "", pluginsDir, "", dir)
+		require.NoError(t, err)
+		plan.Close()
+
+		assertQuarantineEmpty(t, pluginsDir)
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-app"))
+	})
+}
+
+// assertQuarantineEmpty asserts that no plugins are left in the quarantine directory of the plugins directory.
+func assertQuarantineEmpty(t *testing.T, pluginsDir string) {
+	t.Helper()
+	entries, err := ioutil.ReadDir(quarantineDir(pluginsDir))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
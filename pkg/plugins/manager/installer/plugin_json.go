@@ -0,0 +1,151 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// pluginTypes are the plugin types Grafana loads.
+var pluginTypes = map[string]bool{
+	"panel":      true,
+	"datasource": true,
+	"app":        true,
+	"renderer":   true,
+}
+
+// reWildcardVersion matches version requirements like 7.x.x or 5.0+, which are used by older plugins.
+var reWildcardVersion = regexp.MustCompile(`^\d+(\.(\d+|x|\*)){0,2}\+?$`)
+
+// InvalidPluginJSONError is returned when a plugin.json of the installed plugin isn't valid, listing all problems
+// found.
+type InvalidPluginJSONError struct {
+	PluginID string
+	Path     string
+	Problems []string
+}
+
+func (e *InvalidPluginJSONError) Error() string {
+	return fmt.Sprintf("invalid %s of plugin %s: %s", e.Path, e.PluginID, strings.Join(e.Problems, "; "))
+}
+
+// pluginJSON holds the plugin.json fields that are validated.
+type pluginJSON struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	Backend    bool   `json:"backend"`
+	Executable string `json:"executable"`
+	Info       struct {
+		Version string `json:"version"`
+	} `json:"info"`
+	Dependencies struct {
+		GrafanaDependency string `json:"grafanaDependency"`
+		GrafanaVersion    string `json:"grafanaVersion"`
+		Plugins           []struct {
+			ID      string `json:"id"`
+			Type    string `json:"type"`
+			Version string `json:"version"`
+		} `json:"plugins"`
+	} `json:"dependencies"`
+}
+
+// validatePluginJSONs validates the plugin.json files of the installed plugin, including the ones of nested
+// plugins, so that the install fails with precise errors instead of Grafana rejecting the plugin when loading it.
+func validatePluginJSONs(pluginsDir, pluginID string) error {
+	pluginDir := filepath.Join(pluginsDir, pluginID)
+	return filepath.Walk(pluginDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || fi.Name() != "plugin.json" {
+			return nil
+		}
+
+		// nolint:gosec
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(pluginDir, path)
+		if err != nil {
+			return err
+		}
+		if problems := validatePluginJSON(data); len(problems) > 0 {
+			return &InvalidPluginJSONError{PluginID: pluginID, Path: filepath.ToSlash(rel), Problems: problems}
+		}
+		return nil
+	})
+}
+
+// validatePluginJSON returns the problems of a plugin.json.
+func validatePluginJSON(data []byte) []string {
+	var pj pluginJSON
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return []string{fmt.Sprintf("malformed JSON: %s", err)}
+	}
+
+	var problems []string
+	if pj.ID == "" {
+		problems = append(problems, "id is required")
+	}
+	if pj.Name == "" {
+		problems = append(problems, "name is required")
+	}
+	switch {
+	case pj.Type == "":
+		problems = append(problems, "type is required")
+	case !pluginTypes[pj.Type]:
+		problems = append(problems, fmt.Sprintf("unknown type %q, expected panel, datasource, app or renderer",
+			pj.Type))
+	}
+	if (pj.Backend || pj.Type == "renderer") && pj.Executable == "" {
+		problems = append(problems, "executable is required for backend plugins")
+	}
+	if pj.Info.Version != "" {
+		if _, err := version.NewVersion(pj.Info.Version); err != nil {
+			problems = append(problems, fmt.Sprintf("info.version %q is not a valid version", pj.Info.Version))
+		}
+	}
+
+	if r := pj.Dependencies.GrafanaDependency; r != "" && !isValidVersionRange(r) {
+		problems = append(problems, fmt.Sprintf("dependencies.grafanaDependency %q is not a valid version range", r))
+	}
+	if r := pj.Dependencies.GrafanaVersion; r != "" && !isValidVersionRange(r) {
+		problems = append(problems, fmt.Sprintf("dependencies.grafanaVersion %q is not a valid version range", r))
+	}
+	for idx, dep := range pj.Dependencies.Plugins {
+		if dep.ID == "" {
+			problems = append(problems, fmt.Sprintf("dependencies.plugins[%d].id is required", idx))
+		}
+		if dep.Type != "" && !pluginTypes[dep.Type] {
+			problems = append(problems, fmt.Sprintf("dependencies.plugins[%d].type %q is unknown", idx, dep.Type))
+		}
+		if dep.Version != "" && !isValidVersionRange(dep.Version) {
+			problems = append(problems, fmt.Sprintf("dependencies.plugins[%d].version %q is not a valid version range",
+				idx, dep.Version))
+		}
+	}
+	return problems
+}
+
+// isValidVersionRange reports whether r is a version constraint like >=7.0.0, a caret or tilde range like ^7.0.0
+// or a wildcard version like 7.x.x.
+func isValidVersionRange(r string) bool {
+	r = strings.TrimSpace(r)
+	if reWildcardVersion.MatchString(r) {
+		return true
+	}
+	if strings.HasPrefix(r, "^") || strings.HasPrefix(r, "~") {
+		_, err := version.NewVersion(r[1:])
+		return err == nil
+	}
+	_, err := version.NewConstraint(r)
+	return err == nil
+}
@@ -0,0 +1,80 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePluginJSON(t *testing.T) {
+	t.Run("Should accept valid plugin.json", func(t *testing.T) {
+		for _, data := range []string{
+			`{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`,
+			`{"id":"test-app","name":"Test","type":"app","backend":true,"executable":"gpx_app",
+			  "dependencies":{"grafanaDependency":">=7.0.0","plugins":[{"id":"test-panel","type":"panel","version":"^1.0.0"}]}}`,
+			`{"id":"test-datasource","name":"Test","type":"datasource","dependencies":{"grafanaVersion":"7.x.x"}}`,
+			`{"id":"test-renderer","name":"Test","type":"renderer","executable":"plugin_start",
+			  "dependencies":{"grafanaVersion":"6.4.x","grafanaDependency":">= 6.4.0, < 9.0.0"}}`,
+		} {
+			assert.Empty(t, validatePluginJSON([]byte(data)), data)
+		}
+	})
+
+	tcs := []struct {
+		data             string
+		expectedProblems []string
+	}{
+		{
+			data:             `{"id":"test-panel"`,
+			expectedProblems: []string{"malformed JSON: unexpected end of JSON input"},
+		},
+		{
+			data:             `{}`,
+			expectedProblems: []string{"id is required", "name is required", "type is required"},
+		},
+		{
+			data: `{"id":"test-panel","name":"Test","type":"widget","info":{"version":"one"}}`,
+			expectedProblems: []string{
+				`unknown type "widget", expected panel, datasource, app or renderer`,
+				`info.version "one" is not a valid version`,
+			},
+		},
+		{
+			data:             `{"id":"test-datasource","name":"Test","type":"datasource","backend":true}`,
+			expectedProblems: []string{"executable is required for backend plugins"},
+		},
+		{
+			data: `{"id":"test-app","name":"Test","type":"app","dependencies":{"grafanaDependency":"seven",
+				"plugins":[{"type":"dashboard","version":"latest"}]}}`,
+			expectedProblems: []string{
+				`dependencies.grafanaDependency "seven" is not a valid version range`,
+				"dependencies.plugins[0].id is required",
+				`dependencies.plugins[0].type "dashboard" is unknown`,
+				`dependencies.plugins[0].version "latest" is not a valid version range`,
+			},
+		},
+	}
+	for _, tc := range tcs {
+		assert.Equal(t, tc.expectedProblems, validatePluginJSON([]byte(tc.data)), tc.data)
+	}
+
+	t.Run("Should validate nested plugins", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		nestedDir := filepath.Join(pluginsDir, "test-app", "datasource")
+		require.NoError(t, os.MkdirAll(nestedDir, 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pluginsDir, "test-app", "plugin.json"),
+			[]byte(`{"id":"test-app","name":"Test","type":"app"}`), 0600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(nestedDir, "plugin.json"),
+			[]byte(`{"id":"test-datasource","type":"datasource"}`), 0600))
+
+		err := validatePluginJSONs(pluginsDir, "test-app")
+		var jsonErr *InvalidPluginJSONError
+		require.ErrorAs(t, err, &jsonErr)
+		assert.Equal(t, "datasource/plugin.json", jsonErr.Path)
+		assert.Equal(t, []string{"name is required"}, jsonErr.Problems)
+	})
+}
@@ -0,0 +1,120 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// PluginNotAllowedError is returned when a plugin is denied, or isn't allowed, by the configured plugin ID
+// patterns.
+type PluginNotAllowedError struct {
+	PluginID string
+	Pattern  string
+}
+
+func (e *PluginNotAllowedError) Error() string {
+	if e.Pattern != "" {
+		return fmt.Sprintf("installing plugin %s is denied by pattern %q", e.PluginID, e.Pattern)
+	}
+	return fmt.Sprintf("installing plugin %s is not allowed", e.PluginID)
+}
+
+// checkPluginAllowed verifies that the plugin isn't matched by any of the denied plugin patterns and, if allowed
+// plugin patterns are configured, that it's matched by one of them. Denied patterns take precedence.
+func (i *Installer) checkPluginAllowed(pluginID string) error {
+	for _, pattern := range i.opts.DeniedPlugins {
+		match, err := matchPluginPattern(pattern, pluginID)
+		if err != nil {
+			return err
+		}
+		if match {
+			return &PluginNotAllowedError{PluginID: pluginID, Pattern: pattern}
+		}
+	}
+
+	if len(i.opts.AllowedPlugins) == 0 {
+		return nil
+	}
+	for _, pattern := range i.opts.AllowedPlugins {
+		match, err := matchPluginPattern(pattern, pluginID)
+		if err != nil {
+			return err
+		}
+		if match {
+			return nil
+		}
+	}
+	return &PluginNotAllowedError{PluginID: pluginID}
+}
+
+// PluginIDMismatchError is returned when the plugin.json of an extracted plugin declares another ID than the one
+// of the plugin being installed.
+type PluginIDMismatchError struct {
+	PluginID   string
+	DeclaredID string
+}
+
+func (e *PluginIDMismatchError) Error() string {
+	return fmt.Sprintf("plugin.json of plugin %s declares plugin ID %s", e.PluginID, e.DeclaredID)
+}
+
+// checkPluginIDs verifies that the plugin.json of the extracted plugin declares the ID of the plugin being
+// installed, and that the IDs of the plugin and of all nested plugins are allowed. The allow and deny patterns are
+// otherwise only matched against the requested ID, which an archive from a direct URL can declare any plugin under.
+func (i *Installer) checkPluginIDs(pluginsDir, pluginID string) error {
+	pluginDir := filepath.Join(pluginsDir, pluginID)
+	return filepath.Walk(pluginDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || fi.Name() != "plugin.json" {
+			return nil
+		}
+
+		// nolint:gosec
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var pj pluginJSON
+		if err := json.Unmarshal(data, &pj); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(pluginDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "plugin.json" || rel == filepath.Join("dist", "plugin.json") {
+			if pj.ID != pluginID {
+				return &PluginIDMismatchError{PluginID: pluginID, DeclaredID: pj.ID}
+			}
+			return nil
+		}
+		return i.checkPluginAllowed(pj.ID)
+	})
+}
+
+// matchPluginPattern matches a plugin ID against a glob, e.g. grafana-*, or against a regular expression enclosed
+// in slashes, e.g. /^grafana-.+-datasource$/.
+func matchPluginPattern(pattern, pluginID string) (bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return false, fmt.Errorf("invalid plugin pattern %q: %w", pattern, err)
+		}
+		return re.MatchString(pluginID), nil
+	}
+
+	g, err := glob.Compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("invalid plugin pattern %q: %w", pattern, err)
+	}
+	return g.Match(pluginID), nil
+}
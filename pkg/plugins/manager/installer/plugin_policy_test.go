@@ -0,0 +1,85 @@
+package installer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPluginAllowed(t *testing.T) {
+	t.Run("Should allow all plugins without patterns", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+		require.NoError(t, i.checkPluginAllowed("test-panel"))
+	})
+
+	t.Run("Should only allow plugins matching allowed patterns", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}, opts: Opts{
+			AllowedPlugins: []string{"grafana-*", "/^corp-.+-datasource$/"},
+		}}
+
+		require.NoError(t, i.checkPluginAllowed("grafana-clock-panel"))
+		require.NoError(t, i.checkPluginAllowed("corp-metrics-datasource"))
+
+		err := i.checkPluginAllowed("corp-metrics-panel")
+		var notAllowedErr *PluginNotAllowedError
+		require.ErrorAs(t, err, &notAllowedErr)
+		assert.Equal(t, "corp-metrics-panel", notAllowedErr.PluginID)
+		assert.Empty(t, notAllowedErr.Pattern)
+	})
+
+	t.Run("Should deny plugins matching denied patterns", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}, opts: Opts{
+			AllowedPlugins: []string{"grafana-*"},
+			DeniedPlugins:  []string{"*-simple-json-*"},
+		}}
+
+		require.NoError(t, i.checkPluginAllowed("grafana-clock-panel"))
+
+		err := i.checkPluginAllowed("grafana-simple-json-datasource")
+		var notAllowedErr *PluginNotAllowedError
+		require.ErrorAs(t, err, &notAllowedErr)
+		assert.Equal(t, "*-simple-json-*", notAllowedErr.Pattern)
+	})
+
+	t.Run("Should fail for invalid patterns", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}, opts: Opts{DeniedPlugins: []string{"/(/"}}}
+		err := i.checkPluginAllowed("test-panel")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid plugin pattern")
+	})
+
+	t.Run("Should enforce patterns before downloading", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+		i := &Installer{log: &fakeLogger{}, opts: Opts{DeniedPlugins: []string{"test-*"}}}
+
+		err := i.Install("test-panel", "", t.TempDir(), archive, "")
+		var notAllowedErr *PluginNotAllowedError
+		require.ErrorAs(t, err, &notAllowedErr)
+	})
+
+	t.Run("Should reject plugins declaring another ID than requested", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{
+			"plugin.json": `{"id":"evil-panel","name":"Evil","type":"panel","info":{"version":"1.0.0"}}`,
+		})
+		i := &Installer{log: &fakeLogger{}, opts: Opts{DeniedPlugins: []string{"evil-*"}}}
+
+		err := i.Install("test-panel", "", t.TempDir(), archive, "")
+		var mismatchErr *PluginIDMismatchError
+		require.ErrorAs(t, err, &mismatchErr)
+		assert.Equal(t, "evil-panel", mismatchErr.DeclaredID)
+	})
+
+	t.Run("Should enforce patterns on nested plugins", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{
+			"plugin.json":        `{"id":"test-panel","name":"Test","type":"app","info":{"version":"1.0.0"}}`,
+			"nested/plugin.json": `{"id":"evil-panel","name":"Evil","type":"panel","info":{"version":"1.0.0"}}`,
+		})
+		i := &Installer{log: &fakeLogger{}, opts: Opts{DeniedPlugins: []string{"evil-*"}}}
+
+		err := i.Install("test-panel", "", t.TempDir(), archive, "")
+		var notAllowedErr *PluginNotAllowedError
+		require.ErrorAs(t, err, &notAllowedErr)
+		assert.Equal(t, "evil-panel", notAllowedErr.PluginID)
+	})
+}
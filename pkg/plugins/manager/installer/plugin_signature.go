@@ -0,0 +1,164 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
+// SignaturePolicy decides what happens when an installed plugin doesn't have a valid signature.
+type SignaturePolicy string
+
+const (
+	// SignaturePolicyWarn logs a warning for plugins without a valid signature.
+	SignaturePolicyWarn SignaturePolicy = "warn"
+	// SignaturePolicyRequire fails the installation of plugins without a valid signature.
+	SignaturePolicyRequire SignaturePolicy = "require"
+	// SignaturePolicyIgnore skips the signature verification.
+	SignaturePolicyIgnore SignaturePolicy = "ignore"
+)
+
+// ParseSignaturePolicy returns the signature policy with the provided name. An empty name returns the
+// warn policy.
+func ParseSignaturePolicy(name string) (SignaturePolicy, error) {
+	switch p := SignaturePolicy(strings.ToLower(name)); p {
+	case "":
+		return SignaturePolicyWarn, nil
+	case SignaturePolicyWarn, SignaturePolicyRequire, SignaturePolicyIgnore:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown signature policy %q, valid policies are warn, require and ignore", name)
+}
+
+// SignatureLevel is a plugin signature type, ranked by the trust it conveys, see repo.SignatureLevel.
+type SignatureLevel = repo.SignatureLevel
+
+const (
+	SignatureLevelPrivate    = repo.SignatureLevelPrivate
+	SignatureLevelCommunity  = repo.SignatureLevelCommunity
+	SignatureLevelCommercial = repo.SignatureLevelCommercial
+	SignatureLevelGrafana    = repo.SignatureLevelGrafana
+)
+
+// ParseSignatureLevel returns the signature level with the provided name. An empty name returns an empty level,
+// which doesn't require any signature level.
+func ParseSignatureLevel(name string) (SignatureLevel, error) {
+	l := SignatureLevel(strings.ToLower(name))
+	if l == "" || l.Rank() > 0 {
+		return l, nil
+	}
+	return "", fmt.Errorf("unknown signature level %q, valid levels are private, community, commercial and grafana",
+		name)
+}
+
+// SignatureLevelError is returned when an installed plugin is signed below the required signature level.
+type SignatureLevelError struct {
+	PluginID string
+	Type     plugins.PluginSignatureType
+	Minimum  SignatureLevel
+}
+
+func (e *SignatureLevelError) Error() string {
+	return fmt.Sprintf("plugin %s has a %s signature, but at least a %s signature is required", e.PluginID, e.Type,
+		e.Minimum)
+}
+
+// SignatureError is returned when an installed plugin doesn't have a valid signature and the signature policy
+// requires one.
+type SignatureError struct {
+	PluginID string
+	Status   plugins.PluginSignatureStatus
+	Reason   string
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("plugin %s signature is %s: %s", e.PluginID, e.Status, e.Reason)
+}
+
+// checkPluginSignature verifies the signature of the installed plugin and applies the signature policy and the
+// minimum signature level. Plugins that fail the check under the require policy, or that don't meet the minimum
+// signature level, are removed again.
+func (i *Installer) checkPluginSignature(pluginsDir, pluginID string) error {
+	policy := i.opts.SignaturePolicy
+	if policy == "" {
+		policy = SignaturePolicyWarn
+	}
+	if policy == SignaturePolicyIgnore {
+		return nil
+	}
+	// Archives can contain any plugin, so the exemption applies to the ID the plugin declares
+	if pluginInfo, err := toPluginDTO(pluginsDir, pluginID); err == nil {
+		for _, id := range i.opts.AllowUnsigned {
+			if id == pluginInfo.ID {
+				i.log.Debugf("Skipping signature verification of %s, which is allowed to be unsigned", pluginInfo.ID)
+				return nil
+			}
+		}
+	}
+
+	state, reason := verifyPluginSignature(pluginsDir, pluginID, i.opts.AppURL, i.opts.IgnoreRootURLs)
+	minimum := i.opts.MinSignatureLevel
+	if state.Status.IsValid() && minimum != "" &&
+		SignatureLevel(state.Type).Rank() < minimum.Rank() {
+		if err := os.RemoveAll(filepath.Join(pluginsDir, pluginID)); err != nil {
+			i.log.Warn("Failed to remove plugin", "plugin", pluginID, "err", err)
+		}
+		return &SignatureLevelError{PluginID: pluginID, Type: state.Type, Minimum: minimum}
+	}
+	if state.Status.IsValid() {
+		if state.Type == plugins.PrivateType {
+			i.log.Infof("Plugin %s has a private signature by %s, it only loads on the Grafana instances the "+
+				"signature was issued for", pluginID, state.SigningOrg)
+		} else {
+			i.log.Infof("Verified signature of %s by %s", pluginID, state.SigningOrg)
+		}
+		return nil
+	}
+
+	sigErr := &SignatureError{PluginID: pluginID, Status: state.Status, Reason: reason}
+	if policy == SignaturePolicyRequire || minimum != "" {
+		if err := os.RemoveAll(filepath.Join(pluginsDir, pluginID)); err != nil {
+			i.log.Warn("Failed to remove plugin", "plugin", pluginID, "err", err)
+		}
+		return sigErr
+	}
+	i.log.Warnf("%s, Grafana won't load the plugin unless it's allowed with allow_loading_unsigned_plugins", sigErr)
+	return nil
+}
+
+// verifyPluginSignature checks the MANIFEST.txt of the installed plugin the same way Grafana does when loading
+// the plugin. The root URLs of private signatures are checked against the app URL unless ignoreRootURLs is set.
+// It returns the reason for signatures that aren't valid.
+func verifyPluginSignature(pluginsDir, pluginID, appURL string, ignoreRootURLs bool) (plugins.PluginSignatureState,
+	string) {
+	pluginDir := filepath.Join(pluginsDir, pluginID, "dist")
+	if _, err := os.Stat(filepath.Join(pluginDir, "plugin.json")); err != nil {
+		pluginDir = filepath.Join(pluginsDir, pluginID)
+	}
+
+	pluginInfo, err := toPluginDTO(pluginsDir, pluginID)
+	if err != nil {
+		return plugins.PluginSignatureState{Status: plugins.PluginSignatureModified}, err.Error()
+	}
+	files, err := plugins.CollectPluginFiles(pluginDir)
+	if err != nil {
+		return plugins.PluginSignatureState{Status: plugins.PluginSignatureModified}, err.Error()
+	}
+	var state plugins.PluginSignatureState
+	var reason string
+	if ignoreRootURLs {
+		state, reason, err = plugins.GetPluginSignatureStateIgnoringRootURLs(pluginDir, pluginInfo.ID,
+			pluginInfo.Info.Version, files)
+	} else {
+		state, reason, err = plugins.GetPluginSignatureState(pluginDir, pluginInfo.ID, pluginInfo.Info.Version,
+			appURL, files)
+	}
+	if err != nil {
+		return plugins.PluginSignatureState{Status: plugins.PluginSignatureInvalid}, err.Error()
+	}
+	return state, reason
+}
@@ -0,0 +1,153 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPluginSignature(t *testing.T) {
+	tcs := []struct {
+		testdata       string
+		extraFile      bool
+		expectedStatus plugins.PluginSignatureStatus
+	}{
+		{testdata: "valid-v2-signature", expectedStatus: plugins.PluginSignatureValid},
+		{testdata: "valid-v2-signature", extraFile: true, expectedStatus: plugins.PluginSignatureModified},
+		{testdata: "invalid-v1-signature", expectedStatus: plugins.PluginSignatureInvalid},
+		{testdata: "invalid-v2-signature", expectedStatus: plugins.PluginSignatureModified},
+		{testdata: "lacking-files", expectedStatus: plugins.PluginSignatureModified},
+		{testdata: "unsigned", expectedStatus: plugins.PluginSignatureUnsigned},
+	}
+	for _, tc := range tcs {
+		pluginsDir := copyTestPlugin(t, tc.testdata)
+		if tc.extraFile {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(pluginsDir, "test", "module.js"), []byte("{}"), 0600))
+		}
+
+		state, reason := verifyPluginSignature(pluginsDir, "test", "", false)
+		assert.Equal(t, tc.expectedStatus, state.Status, "%s: %s", tc.testdata, reason)
+	}
+
+	t.Run("Should remove plugin with invalid signature when required", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "unsigned")
+		i := &Installer{log: &fakeLogger{}, opts: Opts{SignaturePolicy: SignaturePolicyRequire}}
+
+		err := i.checkPluginSignature(pluginsDir, "test")
+		var sigErr *SignatureError
+		require.ErrorAs(t, err, &sigErr)
+		assert.Equal(t, plugins.PluginSignatureUnsigned, sigErr.Status)
+		_, err = os.Stat(filepath.Join(pluginsDir, "test"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Should keep plugin with invalid signature when warning", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "unsigned")
+		i := &Installer{log: &fakeLogger{}}
+
+		require.NoError(t, i.checkPluginSignature(pluginsDir, "test"))
+		_, err := os.Stat(filepath.Join(pluginsDir, "test", "plugin.json"))
+		require.NoError(t, err)
+	})
+
+	t.Run("Should allow listed unsigned plugins", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "unsigned")
+		i := &Installer{log: &fakeLogger{}, opts: Opts{SignaturePolicy: SignaturePolicyRequire,
+			AllowUnsigned: []string{"test"}}}
+
+		require.NoError(t, i.checkPluginSignature(pluginsDir, "test"))
+	})
+
+	t.Run("Should match listed unsigned plugins against the plugin.json ID", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "unsigned")
+		require.NoError(t, os.Rename(filepath.Join(pluginsDir, "test"), filepath.Join(pluginsDir, "allowed")))
+		i := &Installer{log: &fakeLogger{}, opts: Opts{SignaturePolicy: SignaturePolicyRequire,
+			AllowUnsigned: []string{"allowed"}}}
+
+		var sigErr *SignatureError
+		require.ErrorAs(t, i.checkPluginSignature(pluginsDir, "allowed"), &sigErr)
+	})
+}
+
+func TestSignatureLevel(t *testing.T) {
+	t.Run("Should verify root URLs of private signatures", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "valid-v2-pvt-signature")
+
+		state, reason := verifyPluginSignature(pluginsDir, "test", "http://localhost:3000/", false)
+		assert.Equal(t, plugins.PluginSignatureValid, state.Status, reason)
+		assert.Equal(t, plugins.PrivateType, state.Type)
+
+		state, _ = verifyPluginSignature(pluginsDir, "test", "http://localhost:1234/", false)
+		assert.Equal(t, plugins.PluginSignatureInvalid, state.Status)
+
+		state, _ = verifyPluginSignature(pluginsDir, "test", "", false)
+		assert.Equal(t, plugins.PluginSignatureInvalid, state.Status)
+	})
+
+	t.Run("Should skip root URLs of private signatures if told to", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "valid-v2-pvt-signature")
+
+		state, reason := verifyPluginSignature(pluginsDir, "test", "http://localhost:1234/", true)
+		assert.Equal(t, plugins.PluginSignatureValid, state.Status, reason)
+	})
+
+	t.Run("Should remove plugin signed below minimum level", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "valid-v2-pvt-signature")
+		i := &Installer{log: &fakeLogger{}, opts: Opts{MinSignatureLevel: SignatureLevelCommunity,
+			AppURL: "http://localhost:3000/"}}
+
+		err := i.checkPluginSignature(pluginsDir, "test")
+		var levelErr *SignatureLevelError
+		require.ErrorAs(t, err, &levelErr)
+		assert.Equal(t, plugins.PrivateType, levelErr.Type)
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test"))
+	})
+
+	t.Run("Should accept plugin signed at minimum level", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "valid-v2-signature")
+		i := &Installer{log: &fakeLogger{}, opts: Opts{MinSignatureLevel: SignatureLevelGrafana}}
+
+		require.NoError(t, i.checkPluginSignature(pluginsDir, "test"))
+	})
+
+	t.Run("Should reject unsigned plugin with minimum level", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "unsigned")
+		i := &Installer{log: &fakeLogger{}, opts: Opts{MinSignatureLevel: SignatureLevelPrivate}}
+
+		var sigErr *SignatureError
+		require.ErrorAs(t, i.checkPluginSignature(pluginsDir, "test"), &sigErr)
+	})
+
+	t.Run("Should parse signature levels", func(t *testing.T) {
+		l, err := ParseSignatureLevel("Commercial")
+		require.NoError(t, err)
+		assert.Equal(t, SignatureLevelCommercial, l)
+
+		_, err = ParseSignatureLevel("trusted")
+		require.Error(t, err)
+	})
+}
+
+// copyTestPlugin copies a plugin from the plugin manager's testdata into a new plugins directory.
+func copyTestPlugin(t *testing.T, testdata string) string {
+	t.Helper()
+
+	src := filepath.Join("..", "testdata", testdata, "plugin")
+	pluginsDir := t.TempDir()
+	dst := filepath.Join(pluginsDir, "test")
+	require.NoError(t, os.MkdirAll(dst, 0750))
+
+	entries, err := ioutil.ReadDir(src)
+	require.NoError(t, err)
+	for _, e := range entries {
+		data, err := ioutil.ReadFile(filepath.Join(src, e.Name()))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dst, e.Name()), data, 0600))
+	}
+	return pluginsDir
+}
@@ -0,0 +1,9 @@
+// +build !linux,!darwin,!freebsd,!windows
+
+package installer
+
+// processRunning can't tell whether processes are running on this platform, so they're assumed to be, and their
+// installs are never rolled back.
+func processRunning(pid int) bool {
+	return true
+}
@@ -0,0 +1,15 @@
+// +build linux darwin freebsd
+
+package installer
+
+import "syscall"
+
+// processRunning reports whether a process with the PID is running. Processes of other users are running if
+// signalling them isn't permitted.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
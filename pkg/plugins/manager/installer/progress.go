@@ -0,0 +1,91 @@
+package installer
+
+import "io"
+
+// ProgressPhase is the phase of installing a plugin that progress is reported for.
+type ProgressPhase string
+
+const (
+	// ProgressPhaseDownload reports the bytes of the plugin archive downloaded.
+	ProgressPhaseDownload ProgressPhase = "download"
+	// ProgressPhaseExtract reports the members of the plugin archive extracted.
+	ProgressPhaseExtract ProgressPhase = "extract"
+)
+
+// Progress is the progress of a phase of installing a plugin.
+type Progress struct {
+	PluginID string
+	Phase    ProgressPhase
+	// Done is the number of bytes downloaded or archive members extracted so far.
+	Done int64
+	// Total is the size of the archive from the Content-Length of the download or the number of members of the
+	// archive, 0 if it's unknown, e.g. for tarballs, which are extracted while they're read.
+	Total int64
+}
+
+// ProgressFunc receives the progress of downloading and extracting plugins. Dependencies are downloaded
+// concurrently, so it may be called from multiple goroutines at once.
+type ProgressFunc func(progress Progress)
+
+// reportProgress passes the progress to the progress callback, if any.
+func (i *Installer) reportProgress(progress Progress) {
+	if i.opts.Progress != nil {
+		i.opts.Progress(progress)
+	}
+}
+
+// progressReader reports the bytes read from r as download progress of the plugin.
+func (i *Installer) progressReader(r io.Reader, pluginID string, total int64) io.Reader {
+	if i.opts.Progress == nil {
+		return r
+	}
+	if total < 0 {
+		total = 0
+	}
+	i.reportProgress(Progress{PluginID: pluginID, Phase: ProgressPhaseDownload, Total: total})
+	return &progressReader{r: r, report: func(done int64) {
+		i.reportProgress(Progress{PluginID: pluginID, Phase: ProgressPhaseDownload, Done: done, Total: total})
+	}}
+}
+
+type progressReader struct {
+	r      io.Reader
+	done   int64
+	report func(done int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.done += int64(n)
+		p.report(p.done)
+	}
+	return n, err
+}
+
+// sizedArchive is implemented by plugin archives that know their number of members before they're walked.
+type sizedArchive interface {
+	size() int
+}
+
+// extractProgress reports the members extracted from the archive of a plugin.
+type extractProgress struct {
+	i      *Installer
+	plugin string
+	done   int64
+	total  int64
+}
+
+func (i *Installer) newExtractProgress(pluginID string, r pluginArchive) *extractProgress {
+	p := &extractProgress{i: i, plugin: pluginID}
+	if sized, ok := r.(sizedArchive); ok {
+		p.total = int64(sized.size())
+	}
+	return p
+}
+
+// memberDone reports another extracted member.
+func (p *extractProgress) memberDone() {
+	p.done++
+	p.i.reportProgress(Progress{PluginID: p.plugin, Phase: ProgressPhaseExtract, Done: p.done, Total: p.total})
+}
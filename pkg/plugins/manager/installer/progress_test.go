@@ -0,0 +1,76 @@
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	t.Run("Should report download progress against the Content-Length", func(t *testing.T) {
+		data := bytes.Repeat([]byte("a"), 100000)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			_, _ = w.Write(data)
+		}))
+		t.Cleanup(server.Close)
+
+		var reported []Progress
+		i := NewWithOpts(Opts{Progress: func(p Progress) { reported = append(reported, p) }}, "8.0.0", &fakeLogger{})
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = tmpFile.Close() })
+
+		require.NoError(t, i.downloadFile("test-panel", tmpFile, server.URL, "", 0))
+		require.NotEmpty(t, reported)
+		assert.Equal(t, Progress{PluginID: "test-panel", Phase: ProgressPhaseDownload, Total: int64(len(data))},
+			reported[0])
+		assert.Equal(t, Progress{PluginID: "test-panel", Phase: ProgressPhaseDownload, Done: int64(len(data)),
+			Total: int64(len(data))}, reported[len(reported)-1])
+	})
+
+	t.Run("Should report extracted members against the number of zip members", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		zw := zip.NewWriter(buf)
+		for _, name := range []string{"test-panel/plugin.json", "test-panel/module.js"} {
+			w, err := zw.Create(name)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("{}"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+		archive := filepath.Join(t.TempDir(), "plugin.zip")
+		require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0600))
+
+		var reported []Progress
+		i := &Installer{log: &fakeLogger{}, opts: Opts{Progress: func(p Progress) { reported = append(reported, p) }}}
+		require.NoError(t, i.extractFiles(archive, "test-panel", t.TempDir(), false))
+		assert.Equal(t, []Progress{
+			{PluginID: "test-panel", Phase: ProgressPhaseExtract, Done: 1, Total: 2},
+			{PluginID: "test-panel", Phase: ProgressPhaseExtract, Done: 2, Total: 2},
+		}, reported)
+	})
+
+	t.Run("Should report extracted members without total for tarballs", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{
+			"plugin.json": `{"id":"test-panel","type":"panel","info":{"version":"1.0.0"}}`,
+		})
+
+		var reported []Progress
+		i := &Installer{log: &fakeLogger{}, opts: Opts{Progress: func(p Progress) { reported = append(reported, p) }}}
+		require.NoError(t, i.extractFiles(archive, "test-panel", t.TempDir(), false))
+		require.NotEmpty(t, reported)
+		last := reported[len(reported)-1]
+		assert.Equal(t, ProgressPhaseExtract, last.Phase)
+		assert.Equal(t, int64(len(reported)), last.Done)
+		assert.Zero(t, last.Total)
+	})
+}
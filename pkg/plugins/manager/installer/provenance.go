@@ -0,0 +1,289 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+const (
+	// provenanceSuffix is appended to the archive URL to find its SLSA provenance, the in-toto attestations
+	// output by the SLSA GitHub generator.
+	provenanceSuffix      = ".intoto.jsonl"
+	inTotoPayloadType     = "application/vnd.in-toto+json"
+	slsaProvenanceV02Type = "https://slsa.dev/provenance/v0.2"
+	slsaProvenanceV1Type  = "https://slsa.dev/provenance/v1"
+)
+
+// ProvenanceOpts configures the verification of the SLSA provenance of plugin archives. The provenance is a
+// DSSE envelope per line containing an in-toto statement, which has to be signed with the configured key, list
+// the archive digest as subject and, if configured, name one of the trusted builders and source repositories.
+type ProvenanceOpts struct {
+	// KeyPath is the path to the PEM encoded public key the provenance has to be signed with. Provenance is only
+	// verified if it's set.
+	KeyPath string
+	// URL is the URL or path of the provenance. Defaults to <archive url>.intoto.jsonl.
+	URL string
+	// BuilderIDs are the IDs of the builders trusted to build plugins, e.g.
+	// https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml@refs/tags/v1.9.0.
+	BuilderIDs []string
+	// SourceRepos are the repositories plugins may be built from, e.g. github.com/org/plugin.
+	SourceRepos []string
+}
+
+// ProvenanceError is returned when the provenance of a plugin archive can't be verified.
+type ProvenanceError struct {
+	Reason string
+}
+
+func (e *ProvenanceError) Error() string {
+	return fmt.Sprintf("provenance verification failed: %s", e.Reason)
+}
+
+// dsseEnvelope is a DSSE envelope as used for in-toto attestations.
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+	Signatures  []struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// provenanceStatement holds the fields of an in-toto statement with a SLSA v0.2 or v1 provenance predicate that
+// are verified.
+type provenanceStatement struct {
+	Subject []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	PredicateType string `json:"predicateType"`
+	Predicate     struct {
+		// SLSA v0.2
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Invocation struct {
+			ConfigSource struct {
+				URI string `json:"uri"`
+			} `json:"configSource"`
+		} `json:"invocation"`
+		// SLSA v1
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+		} `json:"runDetails"`
+		BuildDefinition struct {
+			ExternalParameters struct {
+				Workflow struct {
+					Repository string `json:"repository"`
+				} `json:"workflow"`
+			} `json:"externalParameters"`
+			ResolvedDependencies []struct {
+				URI string `json:"uri"`
+			} `json:"resolvedDependencies"`
+		} `json:"buildDefinition"`
+	} `json:"predicate"`
+}
+
+func (s *provenanceStatement) builderID() string {
+	if s.PredicateType == slsaProvenanceV1Type {
+		return s.Predicate.RunDetails.Builder.ID
+	}
+	return s.Predicate.Builder.ID
+}
+
+func (s *provenanceStatement) sourceRepo() string {
+	if s.PredicateType != slsaProvenanceV1Type {
+		return s.Predicate.Invocation.ConfigSource.URI
+	}
+	if repo := s.Predicate.BuildDefinition.ExternalParameters.Workflow.Repository; repo != "" {
+		return repo
+	}
+	if deps := s.Predicate.BuildDefinition.ResolvedDependencies; len(deps) > 0 {
+		return deps[0].URI
+	}
+	return ""
+}
+
+// verifyProvenance verifies the SLSA provenance of the archive when a provenance key is configured. One of the
+// attestations has to be signed with the key, attest the archive's SHA256 digest and meet the builder and source
+// requirements.
+func (i *Installer) verifyProvenance(archivePath, archiveURL string) error {
+	opts := i.opts.Provenance
+	if opts.KeyPath == "" {
+		return nil
+	}
+
+	// nolint:gosec
+	keyPEM, err := ioutil.ReadFile(opts.KeyPath)
+	if err != nil {
+		return errutil.Wrap("failed to read provenance public key", err)
+	}
+	pub, err := parsePEMPublicKey(keyPEM)
+	if err != nil {
+		return errutil.Wrap("invalid provenance public key", err)
+	}
+	if err := i.checkFIPSKey(pub); err != nil {
+		return errutil.Wrap("invalid provenance public key", err)
+	}
+
+	// nolint:gosec
+	artifact, err := ioutil.ReadFile(archivePath)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(artifact)
+
+	provenanceURL := opts.URL
+	if provenanceURL == "" {
+		provenanceURL = archiveURL + provenanceSuffix
+	}
+	i.log.Debugf("Fetching provenance from %s", RedactURL(provenanceURL))
+	body, err := i.readCompanionFile(provenanceURL)
+	if err != nil {
+		return errutil.Wrapf(err, "failed to fetch provenance %s", RedactURL(provenanceURL))
+	}
+
+	// Report why the last attestation was rejected if none is acceptable, as provenance usually contains a
+	// single attestation
+	reason := "no attestations found"
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, 10<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		statement, err := verifyProvenanceEnvelope(line, pub)
+		if err != nil {
+			reason = err.Error()
+			continue
+		}
+		if err := checkProvenanceStatement(statement, hex.EncodeToString(digest[:]), opts); err != nil {
+			reason = err.Error()
+			continue
+		}
+		i.log.Infof("Verified provenance of %s built by %s", statement.sourceRepo(), statement.builderID())
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return errutil.Wrap("failed to read provenance", err)
+	}
+	return &ProvenanceError{Reason: reason}
+}
+
+// verifyProvenanceEnvelope verifies the signature of a DSSE envelope and returns the in-toto statement it
+// contains.
+func verifyProvenanceEnvelope(data []byte, pub crypto.PublicKey) (*provenanceStatement, error) {
+	var envelope dsseEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, errutil.Wrap("malformed attestation", err)
+	}
+	if envelope.PayloadType != inTotoPayloadType {
+		return nil, fmt.Errorf("unexpected payload type %q", envelope.PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, errutil.Wrap("malformed attestation payload", err)
+	}
+
+	pae := dssePAE(envelope.PayloadType, payload)
+	paeDigest := sha256.Sum256(pae)
+	verified := false
+	for _, s := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		if verifyBlobSignature(pub, pae, paeDigest[:], sig) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("attestation isn't signed with the provenance key")
+	}
+
+	var statement provenanceStatement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return nil, errutil.Wrap("malformed in-toto statement", err)
+	}
+	return &statement, nil
+}
+
+// dssePAE returns the DSSE pre-authentication encoding of the payload, which is what's signed.
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// checkProvenanceStatement checks that the statement is SLSA provenance for the archive digest, built by a trusted
+// builder from a trusted source repository.
+func checkProvenanceStatement(s *provenanceStatement, digest string, opts ProvenanceOpts) error {
+	if s.PredicateType != slsaProvenanceV02Type && s.PredicateType != slsaProvenanceV1Type {
+		return fmt.Errorf("unsupported predicate type %q", s.PredicateType)
+	}
+
+	matches := false
+	for _, subject := range s.Subject {
+		if strings.EqualFold(subject.Digest["sha256"], digest) {
+			matches = true
+			break
+		}
+	}
+	if !matches {
+		return fmt.Errorf("archive digest sha256:%s isn't attested", digest)
+	}
+
+	if len(opts.BuilderIDs) > 0 && !containsString(opts.BuilderIDs, s.builderID()) {
+		return fmt.Errorf("builder %q isn't trusted", s.builderID())
+	}
+	if len(opts.SourceRepos) > 0 {
+		source := normalizeSourceRepo(s.sourceRepo())
+		trusted := false
+		for _, repo := range opts.SourceRepos {
+			if normalizeSourceRepo(repo) == source {
+				trusted = true
+				break
+			}
+		}
+		if !trusted {
+			return fmt.Errorf("source repository %q isn't trusted", s.sourceRepo())
+		}
+	}
+	return nil
+}
+
+// normalizeSourceRepo strips the git+ prefix, scheme, ref and .git suffix of source repository URIs, so that
+// e.g. git+https://github.com/org/plugin@refs/tags/v1.0.0 matches github.com/org/plugin.
+func normalizeSourceRepo(uri string) string {
+	uri = strings.TrimPrefix(uri, "git+")
+	if idx := strings.Index(uri, "://"); idx >= 0 {
+		uri = uri[idx+3:]
+	}
+	if idx := strings.LastIndex(uri, "@"); idx > strings.Index(uri, "/") {
+		uri = uri[:idx]
+	}
+	uri = strings.TrimSuffix(strings.TrimSuffix(uri, "/"), ".git")
+	return strings.ToLower(uri)
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package installer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyProvenance(t *testing.T) {
+	const builderID = "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/" +
+		"generator_generic_slsa3.yml@refs/tags/v1.9.0"
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "provenance.pub")
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		0600))
+
+	archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+	data, err := ioutil.ReadFile(archive)
+	require.NoError(t, err)
+	digest := sha256.Sum256(data)
+
+	writeProvenance := func(t *testing.T, signer *ecdsa.PrivateKey, digest, source string) {
+		t.Helper()
+		statement := map[string]interface{}{
+			"_type":         "https://in-toto.io/Statement/v0.1",
+			"predicateType": slsaProvenanceV02Type,
+			"subject": []map[string]interface{}{
+				{"name": "plugin.tar.gz", "digest": map[string]string{"sha256": digest}},
+			},
+			"predicate": map[string]interface{}{
+				"builder":    map[string]string{"id": builderID},
+				"invocation": map[string]interface{}{"configSource": map[string]string{"uri": source}},
+			},
+		}
+		payload, err := json.Marshal(statement)
+		require.NoError(t, err)
+		paeDigest := sha256.Sum256(dssePAE(inTotoPayloadType, payload))
+		sig, err := ecdsa.SignASN1(rand.Reader, signer, paeDigest[:])
+		require.NoError(t, err)
+		envelope, err := json.Marshal(map[string]interface{}{
+			"payloadType": inTotoPayloadType,
+			"payload":     base64.StdEncoding.EncodeToString(payload),
+			"signatures":  []map[string]string{{"sig": base64.StdEncoding.EncodeToString(sig)}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(archive+provenanceSuffix, append(envelope, '\n'), 0600))
+	}
+
+	newInstaller := func() *Installer {
+		return &Installer{log: &fakeLogger{}, opts: Opts{Provenance: ProvenanceOpts{
+			KeyPath:     keyPath,
+			BuilderIDs:  []string{builderID},
+			SourceRepos: []string{"github.com/grafana/test-panel"},
+		}}}
+	}
+
+	t.Run("Should verify provenance", func(t *testing.T) {
+		writeProvenance(t, key, hex.EncodeToString(digest[:]),
+			"git+https://github.com/grafana/test-panel@refs/tags/v1.0.0")
+
+		require.NoError(t, newInstaller().verifyProvenance(archive, archive))
+	})
+
+	t.Run("Should reject provenance not matching requirements", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		other := sha256.Sum256([]byte("other"))
+
+		for reason, write := range map[string]func(){
+			"isn't signed": func() {
+				writeProvenance(t, otherKey, hex.EncodeToString(digest[:]), "git+https://github.com/grafana/test-panel")
+			},
+			"isn't attested": func() {
+				writeProvenance(t, key, hex.EncodeToString(other[:]), "git+https://github.com/grafana/test-panel")
+			},
+			"source repository": func() {
+				writeProvenance(t, key, hex.EncodeToString(digest[:]), "git+https://github.com/evil/test-panel")
+			},
+		} {
+			write()
+			var provErr *ProvenanceError
+			require.ErrorAs(t, newInstaller().verifyProvenance(archive, archive), &provErr)
+			assert.Contains(t, provErr.Reason, reason)
+		}
+
+		i := newInstaller()
+		i.opts.Provenance.BuilderIDs = []string{"https://example.com/builder"}
+		writeProvenance(t, key, hex.EncodeToString(digest[:]), "git+https://github.com/grafana/test-panel")
+		err = i.verifyProvenance(archive, archive)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "builder")
+	})
+
+	t.Run("Should normalize source repositories", func(t *testing.T) {
+		for _, uri := range []string{
+			"git+https://github.com/Grafana/test-panel@refs/heads/main",
+			"https://github.com/grafana/test-panel.git",
+			"github.com/grafana/test-panel/",
+		} {
+			assert.Equal(t, "github.com/grafana/test-panel", normalizeSourceRepo(uri), uri)
+		}
+		assert.True(t, strings.HasPrefix(normalizeSourceRepo("git@github.com:grafana/test-panel"), "git@"))
+	})
+}
@@ -0,0 +1,130 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ProxyCredentials authenticate requests to a proxy with the Proxy-Authorization header, both for plain HTTP
+// requests forwarded by the proxy and for the CONNECT requests tunneling TLS connections through it, including
+// over TLS to https:// proxies. A token is sent as bearer token, otherwise the username and password as basic
+// credentials. SOCKS5 proxies only support a username and password. Credentials in the proxy URL take precedence.
+type ProxyCredentials struct {
+	Token    string
+	Username string
+	Password string
+}
+
+func (c ProxyCredentials) empty() bool {
+	return c.Token == "" && c.Username == ""
+}
+
+// proxyCredentialsFor returns the credentials of the proxy, those of the repository proxies with the same host or
+// the default credentials otherwise.
+func proxyCredentialsFor(proxyURL *url.URL, proxies map[string]RepoProxy, defaults ProxyCredentials) ProxyCredentials {
+	for _, p := range proxies {
+		if p.Credentials.empty() || p.URL == "" || p.URL == directProxy {
+			continue
+		}
+		if u, err := parseProxyURL(p.URL, "http"); err == nil && u.Host == proxyURL.Host {
+			return p.Credentials
+		}
+	}
+	return defaults
+}
+
+// withProxyCredentials adds the basic credentials of the proxies to the URLs returned by the proxy function, which
+// the transport sends to HTTP proxies and in the handshake of SOCKS5 proxies. Bearer tokens are added by the
+// transport, see proxyTokenFunc.
+func withProxyCredentials(proxy func(*http.Request) (*url.URL, error), proxies map[string]RepoProxy,
+	defaults ProxyCredentials) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil || u.User != nil {
+			return u, err
+		}
+		creds := proxyCredentialsFor(u, proxies, defaults)
+		if creds.empty() {
+			return u, nil
+		}
+		if creds.Token != "" {
+			if u.Scheme == socksScheme {
+				return nil, fmt.Errorf("SOCKS5 proxy %s doesn't support token authentication", RedactURL(u.String()))
+			}
+			return u, nil
+		}
+		// The URL may be shared between requests, e.g. the cached proxy of the environment
+		withUser := *u
+		withUser.User = url.UserPassword(creds.Username, creds.Password)
+		return &withUser, nil
+	}
+}
+
+// proxyTokenFunc returns the bearer token of proxies without credentials in their URL, nil if no proxy has a token.
+func proxyTokenFunc(proxies map[string]RepoProxy, defaults ProxyCredentials) func(*url.URL) string {
+	hasToken := defaults.Token != ""
+	for _, p := range proxies {
+		hasToken = hasToken || p.Credentials.Token != ""
+	}
+	if !hasToken {
+		return nil
+	}
+	return func(proxyURL *url.URL) string {
+		if proxyURL.User != nil || proxyURL.Scheme == socksScheme {
+			return ""
+		}
+		return proxyCredentialsFor(proxyURL, proxies, defaults).Token
+	}
+}
+
+// addProxyToken sends the bearer tokens of the proxies with the CONNECT requests of the transport and returns a
+// round tripper adding them to plain HTTP requests forwarded by the proxies, as the transport only authenticates
+// those with the credentials of the proxy URL.
+func addProxyToken(tr *http.Transport, proxyToken func(*url.URL) string) http.RoundTripper {
+	if proxyToken == nil {
+		return tr
+	}
+	tr.GetProxyConnectHeader = func(_ context.Context, proxyURL *url.URL, _ string) (http.Header, error) {
+		if token := proxyToken(proxyURL); token != "" {
+			return http.Header{"Proxy-Authorization": []string{"Bearer " + token}}, nil
+		}
+		return nil, nil
+	}
+	return &proxyTokenTransport{Transport: tr, proxyToken: proxyToken}
+}
+
+// proxyTokenTransport adds the Proxy-Authorization header with the bearer token of the proxy to plain HTTP requests.
+// Requests to https:// URLs are tunneled, so the header would reach the server instead of the proxy.
+type proxyTokenTransport struct {
+	*http.Transport
+	proxyToken func(*url.URL) string
+}
+
+func (t *proxyTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" || t.Proxy == nil {
+		return t.Transport.RoundTrip(req)
+	}
+	proxyURL, err := t.Proxy(req)
+	if err != nil || proxyURL == nil {
+		return t.Transport.RoundTrip(req)
+	}
+	if token := t.proxyToken(proxyURL); token != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Proxy-Authorization", "Bearer "+token)
+	}
+	return t.Transport.RoundTrip(req)
+}
+
+// httpTransport returns the HTTP transport of the round tripper, nil if it isn't one.
+func httpTransport(rt http.RoundTripper) *http.Transport {
+	switch tr := rt.(type) {
+	case *http.Transport:
+		return tr
+	case *proxyTokenTransport:
+		return tr.Transport
+	default:
+		return nil
+	}
+}
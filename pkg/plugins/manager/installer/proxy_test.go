@@ -0,0 +1,322 @@
+package installer
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoProxies(t *testing.T) {
+	newRequest := func(t *testing.T, rawURL string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("Should send repository requests through its proxy", func(t *testing.T) {
+		var proxied []string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = append(proxied, r.URL.String())
+			_, _ = w.Write([]byte("proxied"))
+		}))
+		t.Cleanup(proxy.Close)
+
+		i := NewWithOpts(Opts{RepoProxies: map[string]RepoProxy{
+			"http://grafana.example/api/plugins": {URL: proxy.URL},
+		}}, "8.0.0", &fakeLogger{})
+		body, err := i.sendRequestGetBytes("http://grafana.example/api/plugins", "repo", "test-panel")
+		require.NoError(t, err)
+		assert.Equal(t, "proxied", string(body))
+		assert.Equal(t, []string{"http://grafana.example/api/plugins/repo/test-panel"}, proxied)
+	})
+
+	t.Run("Should use proxy of most specific repository", func(t *testing.T) {
+		proxy := proxyFunc(map[string]RepoProxy{
+			"https://grafana.com":                 {URL: "egress.corp:3128"},
+			"https://grafana.com/api/plugins/dev": {URL: "https://dev-proxy.corp"},
+			"https://mirror.corp/plugins":         {URL: "direct"},
+		}, "")
+
+		u, err := proxy(newRequest(t, "https://grafana.com/api/plugins/repo"))
+		require.NoError(t, err)
+		assert.Equal(t, "http://egress.corp:3128", u.String())
+
+		u, err = proxy(newRequest(t, "https://grafana.com/api/plugins/dev/repo"))
+		require.NoError(t, err)
+		assert.Equal(t, "https://dev-proxy.corp", u.String())
+
+		u, err = proxy(newRequest(t, "https://mirror.corp/plugins/repo"))
+		require.NoError(t, err)
+		assert.Nil(t, u)
+	})
+
+	t.Run("Should apply repository proxy to redirects", func(t *testing.T) {
+		proxy := proxyFunc(map[string]RepoProxy{
+			"https://grafana.com": {URL: "http://egress.corp:3128", NoProxy: []string{".storage.corp", "10.0.0.0/8"}},
+		}, "")
+
+		original := newRequest(t, "https://grafana.com/api/plugins/test-panel/versions/1.0.0/download")
+		for rawURL, proxied := range map[string]bool{
+			"https://storage.googleapis.com/plugins/test-panel.zip": true,
+			"https://eu.storage.corp/plugins/test-panel.zip":        false,
+			"https://storage.corp/plugins/test-panel.zip":           false,
+			"https://10.1.2.3/plugins/test-panel.zip":               false,
+		} {
+			redirect := newRequest(t, rawURL)
+			redirect.Response = &http.Response{Request: original}
+			u, err := proxy(redirect)
+			require.NoError(t, err)
+			assert.Equal(t, proxied, u != nil, rawURL)
+		}
+	})
+
+	t.Run("Should configure proxies of source aliases", func(t *testing.T) {
+		proxies := repoProxies(Opts{
+			RepoProxies: map[string]RepoProxy{"https://grafana.com": {URL: "http://egress.corp:3128"}},
+			SourceAliases: map[string]SourceAlias{
+				"corp":   {URL: "https://artifacts.corp", Proxy: "direct"},
+				"public": {URL: "https://plugins.example.com"},
+			},
+		})
+		assert.Equal(t, map[string]RepoProxy{
+			"https://grafana.com":    {URL: "http://egress.corp:3128"},
+			"https://artifacts.corp": {URL: "direct"},
+		}, proxies)
+	})
+
+	t.Run("Should match no proxy entries", func(t *testing.T) {
+		noProxy := []string{"mirror.corp", "*.internal", "192.168.1.10", "fd00::/8"}
+		for rawURL, matches := range map[string]bool{
+			"https://mirror.corp/plugins":       true,
+			"https://MIRROR.corp:8443/plugins":  true,
+			"https://other.mirror.corp/plugins": false,
+			"https://a.b.internal/plugins":      true,
+			"https://192.168.1.10/plugins":      true,
+			"https://192.168.1.11/plugins":      false,
+			"https://[fd00::1]/plugins":         true,
+			"https://grafana.com/api/plugins":   false,
+		} {
+			assert.Equal(t, matches, matchesNoProxy(newRequest(t, rawURL).URL, noProxy), rawURL)
+		}
+	})
+}
+
+func TestSOCKSProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("via socks " + r.Host))
+	}))
+	t.Cleanup(server.Close)
+	socks := newTestSOCKSServer(t, "grafana", "secret", map[string]string{
+		"plugins.example:80": server.Listener.Addr().String(),
+	})
+
+	t.Run("Should send requests through authenticated SOCKS5 proxy", func(t *testing.T) {
+		i := NewWithOpts(Opts{SOCKSProxy: "socks5://grafana:secret@" + socks.addr}, "8.0.0", &fakeLogger{})
+		body, err := i.sendRequestGetBytes("http://plugins.example/api/plugins", "repo", "test-panel")
+		require.NoError(t, err)
+		assert.Equal(t, "via socks plugins.example", string(body))
+		assert.Equal(t, []string{"plugins.example:80"}, socks.requested())
+	})
+
+	t.Run("Should fail for wrong SOCKS5 credentials", func(t *testing.T) {
+		i := NewWithOpts(Opts{SOCKSProxy: "socks5h://grafana:wrong@" + socks.addr}, "8.0.0", &fakeLogger{})
+		_, err := i.sendRequestGetBytes("http://plugins.example/api/plugins", "repo", "test-panel")
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "wrong")
+	})
+
+	t.Run("Should prefer repository proxy over SOCKS5 proxy", func(t *testing.T) {
+		proxy := proxyFunc(map[string]RepoProxy{"https://mirror.corp": {URL: "direct"}}, "bastion.corp:1080")
+
+		u, err := proxy(httptest.NewRequest(http.MethodGet, "https://mirror.corp/plugins", nil))
+		require.NoError(t, err)
+		assert.Nil(t, u)
+
+		u, err = proxy(httptest.NewRequest(http.MethodGet, "https://grafana.com/api/plugins", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "socks5://bastion.corp:1080", u.String())
+	})
+
+	t.Run("Should reject SOCKS proxy with another scheme", func(t *testing.T) {
+		_, err := proxyFunc(nil, "http://egress.corp:3128")(httptest.NewRequest(http.MethodGet,
+			"https://grafana.com/api/plugins", nil))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be a socks5:// URL")
+	})
+
+	t.Run("Should fall back to ALL_PROXY", func(t *testing.T) {
+		if os.Getenv("HTTP_PROXY") != "" || os.Getenv("HTTPS_PROXY") != "" {
+			t.Skip("HTTP_PROXY or HTTPS_PROXY take precedence over ALL_PROXY")
+		}
+		setTestEnv(t, "ALL_PROXY", "socks5h://bastion.corp:1080")
+		setTestEnv(t, "NO_PROXY", "mirror.corp")
+		proxy := proxyFunc(nil, "")
+
+		u, err := proxy(httptest.NewRequest(http.MethodGet, "https://grafana.com/api/plugins", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "socks5://bastion.corp:1080", u.String())
+
+		for _, rawURL := range []string{"https://mirror.corp/plugins", "http://127.0.0.1:3011/plugins"} {
+			u, err = proxy(httptest.NewRequest(http.MethodGet, rawURL, nil))
+			require.NoError(t, err)
+			assert.Nil(t, u, rawURL)
+		}
+	})
+}
+
+func setTestEnv(t *testing.T, name, value string) {
+	t.Helper()
+	previous, exists := os.LookupEnv(name)
+	require.NoError(t, os.Setenv(name, value))
+	t.Cleanup(func() {
+		if exists {
+			_ = os.Setenv(name, previous)
+		} else {
+			_ = os.Unsetenv(name)
+		}
+	})
+}
+
+// testSOCKSServer is a SOCKS5 proxy requiring username and password authentication, which connects to the
+// addresses it's asked for, or the ones they're mapped to.
+type testSOCKSServer struct {
+	addr string
+
+	mu        sync.Mutex
+	addresses []string
+}
+
+func newTestSOCKSServer(t *testing.T, username, password string, targets map[string]string) *testSOCKSServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	s := &testSOCKSServer{addr: l.Addr().String()}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, username, password, targets)
+		}
+	}()
+	return s
+}
+
+func (s *testSOCKSServer) requested() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addresses
+}
+
+func (s *testSOCKSServer) serve(conn net.Conn, username, password string, targets map[string]string) {
+	defer func() { _ = conn.Close() }()
+
+	// Greeting, only username and password authentication is offered
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte{5, 2}); err != nil {
+		return
+	}
+
+	// Username and password authentication, see RFC 1929
+	user, err := readSOCKSAuthField(conn, true)
+	if err != nil {
+		return
+	}
+	pass, err := readSOCKSAuthField(conn, false)
+	if err != nil {
+		return
+	}
+	if user != username || pass != password {
+		_, _ = conn.Write([]byte{1, 1})
+		return
+	}
+	if _, err := conn.Write([]byte{1, 0}); err != nil {
+		return
+	}
+
+	// CONNECT request
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make([]byte, map[byte]int{1: net.IPv4len, 4: net.IPv6len}[request[3]])
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case 3:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	s.mu.Lock()
+	s.addresses = append(s.addresses, addr)
+	s.mu.Unlock()
+	if target, exists := targets[addr]; exists {
+		addr = target
+	}
+
+	upstream, err := net.Dial("tcp", addr)
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer func() { _ = upstream.Close() }()
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+// readSOCKSAuthField reads the username, preceded by the version of the subnegotiation, or the password.
+func readSOCKSAuthField(conn net.Conn, withVersion bool) (string, error) {
+	if withVersion {
+		if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+			return "", err
+		}
+	}
+	length := make([]byte, 1)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return "", err
+	}
+	field := make([]byte, length[0])
+	if _, err := io.ReadFull(conn, field); err != nil {
+		return "", err
+	}
+	return string(field), nil
+}
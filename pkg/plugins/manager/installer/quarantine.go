@@ -0,0 +1,245 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// quarantineDir returns the quarantine directory of the plugins directory, which plugins are extracted to and
+// verified in before being moved into the plugins directory.
+func quarantineDir(pluginsDir string) string {
+	return filepath.Join(pluginsDir, plugins.QuarantineDirName)
+}
+
+// newStagingDir creates a directory in the quarantine directory to extract a plugin to.
+func newStagingDir(pluginsDir string) (string, error) {
+	dir, err := createQuarantineDir(pluginsDir)
+	if err != nil {
+		return "", err
+	}
+	return ioutil.TempDir(dir, ".staging-")
+}
+
+// createQuarantineDir creates the quarantine directory of the plugins directory if it doesn't exist yet.
+func createQuarantineDir(pluginsDir string) (string, error) {
+	dir := quarantineDir(pluginsDir)
+	// nolint:gosec
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		if os.IsPermission(err) {
+			return "", fmt.Errorf(permissionsDeniedMessage, dir)
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+// tempDir returns the directory to create temporary files for installs into the plugins directory in, which is
+// Opts.TempDir if set. Otherwise it's the quarantine directory, so that downloaded archives are on the same
+// filesystem as the plugins, or the system temporary directory if there's no plugins directory.
+func (i *Installer) tempDir(pluginsDir string) (string, error) {
+	if i.opts.TempDir != "" {
+		// nolint:gosec
+		if err := os.MkdirAll(i.opts.TempDir, 0755); err != nil {
+			return "", errutil.Wrap("failed to create temporary directory", err)
+		}
+		return i.opts.TempDir, nil
+	}
+	if pluginsDir == "" {
+		return "", nil
+	}
+	return createQuarantineDir(pluginsDir)
+}
+
+// movePlugin moves the plugin from one plugins directory to another on the same file system. An existing
+// installation is only removed once the plugin is in place, and restored if moving the plugin fails.
+func movePlugin(fromDir, toDir, pluginID string) error {
+	backup, err := replacePlugin(fromDir, toDir, pluginID)
+	if backup != "" {
+		_ = os.RemoveAll(filepath.Dir(backup))
+	}
+	return err
+}
+
+// replacePlugin moves the plugin like movePlugin, but keeps the existing installation it replaced in a directory
+// in fromDir, whose path it returns, so that it can be restored with restorePlugin.
+func replacePlugin(fromDir, toDir, pluginID string) (string, error) {
+	dst := filepath.Join(toDir, pluginID)
+	backup := ""
+	if _, err := os.Lstat(dst); err == nil {
+		backupDir, err := ioutil.TempDir(fromDir, ".previous-")
+		if err != nil {
+			return "", errutil.Wrapf(err, "failed to back up existing installation of %s", pluginID)
+		}
+		backup = filepath.Join(backupDir, pluginID)
+		if err := os.Rename(dst, backup); err != nil {
+			_ = os.RemoveAll(backupDir)
+			return "", errutil.Wrapf(err, "failed to back up existing installation of %s", pluginID)
+		}
+	}
+
+	if err := os.Rename(filepath.Join(fromDir, pluginID), dst); err != nil {
+		if backup != "" {
+			defer func() {
+				_ = os.RemoveAll(filepath.Dir(backup))
+			}()
+			if restoreErr := os.Rename(backup, dst); restoreErr != nil {
+				return "", fmt.Errorf("failed to move %s into %s: %w, and failed to restore existing installation: %s",
+					pluginID, toDir, err, restoreErr)
+			}
+		}
+		return "", errutil.Wrapf(err, "failed to move %s into %s", pluginID, toDir)
+	}
+	return backup, nil
+}
+
+// restorePlugin removes the plugin from the plugins directory and restores the installation it replaced, if any.
+func restorePlugin(toDir, pluginID, backup string) error {
+	dst := filepath.Join(toDir, pluginID)
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if backup == "" {
+		return nil
+	}
+	return os.Rename(backup, dst)
+}
+
+// quarantineRecord holds what a quarantined plugin is locked and recorded as a dependency with once it's approved,
+// since only the extracted plugin is kept.
+type quarantineRecord struct {
+	Version    string `json:"version"`
+	Source     string `json:"source"`
+	Checksum   string `json:"checksum"`
+	RequiredBy string `json:"requiredBy,omitempty"`
+}
+
+// quarantineRecordPath returns the path of the quarantine record of the plugin, which is hidden from
+// QuarantinedPlugins.
+func quarantineRecordPath(pluginsDir, pluginID string) string {
+	return filepath.Join(quarantineDir(pluginsDir), "."+pluginID+".json")
+}
+
+// writeQuarantineRecord records the planned plugin moved into the quarantine directory.
+func writeQuarantineRecord(pluginsDir string, p *pendingInstall) error {
+	sum, err := p.archiveSum()
+	if err != nil {
+		return errutil.Wrap("failed to compute checksum of plugin archive", err)
+	}
+	version := p.version
+	if p.res.Info.Version != "" {
+		version = p.res.Info.Version
+	}
+	data, err := json.Marshal(quarantineRecord{Version: version, Source: withoutURLCredentials(p.pluginZipURL),
+		Checksum: sum, RequiredBy: p.requirement.RequiredBy})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(quarantineRecordPath(pluginsDir, p.pluginID), data, 0600)
+}
+
+// readQuarantineRecord returns the quarantine record of the plugin, or nil for plugins quarantined without one.
+func readQuarantineRecord(pluginsDir, pluginID string) (*quarantineRecord, error) {
+	// nolint:gosec
+	data, err := ioutil.ReadFile(quarantineRecordPath(pluginsDir, pluginID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var record quarantineRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, errutil.Wrapf(err, "invalid quarantine record of plugin %s", pluginID)
+	}
+	return &record, nil
+}
+
+// QuarantinedPlugins returns the IDs of the plugins awaiting approval in the plugins directory.
+func QuarantinedPlugins(pluginsDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(quarantineDir(pluginsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() && e.Name()[0] != '.' {
+			ids = append(ids, e.Name())
+		}
+	}
+	return ids, nil
+}
+
+// Approve verifies a plugin that was installed in quarantine-only mode once more and moves it into the plugins
+// directory.
+func (i *Installer) Approve(pluginID, pluginsDir string) (err error) {
+	event := i.newAuditEvent(AuditOperationApprove, pluginID, pluginsDir)
+	defer func() {
+		i.audit(event, err)
+	}()
+
+	if err := i.checkFIPS(); err != nil {
+		return err
+	}
+	dir := quarantineDir(pluginsDir)
+	if _, err := os.Stat(filepath.Join(dir, pluginID)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("plugin %s is not awaiting approval", pluginID)
+		}
+		return err
+	}
+
+	if err := i.checkPluginAllowed(pluginID); err != nil {
+		return err
+	}
+	if err := i.validatePlugin(dir, pluginID); err != nil {
+		return err
+	}
+	res, err := toPluginDTO(dir, pluginID)
+	if err != nil {
+		return err
+	}
+	event.Version = res.Info.Version
+	// Where the plugin was downloaded from isn't recorded, so only a configured advisory URL applies
+	if err := i.checkAdvisories(pluginID, res.Info.Version, ""); err != nil {
+		return err
+	}
+
+	record, err := readQuarantineRecord(pluginsDir, pluginID)
+	if err != nil {
+		return err
+	}
+	operation := AuditOperationInstall
+	if _, err := os.Stat(filepath.Join(pluginsDir, pluginID)); err == nil {
+		operation = AuditOperationUpdate
+	}
+
+	if err := movePlugin(dir, pluginsDir, pluginID); err != nil {
+		return err
+	}
+	i.log.Successf("Approved %s v%s", res.ID, res.Info.Version)
+	if record == nil {
+		i.log.Warnf("Plugin %s was quarantined without a record of its source, so it isn't locked", pluginID)
+		return nil
+	}
+	// The approved plugin is only now installed, so it's only now locked and recorded as a dependency
+	p := &pendingInstall{pluginID: pluginID, version: record.Version, pluginZipURL: record.Source,
+		archiveSHA256: record.Checksum, requirement: DependencyRequirement{RequiredBy: record.RequiredBy},
+		event: &AuditEvent{Operation: operation}}
+	i.recordDependency(p, pluginsDir)
+	if err := i.lockPlugin(p, res); err != nil {
+		return err
+	}
+	if err := os.Remove(quarantineRecordPath(pluginsDir, pluginID)); err != nil {
+		i.log.Warn("Failed to remove quarantine record", "plugin", pluginID, "err", err)
+	}
+	return nil
+}
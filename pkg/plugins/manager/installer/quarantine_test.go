@@ -0,0 +1,99 @@
+package installer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantine(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+
+	t.Run("Should keep existing installation when verification fails", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}}
+		require.NoError(t, i.Install("test-panel", "", pluginsDir,
+			writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}), ""))
+
+		err := i.Install("test-panel", "", pluginsDir,
+			writeTestTarGz(t, nil, map[string]string{"plugin.json": `{"id":"test-panel","type":"widget"}`}), "")
+		var jsonErr *InvalidPluginJSONError
+		require.ErrorAs(t, err, &jsonErr)
+
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		require.NoError(t, err)
+		assert.Equal(t, pluginJSON, string(data))
+		entries, err := ioutil.ReadDir(filepath.Join(pluginsDir, plugins.QuarantineDirName))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Should install quarantined plugin once approved", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, QuarantineOnly: true}}
+		require.NoError(t, i.Install("test-panel", "", pluginsDir,
+			writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}), ""))
+
+		assert.NoFileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		quarantined, err := QuarantinedPlugins(pluginsDir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"test-panel"}, quarantined)
+
+		require.NoError(t, i.Approve("test-panel", pluginsDir))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		quarantined, err = QuarantinedPlugins(pluginsDir)
+		require.NoError(t, err)
+		assert.Empty(t, quarantined)
+
+		require.Error(t, i.Approve("test-panel", pluginsDir))
+	})
+	t.Run("Should only lock quarantined plugin once approved", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		lockfilePath := filepath.Join(t.TempDir(), "grafana-plugins.lock")
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, QuarantineOnly: true,
+			LockfilePath: lockfilePath}}
+		require.NoError(t, i.Install("test-panel", "", pluginsDir,
+			writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}), ""))
+
+		assert.NoFileExists(t, lockfilePath)
+		assert.NoFileExists(t, filepath.Join(pluginsDir, dependenciesFile))
+
+		require.NoError(t, i.Approve("test-panel", pluginsDir))
+		l, err := ReadLockfile(lockfilePath)
+		require.NoError(t, err)
+		locked, ok := l.plugin("test-panel")
+		require.True(t, ok)
+		assert.Equal(t, "1.0.0", locked.Version)
+		assert.NoFileExists(t, quarantineRecordPath(pluginsDir, "test-panel"))
+	})
+
+	t.Run("Should download archives to the quarantine directory of the plugins directory", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		i := &Installer{log: &fakeLogger{}}
+
+		dir, err := i.tempDir(pluginsDir)
+		require.NoError(t, err)
+		assert.Equal(t, quarantineDir(pluginsDir), dir)
+		assert.DirExists(t, dir)
+
+		dir, err = i.tempDir("")
+		require.NoError(t, err)
+		assert.Empty(t, dir)
+	})
+
+	t.Run("Should download archives to the configured temporary directory", func(t *testing.T) {
+		tmpDir := filepath.Join(t.TempDir(), "downloads")
+		i := &Installer{log: &fakeLogger{}, opts: Opts{TempDir: tmpDir}}
+
+		for _, pluginsDir := range []string{t.TempDir(), ""} {
+			dir, err := i.tempDir(pluginsDir)
+			require.NoError(t, err)
+			assert.Equal(t, tmpDir, dir)
+			assert.DirExists(t, dir)
+		}
+	})
+}
@@ -0,0 +1,15 @@
+package installer
+
+import (
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
+// RedactURL returns the URL with its credentials replaced, see repo.RedactURL.
+func RedactURL(rawURL string) string {
+	return repo.RedactURL(rawURL)
+}
+
+// RedactURLError redacts the URL of errors returned by HTTP clients, see repo.RedactURLError.
+func RedactURLError(err error) error {
+	return repo.RedactURLError(err)
+}
@@ -0,0 +1,100 @@
+package installer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxRedirects is the number of redirects a download follows, like the default of http.Client.
+const maxRedirects = 10
+
+// defaultRedirectHosts are the hosts of the plugin repository and of the CDNs and code hosts plugin archives are
+// commonly served from, which downloads may be redirected to.
+var defaultRedirectHosts = []string{
+	"grafana.com",
+	"*.grafana.com",
+	"storage.googleapis.com",
+	"github.com",
+	"objects.githubusercontent.com",
+	"codeload.github.com",
+	"gitlab.com",
+}
+
+// RedirectNotAllowedError is returned when a download is redirected to a host that isn't allowed, e.g. because a
+// compromised repository response redirects the installer to a server serving a malicious archive.
+type RedirectNotAllowedError struct {
+	// From and To are the redacted URLs of the redirect.
+	From string
+	To   string
+	// Reason is why the redirect isn't allowed.
+	Reason string
+}
+
+func (e *RedirectNotAllowedError) Error() string {
+	return fmt.Sprintf("refusing to follow redirect from %s to %s: %s", e.From, e.To, e.Reason)
+}
+
+// checkRedirect is the redirect policy of the download clients. Downloads are only redirected to the host they
+// were requested from, the default plugin repository and CDN hosts, the hosts of configured mirrors, source
+// aliases, repositories and allowed sources, and the configured redirect hosts, which may be * to allow any host.
+// Redirects from HTTPS to plain HTTP are refused unless insecure HTTP is allowed.
+func (i *Installer) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	from := via[len(via)-1].URL
+	if strings.EqualFold(from.Scheme, "https") && strings.EqualFold(req.URL.Scheme, "http") &&
+		!i.opts.AllowInsecureHTTP {
+		return &RedirectNotAllowedError{From: from.Redacted(), To: req.URL.Redacted(),
+			Reason: "downgrade from HTTPS to plain HTTP"}
+	}
+	allowed, err := i.redirectAllowed(req.URL, via[0].URL)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &RedirectNotAllowedError{From: from.Redacted(), To: req.URL.Redacted(),
+			Reason: "the host is not an allowed redirect host"}
+	}
+	return nil
+}
+
+// redirectAllowed reports whether a download requested from the original URL may be redirected to the URL.
+func (i *Installer) redirectAllowed(u, original *url.URL) (bool, error) {
+	if strings.EqualFold(u.Hostname(), original.Hostname()) {
+		return true, nil
+	}
+	sources, err := i.allowedSources()
+	if err != nil {
+		return false, err
+	}
+	hosts := append(append(append([]string{}, defaultRedirectHosts...), i.opts.RedirectHosts...), sources...)
+	for _, repoURL := range i.trustedRepoURLs() {
+		if ru, err := url.Parse(repoURL); err == nil && ru.Host != "" {
+			hosts = append(hosts, ru.Hostname())
+		}
+	}
+	for _, host := range hosts {
+		if host == "*" || sourceMatches(host, u) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// trustedRepoURLs returns the URLs of the configured plugin repositories, mirrors and source aliases.
+func (i *Installer) trustedRepoURLs() []string {
+	urls := append([]string{i.opts.GitLabURL}, i.opts.RepoMirrors...)
+	for _, alias := range i.opts.SourceAliases {
+		urls = append(urls, alias.URL)
+	}
+	for repoURL := range i.opts.RepoCredentials {
+		urls = append(urls, repoURL)
+	}
+	for repoURL := range i.opts.RepoProxies {
+		urls = append(urls, repoURL)
+	}
+	return urls
+}
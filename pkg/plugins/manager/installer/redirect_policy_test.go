@@ -0,0 +1,93 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	archive := []byte("plugin archive")
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	t.Cleanup(cdn.Close)
+	// The CDN is addressed by another host name than the repository, which listens on 127.0.0.1 as well
+	cdnURL := strings.Replace(cdn.URL, "127.0.0.1", "localhost", 1)
+	newRepo := func(t *testing.T, tls bool, location string) *httptest.Server {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/archive" {
+				_, _ = w.Write(archive)
+				return
+			}
+			http.Redirect(w, r, location, http.StatusFound)
+		})
+		server := httptest.NewUnstartedServer(handler)
+		if tls {
+			server.StartTLS()
+		} else {
+			server.Start()
+		}
+		t.Cleanup(server.Close)
+		return server
+	}
+	download := func(t *testing.T, opts Opts, url string) error {
+		t.Helper()
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tmpFile.Close()) }()
+		if err := NewWithOpts(opts, "8.0.0", &fakeLogger{}).DownloadFile("test-panel", tmpFile, url, ""); err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(tmpFile.Name())
+		require.NoError(t, err)
+		assert.Equal(t, archive, data)
+		return nil
+	}
+
+	t.Run("Should refuse redirects to hosts that aren't allowed", func(t *testing.T) {
+		repo := newRepo(t, false, cdnURL+"/plugin.zip")
+
+		err := download(t, Opts{}, repo.URL+"/download")
+		var redirectErr *RedirectNotAllowedError
+		require.ErrorAs(t, err, &redirectErr)
+		assert.Equal(t, repo.URL+"/download", redirectErr.From)
+		assert.Equal(t, cdnURL+"/plugin.zip", redirectErr.To)
+		assert.Contains(t, err.Error(), "not an allowed redirect host")
+	})
+
+	t.Run("Should follow redirects to allowed hosts", func(t *testing.T) {
+		repo := newRepo(t, false, cdnURL+"/plugin.zip")
+
+		for _, opts := range []Opts{
+			{RedirectHosts: []string{"localhost"}},
+			{RedirectHosts: []string{"*"}},
+			{RepoMirrors: []string{cdnURL}},
+			{AllowedSources: []string{cdnURL + "/"}},
+		} {
+			require.NoError(t, download(t, opts, repo.URL+"/download"))
+		}
+	})
+
+	t.Run("Should follow redirects on the same host", func(t *testing.T) {
+		repo := newRepo(t, false, "/archive")
+
+		require.NoError(t, download(t, Opts{}, repo.URL+"/download"))
+	})
+
+	t.Run("Should refuse redirects from HTTPS to plain HTTP", func(t *testing.T) {
+		repo := newRepo(t, true, cdn.URL+"/plugin.zip")
+
+		err := download(t, Opts{SkipTLSVerify: true}, repo.URL+"/download")
+		var redirectErr *RedirectNotAllowedError
+		require.ErrorAs(t, err, &redirectErr)
+		assert.Contains(t, err.Error(), "downgrade from HTTPS to plain HTTP")
+
+		require.NoError(t, download(t, Opts{SkipTLSVerify: true, AllowInsecureHTTP: true}, repo.URL+"/download"))
+	})
+}
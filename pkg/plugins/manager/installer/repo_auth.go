@@ -0,0 +1,124 @@
+package installer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// RepoCredentials authenticate the requests to a plugin repository. Only the first configured kind of credentials
+// is used, in the order token, username and password, header and credential helper.
+type RepoCredentials struct {
+	// Token is sent as bearer token.
+	Token    string
+	Username string
+	Password string
+	// Header is an arbitrary header sent as "Name: value", e.g. the API key header of an Artifactory instance or
+	// the session header of an SSO gateway.
+	Header string
+	// CredentialHelper is a command printing the credentials of the repository whose URL it reads from stdin, as
+	// {"token": "...", "username": "...", "password": "...", "header": "..."}. It's passed "get" as last argument
+	// and run at most once per repository.
+	CredentialHelper string
+}
+
+// helperOutput is the output of a credential helper.
+type helperOutput struct {
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Header   string `json:"header"`
+}
+
+// repoCredentialsFor returns the credentials of the most specific repository the URL belongs to and the
+// repository's URL.
+func (i *Installer) repoCredentialsFor(u *url.URL) (RepoCredentials, string, bool) {
+	var match string
+	for repoURL := range i.opts.RepoCredentials {
+		if isUnderURL(u, repoURL) && len(repoURL) > len(match) {
+			match = repoURL
+		}
+	}
+	if match == "" {
+		return RepoCredentials{}, "", false
+	}
+	return i.opts.RepoCredentials[match], match, true
+}
+
+// setRepoAuth adds the credentials of the repository at repoURL to the request, running the credential helper if
+// no other credentials are configured.
+func (i *Installer) setRepoAuth(req *http.Request, repoURL string, creds RepoCredentials) error {
+	if creds.Token == "" && creds.Username == "" && creds.Header == "" && creds.CredentialHelper != "" {
+		helperCreds, err := i.runCredentialHelper(creds.CredentialHelper, repoURL)
+		if err != nil {
+			return err
+		}
+		creds = helperCreds
+	}
+
+	switch {
+	case creds.Token != "":
+		req.Header.Set("Authorization", "Bearer "+creds.Token)
+	case creds.Username != "":
+		req.SetBasicAuth(creds.Username, creds.Password)
+	case creds.Header != "":
+		name, value, err := parseHeader(creds.Header)
+		if err != nil {
+			return errutil.Wrapf(err, "invalid header of plugin repository %s", RedactURL(repoURL))
+		}
+		req.Header.Set(name, value)
+	}
+	return nil
+}
+
+// runCredentialHelper returns the credentials the helper prints for the repository. Its result is cached, so
+// that the helper isn't run for every request.
+func (i *Installer) runCredentialHelper(helper, repoURL string) (RepoCredentials, error) {
+	key := helper + "\x00" + repoURL
+	if creds, exists := i.helperCredentials.Load(key); exists {
+		return creds.(RepoCredentials), nil
+	}
+
+	args := strings.Fields(helper)
+	if len(args) == 0 {
+		return RepoCredentials{}, fmt.Errorf("empty credential helper for plugin repository %s", RedactURL(repoURL))
+	}
+	// nolint:gosec
+	cmd := exec.Command(args[0], append(args[1:], "get")...)
+	cmd.Stdin = strings.NewReader(repoURL + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return RepoCredentials{}, fmt.Errorf("credential helper %q failed for plugin repository %s: %w: %s",
+			args[0], RedactURL(repoURL), err, strings.TrimSpace(stderr.String()))
+	}
+
+	var output helperOutput
+	if err := json.Unmarshal(out, &output); err != nil {
+		return RepoCredentials{}, fmt.Errorf("credential helper %q returned invalid credentials: %w", args[0], err)
+	}
+	creds := RepoCredentials{Token: output.Token, Username: output.Username, Password: output.Password,
+		Header: output.Header}
+	i.helperCredentials.Store(key, creds)
+	return creds, nil
+}
+
+// parseHeader splits a "Name: value" header.
+func parseHeader(header string) (string, string, error) {
+	idx := strings.Index(header, ":")
+	if idx <= 0 {
+		return "", "", fmt.Errorf("expected Name: value")
+	}
+	name := strings.TrimSpace(header[:idx])
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid header name %q", name)
+	}
+	return name, strings.TrimSpace(header[idx+1:]), nil
+}
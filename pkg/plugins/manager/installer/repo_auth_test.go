@@ -0,0 +1,96 @@
+package installer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoCredentials(t *testing.T) {
+	t.Run("Should authenticate requests with credentials of most specific repository", func(t *testing.T) {
+		i := NewWithOpts(Opts{RepoCredentials: map[string]RepoCredentials{
+			"https://artifacts.corp/api/plugins":      {Username: "user", Password: "pass"},
+			"https://artifacts.corp/api/plugins/team": {Header: "X-JFrog-Art-Api: secret"},
+			"https://sso.corp/plugins":                {Token: "token"},
+		}}, "8.0.0", &fakeLogger{})
+
+		req, err := i.createRequest("https://artifacts.corp/api/plugins", "repo", "test-panel")
+		require.NoError(t, err)
+		user, pass, ok := req.BasicAuth()
+		require.True(t, ok)
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "pass", pass)
+
+		req, err = i.createRequest("https://artifacts.corp/api/plugins/team/test-panel/versions/1.0.0/download")
+		require.NoError(t, err)
+		assert.Equal(t, "secret", req.Header.Get("X-JFrog-Art-Api"))
+		assert.Empty(t, req.Header.Get("Authorization"))
+
+		req, err = i.createRequest("https://sso.corp/plugins", "repo")
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+
+		req, err = i.createRequest("https://grafana.com/api/plugins", "repo")
+		require.NoError(t, err)
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+
+	t.Run("Should refuse invalid header", func(t *testing.T) {
+		i := NewWithOpts(Opts{RepoCredentials: map[string]RepoCredentials{
+			"https://artifacts.corp": {Header: "no value"},
+		}}, "8.0.0", &fakeLogger{})
+
+		_, err := i.createRequest("https://artifacts.corp/repo")
+		require.Error(t, err)
+	})
+
+	t.Run("Should use credentials printed by credential helper", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("credential helper is a shell script")
+		}
+		dir := t.TempDir()
+		helper := filepath.Join(dir, "helper.sh")
+		calls := filepath.Join(dir, "calls")
+		require.NoError(t, ioutil.WriteFile(helper, []byte(`#!/bin/sh
+[ "$1" = "get" ] || exit 1
+read url
+echo "$url" >> `+calls+`
+echo '{"username": "helper", "password": "secret"}'
+`), 0700))
+
+		i := NewWithOpts(Opts{SourceAliases: map[string]SourceAlias{
+			"corp": {URL: "https://artifacts.corp/plugins", CredentialHelper: helper},
+		}}, "8.0.0", &fakeLogger{})
+
+		for n := 0; n < 2; n++ {
+			req, err := i.createRequest("https://artifacts.corp/plugins", "repo", "test-panel")
+			require.NoError(t, err)
+			user, pass, ok := req.BasicAuth()
+			require.True(t, ok)
+			assert.Equal(t, "helper", user)
+			assert.Equal(t, "secret", pass)
+		}
+		data, err := ioutil.ReadFile(calls)
+		require.NoError(t, err)
+		assert.Equal(t, "https://artifacts.corp/plugins\n", string(data), "helper should only run once")
+	})
+
+	t.Run("Should fail when credential helper fails", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("credential helper is a shell script")
+		}
+		helper := filepath.Join(t.TempDir(), "helper.sh")
+		require.NoError(t, ioutil.WriteFile(helper, []byte("#!/bin/sh\necho 'not logged in' >&2\nexit 1\n"), 0700))
+		i := NewWithOpts(Opts{RepoCredentials: map[string]RepoCredentials{
+			"https://artifacts.corp": {CredentialHelper: helper},
+		}}, "8.0.0", &fakeLogger{})
+
+		_, err := i.createRequest("https://artifacts.corp/repo")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not logged in")
+	})
+}
@@ -0,0 +1,86 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
+var _ repo.Client = &repoClient{}
+
+// repoClient is the repo.Client of a plugin repository, reading its metadata with the credentials, mirrors, source
+// aliases, caching and verification configured for the installer.
+type repoClient struct {
+	i             *Installer
+	pluginRepoURL string
+}
+
+// RepoClient returns a client of the plugin repository, so that everything reading plugin metadata shares the
+// configuration of the installer.
+func (i *Installer) RepoClient(pluginRepoURL string) repo.Client {
+	return &repoClient{i: i, pluginRepoURL: pluginRepoURL}
+}
+
+func (c *repoClient) GetPlugin(pluginID string) (repo.Plugin, error) {
+	return c.i.GetPlugin(pluginID, c.pluginRepoURL)
+}
+
+func (c *repoClient) ListPlugins() ([]repo.Plugin, error) {
+	return c.i.ListPlugins(c.pluginRepoURL)
+}
+
+func (c *repoClient) ListVersions(pluginID string) ([]repo.VersionInfo, error) {
+	return c.i.ListVersions(pluginID, c.pluginRepoURL)
+}
+
+func (c *repoClient) Search(query string, filters repo.SearchFilters) ([]repo.SearchResult, error) {
+	return c.i.Search(query, filters, c.pluginRepoURL)
+}
+
+func (c *repoClient) LatestVersions(pluginIDs []string) (map[string]string, error) {
+	return c.i.LatestVersions(pluginIDs, c.pluginRepoURL)
+}
+
+func (c *repoClient) DownloadURL(pluginID string, v *repo.Version, platform string) string {
+	return repo.DownloadURL(c.pluginRepoURL, pluginID, v, platform)
+}
+
+// metadataClient returns the repo.HTTPClient of a single plugin repository, fetching its metadata with the
+// installer's HTTP client.
+func (i *Installer) metadataClient(pluginRepoURL string) *repo.HTTPClient {
+	apiVersion := i.opts.RepoAPIVersion
+	if i.opts.Enterprise {
+		apiVersion = RepoAPILegacy
+	}
+	return repo.NewClient(pluginRepoURL, repo.ClientOpts{
+		Fetcher:         &repoFetcher{i: i},
+		GrafanaVersion:  i.targetGrafanaVersion(),
+		Platform:        osAndArchString(),
+		Channel:         i.opts.Channel,
+		AllowPrerelease: i.opts.AllowPrerelease,
+		APIVersion:      apiVersion,
+		Logger:          i.log,
+	})
+}
+
+// repoFetcher is the repo.Fetcher of the installer, fetching plugin repository metadata with the credentials,
+// metadata cache and TUF verification configured for it.
+type repoFetcher struct {
+	i *Installer
+}
+
+func (f *repoFetcher) Fetch(URL string, subPaths ...string) ([]byte, error) {
+	if _, err := os.Stat(URL); err == nil && len(subPaths) == 0 {
+		// nolint:gosec
+		body, err := ioutil.ReadFile(URL)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.i.verifyTUFTarget(URL, body); err != nil {
+			return nil, err
+		}
+		return body, nil
+	}
+	return f.i.sendCachedRequestGetBytes(URL, subPaths...)
+}
@@ -0,0 +1,66 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoClient(t *testing.T) {
+	t.Run("Should read plugin metadata from plugin repository", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/plugins/repo/test-panel", r.URL.Path)
+			_, _ = w.Write([]byte(`{"id": "test-panel", "versions": [{"version": "2.0.0"}, {"version": "1.0.0"}]}`))
+		}))
+		t.Cleanup(server.Close)
+		c := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).RepoClient(server.URL + "/api/plugins")
+
+		plugin, err := c.GetPlugin("test-panel")
+		require.NoError(t, err)
+		assert.Len(t, plugin.Versions, 2)
+
+		versions, err := c.ListVersions("test-panel")
+		require.NoError(t, err)
+		assert.Equal(t, "2.0.0", versions[0].Version)
+
+		assert.Equal(t, server.URL+"/api/plugins/test-panel/versions/2.0.0/download",
+			c.DownloadURL("test-panel", &plugin.Versions[0], "linux-amd64"))
+	})
+
+	t.Run("Should check latest versions with plugins API", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/plugins/versioncheck", r.URL.Path)
+			assert.Equal(t, "test-panel,other-panel", r.URL.Query().Get("slugIn"))
+			assert.Equal(t, "9.0.0", r.URL.Query().Get("grafanaVersion"))
+			_, _ = w.Write([]byte(`[{"slug": "test-panel", "version": "2.0.0"}, {"slug": "unknown", "version": "1.0.0"}]`))
+		}))
+		t.Cleanup(server.Close)
+		c := NewWithOpts(Opts{GrafanaVersion: "9.0.0"}, "8.0.0", &fakeLogger{}).RepoClient(server.URL + "/api/plugins")
+
+		latest, err := c.LatestVersions([]string{"test-panel", "other-panel"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"test-panel": "2.0.0"}, latest)
+	})
+
+	t.Run("Should check latest compatible versions in static repository", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, repo.StaticIndexFile), []byte(`{"plugins": [
+			{"id": "test-panel", "versions": [
+				{"version": "3.0.0", "grafanaDependency": ">=9.0.0"},
+				{"version": "2.0.0", "grafanaDependency": ">=8.0.0"}
+			]},
+			{"id": "other-panel", "versions": [{"version": "1.0.0"}]}
+		]}`), 0600))
+		c := NewWithOpts(Opts{}, "8.1.0", &fakeLogger{}).RepoClient(dir)
+
+		latest, err := c.LatestVersions([]string{"test-panel"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"test-panel": "2.0.0"}, latest)
+	})
+}
@@ -0,0 +1,9 @@
+package installer
+
+import (
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
+// InvalidRepoResponseError is returned when the plugin repository responds with metadata that doesn't match the
+// expected schema, see repo.InvalidRepoResponseError.
+type InvalidRepoResponseError = repo.InvalidRepoResponseError
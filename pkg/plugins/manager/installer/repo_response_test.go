@@ -0,0 +1,79 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoResponseValidation(t *testing.T) {
+	newInstaller := func() *Installer {
+		return NewWithOpts(Opts{RepoAPIVersion: RepoAPILegacy}, "8.0.0", &fakeLogger{})
+	}
+	newServer := func(t *testing.T, body string) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+		return server.URL + "/api/plugins"
+	}
+
+	t.Run("Should name missing and invalid fields and the URL", func(t *testing.T) {
+		repoURL := newServer(t, `{"id": "test-panel", "versions": [{"version": "1.0.0",
+			"arch": {"linux_amd64": {"sha256": "abc"}}}, {"url": "https://example.com"}]}`)
+
+		_, err := newInstaller().GetPlugin("test-panel", repoURL)
+		var invalidErr *InvalidRepoResponseError
+		require.ErrorAs(t, err, &invalidErr)
+		assert.Equal(t, repoURL+"/repo/test-panel", invalidErr.URL)
+		assert.Equal(t, []string{
+			`versions[0].arch key "linux_amd64" is not any or <os>-<arch>`,
+			`versions[0].arch.linux_amd64.sha256 "abc" is not a SHA256 checksum`,
+			"versions[1].version is required",
+		}, invalidErr.Problems)
+		assert.Contains(t, err.Error(), "invalid plugin repository response from "+repoURL+"/repo/test-panel")
+	})
+
+	t.Run("Should report fields of the wrong type", func(t *testing.T) {
+		repoURL := newServer(t, `{"id": "test-panel", "versions": [{"version": 1}]}`)
+
+		_, err := newInstaller().GetPlugin("test-panel", repoURL)
+		var invalidErr *InvalidRepoResponseError
+		require.ErrorAs(t, err, &invalidErr)
+		require.Len(t, invalidErr.Problems, 1)
+		assert.Contains(t, invalidErr.Problems[0], "version must be a string, got a number")
+	})
+
+	t.Run("Should report malformed JSON", func(t *testing.T) {
+		repoURL := newServer(t, `<html>Login</html>`)
+
+		_, err := newInstaller().GetPlugin("test-panel", repoURL)
+		var invalidErr *InvalidRepoResponseError
+		require.ErrorAs(t, err, &invalidErr)
+		assert.Contains(t, invalidErr.Problems[0], "malformed JSON")
+	})
+
+	t.Run("Should only validate the requested plugin of a static index", func(t *testing.T) {
+		indexPath := filepath.Join(t.TempDir(), "index.json")
+		require.NoError(t, ioutil.WriteFile(indexPath, []byte(`{"plugins": [
+			{"id": "test-panel", "versions": [{"version": "1.0.0"}]},
+			{"id": "test-app", "versions": []}]}`), 0600))
+
+		plugin, err := newInstaller().GetPlugin("test-panel", indexPath)
+		require.NoError(t, err)
+		assert.Equal(t, "test-panel", plugin.ID)
+
+		_, err = newInstaller().GetPlugin("test-app", indexPath)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "plugins[1].versions is required")
+
+		latest, err := newInstaller().LatestVersions([]string{"test-panel", "test-app"}, indexPath)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"test-panel": "1.0.0"}, latest)
+	})
+}
@@ -0,0 +1,182 @@
+package installer
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxRetries     = 2
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+	defaultMaxRetryAfter  = time.Minute
+)
+
+// RetryOpts configures the retries of plugin repository metadata requests failing with transient errors. Plugin
+// downloads are only retried when the repository rate limits the installer.
+type RetryOpts struct {
+	// MaxRetries is the number of times a request is retried. Defaults to 2, -1 disables retries.
+	MaxRetries int
+	// InitialBackoff is the maximum delay before the first retry, which doubles with every retry. Defaults to
+	// 500ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Defaults to 10s.
+	MaxBackoff time.Duration
+	// MaxRetryAfter is the longest delay requested by a Retry-After header the installer waits for before
+	// retrying. Requests asking to wait longer fail right away. Defaults to 1m.
+	MaxRetryAfter time.Duration
+}
+
+func (o RetryOpts) maxRetries() int {
+	switch {
+	case o.MaxRetries < 0:
+		return 0
+	case o.MaxRetries == 0:
+		return defaultMaxRetries
+	}
+	return o.MaxRetries
+}
+
+func (o RetryOpts) maxBackoff() time.Duration {
+	if o.MaxBackoff <= 0 {
+		return defaultMaxBackoff
+	}
+	return o.MaxBackoff
+}
+
+func (o RetryOpts) maxRetryAfter() time.Duration {
+	if o.MaxRetryAfter <= 0 {
+		return defaultMaxRetryAfter
+	}
+	return o.MaxRetryAfter
+}
+
+// backoff returns the delay before the retry, a random duration up to the exponentially growing limit, so that
+// clients failing at the same time don't retry at the same time.
+func (o RetryOpts) backoff(retry int) time.Duration {
+	initial, max := o.InitialBackoff, o.maxBackoff()
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	limit := max
+	if retry < 32 && initial<<retry > 0 && initial<<retry < max {
+		limit = initial << retry
+	}
+	// nolint:gosec
+	return time.Duration(rand.Int63n(int64(limit) + 1))
+}
+
+// doWithRetry sends the request, retrying idempotent requests that fail with an error the retryable function
+// accepts. The delay requested by a Retry-After header is honored up to the configured maximum, the response of
+// the last attempt is returned.
+func (i *Installer) doWithRetry(client *http.Client, req *http.Request,
+	retryable func(*http.Response, error) bool) (*http.Response, error) {
+	retries := i.opts.Retry.maxRetries()
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		retries = 0
+	}
+
+	for retry := 0; ; retry++ {
+		res, err := client.Do(req)
+		if retry == retries || !retryable(res, err) {
+			return res, err
+		}
+
+		delay := i.opts.Retry.backoff(retry)
+		if after := retryAfter(res); after > i.opts.Retry.maxRetryAfter() {
+			i.log.Debugf("Request to %s failed with %s, not retrying as Retry-After of %s exceeds %s",
+				RedactURL(req.URL.String()), res.Status, after, i.opts.Retry.maxRetryAfter())
+			return res, err
+		} else if after > 0 {
+			delay = after
+		}
+		if err != nil {
+			i.log.Debugf("Request to %s failed, retrying in %s: %v", RedactURL(req.URL.String()), delay,
+				RedactURLError(err))
+		} else {
+			i.log.Debugf("Request to %s failed with %s, retrying in %s", RedactURL(req.URL.String()), res.Status,
+				delay)
+			if err := res.Body.Close(); err != nil {
+				i.log.Warn("Failed to close response body", "err", err)
+			}
+		}
+		time.Sleep(delay)
+	}
+}
+
+// isRetryable reports whether the request failed with a transient error, that is a connection error, a timeout
+// or a status code meaning the server is temporarily unable to respond. Certificate errors and cancelled
+// requests aren't retried.
+func isRetryable(res *http.Response, err error) bool {
+	if err != nil {
+		var unknownAuthority x509.UnknownAuthorityError
+		var hostname x509.HostnameError
+		var invalidCert x509.CertificateInvalidError
+		if errors.As(err, &unknownAuthority) || errors.As(err, &hostname) || errors.As(err, &invalidCert) ||
+			errors.Is(err, context.Canceled) {
+			return false
+		}
+		var urlErr *url.Error
+		return errors.As(err, &urlErr)
+	}
+
+	switch res.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isRateLimited reports whether the repository rejected the request because the installer sent too many.
+func isRateLimited(res *http.Response, err error) bool {
+	return err == nil && res.StatusCode == http.StatusTooManyRequests
+}
+
+// retryAfter returns the delay requested by the Retry-After header of the response, given either in seconds or
+// as an HTTP date.
+func retryAfter(res *http.Response) time.Duration {
+	if res == nil {
+		return 0
+	}
+	header := strings.TrimSpace(res.Header.Get("Retry-After"))
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return 0
+	}
+	if delay := time.Until(date); delay > 0 {
+		return delay.Round(time.Second)
+	}
+	return 0
+}
+
+// RateLimitError is returned when the plugin repository keeps rate limiting the installer, or asks it to wait
+// longer than it's willing to.
+type RateLimitError struct {
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("plugin repository rate limit exceeded (%s), retry after %s", e.Status, e.RetryAfter)
+	}
+	return fmt.Sprintf("plugin repository rate limit exceeded (%s), retry later", e.Status)
+}
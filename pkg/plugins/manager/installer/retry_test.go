@@ -0,0 +1,169 @@
+package installer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noRetry disables retries in tests of failing repositories.
+var noRetry = RetryOpts{MaxRetries: -1}
+
+func TestRetry(t *testing.T) {
+	fastRetry := RetryOpts{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+	// newServer responds with the status codes in turn, and with the last one once they're used up.
+	newServer := func(t *testing.T, statusCodes ...int) (*httptest.Server, *int) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			statusCode := statusCodes[len(statusCodes)-1]
+			if requests < len(statusCodes) {
+				statusCode = statusCodes[requests]
+			}
+			requests++
+			w.WriteHeader(statusCode)
+			_, _ = w.Write([]byte("body"))
+		}))
+		t.Cleanup(server.Close)
+		return server, &requests
+	}
+
+	t.Run("Should retry transient errors", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+		i := NewWithOpts(Opts{Retry: fastRetry}, "8.0.0", &fakeLogger{})
+
+		body, err := i.sendRequestGetBytes(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "body", string(body))
+		assert.Equal(t, 3, *requests)
+	})
+
+	t.Run("Should give up after max retries", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusBadGateway)
+		i := NewWithOpts(Opts{Retry: fastRetry}, "8.0.0", &fakeLogger{})
+
+		_, err := i.sendRequestGetBytes(server.URL)
+		require.Error(t, err)
+		assert.True(t, isMirrorFailure(err))
+		assert.Equal(t, 3, *requests)
+	})
+
+	t.Run("Should not retry permanent errors", func(t *testing.T) {
+		for _, statusCode := range []int{http.StatusNotFound, http.StatusUnauthorized, http.StatusNotImplemented} {
+			server, requests := newServer(t, statusCode)
+			i := NewWithOpts(Opts{Retry: fastRetry}, "8.0.0", &fakeLogger{})
+
+			_, err := i.sendRequestGetBytes(server.URL)
+			require.Error(t, err)
+			assert.Equal(t, 1, *requests, statusCode)
+		}
+	})
+
+	t.Run("Should retry connection errors", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		i := NewWithOpts(Opts{Retry: fastRetry}, "8.0.0", &fakeLogger{})
+
+		_, err := i.sendRequestGetBytes(server.URL)
+		require.Error(t, err)
+		assert.True(t, isRetryable(nil, err))
+	})
+
+	t.Run("Should not retry when disabled", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusServiceUnavailable)
+		i := NewWithOpts(Opts{Retry: noRetry}, "8.0.0", &fakeLogger{})
+
+		_, err := i.sendRequestGetBytes(server.URL)
+		require.Error(t, err)
+		assert.Equal(t, 1, *requests)
+	})
+
+	t.Run("Should honor Retry-After of rate limited requests", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = w.Write([]byte("body"))
+		}))
+		t.Cleanup(server.Close)
+		i := NewWithOpts(Opts{Retry: fastRetry}, "8.0.0", &fakeLogger{})
+
+		start := time.Now()
+		body, err := i.sendRequestGetBytes(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "body", string(body))
+		assert.Equal(t, 2, requests)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Should not wait for Retry-After longer than max", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		t.Cleanup(server.Close)
+		i := NewWithOpts(Opts{Retry: fastRetry}, "8.0.0", &fakeLogger{})
+
+		_, err := i.sendRequestGetBytes(server.URL)
+		var rateLimitErr *RateLimitError
+		require.ErrorAs(t, err, &rateLimitErr)
+		assert.Equal(t, 2*time.Minute, rateLimitErr.RetryAfter)
+		assert.Equal(t, "plugin repository rate limit exceeded (429 Too Many Requests), retry after 2m0s",
+			err.Error())
+		assert.True(t, isMirrorFailure(err))
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("Should retry rate limited downloads only", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusTooManyRequests, http.StatusOK)
+		i := NewWithOpts(Opts{Retry: fastRetry}, "8.0.0", &fakeLogger{})
+
+		body, err := i.sendRequestWithoutTimeout(server.URL)
+		require.NoError(t, err)
+		require.NoError(t, body.Close())
+		assert.Equal(t, 2, *requests)
+
+		server, requests = newServer(t, http.StatusServiceUnavailable, http.StatusOK)
+		_, err = i.sendRequestWithoutTimeout(server.URL)
+		require.Error(t, err)
+		assert.Equal(t, 1, *requests)
+	})
+
+	t.Run("Should parse Retry-After", func(t *testing.T) {
+		res := &http.Response{Header: http.Header{}}
+		for header, expected := range map[string]time.Duration{
+			"":        0,
+			"30":      30 * time.Second,
+			"-1":      0,
+			"invalid": 0,
+			time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat): 0,
+		} {
+			res.Header.Set("Retry-After", header)
+			assert.Equal(t, expected, retryAfter(res), header)
+		}
+		res.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+		delay := retryAfter(res)
+		assert.True(t, delay > 55*time.Second && delay <= time.Minute, delay)
+	})
+
+	t.Run("Should back off exponentially with jitter", func(t *testing.T) {
+		opts := RetryOpts{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+		for retry, limit := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+			for n := 0; n < 20; n++ {
+				delay := opts.backoff(retry)
+				assert.True(t, delay >= 0 && delay <= limit, "retry %d: %s", retry, delay)
+			}
+		}
+		assert.LessOrEqual(t, opts.backoff(100), 5*time.Second)
+	})
+}
@@ -0,0 +1,128 @@
+package installer
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// lookupPlugin finds the plugin metadata in the repositories of the source aliases routing the plugin or, if no
+// alias routes it, in the plugin repository and its mirrors. Only plugins from the default plugin repository are
+// routed, not plugins referenced by alias or from the enterprise repository. It returns the metadata and the
+// repositories to download the plugin from, starting with the one that served the metadata.
+func (i *Installer) lookupPlugin(pluginID, pluginRepoURL string, defaultRepo bool) (Plugin, []string, error) {
+	if defaultRepo {
+		routed, err := i.routedRepoURLs(pluginID)
+		if err != nil {
+			return Plugin{}, nil, err
+		}
+		if len(routed) > 0 {
+			plugin, servedBy, err := i.getPluginMetadataFromRoutes(pluginID, routed)
+			if err != nil {
+				return Plugin{}, nil, err
+			}
+			return plugin, []string{servedBy}, nil
+		}
+	}
+
+	repoURLs := i.repoURLs(pluginRepoURL, defaultRepo)
+	plugin, servedBy, err := i.getPluginMetadataFromMirrors(pluginID, repoURLs)
+	if err != nil {
+		return Plugin{}, nil, err
+	}
+	return plugin, append([]string{servedBy}, repoURLs...), nil
+}
+
+// routedRepoURLs returns the repositories of the source aliases whose plugin patterns match the plugin, by
+// descending priority and then by alias name.
+func (i *Installer) routedRepoURLs(pluginID string) ([]string, error) {
+	var routes []*sourceAliasConn
+	for _, c := range i.sourceAliases {
+		for _, pattern := range c.alias.Plugins {
+			match, err := matchPluginPattern(pattern, pluginID)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				routes = append(routes, c)
+				break
+			}
+		}
+	}
+	return aliasRepoURLs(routes), nil
+}
+
+// aliasRepoURLs returns the distinct repositories of the source aliases, by descending priority and then by alias
+// name.
+func aliasRepoURLs(aliases []*sourceAliasConn) []string {
+	sort.Slice(aliases, func(a, b int) bool {
+		if aliases[a].alias.Priority != aliases[b].alias.Priority {
+			return aliases[a].alias.Priority > aliases[b].alias.Priority
+		}
+		return aliases[a].name < aliases[b].name
+	})
+
+	repoURLs := make([]string, 0, len(aliases))
+	for _, c := range aliases {
+		if repoURL := strings.TrimSuffix(c.alias.URL, "/"); !containsString(repoURLs, repoURL) {
+			repoURLs = append(repoURLs, repoURL)
+		}
+	}
+	return repoURLs
+}
+
+// lookupDependency finds the metadata of a dependency like lookupPlugin. Dependencies of the default plugin
+// repository that no alias routes and that the plugin repository doesn't have are looked up in the repositories
+// of all source aliases, by descending priority, so that plugins of different repositories can depend on each
+// other.
+func (i *Installer) lookupDependency(pluginID, pluginRepoURL string, defaultRepo bool) (Plugin, []string, error) {
+	plugin, repoURLs, err := i.lookupPlugin(pluginID, pluginRepoURL, defaultRepo)
+	if !defaultRepo || !errors.Is(err, ErrNotFoundError) {
+		return plugin, repoURLs, err
+	}
+	if routed, routeErr := i.routedRepoURLs(pluginID); routeErr != nil || len(routed) > 0 {
+		// Routed plugins are only installed from the repositories routing them
+		return Plugin{}, nil, err
+	}
+
+	aliases := make([]*sourceAliasConn, 0, len(i.sourceAliases))
+	for _, c := range i.sourceAliases {
+		aliases = append(aliases, c)
+	}
+	var others []string
+	for _, repoURL := range aliasRepoURLs(aliases) {
+		if repoURL != strings.TrimSuffix(pluginRepoURL, "/") {
+			others = append(others, repoURL)
+		}
+	}
+	if len(others) == 0 {
+		return Plugin{}, nil, err
+	}
+	plugin, servedBy, otherErr := i.getPluginMetadataFromRoutes(pluginID, others)
+	if errors.Is(otherErr, ErrNotFoundError) {
+		return Plugin{}, nil, err
+	}
+	if otherErr != nil {
+		return Plugin{}, nil, otherErr
+	}
+	i.log.Debugf("Dependency %s found in repository %s", pluginID, RedactURL(servedBy))
+	return plugin, []string{servedBy}, nil
+}
+
+// getPluginMetadataFromRoutes looks up the plugin in each routed repository in turn until one has it. Unlike
+// mirrors, the next repository is only tried if the plugin doesn't exist in the previous one, so that an
+// unavailable repository never lets a lower priority one serve the plugin.
+func (i *Installer) getPluginMetadataFromRoutes(pluginID string, repoURLs []string) (Plugin, string, error) {
+	var err error
+	for _, repoURL := range repoURLs {
+		var plugin Plugin
+		if plugin, err = i.getPluginMetadataFromPluginRepo(pluginID, repoURL); err == nil {
+			i.log.Debugf("Plugin %s is routed to repository %s", pluginID, RedactURL(repoURL))
+			return plugin, repoURL, nil
+		}
+		if !errors.Is(err, ErrNotFoundError) {
+			return Plugin{}, "", err
+		}
+	}
+	return Plugin{}, "", err
+}
@@ -0,0 +1,112 @@
+package installer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoRoutes(t *testing.T) {
+	// newRepo serves the metadata of the plugins, or a server error if there are none.
+	newRepo := func(t *testing.T, pluginIDs ...string) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(pluginIDs) == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			pluginID := strings.TrimPrefix(r.URL.Path, "/repo/")
+			if !containsString(pluginIDs, pluginID) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"id": %q, "versions": [{"version": "1.0.0"}]}`, pluginID)
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+
+	t.Run("Should route plugins to repository of matching alias", func(t *testing.T) {
+		defaultRepo := newRepo(t, "corp-panel", "grafana-clock-panel")
+		corp := newRepo(t, "corp-panel")
+		i := NewWithOpts(Opts{SourceAliases: map[string]SourceAlias{
+			"corp": {URL: corp, Plugins: []string{"corp-*"}},
+		}}, "8.0.0", &fakeLogger{})
+
+		_, repoURLs, err := i.lookupPlugin("corp-panel", defaultRepo, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{corp}, repoURLs)
+
+		_, repoURLs, err = i.lookupPlugin("grafana-clock-panel", defaultRepo, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{defaultRepo, defaultRepo}, repoURLs)
+
+		_, repoURLs, err = i.lookupPlugin("corp-panel", defaultRepo, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{defaultRepo, defaultRepo}, repoURLs)
+	})
+
+	t.Run("Should look up dependencies in repositories of all aliases", func(t *testing.T) {
+		defaultRepo := newRepo(t, "grafana-clock-panel")
+		low, high := newRepo(t, "corp-panel", "grafana-clock-panel"), newRepo(t, "corp-panel")
+		i := NewWithOpts(Opts{SourceAliases: map[string]SourceAlias{
+			"corp":   {URL: low},
+			"shared": {URL: high, Priority: 10},
+			"team":   {URL: newRepo(t, "team-panel"), Plugins: []string{"team-*"}},
+		}}, "8.0.0", &fakeLogger{})
+
+		_, repoURLs, err := i.lookupDependency("corp-panel", defaultRepo, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{high}, repoURLs)
+
+		_, repoURLs, err = i.lookupDependency("grafana-clock-panel", defaultRepo, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{defaultRepo, defaultRepo}, repoURLs)
+
+		_, _, err = i.lookupPlugin("corp-panel", defaultRepo, true)
+		var notFoundErr *PluginNotFoundError
+		require.ErrorAs(t, err, &notFoundErr)
+		_, _, err = i.lookupDependency("other-panel", defaultRepo, true)
+		require.ErrorAs(t, err, &notFoundErr)
+		_, _, err = i.lookupDependency("team-app", defaultRepo, true)
+		require.ErrorAs(t, err, &notFoundErr)
+	})
+
+	t.Run("Should try routed repositories by priority", func(t *testing.T) {
+		high := newRepo(t, "corp-panel")
+		low := newRepo(t, "corp-panel", "corp-app")
+		i := NewWithOpts(Opts{SourceAliases: map[string]SourceAlias{
+			"a-low":  {URL: low, Plugins: []string{"corp-*"}},
+			"b-high": {URL: high, Plugins: []string{"/^corp-/"}, Priority: 10},
+			"c-low":  {URL: newRepo(t, "corp-app"), Plugins: []string{"corp-*"}},
+		}}, "8.0.0", &fakeLogger{})
+
+		_, repoURLs, err := i.lookupPlugin("corp-panel", "https://grafana.com/api/plugins", true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{high}, repoURLs)
+
+		_, repoURLs, err = i.lookupPlugin("corp-app", "https://grafana.com/api/plugins", true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{low}, repoURLs)
+
+		_, _, err = i.lookupPlugin("corp-datasource", "https://grafana.com/api/plugins", true)
+		var notFound *PluginNotFoundError
+		require.ErrorAs(t, err, &notFound)
+	})
+
+	t.Run("Should not fall back when routed repository is unavailable", func(t *testing.T) {
+		defaultRepo := newRepo(t, "corp-panel")
+		i := NewWithOpts(Opts{SourceAliases: map[string]SourceAlias{
+			"corp":   {URL: newRepo(t), Plugins: []string{"corp-*"}, Priority: 1},
+			"public": {URL: newRepo(t, "corp-panel"), Plugins: []string{"*"}},
+		}, Retry: noRetry}, "8.0.0", &fakeLogger{})
+
+		_, _, err := i.lookupPlugin("corp-panel", defaultRepo, true)
+		require.Error(t, err)
+		assert.True(t, isMirrorFailure(err))
+	})
+}
@@ -0,0 +1,17 @@
+package installer
+
+import (
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
+// SearchFilters and SearchResult are the plugin search filters and results, see the repo package.
+type (
+	SearchFilters = repo.SearchFilters
+	SearchResult  = repo.SearchResult
+)
+
+// Search returns the plugins in the plugin repository matching all words of the query and the filters, see
+// repo.HTTPClient.Search.
+func (i *Installer) Search(query string, filters SearchFilters, pluginRepoURL string) ([]SearchResult, error) {
+	return i.metadataClient(pluginRepoURL).Search(query, filters)
+}
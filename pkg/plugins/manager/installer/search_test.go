@@ -0,0 +1,90 @@
+package installer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	const searchResponse = `{"items": [
+		{"slug": "grafana-clock-panel", "name": "Clock Panel", "description": "Analog clock", "typeCode": "panel",
+			"signatureType": "grafana", "version": "2.1.0", "orgName": "Grafana Labs"},
+		{"slug": "acme-clock-datasource", "name": "Acme", "description": "Time series from the clock", "typeCode": "datasource",
+			"signatureType": "community", "version": "1.0.0", "orgName": "Acme"},
+		{"slug": "clock", "name": "Clock", "typeCode": "panel", "version": "0.1.0", "keywords": ["time"]}
+	]}`
+
+	newServer := func(t *testing.T) (*httptest.Server, *url.Values) {
+		var params url.Values
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			params = r.URL.Query()
+			_, _ = w.Write([]byte(searchResponse))
+		}))
+		t.Cleanup(server.Close)
+		return server, &params
+	}
+	ids := func(results []SearchResult) []string {
+		var ids []string
+		for _, res := range results {
+			ids = append(ids, res.ID)
+		}
+		return ids
+	}
+
+	t.Run("Should search plugin repository", func(t *testing.T) {
+		server, params := newServer(t)
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+
+		results, err := i.Search("clock", SearchFilters{Type: "panel"}, server.URL+"/api/plugins")
+		require.NoError(t, err)
+		assert.Equal(t, "clock", params.Get("query"))
+		assert.Equal(t, "panel", params.Get("typeCode"))
+		assert.Equal(t, []SearchResult{
+			{ID: "clock", Name: "Clock", Type: "panel", LatestVersion: "0.1.0"},
+			{ID: "grafana-clock-panel", Name: "Clock Panel", Description: "Analog clock", Type: "panel",
+				SignatureType: "grafana", LatestVersion: "2.1.0"},
+		}, results)
+	})
+
+	t.Run("Should filter results the repository didn't filter", func(t *testing.T) {
+		server, _ := newServer(t)
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+
+		results, err := i.Search("", SearchFilters{MinSignatureLevel: SignatureLevelCommunity}, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"grafana-clock-panel", "acme-clock-datasource"}, ids(results))
+
+		results, err = i.Search("time clock", SearchFilters{}, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"acme-clock-datasource", "clock"}, ids(results))
+
+		results, err = i.Search("", SearchFilters{Limit: 1}, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"grafana-clock-panel"}, ids(results))
+	})
+
+	t.Run("Should search static repository", func(t *testing.T) {
+		repoDir := t.TempDir()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(repoDir, "index.json"), []byte(`{"plugins": [
+			{"id": "test-panel", "name": "Test", "type": "panel", "versions": [{"version": "1.1.0"}, {"version": "1.0.0"}]},
+			{"id": "other-panel", "type": "panel", "versions": [{"version": "2.0.0", "arch": {"plan9-mips": {}}}]},
+			{"id": "test-app", "type": "app", "versions": [{"version": "3.0.0"}]}
+		]}`), 0600))
+		i := &Installer{log: &fakeLogger{}}
+
+		results, err := i.Search("", SearchFilters{Type: "panel"}, repoDir)
+		require.NoError(t, err)
+		assert.Equal(t, []SearchResult{{ID: "test-panel", Name: "Test", Type: "panel", LatestVersion: "1.1.0"}}, results)
+
+		results, err = i.Search("app", SearchFilters{}, repoDir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"test-app"}, ids(results))
+	})
+}
@@ -0,0 +1,175 @@
+package installer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
+// SourceAlias is a named plugin repository, so that plugins can be installed as alias:pluginID instead of
+// passing the full repository URL. Requests to the repository use the alias' credentials and TLS settings.
+type SourceAlias struct {
+	// URL is the URL of the plugin repository.
+	URL string
+	// Token is sent as bearer token. It takes precedence over Username and Password.
+	Token    string
+	Username string
+	Password string
+	// Header is an arbitrary header sent as "Name: value" if neither a token nor a username is set.
+	Header string
+	// CredentialHelper is a command printing the credentials of the repository if no other credentials are set,
+	// see RepoCredentials.
+	CredentialHelper string
+	// SkipTLSVerify disables TLS certificate verification for the repository.
+	SkipTLSVerify bool
+	// CACertPath is the path to a PEM encoded CA bundle used to verify the repository's certificate.
+	CACertPath string
+	// ClientCertPath and ClientKeyPath are the paths to the PEM encoded client certificate and key presented to
+	// repositories requiring mutual TLS. They're reloaded when rotated on disk.
+	ClientCertPath string
+	ClientKeyPath  string
+	// Proxy is the URL of the proxy requests to the repository are sent through, overriding the proxy environment
+	// variables, or "direct" to send them directly.
+	Proxy string
+	// NoProxy are the hosts requests to which bypass the proxy, see RepoProxy.
+	NoProxy []string
+	// Plugins are patterns (globs, or regular expressions enclosed in slashes) of plugin IDs that are installed
+	// from the repository without the alias: prefix, instead of from the plugin repository.
+	Plugins []string
+	// Priority orders the aliases routing the same plugin. The plugin is installed from the highest priority
+	// repository that has it, aliases with the same priority are ordered by name.
+	Priority int
+}
+
+// sourceAliasConn holds the HTTP clients of a source alias, which are created when first used.
+type sourceAliasConn struct {
+	name  string
+	alias SourceAlias
+
+	pinnedKeys map[string][]string
+	fips       bool
+	proxy      func(*http.Request) (*url.URL, error)
+	proxyToken func(*url.URL) string
+	dns        DNSOpts
+	transport  TransportOpts
+	// checkRedirect is the redirect policy of the installer, see Installer.checkRedirect.
+	checkRedirect func(*http.Request, []*http.Request) error
+
+	once                sync.Once
+	err                 error
+	httpClient          http.Client
+	httpClientNoTimeout http.Client
+}
+
+func newSourceAliasConns(aliases map[string]SourceAlias, pinnedKeys map[string][]string, fips bool,
+	proxy func(*http.Request) (*url.URL, error), proxyToken func(*url.URL) string, dns DNSOpts,
+	transport TransportOpts) map[string]*sourceAliasConn {
+	conns := make(map[string]*sourceAliasConn, len(aliases))
+	for name, alias := range aliases {
+		conns[name] = &sourceAliasConn{name: name, alias: alias, pinnedKeys: pinnedKeys, fips: fips, proxy: proxy,
+			proxyToken: proxyToken, dns: dns, transport: transport}
+	}
+	return conns
+}
+
+func (c *sourceAliasConn) clients() (*http.Client, *http.Client, error) {
+	c.once.Do(func() {
+		tlsConfig, err := makeTLSConfig(c.alias.SkipTLSVerify, c.alias.CACertPath, c.alias.ClientCertPath,
+			c.alias.ClientKeyPath, c.pinnedKeys, c.fips)
+		if err != nil {
+			c.err = fmt.Errorf("invalid TLS settings of plugin source %q: %w", c.name, err)
+			return
+		}
+		c.httpClient = makeHttpClientWithTLS(tlsConfig, c.proxy, c.proxyToken, c.dns, 10*time.Second)
+		c.httpClientNoTimeout = makeDownloadClient(tlsConfig, c.proxy, c.proxyToken, c.dns, c.transport)
+		c.httpClientNoTimeout.CheckRedirect = c.checkRedirect
+	})
+	return &c.httpClient, &c.httpClientNoTimeout, c.err
+}
+
+func (c *sourceAliasConn) credentials() RepoCredentials {
+	return RepoCredentials{
+		Token:            c.alias.Token,
+		Username:         c.alias.Username,
+		Password:         c.alias.Password,
+		Header:           c.alias.Header,
+		CredentialHelper: c.alias.CredentialHelper,
+	}
+}
+
+// matches reports whether the URL belongs to the alias' repository.
+func (c *sourceAliasConn) matches(u *url.URL) bool {
+	return isUnderURL(u, c.alias.URL)
+}
+
+// isUnderURL reports whether the URL equals the base URL or is one of its sub paths. Generic repository URLs are
+// matched without their generic+ prefix.
+func isUnderURL(u *url.URL, baseURL string) bool {
+	if genericBase, generic := repo.GenericBase(baseURL); generic {
+		baseURL = genericBase
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(base.Scheme, u.Scheme) || !strings.EqualFold(base.Host, u.Host) {
+		return false
+	}
+	prefix := strings.TrimSuffix(base.Path, "/")
+	return u.Path == prefix || strings.HasPrefix(u.Path, prefix+"/")
+}
+
+// sourceAliasFor returns the alias whose repository the URL belongs to, preferring the most specific one.
+func (i *Installer) sourceAliasFor(u *url.URL) *sourceAliasConn {
+	var match *sourceAliasConn
+	for _, c := range i.sourceAliases {
+		if c.matches(u) && (match == nil || len(c.alias.URL) > len(match.alias.URL)) {
+			match = c
+		}
+	}
+	return match
+}
+
+// resolveSourceAlias splits alias:pluginID references, returning the plugin ID and the alias' repository URL.
+// References without an alias are returned as is.
+func (i *Installer) resolveSourceAlias(ref, pluginRepoURL string) (string, string, error) {
+	idx := strings.Index(ref, ":")
+	if idx < 0 {
+		return ref, pluginRepoURL, nil
+	}
+	name, pluginID := ref[:idx], ref[idx+1:]
+	c, exists := i.sourceAliases[name]
+	if !exists {
+		return "", "", fmt.Errorf("unknown plugin source %q", name)
+	}
+	if pluginID == "" {
+		return "", "", fmt.Errorf("invalid plugin reference %q", ref)
+	}
+	return pluginID, strings.TrimSuffix(c.alias.URL, "/"), nil
+}
+
+// clientFor returns the HTTP client to use for the request URL.
+func (i *Installer) clientFor(u *url.URL, noTimeout bool) (*http.Client, error) {
+	if i.offline {
+		return nil, ErrOffline
+	}
+	if c := i.sourceAliasFor(u); c != nil {
+		client, clientNoTimeout, err := c.clients()
+		if noTimeout {
+			return clientNoTimeout, err
+		}
+		return client, err
+	}
+	if i.tlsErr != nil {
+		return nil, i.tlsErr
+	}
+	if noTimeout {
+		return &i.httpClientNoTimeout, nil
+	}
+	return &i.httpClient, nil
+}
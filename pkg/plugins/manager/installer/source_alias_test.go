@@ -0,0 +1,81 @@
+package installer
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceAliases(t *testing.T) {
+	i := NewWithOpts(Opts{SourceAliases: map[string]SourceAlias{
+		"corp":  {URL: "https://artifacts.corp/grafana-plugins/", Token: "secret"},
+		"team":  {URL: "https://artifacts.corp/grafana-plugins/team", Username: "user", Password: "pass"},
+		"other": {URL: "https://other.corp"},
+	}}, "8.0.0", &fakeLogger{})
+
+	t.Run("Should resolve alias to repository URL", func(t *testing.T) {
+		pluginID, repoURL, err := i.resolveSourceAlias("corp:my-team-panel@2.0.0", "https://grafana.com/api/plugins")
+		require.NoError(t, err)
+		assert.Equal(t, "my-team-panel@2.0.0", pluginID)
+		assert.Equal(t, "https://artifacts.corp/grafana-plugins", repoURL)
+	})
+
+	t.Run("Should keep references without alias", func(t *testing.T) {
+		pluginID, repoURL, err := i.resolveSourceAlias("my-team-panel", "https://grafana.com/api/plugins")
+		require.NoError(t, err)
+		assert.Equal(t, "my-team-panel", pluginID)
+		assert.Equal(t, "https://grafana.com/api/plugins", repoURL)
+	})
+
+	t.Run("Should fail for unknown alias", func(t *testing.T) {
+		_, _, err := i.resolveSourceAlias("unknown:my-team-panel", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown plugin source "unknown"`)
+	})
+
+	t.Run("Should use credentials of most specific alias", func(t *testing.T) {
+		req, err := i.createRequest("https://artifacts.corp/grafana-plugins", "repo", "my-team-panel")
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+
+		req, err = i.createRequest("https://artifacts.corp/grafana-plugins/team", "repo", "my-team-panel")
+		require.NoError(t, err)
+		user, pass, ok := req.BasicAuth()
+		require.True(t, ok)
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "pass", pass)
+
+		req, err = i.createRequest("https://artifacts.corp/grafana-plugins-other/repo")
+		require.NoError(t, err)
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+
+	t.Run("Should trust alias CA certificate", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+		t.Cleanup(server.Close)
+
+		caCert := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, ioutil.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: server.Certificate().Raw,
+		}), 0600))
+
+		_, err := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).sendRequestGetBytes(server.URL)
+		require.Error(t, err)
+
+		i := NewWithOpts(Opts{SourceAliases: map[string]SourceAlias{
+			"corp": {URL: server.URL, CACertPath: caCert},
+		}}, "8.0.0", &fakeLogger{})
+		body, err := i.sendRequestGetBytes(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", string(body))
+	})
+}
@@ -0,0 +1,136 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// SourceNotAllowedError is returned when a plugin archive is to be downloaded from a source that isn't permitted
+// by the source policy.
+type SourceNotAllowedError struct {
+	URL string
+}
+
+func (e *SourceNotAllowedError) Error() string {
+	return fmt.Sprintf("downloading plugins from %s is not permitted by the source policy", RedactURL(e.URL))
+}
+
+// ErrInsecureURL is returned when installing a plugin from a plain HTTP URL without allowing insecure HTTP.
+var ErrInsecureURL = errors.New("refusing to download plugin over plain HTTP, use an HTTPS URL or allow insecure HTTP")
+
+// checkInsecureURL refuses plain HTTP plugin URLs, which can be tampered with in transit, unless insecure HTTP is
+// explicitly allowed, in which case a warning is logged.
+func (i *Installer) checkInsecureURL(pluginZipURL string) error {
+	u, err := url.Parse(pluginZipURL)
+	if err != nil || !strings.EqualFold(u.Scheme, "http") {
+		return nil
+	}
+	if !i.opts.AllowInsecureHTTP {
+		return errutil.Wrapf(ErrInsecureURL, "failed to install plugin from %s", RedactURL(pluginZipURL))
+	}
+	i.log.Warnf("INSECURE: downloading plugin over plain HTTP from %s, the plugin can be tampered with in "+
+		"transit. Use HTTPS unless the network is trusted", RedactURL(pluginZipURL))
+	return nil
+}
+
+// allowedSources returns the sources permitted by the configured sources and the source policy file. A nil
+// slice means all sources are permitted.
+func (i *Installer) allowedSources() ([]string, error) {
+	sources := i.opts.AllowedSources
+	if i.opts.SourcePolicyPath == "" {
+		return sources, nil
+	}
+
+	// nolint:gosec
+	data, err := ioutil.ReadFile(i.opts.SourcePolicyPath)
+	if err != nil {
+		return nil, errutil.Wrap("failed to read source policy", err)
+	}
+	sources = append([]string{}, sources...)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sources = append(sources, line)
+	}
+	return sources, scanner.Err()
+}
+
+// checkSourceAllowed verifies that the plugin archive URL is permitted by the source policy. Sources are either
+// host names, matching any URL on that host and, if prefixed with *., its subdomains, URL prefixes, matching
+// URLs below them, or absolute directories, matching local archives within them.
+func (i *Installer) checkSourceAllowed(archiveURL string) error {
+	sources, err := i.allowedSources()
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(archiveURL); err == nil {
+		path, err := filepath.Abs(archiveURL)
+		if err != nil {
+			return err
+		}
+		for _, source := range sources {
+			if filepath.IsAbs(source) && isUnderDir(path, source) {
+				return nil
+			}
+		}
+		return &SourceNotAllowedError{URL: archiveURL}
+	}
+
+	u, err := url.Parse(archiveURL)
+	if err != nil {
+		return RedactURLError(err)
+	}
+	if u.Scheme == gitLabScheme {
+		if u, err = i.gitLabPackageURL(u); err != nil {
+			return err
+		}
+	}
+	for _, source := range sources {
+		if sourceMatches(source, u) {
+			return nil
+		}
+	}
+	return &SourceNotAllowedError{URL: u.Redacted()}
+}
+
+// sourceMatches reports whether the URL matches a host name or URL prefix source.
+func sourceMatches(source string, u *url.URL) bool {
+	if strings.Contains(source, "://") {
+		return isUnderURL(u, source)
+	}
+	if filepath.IsAbs(source) {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	source = strings.ToLower(source)
+	if strings.HasPrefix(source, "*.") {
+		return strings.HasSuffix(host, source[1:])
+	}
+	return host == source
+}
+
+// isUnderDir reports whether path is dir or within it.
+func isUnderDir(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
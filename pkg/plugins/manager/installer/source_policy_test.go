@@ -0,0 +1,94 @@
+package installer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSourceAllowed(t *testing.T) {
+	t.Run("Should permit all sources without policy", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+		require.NoError(t, i.checkSourceAllowed("https://example.com/plugin.zip"))
+	})
+
+	t.Run("Should match host names and URL prefixes", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AllowedSources: []string{
+			"grafana.com",
+			"*.corp.example",
+			"https://github.com/grafana",
+			"gitlab.com",
+		}}}
+
+		for _, u := range []string{
+			"https://grafana.com/api/plugins/test-panel/versions/1.0.0/download",
+			"https://artifacts.corp.example/plugins/test-panel.zip",
+			"https://github.com/grafana/test-panel/releases/download/v1.0.0/test-panel.zip",
+			"gitlab://group/project/test-panel/1.0.0/test-panel.zip",
+		} {
+			require.NoError(t, i.checkSourceAllowed(u), u)
+		}
+
+		for _, u := range []string{
+			"https://grafana.com.evil.example/plugin.zip",
+			"https://corp.example.evil/plugin.zip",
+			"http://github.com/grafana/test-panel.zip",
+			"https://github.com/grafana-fork/test-panel.zip",
+		} {
+			err := i.checkSourceAllowed(u)
+			var sourceErr *SourceNotAllowedError
+			require.ErrorAs(t, err, &sourceErr, u)
+		}
+	})
+
+	t.Run("Should read source policy file", func(t *testing.T) {
+		policy := filepath.Join(t.TempDir(), "sources")
+		require.NoError(t, ioutil.WriteFile(policy, []byte("# production\n\nartifacts.corp.example\n"), 0600))
+		i := &Installer{log: &fakeLogger{}, opts: Opts{SourcePolicyPath: policy}}
+
+		require.NoError(t, i.checkSourceAllowed("https://artifacts.corp.example/test-panel.zip"))
+		require.Error(t, i.checkSourceAllowed("https://grafana.com/test-panel.zip"))
+	})
+
+	t.Run("Should match local archives against directories", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AllowedSources: []string{filepath.Dir(archive)}}}
+		require.NoError(t, i.checkSourceAllowed(archive))
+
+		i.opts.AllowedSources = []string{t.TempDir()}
+		require.Error(t, i.checkSourceAllowed(archive))
+	})
+
+	t.Run("Should reject archives before downloading", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": testPluginJSON})
+		pluginsDir := t.TempDir()
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AllowedSources: []string{"grafana.com"}}}
+
+		err := i.Install("test-panel", "", pluginsDir, archive, "")
+		var sourceErr *SourceNotAllowedError
+		require.ErrorAs(t, err, &sourceErr)
+		entries, err := ioutil.ReadDir(pluginsDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestCheckInsecureURL(t *testing.T) {
+	t.Run("Should refuse plain HTTP URLs", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}}
+
+		err := i.Install("test-panel", "", t.TempDir(), "http://example.com/test-panel.zip", "")
+		require.ErrorIs(t, err, ErrInsecureURL)
+		require.NoError(t, i.checkInsecureURL("https://example.com/test-panel.zip"))
+		require.NoError(t, i.checkInsecureURL(filepath.Join(t.TempDir(), "test-panel.zip")))
+	})
+
+	t.Run("Should allow plain HTTP URLs when opted out", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AllowInsecureHTTP: true}}
+
+		require.NoError(t, i.checkInsecureURL("HTTP://example.com/test-panel.zip"))
+	})
+}
@@ -0,0 +1,10 @@
+package installer
+
+import (
+	"github.com/grafana/grafana/pkg/plugins/repo"
+)
+
+// pluginDownloadURL returns the URL the archive of the plugin version is downloaded from.
+func pluginDownloadURL(pluginRepoURL, pluginID string, v *Version) string {
+	return repo.DownloadURL(pluginRepoURL, pluginID, v, osAndArchString())
+}
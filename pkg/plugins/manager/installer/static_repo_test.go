@@ -0,0 +1,102 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticRepo(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.1.0"}}`
+
+	writeRepo := func(t *testing.T) string {
+		repoDir := t.TempDir()
+		archive, err := ioutil.ReadFile(writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON}))
+		require.NoError(t, err)
+		archiveDir := filepath.Join(repoDir, "test-panel", "1.1.0")
+		require.NoError(t, os.MkdirAll(archiveDir, 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(archiveDir, "test-panel-1.1.0.zip"), archive, 0600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(repoDir, "index.json"), []byte(`{"plugins": [
+			{"id": "other-panel", "versions": [{"version": "2.0.0"}]},
+			{"id": "test-panel", "versions": [{"version": "1.1.0"}, {"version": "1.0.0"}]}
+		]}`), 0600))
+		return repoDir
+	}
+
+	t.Run("Should install plugin from static repository in file system", func(t *testing.T) {
+		repoDir := writeRepo(t)
+		pluginsDir := t.TempDir()
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}}
+
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, "", filepath.Join(repoDir, "index.json")))
+		data, err := ioutil.ReadFile(filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		require.NoError(t, err)
+		assert.Equal(t, pluginJSON, string(data))
+	})
+
+	t.Run("Should install plugin from local repository directory", func(t *testing.T) {
+		repoDir := writeRepo(t)
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}}
+
+		for _, repoURL := range []string{repoDir, "file://" + filepath.ToSlash(repoDir)} {
+			pluginsDir := t.TempDir()
+			require.NoError(t, i.Install("test-panel", "1.1.0", pluginsDir, "", repoURL), repoURL)
+			assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		}
+	})
+
+	t.Run("Should install plugin from static repository served by file server", func(t *testing.T) {
+		server := httptest.NewServer(http.StripPrefix("/mirror", http.FileServer(http.Dir(writeRepo(t)))))
+		t.Cleanup(server.Close)
+		pluginsDir := t.TempDir()
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, AllowInsecureHTTP: true}, "8.0.0", &fakeLogger{})
+
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, "", server.URL+"/mirror/index.json"))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+	})
+
+	t.Run("Should fail for plugin missing from index", func(t *testing.T) {
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}}
+
+		err := i.Install("missing-panel", "", t.TempDir(), "", filepath.Join(writeRepo(t), "index.json"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `failed to find plugin "missing-panel"`)
+	})
+
+	t.Run("Should resolve archive location", func(t *testing.T) {
+		platform := osAndArchString()
+		repoDir := t.TempDir()
+		for name, tc := range map[string]struct {
+			repoURL  string
+			version  Version
+			expected string
+		}{
+			"API": {"https://grafana.com/api/plugins", Version{Version: "1.0.0"},
+				"https://grafana.com/api/plugins/test-panel/versions/1.0.0/download"},
+			"Any platform": {"https://bucket.example/plugins/index.json?sig=abc",
+				Version{Version: "1.0.0", Arch: map[string]ArchMeta{"any": {}}},
+				"https://bucket.example/plugins/test-panel/1.0.0/test-panel-1.0.0.zip"},
+			"Current platform": {"/mnt/mirror/index.json",
+				Version{Version: "1.0.0", Arch: map[string]ArchMeta{platform: {}, "any": {}}},
+				fmt.Sprintf("/mnt/mirror/test-panel/1.0.0/test-panel-1.0.0.%s.zip", platform)},
+			"Relative download URL": {"https://files.example/index.json",
+				Version{Version: "1.0.0", Arch: map[string]ArchMeta{"any": {DownloadURL: "archives/test.zip"}}},
+				"https://files.example/archives/test.zip"},
+			"Local directory": {repoDir, Version{Version: "1.0.0"},
+				repoDir + string(filepath.Separator) + "test-panel/1.0.0/test-panel-1.0.0.zip"},
+			"Absolute download URL": {"index.json",
+				Version{Version: "1.0.0", Arch: map[string]ArchMeta{platform: {DownloadURL: "https://cdn.example/t.zip"}}},
+				"https://cdn.example/t.zip"},
+		} {
+			v := tc.version
+			assert.Equal(t, tc.expected, pluginDownloadURL(tc.repoURL, "test-panel", &v), name)
+		}
+	})
+}
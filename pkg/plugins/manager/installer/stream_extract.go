@@ -0,0 +1,164 @@
+package installer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// errStreamFallback stops extracting an archive while it's downloaded, so that it's downloaded to a temporary file
+// instead.
+var errStreamFallback = errors.New("archive can't be extracted while it's downloaded")
+
+// canStreamArchives reports whether archives may be extracted while they're downloaded, which requires that no
+// verification needs the whole archive and that archives aren't cached.
+func (i *Installer) canStreamArchives() bool {
+	return i.opts.StreamExtraction && !i.offline && i.opts.SumFilePath == "" && i.opts.SignatureKeyringPath == "" &&
+		!i.opts.Cosign.enabled() && i.opts.Provenance.KeyPath == "" && i.opts.DownloadCache.Dir == ""
+}
+
+// streamArchive extracts the tar archive of the plugin to a staging directory while downloading it, trying its
+// mirrors in turn, and validates the extracted plugin. It reports false if the archive has to be downloaded to a
+// temporary file instead, e.g. because it's a zip archive, a local file or contains an install manifest.
+func (i *Installer) streamArchive(p *pendingInstall, pluginsDir string) (bool, error) {
+	downloadURLs := i.orderByLatency(p.downloadURLs)
+	var mismatches checksumMismatches
+	var err error
+	for idx, downloadURL := range downloadURLs {
+		if _, statErr := os.Stat(downloadURL); statErr == nil {
+			return false, nil
+		}
+		if downloadURL != p.downloadURLs[0] {
+			if err := i.checkSourceAllowed(downloadURL); err != nil {
+				return false, err
+			}
+		}
+		start := time.Now()
+		var size int64
+		if size, err = i.streamArchiveFrom(p, downloadURL, pluginsDir); err == nil {
+			p.pluginZipURL = downloadURL
+			p.event.Source = RedactURL(downloadURL)
+			p.event.Download = newDownloadStats(size, start, idx+1)
+			mismatches.warnRecovered(i, downloadURL)
+			i.logDownloadStats(p.pluginID, downloadURL, p.event.Download)
+			return true, nil
+		}
+		if errors.Is(err, errStreamFallback) {
+			i.log.Debugf("Downloading %s to a temporary file: %v", RedactURL(downloadURL), err)
+			return false, nil
+		}
+		if !mismatches.add(err) && !isMirrorFailure(err) || idx == len(downloadURLs)-1 {
+			break
+		}
+		i.log.Warnf("Failed to download %s, trying %s: %v", RedactURL(downloadURL),
+			RedactURL(downloadURLs[idx+1]), err)
+	}
+	return false, mismatches.error(err)
+}
+
+// streamArchiveFrom downloads the archive from the URL and extracts it to a new staging directory, verifying the
+// checksum once the whole archive is read. It returns the number of bytes downloaded.
+func (i *Installer) streamArchiveFrom(p *pendingInstall, downloadURL, pluginsDir string) (int64, error) {
+	var expected archiveChecksum
+	if p.checksum != "" {
+		var err error
+		if expected, err = parseChecksum(p.checksum); err != nil {
+			return 0, err
+		}
+	}
+
+	body, contentLength, err := i.sendDownloadRequest(downloadURL)
+	if err != nil {
+		return 0, errutil.Wrap("Failed to send request", i.entitlementError(p.pluginID, err))
+	}
+	body = i.idleTimeoutBody(body)
+	defer func() {
+		if err := body.Close(); err != nil {
+			i.log.Warn("Failed to close body", "err", err)
+		}
+	}()
+	// The extracted plugin takes at least the size of the archive
+	if err := i.checkDiskSpace(pluginsDir, contentLength, "extract the plugin"); err != nil {
+		return 0, err
+	}
+
+	h, sum := expected.newHash(), sha256.New()
+	var downloaded byteCounter
+	r := bufio.NewReader(io.TeeReader(i.progressReader(i.archiveSizeReader(i.throttledReader(body)), p.pluginID,
+		contentLength), io.MultiWriter(h, sum, &downloaded)))
+	header, err := r.Peek(archiveSniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	decompress, isTar := tarDecompressor(detectArchiveFormat(header))
+	if !isTar {
+		return 0, fmt.Errorf("%w: it's a %s archive", errStreamFallback, detectArchiveFormat(header))
+	}
+
+	stagingDir, err := newStagingDir(pluginsDir)
+	if err != nil {
+		return 0, errutil.Wrap("failed to create quarantine directory", err)
+	}
+	p.journal.record(journalEntry{PluginID: p.pluginID, Phase: journalPhaseExtract, StagingDir: stagingDir})
+	i.log.Debugf("Extracting %s while downloading it to %q...", p.pluginID, stagingDir)
+	err = i.extractArchive(&streamedTarArchive{r: r, decompress: decompress}, p.pluginID, stagingDir, p.isInternal)
+	if err != nil && !errors.Is(err, errStreamFallback) {
+		err = errutil.Wrap("failed to extract plugin archive", err)
+	}
+	if err == nil {
+		// The checksum covers the rest of the archive as well, e.g. the padding after the end of the tar stream
+		_, err = io.Copy(ioutil.Discard, r)
+	}
+	if err == nil && p.checksum != "" && !expected.matches(h) {
+		err = newChecksumMismatchError(expected, h, downloadURL)
+	}
+	if err == nil {
+		err = i.validatePlugin(stagingDir, p.pluginID)
+	}
+	if err != nil {
+		i.removeStagingDir(stagingDir)
+		return 0, err
+	}
+	p.stagingDir = stagingDir
+	p.archiveSHA256 = hex.EncodeToString(sum.Sum(nil))
+	return int64(downloaded), nil
+}
+
+// streamedTarArchive is a tar archive read from a stream, which can only be walked once. Archives containing an
+// install manifest aren't extracted, as the manifest has to be verified before anything is extracted.
+type streamedTarArchive struct {
+	r          io.Reader
+	decompress func(io.Reader) (io.ReadCloser, error)
+}
+
+func (a *streamedTarArchive) walk(fn func(f *archiveFile) error) error {
+	r := a.r
+	if a.decompress != nil {
+		dr, err := a.decompress(r)
+		if err != nil {
+			return fmt.Errorf("failed to decompress archive: %w", err)
+		}
+		defer func() {
+			_ = dr.Close()
+		}()
+		r = dr
+	}
+	return walkTar(r, func(f *archiveFile) error {
+		if isInstallManifest(f.name) {
+			return fmt.Errorf("%w: it contains an install manifest", errStreamFallback)
+		}
+		return fn(f)
+	})
+}
+
+func (a *streamedTarArchive) Close() error {
+	return nil
+}
@@ -0,0 +1,121 @@
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamExtraction(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"}}`
+	serve := func(t *testing.T, archivePath string) string {
+		t.Helper()
+		data, err := ioutil.ReadFile(archivePath)
+		require.NoError(t, err)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(data)
+		}))
+		t.Cleanup(server.Close)
+		return server.URL + "/plugin"
+	}
+	newPending := func(downloadURL, checksum string) *pendingInstall {
+		return &pendingInstall{pluginID: "test-panel", downloadURLs: []string{downloadURL}, checksum: checksum,
+			event: &AuditEvent{}}
+	}
+	newInstaller := func(opts Opts) *Installer {
+		opts.StreamExtraction = true
+		opts.AdvisoryPolicy = AdvisoryPolicyIgnore
+		return NewWithOpts(opts, "8.0.0", &fakeLogger{})
+	}
+
+	t.Run("Should extract tar archives while downloading them", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+		sum, err := plugins.FileSHA256(archive)
+		require.NoError(t, err)
+		pluginsDir := t.TempDir()
+
+		p := newPending(serve(t, archive), sum)
+		require.NoError(t, newInstaller(Opts{}).fetchArchive(p, pluginsDir))
+		defer p.discard(&fakeLogger{})
+		assert.Empty(t, p.archivePath)
+		assert.FileExists(t, filepath.Join(p.stagingDir, "test-panel", "plugin.json"))
+		assert.Equal(t, sum, p.archiveSHA256)
+	})
+
+	t.Run("Should install and lock plugins extracted while downloading them", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+		pluginsDir := t.TempDir()
+		lockfilePath := filepath.Join(t.TempDir(), "plugins.lock")
+
+		i := newInstaller(Opts{AllowInsecureHTTP: true, LockfilePath: lockfilePath})
+		require.NoError(t, i.Install("test-panel", "", pluginsDir, serve(t, archive), ""))
+		assert.FileExists(t, filepath.Join(pluginsDir, "test-panel", "plugin.json"))
+		assertQuarantineEmpty(t, pluginsDir)
+
+		sum, err := plugins.FileSHA256(archive)
+		require.NoError(t, err)
+		l, err := ReadLockfile(lockfilePath)
+		require.NoError(t, err)
+		require.Len(t, l.Plugins, 1)
+		assert.Equal(t, "sha256:"+sum, l.Plugins[0].Checksum)
+	})
+
+	t.Run("Should remove the extracted plugin if the checksum doesn't match", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+		pluginsDir := t.TempDir()
+
+		p := newPending(serve(t, archive), "0000000000000000000000000000000000000000000000000000000000000000")
+		err := newInstaller(Opts{}).fetchArchive(p, pluginsDir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum does not match")
+		assert.Empty(t, p.stagingDir)
+		assertQuarantineEmpty(t, pluginsDir)
+	})
+
+	t.Run("Should download zip archives and archives with install manifest to a temporary file", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		zw := zip.NewWriter(buf)
+		w, err := zw.Create("test-panel/plugin.json")
+		require.NoError(t, err)
+		_, err = w.Write([]byte(pluginJSON))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		zipArchive := filepath.Join(t.TempDir(), "plugin.zip")
+		require.NoError(t, ioutil.WriteFile(zipArchive, buf.Bytes(), 0600))
+
+		for _, archive := range []string{
+			zipArchive,
+			writeTestTarGz(t, []byte("manifest"), map[string]string{"plugin.json": pluginJSON}),
+		} {
+			pluginsDir := t.TempDir()
+			p := newPending(serve(t, archive), "")
+			require.NoError(t, newInstaller(Opts{}).fetchArchive(p, pluginsDir))
+			assert.Empty(t, p.stagingDir)
+			assert.FileExists(t, p.archivePath)
+			// The archive is downloaded next to the plugins, but not extracted
+			assert.Equal(t, quarantineDir(pluginsDir), filepath.Dir(p.archivePath))
+			staged, _ := ioutil.ReadDir(quarantineDir(pluginsDir))
+			assert.Len(t, staged, 1)
+			p.discard(&fakeLogger{})
+		}
+	})
+
+	t.Run("Should not stream archives if verifying them needs the whole archive", func(t *testing.T) {
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+
+		p := newPending(serve(t, archive), "")
+		i := newInstaller(Opts{SumFilePath: filepath.Join(t.TempDir(), "SHA256SUMS")})
+		require.NoError(t, i.fetchArchive(p, t.TempDir()))
+		defer p.discard(&fakeLogger{})
+		assert.Empty(t, p.stagingDir)
+		assert.FileExists(t, p.archivePath)
+	})
+}
@@ -0,0 +1,136 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// SumFileError is returned when a plugin archive isn't pinned by the sum file or doesn't match its pinned
+// checksum.
+type SumFileError struct {
+	PluginID string
+	Version  string
+	Reason   string
+}
+
+func (e *SumFileError) Error() string {
+	return fmt.Sprintf("%s v%s %s", e.PluginID, e.Version, e.Reason)
+}
+
+// parseSumFile parses a sum file with one "<plugin id> <version> <checksum>" entry per line, just like go.sum.
+// Checksums are hex encoded SHA256 checksums or prefixed with their algorithm, e.g. sha512:<checksum>. Empty
+// lines and lines starting with # are ignored.
+func parseSumFile(body []byte) (map[string]archiveChecksum, error) {
+	sums := make(map[string]archiveChecksum)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected <plugin id> <version> <checksum>", n)
+		}
+		c, err := parseChecksum(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		key := sumFileKey(fields[0], fields[1])
+		if existing, exists := sums[key]; exists && existing != c {
+			return nil, fmt.Errorf("line %d: conflicting checksums for %s %s", n, fields[0], fields[1])
+		}
+		sums[key] = c
+	}
+	return sums, scanner.Err()
+}
+
+func sumFileKey(pluginID, version string) string {
+	return pluginID + " " + strings.TrimPrefix(version, "v")
+}
+
+// readSumFile reads the sum file. If a sum file keyring is configured, the sum file has to have a detached
+// OpenPGP signature at <path>.asc made by one of its keys.
+func (i *Installer) readSumFile() (map[string]archiveChecksum, error) {
+	// nolint:gosec
+	body, err := ioutil.ReadFile(i.opts.SumFilePath)
+	if err != nil {
+		return nil, errutil.Wrap("failed to read sum file", err)
+	}
+
+	if i.opts.SumFileKeyringPath != "" {
+		keyring, err := readKeyring(i.opts.SumFileKeyringPath)
+		if err != nil {
+			return nil, errutil.Wrap("failed to read sum file keyring", err)
+		}
+		// nolint:gosec
+		signature, err := ioutil.ReadFile(i.opts.SumFilePath + signatureFileSuffix)
+		if err != nil {
+			return nil, errutil.Wrap("failed to read sum file signature", err)
+		}
+		if block, err := armor.Decode(bytes.NewReader(signature)); err == nil {
+			if signature, err = ioutil.ReadAll(block.Body); err != nil {
+				return nil, errutil.Wrap("failed to decode sum file signature", err)
+			}
+		}
+		if err := i.checkFIPSSignature(signature); err != nil {
+			return nil, errutil.Wrap("invalid sum file signature", err)
+		}
+		if _, err := openpgp.CheckDetachedSignature(keyring, bytes.NewReader(body),
+			bytes.NewReader(signature)); err != nil {
+			return nil, errutil.Wrap("sum file signature verification failed", err)
+		}
+	}
+
+	sums, err := parseSumFile(body)
+	if err != nil {
+		return nil, errutil.Wrapf(err, "invalid sum file %s", i.opts.SumFilePath)
+	}
+	return sums, nil
+}
+
+// verifySumFile verifies the downloaded archive against the checksum the sum file pins for the plugin version.
+// Plugin versions missing from the sum file are refused. It does nothing if no sum file is configured.
+func (i *Installer) verifySumFile(archivePath, pluginID, version string) error {
+	if i.opts.SumFilePath == "" {
+		return nil
+	}
+	sums, err := i.readSumFile()
+	if err != nil {
+		return err
+	}
+	expected, exists := sums[sumFileKey(pluginID, version)]
+	if !exists {
+		return &SumFileError{PluginID: pluginID, Version: version, Reason: "is missing from the sum file"}
+	}
+
+	// nolint:gosec
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			i.log.Warn("Failed to close file", "err", err)
+		}
+	}()
+	h := expected.newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if !expected.matches(h) {
+		return &SumFileError{PluginID: pluginID, Version: version,
+			Reason: fmt.Sprintf("doesn't match its %s checksum in the sum file", expected.name())}
+	}
+	i.log.Debugf("Verified %s v%s against the sum file", pluginID, version)
+	return nil
+}
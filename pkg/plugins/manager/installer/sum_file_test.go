@@ -0,0 +1,84 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestSumFile(t *testing.T) {
+	const pluginJSON = `{"id":"test-panel","name":"Test","type":"panel","info":{"version":"1.0.0"}}`
+	archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": pluginJSON})
+	data, err := ioutil.ReadFile(archive)
+	require.NoError(t, err)
+	digest := sha256.Sum256(data)
+	checksum := hex.EncodeToString(digest[:])
+
+	writeSumFile := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "plugins.sum")
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	t.Run("Should install pinned plugin", func(t *testing.T) {
+		sumFile := writeSumFile(t, fmt.Sprintf("# pinned plugins\n\ntest-panel 1.0.0 %s\n", checksum))
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, SumFilePath: sumFile}}
+
+		require.NoError(t, i.Install("test-panel", "", t.TempDir(), archive, ""))
+	})
+
+	t.Run("Should refuse plugins that aren't pinned or don't match", func(t *testing.T) {
+		for _, content := range []string{
+			fmt.Sprintf("test-panel 1.0.1 %s\n", checksum),
+			fmt.Sprintf("test-panel 1.0.0 %x\n", sha256.Sum256([]byte("other"))),
+		} {
+			sumFile := writeSumFile(t, content)
+			i := &Installer{log: &fakeLogger{}, opts: Opts{AdvisoryPolicy: AdvisoryPolicyIgnore, SumFilePath: sumFile}}
+			pluginsDir := t.TempDir()
+
+			var sumErr *SumFileError
+			require.ErrorAs(t, i.Install("test-panel", "", pluginsDir, archive, ""), &sumErr)
+			assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+		}
+	})
+
+	t.Run("Should verify sum file signature", func(t *testing.T) {
+		i, entity := newManifestTestInstaller(t)
+		i.opts.AdvisoryPolicy = AdvisoryPolicyIgnore
+		i.opts.SumFileKeyringPath = i.opts.ManifestKeyringPath
+		content := fmt.Sprintf("test-panel 1.0.0 %s\n", checksum)
+		i.opts.SumFilePath = writeSumFile(t, content)
+
+		require.Error(t, i.Install("test-panel", "", t.TempDir(), archive, ""))
+
+		sig := new(bytes.Buffer)
+		require.NoError(t, openpgp.ArmoredDetachSign(sig, entity, bytes.NewBufferString(content), nil))
+		require.NoError(t, ioutil.WriteFile(i.opts.SumFilePath+signatureFileSuffix, sig.Bytes(), 0600))
+		require.NoError(t, i.Install("test-panel", "", t.TempDir(), archive, ""))
+
+		require.NoError(t, ioutil.WriteFile(i.opts.SumFilePath, []byte(content+"other 1.0.0 "+checksum), 0600))
+		err := i.Install("test-panel", "", t.TempDir(), archive, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "signature")
+	})
+
+	t.Run("Should reject malformed sum files", func(t *testing.T) {
+		for _, content := range []string{
+			"test-panel 1.0.0",
+			"test-panel 1.0.0 notachecksum",
+			fmt.Sprintf("test-panel 1.0.0 %s\ntest-panel v1.0.0 sha512:%x", checksum, make([]byte, 64)),
+		} {
+			_, err := parseSumFile([]byte(content))
+			require.Error(t, err, content)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package installer
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// maxThrottleBurst bounds the bytes a throttled download may read at once, so that high limits don't let a download
+// burst for a full second.
+const maxThrottleBurst = 256 << 10
+
+// newDownloadLimiter returns the limiter shared by all downloads of the installer, nil if they're not throttled.
+func newDownloadLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	burst := bytesPerSecond
+	if burst > maxThrottleBurst {
+		burst = maxThrottleBurst
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(burst))
+}
+
+// throttledReader returns r reading no faster than the download rate limit of the installer.
+func (i *Installer) throttledReader(r io.Reader) io.Reader {
+	if i.downloadLimiter == nil {
+		return r
+	}
+	return &throttledReader{r: r, limiter: i.downloadLimiter}
+}
+
+// throttledReader waits for the limiter after every read, so that the bytes read over time don't exceed its rate.
+type throttledReader struct {
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if burst := t.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if waitErr := t.limiter.WaitN(context.Background(), n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
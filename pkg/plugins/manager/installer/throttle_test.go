@@ -0,0 +1,39 @@
+package installer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottledReader(t *testing.T) {
+	t.Run("Should not throttle downloads without rate limit", func(t *testing.T) {
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+		r := bytes.NewReader(nil)
+		assert.Equal(t, r, i.throttledReader(r))
+	})
+
+	t.Run("Should read no faster than the rate limit", func(t *testing.T) {
+		i := NewWithOpts(Opts{DownloadTransport: TransportOpts{RateLimit: 64 << 10}}, "8.0.0", &fakeLogger{})
+		data := bytes.Repeat([]byte("a"), 128<<10)
+
+		start := time.Now()
+		n, err := io.Copy(ioutil.Discard, i.throttledReader(bytes.NewReader(data)))
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(data)), n)
+		// The first 64KiB are the burst of the limiter, the rest takes a second
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(900*time.Millisecond))
+	})
+
+	t.Run("Should cap the burst and share the limiter between downloads", func(t *testing.T) {
+		i := NewWithOpts(Opts{DownloadTransport: TransportOpts{RateLimit: 1 << 30}}, "8.0.0", &fakeLogger{})
+		assert.Equal(t, maxThrottleBurst, i.downloadLimiter.Burst())
+		assert.Same(t, i.throttledReader(nil).(*throttledReader).limiter,
+			i.throttledReader(nil).(*throttledReader).limiter)
+	})
+}
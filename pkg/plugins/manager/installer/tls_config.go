@@ -0,0 +1,93 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// pinPrefix is the prefix of SPKI pins, as used by HPKP and curl's --pinnedpubkey.
+const pinPrefix = "sha256/"
+
+// ParsePinnedKeys parses host=pin pairs into pinned keys per host. Pins are the base64 encoded SHA256 digest of
+// a certificate's subject public key info, optionally prefixed with sha256/. A host can have several pins.
+func ParsePinnedKeys(pairs []string) (map[string][]string, error) {
+	pins := map[string][]string{}
+	for _, pair := range pairs {
+		idx := strings.Index(pair, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid pinned key %q, expected host=sha256/<base64 digest>", pair)
+		}
+		host, pin := strings.ToLower(strings.TrimSpace(pair[:idx])), strings.TrimSpace(pair[idx+1:])
+		digest, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, pinPrefix))
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid pinned key %q of %s, expected sha256/<base64 digest>", pin, host)
+		}
+		pins[host] = append(pins[host], base64.StdEncoding.EncodeToString(digest))
+	}
+	return pins, nil
+}
+
+// makeTLSConfig returns the TLS configuration for requests, trusting the CA bundle in addition to the system's
+// roots if set, presenting the client certificate if set, and verifying the pinned keys of hosts. In FIPS mode,
+// only FIPS approved ciphers are negotiated.
+func makeTLSConfig(skipTLSVerify bool, caCertPath, clientCertPath, clientKeyPath string,
+	pinnedKeys map[string][]string, fips bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: skipTLSVerify,
+	}
+	if fips {
+		applyFIPSTLS(tlsConfig)
+	}
+	if caCertPath != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		// nolint:gosec
+		pem, err := ioutil.ReadFile(caCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", caCertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if clientCertPath != "" || clientKeyPath != "" {
+		loader, err := newClientCertLoader(clientCertPath, clientKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = loader.getClientCertificate
+	}
+	if len(pinnedKeys) > 0 {
+		tlsConfig.VerifyConnection = verifyPinnedKeys(pinnedKeys)
+	}
+	return tlsConfig, nil
+}
+
+// verifyPinnedKeys returns a connection verifier requiring that a certificate presented by hosts with pinned keys
+// has one of the pinned subject public keys. Connections to other hosts aren't restricted.
+func verifyPinnedKeys(pinnedKeys map[string][]string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		pins, exists := pinnedKeys[strings.ToLower(cs.ServerName)]
+		if !exists {
+			return nil
+		}
+		for _, cert := range cs.PeerCertificates {
+			digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			fingerprint := base64.StdEncoding.EncodeToString(digest[:])
+			for _, pin := range pins {
+				if strings.TrimPrefix(pin, pinPrefix) == fingerprint {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("certificate of %s doesn't match any of its pinned keys", cs.ServerName)
+	}
+}
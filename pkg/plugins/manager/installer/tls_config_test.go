@@ -0,0 +1,70 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caBundle, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0600))
+	digest := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := "sha256/" + base64.StdEncoding.EncodeToString(digest[:])
+
+	t.Run("Should trust CA bundle", func(t *testing.T) {
+		_, err := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}).sendRequestGetBytes(server.URL)
+		require.Error(t, err)
+
+		body, err := NewWithOpts(Opts{CACertPath: caBundle}, "8.0.0", &fakeLogger{}).sendRequestGetBytes(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", string(body))
+	})
+
+	t.Run("Should fail for invalid CA bundle", func(t *testing.T) {
+		i := NewWithOpts(Opts{CACertPath: filepath.Join(t.TempDir(), "missing.pem")}, "8.0.0", &fakeLogger{})
+		_, err := i.sendRequestGetBytes(server.URL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "CA bundle")
+	})
+
+	t.Run("Should verify pinned keys", func(t *testing.T) {
+		cs := tls.ConnectionState{ServerName: "example.com", PeerCertificates: []*x509.Certificate{server.Certificate()}}
+		pins, err := ParsePinnedKeys([]string{"example.com=" + pin})
+		require.NoError(t, err)
+		require.NoError(t, verifyPinnedKeys(pins)(cs))
+
+		other := sha256.Sum256([]byte("other key"))
+		pins, err = ParsePinnedKeys([]string{"EXAMPLE.com=" + base64.StdEncoding.EncodeToString(other[:])})
+		require.NoError(t, err)
+		err = verifyPinnedKeys(pins)(cs)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "pinned keys")
+
+		require.NoError(t, verifyPinnedKeys(map[string][]string{"grafana.com": {pin}})(cs))
+	})
+
+	t.Run("Should reject invalid pins", func(t *testing.T) {
+		for _, pair := range []string{"grafana.com", "=" + pin, "grafana.com=sha256/invalid"} {
+			_, err := ParsePinnedKeys([]string{pair})
+			require.Error(t, err, pair)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package installer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// TransportOpts tunes the HTTP transport of plugin downloads, e.g. for pulling large archives over high latency
+// links. The zero value keeps the defaults of the transport of plugin repository requests.
+type TransportOpts struct {
+	// HTTP2 attempts HTTP/2 for downloads over TLS, which otherwise use HTTP/1.1.
+	HTTP2 bool
+	// MaxConnsPerHost limits the connections to a download host, including ones being dialed. 0 means no limit.
+	MaxConnsPerHost int
+	// ReadBufferSize is the size of the buffer used when reading from connections. Defaults to 4KB.
+	ReadBufferSize int
+	// WriteBufferSize is the size of the buffer used when writing to connections. Defaults to 4KB.
+	WriteBufferSize int
+	// KeepAlive is the interval of TCP keep-alive probes of download connections. Defaults to 30s, -1 disables
+	// them.
+	KeepAlive time.Duration
+	// Timeout bounds the whole download, including reading the archive. Defaults to no timeout, as large archives
+	// take long to download over slow links, use IdleReadTimeout to fail stalled downloads instead.
+	Timeout time.Duration
+	// ConnectTimeout bounds establishing the TCP connection. Defaults to 30s, -1 disables it.
+	ConnectTimeout time.Duration
+	// TLSHandshakeTimeout bounds the TLS handshake. Defaults to 10s, -1 disables it.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout bounds waiting for the response headers after sending the request. Defaults to 30s,
+	// -1 disables it.
+	ResponseHeaderTimeout time.Duration
+	// IdleReadTimeout fails a download once no data was received for this long, e.g. because the connection
+	// stalled. Defaults to 1m, -1 disables it.
+	IdleReadTimeout time.Duration
+	// RateLimit is the maximum number of bytes per second all downloads of the installer read together, e.g. so
+	// that background plugin updates don't compete with query traffic. 0 means no limit.
+	RateLimit int64
+	// MaxConcurrentDownloads is the maximum number of archives the installer downloads at the same time, including
+	// dependencies. Defaults to 4.
+	MaxConcurrentDownloads int
+}
+
+// makeDownloadClient returns the client plugin archives are downloaded with, whose transport is tuned with the
+// options.
+func makeDownloadClient(tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error),
+	proxyToken func(*url.URL) string, dns DNSOpts, opts TransportOpts) http.Client {
+	// The HTTP/2 transport adds its protocol to the TLS config, which mustn't leak into the config of the
+	// repository requests' transport
+	client := makeHttpClientWithTLS(tlsConfig.Clone(), proxy, proxyToken, dns, transportTimeout(opts.Timeout, 0))
+	tr := httpTransport(client.Transport)
+
+	keepAlive := 30 * time.Second
+	if opts.KeepAlive != 0 {
+		keepAlive = opts.KeepAlive
+	}
+	tr.DialContext = dns.dialContext(&net.Dialer{
+		Timeout:   transportTimeout(opts.ConnectTimeout, 30*time.Second),
+		KeepAlive: keepAlive,
+	})
+	tr.TLSHandshakeTimeout = transportTimeout(opts.TLSHandshakeTimeout, 10*time.Second)
+	tr.ResponseHeaderTimeout = transportTimeout(opts.ResponseHeaderTimeout, 30*time.Second)
+	// Content encodings are decoded explicitly, see decodeContent
+	tr.DisableCompression = true
+	tr.ForceAttemptHTTP2 = opts.HTTP2
+	tr.MaxConnsPerHost = opts.MaxConnsPerHost
+	tr.ReadBufferSize = opts.ReadBufferSize
+	tr.WriteBufferSize = opts.WriteBufferSize
+	return client
+}
+
+// transportTimeout returns the timeout, the default if it's not set or no timeout if it's negative.
+func transportTimeout(timeout, defaultTimeout time.Duration) time.Duration {
+	switch {
+	case timeout == 0:
+		return defaultTimeout
+	case timeout < 0:
+		return 0
+	default:
+		return timeout
+	}
+}
+
+// IdleTimeoutError is returned when a download receives no data for longer than the idle read timeout. It's a
+// net.Error, so that the download fails over to the next mirror.
+type IdleTimeoutError struct {
+	Idle time.Duration
+}
+
+func (e *IdleTimeoutError) Error() string {
+	return fmt.Sprintf("download stalled, no data received for %s", e.Idle)
+}
+
+func (e *IdleTimeoutError) Timeout() bool   { return true }
+func (e *IdleTimeoutError) Temporary() bool { return true }
+
+// idleTimeoutBody closes the response body once no data was read from it for longer than the idle read timeout,
+// failing the pending read with an IdleTimeoutError.
+func (i *Installer) idleTimeoutBody(body io.ReadCloser) io.ReadCloser {
+	timeout := transportTimeout(i.opts.DownloadTransport.IdleReadTimeout, time.Minute)
+	if timeout == 0 {
+		return body
+	}
+	r := &idleTimeoutReader{body: body, timeout: timeout}
+	r.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&r.expired, 1)
+		_ = body.Close()
+	})
+	return r
+}
+
+type idleTimeoutReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired int32
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if atomic.LoadInt32(&r.expired) == 1 {
+		return n, &IdleTimeoutError{Idle: r.timeout}
+	}
+	r.timer.Reset(r.timeout)
+	return n, err
+}
+
+func (r *idleTimeoutReader) Close() error {
+	r.timer.Stop()
+	return r.body.Close()
+}
@@ -0,0 +1,115 @@
+package installer
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadTransport(t *testing.T) {
+	newServer := func(t *testing.T) *httptest.Server {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Proto))
+		}))
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	t.Run("Should tune download transport", func(t *testing.T) {
+		i := NewWithOpts(Opts{DownloadTransport: TransportOpts{
+			HTTP2:           true,
+			MaxConnsPerHost: 4,
+			ReadBufferSize:  1 << 20,
+			WriteBufferSize: 64 << 10,
+			KeepAlive:       -1,
+		}}, "8.0.0", &fakeLogger{})
+
+		tr := i.httpClientNoTimeout.Transport.(*http.Transport)
+		assert.True(t, tr.ForceAttemptHTTP2)
+		assert.Equal(t, 4, tr.MaxConnsPerHost)
+		assert.Equal(t, 1<<20, tr.ReadBufferSize)
+		assert.Equal(t, 64<<10, tr.WriteBufferSize)
+
+		tr = i.httpClient.Transport.(*http.Transport)
+		assert.False(t, tr.ForceAttemptHTTP2)
+		assert.Zero(t, tr.MaxConnsPerHost)
+	})
+
+	t.Run("Should download over HTTP/2 only if enabled", func(t *testing.T) {
+		server := newServer(t)
+
+		i := NewWithOpts(Opts{SkipTLSVerify: true, DownloadTransport: TransportOpts{HTTP2: true}}, "8.0.0",
+			&fakeLogger{})
+		body, err := i.sendRequestWithoutTimeout(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/2.0", readAll(t, body))
+
+		body, err = i.sendRequest(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1", readAll(t, body))
+
+		i = NewWithOpts(Opts{SkipTLSVerify: true, DownloadTransport: TransportOpts{KeepAlive: time.Minute}},
+			"8.0.0", &fakeLogger{})
+		body, err = i.sendRequestWithoutTimeout(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1", readAll(t, body))
+	})
+}
+
+func TestDownloadTimeouts(t *testing.T) {
+	t.Run("Should default to granular timeouts without total timeout", func(t *testing.T) {
+		i := NewWithOpts(Opts{}, "8.0.0", &fakeLogger{})
+		assert.Zero(t, i.httpClientNoTimeout.Timeout)
+		tr := i.httpClientNoTimeout.Transport.(*http.Transport)
+		assert.Equal(t, 10*time.Second, tr.TLSHandshakeTimeout)
+		assert.Equal(t, 30*time.Second, tr.ResponseHeaderTimeout)
+
+		i = NewWithOpts(Opts{DownloadTransport: TransportOpts{Timeout: time.Hour, TLSHandshakeTimeout: -1,
+			ResponseHeaderTimeout: time.Minute}}, "8.0.0", &fakeLogger{})
+		assert.Equal(t, time.Hour, i.httpClientNoTimeout.Timeout)
+		tr = i.httpClientNoTimeout.Transport.(*http.Transport)
+		assert.Zero(t, tr.TLSHandshakeTimeout)
+		assert.Equal(t, time.Minute, tr.ResponseHeaderTimeout)
+	})
+
+	t.Run("Should fail downloads that stall", func(t *testing.T) {
+		stalled := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "1024")
+			_, _ = w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			<-stalled
+		}))
+		t.Cleanup(server.Close)
+		t.Cleanup(func() { close(stalled) })
+
+		i := NewWithOpts(Opts{DownloadTransport: TransportOpts{IdleReadTimeout: 100 * time.Millisecond}}, "8.0.0",
+			&fakeLogger{})
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = tmpFile.Close() })
+
+		err = i.downloadFile("test-panel", tmpFile, server.URL, "", 0)
+		var idleErr *IdleTimeoutError
+		require.True(t, errors.As(err, &idleErr), "unexpected error %v", err)
+		assert.Equal(t, 100*time.Millisecond, idleErr.Idle)
+		assert.True(t, isMirrorFailure(err))
+	})
+}
+
+func readAll(t *testing.T, r io.ReadCloser) string {
+	t.Helper()
+	defer func() { require.NoError(t, r.Close()) }()
+	b, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	return string(b)
+}
@@ -0,0 +1,122 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/md5"  // nolint:gosec
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// TruncatedDownloadError is returned when the connection ends before the whole response announced by its
+// Content-Length is received.
+type TruncatedDownloadError struct {
+	Received int64
+	// Expected is the Content-Length of the response.
+	Expected int64
+}
+
+func (e *TruncatedDownloadError) Error() string {
+	return fmt.Sprintf("truncated download: received %d of %d bytes", e.Received, e.Expected)
+}
+
+// ContentDigestError is returned when a downloaded file doesn't match the digest the server sent along in its
+// response headers, i.e. it was corrupted in transit.
+type ContentDigestError struct {
+	Header string
+}
+
+func (e *ContentDigestError) Error() string {
+	return fmt.Sprintf("corrupt download: the checksum does not match the %s header of the response", e.Header)
+}
+
+// contentDigest is a digest of the response body sent in a header.
+type contentDigest struct {
+	header   string
+	expected []byte
+	newHash  func() hash.Hash
+}
+
+// responseDigest returns the strongest digest of the response body in its headers: the X-Checksum-Sha256,
+// X-Checksum-Sha1 and X-Checksum-Md5 headers of Artifactory and other artifact repositories, or Content-MD5. It
+// returns false if there is none, the body was decompressed by the transport, which invalidates them, or the body is
+// only part of the content, which they don't cover. In FIPS mode, only the FIPS approved X-Checksum-Sha256 is used.
+func responseDigest(res *http.Response, fips bool) (contentDigest, bool) {
+	if res.Uncompressed || res.StatusCode == http.StatusPartialContent {
+		return contentDigest{}, false
+	}
+	for _, d := range []struct {
+		header      string
+		base64      bool
+		newHash     func() hash.Hash
+		fipsAllowed bool
+	}{
+		{header: "X-Checksum-Sha256", newHash: sha256.New, fipsAllowed: true},
+		{header: "X-Checksum-Sha1", newHash: sha1.New},
+		{header: "X-Checksum-Md5", newHash: md5.New},
+		{header: "Content-MD5", base64: true, newHash: md5.New},
+	} {
+		value := strings.TrimSpace(res.Header.Get(d.header))
+		if value == "" || (fips && !d.fipsAllowed) {
+			continue
+		}
+		var expected []byte
+		var err error
+		if d.base64 {
+			expected, err = base64.StdEncoding.DecodeString(value)
+		} else {
+			expected, err = hex.DecodeString(value)
+		}
+		if err != nil || len(expected) != d.newHash().Size() {
+			continue
+		}
+		return contentDigest{header: d.header, expected: expected, newHash: d.newHash}, true
+	}
+	return contentDigest{}, false
+}
+
+// verifiedBody fails reading a response body with a TruncatedDownloadError if the connection ends before its
+// Content-Length is received, and with a ContentDigestError if it doesn't match the digest of its headers, instead
+// of passing a short or corrupt file on to extraction.
+type verifiedBody struct {
+	io.ReadCloser
+	received      int64
+	contentLength int64
+	digest        contentDigest
+	h             hash.Hash
+}
+
+// verifyBody returns the body of the response, verified against its Content-Length and digest headers.
+func verifyBody(res *http.Response, body io.ReadCloser, fips bool) io.ReadCloser {
+	b := &verifiedBody{ReadCloser: body, contentLength: res.ContentLength}
+	if digest, ok := responseDigest(res, fips); ok {
+		b.digest, b.h = digest, digest.newHash()
+	}
+	return b
+}
+
+func (b *verifiedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.received += int64(n)
+	if b.h != nil {
+		_, _ = b.h.Write(p[:n])
+	}
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF) && b.contentLength >= 0:
+		return n, &TruncatedDownloadError{Received: b.received, Expected: b.contentLength}
+	case err != io.EOF:
+		return n, err
+	case b.contentLength >= 0 && b.received < b.contentLength:
+		return n, &TruncatedDownloadError{Received: b.received, Expected: b.contentLength}
+	case b.h != nil && !bytes.Equal(b.h.Sum(nil), b.digest.expected):
+		return n, &ContentDigestError{Header: b.digest.header}
+	}
+	return n, err
+}
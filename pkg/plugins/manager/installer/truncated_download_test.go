@@ -0,0 +1,114 @@
+package installer
+
+import (
+	"crypto/md5" // nolint:gosec
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncatedDownloads(t *testing.T) {
+	archive := []byte("plugin archive")
+	// newTruncatingServer announces the whole archive but closes the connection after sending half of it.
+	newTruncatingServer := func(t *testing.T) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			defer func() { _ = conn.Close() }()
+			_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 14\r\n\r\n")
+			_, _ = buf.Write(archive[:7])
+			_ = buf.Flush()
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	newServer := func(t *testing.T, header, value string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(header, value)
+			_, _ = w.Write(archive)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	download := func(t *testing.T, i *Installer, downloadURLs ...string) ([]byte, error) {
+		t.Helper()
+		tmpFile, err := ioutil.TempFile(t.TempDir(), "plugin")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tmpFile.Close()) }()
+		if _, _, err := i.downloadFromMirrors("test-panel", tmpFile, downloadURLs, ""); err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadFile(tmpFile.Name())
+		require.NoError(t, err)
+		return data, nil
+	}
+	md5Sum := md5.Sum(archive) // nolint:gosec
+
+	t.Run("Should fail with a truncated download error if the connection ends early", func(t *testing.T) {
+		server := newTruncatingServer(t)
+
+		_, err := download(t, NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}), server.URL)
+		var truncatedErr *TruncatedDownloadError
+		require.ErrorAs(t, err, &truncatedErr)
+		assert.Equal(t, &TruncatedDownloadError{Received: 7, Expected: 14}, truncatedErr)
+		assert.Contains(t, err.Error(), "truncated download")
+	})
+
+	t.Run("Should download truncated archives from the next mirror", func(t *testing.T) {
+		truncating := newTruncatingServer(t)
+		server := newServer(t, "Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+
+		data, err := download(t, NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}), truncating.URL, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, archive, data)
+	})
+
+	t.Run("Should fail if the archive doesn't match its digest headers", func(t *testing.T) {
+		otherSum := md5.Sum([]byte("other archive")) // nolint:gosec
+		for header, value := range map[string]string{
+			"Content-MD5":       base64.StdEncoding.EncodeToString(otherSum[:]),
+			"X-Checksum-Md5":    "9e107d9d372bb6826bd81d3542a419d6",
+			"X-Checksum-Sha1":   "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12",
+			"X-Checksum-Sha256": "d7a8fbb307d7809469ca9abcb0082e4f8d5651e46d3cdb762d02d0bf37c9e592",
+		} {
+			server := newServer(t, header, value)
+
+			_, err := download(t, NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}), server.URL)
+			var digestErr *ContentDigestError
+			require.ErrorAs(t, err, &digestErr, header)
+			assert.Equal(t, header, digestErr.Header)
+		}
+	})
+
+	t.Run("Should ignore invalid digest headers", func(t *testing.T) {
+		server := newServer(t, "Content-MD5", "not a digest")
+
+		data, err := download(t, NewWithOpts(Opts{}, "8.0.0", &fakeLogger{}), server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, archive, data)
+	})
+	t.Run("Should only use FIPS approved digest headers in FIPS mode", func(t *testing.T) {
+		i := NewWithOpts(Opts{FIPSMode: true}, "8.0.0", &fakeLogger{})
+		for header, value := range map[string]string{
+			"Content-MD5":     base64.StdEncoding.EncodeToString(md5Sum[:]),
+			"X-Checksum-Md5":  "9e107d9d372bb6826bd81d3542a419d6",
+			"X-Checksum-Sha1": "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12",
+		} {
+			server := newServer(t, header, value)
+
+			data, err := download(t, i, server.URL)
+			require.NoError(t, err, header)
+			assert.Equal(t, archive, data)
+		}
+
+		server := newServer(t, "X-Checksum-Sha256", "d7a8fbb307d7809469ca9abcb0082e4f8d5651e46d3cdb762d02d0bf37c9e592")
+		_, err := download(t, i, server.URL)
+		var digestErr *ContentDigestError
+		require.ErrorAs(t, err, &digestErr)
+	})
+}
@@ -0,0 +1,631 @@
+package installer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRootRotations bounds the number of root versions fetched when updating the trusted root.
+const maxRootRotations = 32
+
+// TUFOpts configures the verification of plugin repository metadata signed with The Update Framework. The
+// repository publishes root, timestamp, snapshot and targets metadata, whose targets are the metadata files of
+// the plugin repository, e.g. repo/<plugin id> for the grafana.com API or index.json for a static repository,
+// with their length and hashes. Starting from the pinned root, the installer follows root rotations and checks
+// the signature thresholds, versions and expiry of all roles before it trusts a metadata file of the repository.
+// Delegated targets aren't supported.
+type TUFOpts struct {
+	// RootPath is the path to the pinned root.json. Metadata is only verified if it's set. The versions of the
+	// verified metadata are saved next to it, see tufVersionsPath.
+	RootPath string
+	// RepoURL is the URL or directory of the plugin repository whose metadata is signed. Target names are paths
+	// relative to it. Metadata fetched from the repository mirrors is verified the same way.
+	RepoURL string
+	// MetadataURL is the URL or directory the TUF metadata is published at. Defaults to <RepoURL>/tuf.
+	MetadataURL string
+}
+
+func (o TUFOpts) enabled() bool {
+	return o.RootPath != ""
+}
+
+func (o TUFOpts) metadataURL() string {
+	if o.MetadataURL != "" {
+		return strings.TrimSuffix(o.MetadataURL, "/")
+	}
+	return strings.TrimSuffix(o.RepoURL, "/") + "/tuf"
+}
+
+// TUFError is returned when the TUF metadata of the plugin repository, or a metadata file of the repository,
+// can't be verified.
+type TUFError struct {
+	Reason string
+}
+
+func (e *TUFError) Error() string {
+	return fmt.Sprintf("TUF verification of plugin repository metadata failed: %s", e.Reason)
+}
+
+func tufErrorf(format string, args ...interface{}) error {
+	return &TUFError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// tufSigned is a signed TUF metadata file.
+type tufSigned struct {
+	Signed     json.RawMessage `json:"signed"`
+	Signatures []struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+}
+
+type tufKey struct {
+	KeyType string `json:"keytype"`
+	Scheme  string `json:"scheme"`
+	KeyVal  struct {
+		Public string `json:"public"`
+	} `json:"keyval"`
+}
+
+type tufRole struct {
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+type tufRoot struct {
+	Type               string             `json:"_type"`
+	Version            int                `json:"version"`
+	Expires            time.Time          `json:"expires"`
+	ConsistentSnapshot bool               `json:"consistent_snapshot"`
+	Keys               map[string]tufKey  `json:"keys"`
+	Roles              map[string]tufRole `json:"roles"`
+}
+
+type tufFileMeta struct {
+	Version int               `json:"version"`
+	Length  int64             `json:"length"`
+	Hashes  map[string]string `json:"hashes"`
+}
+
+// tufMetadata holds the fields of timestamp, snapshot and targets metadata.
+type tufMetadata struct {
+	Type    string                 `json:"_type"`
+	Version int                    `json:"version"`
+	Expires time.Time              `json:"expires"`
+	Meta    map[string]tufFileMeta `json:"meta"`
+	Targets map[string]tufFileMeta `json:"targets"`
+}
+
+// tufVersions are the versions of the metadata last verified against the pinned root. They're saved next to the
+// root, so that the repository can't roll its metadata back to older versions, e.g. to hide a plugin release.
+type tufVersions struct {
+	Root      int `json:"root"`
+	Timestamp int `json:"timestamp"`
+	Snapshot  int `json:"snapshot"`
+	Targets   int `json:"targets"`
+}
+
+// tufTrust holds the verified targets of the repository once they're loaded.
+type tufTrust struct {
+	mu      sync.Mutex
+	targets map[string]tufFileMeta
+}
+
+// verifyTUFTarget verifies metadata fetched from the plugin repository, or one of its mirrors, against the TUF
+// targets of the repository. Files outside of the repository aren't verified.
+func (i *Installer) verifyTUFTarget(fileURL string, body []byte) error {
+	opts := i.opts.TUF
+	if !opts.enabled() {
+		return nil
+	}
+	name, exists := "", false
+	for _, repoURL := range append([]string{opts.RepoURL}, i.opts.RepoMirrors...) {
+		if name, exists = tufTargetName(repoURL, fileURL); exists {
+			break
+		}
+	}
+	if !exists {
+		return nil
+	}
+
+	targets, err := i.tufTargets()
+	if err != nil {
+		return err
+	}
+	meta, exists := targets[name]
+	if !exists {
+		return tufErrorf("%s isn't a signed target", name)
+	}
+	if err := checkTUFFileMeta(body, meta); err != nil {
+		return tufErrorf("%s: %v", name, err)
+	}
+	i.log.Debugf("Verified %s against TUF targets", name)
+	return nil
+}
+
+// tufTargetName returns the name of the file as target of the repository, its path relative to the repository.
+func tufTargetName(repoURL, fileURL string) (string, bool) {
+	base := normalizeTUFLocation(repoURL)
+	if base == "" {
+		return "", false
+	}
+	file := normalizeTUFLocation(fileURL)
+	if !strings.HasPrefix(file, base+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(file, base+"/"), true
+}
+
+func normalizeTUFLocation(location string) string {
+	if u, err := url.Parse(location); err == nil && len(u.Scheme) > 1 {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = ""
+		return u.String()
+	}
+	if location == "" {
+		return ""
+	}
+	return strings.TrimSuffix(filepath.ToSlash(filepath.Clean(location)), "/")
+}
+
+// tufTargets returns the targets of the repository, updating the trusted root and verifying the timestamp,
+// snapshot and targets metadata when first called.
+func (i *Installer) tufTargets() (map[string]tufFileMeta, error) {
+	i.tuf.mu.Lock()
+	defer i.tuf.mu.Unlock()
+	if i.tuf.targets != nil {
+		return i.tuf.targets, nil
+	}
+
+	versionsPath := tufVersionsPath(i.opts.TUF.RootPath)
+	trusted, err := readTUFVersions(versionsPath)
+	if err != nil {
+		return nil, err
+	}
+	root, err := i.loadTUFRoot()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTUFVersion("root", root.Version, trusted.Root); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := i.fetchTUFMetadata(root, "timestamp", "timestamp.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTUFVersion("timestamp", timestamp.Version, trusted.Timestamp); err != nil {
+		return nil, err
+	}
+	snapshotMeta, exists := timestamp.Meta["snapshot.json"]
+	if !exists {
+		return nil, tufErrorf("timestamp doesn't list snapshot.json")
+	}
+
+	snapshot, err := i.fetchTUFMetadata(root, "snapshot", tufFileName(root, "snapshot", snapshotMeta), &snapshotMeta)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTUFVersion("snapshot", snapshot.Version, trusted.Snapshot); err != nil {
+		return nil, err
+	}
+	targetsMeta, exists := snapshot.Meta["targets.json"]
+	if !exists {
+		return nil, tufErrorf("snapshot doesn't list targets.json")
+	}
+
+	targets, err := i.fetchTUFMetadata(root, "targets", tufFileName(root, "targets", targetsMeta), &targetsMeta)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTUFVersion("targets", targets.Version, trusted.Targets); err != nil {
+		return nil, err
+	}
+
+	verified := tufVersions{Root: root.Version, Timestamp: timestamp.Version, Snapshot: snapshot.Version,
+		Targets: targets.Version}
+	if verified != trusted {
+		if err := writeTUFVersions(versionsPath, verified); err != nil {
+			i.log.Warn("Failed to save verified TUF metadata versions", "path", versionsPath, "err", err)
+		}
+	}
+	i.tuf.targets = targets.Targets
+	if i.tuf.targets == nil {
+		i.tuf.targets = map[string]tufFileMeta{}
+	}
+	return i.tuf.targets, nil
+}
+
+// tufVersionsPath returns the path of the versions last verified against the pinned root, e.g. root.versions.json
+// for root.json.
+func tufVersionsPath(rootPath string) string {
+	return strings.TrimSuffix(rootPath, filepath.Ext(rootPath)) + ".versions.json"
+}
+
+// readTUFVersions reads the versions last verified against the pinned root, which are all 0 if nothing has been
+// verified yet.
+func readTUFVersions(path string) (tufVersions, error) {
+	var versions tufVersions
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return versions, nil
+	}
+	if err != nil {
+		return versions, fmt.Errorf("failed to read verified TUF metadata versions: %w", err)
+	}
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return versions, fmt.Errorf("invalid verified TUF metadata versions %s: %w", path, err)
+	}
+	return versions, nil
+}
+
+func writeTUFVersions(path string, versions tufVersions) error {
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tuf-versions-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// checkTUFVersion rejects metadata of the role older than the version verified before.
+func checkTUFVersion(role string, version, trusted int) error {
+	if version < trusted {
+		return tufErrorf("%s version %d is lower than the last verified version %d", role, version, trusted)
+	}
+	return nil
+}
+
+// loadTUFRoot reads the pinned root and follows the root rotations published by the repository.
+func (i *Installer) loadTUFRoot() (*tufRoot, error) {
+	data, err := ioutil.ReadFile(i.opts.TUF.RootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pinned TUF root: %w", err)
+	}
+	root, err := i.parseTUFRoot(data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for n := 0; n < maxRootRotations; n++ {
+		name := fmt.Sprintf("%d.root.json", root.Version+1)
+		data, err := i.readTUFFile(name)
+		if errors.Is(err, ErrNotFoundError) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		next, err := i.parseTUFRoot(data, root)
+		if err != nil {
+			return nil, err
+		}
+		i.log.Debugf("Rotated TUF root to version %d", next.Version)
+		root = next
+	}
+
+	if time.Now().After(root.Expires) {
+		return nil, tufErrorf("root expired at %s", root.Expires.Format(time.RFC3339))
+	}
+	return root, nil
+}
+
+// parseTUFRoot parses and verifies root metadata, which has to be signed by the threshold of its own root keys
+// and, when rotating from a trusted root, by the threshold of the trusted root's keys.
+func (i *Installer) parseTUFRoot(data []byte, trusted *tufRoot) (*tufRoot, error) {
+	var signed tufSigned
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, tufErrorf("invalid root: %v", err)
+	}
+	var root tufRoot
+	if err := json.Unmarshal(signed.Signed, &root); err != nil {
+		return nil, tufErrorf("invalid root: %v", err)
+	}
+	if root.Type != "root" {
+		return nil, tufErrorf("expected root metadata, got %q", root.Type)
+	}
+	if trusted != nil {
+		if root.Version != trusted.Version+1 {
+			return nil, tufErrorf("expected root version %d, got %d", trusted.Version+1, root.Version)
+		}
+		if err := i.verifyTUFSignatures(&signed, trusted, "root"); err != nil {
+			return nil, err
+		}
+	}
+	if err := i.verifyTUFSignatures(&signed, &root, "root"); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// fetchTUFMetadata fetches the metadata of the role and verifies it against the trusted root and, if listed by
+// the metadata verified before, its expected version, length and hashes.
+func (i *Installer) fetchTUFMetadata(root *tufRoot, role, name string, expected *tufFileMeta) (*tufMetadata, error) {
+	data, err := i.readTUFFile(name)
+	if err != nil {
+		return nil, err
+	}
+	// Snapshot and targets may be listed by version only
+	if expected != nil && (expected.Length != 0 || len(expected.Hashes) > 0) {
+		if err := checkTUFFileMeta(data, *expected); err != nil {
+			return nil, tufErrorf("%s: %v", name, err)
+		}
+	}
+
+	var signed tufSigned
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, tufErrorf("invalid %s: %v", name, err)
+	}
+	if err := i.verifyTUFSignatures(&signed, root, role); err != nil {
+		return nil, err
+	}
+	var meta tufMetadata
+	if err := json.Unmarshal(signed.Signed, &meta); err != nil {
+		return nil, tufErrorf("invalid %s: %v", name, err)
+	}
+	if meta.Type != role {
+		return nil, tufErrorf("expected %s metadata, got %q", role, meta.Type)
+	}
+	if expected != nil && expected.Version != 0 && meta.Version != expected.Version {
+		return nil, tufErrorf("expected %s version %d, got %d", role, expected.Version, meta.Version)
+	}
+	if time.Now().After(meta.Expires) {
+		return nil, tufErrorf("%s expired at %s", role, meta.Expires.Format(time.RFC3339))
+	}
+	return &meta, nil
+}
+
+// tufFileName returns the name of the role's metadata file, prefixed with its version if the repository uses
+// consistent snapshots.
+func tufFileName(root *tufRoot, role string, meta tufFileMeta) string {
+	if root.ConsistentSnapshot && meta.Version > 0 {
+		return fmt.Sprintf("%d.%s.json", meta.Version, role)
+	}
+	return role + ".json"
+}
+
+// readTUFFile reads a TUF metadata file from disk, or downloads it. Missing files return ErrNotFoundError.
+func (i *Installer) readTUFFile(name string) ([]byte, error) {
+	metadataURL := i.opts.TUF.metadataURL()
+	if u, err := url.Parse(metadataURL); err != nil || len(u.Scheme) <= 1 {
+		data, err := ioutil.ReadFile(filepath.Join(metadataURL, name))
+		if os.IsNotExist(err) {
+			return nil, ErrNotFoundError
+		}
+		return data, err
+	}
+	return i.sendRequestGetBytes(metadataURL, name)
+}
+
+// verifyTUFSignatures checks that the metadata is signed by at least the threshold of distinct keys of the role.
+// Keys that aren't FIPS approved don't count in FIPS mode.
+func (i *Installer) verifyTUFSignatures(signed *tufSigned, root *tufRoot, role string) error {
+	r, exists := root.Roles[role]
+	if !exists || r.Threshold < 1 {
+		return tufErrorf("root doesn't define the %s role", role)
+	}
+	message, err := canonicalJSON(signed.Signed)
+	if err != nil {
+		return tufErrorf("invalid %s metadata: %v", role, err)
+	}
+
+	verified := map[string]bool{}
+	for _, sig := range signed.Signatures {
+		if verified[sig.KeyID] || !containsString(r.KeyIDs, sig.KeyID) {
+			continue
+		}
+		key, exists := root.Keys[sig.KeyID]
+		if !exists {
+			continue
+		}
+		signature, err := hex.DecodeString(sig.Sig)
+		if err != nil {
+			continue
+		}
+		if i.verifyTUFSignature(key, message, signature) == nil {
+			verified[sig.KeyID] = true
+		}
+	}
+	if len(verified) < r.Threshold {
+		return tufErrorf("%s metadata has %d valid signatures, %d required", role, len(verified), r.Threshold)
+	}
+	return nil
+}
+
+// verifyTUFSignature verifies an ed25519, ecdsa-sha2-nistp256/384 or rsassa-pss-sha256 signature.
+func (i *Installer) verifyTUFSignature(key tufKey, message, signature []byte) error {
+	var pub crypto.PublicKey
+	if key.KeyType == "ed25519" {
+		b, err := hex.DecodeString(key.KeyVal.Public)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			return errors.New("invalid ed25519 key")
+		}
+		pub = ed25519.PublicKey(b)
+	} else {
+		var err error
+		if pub, err = parsePEMPublicKey([]byte(key.KeyVal.Public)); err != nil {
+			return err
+		}
+	}
+	if err := i.checkFIPSKey(pub); err != nil {
+		return err
+	}
+
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, message, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		var h hash.Hash
+		switch key.Scheme {
+		case "ecdsa-sha2-nistp256":
+			h = sha256.New()
+		case "ecdsa-sha2-nistp384":
+			h = sha512.New384()
+		default:
+			return fmt.Errorf("unsupported signature scheme %q", key.Scheme)
+		}
+		h.Write(message)
+		if !ecdsa.VerifyASN1(k, h.Sum(nil), signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if key.Scheme != "rsassa-pss-sha256" {
+			return fmt.Errorf("unsupported signature scheme %q", key.Scheme)
+		}
+		digest := sha256.Sum256(message)
+		return rsa.VerifyPSS(k, crypto.SHA256, digest[:], signature,
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	}
+	return fmt.Errorf("unsupported key type %q", key.KeyType)
+}
+
+// checkTUFFileMeta checks the length and the sha256 and sha512 hashes of the file. At least one of the hashes
+// has to be listed.
+func checkTUFFileMeta(data []byte, meta tufFileMeta) error {
+	if meta.Length != 0 && int64(len(data)) != meta.Length {
+		return fmt.Errorf("expected %d bytes, got %d", meta.Length, len(data))
+	}
+	checked := 0
+	for algorithm, expected := range meta.Hashes {
+		var sum []byte
+		switch algorithm {
+		case "sha256":
+			s := sha256.Sum256(data)
+			sum = s[:]
+		case "sha512":
+			s := sha512.Sum512(data)
+			sum = s[:]
+		default:
+			continue
+		}
+		if !strings.EqualFold(hex.EncodeToString(sum), expected) {
+			return fmt.Errorf("%s hash mismatch", algorithm)
+		}
+		checked++
+	}
+	if checked == 0 {
+		return errors.New("no sha256 or sha512 hash listed")
+	}
+	return nil
+}
+
+// canonicalJSON encodes the JSON value in the OLPC canonical JSON form TUF metadata is signed in: object keys are
+// sorted, there is no insignificant whitespace and only quotes and backslashes are escaped in strings.
+func canonicalJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeCanonicalJSON(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		if _, err := v.Int64(); err != nil {
+			return fmt.Errorf("non-integer number %s", v)
+		}
+		buf.WriteString(v.String())
+	case string:
+		buf.WriteByte('"')
+		for _, r := range v {
+			if r == '"' || r == '\\' {
+				buf.WriteByte('\\')
+			}
+			buf.WriteRune(r)
+		}
+		buf.WriteByte('"')
+	case []interface{}:
+		buf.WriteByte('[')
+		for n, e := range v {
+			if n > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for n, k := range keys {
+			if n > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeCanonicalJSON(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported JSON value %T", v)
+	}
+	return nil
+}
+
+// tufMetadataURL returns the URL of the metadata file the request to the URL and sub paths fetches.
+func tufMetadataURL(URL string, subPaths ...string) string {
+	u, err := url.Parse(URL)
+	if err != nil || len(subPaths) == 0 {
+		return URL
+	}
+	for _, v := range subPaths {
+		u.Path = path.Join(u.Path, v)
+	}
+	return u.String()
+}
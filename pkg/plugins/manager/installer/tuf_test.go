@@ -0,0 +1,277 @@
+package installer
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTUF(t *testing.T) {
+	type tufTestKey struct {
+		id   string
+		priv ed25519.PrivateKey
+	}
+	newKey := func(t *testing.T) tufTestKey {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		return tufTestKey{id: hex.EncodeToString(pub[:8]), priv: priv}
+	}
+	sign := func(t *testing.T, signed interface{}, keys ...tufTestKey) []byte {
+		raw, err := json.Marshal(signed)
+		require.NoError(t, err)
+		message, err := canonicalJSON(raw)
+		require.NoError(t, err)
+		var signatures []map[string]string
+		for _, k := range keys {
+			signatures = append(signatures, map[string]string{
+				"keyid": k.id,
+				"sig":   hex.EncodeToString(ed25519.Sign(k.priv, message)),
+			})
+		}
+		envelope, err := json.Marshal(map[string]interface{}{"signed": json.RawMessage(raw), "signatures": signatures})
+		require.NoError(t, err)
+		return envelope
+	}
+	fileMeta := func(data []byte) map[string]interface{} {
+		sum := sha256.Sum256(data)
+		return map[string]interface{}{"length": len(data), "hashes": map[string]string{"sha256": hex.EncodeToString(sum[:])}}
+	}
+	rootMeta := func(version int, rootKey, key tufTestKey) map[string]interface{} {
+		keys := map[string]interface{}{}
+		for _, k := range []tufTestKey{rootKey, key} {
+			keys[k.id] = map[string]interface{}{
+				"keytype": "ed25519",
+				"scheme":  "ed25519",
+				"keyval":  map[string]string{"public": hex.EncodeToString(k.priv.Public().(ed25519.PublicKey))},
+			}
+		}
+		role := func(k tufTestKey) map[string]interface{} {
+			return map[string]interface{}{"keyids": []string{k.id}, "threshold": 1}
+		}
+		return map[string]interface{}{
+			"_type":   "root",
+			"version": version,
+			"expires": time.Now().Add(time.Hour),
+			"keys":    keys,
+			"roles": map[string]interface{}{
+				"root": role(rootKey), "timestamp": role(key), "snapshot": role(key), "targets": role(key),
+			},
+		}
+	}
+	// publishVersion signs the targets, snapshot and timestamp metadata for the repository files, all with the
+	// version.
+	publishVersion := func(t *testing.T, files map[string][]byte, key tufTestKey, version int, expires time.Time,
+		targets map[string][]byte) {
+		targetsMeta := map[string]interface{}{}
+		for name, data := range targets {
+			targetsMeta[name] = fileMeta(data)
+		}
+		files["tuf/targets.json"] = sign(t, map[string]interface{}{
+			"_type": "targets", "version": version, "expires": time.Now().Add(time.Hour), "targets": targetsMeta,
+		}, key)
+		files["tuf/snapshot.json"] = sign(t, map[string]interface{}{
+			"_type": "snapshot", "version": version, "expires": time.Now().Add(time.Hour),
+			"meta": map[string]interface{}{"targets.json": map[string]int{"version": version}},
+		}, key)
+		snapshotMeta := fileMeta(files["tuf/snapshot.json"])
+		snapshotMeta["version"] = version
+		files["tuf/timestamp.json"] = sign(t, map[string]interface{}{
+			"_type": "timestamp", "version": version, "expires": expires,
+			"meta": map[string]interface{}{"snapshot.json": snapshotMeta},
+		}, key)
+	}
+	// publish signs the metadata for the repository files with version 1.
+	publish := func(t *testing.T, files map[string][]byte, key tufTestKey, expires time.Time,
+		targets map[string][]byte) {
+		publishVersion(t, files, key, 1, expires, targets)
+	}
+	newRepo := func(t *testing.T, files map[string][]byte) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, exists := files[strings.TrimPrefix(r.URL.Path, "/api/plugins/")]
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		}))
+		t.Cleanup(server.Close)
+		return server.URL + "/api/plugins"
+	}
+	writeRoot := func(t *testing.T, root []byte) string {
+		path := filepath.Join(t.TempDir(), "root.json")
+		require.NoError(t, ioutil.WriteFile(path, root, 0600))
+		return path
+	}
+	pluginMeta := []byte(`{"id": "test-panel", "versions": [{"version": "1.0.0"}]}`)
+
+	t.Run("Should verify metadata against signed targets", func(t *testing.T) {
+		rootKey, key := newKey(t), newKey(t)
+		files := map[string][]byte{"repo/test-panel": pluginMeta, "repo/other-panel": pluginMeta}
+		publish(t, files, key, time.Now().Add(time.Hour), map[string][]byte{"repo/test-panel": pluginMeta})
+		repoURL := newRepo(t, files)
+		i := NewWithOpts(Opts{TUF: TUFOpts{
+			RootPath: writeRoot(t, sign(t, rootMeta(1, rootKey, key), rootKey)),
+			RepoURL:  repoURL,
+		}}, "8.0.0", &fakeLogger{})
+
+		plugin, err := i.getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		require.NoError(t, err)
+		assert.Equal(t, "test-panel", plugin.ID)
+
+		_, err = i.getPluginMetadataFromPluginRepo("other-panel", repoURL)
+		var tufErr *TUFError
+		require.ErrorAs(t, err, &tufErr)
+		assert.Equal(t, "repo/other-panel isn't a signed target", tufErr.Reason)
+	})
+
+	t.Run("Should reject tampered metadata", func(t *testing.T) {
+		rootKey, key := newKey(t), newKey(t)
+		files := map[string][]byte{"repo/test-panel": []byte(`{"id": "test-panel", "versions": []}`)}
+		publish(t, files, key, time.Now().Add(time.Hour), map[string][]byte{"repo/test-panel": pluginMeta})
+		repoURL := newRepo(t, files)
+		i := NewWithOpts(Opts{TUF: TUFOpts{
+			RootPath: writeRoot(t, sign(t, rootMeta(1, rootKey, key), rootKey)),
+			RepoURL:  repoURL,
+		}}, "8.0.0", &fakeLogger{})
+
+		_, err := i.getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		var tufErr *TUFError
+		require.ErrorAs(t, err, &tufErr)
+	})
+
+	t.Run("Should reject expired metadata", func(t *testing.T) {
+		rootKey, key := newKey(t), newKey(t)
+		files := map[string][]byte{"repo/test-panel": pluginMeta}
+		publish(t, files, key, time.Now().Add(-time.Minute), map[string][]byte{"repo/test-panel": pluginMeta})
+		repoURL := newRepo(t, files)
+		i := NewWithOpts(Opts{TUF: TUFOpts{
+			RootPath: writeRoot(t, sign(t, rootMeta(1, rootKey, key), rootKey)),
+			RepoURL:  repoURL,
+		}}, "8.0.0", &fakeLogger{})
+
+		_, err := i.getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		var tufErr *TUFError
+		require.ErrorAs(t, err, &tufErr)
+		assert.Contains(t, tufErr.Reason, "timestamp expired")
+	})
+
+	t.Run("Should reject metadata signed with untrusted key", func(t *testing.T) {
+		rootKey, key := newKey(t), newKey(t)
+		files := map[string][]byte{"repo/test-panel": pluginMeta}
+		publish(t, files, newKey(t), time.Now().Add(time.Hour), map[string][]byte{"repo/test-panel": pluginMeta})
+		repoURL := newRepo(t, files)
+		i := NewWithOpts(Opts{TUF: TUFOpts{
+			RootPath: writeRoot(t, sign(t, rootMeta(1, rootKey, key), rootKey)),
+			RepoURL:  repoURL,
+		}}, "8.0.0", &fakeLogger{})
+
+		_, err := i.getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		var tufErr *TUFError
+		require.ErrorAs(t, err, &tufErr)
+		assert.Equal(t, "timestamp metadata has 0 valid signatures, 1 required", tufErr.Reason)
+	})
+
+	t.Run("Should follow root rotations", func(t *testing.T) {
+		rootKey, oldKey, newRootKey, key := newKey(t), newKey(t), newKey(t), newKey(t)
+		files := map[string][]byte{
+			"repo/test-panel": pluginMeta,
+			"tuf/2.root.json": sign(t, rootMeta(2, newRootKey, key), rootKey, newRootKey),
+			"tuf/3.root.json": sign(t, rootMeta(3, newRootKey, key), rootKey),
+			"tuf/4.root.json": sign(t, rootMeta(4, newRootKey, key), newRootKey),
+		}
+		publish(t, files, key, time.Now().Add(time.Hour), map[string][]byte{"repo/test-panel": pluginMeta})
+		repoURL := newRepo(t, files)
+		newInstaller := func() *Installer {
+			return NewWithOpts(Opts{TUF: TUFOpts{
+				RootPath: writeRoot(t, sign(t, rootMeta(1, rootKey, oldKey), rootKey)),
+				RepoURL:  repoURL,
+			}}, "8.0.0", &fakeLogger{})
+		}
+
+		// Version 3 is signed by the root key rotated out by version 2
+		_, err := newInstaller().getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		var tufErr *TUFError
+		require.ErrorAs(t, err, &tufErr)
+
+		files["tuf/3.root.json"] = sign(t, rootMeta(3, newRootKey, key), newRootKey)
+		_, err = newInstaller().getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		require.NoError(t, err)
+	})
+
+	t.Run("Should reject metadata older than the last verified version", func(t *testing.T) {
+		rootKey, key := newKey(t), newKey(t)
+		files := map[string][]byte{"repo/test-panel": pluginMeta}
+		publishVersion(t, files, key, 2, time.Now().Add(time.Hour), map[string][]byte{"repo/test-panel": pluginMeta})
+		repoURL := newRepo(t, files)
+		rootPath := writeRoot(t, sign(t, rootMeta(1, rootKey, key), rootKey))
+		newInstaller := func() *Installer {
+			return NewWithOpts(Opts{TUF: TUFOpts{RootPath: rootPath, RepoURL: repoURL}}, "8.0.0", &fakeLogger{})
+		}
+
+		_, err := newInstaller().getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		require.NoError(t, err)
+		versions, err := readTUFVersions(filepath.Join(filepath.Dir(rootPath), "root.versions.json"))
+		require.NoError(t, err)
+		assert.Equal(t, tufVersions{Root: 1, Timestamp: 2, Snapshot: 2, Targets: 2}, versions)
+
+		publish(t, files, key, time.Now().Add(time.Hour), map[string][]byte{"repo/test-panel": pluginMeta})
+		_, err = newInstaller().getPluginMetadataFromPluginRepo("test-panel", repoURL)
+		var tufErr *TUFError
+		require.ErrorAs(t, err, &tufErr)
+		assert.Equal(t, "timestamp version 1 is lower than the last verified version 2", tufErr.Reason)
+	})
+
+	t.Run("Should verify local static repository", func(t *testing.T) {
+		rootKey, key := newKey(t), newKey(t)
+		dir := t.TempDir()
+		index := []byte(`{"plugins": [{"id": "test-panel", "versions": [{"version": "1.0.0"}]}]}`)
+		files := map[string][]byte{repo.StaticIndexFile: index}
+		publish(t, files, key, time.Now().Add(time.Hour), map[string][]byte{repo.StaticIndexFile: index})
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, repo.StaticIndexFile), index, 0600))
+		tufDir := filepath.Join(dir, "tuf")
+		require.NoError(t, os.Mkdir(tufDir, 0750))
+		for name, data := range files {
+			if strings.HasPrefix(name, "tuf/") {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), data, 0600))
+			}
+		}
+		i := NewWithOpts(Opts{TUF: TUFOpts{
+			RootPath:    writeRoot(t, sign(t, rootMeta(1, rootKey, key), rootKey)),
+			RepoURL:     dir,
+			MetadataURL: tufDir,
+		}}, "8.0.0", &fakeLogger{})
+
+		plugin, err := i.getPluginMetadataFromPluginRepo("test-panel", dir)
+		require.NoError(t, err)
+		assert.Equal(t, "test-panel", plugin.ID)
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, repo.StaticIndexFile), []byte(`{"plugins": []}`), 0600))
+		i = NewWithOpts(i.opts, "8.0.0", &fakeLogger{})
+		_, err = i.getPluginMetadataFromPluginRepo("test-panel", dir)
+		var tufErr *TUFError
+		require.ErrorAs(t, err, &tufErr)
+	})
+
+	t.Run("Should encode canonical JSON", func(t *testing.T) {
+		data, err := canonicalJSON([]byte(`{"b": [1, true, null], "a": "x\"\\<\u00e9"}`))
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":"x\"\\<é","b":[1,true,null]}`, string(data))
+
+		_, err = canonicalJSON([]byte(`{"a": 1.5}`))
+		require.Error(t, err)
+	})
+}
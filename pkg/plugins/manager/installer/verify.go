@@ -0,0 +1,92 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+// PluginVerification is the result of re-verifying an installed plugin.
+type PluginVerification struct {
+	PluginID  string
+	Version   string
+	Signature plugins.PluginSignatureState
+	// Problems lists how the plugin drifted from a verified installation. It's empty if the plugin is intact.
+	Problems []string
+}
+
+// OK reports whether the plugin passed verification.
+func (v PluginVerification) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// String returns a one-line summary of the verification result.
+func (v PluginVerification) String() string {
+	name := v.PluginID
+	if v.Version != "" {
+		name = fmt.Sprintf("%s v%s", v.PluginID, v.Version)
+	}
+	if v.OK() {
+		if v.Signature.Status.IsValid() {
+			return fmt.Sprintf("%s: ok, signed by %s", name, v.Signature.SigningOrg)
+		}
+		return fmt.Sprintf("%s: ok", name)
+	}
+	return fmt.Sprintf("%s: %s", name, strings.Join(v.Problems, "; "))
+}
+
+// VerifyAll re-verifies the signatures, file checksums and plugin.json files of every plugin installed in the
+// plugins directory, applying the same policies as when installing. Plugins awaiting approval aren't verified.
+// It's meant for readiness checks and scheduled compliance scans, which can fail if any result isn't OK.
+func (i *Installer) VerifyAll(pluginsDir string) ([]PluginVerification, error) {
+	if err := i.checkFIPS(); err != nil {
+		return nil, err
+	}
+	entries, err := ioutil.ReadDir(pluginsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []PluginVerification
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		results = append(results, i.verifyInstalledPlugin(pluginsDir, e.Name()))
+	}
+	return results, nil
+}
+
+func (i *Installer) verifyInstalledPlugin(pluginsDir, pluginID string) PluginVerification {
+	res := PluginVerification{PluginID: pluginID}
+	if err := validatePluginJSONs(pluginsDir, pluginID); err != nil {
+		res.Problems = append(res.Problems, err.Error())
+	}
+	if dto, err := toPluginDTO(pluginsDir, pluginID); err == nil {
+		res.Version = dto.Info.Version
+	}
+
+	if i.opts.SignaturePolicy == SignaturePolicyIgnore {
+		return res
+	}
+	for _, id := range i.opts.AllowUnsigned {
+		if id == pluginID {
+			return res
+		}
+	}
+
+	state, reason := verifyPluginSignature(pluginsDir, pluginID, i.opts.AppURL, i.opts.IgnoreRootURLs)
+	res.Signature = state
+	minimum := i.opts.MinSignatureLevel
+	switch {
+	case !state.Status.IsValid():
+		res.Problems = append(res.Problems, (&SignatureError{PluginID: pluginID, Status: state.Status,
+			Reason: reason}).Error())
+	case minimum != "" && SignatureLevel(state.Type).Rank() < minimum.Rank():
+		res.Problems = append(res.Problems, (&SignatureLevelError{PluginID: pluginID, Type: state.Type,
+			Minimum: minimum}).Error())
+	}
+	return res
+}
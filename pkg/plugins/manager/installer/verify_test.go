@@ -0,0 +1,54 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyAll(t *testing.T) {
+	t.Run("Should report intact plugins", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "valid-v2-signature")
+		require.NoError(t, os.MkdirAll(filepath.Join(pluginsDir, plugins.QuarantineDirName, "other"), 0750))
+		i := &Installer{log: &fakeLogger{}}
+
+		results, err := i.VerifyAll(pluginsDir)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "test", results[0].PluginID)
+		assert.Equal(t, "1.0.0", results[0].Version)
+		assert.True(t, results[0].OK(), results[0].String())
+		assert.Equal(t, plugins.PluginSignatureValid, results[0].Signature.Status)
+	})
+
+	t.Run("Should report modified plugins", func(t *testing.T) {
+		pluginsDir := copyTestPlugin(t, "valid-v2-signature")
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pluginsDir, "test", "module.js"), []byte("evil"), 0600))
+		i := &Installer{log: &fakeLogger{}}
+
+		results, err := i.VerifyAll(pluginsDir)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.False(t, results[0].OK())
+		assert.Equal(t, plugins.PluginSignatureModified, results[0].Signature.Status)
+		assert.Contains(t, results[0].String(), "module.js")
+	})
+
+	t.Run("Should skip plugins allowed to be unsigned", func(t *testing.T) {
+		pluginsDir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(pluginsDir, "test"), 0750))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pluginsDir, "test", "plugin.json"),
+			[]byte(`{"id":"test","name":"Test","type":"panel","info":{"version":"1.0.0"}}`), 0600))
+		i := &Installer{log: &fakeLogger{}, opts: Opts{AllowUnsigned: []string{"test"}}}
+
+		results, err := i.VerifyAll(pluginsDir)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.True(t, results[0].OK(), results[0].String())
+	})
+}
@@ -0,0 +1,220 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins/repo"
+	"github.com/hashicorp/go-version"
+)
+
+// VersionInfo is a published version of a plugin, see repo.VersionInfo.
+type VersionInfo = repo.VersionInfo
+
+// ListVersions returns all versions of the plugin published to the plugin repository, newest first as listed by
+// the repository.
+func (i *Installer) ListVersions(pluginID, pluginRepoURL string) ([]VersionInfo, error) {
+	plugin, err := i.GetPlugin(pluginID, pluginRepoURL)
+	if err != nil {
+		return nil, err
+	}
+	return repo.VersionInfos(&plugin, osAndArchString(), i.targetGrafanaVersion()), nil
+}
+
+// GetPlugin returns the metadata of the plugin, referenced as [<source alias>:]<plugin id>, from the plugin
+// repository or the repository it's routed to.
+func (i *Installer) GetPlugin(pluginID, pluginRepoURL string) (Plugin, error) {
+	if i.opts.Enterprise {
+		pluginRepoURL = i.enterpriseRepoURL()
+	}
+	ref := pluginID
+	pluginID, pluginRepoURL, err := i.resolveSourceAlias(pluginID, pluginRepoURL)
+	if err != nil {
+		return Plugin{}, err
+	}
+	defaultRepo := !i.opts.Enterprise && pluginID == ref
+	plugin, _, err := i.lookupPlugin(pluginID, pluginRepoURL, defaultRepo)
+	return plugin, err
+}
+
+// ListPlugins returns all plugins in the plugin repository with their versions, see repo.HTTPClient.ListPlugins.
+func (i *Installer) ListPlugins(pluginRepoURL string) ([]Plugin, error) {
+	if i.opts.Enterprise {
+		pluginRepoURL = i.enterpriseRepoURL()
+	}
+	return i.metadataClient(pluginRepoURL).ListPlugins()
+}
+
+// LatestVersions returns the latest version of each of the plugins that is compatible with the target Grafana
+// version, by plugin ID, see repo.HTTPClient.LatestVersions.
+func (i *Installer) LatestVersions(pluginIDs []string, pluginRepoURL string) (map[string]string, error) {
+	if i.opts.Enterprise {
+		pluginRepoURL = i.enterpriseRepoURL()
+	}
+	return i.metadataClient(pluginRepoURL).LatestVersions(pluginIDs)
+}
+
+// compatibleWithGrafana reports whether the Grafana version satisfies the Grafana dependency of the plugin
+// version. Versions are compatible if either version is unknown or can't be parsed.
+func (i *Installer) compatibleWithGrafana(v *Version) bool {
+	if v.GrafanaDependency == "" {
+		return true
+	}
+	grafanaVersion, err := version.NewVersion(i.targetGrafanaVersion())
+	if err != nil {
+		return true
+	}
+	compatible, err := repo.VersionInRange(grafanaVersion.Core(), v.GrafanaDependency)
+	if err != nil {
+		i.log.Debugf("Failed to check Grafana dependency %q of version %s: %v", v.GrafanaDependency, v.Version, err)
+		return true
+	}
+	return compatible
+}
+
+// IncompatibleVersionError is returned when the selected plugin version requires another Grafana version than
+// the one plugins are resolved for, so that Grafana would refuse to load it.
+type IncompatibleVersionError struct {
+	PluginID          string
+	Version           string
+	GrafanaDependency string
+	GrafanaVersion    string
+	// NewestCompatible is the newest version of the plugin compatible with the Grafana version, empty if there's
+	// none.
+	NewestCompatible string
+	// FromArchive is set if the Grafana dependency was only found in the plugin.json of the downloaded archive, so
+	// that no compatible version is known.
+	FromArchive bool
+}
+
+func (e *IncompatibleVersionError) Error() string {
+	msg := fmt.Sprintf("%s v%s requires Grafana %s, which isn't satisfied by Grafana %s", e.PluginID, e.Version,
+		e.GrafanaDependency, e.GrafanaVersion)
+	if e.FromArchive {
+		return msg
+	}
+	if e.NewestCompatible == "" {
+		return msg + ", and no version of the plugin is compatible"
+	}
+	return msg + fmt.Sprintf(", the newest compatible version is %s", e.NewestCompatible)
+}
+
+// selectCompatibleVersion selects a version with selectFn, skipping versions whose Grafana dependency isn't
+// satisfied by the target Grafana version unless a version was requested. Requested and latest incompatible
+// versions fail with an IncompatibleVersionError naming the newest compatible version.
+func (i *Installer) selectCompatibleVersion(plugin *Plugin, requestedVersion string,
+	selectFn func(plugin *Plugin, requestedVersion string) (*Version, error)) (*Version, error) {
+	v, err := selectFn(plugin, requestedVersion)
+	if err != nil || i.compatibleWithGrafana(v) {
+		return v, err
+	}
+
+	compatible := i.compatibleVersions(plugin)
+	newest, err := selectFn(&compatible, "")
+	if err == nil && requestedVersion == "" {
+		return newest, nil
+	}
+	incompatibleErr := &IncompatibleVersionError{PluginID: plugin.ID, Version: v.Version,
+		GrafanaDependency: v.GrafanaDependency, GrafanaVersion: i.targetGrafanaVersion()}
+	if err == nil {
+		incompatibleErr.NewestCompatible = newest.Version
+	}
+	return nil, incompatibleErr
+}
+
+// compatibleVersions returns the plugin with only the versions compatible with the target Grafana version.
+func (i *Installer) compatibleVersions(plugin *Plugin) Plugin {
+	compatible := *plugin
+	compatible.Versions = nil
+	for _, cv := range plugin.Versions {
+		ver := cv
+		if i.compatibleWithGrafana(&ver) {
+			compatible.Versions = append(compatible.Versions, ver)
+		}
+	}
+	return compatible
+}
+
+// suggestCompatibleVersion names the newest version of the plugin in the channel that is compatible with the
+// target Grafana version on an IncompatibleVersionError of a version range, whose versions are all incompatible.
+func (i *Installer) suggestCompatibleVersion(err error, plugin *Plugin, channel Channel) error {
+	var incompatibleErr *IncompatibleVersionError
+	if !errors.As(err, &incompatibleErr) || incompatibleErr.NewestCompatible != "" {
+		return err
+	}
+	compatible := i.compatibleVersions(plugin)
+	if newest, selectErr := selectVersion(&compatible, "", channel); selectErr == nil {
+		incompatibleErr.NewestCompatible = newest.Version
+	}
+	return err
+}
+
+// checkGrafanaDependency fails with an IncompatibleVersionError if the Grafana dependency in the plugin.json of
+// the extracted plugin isn't satisfied by the target Grafana version, for plugins installed from a URL or a
+// lockfile and repositories that don't list the Grafana dependency, which Grafana would refuse to load.
+func (i *Installer) checkGrafanaDependency(res InstalledPlugin) error {
+	v := &Version{Version: res.Info.Version, GrafanaDependency: res.Dependencies.GrafanaDependency}
+	if i.compatibleWithGrafana(v) {
+		return nil
+	}
+	return &IncompatibleVersionError{PluginID: res.ID, Version: v.Version, GrafanaDependency: v.GrafanaDependency,
+		GrafanaVersion: i.targetGrafanaVersion(), FromArchive: true}
+}
+
+// resolveVersionRange narrows the versions of the plugin to the ones satisfying the requested version if it's a
+// range like ^1.2.0, ~1.2.0, >=1.2.0 or 1.x, so that the newest satisfying version is selected instead of an exact
+// one. The returned requested version is empty for ranges, published versions and unparsable ranges are returned
+// unchanged.
+func resolveVersionRange(plugin *Plugin, requestedVersion string) (Plugin, string, error) {
+	if requestedVersion == "" {
+		return *plugin, requestedVersion, nil
+	}
+	for _, v := range plugin.Versions {
+		if v.Version == requestedVersion {
+			return *plugin, requestedVersion, nil
+		}
+	}
+
+	satisfying := *plugin
+	satisfying.Versions = nil
+	for _, v := range plugin.Versions {
+		pv, err := version.NewVersion(v.Version)
+		if err != nil {
+			continue
+		}
+		inRange, err := repo.VersionInRange(pv, requestedVersion)
+		if err != nil {
+			// Not a range, selecting the version reports it as missing
+			return *plugin, requestedVersion, nil
+		}
+		if inRange {
+			satisfying.Versions = append(satisfying.Versions, v)
+		}
+	}
+	if len(satisfying.Versions) == 0 {
+		return Plugin{}, "", fmt.Errorf("no version of %s satisfies %s", plugin.ID, requestedVersion)
+	}
+	return satisfying, "", nil
+}
+
+// ParseGrafanaVersion validates the Grafana version plugins are resolved for. An empty version resolves plugins
+// for the running Grafana version.
+func ParseGrafanaVersion(v string) (string, error) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if v == "" {
+		return "", nil
+	}
+	if _, err := version.NewVersion(v); err != nil {
+		return "", fmt.Errorf("invalid Grafana version %q", v)
+	}
+	return v, nil
+}
+
+// targetGrafanaVersion returns the Grafana version plugins are resolved for.
+func (i *Installer) targetGrafanaVersion() string {
+	if i.opts.GrafanaVersion != "" {
+		return i.opts.GrafanaVersion
+	}
+	return i.grafanaVersion
+}
@@ -0,0 +1,167 @@
+package installer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListVersions(t *testing.T) {
+	t.Run("Should list all published versions", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/plugins/repo/test-panel", r.URL.Path)
+			_, _ = fmt.Fprintf(w, `{"id": "test-panel", "versions": [
+				{"version": "3.0.0-beta.1", "grafanaDependency": ">=9.0.0"},
+				{"version": "2.0.0", "grafanaDependency": "^8.0.0", "arch": {"%s": {}, "plan9-mips": {}}},
+				{"version": "1.0.0", "arch": {"plan9-mips": {}}}
+			]}`, osAndArchString())
+		}))
+		t.Cleanup(server.Close)
+		i := NewWithOpts(Opts{}, "8.2.0-pre", &fakeLogger{})
+
+		versions, err := i.ListVersions("test-panel", server.URL+"/api/plugins")
+		require.NoError(t, err)
+		assert.Equal(t, []VersionInfo{
+			{Version: "3.0.0-beta.1", Channel: ChannelBeta, Platforms: []string{"any"}, GrafanaDependency: ">=9.0.0",
+				Supported: true},
+			{Version: "2.0.0", Channel: ChannelStable, Platforms: []string{osAndArchString(), "plan9-mips"},
+				GrafanaDependency: "^8.0.0", Supported: true, Compatible: true},
+			{Version: "1.0.0", Channel: ChannelStable, Platforms: []string{"plan9-mips"}, Compatible: true},
+		}, versions)
+	})
+
+	t.Run("Should resolve versions for target Grafana version", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "9.1.0", r.Header.Get("grafana-version"))
+			assert.Equal(t, "grafana 8.2.0", r.Header.Get("User-Agent"))
+			_, _ = fmt.Fprint(w, `{"id": "test-panel", "versions": [
+				{"version": "3.0.0", "grafanaDependency": ">=9.0.0"},
+				{"version": "2.0.0", "grafanaDependency": "^8.0.0"}
+			]}`)
+		}))
+		t.Cleanup(server.Close)
+		i := NewWithOpts(Opts{GrafanaVersion: "9.1.0"}, "8.2.0", &fakeLogger{})
+
+		versions, err := i.ListVersions("test-panel", server.URL+"/api/plugins")
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.True(t, versions[0].Compatible)
+		assert.False(t, versions[1].Compatible)
+	})
+
+	t.Run("Should select versions compatible with Grafana version", func(t *testing.T) {
+		plugin := &Plugin{ID: "test-panel", Versions: []Version{
+			{Version: "3.0.0", GrafanaDependency: ">=9.0.0"},
+			{Version: "2.0.0", GrafanaDependency: "^8.0.0"},
+			{Version: "1.0.0"},
+		}}
+		selectFn := func(p *Plugin, version string) (*Version, error) {
+			return selectVersion(p, version, "")
+		}
+		i := NewWithOpts(Opts{}, "8.2.0", &fakeLogger{})
+
+		v, err := i.selectCompatibleVersion(plugin, "", selectFn)
+		require.NoError(t, err)
+		assert.Equal(t, "2.0.0", v.Version)
+
+		v, err = i.selectCompatibleVersion(plugin, "1.0.0", selectFn)
+		require.NoError(t, err)
+		assert.Equal(t, "1.0.0", v.Version)
+
+		_, err = i.selectCompatibleVersion(plugin, "3.0.0", selectFn)
+		var incompatibleErr *IncompatibleVersionError
+		require.ErrorAs(t, err, &incompatibleErr)
+		assert.Equal(t, "2.0.0", incompatibleErr.NewestCompatible)
+		assert.Equal(t, "test-panel v3.0.0 requires Grafana >=9.0.0, which isn't satisfied by Grafana 8.2.0, "+
+			"the newest compatible version is 2.0.0", err.Error())
+
+		i = NewWithOpts(Opts{GrafanaVersion: "7.5.0"}, "8.2.0", &fakeLogger{})
+		_, err = i.selectCompatibleVersion(&Plugin{ID: "test-panel", Versions: plugin.Versions[:2]}, "", selectFn)
+		require.ErrorAs(t, err, &incompatibleErr)
+		assert.Equal(t, "3.0.0", incompatibleErr.Version)
+		assert.Empty(t, incompatibleErr.NewestCompatible)
+
+		v, err = NewWithOpts(Opts{}, "master", &fakeLogger{}).selectCompatibleVersion(plugin, "", selectFn)
+		require.NoError(t, err)
+		assert.Equal(t, "3.0.0", v.Version)
+	})
+
+	t.Run("Should resolve version ranges to the newest satisfying version", func(t *testing.T) {
+		plugin := &Plugin{ID: "test-panel", Versions: []Version{
+			{Version: "2.1.0-beta.1"}, {Version: "2.0.0"}, {Version: "1.4.2"}, {Version: "1.3.0"}, {Version: "1.2.0"},
+		}}
+		for r, expected := range map[string]string{
+			"^1.2.0":        "1.4.2",
+			"~1.3.0":        "1.3.0",
+			">=1.3.0":       "2.0.0",
+			">= 1.2, < 1.4": "1.3.0",
+			"1.x":           "1.4.2",
+			"1.2.x":         "1.2.0",
+			"v1.3.0":        "1.3.0",
+		} {
+			candidates, requested, err := resolveVersionRange(plugin, r)
+			require.NoError(t, err, r)
+			assert.Empty(t, requested, r)
+			v, err := selectVersion(&candidates, requested, "")
+			require.NoError(t, err, r)
+			assert.Equal(t, expected, v.Version, r)
+		}
+
+		candidates, requested, err := resolveVersionRange(plugin, "1.3.0")
+		require.NoError(t, err)
+		assert.Equal(t, "1.3.0", requested)
+		assert.Len(t, candidates.Versions, 5)
+
+		_, requested, err = resolveVersionRange(plugin, "latest")
+		require.NoError(t, err)
+		assert.Equal(t, "latest", requested)
+
+		_, _, err = resolveVersionRange(plugin, "^3.0.0")
+		require.Error(t, err)
+		assert.Equal(t, "no version of test-panel satisfies ^3.0.0", err.Error())
+	})
+
+	t.Run("Should fail before installing versions incompatible with Grafana version", func(t *testing.T) {
+		dir := writeTestDependencyRepo(t, map[string]string{
+			"test-panel-1.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"1.0.0"},
+				"dependencies":{"grafanaDependency":">=9.0.0"}}`,
+			"test-panel-2.0.0.zip": `{"id":"test-panel","type":"panel","name":"Panel","info":{"version":"2.0.0"},
+				"dependencies":{"grafanaDependency":"^8.0.0"}}`,
+		})
+		i := NewWithOpts(Opts{AdvisoryPolicy: AdvisoryPolicyIgnore}, "8.0.0", &fakeLogger{})
+		pluginsDir := t.TempDir()
+
+		err := i.Install("test-panel", "^1.0.0", pluginsDir, "", dir)
+		var incompatibleErr *IncompatibleVersionError
+		require.ErrorAs(t, err, &incompatibleErr)
+		assert.Equal(t, "test-panel v1.0.0 requires Grafana >=9.0.0, which isn't satisfied by Grafana 8.0.0, "+
+			"the newest compatible version is 2.0.0", err.Error())
+
+		archive := writeTestTarGz(t, nil, map[string]string{"plugin.json": `{"id":"test-panel","type":"panel",
+			"name":"Panel","info":{"version":"3.0.0"},"dependencies":{"grafanaDependency":">=9.0.0"}}`})
+		err = i.Install("test-panel", "", pluginsDir, archive, "")
+		require.ErrorAs(t, err, &incompatibleErr)
+		assert.True(t, incompatibleErr.FromArchive)
+		assert.Equal(t, "test-panel v3.0.0 requires Grafana >=9.0.0, which isn't satisfied by Grafana 8.0.0",
+			err.Error())
+		assert.NoDirExists(t, filepath.Join(pluginsDir, "test-panel"))
+	})
+
+	t.Run("Should parse target Grafana version", func(t *testing.T) {
+		v, err := ParseGrafanaVersion("v9.1.0")
+		require.NoError(t, err)
+		assert.Equal(t, "9.1.0", v)
+
+		v, err = ParseGrafanaVersion("")
+		require.NoError(t, err)
+		assert.Empty(t, v)
+
+		_, err = ParseGrafanaVersion("next")
+		require.Error(t, err)
+	})
+}
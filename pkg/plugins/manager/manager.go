@@ -20,6 +20,7 @@ import (
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/plugins/backendplugin"
+	"github.com/grafana/grafana/pkg/plugins/repo"
 	"github.com/grafana/grafana/pkg/registry"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/setting"
@@ -58,6 +59,9 @@ type PluginManager struct {
 	grafanaHasUpdate              bool
 	pluginScanningErrors          map[string]plugins.PluginError
 
+	// repoClient checks the plugin repository for updates of the installed plugins.
+	repoClient repo.Client
+
 	renderer     *plugins.RendererPlugin
 	dataSources  map[string]*plugins.DataSourcePlugin
 	plugins      map[string]*plugins.PluginBase
@@ -446,7 +450,7 @@ func (s *PluginScanner) walker(currentPath string, f os.FileInfo, err error) err
 		return fmt.Errorf("filepath.Walk reported an error for %q: %w", currentPath, err)
 	}
 
-	if f.Name() == "node_modules" || f.Name() == "Chromium.app" {
+	if f.Name() == "node_modules" || f.Name() == "Chromium.app" || f.Name() == plugins.QuarantineDirName {
 		return util.ErrWalkSkipDir
 	}
 
@@ -493,7 +497,7 @@ func (s *PluginScanner) loadPlugin(pluginJSONFilePath string) error {
 	}
 
 	pluginCommon.PluginDir = filepath.Dir(pluginJSONFilePath)
-	pluginCommon.Files, err = collectPluginFilesWithin(pluginCommon.PluginDir)
+	pluginCommon.Files, err = plugins.CollectPluginFiles(pluginCommon.PluginDir)
 	if err != nil {
 		s.log.Warn("Could not collect plugin file information in directory", "pluginID", pluginCommon.Id, "dir", pluginCommon.PluginDir)
 		return err
@@ -644,25 +648,6 @@ func (pm *PluginManager) GetPluginMarkdown(pluginId string, name string) ([]byte
 	return data, nil
 }
 
-// gets plugin filenames that require verification for plugin signing
-func collectPluginFilesWithin(rootDir string) ([]string, error) {
-	var files []string
-	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && info.Name() != "MANIFEST.txt" {
-			file, err := filepath.Rel(rootDir, path)
-			if err != nil {
-				return err
-			}
-			files = append(files, filepath.ToSlash(file))
-		}
-		return nil
-	})
-	return files, err
-}
-
 // GetDataPlugin gets a DataPlugin with a certain name. If none is found, nil is returned.
 //nolint: staticcheck // plugins.DataPlugin deprecated
 func (pm *PluginManager) GetDataPlugin(id string) plugins.DataPlugin {
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/plugins/repo"
 	"github.com/grafana/grafana/pkg/setting"
 	"github.com/hashicorp/go-version"
 )
@@ -16,17 +17,12 @@ var (
 	httpClient = http.Client{Timeout: 10 * time.Second}
 )
 
-type grafanaNetPlugin struct {
-	Slug    string `json:"slug"`
-	Version string `json:"version"`
-}
-
 type gitHubLatest struct {
 	Stable  string `json:"stable"`
 	Testing string `json:"testing"`
 }
 
-func (pm *PluginManager) getAllExternalPluginSlugs() string {
+func (pm *PluginManager) getAllExternalPluginSlugs() []string {
 	var result []string
 	for _, plug := range pm.plugins {
 		if plug.IsCorePlugin {
@@ -36,7 +32,7 @@ func (pm *PluginManager) getAllExternalPluginSlugs() string {
 		result = append(result, plug.Id)
 	}
 
-	return strings.Join(result, ",")
+	return result
 }
 
 func (pm *PluginManager) checkForUpdates() {
@@ -46,45 +42,30 @@ func (pm *PluginManager) checkForUpdates() {
 
 	pm.log.Debug("Checking for updates")
 
-	pluginSlugs := pm.getAllExternalPluginSlugs()
-	resp, err := httpClient.Get("https://grafana.com/api/plugins/versioncheck?slugIn=" + pluginSlugs + "&grafanaVersion=" + setting.BuildVersion)
-	if err != nil {
-		log.Tracef("Failed to get plugins repo from grafana.com, %v", err.Error())
-		return
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Warn("Failed to close response body", "err", err)
-		}
-	}()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Tracef("Update check failed, reading response from grafana.com, %v", err.Error())
-		return
+	if pm.repoClient == nil {
+		pm.repoClient = repo.NewClient(setting.GrafanaComUrl+"/api/plugins",
+			repo.ClientOpts{GrafanaVersion: setting.BuildVersion})
 	}
-
-	gNetPlugins := []grafanaNetPlugin{}
-	err = json.Unmarshal(body, &gNetPlugins)
+	latestVersions, err := pm.repoClient.LatestVersions(pm.getAllExternalPluginSlugs())
 	if err != nil {
-		log.Tracef("Failed to unmarshal plugin repo, reading response from grafana.com, %v", err.Error())
+		log.Tracef("Failed to check plugin versions in plugin repository, %v", err.Error())
 		return
 	}
 
 	for _, plug := range pm.plugins {
-		for _, gplug := range gNetPlugins {
-			if gplug.Slug == plug.Id {
-				plug.GrafanaNetVersion = gplug.Version
-
-				plugVersion, err1 := version.NewVersion(plug.Info.Version)
-				gplugVersion, err2 := version.NewVersion(gplug.Version)
-
-				if err1 != nil || err2 != nil {
-					plug.GrafanaNetHasUpdate = plug.Info.Version != plug.GrafanaNetVersion
-				} else {
-					plug.GrafanaNetHasUpdate = plugVersion.LessThan(gplugVersion)
-				}
-			}
+		latest, exists := latestVersions[plug.Id]
+		if !exists {
+			continue
+		}
+		plug.GrafanaNetVersion = latest
+
+		plugVersion, err1 := version.NewVersion(plug.Info.Version)
+		gplugVersion, err2 := version.NewVersion(latest)
+
+		if err1 != nil || err2 != nil {
+			plug.GrafanaNetHasUpdate = plug.Info.Version != plug.GrafanaNetVersion
+		} else {
+			plug.GrafanaNetHasUpdate = plugVersion.LessThan(gplugVersion)
 		}
 	}
 
@@ -98,7 +79,7 @@ func (pm *PluginManager) checkForUpdates() {
 			pm.log.Warn("Failed to close response body", "err", err)
 		}
 	}()
-	body, err = ioutil.ReadAll(resp2.Body)
+	body, err := ioutil.ReadAll(resp2.Body)
 	if err != nil {
 		log.Tracef("Update check failed, reading response from github.com, %v", err.Error())
 		return
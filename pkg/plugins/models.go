@@ -13,6 +13,10 @@ const (
 	PluginTypeDashboard = "dashboard"
 )
 
+// QuarantineDirName is the directory within a plugins directory that grafana-cli stages plugins in until they're
+// verified, or approved when installing in quarantine-only mode. Plugins within it aren't loaded.
+const QuarantineDirName = ".quarantine"
+
 type PluginNotFoundError struct {
 	PluginID string
 }
@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Channel is a release channel plugin versions are published to. Every channel includes the versions of the
+// more stable channels, so the beta channel contains stable and beta versions.
+type Channel string
+
+const (
+	ChannelStable  Channel = "stable"
+	ChannelBeta    Channel = "beta"
+	ChannelNightly Channel = "nightly"
+)
+
+var channelRanks = map[Channel]int{
+	ChannelStable:  0,
+	ChannelBeta:    1,
+	ChannelNightly: 2,
+}
+
+// ParseChannel returns the channel with the provided name. An empty name returns an empty channel, which
+// doesn't filter versions at all.
+func ParseChannel(name string) (Channel, error) {
+	if name == "" {
+		return "", nil
+	}
+	c := Channel(strings.ToLower(name))
+	if _, exists := channelRanks[c]; !exists {
+		return "", fmt.Errorf("unknown release channel %q, valid channels are stable, beta and nightly", name)
+	}
+	return c, nil
+}
+
+// Includes reports whether the version is published to the channel.
+func (c Channel) Includes(v *Version) bool {
+	if c == "" {
+		return true
+	}
+	return channelRanks[VersionChannel(v)] <= channelRanks[c]
+}
+
+// VersionChannel returns the channel of the version as reported by the repository or, if the repository doesn't
+// report it, derived from the prerelease part of the version.
+func VersionChannel(v *Version) Channel {
+	if c, err := ParseChannel(v.Channel); err == nil && c != "" {
+		return c
+	}
+
+	parsed, err := version.NewVersion(v.Version)
+	if err != nil || parsed.Prerelease() == "" {
+		return ChannelStable
+	}
+	prerelease := strings.ToLower(parsed.Prerelease())
+	for _, s := range []string{"nightly", "dev", "canary", "snapshot"} {
+		if strings.Contains(prerelease, s) {
+			return ChannelNightly
+		}
+	}
+	return ChannelBeta
+}
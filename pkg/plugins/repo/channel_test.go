@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannel(t *testing.T) {
+	t.Run("Should derive channel of version", func(t *testing.T) {
+		for v, expected := range map[string]Channel{
+			"1.0.0":                  ChannelStable,
+			"1.0.0-beta.1":           ChannelBeta,
+			"1.0.0-rc1":              ChannelBeta,
+			"1.1.0-nightly.20210501": ChannelNightly,
+			"1.1.0-dev":              ChannelNightly,
+		} {
+			assert.Equal(t, expected, VersionChannel(&Version{Version: v}), v)
+		}
+		assert.Equal(t, ChannelBeta, VersionChannel(&Version{Version: "1.0.0", Channel: "Beta"}))
+	})
+
+	t.Run("Should include versions of more stable channels", func(t *testing.T) {
+		beta := &Version{Version: "1.0.0-beta.1"}
+		assert.True(t, ChannelBeta.Includes(beta))
+		assert.True(t, ChannelNightly.Includes(beta))
+		assert.False(t, ChannelStable.Includes(beta))
+		assert.True(t, Channel("").Includes(beta))
+	})
+
+	t.Run("Should parse channel", func(t *testing.T) {
+		c, err := ParseChannel("Nightly")
+		require.NoError(t, err)
+		assert.Equal(t, ChannelNightly, c)
+
+		_, err = ParseChannel("alpha")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,270 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+var _ Client = &HTTPClient{}
+
+// Fetcher fetches the metadata of plugin repositories.
+type Fetcher interface {
+	// Fetch returns the body of the resource at the URL, or at the local path of a static repository index, with
+	// the sub paths joined to its path. Missing resources fail with an error wrapping ErrNotFound.
+	Fetch(URL string, subPaths ...string) ([]byte, error)
+}
+
+// ClientOpts configures an HTTPClient.
+type ClientOpts struct {
+	// Fetcher fetches the metadata. It defaults to NewHTTPFetcher with a client with a 10 second timeout.
+	Fetcher Fetcher
+	// GrafanaVersion is the Grafana version plugins are resolved for.
+	GrafanaVersion string
+	// Platform is the <os>-<arch> platform versions are resolved for. It defaults to the current platform.
+	Platform string
+	// Channel is the release channel latest versions are selected from. Without it, latest versions are stable
+	// ones unless AllowPrerelease is set.
+	Channel         Channel
+	AllowPrerelease bool
+	// APIVersion is the version of the grafana.com plugins API plugin metadata is fetched with.
+	APIVersion APIVersion
+	// Logger logs the requests to the repository. Nothing is logged without it.
+	Logger plugins.PluginInstallerLogger
+}
+
+// HTTPClient is the Client of a plugin repository implementing the grafana.com plugins API, or of a static or
+// generic plugin repository.
+type HTTPClient struct {
+	pluginRepoURL string
+	opts          ClientOpts
+}
+
+// NewClient returns a client of the plugin repository.
+func NewClient(pluginRepoURL string, opts ClientOpts) *HTTPClient {
+	if opts.Platform == "" {
+		opts.Platform = CurrentPlatform()
+	}
+	if opts.Fetcher == nil {
+		opts.Fetcher = NewHTTPFetcher(&http.Client{Timeout: 10 * time.Second}, opts.GrafanaVersion, opts.Platform)
+	}
+	return &HTTPClient{pluginRepoURL: pluginRepoURL, opts: opts}
+}
+
+// GetPlugin returns the plugin with its versions, newest first.
+func (c *HTTPClient) GetPlugin(pluginID string) (Plugin, error) {
+	if baseURL, generic := GenericBase(c.pluginRepoURL); generic {
+		return c.getPluginFromGenericRepo(pluginID, baseURL)
+	}
+	if index, static := StaticIndex(c.pluginRepoURL); static {
+		return c.getPluginFromStaticRepo(pluginID, index)
+	}
+	if c.opts.APIVersion != APILegacy {
+		plugin, err := c.getPluginFromAPIv2(pluginID)
+		if c.opts.APIVersion == APIV2 || (!errors.Is(err, ErrNotFound) && !errors.Is(err, errAPIv2Unsupported)) {
+			return plugin, err
+		}
+		c.debugf("Falling back to the legacy plugins API of repo %s: %v", RedactURL(c.pluginRepoURL), err)
+	}
+
+	c.debugf("Fetching metadata for plugin \"%s\" from repo %s", pluginID, RedactURL(c.pluginRepoURL))
+	body, err := c.opts.Fetcher.Fetch(c.pluginRepoURL, "repo", pluginID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return Plugin{}, &PluginNotFoundError{PluginID: pluginID}
+		}
+		return Plugin{}, errutil.Wrap("Failed to send request", err)
+	}
+	return decodePlugin(body, strings.TrimSuffix(c.pluginRepoURL, "/")+"/repo/"+pluginID)
+}
+
+// ListPlugins returns all plugins published to the repository with their versions, newest first. Generic
+// repositories can't be listed.
+func (c *HTTPClient) ListPlugins() ([]Plugin, error) {
+	if _, generic := GenericBase(c.pluginRepoURL); generic {
+		return nil, fmt.Errorf("generic plugin repositories can't be listed")
+	}
+
+	var index PluginRepo
+	indexURL, static := StaticIndex(c.pluginRepoURL)
+	if static {
+		var err error
+		if index, err = c.readStaticIndex(indexURL); err != nil {
+			return nil, err
+		}
+	} else {
+		indexURL = strings.TrimSuffix(c.pluginRepoURL, "/") + "/repo"
+		c.debugf("Listing plugins in repo %s", RedactURL(c.pluginRepoURL))
+		body, err := c.opts.Fetcher.Fetch(c.pluginRepoURL, "repo")
+		if err != nil {
+			return nil, errutil.Wrap("Failed to send request", err)
+		}
+		if err := unmarshalResponse(body, indexURL, &index); err != nil {
+			return nil, err
+		}
+	}
+
+	plugins := make([]Plugin, 0, len(index.Plugins))
+	for idx, p := range index.Plugins {
+		plugin := p
+		if err := validateResponse(&plugin, indexURL, fmt.Sprintf("plugins[%d].", idx)); err != nil {
+			c.warnf("Skipping plugin %s: %v", plugin.ID, err)
+			continue
+		}
+		SortVersions(plugin.Versions)
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// ListVersions returns all versions of the plugin published to the repository, newest first.
+func (c *HTTPClient) ListVersions(pluginID string) ([]VersionInfo, error) {
+	plugin, err := c.GetPlugin(pluginID)
+	if err != nil {
+		return nil, err
+	}
+	return VersionInfos(&plugin, c.opts.Platform, c.opts.GrafanaVersion), nil
+}
+
+// versionCheckItem is a plugin in the response of the version check endpoint of the plugins API.
+type versionCheckItem struct {
+	Slug    string `json:"slug"`
+	Version string `json:"version"`
+}
+
+// LatestVersions returns the latest version of each of the plugins that is compatible with the Grafana version, by
+// plugin ID. It asks the version check endpoint of the plugins API, reads the index of a static plugin repository
+// or looks each plugin up in a generic repository.
+func (c *HTTPClient) LatestVersions(pluginIDs []string) (map[string]string, error) {
+	latest := map[string]string{}
+	if len(pluginIDs) == 0 {
+		return latest, nil
+	}
+
+	if baseURL, generic := GenericBase(c.pluginRepoURL); generic {
+		for _, pluginID := range pluginIDs {
+			plugin, err := c.getPluginFromGenericRepo(pluginID, baseURL)
+			var notFound *PluginNotFoundError
+			if errors.As(err, &notFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if v := c.latestCompatibleVersion(&plugin); v != nil {
+				latest[plugin.ID] = v.Version
+			}
+		}
+		return latest, nil
+	}
+
+	if index, static := StaticIndex(c.pluginRepoURL); static {
+		repoIndex, err := c.readStaticIndex(index)
+		if err != nil {
+			return nil, err
+		}
+		for idx, p := range repoIndex.Plugins {
+			plugin := p
+			if !containsString(pluginIDs, plugin.ID) {
+				continue
+			}
+			if err := validateResponse(&plugin, index, fmt.Sprintf("plugins[%d].", idx)); err != nil {
+				c.warnf("Skipping plugin %s: %v", plugin.ID, err)
+				continue
+			}
+			SortVersions(plugin.Versions)
+			if v := c.latestCompatibleVersion(&plugin); v != nil {
+				latest[plugin.ID] = v.Version
+			}
+		}
+		return latest, nil
+	}
+
+	u, err := url.Parse(c.pluginRepoURL)
+	if err != nil {
+		return nil, RedactURLError(err)
+	}
+	u.Path = path.Join(u.Path, "versioncheck")
+	params := u.Query()
+	params.Set("slugIn", strings.Join(pluginIDs, ","))
+	params.Set("grafanaVersion", c.opts.GrafanaVersion)
+	u.RawQuery = params.Encode()
+
+	c.debugf("Checking latest plugin versions in repo %s", RedactURL(c.pluginRepoURL))
+	body, err := c.opts.Fetcher.Fetch(u.String())
+	if err != nil {
+		return nil, errutil.Wrap("Failed to send request", err)
+	}
+	var items []versionCheckItem
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, errutil.Wrapf(err, "invalid plugin version check response from %s", RedactURL(c.pluginRepoURL))
+	}
+	for _, item := range items {
+		if containsString(pluginIDs, item.Slug) {
+			latest[item.Slug] = item.Version
+		}
+	}
+	return latest, nil
+}
+
+// DownloadURL returns the URL the archive of the plugin version for the <os>-<arch> platform is downloaded from.
+func (c *HTTPClient) DownloadURL(pluginID string, v *Version, platform string) string {
+	return DownloadURL(c.pluginRepoURL, pluginID, v, platform)
+}
+
+// latestCompatibleVersion returns the newest version of the plugin in the channel of the client that supports its
+// platform and is compatible with its Grafana version, or nil if there's none.
+func (c *HTTPClient) latestCompatibleVersion(plugin *Plugin) *Version {
+	compatible := *plugin
+	compatible.Versions = nil
+	for _, v := range plugin.Versions {
+		ver := v
+		if CompatibleWithGrafana(&ver, c.opts.GrafanaVersion) {
+			compatible.Versions = append(compatible.Versions, ver)
+		}
+	}
+	return LatestSupportedVersion(&compatible, c.channel(), c.opts.Platform)
+}
+
+// CurrentPlatform returns the <os>-<arch> platform Grafana runs on.
+func CurrentPlatform() string {
+	return strings.ToLower(runtime.GOOS) + "-" + runtime.GOARCH
+}
+
+// channel returns the channel latest versions are selected from.
+func (c *HTTPClient) channel() Channel {
+	if c.opts.Channel == "" && !c.opts.AllowPrerelease {
+		return ChannelStable
+	}
+	return c.opts.Channel
+}
+
+func (c *HTTPClient) debugf(format string, args ...interface{}) {
+	if c.opts.Logger != nil {
+		c.opts.Logger.Debugf(format, args...)
+	}
+}
+
+func (c *HTTPClient) warnf(format string, args ...interface{}) {
+	if c.opts.Logger != nil {
+		c.opts.Logger.Warnf(format, args...)
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient(t *testing.T) {
+	t.Run("Should read plugin metadata from plugins API", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "8.0.0", r.Header.Get("grafana-version"))
+			assert.Equal(t, "linux", r.Header.Get("grafana-os"))
+			assert.Equal(t, "arm64", r.Header.Get("grafana-arch"))
+			switch r.URL.Path {
+			case "/api/plugins/repo/test-panel":
+				_, _ = w.Write([]byte(`{"id": "test-panel", "versions": [{"version": "1.0.0"},
+					{"version": "2.0.0", "arch": {"linux-amd64": {}}}]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		t.Cleanup(server.Close)
+		c := NewClient(server.URL+"/api/plugins", ClientOpts{GrafanaVersion: "8.0.0", Platform: "linux-arm64"})
+
+		plugin, err := c.GetPlugin("test-panel")
+		require.NoError(t, err)
+		assert.Equal(t, "2.0.0", plugin.Versions[0].Version)
+
+		versions, err := c.ListVersions("test-panel")
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.False(t, versions[0].Supported)
+		assert.True(t, versions[1].Supported)
+
+		_, err = c.GetPlugin("other-panel")
+		var notFound *PluginNotFoundError
+		require.ErrorAs(t, err, &notFound)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Should list plugins from plugins API", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/plugins/repo", r.URL.Path)
+			_, _ = w.Write([]byte(`{"plugins": [{"id": "test-panel", "versions": [{"version": "1.0.0"},
+				{"version": "2.0.0"}]}]}`))
+		}))
+		t.Cleanup(server.Close)
+		c := NewClient(server.URL+"/api/plugins", ClientOpts{})
+
+		plugins, err := c.ListPlugins()
+		require.NoError(t, err)
+		require.Len(t, plugins, 1)
+		assert.Equal(t, "test-panel", plugins[0].ID)
+		assert.Equal(t, "2.0.0", plugins[0].Versions[0].Version)
+	})
+
+	t.Run("Should check latest versions with plugins API", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/plugins/versioncheck", r.URL.Path)
+			assert.Equal(t, "test-panel,other-panel", r.URL.Query().Get("slugIn"))
+			assert.Equal(t, "9.0.0", r.URL.Query().Get("grafanaVersion"))
+			_, _ = w.Write([]byte(`[{"slug": "test-panel", "version": "2.0.0"}, {"slug": "unknown", "version": "1.0.0"}]`))
+		}))
+		t.Cleanup(server.Close)
+		c := NewClient(server.URL+"/api/plugins", ClientOpts{GrafanaVersion: "9.0.0"})
+
+		latest, err := c.LatestVersions([]string{"test-panel", "other-panel"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"test-panel": "2.0.0"}, latest)
+	})
+
+	t.Run("Should check latest compatible stable versions in static repository", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, StaticIndexFile), []byte(`{"plugins": [
+			{"id": "test-panel", "versions": [
+				{"version": "3.0.0", "grafanaDependency": ">=9.0.0"},
+				{"version": "2.1.0-beta.1"},
+				{"version": "2.0.0", "grafanaDependency": ">=8.0.0"}
+			]},
+			{"id": "other-panel", "versions": [{"version": "1.0.0"}]}
+		]}`), 0600))
+		c := NewClient(dir, ClientOpts{GrafanaVersion: "8.1.0"})
+
+		latest, err := c.LatestVersions([]string{"test-panel"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"test-panel": "2.0.0"}, latest)
+
+		c = NewClient(dir, ClientOpts{GrafanaVersion: "8.1.0", AllowPrerelease: true})
+		latest, err = c.LatestVersions([]string{"test-panel"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"test-panel": "2.1.0-beta.1"}, latest)
+	})
+
+	t.Run("Should search static repository", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, StaticIndexFile), []byte(`{"plugins": [
+			{"id": "test-panel", "name": "Test", "type": "panel", "versions": [{"version": "1.0.0"}]},
+			{"id": "test-app", "type": "app", "versions": [{"version": "1.0.0"}]}
+		]}`), 0600))
+		c := NewClient(dir, ClientOpts{})
+
+		results, err := c.Search("test", SearchFilters{Type: "panel"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "test-panel", results[0].ID)
+		assert.Equal(t, "1.0.0", results[0].LatestVersion)
+	})
+}
@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// httpFetcher fetches plugin repository metadata with plain GET requests.
+type httpFetcher struct {
+	client         *http.Client
+	grafanaVersion string
+	platform       string
+}
+
+// NewHTTPFetcher returns a Fetcher sending GET requests with the client, with the headers the plugins API picks
+// archives and versions by. Static repository indexes on disk are read from the file system.
+func NewHTTPFetcher(client *http.Client, grafanaVersion, platform string) Fetcher {
+	return &httpFetcher{client: client, grafanaVersion: grafanaVersion, platform: platform}
+}
+
+func (f *httpFetcher) Fetch(URL string, subPaths ...string) ([]byte, error) {
+	if len(subPaths) == 0 {
+		if _, err := os.Stat(URL); err == nil {
+			// nolint:gosec
+			return ioutil.ReadFile(URL)
+		}
+	}
+
+	u, err := url.Parse(URL)
+	if err != nil {
+		return nil, RedactURLError(err)
+	}
+	for _, v := range subPaths {
+		u.Path = path.Join(u.Path, v)
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, RedactURLError(err)
+	}
+	goos, goarch := f.platform, ""
+	if idx := strings.Index(f.platform, "-"); idx >= 0 {
+		goos, goarch = f.platform[:idx], f.platform[idx+1:]
+	}
+	req.Header.Set("grafana-version", f.grafanaVersion)
+	req.Header.Set("grafana-os", goos)
+	req.Header.Set("grafana-arch", goarch)
+	req.Header.Set("User-Agent", "grafana "+f.grafanaVersion)
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, RedactURLError(err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s responded with %s", RedactURL(u.String()), res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// APIVersion is the version of the grafana.com plugins API plugin metadata is fetched with.
+type APIVersion string
+
+const (
+	// APILegacy only uses the legacy <repo>/repo/<plugin id> endpoint.
+	APILegacy APIVersion = ""
+	// APIAuto uses the v2 API, falling back to the legacy API if the plugin repository doesn't support it.
+	APIAuto APIVersion = "auto"
+	// APIV2 only uses the v2 API.
+	APIV2 APIVersion = "v2"
+)
+
+// maxVersionPages limits the number of version pages that are fetched, in case a repository keeps linking to the
+// next page.
+const maxVersionPages = 100
+
+// errAPIv2Unsupported is returned when the plugin repository responds to the v2 API with something else.
+var errAPIv2Unsupported = errors.New("plugin repository doesn't support the v2 plugins API")
+
+// apiV2Plugin is the plugin details response of the v2 API.
+type apiV2Plugin struct {
+	Slug          string `json:"slug"`
+	TypeCode      string `json:"typeCode"`
+	SignatureType string `json:"signatureType"`
+	Status        string `json:"status"`
+}
+
+// apiV2Versions is a page of the version listing of the v2 API.
+type apiV2Versions struct {
+	Items *[]struct {
+		Version           string `json:"version"`
+		GrafanaDependency string `json:"grafanaDependency"`
+		AngularDetected   bool   `json:"angularDetected"`
+		Packages          map[string]struct {
+			SHA256 string `json:"sha256"`
+		} `json:"packages"`
+	} `json:"items"`
+	Links []struct {
+		Rel  string `json:"rel"`
+		Href string `json:"href"`
+	} `json:"links"`
+}
+
+// getPluginFromAPIv2 fetches the plugin details from <repo>/<plugin id> and all pages of its versions from
+// <repo>/<plugin id>/versions, following the links to the next page.
+func (c *HTTPClient) getPluginFromAPIv2(pluginID string) (Plugin, error) {
+	c.debugf("Fetching metadata for plugin \"%s\" from repo %s", pluginID, RedactURL(c.pluginRepoURL))
+	body, err := c.opts.Fetcher.Fetch(c.pluginRepoURL, pluginID)
+	if err != nil {
+		return Plugin{}, apiV2Error(pluginID, err)
+	}
+	var details apiV2Plugin
+	if err := json.Unmarshal(body, &details); err != nil || details.Slug == "" {
+		return Plugin{}, errAPIv2Unsupported
+	}
+
+	plugin := Plugin{
+		ID:            pluginID,
+		Category:      details.TypeCode,
+		SignatureType: details.SignatureType,
+		Status:        details.Status,
+	}
+	u, err := url.Parse(c.pluginRepoURL)
+	if err != nil {
+		return Plugin{}, RedactURLError(err)
+	}
+	u.Path = path.Join(u.Path, pluginID, "versions")
+	pageURL := u.String()
+	for page := 0; pageURL != ""; page++ {
+		if page == maxVersionPages {
+			return Plugin{}, fmt.Errorf("plugin repository lists more than %d pages of versions of %s",
+				maxVersionPages, pluginID)
+		}
+		body, err := c.opts.Fetcher.Fetch(pageURL)
+		if err != nil {
+			return Plugin{}, apiV2Error(pluginID, err)
+		}
+		var versions apiV2Versions
+		if err := json.Unmarshal(body, &versions); err != nil || versions.Items == nil {
+			return Plugin{}, errAPIv2Unsupported
+		}
+
+		for _, item := range *versions.Items {
+			v := Version{
+				Version:           item.Version,
+				GrafanaDependency: item.GrafanaDependency,
+				AngularDetected:   item.AngularDetected,
+			}
+			for platform, pkg := range item.Packages {
+				if v.Arch == nil {
+					v.Arch = map[string]ArchMeta{}
+				}
+				v.Arch[platform] = ArchMeta{SHA256: pkg.SHA256}
+			}
+			plugin.Versions = append(plugin.Versions, v)
+		}
+
+		if pageURL, err = versions.nextPage(pageURL); err != nil {
+			return Plugin{}, err
+		}
+	}
+	if err := validateResponse(&plugin, u.String(), ""); err != nil {
+		return Plugin{}, err
+	}
+	return plugin, nil
+}
+
+// nextPage returns the URL of the next page, resolved against the URL of the current page, or an empty string on
+// the last page.
+func (v *apiV2Versions) nextPage(pageURL string) (string, error) {
+	for _, link := range v.Links {
+		if link.Rel != "next" || link.Href == "" {
+			continue
+		}
+		base, err := url.Parse(pageURL)
+		if err != nil {
+			return "", RedactURLError(err)
+		}
+		next, err := url.Parse(link.Href)
+		if err != nil {
+			return "", RedactURLError(err)
+		}
+		return base.ResolveReference(next).String(), nil
+	}
+	return "", nil
+}
+
+func apiV2Error(pluginID string, err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return &PluginNotFoundError{PluginID: pluginID}
+	}
+	return errutil.Wrap("Failed to send request", err)
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"github.com/hashicorp/go-version"
+)
+
+const (
+	// GenericScheme prefixes the URL of a generic plugin repository, e.g. generic+https://example.com/plugins.
+	GenericScheme = "generic+"
+	// genericMetadataFile lists the versions of a plugin in a generic repository, in the format of a plugin of a
+	// static repository index.
+	genericMetadataFile = "metadata.json"
+	// mavenMetadataFile lists the versions of a plugin in a generic repository in the format of Maven.
+	mavenMetadataFile = "maven-metadata.xml"
+)
+
+// reListingHref matches the links of a directory listing.
+var reListingHref = regexp.MustCompile(`(?i)href\s*=\s*["']([^"'?#]+)["']`)
+
+// mavenMetadata holds the versions of a maven-metadata.xml file.
+type mavenMetadata struct {
+	Versions []string `xml:"versioning>versions>version"`
+}
+
+// A generic plugin repository stores plugin archives in the generic layout of artifact repositories like
+// Artifactory or Nexus, with a directory per plugin and a folder per version:
+//
+//   <plugin id>/<version>/<plugin id>-<version>.zip
+//   <plugin id>/<version>/<plugin id>-<version>.<os>-<arch>.zip
+//
+// The versions of a plugin are listed by an optional <plugin id>/metadata.json, in the format of a plugin of a
+// static repository index, or by an optional <plugin id>/maven-metadata.xml. Without either, they're read from
+// the directory listing of <plugin id>/.
+
+// GenericBase returns the URL of the generic plugin repository, without the generic+ prefix and trailing slash, if
+// the repository URL refers to one.
+func GenericBase(pluginRepoURL string) (string, bool) {
+	if len(pluginRepoURL) <= len(GenericScheme) ||
+		!strings.EqualFold(pluginRepoURL[:len(GenericScheme)], GenericScheme) {
+		return "", false
+	}
+	return strings.TrimSuffix(pluginRepoURL[len(GenericScheme):], "/"), true
+}
+
+// getPluginFromGenericRepo looks the plugin up in a generic repository. Versions are read from the metadata.json or
+// maven-metadata.xml of the plugin, or from its directory listing.
+func (c *HTTPClient) getPluginFromGenericRepo(pluginID, baseURL string) (Plugin, error) {
+	c.debugf("Fetching metadata for plugin \"%s\" from generic repo %s", pluginID, RedactURL(baseURL))
+	pluginURL := strings.TrimSuffix(baseURL, "/") + "/" + pluginID + "/"
+	body, err := c.opts.Fetcher.Fetch(baseURL, pluginID, genericMetadataFile)
+	if err == nil {
+		var plugin Plugin
+		if err := unmarshalResponse(body, pluginURL+genericMetadataFile, &plugin); err != nil {
+			return Plugin{}, err
+		}
+		plugin.ID = pluginID
+		if err := validateResponse(&plugin, pluginURL+genericMetadataFile, ""); err != nil {
+			return Plugin{}, err
+		}
+		SortVersions(plugin.Versions)
+		return plugin, nil
+	}
+
+	var versions []string
+	versionsURL := pluginURL + mavenMetadataFile
+	if errors.Is(err, ErrNotFound) {
+		body, err = c.opts.Fetcher.Fetch(baseURL, pluginID, mavenMetadataFile)
+		if err == nil {
+			var metadata mavenMetadata
+			if err := xml.Unmarshal(body, &metadata); err != nil {
+				return Plugin{}, &InvalidRepoResponseError{URL: versionsURL,
+					Problems: []string{fmt.Sprintf("malformed XML: %s", err)}}
+			}
+			versions = metadata.Versions
+		}
+	}
+	if errors.Is(err, ErrNotFound) {
+		// Directory listings are only served for URLs ending with a slash, which path.Join would drop
+		versionsURL = pluginURL
+		body, err = c.opts.Fetcher.Fetch(pluginURL)
+		if err == nil {
+			versions = parseDirectoryListing(body)
+		}
+	}
+	if errors.Is(err, ErrNotFound) {
+		return Plugin{}, &PluginNotFoundError{PluginID: pluginID}
+	}
+	if err != nil {
+		return Plugin{}, errutil.Wrap("Failed to send request", err)
+	}
+	if len(versions) == 0 {
+		return Plugin{}, &PluginNotFoundError{PluginID: pluginID}
+	}
+
+	plugin := Plugin{ID: pluginID}
+	for _, v := range versions {
+		plugin.Versions = append(plugin.Versions, Version{Version: strings.TrimSpace(v)})
+	}
+	if err := validateResponse(&plugin, versionsURL, ""); err != nil {
+		return Plugin{}, err
+	}
+	SortVersions(plugin.Versions)
+	return plugin, nil
+}
+
+// parseDirectoryListing returns the names of the subdirectories linked by an HTML directory listing, like the ones
+// of Artifactory and Nexus, that are versions.
+func parseDirectoryListing(body []byte) []string {
+	var versions []string
+	seen := map[string]bool{}
+	for _, m := range reListingHref.FindAllSubmatch(body, -1) {
+		href := string(m[1])
+		if !strings.HasSuffix(href, "/") {
+			continue
+		}
+		name := path.Base(href)
+		if _, err := version.NewVersion(name); err != nil || seen[name] {
+			continue
+		}
+		seen[name] = true
+		versions = append(versions, name)
+	}
+	return versions
+}
@@ -0,0 +1,28 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenericBase(t *testing.T) {
+	base, generic := GenericBase("generic+https://nexus.example/repository/plugins/")
+	assert.True(t, generic)
+	assert.Equal(t, "https://nexus.example/repository/plugins", base)
+
+	base, generic = GenericBase("GENERIC+http://localhost:8081/artifactory/plugins")
+	assert.True(t, generic)
+	assert.Equal(t, "http://localhost:8081/artifactory/plugins", base)
+
+	for _, u := range []string{"https://grafana.com/api/plugins", "generic+", "/mnt/mirror/index.json"} {
+		_, generic = GenericBase(u)
+		assert.False(t, generic, u)
+	}
+}
+
+func TestParseDirectoryListing(t *testing.T) {
+	versions := parseDirectoryListing([]byte(`<a href="https://nexus.example/repository/plugins/test-panel/2.0.0/">
+		2.0.0</a><a href='2.0.0/'>2.0.0</a><a href="latest/">latest</a><a href="2.1.0-beta.1/">`))
+	assert.Equal(t, []string{"2.0.0", "2.1.0-beta.1"}, versions)
+}
@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+type Plugin struct {
+	ID       string    `json:"id"`
+	Category string    `json:"category"`
+	Versions []Version `json:"versions"`
+	// SignatureType and Status, e.g. deprecated, are only reported by the v2 plugins API.
+	SignatureType string `json:"signatureType,omitempty"`
+	Status        string `json:"status,omitempty"`
+}
+
+type Version struct {
+	Commit          string              `json:"commit"`
+	URL             string              `json:"url"`
+	Version         string              `json:"version"`
+	Channel         string              `json:"channel,omitempty"`
+	Arch            map[string]ArchMeta `json:"arch"`
+	AngularDetected bool                `json:"angularDetected,omitempty"`
+	// GrafanaDependency is the range of Grafana versions the version is compatible with.
+	GrafanaDependency string `json:"grafanaDependency,omitempty"`
+}
+
+type ArchMeta struct {
+	SHA256 string `json:"sha256"`
+	SHA512 string `json:"sha512,omitempty"`
+	// DownloadURL is the location of the archive in a static plugin repository.
+	DownloadURL string `json:"downloadUrl,omitempty"`
+}
+
+type PluginRepo struct {
+	Plugins []Plugin `json:"plugins"`
+	Version string   `json:"version"`
+}
+
+// VersionInfo is a published version of a plugin.
+type VersionInfo struct {
+	Version string  `json:"version"`
+	Channel Channel `json:"channel"`
+	// Platforms are the platforms, e.g. linux-amd64, the version has archives for. Versions without platform
+	// specific archives list "any".
+	Platforms []string `json:"platforms"`
+	// GrafanaDependency is the range of Grafana versions the version is compatible with, e.g. >=8.0.0. It's empty
+	// if the repository doesn't report it.
+	GrafanaDependency string `json:"grafanaDependency,omitempty"`
+	// Supported reports whether the version can be installed on the current platform.
+	Supported bool `json:"supported"`
+	// Compatible reports whether the version is compatible with the target Grafana version, which is assumed if its
+	// Grafana dependency is unknown.
+	Compatible bool `json:"compatible"`
+}
+
+// SearchResult is a plugin matching a search.
+type SearchResult struct {
+	ID            string                      `json:"id"`
+	Name          string                      `json:"name"`
+	Description   string                      `json:"description,omitempty"`
+	Type          string                      `json:"type"`
+	SignatureType plugins.PluginSignatureType `json:"signatureType,omitempty"`
+	LatestVersion string                      `json:"latestVersion"`
+}
+
+// SignatureLevel is a plugin signature type, ranked by the trust it conveys.
+type SignatureLevel string
+
+const (
+	// SignatureLevelPrivate accepts any valid signature, including private ones.
+	SignatureLevelPrivate SignatureLevel = "private"
+	// SignatureLevelCommunity requires a community, commercial or Grafana signature.
+	SignatureLevelCommunity SignatureLevel = "community"
+	// SignatureLevelCommercial requires a commercial or Grafana signature.
+	SignatureLevelCommercial SignatureLevel = "commercial"
+	// SignatureLevelGrafana requires a Grafana signature.
+	SignatureLevelGrafana SignatureLevel = "grafana"
+)
+
+var signatureLevelRanks = map[SignatureLevel]int{
+	SignatureLevelPrivate:    1,
+	SignatureLevelCommunity:  2,
+	SignatureLevelCommercial: 3,
+	SignatureLevelGrafana:    4,
+}
+
+// Rank returns the rank of the signature level, higher for more trusted levels and 0 for unknown ones.
+func (l SignatureLevel) Rank() int {
+	return signatureLevelRanks[l]
+}
@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveParams are substrings of the names of query parameters carrying credentials, e.g. the X-Amz-Signature
+// and X-Amz-Credential of S3 presigned URLs, the sig of Azure SAS URLs or access_token.
+var sensitiveParams = []string{"token", "sig", "key", "secret", "password", "passwd", "credential", "auth", "session"}
+
+// RedactURL returns the URL with its user info and the values of query parameters that look like credentials
+// replaced, so that it can be logged and returned in errors. Local paths are returned unchanged.
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// Don't risk leaking anything of URLs that can't be parsed
+		if idx := strings.IndexAny(rawURL, "?#"); idx >= 0 {
+			rawURL = rawURL[:idx] + "?" + redacted
+		}
+		if idx := strings.Index(rawURL, "@"); idx >= 0 {
+			if scheme := strings.Index(rawURL, "://"); scheme >= 0 && scheme < idx {
+				rawURL = rawURL[:scheme+3] + redacted + rawURL[idx:]
+			}
+		}
+		return rawURL
+	}
+	if u.Scheme == "" {
+		return rawURL
+	}
+
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	u.RawQuery = redactQuery(u.RawQuery)
+	if u.Fragment != "" {
+		u.Fragment = redacted
+		u.RawFragment = ""
+	}
+	return u.String()
+}
+
+// redactQuery replaces the values of sensitive query parameters, keeping the order of the parameters.
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	params := strings.Split(rawQuery, "&")
+	for idx, param := range params {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		name, err := url.QueryUnescape(kv[0])
+		if err != nil || isSensitiveParam(name) {
+			params[idx] = kv[0] + "=" + redacted
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func isSensitiveParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range sensitiveParams {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURLError redacts the URL of errors returned by HTTP clients, which contain the full request URL.
+func RedactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = RedactURL(urlErr.URL)
+	}
+	return err
+}
@@ -0,0 +1,32 @@
+// Package repo contains the HTTP client and models of plugin repositories, such as the grafana.com plugins API or
+// static plugin repositories, shared by the plugin installer, the plugin update checker and grafana-cli.
+package repo
+
+// Client reads plugin metadata from a plugin repository.
+type Client interface {
+	// GetPlugin returns the plugin with its versions, newest first.
+	GetPlugin(pluginID string) (Plugin, error)
+	// ListPlugins returns all plugins published to the repository with their versions, newest first.
+	ListPlugins() ([]Plugin, error)
+	// ListVersions returns all versions of the plugin published to the repository, newest first.
+	ListVersions(pluginID string) ([]VersionInfo, error)
+	// Search returns the plugins matching all words of the query and the filters. An empty query matches all
+	// plugins.
+	Search(query string, filters SearchFilters) ([]SearchResult, error)
+	// LatestVersions returns the latest version of each of the plugins that is compatible with the Grafana
+	// version, by plugin ID. Plugins unknown to the repository are left out.
+	LatestVersions(pluginIDs []string) (map[string]string, error)
+	// DownloadURL returns the URL the archive of the plugin version for the <os>-<arch> platform is downloaded
+	// from.
+	DownloadURL(pluginID string, v *Version, platform string) string
+}
+
+// SearchFilters narrow down plugin search results. Zero values don't filter.
+type SearchFilters struct {
+	// Type is the plugin type, e.g. panel, datasource or app.
+	Type string
+	// MinSignatureLevel excludes plugins signed below the signature level, and unsigned plugins.
+	MinSignatureLevel SignatureLevel
+	// Limit is the maximum number of results.
+	Limit int
+}
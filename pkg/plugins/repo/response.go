@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned when the plugin repository doesn't have the requested resource.
+var ErrNotFound = errors.New("404 not found error")
+
+// PluginNotFoundError is returned when the plugin doesn't exist in the plugin repository.
+type PluginNotFoundError struct {
+	PluginID string
+}
+
+func (e *PluginNotFoundError) Error() string {
+	return fmt.Sprintf("failed to find plugin \"%s\" in plugin repository. Please check if plugin ID is correct",
+		e.PluginID)
+}
+
+func (e *PluginNotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+// InvalidRepoResponseError is returned when the plugin repository responds with metadata that doesn't match the
+// expected schema, listing all problems found.
+type InvalidRepoResponseError struct {
+	URL      string
+	Problems []string
+}
+
+func (e *InvalidRepoResponseError) Error() string {
+	return fmt.Sprintf("invalid plugin repository response from %s: %s", RedactURL(e.URL),
+		strings.Join(e.Problems, "; "))
+}
+
+// unmarshalResponse decodes the plugin repository response from the URL, reporting malformed JSON and fields of
+// the wrong type as InvalidRepoResponseError.
+func unmarshalResponse(body []byte, sourceURL string, v interface{}) error {
+	err := json.Unmarshal(body, v)
+	if err == nil {
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &InvalidRepoResponseError{URL: sourceURL, Problems: []string{
+			fmt.Sprintf("%s must be a %s, got a %s", typeErr.Field, typeErr.Type, typeErr.Value)}}
+	}
+	return &InvalidRepoResponseError{URL: sourceURL, Problems: []string{fmt.Sprintf("malformed JSON: %s", err)}}
+}
+
+// decodePlugin decodes and validates the plugin metadata the plugin repository responded with from the URL,
+// sorting its versions newest first.
+func decodePlugin(body []byte, sourceURL string) (Plugin, error) {
+	var plugin Plugin
+	if err := unmarshalResponse(body, sourceURL, &plugin); err != nil {
+		return Plugin{}, err
+	}
+	if err := validateResponse(&plugin, sourceURL, ""); err != nil {
+		return Plugin{}, err
+	}
+	SortVersions(plugin.Versions)
+	return plugin, nil
+}
+
+// validateResponse validates the plugin metadata read from the URL, prefixing the fields with the JSON path of the
+// plugin in the response.
+func validateResponse(plugin *Plugin, sourceURL, prefix string) error {
+	if problems := ValidatePluginAt(plugin, prefix); len(problems) > 0 {
+		return &InvalidRepoResponseError{URL: sourceURL, Problems: problems}
+	}
+	return nil
+}
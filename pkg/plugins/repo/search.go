@@ -0,0 +1,179 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// searchItem is a plugin in the grafana.com plugin search response.
+type searchItem struct {
+	Slug          string   `json:"slug"`
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	TypeCode      string   `json:"typeCode"`
+	SignatureType string   `json:"signatureType"`
+	Version       string   `json:"version"`
+	OrgName       string   `json:"orgName"`
+	Keywords      []string `json:"keywords"`
+}
+
+// staticSearchIndex is the index of a static plugin repository, whose plugins may list the fields of searchItem
+// next to their versions.
+type staticSearchIndex struct {
+	Plugins []struct {
+		ID            string    `json:"id"`
+		Name          string    `json:"name"`
+		Description   string    `json:"description"`
+		Type          string    `json:"type"`
+		SignatureType string    `json:"signatureType"`
+		OrgName       string    `json:"orgName"`
+		Keywords      []string  `json:"keywords"`
+		Versions      []Version `json:"versions"`
+	} `json:"plugins"`
+}
+
+// Search returns the plugins in the plugin repository matching all words of the query and the filters. An empty
+// query matches all plugins. Plugins whose ID or name equals the query are returned first.
+func (c *HTTPClient) Search(query string, filters SearchFilters) ([]SearchResult, error) {
+	if _, generic := GenericBase(c.pluginRepoURL); generic {
+		return nil, fmt.Errorf("generic plugin repositories can't be searched")
+	}
+
+	var items []searchItem
+	var err error
+	if index, static := StaticIndex(c.pluginRepoURL); static {
+		items, err = c.searchStaticRepo(index)
+	} else {
+		items, err = c.searchPluginRepo(query, filters)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	terms := strings.Fields(strings.ToLower(query))
+	var results []SearchResult
+	for _, item := range items {
+		if !item.matches(terms) || !filtersMatch(filters, item) {
+			continue
+		}
+		results = append(results, SearchResult{
+			ID:            item.Slug,
+			Name:          item.Name,
+			Description:   item.Description,
+			Type:          item.TypeCode,
+			SignatureType: plugins.PluginSignatureType(item.SignatureType),
+			LatestVersion: item.Version,
+		})
+	}
+
+	query = strings.TrimSpace(query)
+	sort.SliceStable(results, func(a, b int) bool {
+		return exactMatch(results[a], query) && !exactMatch(results[b], query)
+	})
+	if filters.Limit > 0 && len(results) > filters.Limit {
+		results = results[:filters.Limit]
+	}
+	return results, nil
+}
+
+// searchPluginRepo sends the query to the search endpoint of the plugin repository. The results are filtered
+// again, since the repository may ignore parameters it doesn't support.
+func (c *HTTPClient) searchPluginRepo(query string, filters SearchFilters) ([]searchItem, error) {
+	u, err := url.Parse(c.pluginRepoURL)
+	if err != nil {
+		return nil, RedactURLError(err)
+	}
+	params := u.Query()
+	if query != "" {
+		params.Set("query", query)
+	}
+	if filters.Type != "" {
+		params.Set("typeCode", filters.Type)
+	}
+	u.RawQuery = params.Encode()
+
+	c.debugf("Searching plugins in repo %s", RedactURL(c.pluginRepoURL))
+	body, err := c.opts.Fetcher.Fetch(u.String())
+	if err != nil {
+		return nil, errutil.Wrap("Failed to send request", err)
+	}
+
+	var res struct {
+		Items []searchItem `json:"items"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, errutil.Wrapf(err, "invalid plugin search response from %s", RedactURL(c.pluginRepoURL))
+	}
+	return res.Items, nil
+}
+
+// searchStaticRepo returns the plugins of a static plugin repository with their latest version supported on the
+// platform. Plugins without a supported version are skipped.
+func (c *HTTPClient) searchStaticRepo(indexURL string) ([]searchItem, error) {
+	body, err := c.opts.Fetcher.Fetch(indexURL)
+	if err != nil {
+		return nil, errutil.Wrapf(err, "failed to read plugin repository index %s", RedactURL(indexURL))
+	}
+
+	var index staticSearchIndex
+	if err := json.Unmarshal(body, &index); err != nil {
+		return nil, errutil.Wrapf(err, "invalid plugin repository index %s", RedactURL(indexURL))
+	}
+	items := make([]searchItem, 0, len(index.Plugins))
+	for _, p := range index.Plugins {
+		latest := LatestSupportedVersion(&Plugin{ID: p.ID, Versions: p.Versions}, "", c.opts.Platform)
+		if latest == nil {
+			continue
+		}
+		name := p.Name
+		if name == "" {
+			name = p.ID
+		}
+		items = append(items, searchItem{
+			Slug:          p.ID,
+			Name:          name,
+			Description:   p.Description,
+			TypeCode:      p.Type,
+			SignatureType: p.SignatureType,
+			Version:       latest.Version,
+			OrgName:       p.OrgName,
+			Keywords:      p.Keywords,
+		})
+	}
+	return items, nil
+}
+
+// matches returns whether every term occurs in the ID, name, description, keywords or organization of the plugin.
+func (item searchItem) matches(terms []string) bool {
+	text := strings.ToLower(strings.Join(append([]string{item.Slug, item.Name, item.Description, item.OrgName},
+		item.Keywords...), "\n"))
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// filtersMatch returns whether the plugin has the type and at least the signature level of the filters.
+func filtersMatch(f SearchFilters, item searchItem) bool {
+	if f.Type != "" && !strings.EqualFold(f.Type, item.TypeCode) {
+		return false
+	}
+	if f.MinSignatureLevel != "" &&
+		SignatureLevel(strings.ToLower(item.SignatureType)).Rank() < f.MinSignatureLevel.Rank() {
+		return false
+	}
+	return true
+}
+
+// exactMatch returns whether the ID or name of the result equals the query.
+func exactMatch(r SearchResult, query string) bool {
+	return query != "" && (strings.EqualFold(r.ID, query) || strings.EqualFold(r.Name, query))
+}
@@ -0,0 +1,134 @@
+package repo
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+// StaticIndexFile is the name of the index of a static plugin repository.
+const StaticIndexFile = "index.json"
+
+// A static plugin repository is a directory tree that can be served by any file server or object storage bucket,
+// or read from the file system, instead of implementing the grafana.com API. The repository URL is the URL or path
+// of its index.json, or a local directory or file:// URL containing it. The index lists the plugins and their
+// versions in the format of the grafana.com plugin list:
+//
+//   {"plugins": [{"id": "my-panel", "versions": [{"version": "1.0.0", "arch": {"any": {"sha256": "..."}}}]}]}
+//
+// The archive of a version is at <plugin id>/<version>/<plugin id>-<version>.zip relative to the index, or at
+// <plugin id>/<version>/<plugin id>-<version>.<os>-<arch>.zip if the version lists the current platform. The
+// downloadUrl of a platform overrides the location, relative to the index or absolute. Plugins may list their
+// name, description, type, signatureType, orgName and keywords to be found by Search.
+
+// StaticIndex returns the URL or path of the index if the repository URL refers to a static plugin repository.
+// Plugins in local repositories are installed straight from disk.
+func StaticIndex(pluginRepoURL string) (string, bool) {
+	index := pluginRepoURL
+	if u, err := url.Parse(pluginRepoURL); err == nil && strings.EqualFold(u.Scheme, "file") {
+		index = LocalPath(u)
+	}
+	if fi, err := os.Stat(index); err == nil && fi.IsDir() {
+		return filepath.Join(index, StaticIndexFile), true
+	}
+
+	p := index
+	if u, err := url.Parse(index); err == nil && len(u.Scheme) > 1 {
+		p = u.Path
+	}
+	p = strings.ReplaceAll(p, "\\", "/")
+	return index, p == StaticIndexFile || strings.HasSuffix(p, "/"+StaticIndexFile)
+}
+
+// LocalPath returns the file system path of a file:// URL.
+func LocalPath(u *url.URL) string {
+	p := u.Path
+	// file:///C:/plugins on Windows
+	if len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+// StaticBase returns the URL or path the files of the static repository are relative to, with a trailing
+// separator.
+func StaticBase(indexURL string) string {
+	if u, err := url.Parse(indexURL); err == nil && len(u.Scheme) > 1 {
+		u.Path = strings.TrimSuffix(u.Path, StaticIndexFile)
+		u.RawPath = ""
+		u.RawQuery = ""
+		return u.String()
+	}
+	return strings.TrimSuffix(indexURL, StaticIndexFile)
+}
+
+// DownloadURL returns the URL the archive of the plugin version for the <os>-<arch> platform is downloaded from.
+// The plugins API picks the archive by the platform reported in the request headers. Static and generic
+// repositories share the layout of the archives.
+func DownloadURL(pluginRepoURL, pluginID string, v *Version, platform string) string {
+	base, generic := GenericBase(pluginRepoURL)
+	if generic {
+		base += "/"
+	} else if index, static := StaticIndex(pluginRepoURL); static {
+		base = StaticBase(index)
+	} else {
+		return fmt.Sprintf("%s/%s/versions/%s/download",
+			pluginRepoURL,
+			pluginID,
+			v.Version,
+		)
+	}
+
+	name := fmt.Sprintf("%s-%s.zip", pluginID, v.Version)
+	if v.Arch != nil {
+		archMeta, exists := v.Arch[platform]
+		if exists {
+			name = fmt.Sprintf("%s-%s.%s.zip", pluginID, v.Version, platform)
+		} else {
+			archMeta = v.Arch["any"]
+		}
+		if archMeta.DownloadURL != "" {
+			if strings.Contains(archMeta.DownloadURL, "://") || strings.HasPrefix(archMeta.DownloadURL, "/") {
+				return archMeta.DownloadURL
+			}
+			return base + archMeta.DownloadURL
+		}
+	}
+	return fmt.Sprintf("%s%s/%s/%s", base, pluginID, v.Version, name)
+}
+
+// getPluginFromStaticRepo looks the plugin up in the index of a static plugin repository.
+func (c *HTTPClient) getPluginFromStaticRepo(pluginID, indexURL string) (Plugin, error) {
+	c.debugf("Fetching metadata for plugin \"%s\" from static repo %s", pluginID, RedactURL(indexURL))
+	index, err := c.readStaticIndex(indexURL)
+	if err != nil {
+		return Plugin{}, err
+	}
+	for idx, plugin := range index.Plugins {
+		if plugin.ID == pluginID {
+			if err := validateResponse(&plugin, indexURL, fmt.Sprintf("plugins[%d].", idx)); err != nil {
+				return Plugin{}, err
+			}
+			SortVersions(plugin.Versions)
+			return plugin, nil
+		}
+	}
+	return Plugin{}, &PluginNotFoundError{PluginID: pluginID}
+}
+
+// readStaticIndex reads the index of a static plugin repository from disk, or downloads it.
+func (c *HTTPClient) readStaticIndex(indexURL string) (PluginRepo, error) {
+	body, err := c.opts.Fetcher.Fetch(indexURL)
+	if err != nil {
+		return PluginRepo{}, errutil.Wrapf(err, "failed to read plugin repository index %s", RedactURL(indexURL))
+	}
+	var index PluginRepo
+	if err := unmarshalResponse(body, indexURL, &index); err != nil {
+		return PluginRepo{}, err
+	}
+	return index, nil
+}
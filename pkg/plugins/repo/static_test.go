@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadURL(t *testing.T) {
+	repoDir := t.TempDir()
+	for name, tc := range map[string]struct {
+		repoURL  string
+		version  Version
+		platform string
+		expected string
+	}{
+		"API": {"https://grafana.com/api/plugins", Version{Version: "1.0.0"}, "linux-amd64",
+			"https://grafana.com/api/plugins/test-panel/versions/1.0.0/download"},
+		"Any platform": {"https://bucket.example/plugins/index.json?sig=abc",
+			Version{Version: "1.0.0", Arch: map[string]ArchMeta{"any": {}}}, "linux-amd64",
+			"https://bucket.example/plugins/test-panel/1.0.0/test-panel-1.0.0.zip"},
+		"Requested platform": {"/mnt/mirror/index.json",
+			Version{Version: "1.0.0", Arch: map[string]ArchMeta{"darwin-arm64": {}, "any": {}}}, "darwin-arm64",
+			"/mnt/mirror/test-panel/1.0.0/test-panel-1.0.0.darwin-arm64.zip"},
+		"Other platform": {"/mnt/mirror/index.json",
+			Version{Version: "1.0.0", Arch: map[string]ArchMeta{"darwin-arm64": {}, "any": {}}}, "linux-amd64",
+			"/mnt/mirror/test-panel/1.0.0/test-panel-1.0.0.zip"},
+		"Generic repository": {"generic+https://artifactory.example/artifactory/plugins/",
+			Version{Version: "1.0.0", Arch: map[string]ArchMeta{"linux-amd64": {}}}, "linux-amd64",
+			"https://artifactory.example/artifactory/plugins/test-panel/1.0.0/test-panel-1.0.0.linux-amd64.zip"},
+		"Local directory": {repoDir, Version{Version: "1.0.0"}, "linux-amd64",
+			repoDir + string(filepath.Separator) + "test-panel/1.0.0/test-panel-1.0.0.zip"},
+	} {
+		v := tc.version
+		assert.Equal(t, tc.expected, DownloadURL(tc.repoURL, "test-panel", &v, tc.platform), name)
+	}
+}
+
+func TestStaticIndex(t *testing.T) {
+	dir := t.TempDir()
+	for repoURL, expected := range map[string]bool{
+		"https://grafana.com/api/plugins":       false,
+		"https://bucket.example/index.json?x=y": true,
+		"/mnt/mirror/index.json":                true,
+		dir:                                     true,
+		"file://" + filepath.ToSlash(dir):       true,
+	} {
+		_, static := StaticIndex(repoURL)
+		assert.Equal(t, expected, static, repoURL)
+	}
+}
@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// ValidatePlugin returns the problems of plugin metadata read from a plugin repository, naming the missing or
+// invalid fields by their JSON path, e.g. versions[0].arch.any.sha256.
+func ValidatePlugin(p *Plugin) []string {
+	return ValidatePluginAt(p, "")
+}
+
+// ValidatePluginAt validates the plugin like ValidatePlugin, prefixing the fields with the JSON path of the
+// plugin, e.g. plugins[3]. for a plugin of a static repository index.
+func ValidatePluginAt(p *Plugin, prefix string) []string {
+	var problems []string
+	if p.ID == "" {
+		problems = append(problems, prefix+"id is required")
+	}
+	if len(p.Versions) == 0 {
+		problems = append(problems, prefix+"versions is required")
+	}
+	for idx, v := range p.Versions {
+		field := fmt.Sprintf("%sversions[%d]", prefix, idx)
+		if v.Version == "" {
+			problems = append(problems, field+".version is required")
+		} else if _, err := version.NewVersion(v.Version); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.version %q is not a valid version", field, v.Version))
+		}
+		platforms := make([]string, 0, len(v.Arch))
+		for platform := range v.Arch {
+			platforms = append(platforms, platform)
+		}
+		sort.Strings(platforms)
+		for _, platform := range platforms {
+			archMeta := v.Arch[platform]
+			archField := fmt.Sprintf("%s.arch.%s", field, platform)
+			if parts := strings.Split(platform, "-"); platform != "any" &&
+				(len(parts) != 2 || parts[0] == "" || parts[1] == "") {
+				problems = append(problems, fmt.Sprintf("%s.arch key %q is not any or <os>-<arch>", field, platform))
+			}
+			if !isHexDigest(archMeta.SHA256, 32) {
+				problems = append(problems, fmt.Sprintf("%s.sha256 %q is not a SHA256 checksum", archField,
+					archMeta.SHA256))
+			}
+			if !isHexDigest(archMeta.SHA512, 64) {
+				problems = append(problems, fmt.Sprintf("%s.sha512 %q is not a SHA512 checksum", archField,
+					archMeta.SHA512))
+			}
+		}
+	}
+	return problems
+}
+
+// isHexDigest returns whether the checksum is empty or a hex encoded digest of the size.
+func isHexDigest(checksum string, size int) bool {
+	if checksum == "" {
+		return true
+	}
+	decoded, err := hex.DecodeString(checksum)
+	return err == nil && len(decoded) == size
+}
@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePlugin(t *testing.T) {
+	t.Run("Should accept valid plugin", func(t *testing.T) {
+		p := Plugin{ID: "test-panel", Versions: []Version{
+			{Version: "1.0.0", Arch: map[string]ArchMeta{
+				"any":         {SHA256: strings.Repeat("a", 64)},
+				"linux-amd64": {SHA512: strings.Repeat("b", 128)},
+			}},
+			{Version: "0.9.0-beta.1"},
+		}}
+		assert.Empty(t, ValidatePlugin(&p))
+	})
+
+	t.Run("Should name missing and invalid fields", func(t *testing.T) {
+		p := Plugin{Versions: []Version{
+			{},
+			{Version: "latest", Arch: map[string]ArchMeta{
+				"linux": {SHA256: "abc"},
+				"any":   {SHA512: strings.Repeat("z", 128)},
+			}},
+		}}
+		assert.Equal(t, []string{
+			"id is required",
+			"versions[0].version is required",
+			`versions[1].version "latest" is not a valid version`,
+			`versions[1].arch.any.sha512 "` + strings.Repeat("z", 128) + `" is not a SHA512 checksum`,
+			`versions[1].arch key "linux" is not any or <os>-<arch>`,
+			`versions[1].arch.linux.sha256 "abc" is not a SHA256 checksum`,
+		}, ValidatePlugin(&p))
+	})
+
+	t.Run("Should require versions", func(t *testing.T) {
+		assert.Equal(t, []string{"versions is required"}, ValidatePlugin(&Plugin{ID: "test-panel"}))
+	})
+
+	t.Run("Should prefix problems of static repository plugins", func(t *testing.T) {
+		assert.Equal(t, []string{"plugins[1].versions is required"},
+			ValidatePluginAt(&Plugin{ID: "test-app"}, "plugins[1]."))
+	})
+}
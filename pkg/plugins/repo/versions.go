@@ -0,0 +1,188 @@
+package repo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// reWildcardVersion matches version requirements like 7.x.x or 5.0+, which are used by older plugins.
+var reWildcardVersion = regexp.MustCompile(`^\d+(\.(\d+|x|\*)){0,2}\+?$`)
+
+// SortVersions sorts the versions newest first, the order plugin repositories list them in.
+func SortVersions(versions []Version) {
+	sort.SliceStable(versions, func(a, c int) bool {
+		va, errA := version.NewVersion(versions[a].Version)
+		vc, errC := version.NewVersion(versions[c].Version)
+		if errA != nil || errC != nil || va.Equal(vc) {
+			// Equal versions like 1.0.0 and v1.0.0 are ordered by name, whatever order the repository lists them in
+			return versions[a].Version > versions[c].Version
+		}
+		return va.GreaterThan(vc)
+	})
+}
+
+// SupportsPlatform reports whether the version has an archive for the <os>-<arch> platform. Versions without
+// platform specific archives support every platform.
+func SupportsPlatform(v *Version, platform string) bool {
+	if v.Arch == nil {
+		return true
+	}
+	for arch := range v.Arch {
+		if arch == platform || arch == "any" {
+			return true
+		}
+	}
+	return false
+}
+
+// LatestSupportedVersion returns the newest version of the plugin in the channel that supports the platform, or
+// nil if there's none. It expects the versions to be sorted newest first.
+func LatestSupportedVersion(plugin *Plugin, channel Channel, platform string) *Version {
+	for _, v := range plugin.Versions {
+		ver := v
+		if SupportsPlatform(&ver, platform) && channel.Includes(&ver) {
+			return &ver
+		}
+	}
+	return nil
+}
+
+// CompatibleWithGrafana reports whether the Grafana version satisfies the Grafana dependency of the plugin
+// version. Versions are compatible if either version is unknown or can't be parsed.
+func CompatibleWithGrafana(v *Version, grafanaVersion string) bool {
+	if v.GrafanaDependency == "" {
+		return true
+	}
+	gv, err := version.NewVersion(grafanaVersion)
+	if err != nil {
+		return true
+	}
+	compatible, err := VersionInRange(gv.Core(), v.GrafanaDependency)
+	return err != nil || compatible
+}
+
+// VersionInfos lists the versions of the plugin with the platforms they have archives for, and whether they
+// support the <os>-<arch> platform and are compatible with the Grafana version.
+func VersionInfos(plugin *Plugin, platform, grafanaVersion string) []VersionInfo {
+	versions := make([]VersionInfo, 0, len(plugin.Versions))
+	for _, v := range plugin.Versions {
+		ver := v
+		platforms := []string{"any"}
+		if len(ver.Arch) > 0 {
+			platforms = make([]string, 0, len(ver.Arch))
+			for p := range ver.Arch {
+				platforms = append(platforms, p)
+			}
+			sort.Strings(platforms)
+		}
+		versions = append(versions, VersionInfo{
+			Version:           ver.Version,
+			Channel:           VersionChannel(&ver),
+			Platforms:         platforms,
+			GrafanaDependency: ver.GrafanaDependency,
+			Supported:         SupportsPlatform(&ver, platform),
+			Compatible:        CompatibleWithGrafana(&ver, grafanaVersion),
+		})
+	}
+	return versions
+}
+
+// VersionInRange reports whether the version satisfies the range. Ranges are alternatives separated by ||, that
+// are satisfied if all their space or comma separated constraints are. Constraints are comparisons like >=7.0.0,
+// caret or tilde ranges like ^7.0.0 or wildcard versions like 7.x.
+func VersionInRange(v *version.Version, r string) (bool, error) {
+	for _, alternative := range strings.Split(r, "||") {
+		satisfied := true
+		for _, c := range splitConstraints(alternative) {
+			constraint, err := parseConstraint(c)
+			if err != nil {
+				return false, err
+			}
+			if !constraint.Check(v) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RequestsPrerelease reports whether the requested version, or any version of the requested range, is a
+// prerelease like 1.2.0-beta.1.
+func RequestsPrerelease(requestedVersion string) bool {
+	for _, alternative := range strings.Split(requestedVersion, "||") {
+		for _, c := range splitConstraints(alternative) {
+			v, err := version.NewVersion(strings.TrimLeft(c, "<>=!~^v "))
+			if err == nil && v.Prerelease() != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitConstraints splits the constraints of a range alternative, joining comparison operators separated from
+// their version by a space.
+func splitConstraints(alternative string) []string {
+	var constraints []string
+	operator := ""
+	for _, field := range strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == ',' }) {
+		if strings.Trim(field, "<>=!~^") == "" {
+			operator += field
+			continue
+		}
+		constraints = append(constraints, operator+field)
+		operator = ""
+	}
+	return constraints
+}
+
+// parseConstraint converts caret, tilde and wildcard constraints to comparisons.
+func parseConstraint(c string) (version.Constraints, error) {
+	switch {
+	case strings.HasPrefix(c, "^"):
+		v, err := version.NewVersion(c[1:])
+		if err != nil {
+			return nil, err
+		}
+		segments := v.Segments()
+		upper := fmt.Sprintf("%d.0.0", segments[0]+1)
+		if segments[0] == 0 {
+			upper = fmt.Sprintf("0.%d.0", segments[1]+1)
+		}
+		return version.NewConstraint(fmt.Sprintf(">=%s, <%s", v, upper))
+	case strings.HasPrefix(c, "~") && !strings.HasPrefix(c, "~>"):
+		v, err := version.NewVersion(c[1:])
+		if err != nil {
+			return nil, err
+		}
+		segments := v.Segments()
+		return version.NewConstraint(fmt.Sprintf(">=%s, <%d.%d.0", v, segments[0], segments[1]+1))
+	case reWildcardVersion.MatchString(c):
+		var parts []string
+		for _, part := range strings.Split(strings.TrimSuffix(c, "+"), ".") {
+			if _, err := strconv.Atoi(part); err != nil {
+				break
+			}
+			parts = append(parts, part)
+		}
+		if strings.HasSuffix(c, "+") {
+			return version.NewConstraint(">=" + strings.Join(parts, "."))
+		}
+		if len(parts) == 3 {
+			return version.NewConstraint("=" + c)
+		}
+		// 7.x or 7.1.x is at least 7.0.0 or 7.1.0 and below the next major or minor version
+		return version.NewConstraint("~>" + strings.Join(append(parts, "0"), "."))
+	default:
+		return version.NewConstraint(c)
+	}
+}
@@ -0,0 +1,35 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionInRange(t *testing.T) {
+	for r, expected := range map[string]bool{
+		">=8.0.0":         true,
+		">= 8.0.0 < 8.2":  false,
+		">=7.0.0, <9.0.0": true,
+		"^8.1.0":          true,
+		"^7.0.0":          false,
+		"~8.1.0":          false,
+		"~8.2.0":          true,
+		"8.x":             true,
+		"8.2.x":           true,
+		"7.x.x":           false,
+		"8.2.3":           true,
+		"7.0.0+":          true,
+		"<7.0.0 || >=8.2": true,
+		"<7.0.0 || >=9.0": false,
+	} {
+		satisfied, err := VersionInRange(version.Must(version.NewVersion("8.2.3")), r)
+		require.NoError(t, err, r)
+		assert.Equal(t, expected, satisfied, r)
+	}
+
+	_, err := VersionInRange(version.Must(version.NewVersion("8.2.3")), "^latest")
+	require.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package plugins
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+)
+
+// PluginManifestFile is the name of the signed manifest of a plugin.
+const PluginManifestFile = "MANIFEST.txt"
+
+// PluginManifest holds details for the file manifest
+type PluginManifest struct {
+	Plugin  string            `json:"plugin"`
+	Version string            `json:"version"`
+	KeyID   string            `json:"keyId"`
+	Time    int64             `json:"time"`
+	Files   map[string]string `json:"files"`
+
+	// V2 supported fields
+	ManifestVersion string              `json:"manifestVersion"`
+	SignatureType   PluginSignatureType `json:"signatureType"`
+	SignedByOrg     string              `json:"signedByOrg"`
+	SignedByOrgName string              `json:"signedByOrgName"`
+	RootURLs        []string            `json:"rootUrls"`
+}
+
+func (m *PluginManifest) isV2() bool {
+	return strings.HasPrefix(m.ManifestVersion, "2.")
+}
+
+// ReadPluginManifest attempts to read and verify the plugin manifest against the plugin signing key, see
+// PublicKeyText. If any error occurs or the manifest is not valid, this will return an error.
+func ReadPluginManifest(body []byte) (*PluginManifest, error) {
+	block, _ := clearsign.Decode(body)
+	if block == nil {
+		return nil, errors.New("unable to decode manifest")
+	}
+
+	// Convert to a well typed object
+	manifest := &PluginManifest{}
+	err := json.Unmarshal(block.Plaintext, &manifest)
+	if err != nil {
+		return nil, errutil.Wrap("Error parsing manifest JSON", err)
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewBufferString(PublicKeyText))
+	if err != nil {
+		return nil, errutil.Wrap("failed to parse public key", err)
+	}
+
+	if _, err := openpgp.CheckDetachedSignature(keyring,
+		bytes.NewBuffer(block.Bytes),
+		block.ArmoredSignature.Body); err != nil {
+		return nil, errutil.Wrap("failed to check signature", err)
+	}
+
+	return manifest, nil
+}
+
+// GetPluginSignatureState verifies the manifest in the plugin directory against the ID and version of the plugin
+// and its files, the paths relative to the plugin directory as returned by CollectPluginFiles. The root URLs of
+// private signatures are checked against the app URL. For signatures that aren't valid, it returns the reason. An
+// error is only returned if the app URL or a root URL can't be parsed.
+func GetPluginSignatureState(pluginDir, pluginID, version, appURL string, files []string) (PluginSignatureState,
+	string, error) {
+	return getPluginSignatureState(pluginDir, pluginID, version, appURL, true, files)
+}
+
+// GetPluginSignatureStateIgnoringRootURLs is like GetPluginSignatureState, but doesn't check the root URLs of
+// private signatures. It's meant for tools that verify plugins outside of the Grafana instance they're for.
+func GetPluginSignatureStateIgnoringRootURLs(pluginDir, pluginID, version string, files []string) (
+	PluginSignatureState, string, error) {
+	return getPluginSignatureState(pluginDir, pluginID, version, "", false, files)
+}
+
+func getPluginSignatureState(pluginDir, pluginID, version, appURL string, checkRootURLs bool,
+	files []string) (PluginSignatureState, string, error) {
+	// nolint:gosec
+	// We can ignore the gosec G304 warning on this one because the path is based
+	// on the plugin folder structure on disk and not user input.
+	byteValue, err := ioutil.ReadFile(filepath.Join(pluginDir, PluginManifestFile))
+	if err != nil || len(byteValue) < 10 {
+		return PluginSignatureState{Status: PluginSignatureUnsigned}, "no MANIFEST.txt found", nil
+	}
+
+	manifest, err := ReadPluginManifest(byteValue)
+	if err != nil {
+		return PluginSignatureState{Status: PluginSignatureInvalid}, err.Error(), nil
+	}
+	modified := PluginSignatureState{Status: PluginSignatureModified}
+
+	// Make sure the versions all match
+	if manifest.Plugin != pluginID || manifest.Version != version {
+		return modified, fmt.Sprintf("manifest is for %s v%s, but plugin.json declares %s v%s", manifest.Plugin,
+			manifest.Version, pluginID, version), nil
+	}
+
+	// Validate that private is running within defined root URLs
+	if manifest.SignatureType == PrivateType && checkRootURLs {
+		match, err := matchesRootURL(manifest.RootURLs, appURL)
+		if err != nil {
+			return PluginSignatureState{}, "", err
+		}
+		if !match {
+			return PluginSignatureState{Status: PluginSignatureInvalid},
+				fmt.Sprintf("private signature is for %s, which doesn't match the app URL %s",
+					strings.Join(manifest.RootURLs, ", "), appURL), nil
+		}
+	}
+
+	// Verify the manifest contents
+	for p, hash := range manifest.Files {
+		sum, err := FileSHA256(filepath.Join(pluginDir, filepath.FromSlash(p)))
+		if err != nil {
+			return modified, fmt.Sprintf("file %s listed in the manifest can't be read", p), nil
+		}
+		if sum != hash {
+			return modified, fmt.Sprintf("file %s has been modified", p), nil
+		}
+	}
+
+	if manifest.isV2() {
+		// Track files missing from the manifest
+		var unsignedFiles []string
+		for _, f := range files {
+			if _, exists := manifest.Files[f]; !exists {
+				unsignedFiles = append(unsignedFiles, f)
+			}
+		}
+
+		if len(unsignedFiles) > 0 {
+			return modified, fmt.Sprintf("files not included in the signature: %s",
+				strings.Join(unsignedFiles, ", ")), nil
+		}
+	}
+
+	// Everything OK
+	return PluginSignatureState{
+		Status:     PluginSignatureValid,
+		Type:       manifest.SignatureType,
+		SigningOrg: manifest.SignedByOrgName,
+	}, "", nil
+}
+
+// CollectPluginFiles returns the paths of the files in the plugin directory, relative to it and separated by
+// slashes, except for the manifest.
+func CollectPluginFiles(pluginDir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(pluginDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Name() != PluginManifestFile {
+			file, err := filepath.Rel(pluginDir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(file))
+		}
+		return nil
+	})
+	return files, err
+}
+
+// matchesRootURL reports whether one of the root URLs of a private signature matches the app URL.
+func matchesRootURL(rootURLs []string, appURL string) (bool, error) {
+	app, err := url.Parse(appURL)
+	if err != nil {
+		return false, err
+	}
+	for _, u := range rootURLs {
+		root, err := url.Parse(u)
+		if err != nil {
+			return false, errutil.Wrapf(err, "could not parse plugin root URL %q", u)
+		}
+		if root.Scheme == app.Scheme && root.Host == app.Host && root.RequestURI() == app.RequestURI() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FileSHA256 returns the hex encoded SHA-256 checksum of the file.
+func FileSHA256(path string) (string, error) {
+	// nolint:gosec
+	// We can ignore the gosec G304 warning on this one because the path is based
+	// on the manifest file for a plugin and not user input.
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -249,16 +249,45 @@ type Cfg struct {
 	CSPEnabled bool
 	// CSPTemplate contains the Content Security Policy template.
 	CSPTemplate string
+	// AngularSupportEnabled toggles support for plugins built with AngularJS.
+	AngularSupportEnabled bool
 
 	TempDataLifetime         time.Duration
 	PluginsEnableAlpha       bool
 	PluginsAppsSkipVerifyTLS bool
 	PluginSettings           PluginSettings
 	PluginsAllowUnsigned     []string
+	PluginSourceAliases      map[string]PluginSourceAlias
+	PluginPins               map[string]PluginPin
+	PluginProvides           map[string][]string
+	PluginsInstallAllowList  []string
+	PluginsInstallDenyList   []string
+	PluginsAllowedSources    []string
+	PluginsRedirectHosts     []string
+	PluginRepoMirrors        []string
+	PluginsSourcePolicyPath  string
+	PluginsInstallAuditLog   string
 	MarketplaceURL           string
 	DisableSanitizeHtml      bool
 	EnterpriseLicensePath    string
 
+	// Content grafana-cli refuses to install in the plugins directory
+	PluginsInstallDenyNestedArchives bool
+	PluginsInstallDeniedExtensions   []string
+
+	// Plugin repository metadata cache of grafana-cli
+	PluginRepoMetadataCacheDir      string
+	PluginRepoMetadataCacheMaxAge   time.Duration
+	PluginRepoMetadataCacheMaxStale time.Duration
+
+	// Plugin archive download cache of grafana-cli
+	PluginDownloadCacheDir     string
+	PluginDownloadCacheMaxSize int64
+
+	// Plugin repository mirror selection of grafana-cli
+	PluginRepoMirrorsByLatency    bool
+	PluginRepoMirrorProbeInterval time.Duration
+
 	// Metrics
 	MetricsEndpointEnabled           bool
 	MetricsEndpointBasicAuthUsername string
@@ -883,11 +912,30 @@ func (cfg *Cfg) Load(args *CommandLineArgs) error {
 	cfg.PluginsEnableAlpha = pluginsSection.Key("enable_alpha").MustBool(false)
 	cfg.PluginsAppsSkipVerifyTLS = pluginsSection.Key("app_tls_skip_verify_insecure").MustBool(false)
 	cfg.PluginSettings = extractPluginSettings(iniFile.Sections())
+	cfg.PluginSourceAliases = extractPluginSourceAliases(iniFile)
+	cfg.PluginPins = extractPluginPins(iniFile)
+	cfg.PluginProvides = extractPluginProvides(iniFile)
 	pluginsAllowUnsigned := pluginsSection.Key("allow_loading_unsigned_plugins").MustString("")
 	for _, plug := range strings.Split(pluginsAllowUnsigned, ",") {
 		plug = strings.TrimSpace(plug)
 		cfg.PluginsAllowUnsigned = append(cfg.PluginsAllowUnsigned, plug)
 	}
+	cfg.PluginsInstallAllowList = util.SplitString(pluginsSection.Key("install_allow_list").MustString(""))
+	cfg.PluginsInstallDenyList = util.SplitString(pluginsSection.Key("install_deny_list").MustString(""))
+	cfg.PluginsAllowedSources = util.SplitString(pluginsSection.Key("install_allowed_sources").MustString(""))
+	cfg.PluginsRedirectHosts = util.SplitString(pluginsSection.Key("install_redirect_hosts").MustString(""))
+	cfg.PluginRepoMirrors = util.SplitString(pluginsSection.Key("repo_mirrors").MustString(""))
+	cfg.PluginRepoMirrorsByLatency = pluginsSection.Key("repo_mirrors_by_latency").MustBool(false)
+	cfg.PluginRepoMirrorProbeInterval = pluginsSection.Key("repo_mirror_probe_interval").MustDuration(10 * time.Minute)
+	cfg.PluginsSourcePolicyPath = pluginsSection.Key("install_source_policy").MustString("")
+	cfg.PluginsInstallAuditLog = pluginsSection.Key("install_audit_log").MustString("")
+	cfg.PluginsInstallDenyNestedArchives = pluginsSection.Key("install_deny_nested_archives").MustBool(false)
+	cfg.PluginsInstallDeniedExtensions = util.SplitString(pluginsSection.Key("install_denied_extensions").MustString(""))
+	cfg.PluginRepoMetadataCacheDir = pluginsSection.Key("repo_metadata_cache_dir").MustString("")
+	cfg.PluginRepoMetadataCacheMaxAge = pluginsSection.Key("repo_metadata_cache_max_age").MustDuration(0)
+	cfg.PluginRepoMetadataCacheMaxStale = pluginsSection.Key("repo_metadata_cache_max_stale").MustDuration(24 * time.Hour)
+	cfg.PluginDownloadCacheDir = pluginsSection.Key("download_cache_dir").MustString("")
+	cfg.PluginDownloadCacheMaxSize = pluginsSection.Key("download_cache_max_size").MustInt64(1024) << 20
 	cfg.MarketplaceURL = pluginsSection.Key("marketplace_url").MustString("https://grafana.com/grafana/plugins/")
 
 	// Read and populate feature toggles list
@@ -1112,6 +1160,7 @@ func readSecuritySettings(iniFile *ini.File, cfg *Cfg) error {
 	cfg.StrictTransportSecuritySubDomains = security.Key("strict_transport_security_subdomains").MustBool(false)
 	cfg.CSPEnabled = security.Key("content_security_policy").MustBool(false)
 	cfg.CSPTemplate = security.Key("content_security_policy_template").MustString("")
+	cfg.AngularSupportEnabled = security.Key("angular_support_enabled").MustBool(true)
 
 	// read data source proxy whitelist
 	DataProxyWhiteList = make(map[string]bool)
@@ -3,6 +3,7 @@ package setting
 import (
 	"strings"
 
+	"github.com/grafana/grafana/pkg/util"
 	"gopkg.in/ini.v1"
 )
 
@@ -23,3 +24,103 @@ func extractPluginSettings(sections []*ini.Section) PluginSettings {
 
 	return psMap
 }
+
+// PluginSourceAlias is a named plugin repository that plugins can be installed from as alias:pluginID.
+type PluginSourceAlias struct {
+	URL              string
+	Token            string
+	Username         string
+	Password         string
+	Header           string
+	CredentialHelper string
+	SkipTLSVerify    bool
+	CACertPath       string
+	ClientCertPath   string
+	ClientKeyPath    string
+	Proxy            string
+	NoProxy          []string
+	Plugins          []string
+	Priority         int
+}
+
+// extractPluginSourceAliases reads the aliases listed in the [plugin_sources] section, with optional
+// credentials, TLS, proxy and routing settings from the matching [plugin_source.<alias>] section.
+func extractPluginSourceAliases(iniFile *ini.File) map[string]PluginSourceAlias {
+	aliases := map[string]PluginSourceAlias{}
+	for _, key := range iniFile.Section("plugin_sources").Keys() {
+		url := strings.TrimSpace(key.String())
+		if url == "" {
+			continue
+		}
+
+		section := iniFile.Section("plugin_source." + key.Name())
+		alias := PluginSourceAlias{
+			URL:              url,
+			Token:            section.Key("token").String(),
+			Username:         section.Key("username").String(),
+			Password:         section.Key("password").String(),
+			Header:           section.Key("header").String(),
+			CredentialHelper: section.Key("credential_helper").String(),
+			SkipTLSVerify:    section.Key("tls_skip_verify").MustBool(false),
+			CACertPath:       section.Key("tls_ca_cert").String(),
+			ClientCertPath:   section.Key("tls_client_cert").String(),
+			ClientKeyPath:    section.Key("tls_client_key").String(),
+			Proxy:            section.Key("proxy").String(),
+			Priority:         section.Key("priority").MustInt(0),
+		}
+		if noProxy := section.Key("no_proxy").String(); noProxy != "" {
+			alias.NoProxy = util.SplitString(noProxy)
+		}
+		if plugins := section.Key("plugins").String(); plugins != "" {
+			alias.Plugins = util.SplitString(plugins)
+		}
+		aliases[key.Name()] = alias
+	}
+
+	return aliases
+}
+
+// PluginPin pins a dependency plugin to a version, overriding the version the plugins depending on it require.
+type PluginPin struct {
+	Version string
+	// Source is the plugin source alias or the URL of the archive the plugin is installed from instead.
+	Source string
+}
+
+// extractPluginPins reads the dependency pins of the [plugin_pins] section, which map plugin ids to
+// "<version> [<source alias or archive URL>]".
+func extractPluginPins(iniFile *ini.File) map[string]PluginPin {
+	pins := map[string]PluginPin{}
+	for _, key := range iniFile.Section("plugin_pins").Keys() {
+		fields := strings.Fields(key.String())
+		if len(fields) == 0 {
+			continue
+		}
+		pin := PluginPin{Version: fields[0]}
+		if len(fields) > 1 {
+			pin.Source = fields[1]
+		}
+		pins[key.Name()] = pin
+	}
+
+	return pins
+}
+
+// extractPluginProvides reads the [plugin_provides] section, which maps plugin ids to the comma-separated ids of
+// the plugins they provide, like the plugins they're forks of.
+func extractPluginProvides(iniFile *ini.File) map[string][]string {
+	provides := map[string][]string{}
+	for _, key := range iniFile.Section("plugin_provides").Keys() {
+		var provided []string
+		for _, id := range strings.Split(key.String(), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				provided = append(provided, id)
+			}
+		}
+		if len(provided) > 0 {
+			provides[key.Name()] = provided
+		}
+	}
+
+	return provides
+}
@@ -41,3 +41,88 @@ func TestPluginSettings(t *testing.T) {
 	require.Equal(t, ps["plugin2"]["key3"], "value3")
 	require.Equal(t, ps["plugin2"]["key4"], "value4")
 }
+
+func TestPluginSourceAliases(t *testing.T) {
+	cfg := NewCfg()
+	sec, err := cfg.Raw.NewSection("plugin_sources")
+	require.NoError(t, err)
+	_, err = sec.NewKey("corp", "https://artifacts.corp/grafana-plugins")
+	require.NoError(t, err)
+	_, err = sec.NewKey("public", "https://plugins.example.com")
+	require.NoError(t, err)
+	_, err = sec.NewKey("empty", "")
+	require.NoError(t, err)
+
+	sec, err = cfg.Raw.NewSection("plugin_source.corp")
+	require.NoError(t, err)
+	_, err = sec.NewKey("token", "secret")
+	require.NoError(t, err)
+	_, err = sec.NewKey("credential_helper", "corp-credentials")
+	require.NoError(t, err)
+	_, err = sec.NewKey("tls_skip_verify", "true")
+	require.NoError(t, err)
+	_, err = sec.NewKey("tls_ca_cert", "/etc/ssl/corp.pem")
+	require.NoError(t, err)
+	_, err = sec.NewKey("tls_client_cert", "/etc/ssl/client.crt")
+	require.NoError(t, err)
+	_, err = sec.NewKey("tls_client_key", "/etc/ssl/client.key")
+	require.NoError(t, err)
+	_, err = sec.NewKey("proxy", "direct")
+	require.NoError(t, err)
+	_, err = sec.NewKey("no_proxy", "storage.corp, 10.0.0.0/8")
+	require.NoError(t, err)
+	_, err = sec.NewKey("plugins", "corp-*, /^acme-.+-app$/")
+	require.NoError(t, err)
+	_, err = sec.NewKey("priority", "10")
+	require.NoError(t, err)
+
+	aliases := extractPluginSourceAliases(cfg.Raw)
+	require.Len(t, aliases, 2)
+	require.Equal(t, PluginSourceAlias{
+		URL:              "https://artifacts.corp/grafana-plugins",
+		Token:            "secret",
+		CredentialHelper: "corp-credentials",
+		SkipTLSVerify:    true,
+		CACertPath:       "/etc/ssl/corp.pem",
+		ClientCertPath:   "/etc/ssl/client.crt",
+		ClientKeyPath:    "/etc/ssl/client.key",
+		Proxy:            "direct",
+		NoProxy:          []string{"storage.corp", "10.0.0.0/8"},
+		Plugins:          []string{"corp-*", "/^acme-.+-app$/"},
+		Priority:         10,
+	}, aliases["corp"])
+	require.Equal(t, PluginSourceAlias{URL: "https://plugins.example.com"}, aliases["public"])
+}
+
+func TestPluginPins(t *testing.T) {
+	cfg := NewCfg()
+	sec, err := cfg.Raw.NewSection("plugin_pins")
+	require.NoError(t, err)
+	_, err = sec.NewKey("grafana-piechart-panel", "1.6.2")
+	require.NoError(t, err)
+	_, err = sec.NewKey("corp-panel", " 2.1.0  https://artifacts.corp/corp-panel-2.1.0.zip")
+	require.NoError(t, err)
+	_, err = sec.NewKey("empty", "")
+	require.NoError(t, err)
+
+	pins := extractPluginPins(cfg.Raw)
+	require.Equal(t, map[string]PluginPin{
+		"grafana-piechart-panel": {Version: "1.6.2"},
+		"corp-panel":             {Version: "2.1.0", Source: "https://artifacts.corp/corp-panel-2.1.0.zip"},
+	}, pins)
+}
+
+func TestPluginProvides(t *testing.T) {
+	cfg := NewCfg()
+	sec, err := cfg.Raw.NewSection("plugin_provides")
+	require.NoError(t, err)
+	_, err = sec.NewKey("corp-piechart-panel", "grafana-piechart-panel, ,piechart-panel")
+	require.NoError(t, err)
+	_, err = sec.NewKey("empty", " ")
+	require.NoError(t, err)
+
+	provides := extractPluginProvides(cfg.Raw)
+	require.Equal(t, map[string][]string{
+		"corp-piechart-panel": {"grafana-piechart-panel", "piechart-panel"},
+	}, provides)
+}